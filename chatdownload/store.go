@@ -0,0 +1,259 @@
+package chatdownload
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"subtuber-services/models"
+	"subtuber-services/pathsafe"
+)
+
+// LogDir 聊天记录保存目录
+const LogDir = "./chat_logs"
+
+// CompressedExt 压缩聊天记录的扩展名，新保存的聊天记录均为 gzip 压缩
+const CompressedExt = ".json.gz"
+
+// twitchLogPattern Twitch 聊天记录文件名匹配模式（不含扩展名）
+func twitchLogPattern(videoID string) string {
+	return filepath.Join(LogDir, fmt.Sprintf("chat_%s_*", pathsafe.Name(videoID)))
+}
+
+// youtubeLogPattern YouTube 聊天记录文件名匹配模式（不含扩展名）
+func youtubeLogPattern(videoID string) string {
+	return filepath.Join(LogDir, fmt.Sprintf("chat_youtube_%s_*", pathsafe.Name(videoID)))
+}
+
+// findLog 按模式查找聊天记录，逐行 JSON、压缩与未压缩的文件都匹配，优先返回逐行 JSON 与压缩文件
+func findLog(pattern string) string {
+	for _, ext := range []string{StreamExt, CompressedExt, ".json"} {
+		matches, err := filepath.Glob(pattern + ext)
+		if err == nil && len(matches) > 0 {
+			return matches[0]
+		}
+	}
+	return ""
+}
+
+// FindTwitchLog 查找视频的 Twitch 聊天记录文件，不存在时返回空字符串
+func FindTwitchLog(videoID string) string {
+	return findLog(twitchLogPattern(videoID))
+}
+
+// FindYouTubeLog 查找视频的 YouTube 聊天记录文件，不存在时返回空字符串
+func FindYouTubeLog(videoID string) string {
+	return findLog(youtubeLogPattern(videoID))
+}
+
+// SaveTwitch 将 Twitch 聊天记录保存为 chat_{videoID}_{时间}.json.gz，返回文件路径
+func SaveTwitch(response *models.TwitchChatDownloadResponse) (string, error) {
+	filename := fmt.Sprintf("chat_%s_%s%s", pathsafe.Name(response.VideoID), time.Now().Format("20060102_150405"), CompressedExt)
+	return writeLog(filename, response)
+}
+
+// SaveYouTube 将 YouTube 聊天记录保存为 chat_youtube_{videoID}_{时间}.json.gz，返回文件路径
+func SaveYouTube(videoID string, logs []models.YoutubeChatLog) (string, error) {
+	filename := fmt.Sprintf("chat_youtube_%s_%s%s", pathsafe.Name(videoID), time.Now().Format("20060102_150405"), CompressedExt)
+	return writeLog(filename, logs)
+}
+
+// logReader 聊天记录读取器，关闭时同时关闭解压器与底层文件
+type logReader struct {
+	io.Reader
+	gz   *gzip.Reader
+	file *os.File
+}
+
+func (r *logReader) Close() error {
+	if r.gz != nil {
+		r.gz.Close()
+	}
+	return r.file.Close()
+}
+
+// OpenLog 打开聊天记录文件，gzip 压缩的文件自动解压，调用方负责关闭
+func OpenLog(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(f)
+	if magic, _ := br.Peek(2); !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return &logReader{Reader: br, file: f}, nil
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("解压聊天记录失败: %w", err)
+	}
+	return &logReader{Reader: gz, gz: gz, file: f}, nil
+}
+
+// ReadLog 读取聊天记录文件的完整 JSON 内容，gzip 压缩的文件自动解压
+func ReadLog(path string) ([]byte, error) {
+	r, err := OpenLog(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// LoadTwitch 读取完整的 Twitch 聊天记录文件（逐行 JSON 或整份 JSON）
+// 所有评论都会载入内存，只需逐条处理评论时使用 ReadTwitch
+func LoadTwitch(path string) (*models.TwitchChatDownloadResponse, error) {
+	var comments []models.TwitchChatComment
+	header, err := ReadTwitch(path, func(comment models.TwitchChatComment) error {
+		comments = append(comments, comment)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwitchChatDownloadResponse{
+		VideoID:       header.VideoID,
+		TotalComments: len(comments),
+		Comments:      comments,
+		VideoInfo:     header.VideoInfo,
+		DownloadedAt:  header.DownloadedAt,
+	}, nil
+}
+
+// LoadYouTube 读取 YouTube 聊天记录文件
+func LoadYouTube(path string) ([]models.YoutubeChatLog, error) {
+	data, err := ReadLog(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取聊天记录失败: %w", err)
+	}
+
+	var logs []models.YoutubeChatLog
+	if err := json.Unmarshal(data, &logs); err != nil {
+		return nil, fmt.Errorf("解析聊天记录失败: %w", err)
+	}
+	return logs, nil
+}
+
+// writeLog 序列化并以 gzip 压缩写入聊天记录文件
+func writeLog(filename string, v interface{}) (string, error) {
+	if err := os.MkdirAll(LogDir, 0755); err != nil {
+		return "", fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("序列化JSON失败: %w", err)
+	}
+
+	path := filepath.Join(LogDir, filename)
+	if err := writeGzipFile(path, jsonData); err != nil {
+		return "", fmt.Errorf("写入文件失败: %w", err)
+	}
+
+	log.Printf("聊天记录已保存到文件: %s", path)
+	return path, nil
+}
+
+// writeGzipFile 先写入临时文件再重命名，避免中断时留下不完整的压缩文件
+func writeGzipFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(f)
+	if _, err = gz.Write(data); err == nil {
+		err = gz.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// CompressStats 聊天记录压缩迁移的统计
+type CompressStats struct {
+	Files       int   `json:"files"`
+	Failed      int   `json:"failed"`
+	BytesBefore int64 `json:"bytes_before"`
+	BytesAfter  int64 `json:"bytes_after"`
+}
+
+// CompressLogs 将聊天记录目录中未压缩的 .json 文件转换为 .json.gz
+// 压缩结果校验可以正常解析后才删除原文件，单个文件失败不影响其他文件
+func CompressLogs() (CompressStats, error) {
+	var stats CompressStats
+
+	paths, err := filepath.Glob(filepath.Join(LogDir, "chat_*.json"))
+	if err != nil {
+		return stats, err
+	}
+
+	for _, path := range paths {
+		before, after, err := compressLog(path)
+		if err != nil {
+			log.Printf("压缩聊天记录 %s 失败: %v", path, err)
+			stats.Failed++
+			continue
+		}
+		stats.Files++
+		stats.BytesBefore += before
+		stats.BytesAfter += after
+	}
+
+	log.Printf("聊天记录压缩完成: %d 个文件，%d 个失败，%d -> %d 字节", stats.Files, stats.Failed, stats.BytesBefore, stats.BytesAfter)
+	return stats, nil
+}
+
+// compressLog 压缩单个聊天记录文件，返回压缩前后的大小
+func compressLog(path string) (int64, int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !json.Valid(data) {
+		return 0, 0, fmt.Errorf("不是有效的JSON文件")
+	}
+
+	// 旧文件为缩进格式，压缩时一并去掉多余的空白
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return 0, 0, err
+	}
+
+	target := strings.TrimSuffix(path, ".json") + CompressedExt
+	if err := writeGzipFile(target, compact.Bytes()); err != nil {
+		return 0, 0, err
+	}
+
+	restored, err := ReadLog(target)
+	if err != nil || !bytes.Equal(restored, compact.Bytes()) {
+		os.Remove(target)
+		return 0, 0, fmt.Errorf("压缩结果校验失败: %v", err)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := os.Remove(path); err != nil {
+		return 0, 0, err
+	}
+	return int64(len(data)), info.Size(), nil
+}
@@ -0,0 +1,255 @@
+package chatdownload
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"subtuber-services/models"
+	"subtuber-services/pathsafe"
+)
+
+// StreamExt 逐行 JSON（NDJSON）格式的 Twitch 聊天记录扩展名，gzip 压缩
+// 首行为录像信息（TwitchLogHeader），之后每行一条评论，下载时边获取边写入，不在内存中保留评论
+const StreamExt = ".ndjson.gz"
+
+// TwitchLogHeader Twitch 聊天记录中除评论以外的信息
+type TwitchLogHeader struct {
+	VideoID      string                  `json:"video_id"`
+	VideoInfo    *models.TwitchVideoData `json:"video_info,omitempty"`
+	DownloadedAt string                  `json:"downloaded_at"`
+}
+
+// TwitchLogWriter 将 Twitch 评论逐条写入 chat_{videoID}_{时间}.ndjson.gz
+// 写入临时文件，Close 时才重命名为正式文件，下载中断不会留下不完整的聊天记录
+type TwitchLogWriter struct {
+	path  string
+	tmp   string
+	file  *os.File
+	gz    *gzip.Writer
+	enc   *json.Encoder
+	count int
+}
+
+// CreateTwitchLog 创建流式聊天记录并写入首行录像信息
+func CreateTwitchLog(header TwitchLogHeader) (*TwitchLogWriter, error) {
+	if err := os.MkdirAll(LogDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	filename := fmt.Sprintf("chat_%s_%s%s", pathsafe.Name(header.VideoID), time.Now().Format("20060102_150405"), StreamExt)
+	path := filepath.Join(LogDir, filename)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("创建文件失败: %w", err)
+	}
+
+	w := &TwitchLogWriter{path: path, tmp: path + ".tmp", file: f, gz: gzip.NewWriter(f)}
+	w.enc = json.NewEncoder(w.gz)
+	w.enc.SetEscapeHTML(false)
+	if err := w.enc.Encode(header); err != nil {
+		w.Abort()
+		return nil, fmt.Errorf("写入文件失败: %w", err)
+	}
+	return w, nil
+}
+
+// Write 写入一条评论
+func (w *TwitchLogWriter) Write(comment models.TwitchChatComment) error {
+	if err := w.enc.Encode(comment); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	w.count++
+	return nil
+}
+
+// Count 已写入的评论数
+func (w *TwitchLogWriter) Count() int {
+	return w.count
+}
+
+// Close 完成写入并重命名为正式文件，返回文件路径
+func (w *TwitchLogWriter) Close() (string, error) {
+	err := w.gz.Close()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(w.tmp, w.path)
+	}
+	if err != nil {
+		os.Remove(w.tmp)
+		return "", fmt.Errorf("写入文件失败: %w", err)
+	}
+
+	log.Printf("聊天记录已保存到文件: %s (%d 条评论)", w.path, w.count)
+	return w.path, nil
+}
+
+// Abort 放弃写入并删除临时文件
+func (w *TwitchLogWriter) Abort() {
+	w.gz.Close()
+	w.file.Close()
+	os.Remove(w.tmp)
+}
+
+// errStopReading 读取首行后提前结束
+var errStopReading = errors.New("stop reading")
+
+// ReadTwitch 逐条读取 Twitch 聊天记录中的评论，不在内存中保留整份记录
+// 同时支持 .ndjson.gz 与旧的整份 JSON（.json / .json.gz），fn 返回错误时停止读取并返回该错误
+// 旧格式中录像信息可能位于评论之后，返回的 header 在读取完成后才完整
+func ReadTwitch(path string, fn func(comment models.TwitchChatComment) error) (TwitchLogHeader, error) {
+	r, err := OpenLog(path)
+	if err != nil {
+		return TwitchLogHeader{}, fmt.Errorf("读取聊天记录失败: %w", err)
+	}
+	defer r.Close()
+
+	dec := json.NewDecoder(r)
+	if strings.HasSuffix(path, StreamExt) {
+		return readTwitchStream(dec, fn)
+	}
+	return readTwitchDocument(dec, fn)
+}
+
+// readTwitchStream 读取 NDJSON 格式：首行为录像信息，之后每行一条评论
+func readTwitchStream(dec *json.Decoder, fn func(models.TwitchChatComment) error) (TwitchLogHeader, error) {
+	var header TwitchLogHeader
+	if err := dec.Decode(&header); err != nil {
+		return header, fmt.Errorf("解析聊天记录失败: %w", err)
+	}
+	for {
+		var comment models.TwitchChatComment
+		if err := dec.Decode(&comment); err == io.EOF {
+			return header, nil
+		} else if err != nil {
+			return header, fmt.Errorf("解析聊天记录失败: %w", err)
+		}
+		if err := fn(comment); err != nil {
+			return header, err
+		}
+	}
+}
+
+// readTwitchDocument 以 token 方式读取旧的整份 JSON，comments 数组中的评论逐条解码
+func readTwitchDocument(dec *json.Decoder, fn func(models.TwitchChatComment) error) (TwitchLogHeader, error) {
+	var header TwitchLogHeader
+	// fn 返回的错误原样返回，不作为解析错误
+	var fnErr error
+	call := func(comment models.TwitchChatComment) error {
+		fnErr = fn(comment)
+		return fnErr
+	}
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return header, fmt.Errorf("解析聊天记录失败: 不是 Twitch 聊天记录")
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return header, fmt.Errorf("解析聊天记录失败: %w", err)
+		}
+		switch key, _ := tok.(string); key {
+		case "comments":
+			err = readTwitchCommentArray(dec, call)
+		case "video_id":
+			err = dec.Decode(&header.VideoID)
+		case "video_info":
+			err = dec.Decode(&header.VideoInfo)
+		case "downloaded_at":
+			err = dec.Decode(&header.DownloadedAt)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			if fnErr != nil {
+				return header, fnErr
+			}
+			return header, fmt.Errorf("解析聊天记录失败: %w", err)
+		}
+	}
+	return header, nil
+}
+
+// readTwitchCommentArray 逐条解码 comments 数组，值为 null 时跳过
+func readTwitchCommentArray(dec *json.Decoder, fn func(models.TwitchChatComment) error) error {
+	tok, err := dec.Token()
+	if err != nil || tok == nil {
+		return err
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("comments 不是数组")
+	}
+	for dec.More() {
+		var comment models.TwitchChatComment
+		if err := dec.Decode(&comment); err != nil {
+			return err
+		}
+		if err := fn(comment); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// ReadTwitchHeader 只读取 Twitch 聊天记录的录像信息
+// .ndjson.gz 只解析首行；旧格式的录像信息可能位于评论之后，需要读完整个文件（评论逐条解码后丢弃）
+func ReadTwitchHeader(path string) (TwitchLogHeader, error) {
+	if strings.HasSuffix(path, StreamExt) {
+		header, err := ReadTwitch(path, func(models.TwitchChatComment) error { return errStopReading })
+		if err == errStopReading {
+			err = nil
+		}
+		return header, err
+	}
+	return ReadTwitch(path, func(models.TwitchChatComment) error { return nil })
+}
+
+// WriteTwitchJSON 将聊天记录以下载接口相同的整份 JSON 格式写出，评论逐条读取与写出
+func WriteTwitchJSON(w io.Writer, path string) error {
+	if _, err := io.WriteString(w, `{"comments":[`); err != nil {
+		return err
+	}
+	count := 0
+	header, err := ReadTwitch(path, func(comment models.TwitchChatComment) error {
+		data, err := json.Marshal(comment)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		count++
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	tail := struct {
+		VideoID       string                  `json:"video_id"`
+		TotalComments int                     `json:"total_comments"`
+		VideoInfo     *models.TwitchVideoData `json:"video_info,omitempty"`
+		DownloadedAt  string                  `json:"downloaded_at"`
+	}{header.VideoID, count, header.VideoInfo, header.DownloadedAt}
+	data, err := json.Marshal(tail)
+	if err != nil {
+		return err
+	}
+	// 去掉 tail 的左花括号，拼接在 comments 数组之后
+	_, err = fmt.Fprintf(w, "],%s", data[1:])
+	return err
+}
@@ -0,0 +1,305 @@
+// Package chatdownload 提供聊天记录下载与存储的统一实现，
+// 监控流水线与 HTTP 接口都通过它获取和保存聊天记录
+package chatdownload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"subtuber-services/models"
+)
+
+const (
+	gqlURL       = "https://gql.twitch.tv/gql"
+	gqlClientID  = "kd1unb4b3q4t58fwlpcbzcbnm76a8fp"
+	gqlOperation = "VideoCommentsByOffsetOrCursor"
+	gqlSHA256    = "b70a3591ff0f4e0313d126c6a1502d79a1c02baebb288227c582044aa76adf6a"
+)
+
+// VideoInfoFunc 获取录像元数据（Helix API 需要应用凭据，由调用方提供）
+type VideoInfoFunc func(videoID string) (*models.TwitchVideoData, error)
+
+// TwitchDownloader 通过 Twitch GraphQL 接口下载 VOD 聊天记录
+type TwitchDownloader struct {
+	client    *http.Client
+	videoInfo VideoInfoFunc
+	pageDelay time.Duration
+	progress  ProgressFunc
+}
+
+// ProgressFunc 每下载一页评论回调一次，参数为已收集的评论数与当前页最后一条评论的时间偏移（秒）
+type ProgressFunc func(comments int, offsetSeconds float64)
+
+// NewTwitchDownloader 创建 Twitch 聊天下载器，videoInfo 可为 nil
+func NewTwitchDownloader(client *http.Client, videoInfo VideoInfoFunc) *TwitchDownloader {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &TwitchDownloader{
+		client:    client,
+		videoInfo: videoInfo,
+		pageDelay: 100 * time.Millisecond,
+	}
+}
+
+// WithProgress 设置下载进度回调
+func (d *TwitchDownloader) WithProgress(fn ProgressFunc) *TwitchDownloader {
+	d.progress = fn
+	return d
+}
+
+// Download 下载VOD聊天记录，startTime/endTime 为可选的时间范围（秒）
+// 所有评论都保存在内存中返回，整场录像请使用 DownloadToLog 直接写入文件
+func (d *TwitchDownloader) Download(videoID string, startTime, endTime *float64) (*models.TwitchChatDownloadResponse, error) {
+	videoInfo := d.fetchVideoInfo(videoID)
+
+	var allComments []models.TwitchChatComment
+	if _, err := d.Stream(videoID, startTime, endTime, func(comment models.TwitchChatComment) error {
+		allComments = append(allComments, comment)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &models.TwitchChatDownloadResponse{
+		VideoID:       videoID,
+		TotalComments: len(allComments),
+		Comments:      allComments,
+		VideoInfo:     videoInfo,
+		DownloadedAt:  time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// DownloadToLog 下载VOD聊天记录并逐条写入 chat_logs 下的 .ndjson.gz 文件，不在内存中保留评论
+// 返回文件路径与不含评论的下载信息；下载失败时不会留下文件
+func (d *TwitchDownloader) DownloadToLog(videoID string, startTime, endTime *float64) (string, *models.TwitchChatDownloadResponse, error) {
+	header := TwitchLogHeader{
+		VideoID:      videoID,
+		VideoInfo:    d.fetchVideoInfo(videoID),
+		DownloadedAt: time.Now().Format(time.RFC3339),
+	}
+	w, err := CreateTwitchLog(header)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err := d.Stream(videoID, startTime, endTime, w.Write); err != nil {
+		w.Abort()
+		return "", nil, err
+	}
+	path, err := w.Close()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return path, &models.TwitchChatDownloadResponse{
+		VideoID:       videoID,
+		TotalComments: w.Count(),
+		VideoInfo:     header.VideoInfo,
+		DownloadedAt:  header.DownloadedAt,
+	}, nil
+}
+
+// fetchVideoInfo 获取视频信息，失败时只记录日志，继续下载聊天
+func (d *TwitchDownloader) fetchVideoInfo(videoID string) *models.TwitchVideoData {
+	if d.videoInfo == nil {
+		return nil
+	}
+	info, err := d.videoInfo(videoID)
+	if err != nil {
+		log.Printf("获取视频信息失败: %v", err)
+		return nil
+	}
+	return info
+}
+
+// Stream 逐页下载VOD聊天记录，每条评论交给 fn 处理，fn 返回错误时停止下载
+// 不获取录像信息，返回评论总数
+func (d *TwitchDownloader) Stream(videoID string, startTime, endTime *float64, fn func(models.TwitchChatComment) error) (int, error) {
+	var cursor string
+	hasNextPage := true
+	isFirstRequest := true
+	total := 0
+	lastOffset := 0.0
+
+	log.Printf("开始下载 Video ID: %s 的聊天记录", videoID)
+
+	for hasNextPage {
+		variables := map[string]interface{}{"videoID": videoID}
+		if isFirstRequest {
+			// 第一次请求使用 contentOffsetSeconds
+			offsetSeconds := 0.0
+			if startTime != nil {
+				offsetSeconds = *startTime
+			}
+			variables["contentOffsetSeconds"] = offsetSeconds
+			isFirstRequest = false
+		} else {
+			// 后续请求使用 cursor 进行分页
+			variables["cursor"] = cursor
+		}
+
+		gqlResp, err := d.fetchPage(variables)
+		if err != nil {
+			return total, err
+		}
+
+		// 检查是否有评论数据
+		if len(gqlResp.Data.Video.Comments.Edges) == 0 {
+			log.Printf("没有更多评论数据，当前游标: %s", cursor)
+			break
+		}
+
+		// 处理评论
+		for _, edge := range gqlResp.Data.Video.Comments.Edges {
+			node := edge.Node
+
+			// 如果指定了结束时间，检查是否超出范围
+			if endTime != nil && float64(node.ContentOffsetSeconds) > *endTime {
+				hasNextPage = false
+				break
+			}
+
+			// 如果指定了开始时间，只收集开始时间之后的评论
+			if startTime != nil && float64(node.ContentOffsetSeconds) < *startTime {
+				continue
+			}
+
+			comment := ConvertGQLNode(node, videoID)
+			if err := fn(comment); err != nil {
+				return total, err
+			}
+			total++
+			lastOffset = comment.ContentOffsetSeconds
+			cursor = edge.Cursor
+		}
+
+		log.Printf("已获取 %d 条评论，总计: %d", len(gqlResp.Data.Video.Comments.Edges), total)
+		if d.progress != nil && total > 0 {
+			d.progress(total, lastOffset)
+		}
+
+		// 检查是否有下一页
+		hasNextPage = hasNextPage && gqlResp.Data.Video.Comments.PageInfo.HasNextPage
+
+		// 避免请求过快
+		time.Sleep(d.pageDelay)
+	}
+
+	log.Printf("下载完成，共获取 %d 条评论", total)
+	return total, nil
+}
+
+// fetchPage 请求一页评论
+func (d *TwitchDownloader) fetchPage(variables map[string]interface{}) (*models.TwitchGQLCommentResponse, error) {
+	requestBody := models.TwitchGQLRequest{
+		OperationName: gqlOperation,
+		Variables:     variables,
+		Extensions: map[string]interface{}{
+			"persistedQuery": map[string]interface{}{
+				"version":    1,
+				"sha256Hash": gqlSHA256,
+			},
+		},
+	}
+
+	// 序列化请求体
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", gqlURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Client-ID", gqlClientID)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API返回错误状态 %d: %s", resp.StatusCode, string(body))
+	}
+
+	var gqlResp models.TwitchGQLCommentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&gqlResp); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	return &gqlResp, nil
+}
+
+// ConvertGQLNode 将 GraphQL 节点转换为 TwitchChatComment 格式
+func ConvertGQLNode(node models.TwitchGQLCommentNode, videoID string) models.TwitchChatComment {
+	comment := models.TwitchChatComment{
+		ID:                   node.ID,
+		CreatedAt:            node.CreatedAt.Format(time.RFC3339),
+		ContentOffsetSeconds: float64(node.ContentOffsetSeconds),
+		ContentType:          "video",
+		ContentID:            videoID,
+	}
+
+	// 转换 Commenter
+	if node.Commenter != nil {
+		comment.Commenter = models.TwitchChatCommenter{
+			ID:          node.Commenter.ID,
+			DisplayName: node.Commenter.DisplayName,
+			Name:        node.Commenter.Login,
+		}
+	}
+
+	// 转换 Message
+	var messageBody strings.Builder
+	var fragments []models.TwitchChatMessageFragment
+	var emoticons []models.TwitchChatEmoticon
+
+	for i, frag := range node.Message.Fragments {
+		messageBody.WriteString(frag.Text)
+
+		fragment := models.TwitchChatMessageFragment{
+			Text: frag.Text,
+		}
+
+		if frag.Emote != nil {
+			emoticon := models.TwitchChatEmoticon{
+				EmoticonID: frag.Emote.EmoteID,
+				Begin:      i,
+				End:        i + len(frag.Text),
+			}
+			fragment.Emoticon = &emoticon
+			emoticons = append(emoticons, emoticon)
+		}
+
+		fragments = append(fragments, fragment)
+	}
+
+	// 转换 UserBadges
+	var badges []models.TwitchChatBadge
+	for _, badge := range node.Message.UserBadges {
+		badges = append(badges, models.TwitchChatBadge{
+			ID:      badge.SetID,
+			Version: badge.Version,
+		})
+	}
+
+	comment.Message = models.TwitchChatMessage{
+		Body:       messageBody.String(),
+		Fragments:  fragments,
+		UserColor:  node.Message.UserColor,
+		UserBadges: badges,
+		Emoticons:  emoticons,
+	}
+
+	return comment
+}
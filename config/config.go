@@ -0,0 +1,221 @@
+// Package config 统一读取 config.yaml 与环境变量，将各组件的配置注入 handlers，
+// 并在配置文件变更或收到 SIGHUP 时热加载可安全修改的配置（检查间隔、AI 服务等）
+package config
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"subtuber-services/handlers"
+	"subtuber-services/logging"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// App mirrors the layout of config.yaml
+type App struct {
+	SubTuber    handlers.SubTuberConfig    `mapstructure:"subtuber"`
+	SMTP        handlers.SMTPConfig        `mapstructure:"smtp"`
+	Server      handlers.ServerConfig      `mapstructure:"server"`
+	Twitch      handlers.TwitchConfig      `mapstructure:"twitch"`
+	YouTube     handlers.YouTubeConfig     `mapstructure:"youtube"`
+	RPC         handlers.RPCConfig         `mapstructure:"rpc"`
+	GoogleAPI   handlers.GoogleAPIConfig   `mapstructure:"google_api"`
+	AlibabaAPI  handlers.AlibabaAPIConfig  `mapstructure:"alibaba_api"`
+	OpenAIAPI   handlers.OpenAIAPIConfig   `mapstructure:"openai_api"`
+	Ollama      handlers.OllamaConfig      `mapstructure:"ollama"`
+	AI          handlers.AIConfig          `mapstructure:"ai"`
+	ASR         handlers.ASRConfig         `mapstructure:"asr"`
+	Webhooks    handlers.WebhooksConfig    `mapstructure:"webhooks"`
+	Admin       handlers.AdminConfig       `mapstructure:"admin"`
+	Analysis    handlers.AnalysisConfig    `mapstructure:"analysis"`
+	QuietHours  handlers.QuietHoursConfig  `mapstructure:"quiet_hours"`
+	Credentials handlers.CredentialsConfig `mapstructure:"credentials"`
+	Usage       handlers.UsageConfig       `mapstructure:"usage"`
+	Auth        handlers.AuthConfig        `mapstructure:"auth"`
+	Pipeline    handlers.PipelineConfig    `mapstructure:"pipeline"`
+	Moderation  handlers.ModerationConfig  `mapstructure:"moderation"`
+	Storage     handlers.StorageConfig     `mapstructure:"storage"`
+	Schedule    handlers.ScheduleConfig    `mapstructure:"schedule"`
+	Features    map[string]bool            `mapstructure:"features"`
+	Tracing     handlers.TracingConfig     `mapstructure:"tracing"`
+	Secrets     SecretsConfig              `mapstructure:"secrets"`
+	Logging     logging.Config             `mapstructure:"logging"`
+}
+
+// envAliases 除按键名生成的环境变量外额外支持的名称
+var envAliases = map[string][]string{
+	"alibaba_api.api_key": {"DASHSCOPE_API_KEY"},
+	"google_api.api_key":  {"GOOGLE_API_KEY"},
+	"openai_api.api_key":  {"OPENAI_API_KEY"},
+}
+
+var envOnce sync.Once
+
+// Load reads config.yaml from the working directory and environment overrides, then fills in defaults.
+// The returned error reports a missing or unreadable config file; the config is still usable from env and defaults.
+func Load() (App, error) {
+	viper.SetConfigName("config")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath(".")
+	envOnce.Do(bindEnv)
+	err := viper.ReadInConfig()
+	return current(), err
+}
+
+// bindEnv 为所有标量配置项绑定环境变量，键名中的 . 替换为 _ 并转为大写，
+// 例如 twitch.client_id 对应 TWITCH_CLIENT_ID，列表使用逗号分隔；结构体列表（如 webhooks.targets）只能在配置文件中设置
+func bindEnv() {
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+	for _, key := range configKeys(reflect.TypeOf(App{}), "") {
+		_ = viper.BindEnv(append([]string{key}, envNames(key)...)...)
+	}
+}
+
+// configKeys 按 mapstructure 标签列出结构体中的所有标量配置键
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		key := prefix + name
+
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}):
+			keys = append(keys, configKeys(ft, key+".")...)
+		case ft.Kind() == reflect.Map:
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// current unmarshals the current viper state, resolves secrets and fills in defaults
+func current() App {
+	var cfg App
+	_ = viper.Unmarshal(&cfg)
+	// 密钥优先取自环境变量与密钥文件，重新加载时同样重新读取，便于轮换
+	cfg.applySecrets(secretProvider(cfg.Secrets))
+
+	// set default timeout if not provided
+	if cfg.SMTP.Timeout == 0 {
+		cfg.SMTP.Timeout = 30 * time.Second
+	}
+	// set default AI provider if not provided
+	if cfg.AI.Provider == "" {
+		cfg.AI.Provider = "aliyun"
+	}
+	return cfg
+}
+
+// Apply installs the package-level configs that handlers read on every use
+func Apply(cfg App) {
+	handlers.SetSMTPConfig(cfg.SMTP)
+	handlers.SetServerConfig(cfg.Server)
+	handlers.SetRPCConfig(cfg.RPC)
+	handlers.SetGoogleAPIConfig(cfg.GoogleAPI)
+	handlers.SetAlibabaAPIConfig(cfg.AlibabaAPI)
+	handlers.SetOpenAIAPIConfig(cfg.OpenAIAPI)
+	handlers.SetOllamaConfig(cfg.Ollama)
+	handlers.SetAIConfig(cfg.AI)
+	handlers.SetASRConfig(cfg.ASR)
+	handlers.SetWebhooksConfig(cfg.Webhooks)
+	handlers.SetAdminConfig(cfg.Admin)
+	handlers.SetAnalysisConfig(cfg.Analysis)
+	handlers.SetQuietHoursConfig(cfg.QuietHours)
+	handlers.SetPipelineConfig(cfg.Pipeline)
+	handlers.SetModerationConfig(cfg.Moderation)
+	handlers.SetStorageConfig(cfg.Storage)
+	handlers.SetScheduleConfig(cfg.Schedule)
+	handlers.SetUsageConfig(cfg.Usage)
+	handlers.SetAuthConfig(cfg.Auth)
+	handlers.SetLoggingConfig(cfg.Logging)
+	// 加密密钥变更会导致已保存的凭据无法解密，因此不参与热加载
+	handlers.SetCredentialsConfig(cfg.Credentials)
+}
+
+// Reloadable returns the subset of the config that participates in hot reload
+func (cfg App) Reloadable() handlers.ReloadableConfig {
+	return handlers.ReloadableConfig{
+		SMTP:       cfg.SMTP,
+		Server:     cfg.Server,
+		Twitch:     cfg.Twitch,
+		YouTube:    cfg.YouTube,
+		RPC:        cfg.RPC,
+		GoogleAPI:  cfg.GoogleAPI,
+		AlibabaAPI: cfg.AlibabaAPI,
+		OpenAIAPI:  cfg.OpenAIAPI,
+		Ollama:     cfg.Ollama,
+		AI:         cfg.AI,
+		ASR:        cfg.ASR,
+		Webhooks:   cfg.Webhooks,
+		Admin:      cfg.Admin,
+		Analysis:   cfg.Analysis,
+		QuietHours: cfg.QuietHours,
+		Usage:      cfg.Usage,
+		Auth:       cfg.Auth,
+		Pipeline:   cfg.Pipeline,
+		Moderation: cfg.Moderation,
+		Storage:    cfg.Storage,
+		Schedule:   cfg.Schedule,
+		Logging:    cfg.Logging,
+	}
+}
+
+// Reload re-reads config.yaml and applies safe-to-change settings live
+func Reload(reason string) {
+	if err := viper.ReadInConfig(); err != nil {
+		log.Printf("重新加载配置失败 (%s): %v", reason, err)
+		return
+	}
+
+	changes, err := handlers.ApplyConfigReload(current().Reloadable())
+	if err != nil {
+		log.Printf("新配置校验失败，保持原配置 (%s): %v", reason, err)
+		return
+	}
+	log.Printf("配置已重新加载 (%s)，共 %d 项变更", reason, len(changes))
+}
+
+// Watch reloads the configuration when config.yaml changes or on SIGHUP
+func Watch() {
+	// 编辑器保存时可能连续触发多次写事件，合并为一次重新加载
+	var mu sync.Mutex
+	var pending *time.Timer
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if pending != nil {
+			pending.Stop()
+		}
+		pending = time.AfterFunc(500*time.Millisecond, func() {
+			Reload("文件变更")
+		})
+	})
+	viper.WatchConfig()
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			Reload("SIGHUP")
+		}
+	}()
+}
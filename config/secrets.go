@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// SecretsConfig 密钥来源与启动时必须提供的密钥
+type SecretsConfig struct {
+	// File 密钥文件（YAML 或 JSON，结构与 config.yaml 相同），例如 Vault Agent 渲染的文件或 `sops -d` 解密后的文件
+	File string `mapstructure:"file"`
+	// Required 启动时必须非空的密钥键名，例如 twitch.client_secret，缺少时服务拒绝启动
+	Required []string `mapstructure:"required"`
+}
+
+// SecretProvider looks up a secret by its config key (e.g. google_api.api_key)
+type SecretProvider interface {
+	// Secret returns the secret value and whether the provider has it
+	Secret(key string) (string, bool)
+}
+
+// envSecrets 从环境变量读取密钥，变量名与配置项相同（TWITCH_CLIENT_SECRET），并支持 DASHSCOPE_API_KEY 等别名
+type envSecrets struct{}
+
+func (envSecrets) Secret(key string) (string, bool) {
+	for _, name := range envNames(key) {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// fileSecrets 从密钥文件读取
+type fileSecrets struct {
+	v *viper.Viper
+}
+
+// newFileSecrets 读取密钥文件，格式按扩展名识别（.yaml / .yml / .json）
+func newFileSecrets(path string) (fileSecrets, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fileSecrets{}, err
+	}
+	return fileSecrets{v: v}, nil
+}
+
+func (f fileSecrets) Secret(key string) (string, bool) {
+	if !f.v.IsSet(key) {
+		return "", false
+	}
+	// 列表（如 youtube.api_keys）统一为逗号分隔
+	v := strings.Join(f.v.GetStringSlice(key), ",")
+	return v, v != ""
+}
+
+// chainSecrets 按顺序查找，先找到的优先
+type chainSecrets []SecretProvider
+
+func (c chainSecrets) Secret(key string) (string, bool) {
+	for _, p := range c {
+		if v, ok := p.Secret(key); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// secretProvider 环境变量优先，其次是配置的密钥文件；密钥文件读取失败时只使用环境变量
+func secretProvider(cfg SecretsConfig) SecretProvider {
+	providers := chainSecrets{envSecrets{}}
+	if cfg.File != "" {
+		file, err := newFileSecrets(cfg.File)
+		if err != nil {
+			log.Printf("警告: 读取密钥文件 %s 失败: %v", cfg.File, err)
+		} else {
+			providers = append(providers, file)
+		}
+	}
+	return providers
+}
+
+// secretFields 可由密钥来源提供的配置项
+func (cfg *App) secretFields() map[string]*string {
+	return map[string]*string{
+		"google_api.api_key":         &cfg.GoogleAPI.APIKey,
+		"alibaba_api.api_key":        &cfg.AlibabaAPI.APIKey,
+		"openai_api.api_key":         &cfg.OpenAIAPI.APIKey,
+		"twitch.client_id":           &cfg.Twitch.ClientID,
+		"twitch.client_secret":       &cfg.Twitch.ClientSecret,
+		"smtp.pass":                  &cfg.SMTP.Pass,
+		"admin.token":                &cfg.Admin.Token,
+		"auth.magic_link_secret":     &cfg.Auth.MagicLinkSecret,
+		"auth.session_secret":        &cfg.Auth.SessionSecret,
+		"credentials.encryption_key": &cfg.Credentials.EncryptionKey,
+	}
+}
+
+// applySecrets 用密钥来源中的值覆盖配置文件中的密钥
+func (cfg *App) applySecrets(provider SecretProvider) {
+	for key, field := range cfg.secretFields() {
+		if v, ok := provider.Secret(key); ok {
+			*field = v
+		}
+	}
+	if v, ok := provider.Secret("youtube.api_keys"); ok {
+		cfg.YouTube.APIKeys = strings.Split(v, ",")
+	}
+}
+
+// ValidateSecrets fails when the secrets file is unreadable, auth.session_secret is empty, or a key listed in secrets.required is unknown or empty
+func (cfg App) ValidateSecrets() error {
+	if cfg.Secrets.File != "" {
+		if _, err := newFileSecrets(cfg.Secrets.File); err != nil {
+			return fmt.Errorf("读取密钥文件 %s 失败: %w", cfg.Secrets.File, err)
+		}
+	}
+
+	// 会话令牌签名密钥始终必需，否则重启后所有会话失效
+	required := cfg.Secrets.Required
+	if !slices.Contains(required, "auth.session_secret") {
+		required = append([]string{"auth.session_secret"}, required...)
+	}
+
+	fields := cfg.secretFields()
+	var missing []string
+	for _, key := range required {
+		if key == "youtube.api_keys" {
+			if len(cfg.YouTube.APIKeys) == 0 {
+				missing = append(missing, key)
+			}
+			continue
+		}
+		field, ok := fields[key]
+		if !ok {
+			known := append(sortedKeys(fields), "youtube.api_keys")
+			return fmt.Errorf("secrets.required 中的 %q 不是可配置的密钥，可选: %s", key, strings.Join(known, ", "))
+		}
+		if *field == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		for i, key := range missing {
+			missing[i] = fmt.Sprintf("%s（环境变量 %s）", key, strings.Join(envNames(key), " / "))
+		}
+		return fmt.Errorf("缺少必需的密钥: %s，请通过环境变量、密钥文件或 config.yaml 提供", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// envNames 配置键对应的环境变量名（含别名）
+func envNames(key string) []string {
+	return append([]string{strings.ToUpper(strings.ReplaceAll(key, ".", "_"))}, envAliases[key]...)
+}
+
+func sortedKeys(m map[string]*string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,565 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"subtuber-services/pathsafe"
+)
+
+const (
+	abuseReportsFile = "App_Data/abuse_reports.json"
+	takedownsFile    = "App_Data/takedowns.json"
+)
+
+// 可举报的内容类型
+const (
+	ArtifactClip    = "clip"    // 热点片段
+	ArtifactSummary = "summary" // 热点的AI总结
+)
+
+// 举报原因
+var abuseReportReasons = []string{"copyright", "harassment", "privacy", "spam", "inappropriate", "other"}
+
+// 举报状态
+const (
+	reportStatusOpen      = "open"
+	reportStatusActioned  = "actioned"  // 已下架
+	reportStatusDismissed = "dismissed" // 已驳回
+)
+
+// AbuseReport 一条内容举报
+type AbuseReport struct {
+	ID            string    `json:"id"`
+	Kind          string    `json:"kind"`
+	VideoID       string    `json:"video_id"`
+	OffsetSeconds float64   `json:"offset_seconds"`
+	Reason        string    `json:"reason"`
+	Details       string    `json:"details,omitempty"`
+	Reporter      string    `json:"reporter"` // 登录用户为 userHash，匿名用户为 ip:{IP 哈希}
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+	ReviewedAt    time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote    string    `json:"review_note,omitempty"`
+}
+
+// Takedown 已下架的内容
+type Takedown struct {
+	Kind          string    `json:"kind"`
+	VideoID       string    `json:"video_id"`
+	OffsetSeconds float64   `json:"offset_seconds"`
+	Reason        string    `json:"reason"`
+	ReportIDs     []string  `json:"report_ids,omitempty"`
+	Automatic     bool      `json:"automatic"` // 因举报数量自动下架，等待管理员审核
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+var (
+	abuseReportsMu sync.Mutex
+
+	// takedownsMu 保护内存中的下架列表，读取频繁（公开接口），只在变更时写文件
+	takedownsMu     sync.RWMutex
+	takedowns       []Takedown
+	takedownsLoaded bool
+)
+
+// artifactKey 内容在举报与下架记录中的标识
+func artifactKey(kind, videoID string, offsetSeconds float64) string {
+	return fmt.Sprintf("%s:%s:%.0f", kind, videoID, offsetSeconds)
+}
+
+// sameArtifact 是否为同一内容（热点偏移 1 秒内视为同一热点）
+func sameArtifact(kind, videoID string, offsetSeconds float64, otherKind, otherVideoID string, otherOffset float64) bool {
+	return kind == otherKind && videoID == otherVideoID && math.Abs(offsetSeconds-otherOffset) < 1
+}
+
+// loadTakedownsLocked 首次使用时从文件读取下架列表，调用方需持有写锁
+func loadTakedownsLocked() {
+	if takedownsLoaded {
+		return
+	}
+	takedownsLoaded = true
+	data, err := os.ReadFile(takedownsFile)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &takedowns); err != nil {
+		log.Printf("读取下架列表失败: %v", err)
+	}
+}
+
+// saveTakedownsLocked 写回下架列表，调用方需持有写锁
+func saveTakedownsLocked() error {
+	if err := os.MkdirAll(filepath.Dir(takedownsFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(takedowns, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(takedownsFile, data, 0644)
+}
+
+// IsTakenDown 内容是否已下架；片段下架时其总结也不再公开
+func IsTakenDown(kind, videoID string, offsetSeconds float64) bool {
+	takedownsMu.Lock()
+	loadTakedownsLocked()
+	takedownsMu.Unlock()
+
+	takedownsMu.RLock()
+	defer takedownsMu.RUnlock()
+	for _, t := range takedowns {
+		if sameArtifact(kind, videoID, offsetSeconds, t.Kind, t.VideoID, t.OffsetSeconds) {
+			return true
+		}
+		if kind == ArtifactSummary && sameArtifact(ArtifactClip, videoID, offsetSeconds, t.Kind, t.VideoID, t.OffsetSeconds) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutTakenDownClips 过滤掉已下架的热点片段
+func withoutTakenDownClips(videoID string, moments []VodCommentData) []VodCommentData {
+	visible := make([]VodCommentData, 0, len(moments))
+	for _, moment := range moments {
+		if !IsTakenDown(ArtifactClip, videoID, moment.OffsetSeconds) {
+			visible = append(visible, moment)
+		}
+	}
+	return visible
+}
+
+// takeDownArtifact 下架内容并记录审计日志，已下架时合并举报记录
+func takeDownArtifact(t Takedown, actor string) error {
+	takedownsMu.Lock()
+	defer takedownsMu.Unlock()
+	loadTakedownsLocked()
+
+	for i := range takedowns {
+		if sameArtifact(t.Kind, t.VideoID, t.OffsetSeconds, takedowns[i].Kind, takedowns[i].VideoID, takedowns[i].OffsetSeconds) {
+			takedowns[i].ReportIDs = append(takedowns[i].ReportIDs, t.ReportIDs...)
+			// 管理员确认后不再是待审核的自动下架
+			takedowns[i].Automatic = takedowns[i].Automatic && t.Automatic
+			return saveTakedownsLocked()
+		}
+	}
+
+	t.CreatedAt = time.Now()
+	takedowns = append(takedowns, t)
+	if err := saveTakedownsLocked(); err != nil {
+		return err
+	}
+	publicProfileCache.Flush()
+
+	log.Printf("内容已下架: %s (%s, 操作者: %s)", artifactKey(t.Kind, t.VideoID, t.OffsetSeconds), t.Reason, actor)
+	_ = appendErrorLog("moderation-audit.log", fmt.Sprintf("%s\tTAKEDOWN\t%s\t%s\t%s\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), artifactKey(t.Kind, t.VideoID, t.OffsetSeconds), t.Reason, actor, strings.Join(t.ReportIDs, ",")))
+	return nil
+}
+
+// restoreArtifact 恢复已下架的内容，返回是否存在该下架记录
+func restoreArtifact(kind, videoID string, offsetSeconds float64, actor string) (bool, error) {
+	takedownsMu.Lock()
+	defer takedownsMu.Unlock()
+	loadTakedownsLocked()
+
+	kept := takedowns[:0:0]
+	found := false
+	for _, t := range takedowns {
+		if sameArtifact(kind, videoID, offsetSeconds, t.Kind, t.VideoID, t.OffsetSeconds) {
+			found = true
+			continue
+		}
+		kept = append(kept, t)
+	}
+	if !found {
+		return false, nil
+	}
+	takedowns = kept
+	if err := saveTakedownsLocked(); err != nil {
+		return true, err
+	}
+	publicProfileCache.Flush()
+
+	_ = appendErrorLog("moderation-audit.log", fmt.Sprintf("%s\tRESTORE\t%s\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), artifactKey(kind, videoID, offsetSeconds), actor))
+	return true, nil
+}
+
+// loadAbuseReports 读取所有举报，调用方需持有锁
+func loadAbuseReports() ([]AbuseReport, error) {
+	data, err := os.ReadFile(abuseReportsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []AbuseReport{}, nil
+		}
+		return nil, err
+	}
+	var reports []AbuseReport
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// saveAbuseReports 写回所有举报，调用方需持有锁
+func saveAbuseReports(reports []AbuseReport) error {
+	if err := os.MkdirAll(filepath.Dir(abuseReportsFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(abuseReportsFile, data, 0644)
+}
+
+// artifactExists 被举报的内容是否存在
+func artifactExists(kind, videoID string, offsetSeconds float64) bool {
+	videoDir := pathsafe.Join("./analysis_results", videoID)
+	switch kind {
+	case ArtifactSummary:
+		return readSummaryForOffset(videoDir, offsetSeconds) != ""
+	case ArtifactClip:
+		clips, err := loadClipSync(videoID)
+		if err == nil {
+			for _, clip := range clips {
+				if math.Abs(clip.HotMomentOffset-offsetSeconds) < 1 {
+					return true
+				}
+			}
+		}
+		// 没有片段同步信息的旧录像，以分析结果中的热点为准
+		data, err := os.ReadFile(filepath.Join(videoDir, analysisResultFileName(defaultPeakParams)))
+		if err != nil {
+			return false
+		}
+		var result AnalysisResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return false
+		}
+		for _, moment := range result.HotMoments {
+			if math.Abs(moment.OffsetSeconds-offsetSeconds) < 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SubmitAbuseReport 举报片段或总结，登录与匿名用户均可举报，同一用户对同一内容只计一次
+func SubmitAbuseReport(c *gin.Context) {
+	var req struct {
+		Kind          string   `json:"kind" binding:"required"`
+		VideoID       string   `json:"video_id" binding:"required"`
+		OffsetSeconds *float64 `json:"offset_seconds" binding:"required"`
+		Reason        string   `json:"reason" binding:"required"`
+		Details       string   `json:"details"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的请求参数: " + err.Error()})
+		return
+	}
+	if req.Kind != ArtifactClip && req.Kind != ArtifactSummary {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "kind 只能为 clip 或 summary"})
+		return
+	}
+	if !containsFold(abuseReportReasons, req.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "不支持的举报原因", "reasons": abuseReportReasons})
+		return
+	}
+	if len([]rune(req.Details)) > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "补充说明不能超过 1000 字"})
+		return
+	}
+	videoID := filepath.Base(req.VideoID)
+	offset := *req.OffsetSeconds
+	if !artifactExists(req.Kind, videoID, offset) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "被举报的内容不存在"})
+		return
+	}
+
+	reporter := "ip:" + computeSha256Hex(c.ClientIP())[:16]
+	if userHash, err := getUserHashFromCookie(c); err == nil && userHash != "" {
+		reporter = userHash
+	}
+
+	abuseReportsMu.Lock()
+	reports, err := loadAbuseReports()
+	if err != nil {
+		abuseReportsMu.Unlock()
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取举报记录失败: " + err.Error()})
+		return
+	}
+
+	reporters := map[string]bool{}
+	var openIDs []string
+	for _, r := range reports {
+		if r.Status != reportStatusOpen || !sameArtifact(req.Kind, videoID, offset, r.Kind, r.VideoID, r.OffsetSeconds) {
+			continue
+		}
+		if r.Reporter == reporter {
+			abuseReportsMu.Unlock()
+			c.JSON(http.StatusOK, gin.H{"success": true, "message": "已收到你的举报，正在等待审核", "report_id": r.ID})
+			return
+		}
+		reporters[r.Reporter] = true
+		openIDs = append(openIDs, r.ID)
+	}
+
+	report := AbuseReport{
+		ID:            fmt.Sprintf("report-%d", time.Now().UnixNano()),
+		Kind:          req.Kind,
+		VideoID:       videoID,
+		OffsetSeconds: offset,
+		Reason:        strings.ToLower(req.Reason),
+		Details:       strings.TrimSpace(req.Details),
+		Reporter:      reporter,
+		Status:        reportStatusOpen,
+		CreatedAt:     time.Now(),
+	}
+	reports = append(reports, report)
+	err = saveAbuseReports(reports)
+	abuseReportsMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "保存举报失败: " + err.Error()})
+		return
+	}
+	reporters[reporter] = true
+	openIDs = append(openIDs, report.ID)
+
+	_ = appendErrorLog("moderation-audit.log", fmt.Sprintf("%s\tREPORT\t%s\t%s\t%s\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), artifactKey(report.Kind, videoID, offset), report.Reason, reporter, report.ID))
+
+	// 不同举报人达到阈值时自动下架，等待管理员审核
+	takenDown := false
+	if threshold := GetModerationConfig().AutoTakedownReports; threshold > 0 && len(reporters) >= threshold {
+		if err := takeDownArtifact(Takedown{
+			Kind:          report.Kind,
+			VideoID:       videoID,
+			OffsetSeconds: offset,
+			Reason:        fmt.Sprintf("收到 %d 个举报，自动下架待审核", len(reporters)),
+			ReportIDs:     openIDs,
+			Automatic:     true,
+		}, "auto"); err != nil {
+			log.Printf("自动下架失败: %v", err)
+		} else {
+			takenDown = true
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    "举报已提交，我们会尽快审核",
+		"report_id":  report.ID,
+		"taken_down": takenDown,
+	})
+}
+
+// listAbuseReportsHandler 管理员查看举报队列，默认只列出待处理的举报，按内容分组、举报多的在前
+func listAbuseReportsHandler(c *gin.Context) {
+	status := c.DefaultQuery("status", reportStatusOpen)
+
+	abuseReportsMu.Lock()
+	reports, err := loadAbuseReports()
+	abuseReportsMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取举报记录失败: " + err.Error()})
+		return
+	}
+
+	type artifactReports struct {
+		Kind          string        `json:"kind"`
+		VideoID       string        `json:"video_id"`
+		OffsetSeconds float64       `json:"offset_seconds"`
+		TakenDown     bool          `json:"taken_down"`
+		Reports       []AbuseReport `json:"reports"`
+	}
+
+	groups := map[string]*artifactReports{}
+	for _, r := range reports {
+		if status != "all" && r.Status != status {
+			continue
+		}
+		key := artifactKey(r.Kind, r.VideoID, r.OffsetSeconds)
+		group, ok := groups[key]
+		if !ok {
+			group = &artifactReports{Kind: r.Kind, VideoID: r.VideoID, OffsetSeconds: r.OffsetSeconds,
+				TakenDown: IsTakenDown(r.Kind, r.VideoID, r.OffsetSeconds)}
+			groups[key] = group
+		}
+		group.Reports = append(group.Reports, r)
+	}
+
+	queue := make([]*artifactReports, 0, len(groups))
+	for _, group := range groups {
+		queue = append(queue, group)
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		if len(queue[i].Reports) != len(queue[j].Reports) {
+			return len(queue[i].Reports) > len(queue[j].Reports)
+		}
+		return queue[i].Reports[0].CreatedAt.Before(queue[j].Reports[0].CreatedAt)
+	})
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "status": status, "artifacts": queue})
+}
+
+// resolveAbuseReportHandler 管理员处理举报：takedown 下架内容，dismiss 驳回
+// 处理结果应用到同一内容的所有待处理举报；驳回时恢复因举报自动下架的内容
+func resolveAbuseReportHandler(c *gin.Context) {
+	var req struct {
+		Action string `json:"action" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求参数错误: " + err.Error()})
+		return
+	}
+	if req.Action != "takedown" && req.Action != "dismiss" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "action 只能为 takedown 或 dismiss"})
+		return
+	}
+
+	abuseReportsMu.Lock()
+	defer abuseReportsMu.Unlock()
+
+	reports, err := loadAbuseReports()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取举报记录失败: " + err.Error()})
+		return
+	}
+
+	var target *AbuseReport
+	for i := range reports {
+		if reports[i].ID == c.Param("id") {
+			target = &reports[i]
+			break
+		}
+	}
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "举报不存在"})
+		return
+	}
+	kind, videoID, offset := target.Kind, target.VideoID, target.OffsetSeconds
+
+	status := reportStatusDismissed
+	if req.Action == "takedown" {
+		status = reportStatusActioned
+	}
+	var resolved []string
+	reasons := map[string]bool{}
+	for i := range reports {
+		r := &reports[i]
+		if r.Status != reportStatusOpen || !sameArtifact(kind, videoID, offset, r.Kind, r.VideoID, r.OffsetSeconds) {
+			continue
+		}
+		r.Status = status
+		r.ReviewedAt = time.Now()
+		r.ReviewNote = req.Note
+		resolved = append(resolved, r.ID)
+		reasons[r.Reason] = true
+	}
+
+	if req.Action == "takedown" {
+		reasonList := make([]string, 0, len(reasons))
+		for reason := range reasons {
+			reasonList = append(reasonList, reason)
+		}
+		sort.Strings(reasonList)
+		reason := strings.Join(reasonList, ",")
+		if req.Note != "" {
+			reason += ": " + req.Note
+		}
+		if err := takeDownArtifact(Takedown{Kind: kind, VideoID: videoID, OffsetSeconds: offset, Reason: reason, ReportIDs: resolved}, "admin"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "下架失败: " + err.Error()})
+			return
+		}
+	} else if automaticTakedown(kind, videoID, offset) {
+		if _, err := restoreArtifact(kind, videoID, offset, "admin"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "恢复内容失败: " + err.Error()})
+			return
+		}
+	}
+
+	if err := saveAbuseReports(reports); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "保存举报记录失败: " + err.Error()})
+		return
+	}
+	_ = appendErrorLog("moderation-audit.log", fmt.Sprintf("%s\t%s\t%s\tadmin\t%s\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), strings.ToUpper(req.Action), artifactKey(kind, videoID, offset), strings.Join(resolved, ","), req.Note))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    "举报已处理",
+		"resolved":   resolved,
+		"taken_down": IsTakenDown(kind, videoID, offset),
+	})
+}
+
+// automaticTakedown 内容是否处于自动下架、待审核的状态
+func automaticTakedown(kind, videoID string, offsetSeconds float64) bool {
+	takedownsMu.Lock()
+	defer takedownsMu.Unlock()
+	loadTakedownsLocked()
+	for _, t := range takedowns {
+		if sameArtifact(kind, videoID, offsetSeconds, t.Kind, t.VideoID, t.OffsetSeconds) {
+			return t.Automatic
+		}
+	}
+	return false
+}
+
+// listTakedownsHandler 管理员查看已下架的内容
+func listTakedownsHandler(c *gin.Context) {
+	takedownsMu.Lock()
+	loadTakedownsLocked()
+	items := append([]Takedown{}, takedowns...)
+	takedownsMu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "takedowns": items})
+}
+
+// restoreTakedownHandler 管理员恢复已下架的内容
+func restoreTakedownHandler(c *gin.Context) {
+	var req struct {
+		Kind          string   `json:"kind" binding:"required"`
+		VideoID       string   `json:"video_id" binding:"required"`
+		OffsetSeconds *float64 `json:"offset_seconds" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	found, err := restoreArtifact(req.Kind, req.VideoID, *req.OffsetSeconds, "admin")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "恢复内容失败: " + err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "该内容未被下架"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "内容已恢复"})
+}
+
+// Validate checks the abuse report settings
+func (c ModerationConfig) Validate() error {
+	if c.AutoTakedownReports < 0 {
+		return fmt.Errorf("moderation.auto_takedown_reports 不能为负数")
+	}
+	return nil
+}
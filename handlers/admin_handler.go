@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuthMiddleware 校验请求头 X-Admin-Token 是否与配置的管理令牌一致
+// 未配置管理令牌时拒绝所有管理请求
+func AdminAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := GetAdminConfig().Token
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "管理接口未启用",
+			})
+			return
+		}
+
+		provided := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "管理令牌无效",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RegisterAdminRoutes 在接口分组下注册管理接口（/admin）
+func RegisterAdminRoutes(r gin.IRouter) {
+	g := r.Group("/admin", AdminAuthMiddleware())
+	g.GET("/features", listFeatureFlagsHandler)
+	g.PUT("/features/:name", setFeatureFlagHandler)
+	g.GET("/deferred-jobs", listDeferredJobsHandler)
+	g.POST("/deferred-jobs/:id/run", runDeferredJobHandler)
+	g.GET("/jobs", listPersistedJobsHandler)
+	g.POST("/summaries/retry-failed", retryFailedSummariesHandler)
+	g.GET("/summaries/retry-failed/:id", getSummaryRetryBatchHandler)
+	g.GET("/summaries/quality", getSummaryQualityHandler)
+	g.GET("/ai/providers", getAIProvidersHandler)
+	g.GET("/streamers/:id/credentials/youtube", getYouTubeCredentialsHandler)
+	g.PUT("/streamers/:id/credentials/youtube", setYouTubeCredentialsHandler)
+	g.DELETE("/streamers/:id/credentials/youtube", deleteYouTubeCredentialsHandler)
+	g.GET("/streamers/:id/clip-settings", getStreamerClipSettingsHandler)
+	g.PUT("/streamers/:id/clip-settings", setStreamerClipSettingsHandler)
+	g.DELETE("/streamers/:id/clip-settings", deleteStreamerClipSettingsHandler)
+	g.GET("/streamers/:id/monitoring", getStreamerMonitoringHandler)
+	g.PUT("/streamers/:id/monitoring", setStreamerMonitoringHandler)
+	g.DELETE("/streamers/:id/monitoring", deleteStreamerMonitoringHandler)
+	g.GET("/streamers/:id/prompt-templates", getStreamerPromptTemplatesHandler)
+	g.PUT("/streamers/:id/prompt-templates", setStreamerPromptTemplatesHandler)
+	g.DELETE("/streamers/:id/prompt-templates", deleteStreamerPromptTemplatesHandler)
+	g.DELETE("/metadata-cache", invalidateMetadataCacheHandler)
+	g.GET("/reports", listAbuseReportsHandler)
+	g.POST("/reports/:id/resolve", resolveAbuseReportHandler)
+	g.GET("/takedowns", listTakedownsHandler)
+	g.POST("/takedowns/restore", restoreTakedownHandler)
+	g.GET("/storage/cold", listColdClipsHandler)
+	g.POST("/users/:userHash/impersonate", createImpersonationHandler)
+	g.GET("/impersonations", listImpersonationsHandler)
+	g.DELETE("/impersonations/:id", revokeImpersonationHandler)
+	g.GET("/analysis/shadow", getShadowReportHandler)
+	g.GET("/analysis/shadow/:videoID", getVideoShadowHandler)
+	g.POST("/analysis/shadow/:videoID", runVideoShadowHandler)
+	g.POST("/storage/tiering", runStorageTieringHandler)
+	g.POST("/storage/retention", runStorageRetentionHandler)
+	g.GET("/export/parquet", getParquetExportHandler)
+	g.GET("/schedule", listScheduledJobsHandler)
+	g.POST("/export/parquet", runParquetExportHandler)
+	g.GET("/research-keys", listResearchKeysHandler)
+	g.POST("/research-keys", issueResearchKeyHandler)
+	g.DELETE("/research-keys/:id", revokeResearchKeyHandler)
+	g.GET("/logging", getLogLevelsHandler)
+	g.PUT("/logging", setLogLevelHandler)
+}
+
+// featureFlagItem 功能开关列表项
+type featureFlagItem struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// listFeatureFlagsHandler 列出所有功能开关
+func listFeatureFlagsHandler(c *gin.Context) {
+	flags := GetFeatureFlags()
+
+	items := make([]featureFlagItem, 0, len(flags))
+	for _, name := range sortedFeatureNames(flags) {
+		items = append(items, featureFlagItem{Name: name, Enabled: flags[name]})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"features": items,
+	})
+}
+
+// setFeatureFlagHandler 开启或关闭指定功能
+func setFeatureFlagHandler(c *gin.Context) {
+	name := c.Param("name")
+
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if !isKnownFeature(name) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "未知的功能开关: " + name,
+		})
+		return
+	}
+
+	if err := SetFeatureEnabled(name, *req.Enabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "保存功能开关失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    name,
+		"enabled": *req.Enabled,
+	})
+}
+
+// listDeferredJobsHandler 列出静默时段内被延后的任务
+func listDeferredJobsHandler(c *gin.Context) {
+	jobs, err := ListDeferredJobs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "读取延后任务失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"quiet_hours": IsQuietHours(time.Now()),
+		"jobs":        jobs,
+	})
+}
+
+// runDeferredJobHandler 忽略静默时段立即执行指定的延后任务
+func runDeferredJobHandler(c *gin.Context) {
+	job, err := RunDeferredJobNow(c.Param("id"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "延后任务不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "执行延后任务失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "任务已开始执行",
+		"job":     job,
+	})
+}
+
+// retryFailedSummariesHandler 重新执行语音识别或AI总结失败的热点片段
+// 请求体可选 {"video_id": "..."}，为空时扫描所有视频
+func retryFailedSummariesHandler(c *gin.Context) {
+	if !IsFeatureEnabled(FeatureAISummary) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "AI总结已通过功能开关禁用",
+		})
+		return
+	}
+
+	var req struct {
+		VideoID string `json:"video_id"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	videoID := ""
+	if req.VideoID != "" {
+		videoID = filepath.Base(req.VideoID)
+	}
+
+	batch, err := RetryFailedSummaries(videoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "扫描失败记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	summaryOnly, full := 0, 0
+	for _, item := range batch.Items {
+		if item.Mode == "full" {
+			full++
+		} else {
+			summaryOnly++
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success":      true,
+		"message":      fmt.Sprintf("已重新排队 %d 个热点", len(batch.Items)),
+		"summary_only": summaryOnly,
+		"full_rerun":   full,
+		"batch":        batch,
+	})
+}
+
+// getSummaryRetryBatchHandler 查询批量重试的执行结果
+func getSummaryRetryBatchHandler(c *gin.Context) {
+	batch, ok := GetSummaryRetryBatch(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "批量重试不存在",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"batch":   batch,
+	})
+}
+
+// invalidateMetadataCacheHandler 删除录像元数据缓存
+// 查询参数：platform（twitch/youtube，必填）、video_id（单个录像）或 channel（频道的录像列表），都不指定时清空该平台的缓存
+func invalidateMetadataCacheHandler(c *gin.Context) {
+	platform := c.Query("platform")
+	if platform != "twitch" && platform != "youtube" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "platform 必须为 twitch 或 youtube",
+		})
+		return
+	}
+
+	prefix := ""
+	switch {
+	case c.Query("video_id") != "":
+		prefix = videoInfoCacheKey(c.Query("video_id"))
+	case c.Query("channel") != "":
+		prefix = videoListCacheKey(c.Query("channel"))
+	}
+
+	removed := InvalidateVideoMetadata(platform, prefix)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"removed": removed,
+	})
+}
@@ -0,0 +1,588 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AI 请求背压的默认参数，对应配置 ai.backpressure 中为 0 的项
+const (
+	defaultAIMinConcurrency     = 1
+	defaultAIMaxConcurrency     = 8
+	defaultAITargetLatency      = 30 * time.Second
+	defaultAIMaxQueueDepth      = 20
+	defaultAIErrorRateThreshold = 0.5
+)
+
+const (
+	// aiOutcomeWindow 计算错误率时使用的最近请求数
+	aiOutcomeWindow = 20
+	// aiMinOutcomes 样本少于该数量时不根据错误率判断健康状态
+	aiMinOutcomes = 5
+	// aiLatencyEWMAWeight 平均延迟中最新一次请求的权重
+	aiLatencyEWMAWeight = 0.2
+	// aiDecreaseCooldown 两次减半并发数之间的最短间隔，避免同一批慢请求把并发数连续减到最小
+	aiDecreaseCooldown = 10 * time.Second
+	// aiHealthProbeInterval 不健康的提供商超过该时间没有请求时重新尝试，以便发现其已恢复
+	aiHealthProbeInterval = time.Minute
+	// aiSummaryRetryDelay 总结因背压被推迟后首次重试的等待时间，之后每次翻倍
+	aiSummaryRetryDelay = 5 * time.Minute
+	// aiSummaryRetryMaxAttempts 推迟重试的最多次数，超过后标记为失败
+	aiSummaryRetryMaxAttempts = 5
+)
+
+// errAIBackpressure 提供商的等待队列已满，请求未发出，调用方应稍后重试
+var errAIBackpressure = errors.New("AI 服务繁忙，等待队列已满")
+
+// withDefaults 将未配置（为 0）的项替换为默认值
+func (c BackpressureConfig) withDefaults() BackpressureConfig {
+	if c.MinConcurrency <= 0 {
+		c.MinConcurrency = defaultAIMinConcurrency
+	}
+	if c.MaxConcurrency <= 0 {
+		c.MaxConcurrency = defaultAIMaxConcurrency
+	}
+	if c.MaxConcurrency < c.MinConcurrency {
+		c.MaxConcurrency = c.MinConcurrency
+	}
+	if c.TargetLatencySeconds <= 0 {
+		c.TargetLatencySeconds = defaultAITargetLatency.Seconds()
+	}
+	if c.MaxQueueDepth <= 0 {
+		c.MaxQueueDepth = defaultAIMaxQueueDepth
+	}
+	if c.ErrorRateThreshold <= 0 {
+		c.ErrorRateThreshold = defaultAIErrorRateThreshold
+	}
+	return c
+}
+
+// aiLimiter 单个AI提供商的自适应并发限制（AIMD）：
+// 请求快速成功时并发数缓慢增加，请求超时、变慢或出错时并发数减半
+type aiLimiter struct {
+	provider string
+
+	mu           sync.Mutex
+	limit        float64
+	inflight     int
+	waiting      int
+	wake         chan struct{} // 有请求结束时关闭并替换，唤醒等待者
+	latencyEWMA  float64       // 平均延迟（秒）
+	outcomes     []bool        // 最近请求是否失败
+	lastDecrease time.Time
+	lastRequest  time.Time
+
+	requests      int64
+	failures      int64
+	rejected      int64
+	latencySum    float64
+	maxLatencySec float64
+}
+
+var (
+	aiLimitersMu sync.Mutex
+	aiLimiters   = map[string]*aiLimiter{}
+)
+
+// aiLimiterFor 返回提供商的并发限制器，首次使用时以最小并发数启动
+func aiLimiterFor(provider string) *aiLimiter {
+	provider = aiProviderName(provider)
+	aiLimitersMu.Lock()
+	defer aiLimitersMu.Unlock()
+	l, ok := aiLimiters[provider]
+	if !ok {
+		l = &aiLimiter{
+			provider: provider,
+			limit:    float64(GetAIConfig().Backpressure.withDefaults().MinConcurrency),
+			wake:     make(chan struct{}),
+		}
+		aiLimiters[provider] = l
+	}
+	return l
+}
+
+// clampLocked 配置热加载后将并发数限制在新的范围内，调用方需持有 l.mu
+func (l *aiLimiter) clampLocked(cfg BackpressureConfig) {
+	l.limit = math.Max(float64(cfg.MinConcurrency), math.Min(float64(cfg.MaxConcurrency), l.limit))
+}
+
+// acquire 等待可用的并发名额；等待队列已满时立即返回 errAIBackpressure
+// 先按提供商的自适应并发数排队，拿到名额后再占用所有提供商共享的AI阶段名额（pipeline.concurrency.ai），
+// 避免排队等待慢提供商的请求占着共享名额，阻塞其他提供商的请求
+func (l *aiLimiter) acquire(ctx context.Context) (time.Time, error) {
+	cfg := GetAIConfig().Backpressure.withDefaults()
+
+	l.mu.Lock()
+	l.clampLocked(cfg)
+	if l.inflight >= int(l.limit) {
+		if l.waiting >= cfg.MaxQueueDepth {
+			l.rejected++
+			l.mu.Unlock()
+			return time.Time{}, fmt.Errorf("%s: %w", l.provider, errAIBackpressure)
+		}
+		l.waiting++
+		for l.inflight >= int(l.limit) {
+			wake := l.wake
+			l.mu.Unlock()
+			select {
+			case <-wake:
+			case <-ctx.Done():
+				l.mu.Lock()
+				l.waiting--
+				l.mu.Unlock()
+				return time.Time{}, ctx.Err()
+			}
+			l.mu.Lock()
+		}
+		l.waiting--
+	}
+	l.inflight++
+	l.mu.Unlock()
+
+	if _, err := acquireStageSlot(ctx, pipelineStageAI); err != nil {
+		l.cancel()
+		return time.Time{}, err
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.lastRequest = now
+	l.mu.Unlock()
+	return now, nil
+}
+
+// cancel 归还未发出请求的并发名额，不计入统计也不调整并发数
+func (l *aiLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// release 归还并发名额，并根据本次请求的耗时与结果调整并发数
+// 流式请求传入收到首个输出的时间 firstDelta，平均延迟与并发调整按首个输出的等待时间计算：生成总时长取决于输出长度，
+// 不代表提供商变慢；非流式请求或没有收到输出时 firstDelta 为零值，按整个请求的耗时计算。累计耗时统计始终使用整个请求的耗时
+func (l *aiLimiter) release(start, firstDelta time.Time, err error) {
+	cfg := GetAIConfig().Backpressure.withDefaults()
+	duration := time.Since(start).Seconds()
+	latency := duration
+	if !firstDelta.IsZero() {
+		latency = firstDelta.Sub(start).Seconds()
+	}
+	// 调用方主动取消不代表提供商有问题
+	failed := err != nil && !errors.Is(err, context.Canceled)
+
+	defer stageLimiterFor(pipelineStageAI).release()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inflight--
+	l.requests++
+	l.latencySum += duration
+	l.maxLatencySec = math.Max(l.maxLatencySec, duration)
+	if failed {
+		l.failures++
+	}
+	if l.latencyEWMA == 0 {
+		l.latencyEWMA = latency
+	} else {
+		l.latencyEWMA = aiLatencyEWMAWeight*latency + (1-aiLatencyEWMAWeight)*l.latencyEWMA
+	}
+	l.outcomes = append(l.outcomes, failed)
+	if len(l.outcomes) > aiOutcomeWindow {
+		l.outcomes = l.outcomes[len(l.outcomes)-aiOutcomeWindow:]
+	}
+
+	if failed || latency > cfg.TargetLatencySeconds {
+		if time.Since(l.lastDecrease) >= aiDecreaseCooldown {
+			l.limit /= 2
+			l.lastDecrease = time.Now()
+			log.Printf("AI 提供商 %s 变慢或出错（耗时 %.1f 秒），并发数降为 %d", l.provider, latency, int(math.Max(l.limit, float64(cfg.MinConcurrency))))
+		}
+	} else {
+		// 每个并发名额成功一次，并发数加一
+		l.limit += 1 / l.limit
+	}
+	l.clampLocked(cfg)
+
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// aiCall 在提供商并发名额内执行的一次请求
+type aiCall struct {
+	firstDelta time.Time
+}
+
+// delta 流式请求每收到一段输出时调用，记录首个输出的时间用于延迟反馈
+func (c *aiCall) delta() {
+	if c.firstDelta.IsZero() {
+		c.firstDelta = time.Now()
+	}
+}
+
+// withAILimiter 按提供商的自适应并发数排队后执行 fn，结束后归还名额并按耗时与结果调整并发数
+// 队列已满时不执行 fn，直接返回 errAIBackpressure
+func withAILimiter[T any](ctx context.Context, provider string, fn func(call *aiCall) (T, error)) (_ T, err error) {
+	limiter := aiLimiterFor(provider)
+	start, err := limiter.acquire(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	call := &aiCall{}
+	defer func() { limiter.release(start, call.firstDelta, err) }()
+	return fn(call)
+}
+
+// errorRateLocked 最近请求的错误率，调用方需持有 l.mu
+func (l *aiLimiter) errorRateLocked() float64 {
+	if len(l.outcomes) == 0 {
+		return 0
+	}
+	failed := 0
+	for _, f := range l.outcomes {
+		if f {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(l.outcomes))
+}
+
+// healthy 提供商当前是否适合接收新请求：平均延迟未超过目标、错误率未超过阈值且等待队列未满
+// 长时间没有请求的提供商视为健康，让故障转移链有机会重新尝试它
+func (l *aiLimiter) healthy() bool {
+	cfg := GetAIConfig().Backpressure.withDefaults()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.lastRequest) > aiHealthProbeInterval {
+		return true
+	}
+	if l.waiting >= cfg.MaxQueueDepth || l.latencyEWMA > cfg.TargetLatencySeconds {
+		return false
+	}
+	return len(l.outcomes) < aiMinOutcomes || l.errorRateLocked() <= cfg.ErrorRateThreshold
+}
+
+// AIProviderStats 提供商的延迟、错误率与并发状态
+type AIProviderStats struct {
+	Provider           string  `json:"provider"`
+	ConcurrencyLimit   int     `json:"concurrency_limit"`
+	InFlight           int     `json:"in_flight"`
+	Queued             int     `json:"queued"`
+	LatencyEWMASeconds float64 `json:"latency_ewma_seconds"`
+	ErrorRate          float64 `json:"error_rate"`
+	Requests           int64   `json:"requests"`
+	Failures           int64   `json:"failures"`
+	Rejected           int64   `json:"rejected"`
+	LatencySumSeconds  float64 `json:"latency_sum_seconds"`
+	MaxLatencySeconds  float64 `json:"max_latency_seconds"`
+	Healthy            bool    `json:"healthy"`
+}
+
+// aiProviderStats 所有用过的提供商的当前状态，按名称排序
+func aiProviderStats() []AIProviderStats {
+	aiLimitersMu.Lock()
+	limiters := make([]*aiLimiter, 0, len(aiLimiters))
+	for _, name := range sortedKeys(aiLimiters) {
+		limiters = append(limiters, aiLimiters[name])
+	}
+	aiLimitersMu.Unlock()
+
+	stats := make([]AIProviderStats, 0, len(limiters))
+	for _, l := range limiters {
+		healthy := l.healthy()
+		l.mu.Lock()
+		stats = append(stats, AIProviderStats{
+			Provider:           l.provider,
+			ConcurrencyLimit:   int(l.limit),
+			InFlight:           l.inflight,
+			Queued:             l.waiting,
+			LatencyEWMASeconds: l.latencyEWMA,
+			ErrorRate:          l.errorRateLocked(),
+			Requests:           l.requests,
+			Failures:           l.failures,
+			Rejected:           l.rejected,
+			LatencySumSeconds:  l.latencySum,
+			MaxLatencySeconds:  l.maxLatencySec,
+			Healthy:            healthy,
+		})
+		l.mu.Unlock()
+	}
+	return stats
+}
+
+// AIProviderHealth 配置的AI提供商的健康检查结果与运行状态
+type AIProviderHealth struct {
+	Provider  string           `json:"provider"`
+	Model     string           `json:"model"`
+	Available bool             `json:"available"`
+	Error     string           `json:"error,omitempty"`
+	Stats     *AIProviderStats `json:"stats,omitempty"` // 尚未收到请求的提供商没有统计
+}
+
+// checkAIProviders 逐个检测配置的主提供商与备用提供商
+func checkAIProviders(ctx context.Context) []AIProviderHealth {
+	stats := map[string]AIProviderStats{}
+	for _, s := range aiProviderStats() {
+		stats[s.Provider] = s
+	}
+
+	providers := configuredAIProviders()
+	health := make([]AIProviderHealth, len(providers))
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		health[i] = AIProviderHealth{Provider: provider, Model: aiModelName(provider)}
+		if s, ok := stats[provider]; ok {
+			health[i].Stats = &s
+		}
+		wg.Add(1)
+		go func(h *AIProviderHealth) {
+			defer wg.Done()
+			err := NewAIService(h.Provider, "").Probe(ctx)
+			h.Available = err == nil
+			if err != nil {
+				h.Error = err.Error()
+			}
+		}(&health[i])
+	}
+	wg.Wait()
+	return health
+}
+
+// getAIProvidersHandler 检测配置的各AI提供商是否可用（不消耗生成额度），并附带延迟、错误率与并发状态
+func getAIProvidersHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), capabilityProbeTimeout)
+	defer cancel()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"providers": checkAIProviders(ctx),
+	})
+}
+
+// writeAIPrometheus 以 Prometheus 文本格式输出各AI提供商的延迟、错误与并发指标
+func writeAIPrometheus(b *strings.Builder) {
+	stats := aiProviderStats()
+	if len(stats) == 0 {
+		return
+	}
+
+	gauges := []struct {
+		name, help string
+		value      func(AIProviderStats) float64
+	}{
+		{"subtuber_ai_concurrency_limit", "Current adaptive concurrency limit for AI provider calls.", func(s AIProviderStats) float64 { return float64(s.ConcurrencyLimit) }},
+		{"subtuber_ai_in_flight", "AI provider calls currently running.", func(s AIProviderStats) float64 { return float64(s.InFlight) }},
+		{"subtuber_ai_queued", "AI provider calls waiting for a concurrency slot.", func(s AIProviderStats) float64 { return float64(s.Queued) }},
+		{"subtuber_ai_latency_ewma_seconds", "Exponentially weighted average latency of AI provider calls.", func(s AIProviderStats) float64 { return s.LatencyEWMASeconds }},
+		{"subtuber_ai_error_rate", "Error rate over the most recent AI provider calls.", func(s AIProviderStats) float64 { return s.ErrorRate }},
+		{"subtuber_ai_healthy", "Whether the failover chain currently prefers this AI provider.", func(s AIProviderStats) float64 {
+			if s.Healthy {
+				return 1
+			}
+			return 0
+		}},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(b, "# HELP %s %s\n", g.name, g.help)
+		fmt.Fprintf(b, "# TYPE %s gauge\n", g.name)
+		for _, s := range stats {
+			fmt.Fprintf(b, "%s{provider=%q} %g\n", g.name, s.Provider, g.value(s))
+		}
+	}
+
+	name := "subtuber_ai_requests_total"
+	fmt.Fprintf(b, "# HELP %s AI provider calls by outcome; rejected calls were refused because the queue was full.\n", name)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+	for _, s := range stats {
+		fmt.Fprintf(b, "%s{provider=%q,outcome=\"ok\"} %d\n", name, s.Provider, s.Requests-s.Failures)
+		fmt.Fprintf(b, "%s{provider=%q,outcome=\"error\"} %d\n", name, s.Provider, s.Failures)
+		fmt.Fprintf(b, "%s{provider=%q,outcome=\"rejected\"} %d\n", name, s.Provider, s.Rejected)
+	}
+
+	name = "subtuber_ai_request_duration_seconds"
+	fmt.Fprintf(b, "# HELP %s Total time spent in AI provider calls.\n", name)
+	fmt.Fprintf(b, "# TYPE %s summary\n", name)
+	for _, s := range stats {
+		fmt.Fprintf(b, "%s_sum{provider=%q} %g\n", name, s.Provider, s.LatencySumSeconds)
+		fmt.Fprintf(b, "%s_count{provider=%q} %d\n", name, s.Provider, s.Requests)
+	}
+}
+
+// failoverAIService 按配置的提供商顺序组成的故障转移链
+// 健康的提供商优先，同等情况下保持配置顺序；一个提供商失败时由下一个重新执行整个请求
+type failoverAIService struct {
+	providers []string
+}
+
+// NewConfiguredAIService 根据配置的主提供商与备用提供商创建带故障转移的AI服务
+func NewConfiguredAIService() AIService {
+	return &failoverAIService{providers: configuredAIProviders()}
+}
+
+// configuredAIProviders 主提供商与备用提供商（规范化、去重）
+func configuredAIProviders() []string {
+	cfg := GetAIConfig()
+	providers := []string{}
+	seen := map[string]bool{}
+	for _, p := range append([]string{cfg.Provider}, cfg.Fallbacks...) {
+		p = aiProviderName(p)
+		if !seen[p] {
+			seen[p] = true
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// orderedProviders 健康的提供商在前，其余按配置顺序排在后面作为最后的尝试
+func (s *failoverAIService) orderedProviders() []string {
+	var healthy, degraded []string
+	for _, p := range s.providers {
+		if aiLimiterFor(p).healthy() {
+			healthy = append(healthy, p)
+		} else {
+			degraded = append(degraded, p)
+		}
+	}
+	return append(healthy, degraded...)
+}
+
+// try 依次在各提供商上执行 fn，直到成功、调用方取消或全部失败
+// 全部失败且都是因为队列已满时返回 errAIBackpressure，调用方可以推迟重试
+func (s *failoverAIService) try(ctx context.Context, fn func(AIService) error) error {
+	var lastErr error
+	allBackpressure := true
+	for i, provider := range s.orderedProviders() {
+		if i > 0 {
+			log.Printf("AI 提供商切换到 %s（上一个失败: %v）", provider, lastErr)
+		}
+		err := fn(NewAIService(provider, ""))
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !errors.Is(err, errAIBackpressure) {
+			allBackpressure = false
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+	if allBackpressure && lastErr != nil {
+		return fmt.Errorf("所有AI提供商均繁忙: %w", errAIBackpressure)
+	}
+	return lastErr
+}
+
+// GenerateContent 在故障转移链上生成内容
+func (s *failoverAIService) GenerateContent(ctx context.Context, prompt string, maxOutputTokens int) (string, error) {
+	var text string
+	err := s.try(ctx, func(service AIService) error {
+		var err error
+		text, err = service.GenerateContent(ctx, prompt, maxOutputTokens)
+		return err
+	})
+	return text, err
+}
+
+// GenerateContentStream 在故障转移链上流式生成内容
+// 已向调用方输出部分内容后失败时不再切换提供商，避免输出重复或不连贯的内容
+func (s *failoverAIService) GenerateContentStream(ctx context.Context, prompt string, maxOutputTokens int, onDelta func(delta string) error) (string, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var text string
+	streamed := false
+	err := s.try(streamCtx, func(service AIService) error {
+		var err error
+		text, err = generateContentStream(streamCtx, service, prompt, maxOutputTokens, func(delta string) error {
+			streamed = true
+			return onDelta(delta)
+		})
+		if err != nil && streamed {
+			cancel()
+		}
+		return err
+	})
+	return text, err
+}
+
+// SummarizeSRT 在故障转移链上总结字幕，所有分段由同一个提供商完成
+func (s *failoverAIService) SummarizeSRT(ctx context.Context, srtContent string, chunkChars int) (string, []string, error) {
+	var summary string
+	var chunks []string
+	err := s.try(ctx, func(service AIService) error {
+		var err error
+		summary, chunks, err = service.SummarizeSRT(ctx, srtContent, chunkChars)
+		return err
+	})
+	return summary, chunks, err
+}
+
+// SaveSummaryToFile 保存总结，与提供商无关
+func (s *failoverAIService) SaveSummaryToFile(srtFilePath, summary string) error {
+	return NewAIService(s.providers[0], "").SaveSummaryToFile(srtFilePath, summary)
+}
+
+// Probe 任一提供商可用即可，全部不可用时返回各提供商的错误
+func (s *failoverAIService) Probe(ctx context.Context) error {
+	var errs []error
+	for _, provider := range s.providers {
+		err := NewAIService(provider, "").Probe(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider, err))
+	}
+	return errors.Join(errs...)
+}
+
+// deferSummaryRetry AI 服务繁忙时把热点总结放入延后队列，等待时间随重试次数翻倍
+// 已达到最多重试次数时返回 false，调用方应将总结标记为失败
+func deferSummaryRetry(videoID string, offsetSeconds float64, attempts int) bool {
+	if attempts >= aiSummaryRetryMaxAttempts {
+		log.Printf("视频 %s 热点 %.0f 秒的AI总结已推迟 %d 次，不再重试", videoID, offsetSeconds, attempts)
+		return false
+	}
+	notBefore := time.Now().Add(aiSummaryRetryDelay << attempts)
+	job, err := enqueueDeferredJob(DeferredJob{
+		Kind:          deferredJobSummaryRetry,
+		VideoID:       videoID,
+		OffsetSeconds: offsetSeconds,
+		Attempts:      attempts + 1,
+		NotBefore:     &notBefore,
+	})
+	if err != nil {
+		log.Printf("推迟AI总结失败: %v", err)
+		return false
+	}
+	log.Printf("AI 服务繁忙，视频 %s 热点 %.0f 秒的总结推迟到 %s 重试 (任务 %s)",
+		videoID, offsetSeconds, notBefore.Format("15:04:05"), job.ID)
+	return true
+}
+
+// runDeferredSummaryRetry 执行因背压推迟的热点总结，仍然繁忙时再次推迟
+func runDeferredSummaryRetry(job DeferredJob) {
+	err := retryClipSummary(context.Background(), job.VideoID, job.OffsetSeconds)
+	if err == nil {
+		return
+	}
+	if errors.Is(err, errAIBackpressure) && deferSummaryRetry(job.VideoID, job.OffsetSeconds, job.Attempts) {
+		setClipSummaryStatus(job.VideoID, job.OffsetSeconds, clipRunDeferred, err.Error())
+		return
+	}
+	log.Printf("延后的AI总结重试失败 (视频 %s, 热点 %.0f 秒): %v", job.VideoID, job.OffsetSeconds, err)
+}
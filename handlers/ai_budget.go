@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// 字幕总结的预算参数：分段大小、每段与最终总结的输出 token 数随字幕时长伸缩
+const (
+	// budgetChunkSeconds 每段覆盖的目标字幕时长
+	budgetChunkSeconds = 600.0
+	// budgetMinChunkChars/budgetMaxChunkChars 每段字符数的范围
+	budgetMinChunkChars = 4000
+	budgetMaxChunkChars = 30000
+	// budgetMaxChunks 分段数上限，超过时增大每段字符数（不超过 budgetMaxChunkChars）
+	budgetMaxChunks = 12
+	// budgetChunkTokensPerMinute 每分钟字幕对应的分段总结输出 token 数
+	budgetChunkTokensPerMinute = 60.0
+	budgetMinChunkTokens       = 150
+	budgetMaxChunkTokens       = 800
+	// budgetFinalWordsBase/budgetFinalWordsPerMinute 最终总结字数 = 基础字数 + 每分钟字数 × 时长
+	budgetFinalWordsBase      = 100.0
+	budgetFinalWordsPerMinute = 10.0
+	budgetMinFinalWords       = 100
+	budgetMaxFinalWords       = 600
+	// budgetTokensPerWord 中文与其他语言的平均每字 token 数（偏大估计，避免总结被截断）
+	budgetTokensPerWord = 2.0
+	// budgetFallbackCharsPerSecond 无法从时间轴得到时长时，按每秒字幕字符数估算
+	budgetFallbackCharsPerSecond = 15.0
+)
+
+// SummaryBudget 一次字幕总结的分段与输出 token 预算
+type SummaryBudget struct {
+	DurationSeconds   float64 `json:"duration_seconds"`
+	ChunkChars        int     `json:"chunk_chars"`
+	ChunkOutputTokens int     `json:"chunk_output_tokens"`
+	FinalWords        int     `json:"final_words"`
+	FinalOutputTokens int     `json:"final_output_tokens"`
+}
+
+// srtDurationSeconds 字幕第一条开始到最后一条结束的时长，无法解析时返回 0
+func srtDurationSeconds(srtContent string) float64 {
+	subtitles, err := ParseSRTDetailed(srtContent)
+	if err != nil || len(subtitles) == 0 {
+		return 0
+	}
+	start, err := parseSRTTime(subtitles[0].StartTime)
+	if err != nil {
+		return 0
+	}
+	end := start
+	for _, sub := range subtitles {
+		if t, err := parseSRTTime(sub.EndTime); err == nil && t > end {
+			end = t
+		}
+	}
+	return end - start
+}
+
+// clampInt 将 v 限制在 [lo, hi] 范围内
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// planSummaryBudget 按字幕时长规划分段大小与输出 token 数
+// chunkChars > 0 时使用调用方指定的分段大小，输出 token 数仍按每段实际覆盖的时长计算
+func planSummaryBudget(srtContent, transcript string, chunkChars int) SummaryBudget {
+	duration := srtDurationSeconds(srtContent)
+	if duration <= 0 {
+		duration = float64(len([]rune(transcript))) / budgetFallbackCharsPerSecond
+	}
+	duration = math.Max(duration, 1)
+	charsPerSecond := float64(len(transcript)) / duration
+
+	if chunkChars <= 0 {
+		chunkChars = clampInt(int(charsPerSecond*budgetChunkSeconds), budgetMinChunkChars, budgetMaxChunkChars)
+		if minChars := int(math.Ceil(float64(len(transcript)) / budgetMaxChunks)); chunkChars < minChars {
+			chunkChars = min(minChars, budgetMaxChunkChars)
+		}
+	}
+
+	// 每段覆盖的时长不超过整个字幕
+	chunkMinutes := math.Min(float64(chunkChars)/math.Max(charsPerSecond, 1), duration) / 60
+	minutes := duration / 60
+	finalWords := clampInt(int(budgetFinalWordsBase+budgetFinalWordsPerMinute*minutes), budgetMinFinalWords, budgetMaxFinalWords)
+
+	return SummaryBudget{
+		DurationSeconds:   math.Round(duration),
+		ChunkChars:        chunkChars,
+		ChunkOutputTokens: clampInt(int(budgetChunkTokensPerMinute*chunkMinutes), budgetMinChunkTokens, budgetMaxChunkTokens),
+		FinalWords:        finalWords,
+		FinalOutputTokens: int(float64(finalWords)*budgetTokensPerWord) + 100,
+	}
+}
+
+// summaryStreamCallbacks 流式总结的回调
+type summaryStreamCallbacks struct {
+	OnChunk func(done, total int)    // 每完成一个分段总结后调用
+	OnDelta func(delta string) error // 最终总结的增量文本，返回错误时停止生成
+}
+
+// summarizeSRTWithBudget 按预算分段总结字幕并合并为最终总结，各AI提供商的 SummarizeSRT 共用
+// chunkChars 为 0 时按字幕时长自动规划分段大小
+func summarizeSRTWithBudget(ctx context.Context, service AIService, srtContent string, chunkChars int) (string, []string, error) {
+	return summarizeSRTStream(ctx, service, srtContent, chunkChars, summaryStreamCallbacks{})
+}
+
+// summarizeSRTStream 与 summarizeSRTWithBudget 相同，设置 OnDelta 时最终总结以流式生成并逐段回调
+func summarizeSRTStream(ctx context.Context, service AIService, srtContent string, chunkChars int, callbacks summaryStreamCallbacks) (string, []string, error) {
+	transcript, err := parseSRTFile(srtContent)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse SRT file: %w", err)
+	}
+
+	budget := planSummaryBudget(srtContent, transcript, chunkChars)
+	overlapRatio := chunkOverlapRatio()
+	chunks := chunkTranscript(transcript, budget.ChunkChars, overlapRatio)
+	log.Printf("Parsed transcript length: %d characters, duration %.0fs, %d chunks of %d chars (%.0f%% overlap), %d tokens per chunk, final %d words (%d tokens)",
+		len(transcript), budget.DurationSeconds, len(chunks), budget.ChunkChars, overlapRatio*100, budget.ChunkOutputTokens, budget.FinalWords, budget.FinalOutputTokens)
+
+	// 提示词按主播与总结语言选择模板
+	vars := summaryPromptVarsFromContext(ctx)
+	vars.Words = budget.FinalWords
+	templates := promptTemplatesFor(vars.StreamerID, summaryLanguageFromContext(ctx))
+
+	summaries := make([]string, 0, len(chunks))
+	for i, ch := range chunks {
+		vars.Overlap = ch.Overlap
+		prompt := renderPromptOrBuiltin(templates.Chunk, builtinChunkPrompt, vars) + "\n\n" + ch.Text
+		s, err := service.GenerateContent(ctx, prompt, budget.ChunkOutputTokens)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to summarize chunk %d: %w", i, err)
+		}
+		summaries = append(summaries, s)
+		log.Printf("Summarized chunk %d/%d", i+1, len(chunks))
+		reportJobProgressCtx(ctx, JobStageAISummary, float64(i+1)/float64(len(chunks)+1)*100,
+			fmt.Sprintf("已总结 %d/%d 段", i+1, len(chunks)))
+		if callbacks.OnChunk != nil {
+			callbacks.OnChunk(i+1, len(chunks))
+		}
+
+		// Small delay to avoid rate bursts
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	// Combine intermediate summaries and produce a final summary
+	combined := strings.Join(summaries, "\n\n")
+	vars.Overlap = 0
+	finalPrompt := renderPromptOrBuiltin(templates.Final, builtinFinalPrompt, vars) + "\n\n" + combined
+	var finalSummary string
+	if callbacks.OnDelta != nil {
+		finalSummary, err = generateContentStream(ctx, service, finalPrompt, budget.FinalOutputTokens, callbacks.OnDelta)
+	} else {
+		finalSummary, err = service.GenerateContent(ctx, finalPrompt, budget.FinalOutputTokens)
+	}
+	if err != nil {
+		return "", summaries, fmt.Errorf("failed to produce final summary: %w", err)
+	}
+
+	return finalSummary, summaries, nil
+}
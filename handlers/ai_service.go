@@ -1,8 +1,15 @@
 package handlers
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
 
-// AIService defines the common interface for AI services (Google AI, Aliyun AI, etc.)
+// AIService defines the common interface for AI services (Google AI, Aliyun AI, OpenAI, Ollama, etc.)
 // This allows for easy switching between different AI providers
 type AIService interface {
 	// GenerateContent generates content using AI with a given prompt
@@ -11,7 +18,7 @@ type AIService interface {
 	GenerateContent(ctx context.Context, prompt string, maxOutputTokens int) (string, error)
 
 	// SummarizeSRT summarizes SRT subtitle content
-	// Input: ctx context, srtContent string (SRT file content), chunkChars int (size of each chunk)
+	// Input: ctx context, srtContent string (SRT file content), chunkChars int (size of each chunk, 0 plans it from the transcript duration)
 	// Output: final summary string, chunk summaries []string, error
 	SummarizeSRT(ctx context.Context, srtContent string, chunkChars int) (string, []string, error)
 
@@ -19,10 +26,37 @@ type AIService interface {
 	// Input: srtFilePath string, summary string
 	// Output: error
 	SaveSummaryToFile(srtFilePath, summary string) error
+
+	// Probe checks whether the provider is reachable and configured, returning nil when it can be used
+	// Probes must not consume generation quota
+	Probe(ctx context.Context) error
+}
+
+// StreamingAIService is implemented by AI services that can stream generated content as it arrives
+type StreamingAIService interface {
+	AIService
+
+	// GenerateContentStream generates content and calls onDelta with each piece of text as it arrives
+	// Input: ctx context, prompt string, maxOutputTokens int, onDelta callback (returning an error stops the generation)
+	// Output: the complete generated text string, error
+	GenerateContentStream(ctx context.Context, prompt string, maxOutputTokens int, onDelta func(delta string) error) (string, error)
+}
+
+// generateContentStream streams content when the service supports it; otherwise it generates the whole text
+// and delivers it to onDelta in one piece, so callers work the same across providers
+func generateContentStream(ctx context.Context, service AIService, prompt string, maxOutputTokens int, onDelta func(delta string) error) (string, error) {
+	if streaming, ok := service.(StreamingAIService); ok {
+		return streaming.GenerateContentStream(ctx, prompt, maxOutputTokens, onDelta)
+	}
+	text, err := service.GenerateContent(ctx, prompt, maxOutputTokens)
+	if err != nil {
+		return "", err
+	}
+	return text, onDelta(text)
 }
 
 // NewAIService creates an AI service instance based on the provider type
-// Input: provider string ("google" or "aliyun"), apiKey string (optional)
+// Input: provider string ("google", "aliyun", "openai" or "ollama"), apiKey string (optional, unused by ollama)
 // Output: AIService interface
 func NewAIService(provider string, apiKey string) AIService {
 	switch provider {
@@ -30,8 +64,31 @@ func NewAIService(provider string, apiKey string) AIService {
 		return NewGoogleAIService(apiKey)
 	case "aliyun":
 		return NewAliyunAIService(apiKey)
+	case "openai":
+		return NewOpenAIChatService(apiKey)
+	case "ollama":
+		return NewOllamaAIService()
 	default:
 		// Default to Google AI
 		return NewGoogleAIService(apiKey)
 	}
 }
+
+// capOutputTokens 按提供商配置的上限限制输出 token 数，limit 为 0 时不限制；
+// 未指定输出长度（requested 为 0）时使用上限
+func capOutputTokens(requested, limit int) int {
+	if limit > 0 && (requested <= 0 || requested > limit) {
+		return limit
+	}
+	return requested
+}
+
+// saveSummaryNextToSubtitle 将总结保存到字幕文件旁的 _summary.txt
+func saveSummaryNextToSubtitle(srtFilePath, summary string) error {
+	summaryPath := strings.TrimSuffix(srtFilePath, filepath.Ext(srtFilePath)) + "_summary.txt"
+	if err := os.WriteFile(summaryPath, []byte(summary), 0644); err != nil {
+		return fmt.Errorf("failed to write summary file: %w", err)
+	}
+	log.Printf("💾 Summary saved to: %s", summaryPath)
+	return nil
+}
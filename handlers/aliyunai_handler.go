@@ -16,9 +16,10 @@ import (
 
 // AliyunAIService provides AI summarization and content generation capabilities using Alibaba Cloud DashScope API
 type AliyunAIService struct {
-	apiKey string
-	client *openai.Client
-	model  string
+	apiKey          string
+	client          *openai.Client
+	model           string
+	maxOutputTokens int
 }
 
 // NewAliyunAIService creates a new AliyunAI service instance
@@ -35,18 +36,20 @@ func NewAliyunAIService(apiKey string) *AliyunAIService {
 
 	model := config.Model
 	if model == "" {
-		model = "qwen-flash" // 默认模型
+		model = defaultAliyunModel
 	}
 
 	client := openai.NewClient(
 		option.WithAPIKey(apiKey),
 		option.WithBaseURL("https://dashscope.aliyuncs.com/compatible-mode/v1"),
+		option.WithHTTPClient(newTracedHTTPClient(0)),
 	)
 
 	return &AliyunAIService{
-		apiKey: apiKey,
-		client: &client,
-		model:  model,
+		apiKey:          apiKey,
+		client:          &client,
+		model:           model,
+		maxOutputTokens: config.MaxOutputTokens,
 	}
 }
 
@@ -63,36 +66,90 @@ func (s *AliyunAIService) GenerateContent(ctx context.Context, prompt string, ma
 		return "", errors.New("Aliyun API key not configured")
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
-	defer cancel()
+	// 按提供商的自适应并发数排队，队列已满时返回 errAIBackpressure
+	return withAILimiter(ctx, "aliyun", func(call *aiCall) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+		defer cancel()
 
-	log.Printf("Calling Qwen API with maxOutputTokens: %d, prompt length: %d", maxOutputTokens, len(prompt))
+		log.Printf("Calling Qwen API with maxOutputTokens: %d, prompt length: %d", maxOutputTokens, len(prompt))
 
-	chatCompletion, err := s.client.Chat.Completions.New(
-		ctx, openai.ChatCompletionNewParams{
+		params := openai.ChatCompletionNewParams{
 			Messages: []openai.ChatCompletionMessageParamUnion{
 				openai.UserMessage(prompt),
 			},
 			Model: s.model,
-		},
-	)
+		}
+		if maxOutputTokens = capOutputTokens(maxOutputTokens, s.maxOutputTokens); maxOutputTokens > 0 {
+			params.MaxTokens = openai.Int(int64(maxOutputTokens))
+		}
+		chatCompletion, err := s.client.Chat.Completions.New(ctx, params)
 
-	if err != nil {
-		return "", fmt.Errorf("failed to generate content: %w", err)
-	}
+		if err != nil {
+			return "", fmt.Errorf("failed to generate content: %w", err)
+		}
 
-	if len(chatCompletion.Choices) == 0 {
-		return "", errors.New("no choices returned from API")
-	}
+		if len(chatCompletion.Choices) == 0 {
+			return "", errors.New("no choices returned from API")
+		}
+
+		text := chatCompletion.Choices[0].Message.Content
+		if text == "" {
+			return "", errors.New("no generated text found in response")
+		}
 
-	text := chatCompletion.Choices[0].Message.Content
-	if text == "" {
-		return "", errors.New("no generated text found in response")
+		log.Printf("Received response length: %d characters", len(text))
+
+		return text, nil
+	})
+}
+
+// GenerateContentStream generates content using the Qwen streaming API, calling onDelta with each piece of text as it arrives
+// Input: prompt string, maxOutputTokens int, onDelta callback
+// Output: the complete generated text string
+func (s *AliyunAIService) GenerateContentStream(ctx context.Context, prompt string, maxOutputTokens int, onDelta func(delta string) error) (string, error) {
+	if s.apiKey == "" {
+		return "", errors.New("Aliyun API key not configured")
 	}
 
-	log.Printf("Received response length: %d characters", len(text))
+	// 按提供商的自适应并发数排队，队列已满时返回 errAIBackpressure
+	return withAILimiter(ctx, "aliyun", func(call *aiCall) (string, error) {
+		// 回调出错时取消生成，StreamingChatCompletion 随之结束并关闭通道
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		log.Printf("Calling Qwen streaming API with maxOutputTokens: %d, prompt length: %d", maxOutputTokens, len(prompt))
 
-	return text, nil
+		messages := []openai.ChatCompletionMessageParamUnion{
+			openai.UserMessage(prompt),
+		}
+		deltas, errs := s.StreamingChatCompletion(ctx, messages, s.model, capOutputTokens(maxOutputTokens, s.maxOutputTokens))
+
+		var text strings.Builder
+		var callbackErr error
+		for delta := range deltas {
+			if callbackErr != nil {
+				continue
+			}
+			call.delta()
+			text.WriteString(delta)
+			if callbackErr = onDelta(delta); callbackErr != nil {
+				cancel()
+			}
+		}
+		if callbackErr != nil {
+			return text.String(), callbackErr
+		}
+		if err := <-errs; err != nil {
+			return text.String(), fmt.Errorf("failed to generate content: %w", err)
+		}
+		if text.Len() == 0 {
+			return "", errors.New("no generated text found in response")
+		}
+
+		log.Printf("Received streamed response length: %d characters", text.Len())
+
+		return text.String(), nil
+	})
 }
 
 // GenerateContentWithModel generates content using a specified Qwen model
@@ -103,81 +160,48 @@ func (s *AliyunAIService) GenerateContentWithModel(ctx context.Context, prompt s
 		return "", errors.New("Aliyun API key not configured")
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
-	defer cancel()
+	// 按提供商的自适应并发数排队，队列已满时返回 errAIBackpressure
+	return withAILimiter(ctx, "aliyun", func(call *aiCall) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+		defer cancel()
 
-	log.Printf("Calling Qwen API (%s) with maxOutputTokens: %d, prompt length: %d", model, maxOutputTokens, len(prompt))
+		log.Printf("Calling Qwen API (%s) with maxOutputTokens: %d, prompt length: %d", model, maxOutputTokens, len(prompt))
 
-	chatCompletion, err := s.client.Chat.Completions.New(
-		ctx, openai.ChatCompletionNewParams{
+		params := openai.ChatCompletionNewParams{
 			Messages: []openai.ChatCompletionMessageParamUnion{
 				openai.UserMessage(prompt),
 			},
 			Model: model,
-		},
-	)
+		}
+		if maxOutputTokens = capOutputTokens(maxOutputTokens, s.maxOutputTokens); maxOutputTokens > 0 {
+			params.MaxTokens = openai.Int(int64(maxOutputTokens))
+		}
+		chatCompletion, err := s.client.Chat.Completions.New(ctx, params)
 
-	if err != nil {
-		return "", fmt.Errorf("failed to generate content: %w", err)
-	}
+		if err != nil {
+			return "", fmt.Errorf("failed to generate content: %w", err)
+		}
 
-	if len(chatCompletion.Choices) == 0 {
-		return "", errors.New("no choices returned from API")
-	}
+		if len(chatCompletion.Choices) == 0 {
+			return "", errors.New("no choices returned from API")
+		}
 
-	text := chatCompletion.Choices[0].Message.Content
-	if text == "" {
-		return "", errors.New("no generated text found in response")
-	}
+		text := chatCompletion.Choices[0].Message.Content
+		if text == "" {
+			return "", errors.New("no generated text found in response")
+		}
 
-	log.Printf("Received response length: %d characters", len(text))
+		log.Printf("Received response length: %d characters", len(text))
 
-	return text, nil
+		return text, nil
+	})
 }
 
 // SummarizeSRT summarizes SRT subtitle content
-// Input: srtContent string (SRT file content), chunkChars int (size of each chunk, default 10000)
+// Input: srtContent string (SRT file content), chunkChars int (size of each chunk, 0 plans it from the transcript duration)
 // Output: final summary string, chunk summaries []string
 func (s *AliyunAIService) SummarizeSRT(ctx context.Context, srtContent string, chunkChars int) (string, []string, error) {
-	// Parse SRT content
-	transcript, err := parseSRTFile(srtContent)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to parse SRT file: %w", err)
-	}
-
-	if chunkChars <= 0 {
-		chunkChars = 10000
-	}
-
-	log.Printf("Parsed transcript length: %d characters", len(transcript))
-
-	// Split transcript into chunks
-	chunks := chunkText(transcript, chunkChars)
-	summaries := make([]string, 0, len(chunks))
-
-	// Summarize each chunk
-	for i, ch := range chunks {
-		prompt := "his is a clip from a streamer's live broadcast. To summarize, what topics are being discussed in this segment: \n\n" + ch
-		s, err := s.GenerateContent(ctx, prompt, 600)
-		if err != nil {
-			return "", nil, fmt.Errorf("failed to summarize chunk %d: %w", i, err)
-		}
-		summaries = append(summaries, s)
-		log.Printf("Summarized chunk %d/%d", i+1, len(chunks))
-
-		// Small delay to avoid rate bursts
-		time.Sleep(200 * time.Millisecond)
-	}
-
-	// Combine intermediate summaries and produce a final summary
-	combined := strings.Join(summaries, "\n\n")
-	finalPrompt := "Here are summaries of each section. Please consolidate them into a final summary, presenting key points in Chinese and keeping the length within 300 words: \n\n" + combined
-	finalSummary, err := s.GenerateContent(ctx, finalPrompt, 600)
-	if err != nil {
-		return "", summaries, fmt.Errorf("failed to produce final summary: %w", err)
-	}
-
-	return finalSummary, summaries, nil
+	return summarizeSRTWithBudget(ctx, s, srtContent, chunkChars)
 }
 
 // SaveSummaryToFile saves the summary to a text file next to the subtitle file
@@ -195,6 +219,14 @@ func (s *AliyunAIService) SaveSummaryToFile(srtFilePath, summary string) error {
 	return nil
 }
 
+// Probe 只检查是否配置了 API Key，不消耗生成额度
+func (s *AliyunAIService) Probe(ctx context.Context) error {
+	if s.apiKey == "" {
+		return errors.New("Aliyun API key not configured")
+	}
+	return nil
+}
+
 // ChatCompletion provides a more flexible chat completion interface
 // Input: messages []openai.ChatCompletionMessageParamUnion, model string, maxTokens int
 // Output: response text string
@@ -216,8 +248,9 @@ func (s *AliyunAIService) ChatCompletion(ctx context.Context, messages []openai.
 
 	chatCompletion, err := s.client.Chat.Completions.New(
 		ctx, openai.ChatCompletionNewParams{
-			Messages: messages,
-			Model:    model,
+			Messages:  messages,
+			Model:     model,
+			MaxTokens: openai.Int(int64(maxTokens)),
 		},
 	)
 
@@ -260,8 +293,9 @@ func (s *AliyunAIService) StreamingChatCompletion(ctx context.Context, messages
 
 		stream := s.client.Chat.Completions.NewStreaming(
 			ctx, openai.ChatCompletionNewParams{
-				Messages: messages,
-				Model:    model,
+				Messages:  messages,
+				Model:     model,
+				MaxTokens: openai.Int(int64(maxTokens)),
 			},
 		)
 
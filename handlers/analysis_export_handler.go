@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"subtuber-services/chatdownload"
+	"subtuber-services/pathsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	exportFrameRate      = 30 // EDL 时间码使用的帧率
+	exportTitleMaxRunes  = 40 // 标记标题的最大长度
+	youtubeChapterMinGap = 10 // YouTube 章节之间的最小间隔（秒）
+	youtubeMinChapters   = 3  // YouTube 识别章节列表所需的最少章节数
+)
+
+// exportMarker 导出用的热点标记
+type exportMarker struct {
+	Title         string
+	Description   string
+	OffsetSeconds float64
+	Duration      float64
+	Score         float64
+}
+
+// ExportAnalysisResult 将热点时刻导出为剪辑软件可用的标记文件
+// format=chat 时导出原始聊天记录 JSON
+// youtube-chapters 默认使用根据弹幕划分的章节，章节不足时或 source=hot_moments 时使用热点时刻
+// GET /api/analysis/:videoID/export?format=edl|csv|youtube-chapters|chat
+func ExportAnalysisResult(c *gin.Context) {
+	videoID := c.Param("videoID")
+	format := c.DefaultQuery("format", "csv")
+
+	if format == "chat" {
+		exportChatLog(c, filepath.Base(videoID))
+		return
+	}
+
+	params, err := peakParamsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	result, err := loadAnalysisResultForParams(videoID, params)
+	if err != nil {
+		respondAnalysisResultError(c, err)
+		return
+	}
+
+	markers := buildExportMarkers(videoID, result.HotMoments, params)
+
+	switch format {
+	case "edl":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.edl"`, videoID))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(renderEDL(result.VideoInfo.Title, markers)))
+	case "csv":
+		body, err := renderMarkerCSV(markers)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "生成CSV失败: " + err.Error(),
+			})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s_markers.csv"`, videoID))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", body)
+	case "youtube-chapters":
+		if c.Query("source") != "hot_moments" {
+			chapters := result.Chapters
+			if chapters == nil {
+				chapters, _ = chaptersForVideo(filepath.Base(videoID))
+			}
+			if len(chapters) >= youtubeMinChapters {
+				c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(renderChapterList(chapters)))
+				return
+			}
+		}
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(renderYouTubeChapters(markers)))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "不支持的导出格式: " + format + "（可选 edl、csv、youtube-chapters、chat）",
+		})
+	}
+}
+
+// loadAnalysisResultForParams 读取录像指定参数的分析结果，不存在时返回的错误满足 os.IsNotExist
+func loadAnalysisResultForParams(videoID string, params PeakDetectionParams) (AnalysisResult, error) {
+	var result AnalysisResult
+	data, err := readAnalysisResultData(videoID, analysisResultFileName(params))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, err
+		}
+		return result, fmt.Errorf("读取分析结果失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("解析分析结果失败: %w", err)
+	}
+	return result, nil
+}
+
+// respondAnalysisResultError 返回 loadAnalysisResultForParams 的错误
+func respondAnalysisResultError(c *gin.Context, err error) {
+	if os.IsNotExist(err) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "未找到该参数的分析结果，请先获取分析结果",
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": err.Error(),
+	})
+}
+
+// exportChatLog 导出视频的原始聊天记录
+// 文件以 gzip 压缩保存，客户端支持 gzip 时直接返回压缩内容，否则解压后返回
+// collapse_emotes=true 时将纯表情刷屏合并为 collapsed_emotes 中的条目（用于回放展示）
+func exportChatLog(c *gin.Context, videoID string) {
+	opts, err := emoteCollapseFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	twitchPath := chatdownload.FindTwitchLog(videoID)
+	path := twitchPath
+	if path == "" {
+		path = chatdownload.FindYouTubeLog(videoID)
+	}
+	if path == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "未找到该视频的聊天记录，请先下载聊天记录",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s_chat.json"`, videoID))
+	if opts.Enabled {
+		exportCollapsedChatLog(c, path, path == twitchPath, opts)
+		return
+	}
+	c.Header("Vary", "Accept-Encoding")
+
+	// 逐行 JSON 格式的聊天记录转换为下载接口相同的整份 JSON，评论逐条写出
+	if strings.HasSuffix(path, chatdownload.StreamExt) {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := chatdownload.WriteTwitchJSON(c.Writer, path); err != nil {
+			log.Printf("导出聊天记录 %s 失败: %v", path, err)
+		}
+		return
+	}
+
+	if strings.HasSuffix(path, chatdownload.CompressedExt) && strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+		c.Header("Content-Encoding", "gzip")
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.File(path)
+		return
+	}
+
+	r, err := chatdownload.OpenLog(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "读取聊天记录失败: " + err.Error(),
+		})
+		return
+	}
+	defer r.Close()
+	c.DataFromReader(http.StatusOK, -1, "application/json; charset=utf-8", r, nil)
+}
+
+// exportCollapsedChatLog 读取聊天记录并合并纯表情刷屏后返回
+func exportCollapsedChatLog(c *gin.Context, path string, twitch bool, opts emoteCollapseOptions) {
+	if twitch {
+		response, err := chatdownload.LoadTwitch(path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, newCollapsedTwitchChat(response, opts))
+		return
+	}
+
+	logs, err := chatdownload.LoadYouTube(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, newCollapsedYouTubeChat(logs, opts))
+}
+
+// buildExportMarkers 按时间顺序生成标记，标题优先使用AI生成的片段标题，否则取自AI总结的第一行
+// 已下架的总结不导出，标记只保留默认标题
+func buildExportMarkers(videoID string, hotMoments []VodCommentData, params PeakDetectionParams) []exportMarker {
+	videoDir := pathsafe.Join("./analysis_results", videoID)
+	moments := make([]VodCommentData, len(hotMoments))
+	copy(moments, hotMoments)
+	sort.Slice(moments, func(i, j int) bool {
+		return moments[i].OffsetSeconds < moments[j].OffsetSeconds
+	})
+
+	markers := make([]exportMarker, 0, len(moments))
+	for i, moment := range moments {
+		var summary, title string
+		if !IsTakenDown(ArtifactSummary, videoID, moment.OffsetSeconds) {
+			summary = readSummaryForOffset(videoDir, moment.OffsetSeconds)
+			title = summaryTitle(summary)
+			if moment.Metadata != nil && moment.Metadata.Title != "" {
+				title = moment.Metadata.Title
+			}
+		}
+		if title == "" {
+			title = fmt.Sprintf("热点 #%d", i+1)
+		}
+
+		markers = append(markers, exportMarker{
+			Title:         title,
+			Description:   summary,
+			OffsetSeconds: moment.OffsetSeconds,
+			Duration:      float64(params.WindowsLen),
+			Score:         moment.CommentsScore,
+		})
+	}
+	return markers
+}
+
+// summaryTitle 取总结的第一行非空文本作为标题
+func summaryTitle(summary string) string {
+	for _, line := range strings.Split(summary, "\n") {
+		line = strings.TrimSpace(strings.Trim(line, "#*- \t"))
+		if line == "" {
+			continue
+		}
+		return truncateTitle(line, exportTitleMaxRunes)
+	}
+	return ""
+}
+
+// truncateTitle 将标题截断到 maxRunes 个字符，超出时以 … 结尾
+func truncateTitle(s string, maxRunes int) string {
+	runes := []rune(strings.TrimSpace(s))
+	if maxRunes <= 0 || len(runes) <= maxRunes {
+		return string(runes)
+	}
+	return string(runes[:maxRunes-1]) + "…"
+}
+
+// formatTimecode 将秒数格式化为 HH:MM:SS:FF 时间码
+func formatTimecode(seconds float64) string {
+	if seconds < 0 {
+		seconds = 0
+	}
+	totalFrames := int(seconds * exportFrameRate)
+	frames := totalFrames % exportFrameRate
+	totalSeconds := totalFrames / exportFrameRate
+	return fmt.Sprintf("%02d:%02d:%02d:%02d",
+		totalSeconds/3600, (totalSeconds%3600)/60, totalSeconds%60, frames)
+}
+
+// renderEDL 生成 CMX3600 EDL，每个热点为一个事件并附带 DaVinci Resolve 可识别的标记
+func renderEDL(title string, markers []exportMarker) string {
+	if title == "" {
+		title = "LumiTime Hot Moments"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "TITLE: %s\n", title)
+	b.WriteString("FCM: NON-DROP FRAME\n\n")
+
+	for i, m := range markers {
+		in := formatTimecode(m.OffsetSeconds)
+		out := formatTimecode(m.OffsetSeconds + 1.0/exportFrameRate)
+		fmt.Fprintf(&b, "%03d  AX       V     C        %s %s %s %s\n", i+1, in, out, in, out)
+		fmt.Fprintf(&b, " |C:ResolveColorBlue |M:%s |D:1\n", m.Title)
+		fmt.Fprintf(&b, "* COMMENT: %s (score %.0f)\n\n", m.Title, m.Score)
+	}
+	return b.String()
+}
+
+// renderMarkerCSV 生成 Premiere Pro 标记格式的 CSV
+func renderMarkerCSV(markers []exportMarker) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write([]string{"Marker Name", "Description", "In", "Out", "Duration", "Marker Type", "Score"}); err != nil {
+		return nil, err
+	}
+	for _, m := range markers {
+		if err := w.Write([]string{
+			m.Title,
+			m.Description,
+			formatTimecode(m.OffsetSeconds),
+			formatTimecode(m.OffsetSeconds + m.Duration),
+			formatTimecode(m.Duration),
+			"Comment",
+			strconv.FormatFloat(m.Score, 'f', 0, 64),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// formatChapterTime 格式化 YouTube 章节时间：不足一小时为 M:SS，否则 H:MM:SS
+func formatChapterTime(seconds float64) string {
+	total := int(seconds)
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, (total%3600)/60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+// youtubeChapters 将按时间排序的标记生成 YouTube 章节
+// YouTube 要求第一个章节从 0:00 开始且章节间隔不少于 10 秒，间隔不足的标记跳过
+func youtubeChapters(markers []exportMarker) []HotMomentChapter {
+	chapters := []HotMomentChapter{{OffsetSeconds: 0, Time: formatChapterTime(0), Title: "开场"}}
+	last := 0.0
+	for _, m := range markers {
+		if m.OffsetSeconds-last < youtubeChapterMinGap {
+			continue
+		}
+		last = m.OffsetSeconds
+		chapters = append(chapters, HotMomentChapter{
+			OffsetSeconds: m.OffsetSeconds,
+			Time:          formatChapterTime(m.OffsetSeconds),
+			Title:         m.Title,
+			Summary:       summaryTitle(m.Description),
+		})
+	}
+	return chapters
+}
+
+// renderYouTubeChapters 生成可直接粘贴到视频简介的章节列表（只有时间与标题）
+func renderYouTubeChapters(markers []exportMarker) string {
+	var b strings.Builder
+	for _, ch := range youtubeChapters(markers) {
+		fmt.Fprintf(&b, "%s %s\n", ch.Time, ch.Title)
+	}
+	return b.String()
+}
+
+// renderChapterList 将弹幕章节生成为 YouTube 章节列表，第一个章节从 0:00 开始
+func renderChapterList(chapters []Chapter) string {
+	var b strings.Builder
+	for _, ch := range chapters {
+		fmt.Fprintf(&b, "%s %s\n", formatChapterTime(ch.StartSeconds), ch.Title)
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// analysisFlight 一次正在执行的分析，相同录像与参数的并发请求共享同一次执行
+type analysisFlight struct {
+	jobID   string
+	done    chan struct{}
+	value   interface{}
+	err     error
+	waiters int
+}
+
+var (
+	analysisFlightsMu sync.Mutex
+	analysisFlights   = map[string]*analysisFlight{}
+)
+
+// analysisFlightJobID 分析任务的ID，由录像ID与参数决定，并发的相同请求得到同一个ID
+// 执行期间可通过 /api/jobs/:id 查看进度
+func analysisFlightJobID(videoID, key string) string {
+	return fmt.Sprintf("analysis-%s-%s", videoID, computeSha256Hex(key)[:12])
+}
+
+// doAnalysisFlight 执行分析，已有相同的分析在执行时等待其结果而不重复执行
+// shared 为 true 表示结果来自其他请求发起的执行；结果不缓存，执行结束后的新请求会重新执行
+func doAnalysisFlight(videoID, key string, fn func() (interface{}, error)) (value interface{}, jobID string, shared bool, err error) {
+	flightKey := videoID + "\x00" + key
+
+	analysisFlightsMu.Lock()
+	if flight, ok := analysisFlights[flightKey]; ok {
+		flight.waiters++
+		analysisFlightsMu.Unlock()
+		log.Printf("录像 %s 的相同分析正在执行，合并请求 (任务ID: %s)", videoID, flight.jobID)
+		<-flight.done
+		return flight.value, flight.jobID, true, flight.err
+	}
+	flight := &analysisFlight{jobID: analysisFlightJobID(videoID, key), done: make(chan struct{})}
+	analysisFlights[flightKey] = flight
+	analysisFlightsMu.Unlock()
+
+	reportJobProgress(flight.jobID, JobStageAnalysis, 0, "录像 "+videoID)
+	defer func() {
+		analysisFlightsMu.Lock()
+		delete(analysisFlights, flightKey)
+		waiters := flight.waiters
+		analysisFlightsMu.Unlock()
+		close(flight.done)
+
+		finishJobProgress(flight.jobID, flight.err)
+		if waiters > 0 {
+			log.Printf("录像 %s 的分析完成，共合并 %d 个相同请求", videoID, waiters)
+		}
+	}()
+
+	// fn 异常退出时等待中的请求收到该错误
+	flight.err = fmt.Errorf("分析异常中止")
+	flight.value, flight.err = fn()
+	return flight.value, flight.jobID, false, flight.err
+}
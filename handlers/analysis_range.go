@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"subtuber-services/pathsafe"
+)
+
+const (
+	// minAnalysisRangeSeconds 区间分析允许的最短区间
+	minAnalysisRangeSeconds = 60
+	// defaultRangeMaxMoments 区间分析默认保留的热点数
+	defaultRangeMaxMoments = 5
+)
+
+// RangeAnalysisRequest 区间分析请求，start/end 为录像内的偏移（秒）
+type RangeAnalysisRequest struct {
+	Start      float64              `json:"start"`
+	End        float64              `json:"end" binding:"required"`
+	Params     *PeakDetectionParams `json:"params,omitempty"`        // 未指定时按区间长度缩放默认参数
+	MaxMoments int                  `json:"max_moments,omitempty"`   // 最多保留的热点数（按得分），默认 5
+	Clips      bool                 `json:"clips,omitempty"`         // 是否为区间内的热点下载片段并执行语音识别与AI总结
+	Interval   float64              `json:"clip_interval,omitempty"` // 片段时长（秒），默认使用流水线规则的设置
+}
+
+// AnalysisRange 分析结果对应的录像区间
+type AnalysisRange struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// rangePeakParams 区间分析的默认参数：窗口不超过默认值，且不超过区间长度的 1/6，保证短区间也能检出多个峰值
+func rangePeakParams(duration float64) PeakDetectionParams {
+	params := defaultPeakParams
+	if windows := int(duration / 6); windows < params.WindowsLen {
+		params.WindowsLen = int(math.Max(30, float64(windows)))
+		params.SearchRange = params.WindowsLen / 2
+	}
+	return params
+}
+
+// rangeAnalysisFileName 区间分析结果的文件名，与整场分析的 analysis_*.json 区分
+func rangeAnalysisFileName(r AnalysisRange, params PeakDetectionParams) string {
+	return fmt.Sprintf("range_%.0f-%.0f_%s.json", r.Start, r.End, peakParamsKey(params))
+}
+
+// analyzeRange 只统计区间内的评论进行峰值检测，结果中的偏移换算回录像时间
+// 以区间起点为 0 分析，避免区间外的空白拉低百分位阈值
+func analyzeRange(offsets []float64, r AnalysisRange, params PeakDetectionParams) AnalysisResultWithTimeSeries {
+	inRange := make([]float64, 0, len(offsets))
+	for _, offset := range offsets {
+		if offset >= r.Start && offset < r.End {
+			inRange = append(inRange, offset-r.Start)
+		}
+	}
+
+	result := NewAnalysisSession(inRange).Analyze(params)
+	for i := range result.HotMoments {
+		result.HotMoments[i].OffsetSeconds += r.Start
+		result.HotMoments[i].FormattedTime = formatDuration(result.HotMoments[i].OffsetSeconds)
+	}
+	for i := range result.TimeSeriesData {
+		result.TimeSeriesData[i].OffsetSeconds += r.Start
+		result.TimeSeriesData[i].FormattedTime = formatDuration(result.TimeSeriesData[i].OffsetSeconds)
+	}
+	for i := range result.SkipRanges {
+		result.SkipRanges[i].StartSeconds += r.Start
+		result.SkipRanges[i].EndSeconds += r.Start
+		result.SkipRanges[i].FormattedStart = formatDuration(result.SkipRanges[i].StartSeconds)
+		result.SkipRanges[i].FormattedEnd = formatDuration(result.SkipRanges[i].EndSeconds)
+	}
+	return result
+}
+
+// runRangeAnalysis 分析区间并保存结果，按需为区间内的热点加入片段任务
+func runRangeAnalysis(videoID string, r AnalysisRange, params PeakDetectionParams, maxMoments int, clips bool, interval float64) (AnalysisResult, error) {
+	offsets, err := loadChatOffsetsForVideo(videoID)
+	if err != nil {
+		return AnalysisResult{}, err
+	}
+
+	result := analyzeRange(offsets, r, params)
+
+	// 只保留得分最高的若干热点，按时间顺序返回
+	hotMoments := result.HotMoments
+	if len(hotMoments) > maxMoments {
+		sort.Slice(hotMoments, func(i, j int) bool { return hotMoments[i].CommentsScore > hotMoments[j].CommentsScore })
+		hotMoments = hotMoments[:maxMoments]
+		sort.Slice(hotMoments, func(i, j int) bool { return hotMoments[i].OffsetSeconds < hotMoments[j].OffsetSeconds })
+	}
+
+	annotateEmotions(videoID, hotMoments, result.TimeSeriesData, normalizePeakParams(params).WindowsLen)
+	annotateTopEmotes(videoID, hotMoments, normalizePeakParams(params).WindowsLen)
+	annotateChatters(videoID, hotMoments, result.TimeSeriesData, &result.Stats, normalizePeakParams(params).WindowsLen)
+
+	streamerName, videoInfo := loadVideoMetaForAnalysis(videoID)
+	saved := AnalysisResult{
+		VideoID:        videoID,
+		StreamerName:   streamerName,
+		Method:         peakMethodName(params),
+		HotMoments:     hotMoments,
+		TimeSeriesData: result.TimeSeriesData,
+		Stats:          result.Stats,
+		SkipRanges:     result.SkipRanges,
+		Range:          &r,
+		VideoInfo:      *videoInfo,
+		AnalyzedAt:     time.Now(),
+	}
+	videoDir := pathsafe.Join("./analysis_results", videoID)
+	filename := rangeAnalysisFileName(r, params)
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(videoDir, 0755); err == nil {
+			err = os.WriteFile(filepath.Join(videoDir, filename), data, 0644)
+		}
+	}
+	if err != nil {
+		return AnalysisResult{}, fmt.Errorf("保存分析结果失败: %w", err)
+	}
+	indexAnalysisResult(videoID, filename, saved, data)
+	log.Printf("视频 %s 区间 %s-%s 的分析结果已保存到: %s", videoID, formatDuration(r.Start), formatDuration(r.End), filename)
+
+	if clips && len(hotMoments) > 0 {
+		reportJobProgress(videoID, JobStageQueued, 0, fmt.Sprintf("区间 %s-%s", formatDuration(r.Start), formatDuration(r.End)))
+		go submitHotClipsJob(videoID, hotMoments, interval)
+	}
+	return saved, nil
+}
+
+// AnalyzeVODRange 只分析录像中用户选定的区间（使用已下载的聊天记录）
+// 可选为区间内的热点下载片段并生成AI总结，进度通过 /api/jobs/:videoID 查看
+// POST /api/analysis/:videoID/range
+func AnalyzeVODRange(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+
+	var req RangeAnalysisRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	// 片段会触发下载、语音识别与AI总结，只允许登录用户提交
+	if req.Clips {
+		if _, err := getUserHashFromCookie(c); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "未登录或登录已过期",
+			})
+			return
+		}
+	}
+	if req.Start < 0 || req.End-req.Start < minAnalysisRangeSeconds {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("区间无效：start 不能为负数，且区间长度至少 %d 秒", minAnalysisRangeSeconds),
+		})
+		return
+	}
+	r := AnalysisRange{Start: req.Start, End: req.End}
+
+	params := rangePeakParams(r.End - r.Start)
+	if req.Params != nil {
+		if err := validatePeakMethod(req.Params.Method); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		params = *req.Params
+	}
+	params = normalizePeakParams(params)
+
+	maxMoments := req.MaxMoments
+	if maxMoments <= 0 {
+		maxMoments = defaultRangeMaxMoments
+	}
+
+	interval := req.Interval
+	if req.Clips && interval <= 0 {
+		interval = loadPipelinePlan(videoID).ClipInterval
+	}
+	// 片段时长不超过所选区间的长度
+	interval = math.Min(interval, r.End-r.Start)
+
+	// 相同区间、参数与片段设置的并发请求共享同一次分析，片段任务也只加入一次
+	key := fmt.Sprintf("%s_%d_%t_%.0f", rangeAnalysisFileName(r, params), maxMoments, req.Clips, interval)
+	value, analysisJobID, shared, err := doAnalysisFlight(videoID, key, func() (interface{}, error) {
+		return runRangeAnalysis(videoID, r, params, maxMoments, req.Clips, interval)
+	})
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "未找到该视频的聊天记录，请先下载聊天记录",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	saved := value.(AnalysisResult)
+
+	resp := gin.H{
+		"video_id":        videoID,
+		"range":           r,
+		"params":          params,
+		"file":            rangeAnalysisFileName(r, params),
+		"result":          saved,
+		"analysis_job_id": analysisJobID,
+		"coalesced":       shared,
+	}
+	if req.Clips && len(saved.HotMoments) > 0 {
+		resp["job_id"] = videoID
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
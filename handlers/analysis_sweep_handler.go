@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"subtuber-services/chatdownload"
+	"subtuber-services/models"
+	"subtuber-services/pathsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSweepParams 单次参数扫描允许的最大参数组数
+const maxSweepParams = 64
+
+// AnalysisSweepRequest 参数扫描请求
+type AnalysisSweepRequest struct {
+	VideoID string                `json:"video_id" binding:"required"`
+	Params  []PeakDetectionParams `json:"params"`
+	// Grid 参数网格，与 params 合并后去重
+	Grid *AnalysisSweepGrid `json:"grid,omitempty"`
+	// Save 为 true 时将每组参数的分析结果保存为 analysis_*.json（需要登录），相同参数的并发保存请求合并为一次分析；
+	// 默认只返回对比结果，不写入文件
+	Save bool `json:"save,omitempty"`
+}
+
+// AnalysisSweepGrid 参数网格，各维度取值的所有组合，每个维度至少需要一个取值（0 表示默认值）
+type AnalysisSweepGrid struct {
+	Method      string    `json:"method,omitempty"`
+	WindowsLen  []int     `json:"windows_len"`
+	Thr         []float64 `json:"thr"`
+	SearchRange []int     `json:"search_range"`
+}
+
+// size 网格的参数组数；有维度为空或组数超过 limit 时返回错误，逐维相乘前检查避免溢出
+func (g AnalysisSweepGrid) size(limit int) (int, error) {
+	axes := []struct {
+		name string
+		len  int
+	}{
+		{"windows_len", len(g.WindowsLen)},
+		{"thr", len(g.Thr)},
+		{"search_range", len(g.SearchRange)},
+	}
+	n := 1
+	for _, axis := range axes {
+		if axis.len == 0 {
+			return 0, fmt.Errorf("参数网格的 %s 至少需要一个取值", axis.name)
+		}
+		if n > limit/axis.len {
+			return 0, fmt.Errorf("参数组数不能超过 %d", maxSweepParams)
+		}
+		n *= axis.len
+	}
+	return n, nil
+}
+
+// expand 展开网格中的所有参数组合，调用前需用 size 检查组数
+func (g AnalysisSweepGrid) expand(size int) []PeakDetectionParams {
+	params := make([]PeakDetectionParams, 0, size)
+	for _, w := range g.WindowsLen {
+		for _, thr := range g.Thr {
+			for _, r := range g.SearchRange {
+				params = append(params, PeakDetectionParams{Method: g.Method, WindowsLen: w, Thr: thr, SearchRange: r})
+			}
+		}
+	}
+	return params
+}
+
+// AnalysisSweepItem 单组参数的分析结果（不包含时间序列数据）
+type AnalysisSweepItem struct {
+	Params     PeakDetectionParams `json:"params"`
+	HotMoments []VodCommentData    `json:"hot_moments"`
+	Stats      VodCommentStats     `json:"stats"`
+	File       string              `json:"file,omitempty"` // 保存的分析结果文件名
+}
+
+// SweepComparisonRow 对比表中单组参数的汇总
+type SweepComparisonRow struct {
+	Key             string  `json:"key"` // 参数标识，与分析结果文件名中的参数部分相同
+	HotMomentsCount int     `json:"hot_moments_count"`
+	MeanOverlap     float64 `json:"mean_overlap"` // 与其他各组参数热点重合度（Jaccard）的平均值
+}
+
+// SweepComparison 参数扫描的对比表，overlap[i][j] 为第 i 组与第 j 组参数热点的重合度
+type SweepComparison struct {
+	Rows    []SweepComparisonRow `json:"rows"`
+	Overlap [][]float64          `json:"overlap"`
+}
+
+// compareSweepResults 两两比较各组参数的热点，匹配容差与影子模式相同（窗口较短一方的一半，至少 shadowMinMatchSeconds）
+func compareSweepResults(results []AnalysisSweepItem) SweepComparison {
+	cmp := SweepComparison{
+		Rows:    make([]SweepComparisonRow, len(results)),
+		Overlap: make([][]float64, len(results)),
+	}
+	for i := range results {
+		cmp.Overlap[i] = make([]float64, len(results))
+		cmp.Overlap[i][i] = 1
+	}
+	for i := range results {
+		for j := i + 1; j < len(results); j++ {
+			window := min(results[i].Params.WindowsLen, results[j].Params.WindowsLen)
+			tolerance := math.Max(shadowMinMatchSeconds, float64(window)/2)
+			overlap := compareHotMoments(results[i].HotMoments, results[j].HotMoments, tolerance).Overlap
+			cmp.Overlap[i][j], cmp.Overlap[j][i] = overlap, overlap
+		}
+	}
+	for i, result := range results {
+		row := SweepComparisonRow{Key: peakParamsKey(result.Params), HotMomentsCount: len(result.HotMoments)}
+		if len(results) > 1 {
+			var sum float64
+			for j := range results {
+				if j != i {
+					sum += cmp.Overlap[i][j]
+				}
+			}
+			row.MeanOverlap = math.Round(sum/float64(len(results)-1)*1000) / 1000
+		}
+		cmp.Rows[i] = row
+	}
+	return cmp
+}
+
+// loadTwitchChatOffsets 逐条读取 Twitch 聊天记录文件，返回所有评论的时间偏移与录像信息
+// 评论不在内存中保留，长录像的聊天记录也只占用时间偏移的空间
+func loadTwitchChatOffsets(path string) ([]float64, chatdownload.TwitchLogHeader, error) {
+	var offsets []float64
+	header, err := chatdownload.ReadTwitch(path, func(comment models.TwitchChatComment) error {
+		offsets = append(offsets, comment.ContentOffsetSeconds)
+		return nil
+	})
+	if err != nil {
+		return nil, header, err
+	}
+	return offsets, header, nil
+}
+
+// loadChatOffsetsForVideo 读取视频的聊天记录并返回所有评论的时间偏移
+// 依次查找 Twitch 与 YouTube 的聊天记录文件
+func loadChatOffsetsForVideo(videoID string) ([]float64, error) {
+	var offsets []float64
+	if err := eachChatMessage(videoID, func(msg models.ChatMessage) {
+		offsets = append(offsets, msg.OffsetSeconds)
+	}); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+// loadVideoMetaForAnalysis 获取保存分析结果所需的主播名与录像信息
+// 优先使用 Twitch 聊天记录中的录像信息，否则沿用已有分析结果中的信息
+func loadVideoMetaForAnalysis(videoID string) (string, *models.TwitchVideoData) {
+	if path := chatdownload.FindTwitchLog(videoID); path != "" {
+		if header, err := chatdownload.ReadTwitchHeader(path); err == nil && header.VideoInfo != nil {
+			return header.VideoInfo.UserName, header.VideoInfo
+		}
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(pathsafe.Join("./analysis_results", videoID), "analysis_*.json"))
+	for _, match := range matches {
+		data, err := os.ReadFile(match)
+		if err != nil {
+			continue
+		}
+		var existing AnalysisResult
+		if err := json.Unmarshal(data, &existing); err == nil {
+			return existing.StreamerName, &existing.VideoInfo
+		}
+	}
+
+	return "", &models.TwitchVideoData{ID: videoID}
+}
+
+// SweepAnalysisParams 对同一视频使用多组参数（或参数网格）进行峰值检测，返回各组热点与两两重合度的对比表
+// 评论密度只按窗口长度计算一次，各组参数共享中间结果；save 为 true 时保存每组参数的分析结果，需要登录
+// POST /api/analysis/sweep
+func SweepAnalysisParams(c *gin.Context) {
+	var req AnalysisSweepRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 保存结果会写入最多 maxSweepParams 个文件，只允许登录用户保存
+	if req.Save {
+		if _, err := getUserHashFromCookie(c); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "未登录或登录已过期",
+			})
+			return
+		}
+	}
+
+	// 展开网格前检查组数，避免按客户端提供的网格分配过大的内存
+	if len(req.Params) > maxSweepParams {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("参数组数不能超过 %d", maxSweepParams),
+		})
+		return
+	}
+	paramSets := req.Params
+	if req.Grid != nil {
+		size, err := req.Grid.size(maxSweepParams - len(req.Params))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		paramSets = append(paramSets, req.Grid.expand(size)...)
+	}
+	if len(paramSets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "至少需要一组参数",
+		})
+		return
+	}
+
+	for _, params := range paramSets {
+		if err := validatePeakMethod(params.Method); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	run := func() (interface{}, error) {
+		return runAnalysisSweep(req.VideoID, paramSets, req.Save)
+	}
+	var value interface{}
+	var jobID string
+	var shared bool
+	var err error
+	if req.Save {
+		// 相同参数组合的并发保存请求共享同一次分析，避免重复写入结果文件
+		keys := make([]string, 0, len(paramSets))
+		for _, params := range paramSets {
+			keys = append(keys, peakParamsKey(normalizePeakParams(params)))
+		}
+		sort.Strings(keys)
+		value, jobID, shared, err = doAnalysisFlight(req.VideoID, "sweep:"+strings.Join(keys, ","), run)
+	} else {
+		value, err = run()
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "未找到该视频的聊天记录，请先下载聊天记录",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	sweep := value.(analysisSweep)
+
+	resp := gin.H{
+		"video_id":       req.VideoID,
+		"total_comments": sweep.totalComments,
+		"results":        sweep.results,
+		"comparison":     compareSweepResults(sweep.results),
+	}
+	if req.Save {
+		resp["analysis_job_id"] = jobID
+		resp["coalesced"] = shared
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// analysisSweep 参数扫描的结果
+type analysisSweep struct {
+	totalComments int
+	results       []AnalysisSweepItem
+}
+
+// runAnalysisSweep 使用多组参数分析同一视频，重复的参数只分析一次；save 为 true 时分别保存结果文件
+func runAnalysisSweep(videoID string, paramSets []PeakDetectionParams, save bool) (analysisSweep, error) {
+	offsets, err := loadChatOffsetsForVideo(videoID)
+	if err != nil {
+		return analysisSweep{}, err
+	}
+
+	var streamerName string
+	var videoInfo *models.TwitchVideoData
+	if save {
+		streamerName, videoInfo = loadVideoMetaForAnalysis(videoID)
+	}
+
+	session := NewAnalysisSession(offsets)
+	results := make([]AnalysisSweepItem, 0, len(paramSets))
+	seen := make(map[PeakDetectionParams]bool, len(paramSets))
+	for _, params := range paramSets {
+		params = normalizePeakParams(params)
+		if seen[params] {
+			continue
+		}
+		seen[params] = true
+
+		result := session.Analyze(params)
+		item := AnalysisSweepItem{
+			Params:     params,
+			HotMoments: result.HotMoments,
+			Stats:      result.Stats,
+		}
+		if save {
+			if err := saveAnalysisResultToFile(videoID, result.HotMoments, result.TimeSeriesData,
+				streamerName, result.Stats, result.SkipRanges, videoInfo, params); err != nil {
+				return analysisSweep{}, fmt.Errorf("保存分析结果失败: %w", err)
+			}
+			item.File = analysisResultFileName(params)
+		}
+		results = append(results, item)
+	}
+	return analysisSweep{totalComments: len(offsets), results: results}, nil
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestSweepRequestDryRunByDefault 参数扫描默认只返回对比结果，只有 save 为 true 时保存
+func TestSweepRequestDryRunByDefault(t *testing.T) {
+	var req AnalysisSweepRequest
+	if err := json.Unmarshal([]byte(`{"video_id":"1","params":[{}]}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Save {
+		t.Errorf("Save = true by default, want false")
+	}
+}
+
+// TestSweepSaveRequiresSession 未登录时保存扫描结果返回 401
+func TestSweepSaveRequiresSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/analysis/sweep",
+		strings.NewReader(`{"video_id":"1","params":[{}],"save":true}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	SweepAnalysisParams(c)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// 时间序列存储方式
+const (
+	timeSeriesStorageInline  = "inline"
+	timeSeriesStorageSidecar = "sidecar"
+)
+
+// useTimeSeriesSidecar 当前配置是否将时间序列单独存储
+func useTimeSeriesSidecar() bool {
+	return GetAnalysisConfig().TimeSeriesStorage == timeSeriesStorageSidecar
+}
+
+// timeSeriesSidecarName 时间序列附属文件名：timeseries_{windowsLen}_{thr}_{searchRange}.json.gz，参数部分与分析结果文件一致
+func timeSeriesSidecarName(params PeakDetectionParams) string {
+	return fmt.Sprintf("timeseries_%s.json.gz", peakParamsKey(params))
+}
+
+// writeTimeSeriesSidecar 将时间序列以 gzip 压缩的 JSON 写入分析目录，返回文件名
+func writeTimeSeriesSidecar(videoDir string, params PeakDetectionParams, data []TimeSeriesDataPoint) (string, error) {
+	name := timeSeriesSidecarName(params)
+
+	f, err := os.Create(filepath.Join(videoDir, name))
+	if err != nil {
+		return "", fmt.Errorf("创建时间序列文件失败: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	if err := json.NewEncoder(gz).Encode(data); err != nil {
+		gz.Close()
+		return "", fmt.Errorf("写入时间序列失败: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("写入时间序列失败: %w", err)
+	}
+
+	return name, nil
+}
+
+// loadTimeSeriesSidecar 读取分析目录中的时间序列附属文件
+func loadTimeSeriesSidecar(videoDir, name string) ([]TimeSeriesDataPoint, error) {
+	// 文件名来自分析结果，只允许引用同目录下的文件
+	f, err := os.Open(filepath.Join(videoDir, filepath.Base(name)))
+	if err != nil {
+		return nil, fmt.Errorf("打开时间序列文件失败: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("解压时间序列失败: %w", err)
+	}
+	defer gz.Close()
+
+	var data []TimeSeriesDataPoint
+	if err := json.NewDecoder(gz).Decode(&data); err != nil {
+		return nil, fmt.Errorf("解析时间序列失败: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// APIV1Prefix 版本化接口的路径前缀
+	APIV1Prefix = "/api/v1"
+	// LegacyAPIPrefix 未版本化的旧接口路径前缀，保留用于兼容
+	LegacyAPIPrefix = "/api"
+)
+
+// DeprecatedAPIMiddleware 为旧路径的响应加上弃用提示，并通过 Link 头指向对应的 /api/v1 路径
+func DeprecatedAPIMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+v1PathFor(c.Request.URL.Path)+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
+
+// WrapHTTPHandler 将 net/http 风格的处理函数适配为 gin 处理函数，路由参数通过 PathValue 读取
+func WrapHTTPHandler(h http.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, p := range c.Params {
+			c.Request.SetPathValue(p.Key, p.Value)
+		}
+		h(c.Writer, c.Request)
+	}
+}
+
+// v1PathFor 返回旧路径对应的 /api/v1 路径
+func v1PathFor(path string) string {
+	if strings.HasPrefix(path, APIV1Prefix+"/") {
+		return path
+	}
+	return APIV1Prefix + strings.TrimPrefix(path, LegacyAPIPrefix)
+}
+
+// canonicalRoute 将 /api/v1 路由模板还原为旧路径形式，使两套路径共用统计与限额配置
+func canonicalRoute(fullPath string) string {
+	if rest, ok := strings.CutPrefix(fullPath, APIV1Prefix); ok {
+		return LegacyAPIPrefix + rest
+	}
+	return fullPath
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"subtuber-services/services"
+)
+
+// 语音识别提供商，对应配置 asr.provider 与 asr.fallbacks
+const (
+	asrProviderBcut       = "bcut"
+	asrProviderOpenAI     = "openai"
+	asrProviderWhisperCpp = "whisper_cpp"
+)
+
+// ASRService defines the common interface for speech recognition backends (Bcut, Whisper, etc.)
+type ASRService interface {
+	// Name returns the provider name used in config and logs
+	Name() string
+
+	// Transcribe recognizes the speech in an audio file
+	// Input: ctx context (carries the job ID for progress reports), audioPath string (mp3 extracted from the clip)
+	// Output: recognized segments with millisecond timestamps, error
+	Transcribe(ctx context.Context, audioPath string) (*services.ASRResult, error)
+
+	// Probe checks whether the provider is reachable and configured, returning nil when it can be used
+	Probe(ctx context.Context) error
+}
+
+// Validate checks that the ASR configuration only names supported providers and that they are configured
+func (c ASRConfig) Validate() error {
+	for _, provider := range c.providers() {
+		switch provider {
+		case asrProviderBcut, asrProviderOpenAI:
+		case asrProviderWhisperCpp:
+			if c.WhisperCpp.Model == "" {
+				return fmt.Errorf("使用 whisper_cpp 语音识别时必须配置模型文件 asr.whisper_cpp.model")
+			}
+		default:
+			return fmt.Errorf("不支持的语音识别提供商: %s", provider)
+		}
+	}
+	if c.WhisperCpp.Threads < 0 {
+		return fmt.Errorf("whisper.cpp 线程数不能为负数")
+	}
+	return nil
+}
+
+// providers 主提供商与备用提供商（去重、统一小写），未配置时只使用必剪
+func (c ASRConfig) providers() []string {
+	providers := []string{}
+	seen := map[string]bool{}
+	for _, p := range append([]string{c.Provider}, c.Fallbacks...) {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			p = asrProviderBcut
+		}
+		if !seen[p] {
+			seen[p] = true
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// NewASRService creates a speech recognition service for the provider, unknown providers use Bcut
+func NewASRService(provider string) ASRService {
+	switch provider {
+	case asrProviderOpenAI:
+		return newOpenAIWhisperASR(GetASRConfig().OpenAI)
+	case asrProviderWhisperCpp:
+		return newWhisperCppASR(GetASRConfig().WhisperCpp)
+	default:
+		return bcutASRService{}
+	}
+}
+
+// bcutASRService 必剪语音识别，上传音频后轮询识别结果
+type bcutASRService struct{}
+
+func (bcutASRService) Name() string { return asrProviderBcut }
+
+func (bcutASRService) Transcribe(ctx context.Context, audioPath string) (*services.ASRResult, error) {
+	audioData, err := os.ReadFile(audioPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取音频失败: %w", err)
+	}
+	asr := services.NewBcutASR(audioData)
+	reportJobProgressCtx(ctx, JobStageASR, 0, "上传音频")
+	asr.OnState = func(state int) {
+		reportJobProgressCtx(ctx, JobStageASR, 0, asrStateText(state))
+	}
+	return asr.Run()
+}
+
+func (bcutASRService) Probe(ctx context.Context) error { return probeBcutASR(ctx) }
+
+// failoverASRService 按配置的提供商顺序组成的故障转移链，一个提供商失败时由下一个重新识别
+type failoverASRService struct {
+	providers []string
+}
+
+// NewConfiguredASRService 根据配置的主提供商与备用提供商创建带故障转移的语音识别服务
+func NewConfiguredASRService() ASRService {
+	return &failoverASRService{providers: GetASRConfig().providers()}
+}
+
+func (s *failoverASRService) Name() string { return strings.Join(s.providers, ",") }
+
+// Transcribe 依次使用各提供商识别，直到成功、调用方取消或全部失败
+func (s *failoverASRService) Transcribe(ctx context.Context, audioPath string) (*services.ASRResult, error) {
+	var errs []error
+	for i, provider := range s.providers {
+		if i > 0 {
+			log.Printf("语音识别切换到 %s（上一个失败: %v）", provider, errs[len(errs)-1])
+		}
+		result, err := NewASRService(provider).Transcribe(ctx, audioPath)
+		if err == nil {
+			return result, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Probe 任一提供商可用即可，全部不可用时返回各提供商的错误
+func (s *failoverASRService) Probe(ctx context.Context) error {
+	var errs []error
+	for _, provider := range s.providers {
+		err := NewASRService(provider).Probe(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider, err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"subtuber-services/services"
+)
+
+const (
+	defaultOpenAIWhisperBaseURL = "https://api.openai.com/v1"
+	defaultOpenAIWhisperModel   = "whisper-1"
+	defaultWhisperCppBinary     = "whisper-cli"
+
+	// openAIWhisperMaxBytes Whisper 接口单个文件的大小上限
+	openAIWhisperMaxBytes = 25 << 20
+)
+
+// openAIWhisperASR 使用 OpenAI Whisper 接口（或兼容的自建服务）识别
+type openAIWhisperASR struct {
+	apiKey   string
+	baseURL  string
+	model    string
+	language string
+}
+
+// newOpenAIWhisperASR 创建 Whisper 接口的识别服务，未配置 API Key 时使用 OPENAI_API_KEY 环境变量
+func newOpenAIWhisperASR(cfg OpenAIWhisperConfig) *openAIWhisperASR {
+	s := &openAIWhisperASR{
+		apiKey:   cfg.APIKey,
+		baseURL:  strings.TrimRight(cfg.BaseURL, "/"),
+		model:    cfg.Model,
+		language: cfg.Language,
+	}
+	if s.apiKey == "" {
+		s.apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	if s.baseURL == "" {
+		s.baseURL = defaultOpenAIWhisperBaseURL
+	}
+	if s.model == "" {
+		s.model = defaultOpenAIWhisperModel
+	}
+	return s
+}
+
+func (s *openAIWhisperASR) Name() string { return asrProviderOpenAI }
+
+// Probe 只检查是否配置了 API Key（自建的兼容服务可以不需要），不消耗识别额度
+func (s *openAIWhisperASR) Probe(ctx context.Context) error {
+	if s.apiKey == "" && s.baseURL == defaultOpenAIWhisperBaseURL {
+		return fmt.Errorf("未配置 OpenAI API Key")
+	}
+	return nil
+}
+
+// Transcribe 上传音频并以 verbose_json 格式获取带时间戳的分段
+func (s *openAIWhisperASR) Transcribe(ctx context.Context, audioPath string) (*services.ASRResult, error) {
+	if err := s.Probe(ctx); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(audioPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取音频失败: %w", err)
+	}
+	if info.Size() > openAIWhisperMaxBytes {
+		return nil, fmt.Errorf("音频 %.1f MB 超过 Whisper 接口 25 MB 的上限", float64(info.Size())/(1<<20))
+	}
+	audio, err := os.Open(audioPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取音频失败: %w", err)
+	}
+	defer audio.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := map[string]string{"model": s.model, "response_format": "verbose_json"}
+	if s.language != "" {
+		fields["language"] = s.language
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	part, err := form.CreateFormFile("file", filepath.Base(audioPath))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/audio/transcriptions", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	reportJobProgressCtx(ctx, JobStageASR, 0, "Whisper 识别中")
+	resp, err := newTracedHTTPClient(0).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 Whisper 接口失败: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Whisper 接口返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var result struct {
+		Text     string `json:"text"`
+		Segments []struct {
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Text  string  `json:"text"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("解析 Whisper 识别结果失败: %w", err)
+	}
+
+	segments := make([]services.ASRSegment, 0, len(result.Segments))
+	for _, seg := range result.Segments {
+		if text := strings.TrimSpace(seg.Text); text != "" {
+			segments = append(segments, services.ASRSegment{
+				Text:      text,
+				StartTime: int64(seg.Start * 1000),
+				EndTime:   int64(seg.End * 1000),
+			})
+		}
+	}
+	return &services.ASRResult{Segments: segments, RawData: json.RawMessage(data)}, nil
+}
+
+// whisperCppASR 使用本地 whisper.cpp 识别，不依赖外部服务
+type whisperCppASR struct {
+	binary   string
+	model    string
+	language string
+	threads  int
+}
+
+// newWhisperCppASR 创建 whisper.cpp 的识别服务
+func newWhisperCppASR(cfg WhisperCppConfig) *whisperCppASR {
+	s := &whisperCppASR{
+		binary:   cfg.Binary,
+		model:    cfg.Model,
+		language: cfg.Language,
+		threads:  cfg.Threads,
+	}
+	if s.binary == "" {
+		s.binary = defaultWhisperCppBinary
+	}
+	if s.language == "" {
+		s.language = "auto"
+	}
+	return s
+}
+
+func (s *whisperCppASR) Name() string { return asrProviderWhisperCpp }
+
+// Probe 检查 whisper.cpp 可执行文件与模型文件是否存在
+func (s *whisperCppASR) Probe(ctx context.Context) error {
+	if _, err := exec.LookPath(s.binary); err != nil {
+		return fmt.Errorf("%s not found in PATH", s.binary)
+	}
+	if s.model == "" {
+		return fmt.Errorf("未配置 whisper.cpp 模型文件")
+	}
+	if _, err := os.Stat(s.model); err != nil {
+		return fmt.Errorf("whisper.cpp 模型文件不可用: %w", err)
+	}
+	return nil
+}
+
+// Transcribe 先用 ffmpeg 转换为 whisper.cpp 要求的 16kHz 单声道 WAV，再输出 JSON 格式的识别结果
+func (s *whisperCppASR) Transcribe(ctx context.Context, audioPath string) (*services.ASRResult, error) {
+	if err := s.Probe(ctx); err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "whisper-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	wavPath := filepath.Join(tmpDir, "audio.wav")
+	convert := exec.CommandContext(ctx, "ffmpeg", "-i", audioPath, "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", "-y", wavPath)
+	if output, err := convert.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("转换音频失败: %v, 输出: %s", err, lastLine(string(output)))
+	}
+
+	outputBase := filepath.Join(tmpDir, "result")
+	args := []string{"-m", s.model, "-f", wavPath, "-l", s.language, "-oj", "-of", outputBase, "-np"}
+	if s.threads > 0 {
+		args = append(args, "-t", strconv.Itoa(s.threads))
+	}
+	reportJobProgressCtx(ctx, JobStageASR, 0, "whisper.cpp 识别中")
+	cmd := exec.CommandContext(ctx, s.binary, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("whisper.cpp 执行失败: %v, 输出: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	data, err := os.ReadFile(outputBase + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("读取 whisper.cpp 识别结果失败: %w", err)
+	}
+	var result struct {
+		Transcription []struct {
+			Offsets struct {
+				From int64 `json:"from"`
+				To   int64 `json:"to"`
+			} `json:"offsets"`
+			Text string `json:"text"`
+		} `json:"transcription"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("解析 whisper.cpp 识别结果失败: %w", err)
+	}
+
+	segments := make([]services.ASRSegment, 0, len(result.Transcription))
+	for _, seg := range result.Transcription {
+		if text := strings.TrimSpace(seg.Text); text != "" {
+			segments = append(segments, services.ASRSegment{
+				Text:      text,
+				StartTime: seg.Offsets.From,
+				EndTime:   seg.Offsets.To,
+			})
+		}
+	}
+	return &services.ASRResult{Segments: segments, RawData: json.RawMessage(data)}, nil
+}
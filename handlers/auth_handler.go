@@ -44,6 +44,9 @@ type userPreferences struct {
 	Language           string `json:"language"`
 	Timezone           string `json:"timezone"`
 	EmailNotifications bool   `json:"emailNotifications"`
+	ClipQuality        string `json:"clipQuality"`     // 订阅主播的热点片段画质
+	SummaryLanguage    string `json:"summaryLanguage"` // AI总结使用的语言
+	DigestFrequency    string `json:"digestFrequency"` // 摘要邮件频率: hourly / daily / weekly / never
 }
 
 type userModel struct {
@@ -55,12 +58,21 @@ type userModel struct {
 	Preferences  userPreferences `json:"preferences"`
 }
 
-// RegisterAuthRoutes registers authentication-related endpoints under /api/auth
-func RegisterAuthRoutes(r *gin.Engine) {
-	g := r.Group("/api/auth")
+// RegisterAuthRoutes registers authentication-related endpoints under {api}/auth
+func RegisterAuthRoutes(r gin.IRouter) {
+	g := r.Group("/auth")
 	g.POST("/send-code", sendCodeHandler)
 	g.POST("/verify", verifyHandler)
 	g.POST("/logout", logoutHandler)
+	g.POST("/refresh", refreshSessionHandler)
+	g.GET("/magic-link", magicLinkConfirmHandler)
+	g.POST("/magic-link", magicLinkLoginHandler)
+
+	// 关联 Twitch 账号（用于认领主播主页时确认身份）
+	g.GET("/twitch/link", twitchLinkStartHandler)
+	g.GET("/twitch/callback", twitchLinkCallbackHandler)
+	g.GET("/twitch/link/status", twitchLinkStatusHandler)
+	g.DELETE("/twitch/link", twitchUnlinkHandler)
 }
 
 func sendCodeHandler(c *gin.Context) {
@@ -96,36 +108,16 @@ func sendCodeHandler(c *gin.Context) {
 	}
 
 	// Try to send email if SMTP configured (use injected smtpCfg)
-	smtpHost := smtpCfg.Host
-	if smtpHost != "" {
-		smtpPort := smtpCfg.Port
-		if smtpPort == "" {
-			smtpPort = "25"
-		}
-		smtpUser := smtpCfg.User
-		smtpPass := smtpCfg.Pass
-		from := smtpCfg.From
-		if from == "" {
-			if smtpUser != "" {
-				from = smtpUser
-			} else {
-				from = "no-reply@localhost"
-			}
-		}
-
+	if GetSMTPConfig().Host != "" && IsFeatureEnabled(FeatureEmail) {
 		subject := "您的登录验证码"
 		body := fmt.Sprintf("您的验证码为：%s（有效期 10 分钟）", code)
-
-		msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s", from, email, subject, body)
-
-		addr := smtpHost + ":" + smtpPort
-		auth := smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
-
-		// if send fails, log and write to emails-errors.log
-		if err := SendMailWithTLS(addr, auth, from, []string{email}, []byte(msg)); err != nil {
-			log.Printf("smtp send failed: %v", err)
-			_ = appendErrorLog("emails-errors.log", fmt.Sprintf("%s\tSMTP_ERROR\t%s\tTo:%s\tErr:%v\n", time.Now().UTC().Format(time.RFC3339Nano), addr, email, err))
-		} else {
+		if link, err := newMagicLink(email); err != nil {
+			log.Printf("生成登录链接失败: %v", err)
+		} else if link != "" {
+			body += fmt.Sprintf("\n\n也可以直接点击以下链接登录（%d 分钟内有效，仅可使用一次）：\n%s",
+				GetAuthConfig().magicLinkTTL()/time.Minute, link)
+		}
+		if err := sendMail(email, subject, body); err == nil {
 			log.Printf("sent email to %s", email)
 		}
 	} else {
@@ -135,6 +127,43 @@ func sendCodeHandler(c *gin.Context) {
 	c.JSON(200, gin.H{"success": true, "message": "验证码已发送（如果未收到请检查垃圾邮件或联系管理员）。"})
 }
 
+// sendMail sends a plain-text email through the configured SMTP server.
+// Failures are logged and appended to emails-errors.log.
+func sendMail(to, subject, body string) error {
+	smtpCfg := GetSMTPConfig()
+	smtpHost := smtpCfg.Host
+	if smtpHost == "" {
+		return fmt.Errorf("SMTP not configured")
+	}
+	smtpPort := smtpCfg.Port
+	if smtpPort == "" {
+		smtpPort = "25"
+	}
+	smtpUser := smtpCfg.User
+	smtpPass := smtpCfg.Pass
+	from := smtpCfg.From
+	if from == "" {
+		if smtpUser != "" {
+			from = smtpUser
+		} else {
+			from = "no-reply@localhost"
+		}
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s", from, to, subject, body)
+
+	addr := smtpHost + ":" + smtpPort
+	auth := smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
+
+	// if send fails, log and write to emails-errors.log
+	if err := SendMailWithTLS(addr, auth, from, []string{to}, []byte(msg)); err != nil {
+		log.Printf("smtp send failed: %v", err)
+		_ = appendErrorLog("emails-errors.log", fmt.Sprintf("%s\tSMTP_ERROR\t%s\tTo:%s\tErr:%v\n", time.Now().UTC().Format(time.RFC3339Nano), addr, to, err))
+		return err
+	}
+	return nil
+}
+
 // Dial return a smtp client
 func Dial(addr string) (*smtp.Client, error) {
 	conn, err := tls.Dial("tcp", addr, nil)
@@ -215,6 +244,17 @@ func verifyHandler(c *gin.Context) {
 		return
 	}
 
+	user, token := loginUser(c, email)
+
+	// remove cached code
+	codeCache.Delete(key)
+
+	// 返回用户信息与会话令牌，令牌已写入 Cookie（不进行跳转，前端负责处理）
+	c.JSON(200, gin.H{"success": true, "message": "登录成功", "user": user, "token": token})
+}
+
+// loginUser 创建或更新用户资料，签发会话令牌写入 Cookie 并通知数据层
+func loginUser(c *gin.Context, email string) (userModel, string) {
 	safe := computeSha256Hex(strings.ToLower(email))
 	baseDir := filepath.Join("App_Data")
 	userDir := filepath.Join(baseDir, safe)
@@ -233,7 +273,7 @@ func verifyHandler(c *gin.Context) {
 			DisplayName:  displayName,
 			RegisteredAt: now,
 			LastLoginAt:  now,
-			Preferences:  userPreferences{Language: "zh-CN", Timezone: "Asia/Shanghai", EmailNotifications: true},
+			Preferences:  userPreferences{EmailNotifications: true}.withDefaults(),
 		}
 		// save
 		if b, err := json.MarshalIndent(user, "", "  "); err == nil {
@@ -260,28 +300,20 @@ func verifyHandler(c *gin.Context) {
 	// write email.txt for compatibility
 	_ = os.WriteFile(filepath.Join(userDir, "email.txt"), []byte(email), 0o644)
 
-	// set cookie with user info (JSON)
-	if b, err := json.Marshal(user); err == nil {
-		// maxAge in seconds; set long expiration (10 years)
-		maxAge := 10 * 365 * 24 * 60 * 60
-		c.SetCookie("UserInfo", string(b), maxAge, "/", "", true, true)
+	// 签发会话令牌（JWT）写入 Cookie
+	token, _, err := setSessionCookie(c, user)
+	if err != nil {
+		log.Printf("failed to issue session token: %v", err)
 	}
 
-	// remove cached code
-	codeCache.Delete(key)
-
-	// 返回用户信息并已写入 Cookie（不进行跳转，前端负责处理）
-	c.JSON(200, gin.H{"success": true, "message": "登录成功", "user": user})
-
 	// asynchronously notify data layer to create user via gRPC
 	sendCreateUserToRPC(user)
+	return user, token
 }
 
-// logoutHandler 处理用户注销，清除 UserInfo cookie
+// logoutHandler 处理用户注销，清除会话令牌与旧的 UserInfo cookie
 func logoutHandler(c *gin.Context) {
-	// 清除 UserInfo cookie
-	// MaxAge=-1 表示立即删除 cookie
-	c.SetCookie("UserInfo", "", -1, "/", "", true, true)
+	clearSessionCookies(c)
 
 	log.Printf("用户已注销登录")
 
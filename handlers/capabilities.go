@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"subtuber-services/services"
+)
+
+// 热点片段流水线依赖的外部组件，不可用时流水线降级：跳过依赖它的阶段，依赖恢复后自动补做
+const (
+	capabilityFFmpeg = "ffmpeg" // 片段下载与音频提取
+	capabilityASR    = "asr"    // 语音识别（配置的提供商中任一可用即可）
+	capabilityAI     = "ai"     // AI总结（配置的提供商中任一可用即可）
+)
+
+const (
+	// capabilityCheckInterval 依赖状态的缓存时长，超过后下次使用时重新检测
+	capabilityCheckInterval = time.Minute
+	// capabilityProbeTimeout 单次检测的超时时间
+	capabilityProbeTimeout = 10 * time.Second
+)
+
+// capabilityProbes 各依赖的检测方法，返回 nil 表示可用
+var capabilityProbes = map[string]func(ctx context.Context) error{
+	capabilityFFmpeg: probeFFmpeg,
+	capabilityASR:    probeASR,
+	capabilityAI:     probeAI,
+}
+
+// CapabilityStatus 依赖最近一次检测的结果
+type CapabilityStatus struct {
+	Name      string    `json:"name"`
+	Available bool      `json:"available"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+var (
+	capabilitiesMu sync.Mutex
+	capabilities   = map[string]*CapabilityStatus{}
+)
+
+// probeFFmpeg 检查 ffmpeg 是否在 PATH 中且可以执行
+func probeFFmpeg(ctx context.Context) error {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("ffmpeg not found in PATH")
+	}
+	if err := exec.CommandContext(ctx, "ffmpeg", "-version").Run(); err != nil {
+		return fmt.Errorf("ffmpeg 无法执行: %w", err)
+	}
+	return nil
+}
+
+// probeASR 检查配置的语音识别提供商中是否有可用的
+func probeASR(ctx context.Context) error {
+	return NewConfiguredASRService().Probe(ctx)
+}
+
+// probeAI 检查配置的AI提供商中是否有可用的
+func probeAI(ctx context.Context) error {
+	return NewConfiguredAIService().Probe(ctx)
+}
+
+// probeBcutASR 检查必剪接口是否可以访问，网络错误或 5xx 视为不可用
+func probeBcutASR(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, services.APIBaseURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("必剪接口无法访问: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("必剪接口返回 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkCapability 立即检测依赖并更新缓存，状态变化时记录日志
+func checkCapability(name string) CapabilityStatus {
+	probe, ok := capabilityProbes[name]
+	if !ok {
+		return CapabilityStatus{Name: name, Available: true, CheckedAt: time.Now()}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), capabilityProbeTimeout)
+	defer cancel()
+	err := probe(ctx)
+
+	status := CapabilityStatus{Name: name, Available: err == nil, CheckedAt: time.Now()}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	capabilitiesMu.Lock()
+	prev, existed := capabilities[name]
+	capabilities[name] = &status
+	capabilitiesMu.Unlock()
+
+	switch {
+	case !status.Available && (!existed || prev.Available):
+		log.Printf("⚠️ 依赖 %s 不可用，热点片段流水线降级（跳过依赖它的阶段，恢复后自动补做）: %s", name, status.Error)
+	case status.Available && existed && !prev.Available:
+		log.Printf("✅ 依赖 %s 已恢复，延后队列中等待它的任务将自动执行", name)
+	}
+	return status
+}
+
+// capabilityAvailable 依赖当前是否可用，缓存超过 capabilityCheckInterval 时重新检测
+func capabilityAvailable(name string) bool {
+	capabilitiesMu.Lock()
+	status, ok := capabilities[name]
+	capabilitiesMu.Unlock()
+	if ok && time.Since(status.CheckedAt) < capabilityCheckInterval {
+		return status.Available
+	}
+	return checkCapability(name).Available
+}
+
+// capabilityStatuses 所有依赖最近一次检测的结果，未检测过的依赖会立即检测
+func capabilityStatuses() []CapabilityStatus {
+	statuses := make([]CapabilityStatus, 0, len(capabilityProbes))
+	for _, name := range sortedKeys(capabilityProbes) {
+		capabilityAvailable(name)
+		capabilitiesMu.Lock()
+		statuses = append(statuses, *capabilities[name])
+		capabilitiesMu.Unlock()
+	}
+	return statuses
+}
+
+// dependencyUnavailableError 因依赖不可用而跳过时记录的原因
+func dependencyUnavailableError(name string) string {
+	return fmt.Sprintf("%s: %s", clipRunDependencyUnavailable, name)
+}
+
+// deferUntilAvailable 将跳过的热点放入延后队列，依赖恢复后由延后任务自动重新处理
+func deferUntilAvailable(videoID string, hotMoments []VodCommentData, interval float64, dependency string) {
+	if len(hotMoments) == 0 {
+		return
+	}
+	job, err := enqueueDeferredJob(DeferredJob{
+		Kind:       deferredJobHotClips,
+		VideoID:    videoID,
+		HotMoments: hotMoments,
+		Interval:   interval,
+		WaitFor:    dependency,
+	})
+	if err != nil {
+		log.Printf("加入延后队列失败，依赖 %s 恢复后需手动重试视频 %s 的热点片段: %v", dependency, videoID, err)
+		return
+	}
+	log.Printf("视频 %s 的 %d 个热点因 %s 不可用已跳过，恢复后自动处理 (任务ID: %s)", videoID, len(hotMoments), dependency, job.ID)
+}
+
+// writeCapabilityPrometheus 以 Prometheus 文本格式输出依赖可用状态
+func writeCapabilityPrometheus(b *strings.Builder) {
+	name := "subtuber_dependency_available"
+	fmt.Fprintf(b, "# HELP %s Whether an external pipeline dependency (ffmpeg, ASR, AI) was available at the last check.\n", name)
+	fmt.Fprintf(b, "# TYPE %s gauge\n", name)
+	for _, status := range capabilityStatuses() {
+		value := 0
+		if status.Available {
+			value = 1
+		}
+		fmt.Fprintf(b, "%s{dependency=%q} %d\n", name, status.Name, value)
+	}
+}
@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"subtuber-services/chatdownload"
+	"subtuber-services/models"
+	"subtuber-services/pathsafe"
+)
+
+const (
+	// chapterWindowSeconds 统计弹幕关键词分布的窗口长度（秒）
+	chapterWindowSeconds = 300
+	// chapterContextWindows 比较分界点两侧各多少个窗口
+	chapterContextWindows = 3
+	// chapterMinWindows 章节的最短长度（窗口数），即 15 分钟
+	chapterMinWindows = 3
+	// chapterMaxCount 每个录像最多划分的章节数
+	chapterMaxCount = 20
+	// chapterDensityWeight 弹幕密度变化在分界得分中的权重，其余来自话题变化
+	chapterDensityWeight = 0.5
+	// chapterMinTokenCount 关键词在整个录像中至少出现的次数，过滤偶发的词
+	chapterMinTokenCount = 3
+	// chapterKeywordCount 章节标题使用的关键词数
+	chapterKeywordCount = 3
+	// chapterQuietRatio 弹幕速度低于中位数的该比例时标记为冷场章节
+	chapterQuietRatio = 0.2
+
+	chaptersFileName = "chapters.json"
+)
+
+// Chapter 根据弹幕密度与话题变化划分的录像章节
+type Chapter struct {
+	StartSeconds      float64  `json:"start_seconds"`
+	EndSeconds        float64  `json:"end_seconds"`
+	FormattedStart    string   `json:"formatted_start"`
+	Title             string   `json:"title"`
+	Keywords          []string `json:"keywords"`
+	Comments          int      `json:"comments"`
+	CommentsPerMinute float64  `json:"comments_per_minute"`
+}
+
+// chatLine 带文本的弹幕，用于话题分析
+type chatLine struct {
+	offset float64
+	text   string
+}
+
+// loadChatLinesForVideo 读取录像聊天记录中的弹幕时间与文本
+func loadChatLinesForVideo(videoID string) ([]chatLine, error) {
+	var lines []chatLine
+	if err := eachChatMessage(videoID, func(msg models.ChatMessage) {
+		lines = append(lines, chatLine{offset: msg.OffsetSeconds, text: msg.Text})
+	}); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// chapterTokens 提取一条弹幕中的关键词（去重）
+// 字母数字按词切分并转为小写，中日文没有分隔符，按相邻两字切分
+func chapterTokens(text string) []string {
+	seen := map[string]bool{}
+	tokens := []string{}
+	add := func(token string) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	var word []rune
+	var cjk []rune
+	flushWord := func() {
+		if len(word) >= 2 && !isAllDigits(word) {
+			add(string(word))
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		for i := 0; i+1 < len(cjk); i++ {
+			add(string(cjk[i : i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+func isAllDigits(rs []rune) bool {
+	for _, r := range rs {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// chapterWindow 一个统计窗口内的关键词频次与弹幕数
+type chapterWindow struct {
+	tokens   map[string]float64
+	comments int
+}
+
+// detectChapters 将弹幕按固定窗口统计关键词分布，在话题与弹幕密度同时变化明显的位置划分章节
+// 每个候选分界点比较两侧各 chapterContextWindows 个窗口：话题变化为 TF-IDF 向量的余弦距离，
+// 密度变化为弹幕速度对数差；得分高于平均值加半个标准差的分界点按得分从高到低选取，章节不短于 chapterMinWindows 个窗口
+func detectChapters(lines []chatLine) []Chapter {
+	chapters := []Chapter{}
+	if len(lines) == 0 {
+		return chapters
+	}
+
+	duration := 0.0
+	for _, line := range lines {
+		if line.offset > duration {
+			duration = line.offset
+		}
+	}
+	n := int(duration/chapterWindowSeconds) + 1
+
+	windows := make([]chapterWindow, n)
+	for i := range windows {
+		windows[i].tokens = map[string]float64{}
+	}
+	totals := map[string]int{}
+	for _, line := range lines {
+		if line.offset < 0 {
+			continue
+		}
+		w := &windows[int(line.offset/chapterWindowSeconds)]
+		w.comments++
+		for _, token := range chapterTokens(line.text) {
+			w.tokens[token]++
+			totals[token]++
+		}
+	}
+
+	// 出现在每个窗口的词（常用表情、口头禅）不区分话题，IDF 为 0
+	df := map[string]int{}
+	for _, w := range windows {
+		for token := range w.tokens {
+			if totals[token] >= chapterMinTokenCount {
+				df[token]++
+			}
+		}
+	}
+	idf := make(map[string]float64, len(df))
+	for token, count := range df {
+		idf[token] = math.Log(float64(n) / float64(count))
+	}
+
+	boundaries := []int{}
+	if n >= 2*chapterMinWindows {
+		boundaries = selectChapterBoundaries(windows, idf)
+	}
+
+	starts := append([]int{0}, boundaries...)
+	ranges := make([][2]int, len(starts))
+	for i, start := range starts {
+		end := n
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		ranges[i] = [2]int{start, end}
+	}
+
+	// 章节关键词：章节内频次乘以章节间的 IDF，突出该章节特有的词
+	chapterTokenCounts := make([]map[string]float64, len(ranges))
+	chapterDF := map[string]int{}
+	for i, r := range ranges {
+		chapterTokenCounts[i] = sumWindowTokens(windows, r[0], r[1], nil)
+		for token := range chapterTokenCounts[i] {
+			if idf[token] > 0 {
+				chapterDF[token]++
+			}
+		}
+	}
+
+	rates := make([]float64, len(ranges))
+	for i, r := range ranges {
+		comments := 0
+		for _, w := range windows[r[0]:r[1]] {
+			comments += w.comments
+		}
+		end := math.Min(float64(r[1]*chapterWindowSeconds), math.Ceil(duration))
+		start := float64(r[0] * chapterWindowSeconds)
+		minutes := math.Max((end-start)/60, 1)
+		rates[i] = float64(comments) / minutes
+
+		keywords := topChapterKeywords(chapterTokenCounts[i], func(token string) float64 {
+			if idf[token] == 0 {
+				return 0
+			}
+			return idf[token] * math.Log(float64(len(ranges)+1)/float64(chapterDF[token]))
+		})
+		chapters = append(chapters, Chapter{
+			StartSeconds:      start,
+			EndSeconds:        end,
+			FormattedStart:    formatDuration(start),
+			Keywords:          keywords,
+			Comments:          comments,
+			CommentsPerMinute: math.Round(rates[i]*10) / 10,
+		})
+	}
+
+	sortedRates := append([]float64(nil), rates...)
+	sort.Float64s(sortedRates)
+	medianRate := sortedRates[len(sortedRates)/2]
+	for i := range chapters {
+		chapters[i].Title = chapterTitle(i, chapters[i], rates[i] < medianRate*chapterQuietRatio)
+	}
+	return chapters
+}
+
+// selectChapterBoundaries 计算每个窗口边界的分界得分并选出章节起点（窗口序号，升序）
+func selectChapterBoundaries(windows []chapterWindow, idf map[string]float64) []int {
+	n := len(windows)
+	topic := make([]float64, n)
+	density := make([]float64, n)
+	maxDensity := 0.0
+	for b := chapterMinWindows; b <= n-chapterMinWindows; b++ {
+		leftStart := b - chapterContextWindows
+		if leftStart < 0 {
+			leftStart = 0
+		}
+		rightEnd := b + chapterContextWindows
+		if rightEnd > n {
+			rightEnd = n
+		}
+
+		left := sumWindowTokens(windows, leftStart, b, idf)
+		right := sumWindowTokens(windows, b, rightEnd, idf)
+		topic[b] = 1 - cosineSimilarity(left, right)
+
+		leftRate := windowCommentRate(windows, leftStart, b)
+		rightRate := windowCommentRate(windows, b, rightEnd)
+		density[b] = math.Abs(math.Log1p(leftRate) - math.Log1p(rightRate))
+		if density[b] > maxDensity {
+			maxDensity = density[b]
+		}
+	}
+
+	type candidate struct {
+		index int
+		score float64
+	}
+	candidates := []candidate{}
+	sum, sumSq := 0.0, 0.0
+	for b := chapterMinWindows; b <= n-chapterMinWindows; b++ {
+		score := topic[b]
+		if maxDensity > 0 {
+			score += chapterDensityWeight * density[b] / maxDensity
+		}
+		candidates = append(candidates, candidate{index: b, score: score})
+		sum += score
+		sumSq += score * score
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	mean := sum / float64(len(candidates))
+	std := math.Sqrt(math.Max(sumSq/float64(len(candidates))-mean*mean, 0))
+	threshold := mean + 0.5*std
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].index < candidates[j].index
+	})
+
+	selected := []int{}
+	for _, c := range candidates {
+		if c.score <= threshold || len(selected) >= chapterMaxCount-1 {
+			break
+		}
+		tooClose := false
+		for _, s := range selected {
+			if c.index-s < chapterMinWindows && s-c.index < chapterMinWindows {
+				tooClose = true
+				break
+			}
+		}
+		if !tooClose {
+			selected = append(selected, c.index)
+		}
+	}
+	sort.Ints(selected)
+	return selected
+}
+
+// sumWindowTokens 汇总 [start, end) 窗口内的关键词频次，weights 不为空时按权重加权并丢弃权重为 0 的词
+func sumWindowTokens(windows []chapterWindow, start, end int, weights map[string]float64) map[string]float64 {
+	sum := map[string]float64{}
+	for _, w := range windows[start:end] {
+		for token, count := range w.tokens {
+			if weights == nil {
+				sum[token] += count
+				continue
+			}
+			if weight := weights[token]; weight > 0 {
+				sum[token] += count * weight
+			}
+		}
+	}
+	return sum
+}
+
+// windowCommentRate [start, end) 窗口内平均每个窗口的弹幕数
+func windowCommentRate(windows []chapterWindow, start, end int) float64 {
+	if end <= start {
+		return 0
+	}
+	comments := 0
+	for _, w := range windows[start:end] {
+		comments += w.comments
+	}
+	return float64(comments) / float64(end-start)
+}
+
+func cosineSimilarity(a, b map[string]float64) float64 {
+	dot, normA, normB := 0.0, 0.0, 0.0
+	for token, v := range a {
+		normA += v * v
+		dot += v * b[token]
+	}
+	for _, v := range b {
+		normB += v * v
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// topChapterKeywords 按得分取章节的前几个关键词，被已选中的词包含的词不重复选取
+func topChapterKeywords(counts map[string]float64, weight func(string) float64) []string {
+	type scored struct {
+		token string
+		score float64
+	}
+	items := make([]scored, 0, len(counts))
+	for token, count := range counts {
+		if s := count * weight(token); s > 0 {
+			items = append(items, scored{token: token, score: s})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].score != items[j].score {
+			return items[i].score > items[j].score
+		}
+		return items[i].token < items[j].token
+	})
+
+	keywords := []string{}
+	for _, item := range items {
+		if len(keywords) >= chapterKeywordCount {
+			break
+		}
+		duplicate := false
+		for _, k := range keywords {
+			if strings.Contains(k, item.token) || strings.Contains(item.token, k) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			keywords = append(keywords, item.token)
+		}
+	}
+	return keywords
+}
+
+// chapterTitle 章节标题：冷场章节标记为冷场，否则使用关键词；第一个章节没有特征词时为开场
+func chapterTitle(index int, chapter Chapter, quiet bool) string {
+	switch {
+	case quiet:
+		return "冷场"
+	case len(chapter.Keywords) > 0:
+		return strings.Join(chapter.Keywords, " / ")
+	case index == 0:
+		return "开场"
+	default:
+		return fmt.Sprintf("第 %d 段", index+1)
+	}
+}
+
+// chaptersCache 录像章节的缓存文件，章节与峰值检测参数无关，聊天记录未变化时复用
+type chaptersCache struct {
+	ChatLogModTime int64     `json:"chat_log_mod_time"`
+	Chapters       []Chapter `json:"chapters"`
+}
+
+// chaptersForVideo 返回录像的章节，优先读取 analysis_results/{videoID}/chapters.json，
+// 不存在或聊天记录已更新时重新计算并保存
+func chaptersForVideo(videoID string) ([]Chapter, error) {
+	logPath := chatdownload.FindTwitchLog(videoID)
+	if logPath == "" {
+		logPath = chatdownload.FindYouTubeLog(videoID)
+	}
+	if logPath == "" {
+		return nil, os.ErrNotExist
+	}
+	info, err := os.Stat(logPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath := filepath.Join(pathsafe.Join("./analysis_results", videoID), chaptersFileName)
+	if data, err := os.ReadFile(cachePath); err == nil {
+		var cache chaptersCache
+		if json.Unmarshal(data, &cache) == nil && cache.ChatLogModTime == info.ModTime().Unix() && cache.Chapters != nil {
+			return cache.Chapters, nil
+		}
+	}
+
+	lines, err := loadChatLinesForVideo(videoID)
+	if err != nil {
+		return nil, err
+	}
+	chapters := detectChapters(lines)
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return chapters, nil
+	}
+	data, err := json.MarshalIndent(chaptersCache{ChatLogModTime: info.ModTime().Unix(), Chapters: chapters}, "", "  ")
+	if err == nil {
+		err = os.WriteFile(cachePath, data, 0644)
+	}
+	if err != nil {
+		log.Printf("保存录像 %s 的章节失败: %v", videoID, err)
+	}
+	return chapters, nil
+}
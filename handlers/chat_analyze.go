@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"subtuber-services/models"
@@ -21,6 +23,19 @@ type VodCommentData struct {
 	CommentsScore float64 `json:"comments_score"`
 	OffsetSeconds float64 `json:"offset_seconds"`
 	FormattedTime string  `json:"formatted_time,omitempty"` // 格式化的时间显示
+	Scale         int     `json:"scale,omitempty"`          // 多尺度检测时峰值的主导尺度（窗口长度，秒）
+	// Emotions 热点窗口内弹幕的情绪分布，保存分析结果时根据聊天记录统计
+	Emotions *EmotionBreakdown `json:"emotions,omitempty"`
+	// TopEmotes 热点窗口内最常用的表情
+	TopEmotes []EmoteCount `json:"top_emotes,omitempty"`
+	// UniqueChatters / MessagesPerChatter 热点窗口内发言的不同观众数与人均弹幕数，人均弹幕数高说明是少数观众刷屏
+	UniqueChatters     int     `json:"unique_chatters,omitempty"`
+	MessagesPerChatter float64 `json:"messages_per_chatter,omitempty"`
+	// Metadata 热点总结生成后由AI生成的片段标题、话题标签与简介，可直接用于发布片段
+	Metadata *ClipMetadata `json:"metadata,omitempty"`
+	// ThumbnailURL / PreviewURL 片段的封面帧与动态预览地址，查询分析结果时根据片段同步信息填充，不保存在结果文件中
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	PreviewURL   string `json:"preview_url,omitempty"`
 }
 
 // TimeSeriesDataPoint 时间序列数据点
@@ -29,6 +44,13 @@ type TimeSeriesDataPoint struct {
 	FormattedTime string  `json:"formatted_time"`
 	Score         float64 `json:"score"`
 	IsPeak        bool    `json:"is_peak"` // 是否为峰值点
+	// 与评论密度相同窗口内各情绪的弹幕数
+	Hype     float64 `json:"hype,omitempty"`
+	Laughter float64 `json:"laughter,omitempty"`
+	Sadness  float64 `json:"sadness,omitempty"`
+	// 与评论密度相同窗口内发言的不同观众数与人均弹幕数
+	UniqueChatters     int     `json:"unique_chatters,omitempty"`
+	MessagesPerChatter float64 `json:"messages_per_chatter,omitempty"`
 }
 
 // AnalysisResultWithTimeSeries 包含时间序列的完整分析结果
@@ -36,6 +58,7 @@ type AnalysisResultWithTimeSeries struct {
 	HotMoments     []VodCommentData      `json:"hot_moments"`
 	TimeSeriesData []TimeSeriesDataPoint `json:"time_series_data"`
 	Stats          VodCommentStats       `json:"stats"`
+	SkipRanges     []SkipRange           `json:"skip_ranges"` // 持续冷场、可跳过的片段
 }
 
 // VodCommentStats 评论统计信息
@@ -43,15 +66,26 @@ type VodCommentStats struct {
 	Mean  float64 `json:"mean"`
 	Sigma float64 `json:"sigma"`
 	Count int     `json:"count"`
-	sum   float64
-	sumSq float64
+	// 以下根据聊天记录统计，范围与时间序列一致
+	Messages           int     `json:"messages,omitempty"`
+	UniqueChatters     int     `json:"unique_chatters,omitempty"`
+	MessagesPerChatter float64 `json:"messages_per_chatter,omitempty"`
+	PeakVelocity       float64 `json:"peak_velocity,omitempty"`        // 峰值弹幕速度：任意 60 秒内的最大弹幕数
+	PeakVelocityOffset float64 `json:"peak_velocity_offset,omitempty"` // 峰值弹幕速度所在 60 秒窗口的开始时间（秒）
+	sum                float64
+	sumSq              float64
 }
 
 // PeakDetectionParams 峰值检测参数
 type PeakDetectionParams struct {
-	WindowsLen  int     // 滑动窗口长度（秒），用于计算评论密度，默认120
-	Thr         float64 // 阈值百分位（0-1），只考虑超过该百分位的密度值，默认0.9
-	SearchRange int     // 搜索范围（秒），在此范围内查找局部最大值，默认60
+	WindowsLen    int     `json:"windows_len"`               // 滑动窗口长度（秒），用于计算评论密度，默认120
+	Thr           float64 `json:"thr"`                       // 阈值百分位（0-1），只考虑超过该百分位的密度值，默认0.9
+	SearchRange   int     `json:"search_range"`              // 搜索范围（秒），在此范围内查找局部最大值，默认60
+	Method        string  `json:"method,omitempty"`          // 峰值检测算法，默认 convolution
+	ZThreshold    float64 `json:"z_threshold,omitempty"`     // zscore：高于滚动均值的标准差倍数，默认3
+	BaselineLen   int     `json:"baseline_len,omitempty"`    // zscore：滚动均值与标准差的窗口长度（秒），默认1800
+	MinWindowsLen int     `json:"min_windows_len,omitempty"` // multiscale：最小窗口长度（秒），默认15，最大窗口为 windows_len
+	Scales        int     `json:"scales,omitempty"`          // multiscale：尺度数量，默认4
 }
 
 // AddData 添加数据点
@@ -66,41 +100,24 @@ func (s *VodCommentStats) AddData(data VodCommentData) {
 	}
 }
 
-// TwitchVideoData Twitch视频数据
-func FindHotCommentsWithParamsTwitch(comments []models.TwitchChatComment, secondsDt int,
+// FindHotCommentsWithParams 与平台无关的峰值检测入口，各平台的聊天记录先转换为 models.ChatMessage
+func FindHotCommentsWithParams(messages []models.ChatMessage, secondsDt int,
 	params PeakDetectionParams) AnalysisResultWithTimeSeries {
-	var commentsOffsetSeconds []float64
-	for _, comment := range comments {
-		commentsOffsetSeconds = append(commentsOffsetSeconds, comment.ContentOffsetSeconds)
-	}
-	return findHotCommentsWithParams(commentsOffsetSeconds, secondsDt, params)
+	return findHotCommentsWithParams(chatMessageOffsets(messages), secondsDt, params)
 }
 
-// TwitchVideoData Youtube视频数据
-func FindHotCommentsWithParamsYoutube(comments []models.YoutubeChatLog, secondsDt int,
-	params PeakDetectionParams) AnalysisResultWithTimeSeries {
-	var commentsOffsetSeconds []float64
-	for _, comment := range comments {
-		commentsOffsetSeconds = append(commentsOffsetSeconds, comment.OffsetSeconds)
-	}
-	return findHotCommentsWithParams(commentsOffsetSeconds, secondsDt, params)
-}
-
-// FindHotCommentsWithParams 使用自定义参数的峰值检测
+// findHotCommentsWithParams 按评论时间偏移进行峰值检测，逐条读取聊天记录的调用方只需收集时间偏移
 func findHotCommentsWithParams(commentsOffsetSeconds []float64, secondsDt int,
 	params PeakDetectionParams) AnalysisResultWithTimeSeries {
-	if len(commentsOffsetSeconds) == 0 {
-		return AnalysisResultWithTimeSeries{
-			HotMoments:     []VodCommentData{},
-			TimeSeriesData: []TimeSeriesDataPoint{},
-		}
-	}
-
 	if secondsDt <= 0 {
 		secondsDt = 5 // 默认5秒间隔
 	}
 
-	// 设置默认参数
+	return NewAnalysisSession(commentsOffsetSeconds).Analyze(params)
+}
+
+// normalizePeakParams 为未设置或非法的峰值检测参数填充默认值
+func normalizePeakParams(params PeakDetectionParams) PeakDetectionParams {
 	if params.WindowsLen <= 0 {
 		params.WindowsLen = 120
 	}
@@ -110,20 +127,45 @@ func findHotCommentsWithParams(commentsOffsetSeconds []float64, secondsDt int,
 	if params.SearchRange <= 0 {
 		params.SearchRange = 60
 	}
+	if detector, ok := getPeakDetector(params.Method); ok {
+		params = detector.Normalize(params)
+	}
+	return params
+}
 
-	// 提取所有时间偏移并找到时间范围
-	var offsetSeconds = commentsOffsetSeconds
+// AnalysisSession 单个视频的分析会话
+// 每秒评论数只统计一次；评论密度及其排序结果按窗口长度缓存，
+// 滑动最大值按（窗口长度，搜索范围）缓存，多组参数分析时共享这些中间结果
+type AnalysisSession struct {
+	counts []float64 // 每秒的评论数
 
-	if len(offsetSeconds) == 0 {
-		return AnalysisResultWithTimeSeries{
-			HotMoments:     []VodCommentData{},
-			TimeSeriesData: []TimeSeriesDataPoint{},
-		}
+	mu        sync.Mutex
+	densities map[int]*sessionDensity // 窗口长度 -> 评论密度
+	maxima    map[[2]int][]float64    // (窗口长度, 搜索范围) -> 滑动最大值
+
+	skipOnce   sync.Once
+	skipRanges []SkipRange
+}
+
+// sessionDensity 某一窗口长度下的评论密度及其升序排列（用于百分位阈值）
+type sessionDensity struct {
+	density []float64
+	sorted  []float64
+}
+
+// NewAnalysisSession 根据评论时间偏移创建分析会话
+func NewAnalysisSession(commentsOffsetSeconds []float64) *AnalysisSession {
+	session := &AnalysisSession{
+		densities: make(map[int]*sessionDensity),
+		maxima:    make(map[[2]int][]float64),
+	}
+	if len(commentsOffsetSeconds) == 0 {
+		return session
 	}
 
 	// 找到最大时间偏移
 	maxOffset := 0.0
-	for _, offset := range offsetSeconds {
+	for _, offset := range commentsOffsetSeconds {
 		if offset > maxOffset {
 			maxOffset = offset
 		}
@@ -131,21 +173,79 @@ func findHotCommentsWithParams(commentsOffsetSeconds []float64, secondsDt int,
 
 	// 构建按秒计数的评论数组（从0到最大时间）
 	totalSeconds := int(math.Ceil(maxOffset)) + 1
-	commentCountPerSecond := make([]float64, totalSeconds)
+	session.counts = make([]float64, totalSeconds)
 
 	// 统计每秒的评论数
-	for _, offset := range offsetSeconds {
+	for _, offset := range commentsOffsetSeconds {
 		timeIndex := int(math.Floor(offset))
 		if timeIndex >= 0 && timeIndex < totalSeconds {
-			commentCountPerSecond[timeIndex]++
+			session.counts[timeIndex]++
+		}
+	}
+
+	return session
+}
+
+// densityFor 获取（或计算并缓存）指定窗口长度的评论密度
+func (s *AnalysisSession) densityFor(windowsLen int) *sessionDensity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.densities[windowsLen]; ok {
+		return d
+	}
+
+	density := boxSumSame(s.counts, windowsLen+1)
+	sorted := make([]float64, len(density))
+	copy(sorted, density)
+	sort.Float64s(sorted)
+
+	d := &sessionDensity{density: density, sorted: sorted}
+	s.densities[windowsLen] = d
+	return d
+}
+
+// maximaFor 获取（或计算并缓存）指定窗口长度与搜索范围的滑动最大值
+func (s *AnalysisSession) maximaFor(windowsLen, searchRange int, density []float64) []float64 {
+	key := [2]int{windowsLen, searchRange}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m, ok := s.maxima[key]; ok {
+		return m
+	}
+
+	m := slidingMax(density, searchRange)
+	s.maxima[key] = m
+	return m
+}
+
+// Analyze 使用给定参数及其指定的算法执行峰值检测，复用会话内已有的中间结果
+func (s *AnalysisSession) Analyze(params PeakDetectionParams) AnalysisResultWithTimeSeries {
+	if len(s.counts) == 0 {
+		return AnalysisResultWithTimeSeries{
+			HotMoments:     []VodCommentData{},
+			TimeSeriesData: []TimeSeriesDataPoint{},
+			SkipRanges:     []SkipRange{},
 		}
 	}
 
-	// 使用新算法检测峰值
-	isPeak, commentDensity := findPeakWithParams(commentCountPerSecond, params)
+	params = normalizePeakParams(params)
+
+	detection := s.detectPeaks(params)
+
+	result := buildAnalysisResult(detection, params)
+	result.SkipRanges = s.SkipRanges()
+	return result
+}
+
+// buildAnalysisResult 根据峰值检测得分和峰值标记构建时间序列、热点时刻与统计信息
+func buildAnalysisResult(detection PeakDetection, params PeakDetectionParams) AnalysisResultWithTimeSeries {
+	commentDensity, isPeak := detection.Score, detection.IsPeak
 
 	// 构建时间序列数据
-	var timeSeriesData []TimeSeriesDataPoint
+	timeSeriesData := make([]TimeSeriesDataPoint, 0, len(commentDensity))
 	for i := 0; i < len(commentDensity); i++ {
 		timeSeriesData = append(timeSeriesData, TimeSeriesDataPoint{
 			OffsetSeconds: float64(i),
@@ -159,12 +259,17 @@ func findHotCommentsWithParams(commentsOffsetSeconds []float64, secondsDt int,
 	var hotMoments []VodCommentData
 	for i := 0; i < len(isPeak); i++ {
 		if isPeak[i] {
-			hotMoments = append(hotMoments, VodCommentData{
+			hotMoment := VodCommentData{
 				TimeInterval:  fmt.Sprintf("%ds", params.WindowsLen),
 				CommentsScore: commentDensity[i],
 				OffsetSeconds: float64(i),
 				FormattedTime: formatDuration(float64(i)),
-			})
+			}
+			if detection.Scale != nil {
+				hotMoment.Scale = detection.Scale[i]
+				hotMoment.TimeInterval = fmt.Sprintf("%ds", detection.Scale[i])
+			}
+			hotMoments = append(hotMoments, hotMoment)
 		}
 	}
 
@@ -209,68 +314,77 @@ func findPeakWithParams(comment []float64, params PeakDetectionParams) ([]bool,
 		return []bool{}, []float64{}
 	}
 
-	// 创建卷积核（长度为windowsLen+1的全1数组）
-	kernel := make([]float64, params.WindowsLen+1)
-	for i := range kernel {
-		kernel[i] = 1.0
-	}
-
-	// 使用卷积计算评论密度（same模式）
-	commentDensity := convSame(comment, kernel)
+	// 卷积核为长度windowsLen+1的全1数组，等价于滑动窗口求和，
+	// 使用前缀和实现，复杂度从 O(n·k) 降为 O(n)
+	commentDensity := boxSumSame(comment, params.WindowsLen+1)
 
 	// 计算阈值密度（使用百分位）
 	sortedDensity := make([]float64, len(commentDensity))
 	copy(sortedDensity, commentDensity)
 	sort.Float64s(sortedDensity)
+	thrDensity := percentileFromSorted(sortedDensity, params.Thr)
 
-	thrIndex := int(math.Floor(float64(len(commentDensity)) * params.Thr))
-	if thrIndex >= len(sortedDensity) {
-		thrIndex = len(sortedDensity) - 1
-	}
-	thrDensity := sortedDensity[thrIndex]
+	// 在 [i-searchRange, i+searchRange] 范围内的最大值等于自身的点为峰值
+	windowMax := slidingMax(commentDensity, params.SearchRange)
 
-	// 对commentDensity进行padding，方便搜索
-	commentDenPad := make([]float64, len(commentDensity)+2*params.SearchRange)
-	// 前面填充searchRange个0
-	for i := 0; i < params.SearchRange; i++ {
-		commentDenPad[i] = 0
-	}
-	// 复制原始数据
-	copy(commentDenPad[params.SearchRange:], commentDensity)
-	// 后面填充searchRange个0
-	for i := len(commentDensity) + params.SearchRange; i < len(commentDenPad); i++ {
-		commentDenPad[i] = 0
+	return markPeaks(commentDensity, windowMax, thrDensity), commentDensity
+}
+
+// percentileFromSorted 从升序数组中取百分位对应的值
+func percentileFromSorted(sorted []float64, thr float64) float64 {
+	thrIndex := int(math.Floor(float64(len(sorted)) * thr))
+	if thrIndex >= len(sorted) {
+		thrIndex = len(sorted) - 1
 	}
+	return sorted[thrIndex]
+}
 
-	// 检测峰值
-	isPeak := make([]bool, n)
-	for i := 0; i < len(commentDensity); i++ {
-		isPeak[i] = false
+// slidingMax 计算每个点在 [i-radius, i+radius] 范围内的最大值（越界部分按0填充）
+// 使用单调队列实现，复杂度 O(n)
+func slidingMax(values []float64, radius int) []float64 {
+	n := len(values)
+	result := make([]float64, n)
+	deque := make([]int, 0, 2*radius+1)
 
-		// 如果小于阈值，跳过
-		if commentDensity[i] < thrDensity {
-			continue
+	next := 0 // 下一个待入队的下标
+	for i := 0; i < n; i++ {
+		// 窗口右边界入队
+		for ; next < n && next <= i+radius; next++ {
+			for len(deque) > 0 && values[deque[len(deque)-1]] <= values[next] {
+				deque = deque[:len(deque)-1]
+			}
+			deque = append(deque, next)
+		}
+		// 窗口左边界出队
+		for deque[0] < i-radius {
+			deque = deque[1:]
 		}
 
-		// 在搜索范围内查找最大值
-		ind := i + params.SearchRange
-		tmpData := commentDenPad[ind-params.SearchRange : ind+params.SearchRange+1]
-
-		// 找到最大值
-		maxVal := tmpData[0]
-		for _, val := range tmpData {
-			if val > maxVal {
-				maxVal = val
-			}
+		maxVal := values[deque[0]]
+		// 窗口超出数组边界时包含填充的0
+		if (i-radius < 0 || i+radius >= n) && maxVal < 0 {
+			maxVal = 0
 		}
+		result[i] = maxVal
+	}
 
+	return result
+}
+
+// markPeaks 标记不低于阈值且等于其搜索范围内最大值的点
+func markPeaks(density, windowMax []float64, thrDensity float64) []bool {
+	isPeak := make([]bool, len(density))
+	for i, v := range density {
+		// 如果小于阈值，跳过
+		if v < thrDensity {
+			continue
+		}
 		// 如果当前值等于最大值，则为峰值
-		if commentDensity[i] == maxVal {
+		if v == windowMax[i] {
 			isPeak[i] = true
 		}
 	}
-
-	return isPeak, commentDensity
+	return isPeak
 }
 
 // mergeCloseHotMoments 合并接近的热点时刻
@@ -350,6 +464,41 @@ func convSame(signal []float64, kernel []float64) []float64 {
 	return result
 }
 
+// boxSumSame 全1卷积核的卷积运算（same模式）
+// 与 convSame(signal, ones(width)) 结果一致，但使用前缀和在 O(n) 内完成
+func boxSumSame(signal []float64, width int) []float64 {
+	n := len(signal)
+	result := make([]float64, n)
+	if n == 0 || width <= 0 {
+		return result
+	}
+
+	// prefix[k] 为 signal[0..k-1] 的和
+	prefix := make([]float64, n+1)
+	for i, v := range signal {
+		prefix[i+1] = prefix[i] + v
+	}
+
+	// 与 convSame 相同的偏移量
+	offset := (width - 1) / 2
+
+	for i := 0; i < n; i++ {
+		lo := i - offset
+		hi := lo + width
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > n {
+			hi = n
+		}
+		if hi > lo {
+			result[i] = prefix[hi] - prefix[lo]
+		}
+	}
+
+	return result
+}
+
 // formatDuration 格式化时长为可读格式
 func formatDuration(seconds float64) string {
 	duration := time.Duration(seconds) * time.Second
@@ -385,6 +534,13 @@ func GetAnalysisSummary(c *gin.Context) {
 		return
 	}
 
+	if IsTakenDown(ArtifactSummary, videoID, offsetSeconds) {
+		c.JSON(410, gin.H{
+			"error": "summary has been taken down",
+		})
+		return
+	}
+
 	// 查找analysis_results目录
 	analysisDir := "analysis_results"
 	entries, err := os.ReadDir(analysisDir)
@@ -468,8 +624,20 @@ func GetAnalysisSummary(c *gin.Context) {
 		actualOffset = parts[1]
 	}
 
-	c.JSON(200, gin.H{
+	resp := gin.H{
 		"actual_offset": actualOffset,
 		"summary":       string(content),
-	})
+	}
+	// 附带根据弹幕生成的反应总结（如有）
+	if reaction := readChatReactionForOffset(videoID, offsetSeconds); reaction != "" {
+		resp["chat_reaction"] = reaction
+	}
+	// 附带AI自评分数（如有）
+	if data, err := os.ReadFile(strings.TrimSuffix(closestFile, "_summary.txt") + "_summary_eval.json"); err == nil {
+		var eval SummaryEvaluation
+		if json.Unmarshal(data, &eval) == nil {
+			resp["evaluation"] = eval
+		}
+	}
+	c.JSON(200, resp)
 }
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// 性能预算：100万条评论（约8小时VOD）的完整分析应在该时间内完成
+const fullAnalysisBudget1M = 2 * time.Second
+
+// syntheticOffsets 生成模拟的评论时间偏移：均匀背景 + 若干突发高峰
+func syntheticOffsets(count int, durationSeconds float64, seed int64) []float64 {
+	r := rand.New(rand.NewSource(seed))
+	offsets := make([]float64, 0, count)
+
+	// 约 70% 为背景评论
+	background := count * 7 / 10
+	for i := 0; i < background; i++ {
+		offsets = append(offsets, r.Float64()*durationSeconds)
+	}
+
+	// 剩余评论集中在随机的高峰附近
+	peaks := 20
+	for i := background; i < count; i++ {
+		center := float64(r.Intn(peaks)+1) * durationSeconds / float64(peaks+1)
+		offset := center + r.NormFloat64()*30
+		if offset < 0 {
+			offset = 0
+		}
+		offsets = append(offsets, offset)
+	}
+
+	return offsets
+}
+
+// countPerSecond 将评论偏移转换为每秒评论数，与 findHotCommentsWithParams 一致
+func countPerSecond(offsets []float64) []float64 {
+	maxOffset := 0.0
+	for _, o := range offsets {
+		if o > maxOffset {
+			maxOffset = o
+		}
+	}
+	counts := make([]float64, int(maxOffset)+2)
+	for _, o := range offsets {
+		counts[int(o)]++
+	}
+	return counts
+}
+
+func onesKernel(n int) []float64 {
+	kernel := make([]float64, n)
+	for i := range kernel {
+		kernel[i] = 1.0
+	}
+	return kernel
+}
+
+func TestBoxSumSameMatchesConvSame(t *testing.T) {
+	signals := [][]float64{
+		{},
+		{3},
+		{1, 2, 3, 4, 5},
+		countPerSecond(syntheticOffsets(20000, 3600, 1)),
+	}
+	widths := []int{1, 2, 3, 8, 121, 421, 5000}
+
+	for si, signal := range signals {
+		for _, width := range widths {
+			want := convSame(signal, onesKernel(width))
+			got := boxSumSame(signal, width)
+			if len(got) != len(want) {
+				t.Fatalf("signal %d width %d: len %d, want %d", si, width, len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("signal %d width %d: index %d got %v, want %v", si, width, i, got[i], want[i])
+				}
+			}
+		}
+	}
+}
+
+func TestFindPeakWithParamsMatchesReference(t *testing.T) {
+	counts := countPerSecond(syntheticOffsets(100000, 4*3600, 2))
+
+	for _, params := range []PeakDetectionParams{
+		defaultPeakParams,
+		{WindowsLen: 120, Thr: 0.9, SearchRange: 60},
+		{WindowsLen: 30, Thr: 0.95, SearchRange: 15},
+	} {
+		// 参考实现：原始的 O(n·k) 卷积
+		refDensity := convSame(counts, onesKernel(params.WindowsLen+1))
+		isPeak, density := findPeakWithParams(counts, params)
+
+		for i := range refDensity {
+			if density[i] != refDensity[i] {
+				t.Fatalf("params %+v: density[%d] = %v, want %v", params, i, density[i], refDensity[i])
+			}
+		}
+		if len(isPeak) != len(counts) {
+			t.Fatalf("params %+v: len(isPeak) = %d, want %d", params, len(isPeak), len(counts))
+		}
+		refPeak := bruteForcePeaks(refDensity, params)
+		for i := range refPeak {
+			if isPeak[i] != refPeak[i] {
+				t.Fatalf("params %+v: isPeak[%d] = %v, want %v", params, i, isPeak[i], refPeak[i])
+			}
+		}
+	}
+}
+
+// bruteForcePeaks 原始的补零后逐点搜索最大值的峰值检测
+func bruteForcePeaks(density []float64, params PeakDetectionParams) []bool {
+	sorted := append([]float64(nil), density...)
+	sort.Float64s(sorted)
+	thrIndex := int(float64(len(sorted)) * params.Thr)
+	if thrIndex >= len(sorted) {
+		thrIndex = len(sorted) - 1
+	}
+	thr := sorted[thrIndex]
+
+	padded := make([]float64, len(density)+2*params.SearchRange)
+	copy(padded[params.SearchRange:], density)
+
+	isPeak := make([]bool, len(density))
+	for i, v := range density {
+		if v < thr {
+			continue
+		}
+		maxVal := 0.0
+		for _, w := range padded[i : i+2*params.SearchRange+1] {
+			if w > maxVal {
+				maxVal = w
+			}
+		}
+		isPeak[i] = v == maxVal
+	}
+	return isPeak
+}
+
+func TestAnalysisSessionMatchesSingleAnalysis(t *testing.T) {
+	offsets := syntheticOffsets(50000, 2*3600, 7)
+	session := NewAnalysisSession(offsets)
+
+	paramSets := []PeakDetectionParams{
+		defaultPeakParams,
+		{WindowsLen: 420, Thr: 0.8, SearchRange: 210},
+		{WindowsLen: 420, Thr: 0.95, SearchRange: 60},
+		{WindowsLen: 120, Thr: 0.9, SearchRange: 60},
+		{},
+	}
+	// 运行两遍，第二遍全部命中缓存
+	for pass := 0; pass < 2; pass++ {
+		for _, params := range paramSets {
+			want := findHotCommentsWithParams(offsets, 5, params)
+			got := session.Analyze(params)
+			if len(got.HotMoments) != len(want.HotMoments) {
+				t.Fatalf("pass %d params %+v: %d hot moments, want %d",
+					pass, params, len(got.HotMoments), len(want.HotMoments))
+			}
+			for i := range want.HotMoments {
+				if !reflect.DeepEqual(got.HotMoments[i], want.HotMoments[i]) {
+					t.Fatalf("pass %d params %+v: hot moment %d = %+v, want %+v",
+						pass, params, i, got.HotMoments[i], want.HotMoments[i])
+				}
+			}
+		}
+	}
+}
+
+func benchmarkSweep(b *testing.B, messages int, shared bool) {
+	offsets := syntheticOffsets(messages, float64(messages)/30, 8)
+	var paramSets []PeakDetectionParams
+	for _, w := range []int{120, 420} {
+		for _, thr := range []float64{0.8, 0.85, 0.9, 0.95} {
+			paramSets = append(paramSets, PeakDetectionParams{WindowsLen: w, Thr: thr, SearchRange: w / 2})
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if shared {
+			session := NewAnalysisSession(offsets)
+			for _, params := range paramSets {
+				session.Analyze(params)
+			}
+		} else {
+			for _, params := range paramSets {
+				findHotCommentsWithParams(offsets, 5, params)
+			}
+		}
+	}
+}
+
+// TestFullAnalysisPerformanceBudget 检查墙钟时间，受机器负载与 -race 影响，默认不运行；
+// 在专用机器上设置 LUMITIME_PERF_BUDGET=1 后执行，日常对比使用 Benchmark*
+func TestFullAnalysisPerformanceBudget(t *testing.T) {
+	if os.Getenv("LUMITIME_PERF_BUDGET") == "" {
+		t.Skip("set LUMITIME_PERF_BUDGET=1 to check the analysis performance budget")
+	}
+
+	offsets := syntheticOffsets(1000000, 8*3600, 3)
+	start := time.Now()
+	result := findHotCommentsWithParams(offsets, 5, defaultPeakParams)
+	elapsed := time.Since(start)
+
+	if len(result.HotMoments) == 0 {
+		t.Fatal("expected hot moments for synthetic log")
+	}
+	if elapsed > fullAnalysisBudget1M {
+		t.Fatalf("analysis of 1M messages took %v, budget is %v", elapsed, fullAnalysisBudget1M)
+	}
+}
+
+func benchmarkConvSame(b *testing.B, messages int) {
+	counts := countPerSecond(syntheticOffsets(messages, float64(messages)/30, 4))
+	kernel := onesKernel(defaultPeakParams.WindowsLen + 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		convSame(counts, kernel)
+	}
+}
+
+func benchmarkBoxSumSame(b *testing.B, messages int) {
+	counts := countPerSecond(syntheticOffsets(messages, float64(messages)/30, 4))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		boxSumSame(counts, defaultPeakParams.WindowsLen+1)
+	}
+}
+
+func benchmarkFindPeak(b *testing.B, messages int) {
+	counts := countPerSecond(syntheticOffsets(messages, float64(messages)/30, 5))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		findPeakWithParams(counts, defaultPeakParams)
+	}
+}
+
+func benchmarkFullAnalysis(b *testing.B, messages int) {
+	offsets := syntheticOffsets(messages, float64(messages)/30, 6)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		findHotCommentsWithParams(offsets, 5, defaultPeakParams)
+	}
+}
+
+func BenchmarkConvSame100k(b *testing.B)           { benchmarkConvSame(b, 100000) }
+func BenchmarkConvSame1M(b *testing.B)             { benchmarkConvSame(b, 1000000) }
+func BenchmarkBoxSumSame100k(b *testing.B)         { benchmarkBoxSumSame(b, 100000) }
+func BenchmarkBoxSumSame1M(b *testing.B)           { benchmarkBoxSumSame(b, 1000000) }
+func BenchmarkFindPeakWithParams100k(b *testing.B) { benchmarkFindPeak(b, 100000) }
+func BenchmarkFindPeakWithParams1M(b *testing.B)   { benchmarkFindPeak(b, 1000000) }
+func BenchmarkFullAnalysis100k(b *testing.B)       { benchmarkFullAnalysis(b, 100000) }
+func BenchmarkFullAnalysis1M(b *testing.B)         { benchmarkFullAnalysis(b, 1000000) }
+func BenchmarkSweepIndependent1M(b *testing.B)     { benchmarkSweep(b, 1000000, false) }
+func BenchmarkSweepSession1M(b *testing.B)         { benchmarkSweep(b, 1000000, true) }
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"os"
+	"strings"
+
+	"subtuber-services/models"
+)
+
+// chatVelocityWindow 弹幕速度的统计窗口（秒），峰值弹幕速度为任意 60 秒内的最大弹幕数，即每分钟弹幕数
+const chatVelocityWindow = 60
+
+// chatterSeconds 按秒记录的弹幕发送者（观众编号），同一观众在一秒内的多条弹幕重复记录
+type chatterSeconds struct {
+	start   int     // 第一秒对应的录像偏移
+	authors [][]int // authors[i] 为第 start+i 秒的弹幕发送者
+	total   int     // 观众总数（编号范围）
+}
+
+// loadChatterSeconds 读取录像聊天记录中 [start, end) 秒内弹幕的发送者
+// Twitch 按用户 ID 区分观众（改名后仍为同一人），YouTube 按名称；没有发送者信息的弹幕不计入
+func loadChatterSeconds(videoID string, start, end int) (chatterSeconds, error) {
+	cs := chatterSeconds{start: start, authors: make([][]int, max(end-start, 0))}
+	ids := map[string]int{}
+	err := eachChatMessage(videoID, func(msg models.ChatMessage) {
+		i := int(math.Floor(msg.OffsetSeconds)) - start
+		if i < 0 || i >= len(cs.authors) {
+			return
+		}
+		key := msg.AuthorID
+		if key == "" {
+			key = strings.ToLower(msg.Author)
+		}
+		if key == "" {
+			return
+		}
+		id, ok := ids[key]
+		if !ok {
+			id = len(ids)
+			ids[key] = id
+		}
+		cs.authors[i] = append(cs.authors[i], id)
+	})
+	cs.total = len(ids)
+	return cs, err
+}
+
+// uniqueIn 统计 [lo, hi) 秒（相对 start）内的弹幕数与不同观众数
+func (cs chatterSeconds) uniqueIn(lo, hi int) (messages, unique int) {
+	lo, hi = max(lo, 0), min(hi, len(cs.authors))
+	seen := map[int]bool{}
+	for i := lo; i < hi; i++ {
+		for _, id := range cs.authors[i] {
+			messages++
+			seen[id] = true
+		}
+	}
+	return messages, len(seen)
+}
+
+// slidingUnique 与 boxSumSame 相同的窗口（宽度 width，same 模式）内的不同观众数与弹幕数
+// 窗口两端单调右移，按观众计数增减，复杂度 O(弹幕数)
+func (cs chatterSeconds) slidingUnique(width int) (unique, messages []int) {
+	n := len(cs.authors)
+	unique, messages = make([]int, n), make([]int, n)
+	counts := make([]int, cs.total)
+	distinct, inWindow := 0, 0
+	lo, hi := 0, 0 // 当前已计入 [lo, hi)
+	offset := (width - 1) / 2
+	for i := 0; i < n; i++ {
+		wantLo, wantHi := max(i-offset, 0), min(i-offset+width, n)
+		for ; hi < wantHi; hi++ {
+			for _, id := range cs.authors[hi] {
+				if counts[id] == 0 {
+					distinct++
+				}
+				counts[id]++
+				inWindow++
+			}
+		}
+		for ; lo < wantLo; lo++ {
+			for _, id := range cs.authors[lo] {
+				counts[id]--
+				if counts[id] == 0 {
+					distinct--
+				}
+				inWindow--
+			}
+		}
+		unique[i], messages[i] = distinct, inWindow
+	}
+	return unique, messages
+}
+
+// messagesPerChatter 人均弹幕数，没有观众时为 0
+func messagesPerChatter(messages, unique int) float64 {
+	if unique == 0 {
+		return 0
+	}
+	return math.Round(float64(messages)/float64(unique)*100) / 100
+}
+
+// annotateChatters 为时间序列、热点与统计信息附加观众维度的指标：窗口内不同观众数、人均弹幕数与峰值弹幕速度
+// 少数观众刷屏时评论密度很高但观众数很少，人均弹幕数可用于区分；统计范围与时间序列一致（区间分析时只统计区间）
+// 没有聊天记录时不做处理
+func annotateChatters(videoID string, hotMoments []VodCommentData, timeSeriesData []TimeSeriesDataPoint, stats *VodCommentStats, windowsLen int) {
+	if len(timeSeriesData) == 0 {
+		return
+	}
+	start := int(timeSeriesData[0].OffsetSeconds)
+	cs, err := loadChatterSeconds(videoID, start, start+len(timeSeriesData))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("统计录像 %s 的观众数失败: %v", videoID, err)
+		}
+		return
+	}
+
+	unique, messages := cs.slidingUnique(windowsLen + 1)
+	for i := range timeSeriesData {
+		timeSeriesData[i].UniqueChatters = unique[i]
+		timeSeriesData[i].MessagesPerChatter = messagesPerChatter(messages[i], unique[i])
+	}
+
+	for i := range hotMoments {
+		// 热点的窗口以峰值为中心，多尺度检测时使用主导尺度
+		window := windowsLen
+		if hotMoments[i].Scale > 0 {
+			window = hotMoments[i].Scale
+		}
+		offset := int(hotMoments[i].OffsetSeconds) - start
+		msgs, chatters := cs.uniqueIn(offset-window/2, offset+window/2+1)
+		hotMoments[i].UniqueChatters = chatters
+		hotMoments[i].MessagesPerChatter = messagesPerChatter(msgs, chatters)
+	}
+
+	if stats == nil {
+		return
+	}
+	stats.Messages, stats.UniqueChatters = cs.uniqueIn(0, len(cs.authors))
+	stats.MessagesPerChatter = messagesPerChatter(stats.Messages, stats.UniqueChatters)
+
+	// 峰值弹幕速度：任意 60 秒窗口内的最大弹幕数
+	inWindow := 0
+	for i := range cs.authors {
+		inWindow += len(cs.authors[i])
+		if i >= chatVelocityWindow {
+			inWindow -= len(cs.authors[i-chatVelocityWindow])
+		}
+		if float64(inWindow) > stats.PeakVelocity {
+			stats.PeakVelocity = float64(inWindow)
+			stats.PeakVelocityOffset = float64(start + max(i-chatVelocityWindow+1, 0))
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"subtuber-services/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultEmoteCollapseWindow 同一表情的刷屏从第一条开始在该时长（秒）内的消息合并为一条
+	defaultEmoteCollapseWindow = 10.0
+	// defaultEmoteCollapseMin 时间窗口内同一表情的纯表情消息达到该数量才合并，零星的表情消息保持原样
+	defaultEmoteCollapseMin = 3
+)
+
+// emoteCollapseOptions 回放接口的纯表情刷屏合并选项
+type emoteCollapseOptions struct {
+	Enabled bool
+	Window  float64
+	Min     int
+}
+
+// CollapsedEmoteRun 合并后的一段纯表情刷屏
+type CollapsedEmoteRun struct {
+	Emote       string  `json:"emote"`
+	Count       int     `json:"count"`   // 合并的消息数
+	Authors     int     `json:"authors"` // 发送者人数
+	StartOffset float64 `json:"start_offset_seconds"`
+	EndOffset   float64 `json:"end_offset_seconds"`
+	Text        string  `json:"text"` // 用于直接展示，如 "×57 PogChamp in 10s"
+}
+
+// emoteCollapseFromQuery 从 collapse_emotes、collapse_window（秒）与 collapse_min 参数读取合并选项
+func emoteCollapseFromQuery(c *gin.Context) (emoteCollapseOptions, error) {
+	opts := emoteCollapseOptions{Window: defaultEmoteCollapseWindow, Min: defaultEmoteCollapseMin}
+	opts.Enabled, _ = strconv.ParseBool(c.Query("collapse_emotes"))
+	if v := c.Query("collapse_window"); v != "" {
+		window, err := strconv.ParseFloat(v, 64)
+		if err != nil || window <= 0 || window > 600 {
+			return opts, fmt.Errorf("collapse_window 必须为 0 到 600 之间的秒数")
+		}
+		opts.Window = window
+	}
+	if v := c.Query("collapse_min"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2 {
+			return opts, fmt.Errorf("collapse_min 必须为不小于 2 的整数")
+		}
+		opts.Min = n
+	}
+	return opts, nil
+}
+
+// twitchEmoteOnlyKey 消息只包含表情（可含空白）时返回表情名称，多种表情按出现顺序去重后以空格连接；否则返回空字符串
+func twitchEmoteOnlyKey(msg models.TwitchChatMessage) string {
+	var names []string
+	for _, frag := range msg.Fragments {
+		text := strings.TrimSpace(frag.Text)
+		if frag.Emoticon == nil {
+			if text != "" {
+				return ""
+			}
+			continue
+		}
+		names = appendUnique(names, text)
+	}
+	return strings.Join(names, " ")
+}
+
+// youtubeEmoteOnlyKey 消息只包含自定义表情（:name:）与 emoji 时返回表情名称，否则返回空字符串
+func youtubeEmoteOnlyKey(message string) string {
+	var names []string
+	for _, token := range strings.Fields(message) {
+		if !isEmoteToken(token) {
+			return ""
+		}
+		names = appendUnique(names, strings.Trim(token, ":"))
+	}
+	return strings.Join(names, " ")
+}
+
+// isEmoteToken 是否为 :name: 形式的自定义表情，或只由 emoji 组成
+func isEmoteToken(token string) bool {
+	if len(token) > 2 && strings.HasPrefix(token, ":") && strings.HasSuffix(token, ":") && !strings.Contains(token[1:len(token)-1], ":") {
+		return true
+	}
+	hasSymbol := false
+	for _, r := range token {
+		switch {
+		case r > unicode.MaxASCII && (unicode.Is(unicode.So, r) || unicode.Is(unicode.Sk, r)):
+			hasSymbol = true
+		case r == '\u200d' || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Variation_Selector, r):
+			// 零宽连接符、肤色与变体选择符是 emoji 的一部分
+		default:
+			return false
+		}
+	}
+	return hasSymbol
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// emoteChatMessage 合并时需要的消息信息
+type emoteChatMessage struct {
+	Key    string // 纯表情消息的表情名称，普通消息为空
+	Author string
+	Offset float64
+}
+
+// collapseEmoteRuns 将时间窗口内同一表情达到最少数量的纯表情消息合并
+// 返回每条消息是否被合并（与输入顺序一致）以及按开始时间排序的合并结果
+func collapseEmoteRuns(messages []emoteChatMessage, opts emoteCollapseOptions) ([]bool, []CollapsedEmoteRun) {
+	type run struct {
+		CollapsedEmoteRun
+		members []int
+		authors map[string]bool
+	}
+
+	order := make([]int, len(messages))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return messages[order[a]].Offset < messages[order[b]].Offset })
+
+	var runs []*run
+	active := map[string]*run{}
+	for _, i := range order {
+		msg := messages[i]
+		if msg.Key == "" {
+			continue
+		}
+		r, ok := active[msg.Key]
+		if !ok || msg.Offset-r.StartOffset > opts.Window {
+			r = &run{CollapsedEmoteRun: CollapsedEmoteRun{Emote: msg.Key, StartOffset: msg.Offset}, authors: map[string]bool{}}
+			active[msg.Key] = r
+			runs = append(runs, r)
+		}
+		r.members = append(r.members, i)
+		r.authors[msg.Author] = true
+		r.EndOffset = msg.Offset
+	}
+
+	collapsed := make([]bool, len(messages))
+	result := []CollapsedEmoteRun{}
+	for _, r := range runs {
+		if len(r.members) < opts.Min {
+			continue
+		}
+		for _, i := range r.members {
+			collapsed[i] = true
+		}
+		r.Count = len(r.members)
+		r.Authors = len(r.authors)
+		span := math.Max(1, math.Ceil(r.EndOffset-r.StartOffset))
+		r.Text = fmt.Sprintf("×%d %s in %.0fs", r.Count, r.Emote, span)
+		result = append(result, r.CollapsedEmoteRun)
+	}
+	return collapsed, result
+}
+
+// collapseTwitchComments 合并 Twitch 聊天记录中的纯表情刷屏，返回保留的评论与合并结果
+func collapseTwitchComments(comments []models.TwitchChatComment, opts emoteCollapseOptions) ([]models.TwitchChatComment, []CollapsedEmoteRun) {
+	messages := make([]emoteChatMessage, len(comments))
+	for i, comment := range comments {
+		messages[i] = emoteChatMessage{
+			Key:    twitchEmoteOnlyKey(comment.Message),
+			Author: comment.Commenter.ID,
+			Offset: comment.ContentOffsetSeconds,
+		}
+	}
+	collapsed, runs := collapseEmoteRuns(messages, opts)
+
+	kept := make([]models.TwitchChatComment, 0, len(comments))
+	for i, comment := range comments {
+		if !collapsed[i] {
+			kept = append(kept, comment)
+		}
+	}
+	return kept, runs
+}
+
+// collapseYouTubeChat 合并 YouTube 聊天记录中的纯表情刷屏，返回保留的消息与合并结果
+func collapseYouTubeChat(logs []models.YoutubeChatLog, opts emoteCollapseOptions) ([]models.YoutubeChatLog, []CollapsedEmoteRun) {
+	messages := make([]emoteChatMessage, len(logs))
+	for i, entry := range logs {
+		messages[i] = emoteChatMessage{
+			Key:    youtubeEmoteOnlyKey(entry.Message),
+			Author: entry.Author,
+			Offset: entry.OffsetSeconds,
+		}
+	}
+	collapsed, runs := collapseEmoteRuns(messages, opts)
+
+	kept := make([]models.YoutubeChatLog, 0, len(logs))
+	for i, entry := range logs {
+		if !collapsed[i] {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, runs
+}
+
+// CollapsedTwitchChat 合并纯表情刷屏后的 Twitch 聊天记录，total_comments 仍为合并前的评论总数
+type CollapsedTwitchChat struct {
+	*models.TwitchChatDownloadResponse
+	CollapsedComments int                 `json:"collapsed_comments"`
+	CollapsedEmotes   []CollapsedEmoteRun `json:"collapsed_emotes"`
+}
+
+// newCollapsedTwitchChat 按选项合并 Twitch 聊天记录，不修改原记录
+func newCollapsedTwitchChat(response *models.TwitchChatDownloadResponse, opts emoteCollapseOptions) CollapsedTwitchChat {
+	copied := *response
+	kept, runs := collapseTwitchComments(response.Comments, opts)
+	copied.Comments = kept
+	return CollapsedTwitchChat{
+		TwitchChatDownloadResponse: &copied,
+		CollapsedComments:          len(response.Comments) - len(kept),
+		CollapsedEmotes:            runs,
+	}
+}
+
+// CollapsedYouTubeChat 合并纯表情刷屏后的 YouTube 聊天记录
+type CollapsedYouTubeChat struct {
+	TotalComments     int                     `json:"total_comments"`
+	CollapsedComments int                     `json:"collapsed_comments"`
+	Messages          []models.YoutubeChatLog `json:"messages"`
+	CollapsedEmotes   []CollapsedEmoteRun     `json:"collapsed_emotes"`
+}
+
+// newCollapsedYouTubeChat 按选项合并 YouTube 聊天记录
+func newCollapsedYouTubeChat(logs []models.YoutubeChatLog, opts emoteCollapseOptions) CollapsedYouTubeChat {
+	kept, runs := collapseYouTubeChat(logs, opts)
+	return CollapsedYouTubeChat{
+		TotalComments:     len(logs),
+		CollapsedComments: len(logs) - len(kept),
+		Messages:          kept,
+		CollapsedEmotes:   runs,
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"os"
+
+	"subtuber-services/chatdownload"
+	"subtuber-services/models"
+)
+
+// eachChatMessage 逐条读取录像的聊天记录并转换为与平台无关的 ChatMessage
+// 依次查找 Twitch 与 YouTube 的聊天记录文件，没有聊天记录时返回 os.ErrNotExist
+// 新增平台时在这里加入对应聊天记录的查找与转换，分析、章节、表情统计等无需改动
+func eachChatMessage(videoID string, fn func(msg models.ChatMessage)) error {
+	if path := chatdownload.FindTwitchLog(videoID); path != "" {
+		_, err := chatdownload.ReadTwitch(path, func(comment models.TwitchChatComment) error {
+			fn(models.ChatMessageFromTwitch(comment))
+			return nil
+		})
+		return err
+	}
+
+	if path := chatdownload.FindYouTubeLog(videoID); path != "" {
+		chatLogs, err := chatdownload.LoadYouTube(path)
+		if err != nil {
+			return err
+		}
+		for _, chat := range chatLogs {
+			fn(models.ChatMessageFromYouTube(chat))
+		}
+		return nil
+	}
+
+	return os.ErrNotExist
+}
+
+// chatMessageOffsets 提取所有消息的时间偏移
+func chatMessageOffsets(messages []models.ChatMessage) []float64 {
+	offsets := make([]float64, 0, len(messages))
+	for _, msg := range messages {
+		offsets = append(offsets, msg.OffsetSeconds)
+	}
+	return offsets
+}
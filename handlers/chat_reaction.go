@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"subtuber-services/models"
+	"subtuber-services/pathsafe"
+)
+
+// 弹幕反应总结保存为 {offset}_chat_reaction.txt，与字幕总结 {offset}_summary.txt 放在同一目录，
+// 后缀不同以免被按 *_summary.txt 查找字幕总结的地方读到
+const chatReactionFileSuffix = "_chat_reaction.txt"
+
+// 弹幕反应总结的默认参数
+const (
+	defaultChatSummaryMaxMessages = 300
+	defaultChatSummaryWords       = 150
+	maxChatSummaryMaxMessages     = 2000
+)
+
+// ChatSummarySettings holds whether hot moments get a summary of the chat reaction and how much chat is sent to the AI
+type ChatSummarySettings struct {
+	Enabled       bool `mapstructure:"enabled" json:"enabled"`               // 分析完成后为每个热点生成弹幕反应总结（同时需要开启 ai_summary 功能）
+	WindowSeconds int  `mapstructure:"window_seconds" json:"window_seconds"` // 以热点为中心取多长时间的弹幕（秒），默认使用热点的检测窗口
+	MaxMessages   int  `mapstructure:"max_messages" json:"max_messages"`     // 每个热点最多发送给AI的弹幕条数，超出时均匀抽样，默认 300
+	Words         int  `mapstructure:"words" json:"words"`                   // 总结的字数上限，默认 150
+}
+
+// Validate checks the chat window, message limit and summary length
+func (s ChatSummarySettings) Validate() error {
+	if s.WindowSeconds < 0 || s.MaxMessages < 0 || s.Words < 0 {
+		return fmt.Errorf("弹幕反应总结的窗口、弹幕条数与字数不能为负数")
+	}
+	if s.MaxMessages > maxChatSummaryMaxMessages {
+		return fmt.Errorf("弹幕反应总结的弹幕条数不能超过 %d", maxChatSummaryMaxMessages)
+	}
+	return nil
+}
+
+func (s ChatSummarySettings) maxMessages() int {
+	if s.MaxMessages <= 0 {
+		return defaultChatSummaryMaxMessages
+	}
+	return s.MaxMessages
+}
+
+func (s ChatSummarySettings) words() int {
+	if s.Words <= 0 {
+		return defaultChatSummaryWords
+	}
+	return s.Words
+}
+
+// window 热点取弹幕的窗口长度，未配置时与情绪、表情统计相同，使用主导尺度或检测窗口
+func (s ChatSummarySettings) window(moment VodCommentData, windowsLen int) float64 {
+	if s.WindowSeconds > 0 {
+		return float64(s.WindowSeconds)
+	}
+	if moment.Scale > 0 {
+		return float64(moment.Scale)
+	}
+	return float64(windowsLen)
+}
+
+// chatReactionPath 热点弹幕反应总结的文件路径
+func chatReactionPath(videoID string, offsetSeconds float64) string {
+	name := pathsafe.Seconds(offsetSeconds)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return filepath.Join(pathsafe.Join("./analysis_results", videoID), name+chatReactionFileSuffix)
+}
+
+// readChatReactionForOffset 读取与热点偏移对应的弹幕反应总结，不存在时返回空
+func readChatReactionForOffset(videoID string, offsetSeconds float64) string {
+	content, err := os.ReadFile(chatReactionPath(videoID, offsetSeconds))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// shouldSummarizeChatReactions 录像是否需要生成弹幕反应总结
+func shouldSummarizeChatReactions(plan PipelinePlan) bool {
+	return GetPipelineConfig().ChatSummary.Enabled && IsFeatureEnabled(FeatureAISummary) && plan.Runs(PipelineStepChatSummary)
+}
+
+// collectChatReactions 一次读取聊天记录，按热点窗口收集弹幕，结果与 moments 一一对应
+func collectChatReactions(videoID string, moments []VodCommentData, settings ChatSummarySettings, windowsLen int) ([][]models.ChatMessage, error) {
+	collected := make([][]models.ChatMessage, len(moments))
+	err := eachChatMessage(videoID, func(msg models.ChatMessage) {
+		for i, moment := range moments {
+			half := settings.window(moment, windowsLen) / 2
+			if msg.OffsetSeconds >= moment.OffsetSeconds-half && msg.OffsetSeconds <= moment.OffsetSeconds+half {
+				collected[i] = append(collected[i], msg)
+			}
+		}
+	})
+	return collected, err
+}
+
+// formatChatReactionLog 将弹幕格式化为每行一条的“[时间] 作者: 内容”，超过上限时均匀抽样，并附上窗口内最常用的表情
+func formatChatReactionLog(messages []models.ChatMessage, topEmotes []EmoteCount, maxMessages int) string {
+	var b strings.Builder
+	if len(topEmotes) > 0 {
+		emotes := make([]string, 0, len(topEmotes))
+		for _, e := range topEmotes {
+			emotes = append(emotes, fmt.Sprintf("%s x%d", e.Emote, e.Count))
+		}
+		fmt.Fprintf(&b, "Most used emotes: %s\n\n", strings.Join(emotes, ", "))
+	}
+
+	count := min(len(messages), maxMessages)
+	for i := 0; i < count; i++ {
+		msg := messages[i*len(messages)/count]
+		// 多行消息合并为一行，保证每行对应一条弹幕
+		text := strings.Join(strings.Fields(msg.Text), " ")
+		if text == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "[%s] %s: %s\n", formatDuration(msg.OffsetSeconds), msg.Author, text)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// summarizeChatReaction 让AI根据热点附近的弹幕总结观众在对什么做出反应
+// 提示词变量与总结语言从 ctx 读取，与字幕总结相同
+func summarizeChatReaction(ctx context.Context, service AIService, chatLog string, words int) (string, error) {
+	vars := summaryPromptVarsFromContext(ctx)
+	vars.Words = words
+	templates := promptTemplatesFor(vars.StreamerID, summaryLanguageFromContext(ctx))
+	prompt := renderPromptOrBuiltin(templates.Chat, builtinChatPrompt, vars) + "\n\n" + chatLog
+	return service.GenerateContent(ctx, prompt, int(float64(words)*budgetTokensPerWord)+100)
+}
+
+// summarizeHotMomentChatReactions 为录像的每个热点生成弹幕反应总结，已有总结或已下架的热点跳过
+// 没有聊天记录或窗口内没有弹幕时不做处理
+func summarizeHotMomentChatReactions(ctx context.Context, videoID string, moments []VodCommentData, plan PipelinePlan, windowsLen int) {
+	if len(moments) == 0 {
+		return
+	}
+	settings := GetPipelineConfig().ChatSummary
+
+	pending := make([]VodCommentData, 0, len(moments))
+	for _, moment := range moments {
+		if IsTakenDown(ArtifactSummary, videoID, moment.OffsetSeconds) || readChatReactionForOffset(videoID, moment.OffsetSeconds) != "" {
+			continue
+		}
+		pending = append(pending, moment)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	collected, err := collectChatReactions(videoID, pending, settings, windowsLen)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("读取录像 %s 的聊天记录失败，跳过弹幕反应总结: %v", videoID, err)
+		}
+		return
+	}
+
+	aiService := NewConfiguredAIService()
+	if aiService == nil {
+		log.Println("AI 服务未初始化，跳过弹幕反应总结")
+		return
+	}
+	analysisDir := pathsafe.Join("./analysis_results", videoID)
+	if err := os.MkdirAll(analysisDir, 0755); err != nil {
+		log.Printf("创建分析目录失败: %v", err)
+		return
+	}
+
+	done := 0
+	for i, moment := range pending {
+		if len(collected[i]) == 0 {
+			continue
+		}
+		chatLog := formatChatReactionLog(collected[i], moment.TopEmotes, settings.maxMessages())
+		if chatLog == "" {
+			continue
+		}
+		momentCtx := withSummaryPromptVars(withSummaryLanguage(ctx, plan.SummaryLanguage),
+			summaryPromptVarsFor(plan, moment.OffsetSeconds))
+		summary, err := summarizeChatReaction(momentCtx, aiService, chatLog, settings.words())
+		if err != nil {
+			log.Printf("热点 %.0f 秒的弹幕反应总结失败: %v", moment.OffsetSeconds, err)
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		path := chatReactionPath(videoID, moment.OffsetSeconds)
+		if err := os.WriteFile(path, []byte(summary), 0644); err != nil {
+			log.Printf("保存弹幕反应总结失败: %v", err)
+			continue
+		}
+		done++
+	}
+	log.Printf("录像 %s 的弹幕反应总结完成: %d/%d 个热点", videoID, done, len(pending))
+}
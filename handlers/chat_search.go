@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"subtuber-services/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 聊天记录搜索的分页大小
+const (
+	defaultChatSearchLimit = 50
+	maxChatSearchLimit     = 500
+	maxChatSearchQueryLen  = 200
+)
+
+// ChatSearchHit 命中搜索条件的一条聊天消息
+type ChatSearchHit struct {
+	OffsetSeconds float64 `json:"offset_seconds"`
+	FormattedTime string  `json:"formatted_time"`
+	Author        string  `json:"author"`
+	AuthorID      string  `json:"author_id,omitempty"`
+	Text          string  `json:"text"`
+	Timestamp     string  `json:"timestamp,omitempty"`
+}
+
+// chatSearchFilter 聊天记录的搜索条件，空字段表示不限制
+type chatSearchFilter struct {
+	query string // 消息文本包含的内容（不区分大小写）
+	user  string // 发送者显示名包含的内容（不区分大小写），或与发送者ID完全一致
+	from  float64
+	to    float64 // 0 表示不限制结束时间
+}
+
+// match 消息是否满足搜索条件
+func (f chatSearchFilter) match(msg models.ChatMessage) bool {
+	if msg.OffsetSeconds < f.from || (f.to > 0 && msg.OffsetSeconds > f.to) {
+		return false
+	}
+	if f.user != "" && msg.AuthorID != f.user && !strings.Contains(strings.ToLower(msg.Author), f.user) {
+		return false
+	}
+	return f.query == "" || strings.Contains(strings.ToLower(msg.Text), f.query)
+}
+
+// parseChatSearchOffset 解析录像中的时间位置，支持秒数（90）、时长（1h2m3s）与时钟格式（1:02:03、62:03）
+func parseChatSearchOffset(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil && seconds >= 0 {
+		return seconds, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return d.Seconds(), nil
+	}
+	if parts := strings.Split(s, ":"); len(parts) == 2 || len(parts) == 3 {
+		total := 0.0
+		for _, part := range parts {
+			n, err := strconv.ParseFloat(part, 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("无法解析时间: %s", s)
+			}
+			total = total*60 + n
+		}
+		return total, nil
+	}
+	return 0, fmt.Errorf("无法解析时间: %s", s)
+}
+
+// SearchChat 按文本、发送者与时间范围搜索已下载的录像聊天记录，结果按时间排序并分页
+// GET /api/chats/:videoID/search?q=&user=&from=&to=&limit=50&offset=0
+func SearchChat(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+	filter := chatSearchFilter{
+		query: strings.ToLower(strings.TrimSpace(c.Query("q"))),
+		user:  strings.ToLower(strings.TrimPrefix(strings.TrimSpace(c.Query("user")), "@")),
+	}
+	if filter.query == "" && filter.user == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q 与 user 至少需要指定一个"})
+		return
+	}
+	if utf8.RuneCountInString(filter.query) > maxChatSearchQueryLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("q 不能超过 %d 个字符", maxChatSearchQueryLen)})
+		return
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  *float64
+	}{{"from", &filter.from}, {"to", &filter.to}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		seconds, err := parseChatSearchOffset(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": p.name + ": " + err.Error()})
+			return
+		}
+		*p.dst = seconds
+	}
+	if filter.to > 0 && filter.to < filter.from {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to 不能早于 from"})
+		return
+	}
+
+	limit := defaultChatSearchLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须为正整数"})
+			return
+		}
+		limit = min(n, maxChatSearchLimit)
+	}
+	offset := 0
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset 必须为非负整数"})
+			return
+		}
+		offset = n
+	}
+
+	// 逐条读取聊天记录，只保留当前页的消息，总数照常统计
+	hits := make([]ChatSearchHit, 0, limit)
+	total := 0
+	err := eachChatMessage(videoID, func(msg models.ChatMessage) {
+		if !filter.match(msg) {
+			return
+		}
+		if total >= offset && len(hits) < limit {
+			hits = append(hits, ChatSearchHit{
+				OffsetSeconds: msg.OffsetSeconds,
+				FormattedTime: formatDuration(msg.OffsetSeconds),
+				Author:        msg.Author,
+				AuthorID:      msg.AuthorID,
+				Text:          msg.Text,
+				Timestamp:     msg.Timestamp,
+			})
+		}
+		total++
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未找到该视频的聊天记录，请先下载聊天记录"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取聊天记录失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id": videoID,
+		"messages": hits,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+		"has_more": offset+len(hits) < total,
+	})
+}
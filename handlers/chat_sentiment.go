@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"subtuber-services/chatdownload"
+	"subtuber-services/pathsafe"
+)
+
+// 弹幕情绪分类
+const (
+	EmotionHype     = "hype"
+	EmotionLaughter = "laughter"
+	EmotionSadness  = "sadness"
+)
+
+const emotionsFileName = "emotions.json"
+
+// emotionWords 按整词匹配的表情与词语（小写），覆盖 Twitch / 7TV 常用表情与英文网络用语
+var emotionWords = map[string]string{
+	"pog": EmotionHype, "pogchamp": EmotionHype, "poggers": EmotionHype, "pogu": EmotionHype, "poggies": EmotionHype,
+	"hype": EmotionHype, "hypers": EmotionHype, "letsgo": EmotionHype, "letsgooo": EmotionHype, "clap": EmotionHype,
+	"ez": EmotionHype, "gg": EmotionHype, "goat": EmotionHype, "insane": EmotionHype, "wtf": EmotionHype,
+	"omg": EmotionHype, "woah": EmotionHype, "wow": EmotionHype, "clutch": EmotionHype, "pagman": EmotionHype,
+	"kekw": EmotionLaughter, "kek": EmotionLaughter, "lul": EmotionLaughter, "lulw": EmotionLaughter,
+	"omegalul": EmotionLaughter, "lol": EmotionLaughter, "lmao": EmotionLaughter, "lmfao": EmotionLaughter,
+	"rofl": EmotionLaughter, "icant": EmotionLaughter, "xd": EmotionLaughter, "pepelaugh": EmotionLaughter,
+	"www":   EmotionLaughter,
+	"sadge": EmotionSadness, "biblethump": EmotionSadness, "pepehands": EmotionSadness, "feelsbadman": EmotionSadness,
+	"sad": EmotionSadness, "rip": EmotionSadness, "crying": EmotionSadness, "cry": EmotionSadness,
+	"f": EmotionSadness, "nooo": EmotionSadness, "noo": EmotionSadness, "qaq": EmotionSadness,
+}
+
+// emotionPhrases 按子串匹配的中日文词语、颜文字与 emoji
+var emotionPhrases = []struct {
+	phrase  string
+	emotion string
+}{
+	{"卧槽", EmotionHype}, {"我草", EmotionHype}, {"牛逼", EmotionHype}, {"太强", EmotionHype},
+	{"好强", EmotionHype}, {"神了", EmotionHype}, {"燃", EmotionHype}, {"冲", EmotionHype}, {"すごい", EmotionHype},
+	{"やば", EmotionHype}, {"うおお", EmotionHype}, {"🔥", EmotionHype}, {"!!!", EmotionHype}, {"！！！", EmotionHype},
+	{"哈哈", EmotionLaughter}, {"笑死", EmotionLaughter}, {"233", EmotionLaughter}, {"xswl", EmotionLaughter},
+	{"草", EmotionLaughter}, {"笑", EmotionLaughter}, {"ｗｗ", EmotionLaughter}, {"😂", EmotionLaughter}, {"🤣", EmotionLaughter},
+	{"哭", EmotionSadness}, {"泪目", EmotionSadness}, {"呜呜", EmotionSadness}, {"难过", EmotionSadness}, {"心疼", EmotionSadness},
+	{"悲", EmotionSadness}, {"つらい", EmotionSadness}, {"t_t", EmotionSadness}, {"😭", EmotionSadness}, {"😢", EmotionSadness},
+	{":(", EmotionSadness},
+}
+
+// classifyChatEmotion 按词典为弹幕分类，命中最多的情绪胜出，没有命中时返回空
+// 笑声（hahaha、wwww）与拉长的 no 单独识别
+func classifyChatEmotion(text string) string {
+	lower := strings.ToLower(text)
+	hits := map[string]int{}
+
+	for _, word := range strings.FieldsFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		if emotion, ok := emotionWords[word]; ok {
+			hits[emotion]++
+			continue
+		}
+		switch {
+		case isLaughterWord(word):
+			hits[EmotionLaughter]++
+		case len(word) >= 4 && strings.HasPrefix(word, "no") && strings.Trim(word[1:], "o") == "":
+			hits[EmotionSadness]++
+		}
+	}
+	for _, p := range emotionPhrases {
+		if strings.Contains(lower, p.phrase) {
+			hits[p.emotion]++
+		}
+	}
+
+	best, bestHits := "", 0
+	for _, emotion := range []string{EmotionLaughter, EmotionHype, EmotionSadness} {
+		if hits[emotion] > bestHits {
+			best, bestHits = emotion, hits[emotion]
+		}
+	}
+	return best
+}
+
+// isLaughterWord 识别 haha、hahaha、jaja、wwww 等笑声
+func isLaughterWord(word string) bool {
+	if len(word) >= 3 && strings.Trim(word, "w") == "" {
+		return true
+	}
+	if len(word) < 4 {
+		return false
+	}
+	trimmed := strings.NewReplacer("ha", "", "ja", "", "he", "").Replace(word)
+	return trimmed == "" || trimmed == "h" || trimmed == "a"
+}
+
+// EmotionBreakdown 一段时间内弹幕的情绪分布
+type EmotionBreakdown struct {
+	Messages int    `json:"messages"` // 时间段内的弹幕总数
+	Hype     int    `json:"hype"`
+	Laughter int    `json:"laughter"`
+	Sadness  int    `json:"sadness"`
+	Dominant string `json:"dominant,omitempty"` // 占比最高的情绪，没有情绪弹幕时为空
+}
+
+// emotionSecond 一秒内的弹幕数与各情绪弹幕数，缓存中只保存有弹幕的秒
+type emotionSecond struct {
+	Offset   int `json:"t"`
+	Messages int `json:"n"`
+	Hype     int `json:"hype,omitempty"`
+	Laughter int `json:"laughter,omitempty"`
+	Sadness  int `json:"sadness,omitempty"`
+}
+
+// emotionsCache 录像弹幕情绪的缓存文件，与峰值检测参数无关，聊天记录未变化时复用
+type emotionsCache struct {
+	ChatLogModTime int64           `json:"chat_log_mod_time"`
+	Seconds        []emotionSecond `json:"seconds"`
+}
+
+// classifyChatLines 按秒统计弹幕数与各情绪弹幕数
+func classifyChatLines(lines []chatLine) []emotionSecond {
+	bySecond := map[int]*emotionSecond{}
+	maxOffset := -1
+	for _, line := range lines {
+		offset := int(math.Floor(line.offset))
+		if offset < 0 {
+			continue
+		}
+		s, ok := bySecond[offset]
+		if !ok {
+			s = &emotionSecond{Offset: offset}
+			bySecond[offset] = s
+			maxOffset = max(maxOffset, offset)
+		}
+		s.Messages++
+		switch classifyChatEmotion(line.text) {
+		case EmotionHype:
+			s.Hype++
+		case EmotionLaughter:
+			s.Laughter++
+		case EmotionSadness:
+			s.Sadness++
+		}
+	}
+
+	seconds := make([]emotionSecond, 0, len(bySecond))
+	for offset := 0; offset <= maxOffset; offset++ {
+		if s, ok := bySecond[offset]; ok {
+			seconds = append(seconds, *s)
+		}
+	}
+	return seconds
+}
+
+// emotionsForVideo 返回录像按秒的弹幕情绪，优先读取 analysis_results/{videoID}/emotions.json，
+// 不存在或聊天记录已更新时重新计算并保存
+func emotionsForVideo(videoID string) ([]emotionSecond, error) {
+	logPath := chatdownload.FindTwitchLog(videoID)
+	if logPath == "" {
+		logPath = chatdownload.FindYouTubeLog(videoID)
+	}
+	if logPath == "" {
+		return nil, os.ErrNotExist
+	}
+	info, err := os.Stat(logPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath := filepath.Join(pathsafe.Join("./analysis_results", videoID), emotionsFileName)
+	if data, err := os.ReadFile(cachePath); err == nil {
+		var cache emotionsCache
+		if json.Unmarshal(data, &cache) == nil && cache.ChatLogModTime == info.ModTime().Unix() && cache.Seconds != nil {
+			return cache.Seconds, nil
+		}
+	}
+
+	lines, err := loadChatLinesForVideo(videoID)
+	if err != nil {
+		return nil, err
+	}
+	seconds := classifyChatLines(lines)
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return seconds, nil
+	}
+	data, err := json.Marshal(emotionsCache{ChatLogModTime: info.ModTime().Unix(), Seconds: seconds})
+	if err == nil {
+		err = os.WriteFile(cachePath, data, 0644)
+	}
+	if err != nil {
+		log.Printf("保存录像 %s 的弹幕情绪失败: %v", videoID, err)
+	}
+	return seconds, nil
+}
+
+// emotionBreakdown 统计 [start, end) 秒内的情绪分布，seconds 按时间升序
+func emotionBreakdown(seconds []emotionSecond, start, end float64) *EmotionBreakdown {
+	b := &EmotionBreakdown{}
+	for _, s := range seconds {
+		offset := float64(s.Offset)
+		if offset < start {
+			continue
+		}
+		if offset >= end {
+			break
+		}
+		b.Messages += s.Messages
+		b.Hype += s.Hype
+		b.Laughter += s.Laughter
+		b.Sadness += s.Sadness
+	}
+
+	best := 0
+	for _, e := range []struct {
+		name  string
+		count int
+	}{{EmotionHype, b.Hype}, {EmotionLaughter, b.Laughter}, {EmotionSadness, b.Sadness}} {
+		if e.count > best {
+			b.Dominant, best = e.name, e.count
+		}
+	}
+	return b
+}
+
+// annotateEmotions 为热点时刻附加窗口内的情绪分布，并为时间序列附加与评论密度相同窗口的各情绪弹幕数
+// 没有聊天记录时不做处理
+func annotateEmotions(videoID string, hotMoments []VodCommentData, timeSeriesData []TimeSeriesDataPoint, windowsLen int) {
+	seconds, err := emotionsForVideo(videoID)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("统计录像 %s 的弹幕情绪失败: %v", videoID, err)
+		}
+		return
+	}
+
+	for i := range hotMoments {
+		// 热点的窗口以峰值为中心，多尺度检测时使用主导尺度
+		window := float64(windowsLen)
+		if hotMoments[i].Scale > 0 {
+			window = float64(hotMoments[i].Scale)
+		}
+		offset := hotMoments[i].OffsetSeconds
+		hotMoments[i].Emotions = emotionBreakdown(seconds, offset-window/2, offset+window/2+1)
+	}
+
+	if len(timeSeriesData) == 0 {
+		return
+	}
+	// 时间序列每秒一个点，区间分析时从区间起点开始
+	start := int(timeSeriesData[0].OffsetSeconds)
+	hype := make([]float64, len(timeSeriesData))
+	laughter := make([]float64, len(timeSeriesData))
+	sadness := make([]float64, len(timeSeriesData))
+	for _, s := range seconds {
+		if i := s.Offset - start; i >= 0 && i < len(timeSeriesData) {
+			hype[i], laughter[i], sadness[i] = float64(s.Hype), float64(s.Laughter), float64(s.Sadness)
+		}
+	}
+	hype = boxSumSame(hype, windowsLen+1)
+	laughter = boxSumSame(laughter, windowsLen+1)
+	sadness = boxSumSame(sadness, windowsLen+1)
+	for i := range timeSeriesData {
+		timeSeriesData[i].Hype = hype[i]
+		timeSeriesData[i].Laughter = laughter[i]
+		timeSeriesData[i].Sadness = sadness[i]
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// clipArtifactDir 片段目录中的产物登记
+// 同一录像的片段文件名相同（录像ID_标题），并发执行的任务（批量重试、指定范围重新分析）可能正在写入或读取同名文件，
+// 因此目录中有进行中的阶段时不清理任何文件，由最后一个结束的阶段统一清理
+type clipArtifactDir struct {
+	inFlight  int             // 正在下载、识别或总结的热点数
+	persisted map[string]bool // 所属阶段已完成且处理结果已持久化的文件
+}
+
+var (
+	clipArtifactsMu sync.Mutex
+	clipArtifacts   = map[string]*clipArtifactDir{}
+)
+
+func clipArtifactDirKey(dir string) string {
+	return filepath.Clean(dir)
+}
+
+// beginClipArtifacts 登记目录中开始了一个阶段，结束前目录中的文件都不会被清理
+func beginClipArtifacts(dir string) {
+	clipArtifactsMu.Lock()
+	defer clipArtifactsMu.Unlock()
+
+	key := clipArtifactDirKey(dir)
+	state, ok := clipArtifacts[key]
+	if !ok {
+		state = &clipArtifactDir{persisted: map[string]bool{}}
+		clipArtifacts[key] = state
+	}
+	state.inFlight++
+}
+
+// finishClipArtifacts 登记阶段结束；persisted 为 true 表示处理结果已持久化，paths 中的文件可以清理，
+// 否则这些文件保留（例如结果写入失败，留待排查或重试）
+func finishClipArtifacts(dir string, persisted bool, paths ...string) {
+	clipArtifactsMu.Lock()
+	defer clipArtifactsMu.Unlock()
+
+	state, ok := clipArtifacts[clipArtifactDirKey(dir)]
+	if !ok {
+		return
+	}
+	if state.inFlight > 0 {
+		state.inFlight--
+	}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if persisted {
+			state.persisted[filepath.Clean(path)] = true
+		} else {
+			delete(state.persisted, filepath.Clean(path))
+		}
+	}
+}
+
+// clipArtifactsInFlight 目录中是否有进行中的阶段
+func clipArtifactsInFlight(dir string) bool {
+	clipArtifactsMu.Lock()
+	defer clipArtifactsMu.Unlock()
+
+	state, ok := clipArtifacts[clipArtifactDirKey(dir)]
+	return ok && state.inFlight > 0
+}
+
+// takeCleanableClipArtifacts 目录中没有进行中的阶段时，返回并移除可清理的文件登记；有进行中的阶段时返回 false
+// 调用方需在删除文件期间继续持有返回的 release，避免新阶段在删除过程中开始写入同名文件
+func takeCleanableClipArtifacts(dir string) (map[string]bool, func(), bool) {
+	clipArtifactsMu.Lock()
+
+	key := clipArtifactDirKey(dir)
+	state, ok := clipArtifacts[key]
+	if ok && state.inFlight > 0 {
+		clipArtifactsMu.Unlock()
+		return nil, nil, false
+	}
+	var cleanable map[string]bool
+	if ok {
+		cleanable = state.persisted
+		delete(clipArtifacts, key)
+	}
+	return cleanable, clipArtifactsMu.Unlock, true
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"subtuber-services/pathsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 片段文件的下载格式
+const (
+	clipFormatVideo    = "mp4"
+	clipFormatSubtitle = "srt"
+)
+
+// ClipFileInfo 热点片段及其字幕的下载信息
+type ClipFileInfo struct {
+	ID              string    `json:"id"` // 热点偏移取整后的秒数，用于下载地址
+	HotMomentOffset float64   `json:"hot_moment_offset"`
+	FormattedTime   string    `json:"formatted_time"`
+	VODStart        float64   `json:"vod_start"` // 片段第 0 秒对应的录像偏移（秒）
+	Duration        float64   `json:"duration"`
+	File            string    `json:"file"`
+	Size            int64     `json:"size,omitempty"`
+	Storage         string    `json:"storage"` // local：本地文件；cold：已移到对象存储，下载时重定向到签名链接
+	CreatedAt       time.Time `json:"created_at"`
+	DownloadURL     string    `json:"download_url"`
+	SubtitleURL     string    `json:"subtitle_url,omitempty"`  // 有字幕时提供
+	ThumbnailURL    string    `json:"thumbnail_url,omitempty"` // 已生成封面帧时提供
+	PreviewURL      string    `json:"preview_url,omitempty"`   // 已生成动态预览时提供
+}
+
+// clipID 片段在下载地址中的标识
+func clipID(hotMomentOffset float64) string {
+	return fmt.Sprintf("%.0f", hotMomentOffset)
+}
+
+// clipFilePath 片段的本地路径
+func clipFilePath(videoID string, clip ClipSyncInfo) string {
+	return filepath.Join(pathsafe.Join(hotClipsDir, videoID), filepath.Base(clip.ClipFile))
+}
+
+// clipSubtitlePath 片段字幕的路径：优先使用片段旁的 .srt，片段目录已清理时使用复制到 analysis_results 的字幕
+// 两者都不存在时返回空字符串
+func clipSubtitlePath(videoID string, clip ClipSyncInfo) string {
+	beside := strings.TrimSuffix(clipFilePath(videoID, clip), filepath.Ext(clip.ClipFile)) + ".srt"
+	copied := transcriptPathForRun(videoID, ClipRunRecord{StartTime: clip.RequestedStart})
+	for _, path := range []string{beside, copied} {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// findClip 按片段标识查找片段
+func findClip(videoID, id string) (ClipSyncInfo, bool, error) {
+	offset, err := strconv.ParseFloat(id, 64)
+	if err != nil {
+		return ClipSyncInfo{}, false, nil
+	}
+	clips, err := loadClipSync(videoID)
+	if err != nil {
+		return ClipSyncInfo{}, false, err
+	}
+	for _, clip := range clips {
+		if clip.ClipFile != "" && math.Abs(clip.HotMomentOffset-offset) < 1 {
+			return clip, true, nil
+		}
+	}
+	return ClipSyncInfo{}, false, nil
+}
+
+// ListClips 列出录像已生成的热点片段（不含已下架的片段），片段已清理且不在冷存储中的不列出
+// GET /api/clips/:videoID
+func ListClips(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+
+	clips, err := loadClipSync(videoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取片段信息失败: " + err.Error()})
+		return
+	}
+
+	base := strings.TrimSuffix(c.Request.URL.Path, "/")
+	items := make([]ClipFileInfo, 0, len(clips))
+	for _, clip := range clips {
+		if clip.ClipFile == "" || IsTakenDown(ArtifactClip, videoID, clip.HotMomentOffset) {
+			continue
+		}
+		item := ClipFileInfo{
+			ID:              clipID(clip.HotMomentOffset),
+			HotMomentOffset: clip.HotMomentOffset,
+			FormattedTime:   formatDuration(clip.HotMomentOffset),
+			VODStart:        clip.VODStart,
+			Duration:        clip.Duration,
+			File:            filepath.Base(clip.ClipFile),
+			CreatedAt:       clip.CreatedAt,
+		}
+		if info, err := os.Stat(clipFilePath(videoID, clip)); err == nil {
+			item.Storage = "local"
+			item.Size = info.Size()
+		} else if cold, ok := lookupColdClip(videoID, clip.ClipFile); ok {
+			item.Storage = "cold"
+			item.Size = cold.Size
+		} else {
+			continue
+		}
+		item.DownloadURL = base + "/" + item.ID + "/download"
+		if clipSubtitlePath(videoID, clip) != "" {
+			item.SubtitleURL = item.DownloadURL + "?format=" + clipFormatSubtitle
+		}
+		if clip.Thumbnail != "" {
+			item.ThumbnailURL = base + "/" + item.ID + "/thumbnail"
+		}
+		if clip.Preview != "" {
+			item.PreviewURL = base + "/" + item.ID + "/thumbnail?animated=true"
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id": videoID,
+		"clips":    items,
+		"count":    len(items),
+	})
+}
+
+// DownloadClip 下载热点片段（format=mp4，默认）或字幕（format=srt），支持 Range 请求以便直接在页面中播放
+// attachment=true 时以附件形式下载；已移到冷存储的片段重定向到限时签名链接
+// GET /api/clips/:videoID/:clipID/download
+func DownloadClip(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+	format := c.DefaultQuery("format", clipFormatVideo)
+	if format != clipFormatVideo && format != clipFormatSubtitle {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 只能是 mp4 或 srt"})
+		return
+	}
+
+	clip, ok, err := findClip(videoID, c.Param("clipID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取片段信息失败: " + err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "片段不存在"})
+		return
+	}
+	if IsTakenDown(ArtifactClip, videoID, clip.HotMomentOffset) {
+		c.JSON(http.StatusGone, gin.H{"error": "该片段因违规举报已下架"})
+		return
+	}
+
+	path := clipFilePath(videoID, clip)
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if format == clipFormatSubtitle {
+		path = clipSubtitlePath(videoID, clip)
+		contentType = "application/x-subrip; charset=utf-8"
+		if path == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "该片段没有字幕"})
+			return
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && format == clipFormatVideo {
+			if signedURL := coldClipURL(videoID, clip.ClipFile); signedURL != "" {
+				// 对象存储同样支持 Range 请求，重定向本身不缓存
+				c.Header("Cache-Control", "no-store")
+				c.Redirect(http.StatusFound, signedURL)
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "片段文件不存在"})
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "片段文件不存在"})
+		return
+	}
+
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	disposition := "inline"
+	if c.Query("attachment") == "true" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filepath.Base(path)}))
+	c.Header("Cache-Control", "private, max-age=3600")
+	// ServeContent 处理 Range / If-Range / If-Modified-Since，返回 206 分段内容
+	http.ServeContent(c.Writer, c.Request, filepath.Base(path), info.ModTime(), f)
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"subtuber-services/pathsafe"
+)
+
+// 片段元数据的长度限制，超出时截断
+const (
+	clipMetadataTitleMaxRunes       = 60
+	clipMetadataDescriptionMaxRunes = 200
+	clipMetadataMaxHashtags         = 5
+)
+
+// clipMetadataPrompt 根据热点总结生成片段标题、话题标签与简介的提示词，输出格式固定为 JSON，因此不提供模板配置
+const clipMetadataPrompt = "You are writing metadata for a short clip from {{.Streamer}}'s live stream" +
+	"{{if .Title}} \"{{.Title}}\"{{end}}{{if .Game}} while playing {{.Game}}{{end}}, taken at {{.ClipTime}}.\n" +
+	"Based on the summary below, write in {{.Language}}: a short catchy clip title (at most 60 characters), " +
+	"3 to 5 hashtags, and a one-line description.\n" +
+	"Respond with JSON only, for example {\"title\": \"...\", \"hashtags\": [\"#tag\"], \"description\": \"...\"}.\n\n" +
+	"Summary:\n"
+
+// ClipMetadata 热点总结生成后由AI生成的片段标题、话题标签与简介，保存在分析结果的热点中
+type ClipMetadata struct {
+	Title       string    `json:"title"`
+	Hashtags    []string  `json:"hashtags,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Provider    string    `json:"provider,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// generateClipMetadata 让AI根据热点总结生成片段元数据；提示词变量与输出语言从 ctx 读取，与总结相同
+func generateClipMetadata(ctx context.Context, aiService AIService, summary string) (ClipMetadata, error) {
+	prompt, err := renderPrompt(clipMetadataPrompt, summaryPromptVarsFromContext(ctx))
+	if err != nil {
+		return ClipMetadata{}, err
+	}
+	text, err := aiService.GenerateContent(ctx, prompt+summary, 300)
+	if err != nil {
+		return ClipMetadata{}, err
+	}
+
+	var result struct {
+		Title       string   `json:"title"`
+		Hashtags    []string `json:"hashtags"`
+		Description string   `json:"description"`
+	}
+	if err := json.Unmarshal([]byte(summaryEvalJSONRe.FindString(text)), &result); err != nil {
+		return ClipMetadata{}, fmt.Errorf("无法解析片段元数据: %q", text)
+	}
+	title := truncateWebhookText(result.Title, clipMetadataTitleMaxRunes)
+	if title == "" {
+		return ClipMetadata{}, fmt.Errorf("片段元数据缺少标题: %q", text)
+	}
+
+	metadata := ClipMetadata{
+		Title:       title,
+		Description: truncateWebhookText(result.Description, clipMetadataDescriptionMaxRunes),
+		GeneratedAt: time.Now(),
+	}
+	for _, tag := range result.Hashtags {
+		// 统一为 #标签 的形式，去掉空白与重复
+		tag = strings.Join(strings.Fields(strings.TrimLeft(strings.TrimSpace(tag), "#")), "")
+		if tag == "" || containsFold(metadata.Hashtags, "#"+tag) {
+			continue
+		}
+		metadata.Hashtags = append(metadata.Hashtags, "#"+tag)
+		if len(metadata.Hashtags) == clipMetadataMaxHashtags {
+			break
+		}
+	}
+	return metadata, nil
+}
+
+// recordClipMetadata 为刚生成总结的热点生成片段元数据并写入录像的分析结果
+// 生成失败只记录日志，不影响总结本身
+func recordClipMetadata(ctx context.Context, aiService AIService, provider, videoID string, offsetSeconds float64, summary string) {
+	if !IsFeatureEnabled(FeatureClipMetadata) || strings.TrimSpace(summary) == "" {
+		return
+	}
+
+	ctx, span := startPipelineSpan(ctx, "clip_metadata", videoID)
+	metadata, err := generateClipMetadata(ctx, aiService, summary)
+	endSpan(span, err)
+	if err != nil {
+		log.Printf("热点 %.0f 秒的片段元数据生成失败: %v", offsetSeconds, err)
+		return
+	}
+	metadata.Provider, metadata.Model = aiProviderName(provider), aiModelName(provider)
+
+	updated, err := updateHotMomentInResults(videoID, offsetSeconds, func(moment *VodCommentData) {
+		moment.Metadata = &metadata
+	})
+	if err != nil {
+		log.Printf("保存热点 %.0f 秒的片段元数据失败: %v", offsetSeconds, err)
+		return
+	}
+	log.Printf("热点 %.0f 秒的片段元数据已写入 %d 个分析结果: %s %s", offsetSeconds, updated, metadata.Title, strings.Join(metadata.Hashtags, " "))
+}
+
+// analysisResultsMu 串行化对已保存分析结果的修改，避免同一录像的多个热点同时改写文件
+var analysisResultsMu sync.Mutex
+
+// updateHotMomentInResults 修改录像所有分析结果中与偏移对应（相差不到 1 秒）的热点，返回修改的分析结果数
+func updateHotMomentInResults(videoID string, offsetSeconds float64, update func(moment *VodCommentData)) (int, error) {
+	analysisResultsMu.Lock()
+	defer analysisResultsMu.Unlock()
+
+	files, err := listAnalysisResultFiles(videoID)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, file := range files {
+		data, err := readAnalysisResultData(videoID, file)
+		if err != nil {
+			return updated, err
+		}
+		var result AnalysisResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return updated, fmt.Errorf("解析分析结果 %s 失败: %w", file, err)
+		}
+
+		changed := false
+		for i := range result.HotMoments {
+			if math.Abs(result.HotMoments[i].OffsetSeconds-offsetSeconds) < 1 {
+				update(&result.HotMoments[i])
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		data, err = json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return updated, fmt.Errorf("序列化失败: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(pathsafe.Join("./analysis_results", videoID), file), data, 0644); err != nil {
+			return updated, fmt.Errorf("写入文件失败: %w", err)
+		}
+		indexAnalysisResult(videoID, file, result, data)
+		updated++
+	}
+	return updated, nil
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"subtuber-services/pathsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+const clipPreviewsDirName = "previews"
+
+// 预览图的默认尺寸与动态预览时长
+const (
+	defaultPreviewWidth           = 640
+	defaultPreviewAnimatedSeconds = 3
+	maxPreviewAnimatedSeconds     = 10
+)
+
+// ClipPreviewSettings holds the size of the frame extracted from each clip and whether an animated preview is generated
+type ClipPreviewSettings struct {
+	Width           int     `mapstructure:"width" json:"width"`                       // 封面帧宽度（像素），高度按比例缩放，默认 640；动态预览为一半
+	Animated        bool    `mapstructure:"animated" json:"animated"`                 // 同时生成动态 WebP 预览（需要 ffmpeg 支持 libwebp）
+	AnimatedSeconds float64 `mapstructure:"animated_seconds" json:"animated_seconds"` // 动态预览时长，以热点为中心，默认 3 秒
+}
+
+// Validate checks the preview width and the animated preview length
+func (s ClipPreviewSettings) Validate() error {
+	if s.Width < 0 || s.AnimatedSeconds < 0 {
+		return fmt.Errorf("预览图宽度与动态预览时长不能为负数")
+	}
+	if s.AnimatedSeconds > maxPreviewAnimatedSeconds {
+		return fmt.Errorf("动态预览时长不能超过 %d 秒", maxPreviewAnimatedSeconds)
+	}
+	return nil
+}
+
+func (s ClipPreviewSettings) width() int {
+	if s.Width <= 0 {
+		return defaultPreviewWidth
+	}
+	return s.Width
+}
+
+func (s ClipPreviewSettings) animatedSeconds() float64 {
+	if s.AnimatedSeconds <= 0 {
+		return defaultPreviewAnimatedSeconds
+	}
+	return s.AnimatedSeconds
+}
+
+// clipPreviewsDir 录像预览图所在目录，随分析结果一起保留，不受片段移到冷存储影响
+func clipPreviewsDir(videoID string) string {
+	return filepath.Join(pathsafe.Join("./analysis_results", videoID), clipPreviewsDirName)
+}
+
+// clipPreviewURL 预览图的访问地址，animated 为 true 时为动态预览
+func clipPreviewURL(videoID string, hotMomentOffset float64, animated bool) string {
+	u := APIV1Prefix + "/clips/" + url.PathEscape(videoID) + "/" + clipID(hotMomentOffset) + "/thumbnail"
+	if animated {
+		u += "?animated=true"
+	}
+	return u
+}
+
+// generateClipPreviews 从片段中提取热点时刻的封面帧（以及开启时的动态 WebP 预览），并记录到片段同步信息
+// 预览只是展示用途，失败时只记录日志，不影响片段处理结果
+func generateClipPreviews(ctx context.Context, videoID string, clip ClipSyncInfo, clipPath string) ClipSyncInfo {
+	settings := GetPipelineConfig().Previews
+	dir := clipPreviewsDir(videoID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("创建预览图目录失败: %v", err)
+		return clip
+	}
+
+	// 热点在片段中的位置，限制在片段范围内
+	at := clip.HotMomentOffset - clip.VODStart
+	if clip.Duration > 0 {
+		at = math.Min(math.Max(at, 0), math.Max(clip.Duration-0.5, 0))
+	} else {
+		at = math.Max(at, 0)
+	}
+
+	release, err := acquireStageSlot(ctx, pipelineStageFFmpeg)
+	if err != nil {
+		return clip
+	}
+	defer release()
+
+	base := fmt.Sprintf("%s_%.0f", pathsafe.Name(videoID), clip.HotMomentOffset)
+	thumbnail := base + ".jpg"
+	err = exec.CommandContext(ctx, "ffmpeg", "-v", "error",
+		"-ss", fmt.Sprintf("%.2f", at), "-i", clipPath,
+		"-frames:v", "1", "-vf", fmt.Sprintf("scale=%d:-2", settings.width()), "-q:v", "3",
+		"-y", filepath.Join(dir, thumbnail)).Run()
+	if err != nil {
+		log.Printf("提取热点 %.0f 的封面帧失败: %v", clip.HotMomentOffset, err)
+		return clip
+	}
+	clip.Thumbnail = thumbnail
+
+	if settings.Animated {
+		seconds := settings.animatedSeconds()
+		preview := base + ".webp"
+		err = exec.CommandContext(ctx, "ffmpeg", "-v", "error",
+			"-ss", fmt.Sprintf("%.2f", math.Max(at-seconds/2, 0)), "-t", fmt.Sprintf("%.2f", seconds), "-i", clipPath,
+			"-vf", fmt.Sprintf("fps=10,scale=%d:-2", settings.width()/2), "-an",
+			"-c:v", "libwebp", "-loop", "0", "-quality", "60",
+			"-y", filepath.Join(dir, preview)).Run()
+		if err != nil {
+			log.Printf("生成热点 %.0f 的动态预览失败: %v", clip.HotMomentOffset, err)
+		} else {
+			clip.Preview = preview
+		}
+	}
+
+	if err := saveClipSync(videoID, clip); err != nil {
+		log.Printf("保存片段预览信息失败: %v", err)
+	}
+	return clip
+}
+
+// attachClipPreviews 为分析结果中的热点填充预览图地址，已下架的片段不提供预览
+func attachClipPreviews(videoID string, moments []VodCommentData) {
+	clips, err := loadClipSync(videoID)
+	if err != nil || len(clips) == 0 {
+		return
+	}
+	for i := range moments {
+		for _, clip := range clips {
+			if math.Abs(clip.HotMomentOffset-moments[i].OffsetSeconds) >= 1 {
+				continue
+			}
+			if clip.Thumbnail != "" && !IsTakenDown(ArtifactClip, videoID, clip.HotMomentOffset) {
+				moments[i].ThumbnailURL = clipPreviewURL(videoID, clip.HotMomentOffset, false)
+				if clip.Preview != "" {
+					moments[i].PreviewURL = clipPreviewURL(videoID, clip.HotMomentOffset, true)
+				}
+			}
+			break
+		}
+	}
+}
+
+// GetClipThumbnail 获取片段的封面帧（animated=true 时为动态 WebP 预览）
+// GET /api/clips/:videoID/:clipID/thumbnail
+func GetClipThumbnail(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+	clip, ok, err := findClip(videoID, c.Param("clipID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取片段信息失败: " + err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "片段不存在"})
+		return
+	}
+	if IsTakenDown(ArtifactClip, videoID, clip.HotMomentOffset) {
+		c.JSON(http.StatusGone, gin.H{"error": "该片段因违规举报已下架"})
+		return
+	}
+
+	file := clip.Thumbnail
+	if c.Query("animated") == "true" {
+		file = clip.Preview
+	}
+	if file == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该片段没有预览图"})
+		return
+	}
+	path := filepath.Join(clipPreviewsDir(videoID), filepath.Base(file))
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "预览图文件不存在"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.File(path)
+}
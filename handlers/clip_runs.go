@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"subtuber-services/pathsafe"
+)
+
+const clipRunsFileName = "clip_runs.json"
+
+// 热点片段各阶段的执行状态
+const (
+	clipRunOK       = "ok"
+	clipRunFailed   = "failed"
+	clipRunSkipped  = "skipped"
+	clipRunDeferred = "deferred" // AI 服务繁忙，已放入延后队列稍后重试
+	// clipRunDependencyUnavailable ffmpeg 或语音识别不可用而跳过，依赖恢复后由延后队列自动重新处理
+	clipRunDependencyUnavailable = "skipped: dependency unavailable"
+)
+
+// ClipRunRecord 单个热点片段最近一次处理的结果
+type ClipRunRecord struct {
+	HotMomentOffset float64   `json:"hot_moment_offset"`
+	StartTime       float64   `json:"start_time"`
+	Interval        float64   `json:"interval"`
+	ClipStatus      string    `json:"clip_status"`
+	ASRStatus       string    `json:"asr_status,omitempty"`
+	SummaryStatus   string    `json:"summary_status,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// failed 语音识别或AI总结阶段是否失败
+func (r ClipRunRecord) failed() bool {
+	return r.ASRStatus == clipRunFailed || r.SummaryStatus == clipRunFailed
+}
+
+// waitingForDependency 是否有阶段因依赖不可用而跳过
+func (r ClipRunRecord) waitingForDependency() bool {
+	return r.ClipStatus == clipRunDependencyUnavailable || r.ASRStatus == clipRunDependencyUnavailable ||
+		r.SummaryStatus == clipRunDependencyUnavailable
+}
+
+// SummaryRetryItem 批量重试中的一个热点
+type SummaryRetryItem struct {
+	VideoID         string  `json:"video_id"`
+	HotMomentOffset float64 `json:"hot_moment_offset"`
+	Mode            string  `json:"mode"`   // summary_only：复用已有字幕；full：重新下载片段并识别
+	Status          string  `json:"status"` // queued / ok / failed
+	Error           string  `json:"error,omitempty"`
+}
+
+// SummaryRetryBatch 一次批量重试的结果
+type SummaryRetryBatch struct {
+	ID         string             `json:"id"`
+	CreatedAt  time.Time          `json:"created_at"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+	Items      []SummaryRetryItem `json:"items"`
+}
+
+var (
+	clipRunsMu sync.Mutex
+
+	summaryRetryMu      sync.Mutex
+	summaryRetryBatches = map[string]*SummaryRetryBatch{}
+)
+
+// recordClipRun 写入或替换同一热点的处理结果，返回写入失败的错误（已记录日志）
+func recordClipRun(videoID string, run ClipRunRecord) error {
+	clipRunsMu.Lock()
+	defer clipRunsMu.Unlock()
+
+	runs, err := loadClipRuns(videoID)
+	if err != nil {
+		log.Printf("读取片段处理记录失败: %v", err)
+		return err
+	}
+
+	run.UpdatedAt = time.Now()
+	replaced := false
+	for i := range runs {
+		if math.Abs(runs[i].HotMomentOffset-run.HotMomentOffset) < 1 {
+			runs[i] = run
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].HotMomentOffset < runs[j].HotMomentOffset })
+
+	videoDir := pathsafe.Join("./analysis_results", videoID)
+	if err := os.MkdirAll(videoDir, 0755); err != nil {
+		log.Printf("创建分析目录失败: %v", err)
+		return err
+	}
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(videoDir, clipRunsFileName), data, 0644); err != nil {
+		log.Printf("保存片段处理记录失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// setClipSummaryStatus 更新已有处理记录中热点的AI总结状态
+func setClipSummaryStatus(videoID string, offsetSeconds float64, status, errMsg string) {
+	clipRunsMu.Lock()
+	runs, err := loadClipRuns(videoID)
+	clipRunsMu.Unlock()
+	if err != nil {
+		log.Printf("读取片段处理记录失败: %v", err)
+		return
+	}
+	for _, run := range runs {
+		if math.Abs(run.HotMomentOffset-offsetSeconds) < 1 {
+			run.SummaryStatus, run.Error = status, errMsg
+			recordClipRun(videoID, run)
+			return
+		}
+	}
+}
+
+// loadClipRuns 读取视频的片段处理记录
+func loadClipRuns(videoID string) ([]ClipRunRecord, error) {
+	data, err := os.ReadFile(filepath.Join(pathsafe.Join("./analysis_results", videoID), clipRunsFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var runs []ClipRunRecord
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// transcriptPathForRun 返回片段已保存的字幕路径（由 VOD 下载器复制到 analysis_results）
+func transcriptPathForRun(videoID string, run ClipRunRecord) string {
+	return filepath.Join(pathsafe.Join("./analysis_results", videoID), fmt.Sprintf("%s_%.0f.srt", pathsafe.Name(videoID), run.StartTime))
+}
+
+// RetryFailedSummaries 扫描片段处理记录，重新执行失败的语音识别/AI总结阶段
+// 已有字幕的热点只重新总结，否则重新下载片段并识别；videoID 为空时扫描全部视频
+func RetryFailedSummaries(videoID string) (*SummaryRetryBatch, error) {
+	videoIDs := []string{videoID}
+	if videoID == "" {
+		entries, err := os.ReadDir("./analysis_results")
+		if err != nil {
+			if os.IsNotExist(err) {
+				entries = nil
+			} else {
+				return nil, err
+			}
+		}
+		videoIDs = videoIDs[:0]
+		for _, entry := range entries {
+			if entry.IsDir() {
+				videoIDs = append(videoIDs, entry.Name())
+			}
+		}
+	}
+
+	batch := &SummaryRetryBatch{
+		ID:        fmt.Sprintf("retry-%d", time.Now().UnixNano()),
+		CreatedAt: time.Now(),
+	}
+	fullRuns := map[string][]ClipRunRecord{}
+
+	for _, id := range videoIDs {
+		clipRunsMu.Lock()
+		runs, err := loadClipRuns(id)
+		clipRunsMu.Unlock()
+		if err != nil {
+			log.Printf("读取视频 %s 的片段处理记录失败: %v", id, err)
+			continue
+		}
+		for _, run := range runs {
+			if !run.failed() {
+				continue
+			}
+			item := SummaryRetryItem{VideoID: id, HotMomentOffset: run.HotMomentOffset, Mode: "summary_only", Status: "queued"}
+			if info, err := os.Stat(transcriptPathForRun(id, run)); err != nil || info.Size() == 0 {
+				item.Mode = "full"
+				fullRuns[id] = append(fullRuns[id], run)
+			}
+			batch.Items = append(batch.Items, item)
+		}
+	}
+
+	if len(batch.Items) == 0 {
+		now := time.Now()
+		batch.FinishedAt = &now
+	}
+
+	summaryRetryMu.Lock()
+	summaryRetryBatches[batch.ID] = batch
+	summaryRetryMu.Unlock()
+
+	if len(batch.Items) > 0 {
+		go runSummaryRetryBatch(batch, fullRuns)
+	}
+
+	return snapshotSummaryRetryBatch(batch), nil
+}
+
+// runSummaryRetryBatch 依次执行批量重试，并更新各项结果
+func runSummaryRetryBatch(batch *SummaryRetryBatch, fullRuns map[string][]ClipRunRecord) {
+	ctx := context.Background()
+
+	for i := range batch.Items {
+		summaryRetryMu.Lock()
+		item := batch.Items[i]
+		summaryRetryMu.Unlock()
+		if item.Mode != "summary_only" {
+			continue
+		}
+
+		err := retryClipSummary(ctx, item.VideoID, item.HotMomentOffset)
+		summaryRetryMu.Lock()
+		if err != nil {
+			batch.Items[i].Status, batch.Items[i].Error = "failed", err.Error()
+		} else {
+			batch.Items[i].Status = "ok"
+		}
+		summaryRetryMu.Unlock()
+	}
+
+	// 没有字幕的热点重新走完整流程（下载、识别、总结），每个视频一次
+	for videoID, runs := range fullRuns {
+		hotMoments := make([]VodCommentData, 0, len(runs))
+		interval := runs[0].Interval
+		for _, run := range runs {
+			hotMoments = append(hotMoments, VodCommentData{OffsetSeconds: run.HotMomentOffset})
+		}
+		runHotMomentClips(videoID, hotMoments, interval)
+
+		clipRunsMu.Lock()
+		latest, _ := loadClipRuns(videoID)
+		clipRunsMu.Unlock()
+
+		summaryRetryMu.Lock()
+		for i := range batch.Items {
+			item := &batch.Items[i]
+			if item.VideoID != videoID || item.Mode != "full" {
+				continue
+			}
+			item.Status, item.Error = "failed", "未找到处理记录"
+			for _, run := range latest {
+				if math.Abs(run.HotMomentOffset-item.HotMomentOffset) < 1 {
+					if run.failed() || run.ClipStatus == clipRunFailed {
+						item.Error = run.Error
+					} else {
+						item.Status, item.Error = "ok", ""
+					}
+					break
+				}
+			}
+		}
+		summaryRetryMu.Unlock()
+	}
+
+	summaryRetryMu.Lock()
+	now := time.Now()
+	batch.FinishedAt = &now
+	ok, failed := 0, 0
+	for _, item := range batch.Items {
+		if item.Status == "ok" {
+			ok++
+		} else {
+			failed++
+		}
+	}
+	summaryRetryMu.Unlock()
+
+	log.Printf("批量重试 %s 完成: 成功 %d 个，失败 %d 个", batch.ID, ok, failed)
+}
+
+// retryClipSummary 使用已保存的字幕重新执行AI总结
+func retryClipSummary(ctx context.Context, videoID string, offsetSeconds float64) error {
+	clipRunsMu.Lock()
+	runs, err := loadClipRuns(videoID)
+	clipRunsMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		if math.Abs(run.HotMomentOffset-offsetSeconds) >= 1 {
+			continue
+		}
+		srtContent, err := os.ReadFile(transcriptPathForRun(videoID, run))
+		if err != nil {
+			return fmt.Errorf("读取字幕失败: %v", err)
+		}
+
+		run.ASRStatus = clipRunOK
+		if err := summarizeHotMomentTranscript(ctx, videoID, offsetSeconds, string(srtContent)); err != nil {
+			run.SummaryStatus, run.Error = clipRunFailed, err.Error()
+			recordClipRun(videoID, run)
+			return err
+		}
+		run.SummaryStatus, run.Error = clipRunOK, ""
+		recordClipRun(videoID, run)
+		return nil
+	}
+	return fmt.Errorf("未找到处理记录")
+}
+
+// GetSummaryRetryBatch 返回批量重试的当前结果
+func GetSummaryRetryBatch(id string) (*SummaryRetryBatch, bool) {
+	summaryRetryMu.Lock()
+	batch, ok := summaryRetryBatches[id]
+	summaryRetryMu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return snapshotSummaryRetryBatch(batch), true
+}
+
+// snapshotSummaryRetryBatch 复制批量结果，避免与后台执行并发读写
+func snapshotSummaryRetryBatch(batch *SummaryRetryBatch) *SummaryRetryBatch {
+	summaryRetryMu.Lock()
+	defer summaryRetryMu.Unlock()
+
+	snapshot := *batch
+	snapshot.Items = append([]SummaryRetryItem(nil), batch.Items...)
+	return &snapshot
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"subtuber-services/models"
+	"subtuber-services/pathsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+const clipSyncFileName = "clip_sync.json"
+
+// ClipSyncInfo 片段本地时间与录像时间的对应关系
+// 录像时间 = 片段时间 + VODStart
+type ClipSyncInfo struct {
+	HotMomentOffset   float64   `json:"hot_moment_offset"`  // 对应热点在录像中的偏移（秒）
+	RequestedStart    float64   `json:"requested_start"`    // 请求裁剪的开始时间（秒）
+	RequestedDuration float64   `json:"requested_duration"` // 请求裁剪的时长（秒）
+	VODStart          float64   `json:"vod_start"`          // 片段第 0 秒对应的录像偏移（已按实际裁剪修正）
+	Duration          float64   `json:"duration"`           // 片段实际时长（秒）
+	ClipFile          string    `json:"clip_file,omitempty"`
+	Thumbnail         string    `json:"thumbnail,omitempty"` // 封面帧文件名，位于 analysis_results/{videoID}/previews
+	Preview           string    `json:"preview,omitempty"`   // 动态 WebP 预览文件名，未开启时为空
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// ClipChatMessage 按片段时间对齐的聊天消息
+type ClipChatMessage struct {
+	ClipTime  float64 `json:"clip_time"`
+	VODOffset float64 `json:"vod_offset"`
+	Author    string  `json:"author"`
+	Message   string  `json:"message"`
+}
+
+var clipSyncMu sync.Mutex
+
+// recordClipSync 记录片段与录像的时间对应关系
+// 流复制裁剪会从开始时间之前的关键帧开始，实际片段比请求的更长，多出的部分即开头提前量
+func recordClipSync(videoID string, hotMomentOffset, startTime, duration float64, clipPath string) ClipSyncInfo {
+	info := ClipSyncInfo{
+		HotMomentOffset:   hotMomentOffset,
+		RequestedStart:    startTime,
+		RequestedDuration: duration,
+		VODStart:          startTime,
+		Duration:          duration,
+		ClipFile:          filepath.Base(clipPath),
+		CreatedAt:         time.Now(),
+	}
+
+	if actual, err := probeMediaDuration(clipPath); err != nil {
+		log.Printf("读取片段时长失败，使用请求的裁剪范围: %v", err)
+	} else if actual > 0 {
+		info.Duration = actual
+		if lead := actual - duration; lead > 0 {
+			info.VODStart = math.Max(startTime-lead, 0)
+		}
+	}
+
+	if err := saveClipSync(videoID, info); err != nil {
+		log.Printf("保存片段同步信息失败: %v", err)
+	}
+	return info
+}
+
+// probeMediaDuration 使用 ffprobe 读取媒体文件时长（秒）
+func probeMediaDuration(path string) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "ffprobe", "-v", "error",
+		"-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path).Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+}
+
+// saveClipSync 写入或替换同一热点的同步信息
+func saveClipSync(videoID string, info ClipSyncInfo) error {
+	clipSyncMu.Lock()
+	defer clipSyncMu.Unlock()
+
+	clips, err := loadClipSync(videoID)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range clips {
+		if math.Abs(clips[i].HotMomentOffset-info.HotMomentOffset) < 1 {
+			clips[i] = info
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		clips = append(clips, info)
+	}
+	sort.Slice(clips, func(i, j int) bool { return clips[i].HotMomentOffset < clips[j].HotMomentOffset })
+
+	videoDir := pathsafe.Join("./analysis_results", videoID)
+	if err := os.MkdirAll(videoDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(clips, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(videoDir, clipSyncFileName), data, 0644)
+}
+
+// loadClipSync 读取视频的所有片段同步信息
+func loadClipSync(videoID string) ([]ClipSyncInfo, error) {
+	data, err := os.ReadFile(filepath.Join(pathsafe.Join("./analysis_results", videoID), clipSyncFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var clips []ClipSyncInfo
+	if err := json.Unmarshal(data, &clips); err != nil {
+		return nil, err
+	}
+	return clips, nil
+}
+
+// loadClipChat 读取片段时间范围内的聊天消息，并换算为片段时间（已扣除校准的聊天时间偏差）
+func loadClipChat(videoID string, info ClipSyncInfo) ([]ClipChatMessage, error) {
+	start, end := info.VODStart, info.VODStart+info.Duration
+	drift := GetVODDriftCorrection(videoID)
+	messages := []ClipChatMessage{}
+	add := func(offset float64, author, message string) {
+		videoTime := offset - drift
+		if videoTime < start || videoTime > end {
+			return
+		}
+		messages = append(messages, ClipChatMessage{
+			ClipTime:  videoTime - info.VODStart,
+			VODOffset: offset,
+			Author:    author,
+			Message:   message,
+		})
+	}
+
+	if err := eachChatMessage(videoID, func(msg models.ChatMessage) {
+		add(msg.OffsetSeconds, msg.Author, msg.Text)
+	}); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// GetClipSync 返回视频片段的时间对应关系
+// 指定 offset（热点偏移）时只返回该片段，并附带按片段时间对齐的聊天消息
+func GetClipSync(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+
+	clips, err := loadClipSync(videoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取片段同步信息失败: " + err.Error()})
+		return
+	}
+
+	offsetParam := c.Query("offset")
+	if offsetParam == "" {
+		// 不列出已下架的片段
+		visible := make([]ClipSyncInfo, 0, len(clips))
+		for _, clip := range clips {
+			if !IsTakenDown(ArtifactClip, videoID, clip.HotMomentOffset) {
+				visible = append(visible, clip)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "video_id": videoID, "clips": visible})
+		return
+	}
+
+	offset, err := strconv.ParseFloat(offsetParam, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "offset 参数无效"})
+		return
+	}
+	if IsTakenDown(ArtifactClip, videoID, offset) {
+		c.JSON(http.StatusGone, gin.H{"success": false, "message": "该片段因违规举报已下架"})
+		return
+	}
+	for _, clip := range clips {
+		if math.Abs(clip.HotMomentOffset-offset) >= 1 {
+			continue
+		}
+		messages, err := loadClipChat(videoID, clip)
+		if err != nil && !os.IsNotExist(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取聊天记录失败: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "video_id": videoID, "clip": clip, "chat": messages})
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"success": false, "message": fmt.Sprintf("未找到偏移 %.0f 秒的片段", offset)})
+}
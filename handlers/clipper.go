@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"subtuber-services/pathsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+const streamerClipSettingsFile = "App_Data/streamer_clip_settings.json"
+
+// maxClipLengthSeconds 单个片段（含前后留白）的最长时长
+const maxClipLengthSeconds = 3600
+
+// ClipSettings holds the length, quality and padding of hot moment clips; zero fields fall back to the next level
+type ClipSettings struct {
+	LengthSeconds      float64 `mapstructure:"length_seconds" json:"length_seconds,omitempty"`             // 以热点为中心的片段时长
+	Quality            string  `mapstructure:"quality" json:"quality,omitempty"`                           // 片段画质，例如 720p、480p 或 audio_only
+	PrePaddingSeconds  float64 `mapstructure:"pre_padding_seconds" json:"pre_padding_seconds,omitempty"`   // 片段开头额外保留的时长
+	PostPaddingSeconds float64 `mapstructure:"post_padding_seconds" json:"post_padding_seconds,omitempty"` // 片段结尾额外保留的时长
+}
+
+// Validate checks that no value is negative and the clip including padding stays within the maximum length
+func (s ClipSettings) Validate() error {
+	if s.LengthSeconds < 0 || s.PrePaddingSeconds < 0 || s.PostPaddingSeconds < 0 {
+		return fmt.Errorf("片段时长与留白不能为负数")
+	}
+	if s.LengthSeconds+s.PrePaddingSeconds+s.PostPaddingSeconds > maxClipLengthSeconds {
+		return fmt.Errorf("片段时长加上前后留白不能超过 %d 秒", maxClipLengthSeconds)
+	}
+	return nil
+}
+
+// merge 用 override 中设置了的字段覆盖当前设置
+func (s ClipSettings) merge(override ClipSettings) ClipSettings {
+	if override.LengthSeconds > 0 {
+		s.LengthSeconds = override.LengthSeconds
+	}
+	if override.Quality != "" {
+		s.Quality = override.Quality
+	}
+	if override.PrePaddingSeconds > 0 {
+		s.PrePaddingSeconds = override.PrePaddingSeconds
+	}
+	if override.PostPaddingSeconds > 0 {
+		s.PostPaddingSeconds = override.PostPaddingSeconds
+	}
+	return s
+}
+
+// ClipperService 热点片段的剪辑设置与重新剪辑
+// 主播的片段设置按以下顺序覆盖：接口保存的主播设置 > 配置文件的 streamer_clips > 配置文件的 clips > 默认值（420 秒、720p、无留白）
+type ClipperService struct {
+	mu sync.Mutex
+}
+
+var clipperService = &ClipperService{}
+
+// GetClipperService 返回片段服务
+func GetClipperService() *ClipperService {
+	return clipperService
+}
+
+// clipSettingsKey 主播设置文件中的键：小写的主播ID
+func clipSettingsKey(streamerID string) string {
+	return strings.ToLower(strings.TrimPrefix(streamerID, "@"))
+}
+
+// loadStreamerClipSettings 读取接口保存的主播片段设置，文件不存在时返回空
+func loadStreamerClipSettings() (map[string]ClipSettings, error) {
+	data, err := os.ReadFile(streamerClipSettingsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]ClipSettings{}, nil
+		}
+		return nil, err
+	}
+	all := map[string]ClipSettings{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// saveStreamerClipSettings 将主播片段设置写回文件
+func saveStreamerClipSettings(all map[string]ClipSettings) error {
+	if err := os.MkdirAll(filepath.Dir(streamerClipSettingsFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(streamerClipSettingsFile, data, 0644)
+}
+
+// StoredSettings 接口保存的主播片段设置，未保存时返回 false
+func (s *ClipperService) StoredSettings(streamerID string) (ClipSettings, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := loadStreamerClipSettings()
+	if err != nil {
+		log.Printf("读取主播片段设置失败: %v", err)
+		return ClipSettings{}, false
+	}
+	settings, ok := all[clipSettingsKey(streamerID)]
+	return settings, ok
+}
+
+// SetStoredSettings 保存主播的片段设置，覆盖配置文件中的设置
+func (s *ClipperService) SetStoredSettings(streamerID string, settings ClipSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := loadStreamerClipSettings()
+	if err != nil {
+		return err
+	}
+	all[clipSettingsKey(streamerID)] = settings
+	return saveStreamerClipSettings(all)
+}
+
+// DeleteStoredSettings 删除接口保存的主播片段设置，恢复使用配置文件中的设置
+func (s *ClipperService) DeleteStoredSettings(streamerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := loadStreamerClipSettings()
+	if err != nil {
+		return err
+	}
+	delete(all, clipSettingsKey(streamerID))
+	return saveStreamerClipSettings(all)
+}
+
+// ConfiguredSettings 主播设置了的片段参数（不含默认值），未设置的字段为零值
+func (s *ClipperService) ConfiguredSettings(streamerID string) ClipSettings {
+	cfg := GetPipelineConfig()
+	settings := cfg.Clips
+	for id, override := range cfg.StreamerClips {
+		if strings.EqualFold(id, strings.TrimPrefix(streamerID, "@")) {
+			settings = settings.merge(override)
+			break
+		}
+	}
+	if stored, ok := s.StoredSettings(streamerID); ok {
+		settings = settings.merge(stored)
+	}
+	return settings
+}
+
+// SettingsFor 主播实际使用的片段设置，未设置的字段使用默认值
+func (s *ClipperService) SettingsFor(streamerID string) ClipSettings {
+	return ClipSettings{LengthSeconds: defaultClipInterval, Quality: defaultClipQuality}.merge(s.ConfiguredSettings(streamerID))
+}
+
+// applyTo 将主播的片段设置写入处理方案，之后的流水线规则仍可覆盖；设置了画质时不再使用订阅者偏好
+func (s *ClipperService) applyTo(plan *PipelinePlan, streamerID string) {
+	settings := s.ConfiguredSettings(streamerID)
+	if settings.LengthSeconds > 0 {
+		plan.ClipInterval = settings.LengthSeconds
+	}
+	if settings.Quality != "" {
+		plan.Quality, plan.qualitySet = settings.Quality, true
+	}
+	plan.ClipPrePadding = settings.PrePaddingSeconds
+	plan.ClipPostPadding = settings.PostPaddingSeconds
+}
+
+// clipWindow 热点片段在录像中的起点与时长：以热点为中心取 interval，再加上前后留白
+// 聊天时间与录像存在偏差时（静音/剪辑片段），按已校准的偏差换算为录像时间
+func clipWindow(videoID string, offsetSeconds, interval float64, plan PipelinePlan) (float64, float64) {
+	start := offsetSeconds - GetVODDriftCorrection(videoID) - interval/2 - plan.ClipPrePadding
+	duration := interval + plan.ClipPrePadding + plan.ClipPostPadding
+	// 确保开始时间不小于0
+	return math.Max(start, 0), duration
+}
+
+// GenerateClipsRequest 重新剪辑已有分析结果的热点片段
+type GenerateClipsRequest struct {
+	VideoID string `json:"video_id" binding:"required"`
+	// File 分析结果文件名（analysis_*.json），为空时使用默认参数的结果
+	File string `json:"file"`
+	// Offsets 只重新剪辑这些热点（热点偏移秒数），为空时剪辑全部热点
+	Offsets []float64 `json:"offsets"`
+	ClipSettings
+}
+
+// savedAnalysisHotMoments 读取录像已保存的分析结果中的热点
+func savedAnalysisHotMoments(videoID, file string) ([]VodCommentData, error) {
+	if file == "" {
+		file = analysisResultFileName(defaultPeakParams)
+	}
+	file = filepath.Base(file)
+	if !strings.HasPrefix(file, "analysis_") || !strings.HasSuffix(file, ".json") {
+		return nil, fmt.Errorf("无效的分析结果文件名: %s", file)
+	}
+
+	data, err := os.ReadFile(filepath.Join(pathsafe.Join("./analysis_results", videoID), file))
+	if err != nil {
+		return nil, err
+	}
+	var result AnalysisResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("解析分析结果失败: %w", err)
+	}
+	return result.HotMoments, nil
+}
+
+// selectHotMoments 按偏移挑选热点（误差 1 秒内），offsets 为空时返回全部热点
+func selectHotMoments(moments []VodCommentData, offsets []float64) []VodCommentData {
+	if len(offsets) == 0 {
+		return moments
+	}
+	var selected []VodCommentData
+	for _, moment := range moments {
+		for _, offset := range offsets {
+			if math.Abs(moment.OffsetSeconds-offset) < 1 {
+				selected = append(selected, moment)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// Regenerate 按新的片段设置重新剪辑录像的热点片段（包含语音识别与AI总结），不重新下载聊天或重新分析
+// 设置会写入录像的处理方案，之后的重试与补做沿用同样的设置；返回实际使用的设置
+func (s *ClipperService) Regenerate(videoID string, moments []VodCommentData, override ClipSettings) ClipSettings {
+	plan := loadPipelinePlan(videoID)
+	// 按当前的主播设置与流水线规则重新求值，规则与主播均未指定画质时保留原方案的画质（订阅者偏好）
+	if plan.Facts.Streamer != "" {
+		fresh := EvaluatePipeline(plan.Facts)
+		plan.ClipInterval, plan.ClipPrePadding, plan.ClipPostPadding = fresh.ClipInterval, fresh.ClipPrePadding, fresh.ClipPostPadding
+		if fresh.qualitySet {
+			plan.Quality = fresh.Quality
+		}
+	}
+	settings := ClipSettings{
+		LengthSeconds:      plan.ClipInterval,
+		Quality:            plan.Quality,
+		PrePaddingSeconds:  plan.ClipPrePadding,
+		PostPaddingSeconds: plan.ClipPostPadding,
+	}.merge(override)
+
+	plan.ClipInterval, plan.Quality = settings.LengthSeconds, settings.Quality
+	plan.ClipPrePadding, plan.ClipPostPadding = settings.PrePaddingSeconds, settings.PostPaddingSeconds
+	savePipelinePlan(videoID, plan)
+
+	log.Printf("重新剪辑视频 %s 的 %d 个热点片段: 时长 %.0f 秒, 画质 %s, 留白 %.0f/%.0f 秒",
+		videoID, len(moments), settings.LengthSeconds, settings.Quality, settings.PrePaddingSeconds, settings.PostPaddingSeconds)
+	reportJobProgress(videoID, JobStageQueued, 0, fmt.Sprintf("重新剪辑 %d 个热点", len(moments)))
+	go submitHotClipsJob(videoID, moments, settings.LengthSeconds)
+	return settings
+}
+
+// GenerateClips 按新的片段设置重新剪辑已有分析结果的热点片段，无需重新执行整个流水线
+// 未指定的设置使用主播的片段设置，进度通过 /api/jobs/:videoID 查看
+// POST /api/clips/generate
+func GenerateClips(c *gin.Context) {
+	if _, err := getUserHashFromCookie(c); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未登录或登录已过期",
+		})
+		return
+	}
+
+	var req GenerateClipsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if err := req.ClipSettings.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if !IsFeatureEnabled(FeatureClipDownload) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "片段下载功能未开启",
+		})
+		return
+	}
+
+	videoID := filepath.Base(req.VideoID)
+
+	moments, err := savedAnalysisHotMoments(videoID, req.File)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "未找到该视频的分析结果，请先分析录像",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	moments = withoutTakenDownClips(videoID, selectHotMoments(moments, req.Offsets))
+	if len(moments) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "分析结果中没有可剪辑的热点",
+		})
+		return
+	}
+
+	if persistedJobInProgress(PersistedJob{Stage: persistedStageHotClips, VideoID: videoID}) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "该视频的片段任务正在执行，请稍后再试",
+		})
+		return
+	}
+
+	settings := GetClipperService().Regenerate(videoID, moments, req.ClipSettings)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"video_id":     videoID,
+		"hot_moments":  len(moments),
+		"settings":     settings,
+		"job_id":       videoID,
+		"progress_url": "/api/jobs/" + videoID,
+	})
+}
+
+// getStreamerClipSettingsHandler 查看主播实际使用的片段设置及接口保存的设置
+func getStreamerClipSettingsHandler(c *gin.Context) {
+	streamerID := c.Param("id")
+	clipper := GetClipperService()
+
+	resp := gin.H{
+		"success":     true,
+		"streamer_id": streamerID,
+		"effective":   clipper.SettingsFor(streamerID),
+	}
+	if stored, ok := clipper.StoredSettings(streamerID); ok {
+		resp["stored"] = stored
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// setStreamerClipSettingsHandler 保存主播的片段设置，之后处理的录像使用新设置
+func setStreamerClipSettingsHandler(c *gin.Context) {
+	streamerID := c.Param("id")
+
+	var req ClipSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	clipper := GetClipperService()
+	if err := clipper.SetStoredSettings(streamerID, req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "保存片段设置失败: " + err.Error(),
+		})
+		return
+	}
+	log.Printf("已更新主播 %s 的片段设置: %+v", streamerID, req)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "片段设置已保存",
+		"streamer_id": streamerID,
+		"effective":   clipper.SettingsFor(streamerID),
+	})
+}
+
+// deleteStreamerClipSettingsHandler 删除接口保存的主播片段设置
+func deleteStreamerClipSettingsHandler(c *gin.Context) {
+	streamerID := c.Param("id")
+
+	clipper := GetClipperService()
+	if err := clipper.DeleteStoredSettings(streamerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "删除片段设置失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "片段设置已删除",
+		"streamer_id": streamerID,
+		"effective":   clipper.SettingsFor(streamerID),
+	})
+}
@@ -1,6 +1,13 @@
 package handlers
 
-import "time"
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"subtuber-services/logging"
+)
 
 type SubTuberConfig struct {
 	DevMode bool `mapstructure:"dev_mode" json:"dev_mode"`
@@ -26,63 +33,675 @@ type RPCConfig struct {
 // GoogleAPIConfig holds Google AI API configuration
 type GoogleAPIConfig struct {
 	APIKey string `mapstructure:"api_key" json:"-"`
+	Model  string `mapstructure:"model" json:"model"` // 默认 gemini-2.5-flash-lite
+	// MaxOutputTokens 单次请求输出 token 数的上限，0 表示只按总结预算限制
+	MaxOutputTokens int `mapstructure:"max_output_tokens" json:"max_output_tokens"`
 }
 
 type AlibabaAPIConfig struct {
-	APIKey string `mapstructure:"api_key" json:"-"`
-	Model  string `mapstructure:"model" json:"model"`
+	APIKey          string `mapstructure:"api_key" json:"-"`
+	Model           string `mapstructure:"model" json:"model"`
+	MaxOutputTokens int    `mapstructure:"max_output_tokens" json:"max_output_tokens"`
+}
+
+// OpenAIAPIConfig holds the settings of the OpenAI chat completions API or a compatible server (vLLM, LM Studio, etc.)
+type OpenAIAPIConfig struct {
+	APIKey          string `mapstructure:"api_key" json:"-"`         // 为空时使用 OPENAI_API_KEY 环境变量
+	BaseURL         string `mapstructure:"base_url" json:"base_url"` // 默认 https://api.openai.com/v1，可指向兼容的自建服务
+	Model           string `mapstructure:"model" json:"model"`       // 默认 gpt-4o-mini
+	MaxOutputTokens int    `mapstructure:"max_output_tokens" json:"max_output_tokens"`
+}
+
+// OllamaConfig holds the settings of a local Ollama server
+type OllamaConfig struct {
+	BaseURL         string `mapstructure:"base_url" json:"base_url"` // 默认 http://localhost:11434
+	Model           string `mapstructure:"model" json:"model"`       // 已拉取的模型，例如 qwen2.5:7b，使用 ollama 时必填
+	MaxOutputTokens int    `mapstructure:"max_output_tokens" json:"max_output_tokens"`
+	// ContextTokens 上下文长度（num_ctx），本地模型默认的上下文较短，分段总结时可能被截断；0 使用模型的默认值
+	ContextTokens int `mapstructure:"context_tokens" json:"context_tokens"`
 }
 
 // AIConfig holds AI service configuration
 type AIConfig struct {
-	Provider string `mapstructure:"provider" json:"provider"` // aliyun or google
+	Provider string `mapstructure:"provider" json:"provider"` // aliyun、google、openai 或 ollama
+	// Fallbacks 主提供商变慢或出错时依次尝试的备用提供商，例如 ["google"]
+	Fallbacks    []string           `mapstructure:"fallbacks" json:"fallbacks"`
+	Backpressure BackpressureConfig `mapstructure:"backpressure" json:"backpressure"`
+	// ChunkOverlapRatio 字幕分段总结时相邻分段重叠的比例（按分段大小计算，0-0.5），默认 0.1
+	ChunkOverlapRatio float64 `mapstructure:"chunk_overlap_ratio" json:"chunk_overlap_ratio"`
+	// Prompts 字幕总结的提示词模板，可按总结语言与主播覆盖，未设置时使用内置提示词
+	Prompts PromptConfig `mapstructure:"prompts" json:"prompts"`
+}
+
+// BackpressureConfig holds the adaptive concurrency limits applied to AI provider calls
+type BackpressureConfig struct {
+	// MinConcurrency/MaxConcurrency 每个提供商同时进行的请求数的调整范围，默认 1 与 8
+	MinConcurrency int `mapstructure:"min_concurrency" json:"min_concurrency"`
+	MaxConcurrency int `mapstructure:"max_concurrency" json:"max_concurrency"`
+	// TargetLatencySeconds 单次请求超过该耗时视为提供商变慢，并发数减半，默认 30 秒；流式请求按收到首个输出的等待时间计算
+	TargetLatencySeconds float64 `mapstructure:"target_latency_seconds" json:"target_latency_seconds"`
+	// MaxQueueDepth 每个提供商排队等待的请求数上限，超出时推迟重试，默认 20
+	MaxQueueDepth int `mapstructure:"max_queue_depth" json:"max_queue_depth"`
+	// ErrorRateThreshold 最近请求的错误率超过该值时视为不健康，优先使用备用提供商，默认 0.5
+	ErrorRateThreshold float64 `mapstructure:"error_rate_threshold" json:"error_rate_threshold"`
+}
+
+// ASRConfig holds the speech recognition providers used to transcribe hot moment clips
+type ASRConfig struct {
+	Provider string `mapstructure:"provider" json:"provider"` // bcut（默认）、openai 或 whisper_cpp
+	// Fallbacks 主提供商失败时依次尝试的备用提供商，例如 ["whisper_cpp"]
+	Fallbacks  []string            `mapstructure:"fallbacks" json:"fallbacks"`
+	OpenAI     OpenAIWhisperConfig `mapstructure:"openai" json:"openai"`
+	WhisperCpp WhisperCppConfig    `mapstructure:"whisper_cpp" json:"whisper_cpp"`
+}
+
+// OpenAIWhisperConfig holds the settings of the OpenAI Whisper transcription API (or a compatible server)
+type OpenAIWhisperConfig struct {
+	APIKey   string `mapstructure:"api_key" json:"-"`         // 为空时使用 OPENAI_API_KEY 环境变量
+	BaseURL  string `mapstructure:"base_url" json:"base_url"` // 默认 https://api.openai.com/v1，可指向兼容的自建服务
+	Model    string `mapstructure:"model" json:"model"`       // 默认 whisper-1
+	Language string `mapstructure:"language" json:"language"` // ISO-639-1 语言代码，为空时自动识别
+}
+
+// WhisperCppConfig holds the settings of a local whisper.cpp installation
+type WhisperCppConfig struct {
+	Binary   string `mapstructure:"binary" json:"binary"`     // 可执行文件，默认 whisper-cli
+	Model    string `mapstructure:"model" json:"model"`       // ggml 模型文件路径，使用 whisper_cpp 时必填
+	Language string `mapstructure:"language" json:"language"` // 语言代码，默认 auto
+	Threads  int    `mapstructure:"threads" json:"threads"`   // 线程数，0 使用 whisper.cpp 的默认值
+}
+
+// AnalysisConfig holds analysis output settings
+type AnalysisConfig struct {
+	// TimeSeriesStorage 时间序列的存储方式：inline（默认，写入分析结果文件）或 sidecar（单独的 gzip 文件）
+	TimeSeriesStorage string `mapstructure:"time_series_storage" json:"time_series_storage"`
+	// ChatReactionLatencySeconds 观众反应延迟，用于校准聊天与录像的时间偏差，默认 4 秒
+	ChatReactionLatencySeconds float64 `mapstructure:"chat_reaction_latency_seconds" json:"chat_reaction_latency_seconds"`
+	// SkipMinDurationSeconds 可跳过（冷场）片段的最短时长，默认 300 秒
+	SkipMinDurationSeconds int `mapstructure:"skip_min_duration_seconds" json:"skip_min_duration_seconds"`
+	// SkipActivityRatio 弹幕活跃度低于中位数的该比例时视为冷场（0-1），默认 0.15
+	SkipActivityRatio float64 `mapstructure:"skip_activity_ratio" json:"skip_activity_ratio"`
+	// Shadow 影子模式：对新录像额外运行候选算法，与线上结果对比但不影响线上结果
+	Shadow ShadowAnalysisConfig `mapstructure:"shadow" json:"shadow"`
+}
+
+// ShadowAnalysisConfig holds the candidate peak detection setup run alongside production in shadow mode
+type ShadowAnalysisConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+	// Version 候选版本标签，例如 zscore-v2，为空时由算法与参数生成
+	Version       string  `mapstructure:"version" json:"version"`
+	Method        string  `mapstructure:"method" json:"method"`
+	WindowsLen    int     `mapstructure:"windows_len" json:"windows_len"`
+	Thr           float64 `mapstructure:"thr" json:"thr"`
+	SearchRange   int     `mapstructure:"search_range" json:"search_range"`
+	ZThreshold    float64 `mapstructure:"z_threshold" json:"z_threshold"`
+	BaselineLen   int     `mapstructure:"baseline_len" json:"baseline_len"`
+	MinWindowsLen int     `mapstructure:"min_windows_len" json:"min_windows_len"`
+	Scales        int     `mapstructure:"scales" json:"scales"`
+}
+
+// PipelineConfig holds ordered rules that customize how each VOD is processed.
+// Rules are evaluated in order; later matches override earlier ones and "stop" ends evaluation.
+type PipelineConfig struct {
+	Rules []PipelineRule `mapstructure:"rules" json:"rules"`
+	// Gates 自动处理的最低门槛，未达到时跳过分析与片段（例如短时间的测试直播）
+	Gates ProcessingGates `mapstructure:"gates" json:"gates"`
+	// StreamerGates 按主播ID（小写）覆盖全局门槛，配置了的主播完全使用自己的门槛
+	StreamerGates map[string]ProcessingGates `mapstructure:"streamer_gates" json:"streamer_gates,omitempty"`
+	// Queue 下播处理等后台任务的并发数与积压时的调度策略
+	Queue ProcessingQueueConfig `mapstructure:"queue" json:"queue"`
+	// Clips 热点片段的默认时长、画质与前后留白
+	Clips ClipSettings `mapstructure:"clips" json:"clips"`
+	// StreamerClips 按主播ID（小写）覆盖片段设置，只覆盖设置了的字段；管理接口保存的设置优先
+	StreamerClips map[string]ClipSettings `mapstructure:"streamer_clips" json:"streamer_clips,omitempty"`
+	// Previews 片段下载后提取的预览图（封面帧与可选的动态 WebP）
+	Previews ClipPreviewSettings `mapstructure:"previews" json:"previews"`
+	// ChatSummary 根据热点附近的弹幕生成“观众在对什么做出反应”的AI总结
+	ChatSummary ChatSummarySettings `mapstructure:"chat_summary" json:"chat_summary"`
+	// Concurrency 各阶段（聊天下载、ffmpeg、语音识别、AI）同时执行的上限，所有任务共享
+	Concurrency StageConcurrencyConfig `mapstructure:"concurrency" json:"concurrency"`
+}
+
+// StageConcurrencyConfig holds the per-stage concurrency limits of the VOD pipeline (0 uses the default)
+type StageConcurrencyConfig struct {
+	ChatDownload int `mapstructure:"chat_download" json:"chat_download"` // 同时下载聊天记录的录像数，默认 2
+	FFmpeg       int `mapstructure:"ffmpeg" json:"ffmpeg"`               // 同时运行的 ffmpeg / yt-dlp 下载与转码，默认 2
+	ASR          int `mapstructure:"asr" json:"asr"`                     // 同时进行的语音识别，默认 2
+	AI           int `mapstructure:"ai" json:"ai"`                       // 同时进行的AI请求（所有提供商合计），默认 4
+}
+
+// ProcessingQueueConfig holds the concurrency limit and scheduling policy of the processing queue
+type ProcessingQueueConfig struct {
+	// Policy 调度策略：subscribers（默认，订阅者多的主播优先）或 fifo（先到先处理）
+	Policy string `mapstructure:"policy" json:"policy"`
+	// MaxConcurrent 同时执行的任务数，默认 2
+	MaxConcurrent int `mapstructure:"max_concurrent" json:"max_concurrent"`
+	// AgingMinutes 任务每等待该时长，优先级提高相当于订阅者数量翻一倍，避免订阅者少的主播一直排在后面，默认 10 分钟
+	AgingMinutes float64 `mapstructure:"aging_minutes" json:"aging_minutes"`
+}
+
+// ProcessingGates holds the minimum requirements a VOD must meet before it is auto-processed (0 disables a gate)
+type ProcessingGates struct {
+	MinDurationSeconds int `mapstructure:"min_duration_seconds" json:"min_duration_seconds"` // 录像最短时长
+	MinComments        int `mapstructure:"min_comments" json:"min_comments"`                 // 聊天消息最少条数
+}
+
+// PipelineRule applies its actions to VODs matching the condition
+type PipelineRule struct {
+	// When 条件表达式，例如 "duration > 6h"、"platform == twitch && language == ru"，为空时总是命中
+	When string `mapstructure:"when" json:"when"`
+	// Then 依次执行的动作：skip <步骤>、run <步骤>、audio_only、quality <画质>、clip_interval <时长>、stop
+	Then []string `mapstructure:"then" json:"then"`
+}
+
+// ModerationConfig holds settings for abuse reports on published clips and summaries
+type ModerationConfig struct {
+	// AutoTakedownReports 同一内容收到该数量的不同举报人举报后自动下架并等待审核，0 表示只由管理员处理
+	AutoTakedownReports int `mapstructure:"auto_takedown_reports" json:"auto_takedown_reports"`
+}
+
+// StorageConfig holds the clip storage tiering settings
+type StorageConfig struct {
+	// ColdAfterDays 片段下载超过该天数后移到对象存储并删除本地文件，0 表示不启用
+	ColdAfterDays int               `mapstructure:"cold_after_days" json:"cold_after_days"`
+	ObjectStore   ObjectStoreConfig `mapstructure:"object_store" json:"object_store"`
+	// Retention 按目录（chat_logs、downloads、analysis_results）限制保留天数与占用空间
+	Retention map[string]RetentionPolicy `mapstructure:"retention" json:"retention"`
+}
+
+// RetentionPolicy holds the age and disk usage limits enforced on one data directory
+type RetentionPolicy struct {
+	MaxAgeDays int     `mapstructure:"max_age_days" json:"max_age_days"` // 超过该天数未修改的数据删除，0 表示不限制
+	MaxSizeGB  float64 `mapstructure:"max_size_gb" json:"max_size_gb"`   // 目录占用上限，超出时从最旧的数据开始删除，0 表示不限制
 }
 
+// ObjectStoreConfig holds the S3-compatible object store used as cold storage
+type ObjectStoreConfig struct {
+	Endpoint  string `mapstructure:"endpoint" json:"endpoint"` // 例如 https://s3.us-east-1.amazonaws.com 或 MinIO 地址
+	Region    string `mapstructure:"region" json:"region"`     // 默认 us-east-1
+	Bucket    string `mapstructure:"bucket" json:"bucket"`
+	Prefix    string `mapstructure:"prefix" json:"prefix"` // 对象键前缀，例如 hot_clips/
+	AccessKey string `mapstructure:"access_key" json:"-"`
+	SecretKey string `mapstructure:"secret_key" json:"-"`
+	// SignedURLTTLMinutes 访问冷存储片段时签名链接的有效期，默认 60 分钟
+	SignedURLTTLMinutes int `mapstructure:"signed_url_ttl_minutes" json:"signed_url_ttl_minutes"`
+}
+
+// ScheduleConfig holds the cron schedules of the periodic background jobs.
+// Expressions use the standard 5-field syntax or descriptors such as @daily and @every 5m.
+type ScheduleConfig struct {
+	Timezone string            `mapstructure:"timezone" json:"timezone"` // IANA 时区名，为空时使用服务器本地时区
+	Jobs     map[string]string `mapstructure:"jobs" json:"jobs"`         // 任务名 -> cron 表达式，未配置的任务使用默认计划
+}
+
+// WebhooksConfig holds the Discord and Telegram targets that receive stream events and AI summaries
+type WebhooksConfig struct {
+	Targets []WebhookTarget `mapstructure:"targets" json:"-"` // 包含 Webhook 地址与机器人令牌，不在日志与接口中输出
+}
+
+// WebhookTarget holds a single outbound webhook target
+type WebhookTarget struct {
+	Name      string   `mapstructure:"name" json:"name"`
+	Type      string   `mapstructure:"type" json:"type"`           // discord 或 telegram
+	URL       string   `mapstructure:"url" json:"-"`               // Discord 频道的 Webhook 地址
+	BotToken  string   `mapstructure:"bot_token" json:"-"`         // Telegram 机器人令牌
+	ChatID    string   `mapstructure:"chat_id" json:"chat_id"`     // Telegram 频道或群组 ID，例如 @my_channel
+	Streamers []string `mapstructure:"streamers" json:"streamers"` // 主播 ID、登录名或频道 ID，为空时推送所有主播
+	Events    []string `mapstructure:"events" json:"events"`       // go_live、offline、summary，为空时推送全部事件
+}
+
+// ServerConfig holds settings about how the service is reached from outside
+type ServerConfig struct {
+	PublicBaseURL string `mapstructure:"public_base_url" json:"public_base_url"` // 对外访问的地址，例如 https://lumitime.example.com，用于嵌入页与 oEmbed 中的绝对链接
+}
+
+// AdminConfig holds settings for the operator-only admin API
+type AdminConfig struct {
+	Token string `mapstructure:"token" json:"-"` // 管理接口令牌，为空时禁用管理接口
+}
+
+// QuietHoursConfig holds the time windows during which heavy processing
+// (clip downloads, ASR, AI summaries) is queued instead of executed
+type QuietHoursConfig struct {
+	Enabled  bool     `mapstructure:"enabled" json:"enabled"`
+	Timezone string   `mapstructure:"timezone" json:"timezone"` // IANA 时区名，为空时使用服务器本地时区
+	Windows  []string `mapstructure:"windows" json:"windows"`   // 形如 "22:00-02:00"，允许跨越午夜
+}
+
+// CredentialsConfig holds the key used to encrypt stored platform credentials
+type CredentialsConfig struct {
+	EncryptionKey string `mapstructure:"encryption_key" json:"-"` // 为空时禁止保存会员凭据
+}
+
+// AuthConfig holds settings for the password-less magic-link login and session tokens
+type AuthConfig struct {
+	MagicLinkEnabled    bool   `mapstructure:"magic_link_enabled" json:"magic_link_enabled"`
+	MagicLinkURL        string `mapstructure:"magic_link_url" json:"magic_link_url"`                 // 登录链接指向的地址，例如 https://api.example.com/api/v1/auth/magic-link
+	MagicLinkTTLMinutes int    `mapstructure:"magic_link_ttl_minutes" json:"magic_link_ttl_minutes"` // 链接有效期，默认 15 分钟
+	MagicLinkSecret     string `mapstructure:"magic_link_secret" json:"-"`                           // 签名密钥，为空时每次启动随机生成
+	LoginRedirectURL    string `mapstructure:"login_redirect_url" json:"login_redirect_url"`         // 链接登录成功后跳转的前端页面
+	SessionSecret       string `mapstructure:"session_secret" json:"-"`                              // 会话令牌签名密钥，必须配置
+	SessionTTLHours     int    `mapstructure:"session_ttl_hours" json:"session_ttl_hours"`           // 会话令牌有效期，默认 168 小时（7 天）
+	LegacyCookieUntil   string `mapstructure:"legacy_cookie_until" json:"legacy_cookie_until"`       // 旧的明文 UserInfo Cookie 接受到该日期（YYYY-MM-DD，最多 90 天后），为空时不接受
+}
+
+// PlanLimits holds the daily usage limits of a plan (0 means unlimited)
+type PlanLimits struct {
+	DailyRequests    int `mapstructure:"daily_requests" json:"daily_requests"`
+	DailyJobs        int `mapstructure:"daily_jobs" json:"daily_jobs"`
+	DailyAISummaries int `mapstructure:"daily_ai_summaries" json:"daily_ai_summaries"`
+}
+
+// UsageConfig holds the per-plan usage limits and the plan assigned to each user
+type UsageConfig struct {
+	DefaultPlan string                `mapstructure:"default_plan" json:"default_plan"` // 未指定套餐的用户使用的套餐
+	Plans       map[string]PlanLimits `mapstructure:"plans" json:"plans"`
+	UserPlans   map[string]string     `mapstructure:"user_plans" json:"user_plans"` // userHash -> 套餐名
+}
+
+// configMu 保护以下包级配置，配置热加载时会在运行中替换它们
+var configMu sync.RWMutex
+
 var smtpCfg = SMTPConfig{}
+var serverCfg = ServerConfig{}
 var rpcCfg = RPCConfig{}
 var googleAPICfg = GoogleAPIConfig{}
 var alibabaApiCfg = AlibabaAPIConfig{}
+var openAIAPICfg = OpenAIAPIConfig{}
+var ollamaCfg = OllamaConfig{}
 var aiCfg = AIConfig{}
+var asrCfg = ASRConfig{}
+var webhooksCfg = WebhooksConfig{}
 var youtubeCfg = YouTubeConfig{}
+var adminCfg = AdminConfig{}
+var analysisCfg = AnalysisConfig{}
+var quietHoursCfg = QuietHoursConfig{}
+var credentialsCfg = CredentialsConfig{}
+var usageCfg = UsageConfig{}
+var authCfg = AuthConfig{}
+var pipelineCfg = PipelineConfig{}
+var moderationCfg = ModerationConfig{}
+var storageCfg = StorageConfig{}
+var scheduleCfg = ScheduleConfig{}
+var loggingCfg = logging.Config{}
 
 // SetSMTPConfig sets the package-level SMTP configuration used by handlers.
 func SetSMTPConfig(cfg SMTPConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
 	smtpCfg = cfg
 }
 
 // GetSMTPConfig returns a copy of the current SMTP configuration.
-func GetSMTPConfig() SMTPConfig { return smtpCfg }
+func GetSMTPConfig() SMTPConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return smtpCfg
+}
 
 // SetRPCConfig sets the package-level RPC configuration
 func SetRPCConfig(cfg RPCConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
 	rpcCfg = cfg
 }
 
 // GetRPCConfig returns a copy of the current RPC configuration
-func GetRPCConfig() RPCConfig { return rpcCfg }
+func GetRPCConfig() RPCConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return rpcCfg
+}
 
 // SetGoogleAPIConfig sets the package-level Google API configuration
 func SetGoogleAPIConfig(cfg GoogleAPIConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
 	googleAPICfg = cfg
 }
 
 // GetGoogleAPIConfig returns a copy of the current Google API configuration
-func GetGoogleAPIConfig() GoogleAPIConfig { return googleAPICfg }
+func GetGoogleAPIConfig() GoogleAPIConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return googleAPICfg
+}
 
 func SetAlibabaAPIConfig(cfg AlibabaAPIConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
 	alibabaApiCfg = cfg
 }
 
 func GetAlibabaAPIConfig() AlibabaAPIConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
 	return alibabaApiCfg
 }
 
+// SetOpenAIAPIConfig sets the package-level OpenAI chat API configuration
+func SetOpenAIAPIConfig(cfg OpenAIAPIConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	openAIAPICfg = cfg
+}
+
+// GetOpenAIAPIConfig returns a copy of the current OpenAI chat API configuration
+func GetOpenAIAPIConfig() OpenAIAPIConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return openAIAPICfg
+}
+
+// SetOllamaConfig sets the package-level Ollama configuration
+func SetOllamaConfig(cfg OllamaConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	ollamaCfg = cfg
+}
+
+// GetOllamaConfig returns a copy of the current Ollama configuration
+func GetOllamaConfig() OllamaConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return ollamaCfg
+}
+
+// SetASRConfig sets the package-level speech recognition configuration
+func SetASRConfig(cfg ASRConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	asrCfg = cfg
+}
+
+// GetASRConfig returns a copy of the current speech recognition configuration
+func GetASRConfig() ASRConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return asrCfg
+}
+
+// SetWebhooksConfig sets the package-level outbound webhook configuration
+func SetWebhooksConfig(cfg WebhooksConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	webhooksCfg = cfg
+}
+
+// GetWebhooksConfig returns a copy of the current outbound webhook configuration
+func GetWebhooksConfig() WebhooksConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return webhooksCfg
+}
+
 // SetAIConfig sets the package-level AI configuration
 func SetAIConfig(cfg AIConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
 	aiCfg = cfg
 }
 
 // GetAIConfig returns a copy of the current AI configuration
 func GetAIConfig() AIConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
 	return aiCfg
 }
+
+// SetServerConfig sets the package-level public address configuration
+func SetServerConfig(cfg ServerConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	serverCfg = cfg
+}
+
+// GetServerConfig returns a copy of the current public address configuration
+func GetServerConfig() ServerConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return serverCfg
+}
+
+// SetAdminConfig sets the package-level admin API configuration
+func SetAdminConfig(cfg AdminConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	adminCfg = cfg
+}
+
+// GetAdminConfig returns a copy of the current admin API configuration
+func GetAdminConfig() AdminConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return adminCfg
+}
+
+// SetAnalysisConfig sets the package-level analysis output configuration
+func SetAnalysisConfig(cfg AnalysisConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	analysisCfg = cfg
+}
+
+// GetAnalysisConfig returns a copy of the current analysis output configuration
+func GetAnalysisConfig() AnalysisConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return analysisCfg
+}
+
+// SetQuietHoursConfig sets the package-level quiet hours configuration
+func SetQuietHoursConfig(cfg QuietHoursConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	quietHoursCfg = cfg
+}
+
+// GetQuietHoursConfig returns a copy of the current quiet hours configuration
+func GetQuietHoursConfig() QuietHoursConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return quietHoursCfg
+}
+
+// SetPipelineConfig sets the package-level pipeline rules
+func SetPipelineConfig(cfg PipelineConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	pipelineCfg = cfg
+}
+
+// GetPipelineConfig returns a copy of the current pipeline rules
+func GetPipelineConfig() PipelineConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return pipelineCfg
+}
+
+// SetModerationConfig sets the package-level abuse report settings
+func SetModerationConfig(cfg ModerationConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	moderationCfg = cfg
+}
+
+// GetModerationConfig returns a copy of the current abuse report settings
+func GetModerationConfig() ModerationConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return moderationCfg
+}
+
+// SetStorageConfig sets the package-level storage tiering settings
+func SetStorageConfig(cfg StorageConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	storageCfg = cfg
+}
+
+// GetStorageConfig returns a copy of the current storage tiering settings
+func GetStorageConfig() StorageConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return storageCfg
+}
+
+// SetScheduleConfig sets the package-level background job schedules
+func SetScheduleConfig(cfg ScheduleConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	scheduleCfg = cfg
+}
+
+// GetScheduleConfig returns a copy of the current background job schedules
+func GetScheduleConfig() ScheduleConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return scheduleCfg
+}
+
+// SetLoggingConfig sets the package-level log format and level configuration
+func SetLoggingConfig(cfg logging.Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	loggingCfg = cfg
+}
+
+// GetLoggingConfig returns a copy of the current log configuration
+func GetLoggingConfig() logging.Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return loggingCfg
+}
+
+// SetCredentialsConfig sets the package-level credentials encryption configuration
+func SetCredentialsConfig(cfg CredentialsConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	credentialsCfg = cfg
+}
+
+// GetCredentialsConfig returns a copy of the current credentials encryption configuration
+func GetCredentialsConfig() CredentialsConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return credentialsCfg
+}
+
+// SetUsageConfig sets the package-level usage limit configuration
+func SetUsageConfig(cfg UsageConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	usageCfg = cfg
+}
+
+// GetUsageConfig returns a copy of the current usage limit configuration
+func GetUsageConfig() UsageConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return usageCfg
+}
+
+// SetAuthConfig sets the package-level login configuration
+func SetAuthConfig(cfg AuthConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	authCfg = cfg
+}
+
+// GetAuthConfig returns a copy of the current login configuration
+func GetAuthConfig() AuthConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return authCfg
+}
+
+// planFor returns the plan name assigned to the user
+func (c UsageConfig) planFor(userHash string) string {
+	if plan, ok := c.UserPlans[userHash]; ok {
+		return plan
+	}
+	return c.DefaultPlan
+}
+
+// limitsFor returns the limits of the user's plan; unknown plans are unlimited
+func (c UsageConfig) limitsFor(userHash string) PlanLimits {
+	return c.Plans[c.planFor(userHash)]
+}
+
+// Validate checks that the public base URL is an absolute http(s) URL
+func (c ServerConfig) Validate() error {
+	if c.PublicBaseURL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.PublicBaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("public_base_url 必须是完整的 http(s) 地址")
+	}
+	return nil
+}
+
+// Validate checks that every referenced plan is defined
+func (c UsageConfig) Validate() error {
+	if c.DefaultPlan != "" {
+		if _, ok := c.Plans[c.DefaultPlan]; !ok {
+			return fmt.Errorf("默认套餐 %s 未定义", c.DefaultPlan)
+		}
+	}
+	for user, plan := range c.UserPlans {
+		if _, ok := c.Plans[plan]; !ok {
+			return fmt.Errorf("用户 %s 的套餐 %s 未定义", user, plan)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the time series storage mode is supported
+func (c AnalysisConfig) Validate() error {
+	if c.ChatReactionLatencySeconds < 0 || c.ChatReactionLatencySeconds > driftMaxLag {
+		return fmt.Errorf("观众反应延迟必须在 0 到 %d 秒之间", driftMaxLag)
+	}
+	if c.SkipMinDurationSeconds < 0 {
+		return fmt.Errorf("冷场片段最短时长不能为负数")
+	}
+	if c.SkipActivityRatio < 0 || c.SkipActivityRatio >= 1 {
+		return fmt.Errorf("冷场活跃度比例必须在 0 到 1 之间")
+	}
+	if c.Shadow.Enabled {
+		if err := validatePeakMethod(c.Shadow.Method); err != nil {
+			return fmt.Errorf("影子模式: %w", err)
+		}
+	}
+	switch c.TimeSeriesStorage {
+	case "", timeSeriesStorageInline, timeSeriesStorageSidecar:
+		return nil
+	default:
+		return fmt.Errorf("不支持的时间序列存储方式: %s", c.TimeSeriesStorage)
+	}
+}
+
+// Validate checks that the AI configuration names a supported provider
+func (c AIConfig) Validate() error {
+	for _, provider := range append([]string{c.Provider}, c.Fallbacks...) {
+		switch provider {
+		case "aliyun", "google", "openai", "ollama":
+		default:
+			return fmt.Errorf("不支持的AI服务提供商: %s", provider)
+		}
+	}
+	b := c.Backpressure
+	if b.MinConcurrency < 0 || b.MaxConcurrency < 0 || b.MaxQueueDepth < 0 || b.TargetLatencySeconds < 0 {
+		return fmt.Errorf("AI 背压配置不能为负数")
+	}
+	if b.MinConcurrency > 0 && b.MaxConcurrency > 0 && b.MinConcurrency > b.MaxConcurrency {
+		return fmt.Errorf("AI 最小并发数 %d 大于最大并发数 %d", b.MinConcurrency, b.MaxConcurrency)
+	}
+	if b.ErrorRateThreshold < 0 || b.ErrorRateThreshold > 1 {
+		return fmt.Errorf("AI 错误率阈值必须在 0-1 之间")
+	}
+	if c.ChunkOverlapRatio < 0 || c.ChunkOverlapRatio > maxChunkOverlapRatio {
+		return fmt.Errorf("字幕分段重叠比例必须在 0-%.1f 之间", maxChunkOverlapRatio)
+	}
+	return c.Prompts.Validate()
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"subtuber-services/logging"
+)
+
+// ReloadableConfig 配置热加载时参与比对的配置集合
+type ReloadableConfig struct {
+	SMTP       SMTPConfig
+	Server     ServerConfig
+	Twitch     TwitchConfig
+	YouTube    YouTubeConfig
+	RPC        RPCConfig
+	GoogleAPI  GoogleAPIConfig
+	AlibabaAPI AlibabaAPIConfig
+	OpenAIAPI  OpenAIAPIConfig
+	Ollama     OllamaConfig
+	AI         AIConfig
+	ASR        ASRConfig
+	Webhooks   WebhooksConfig
+	Admin      AdminConfig
+	Analysis   AnalysisConfig
+	QuietHours QuietHoursConfig
+	Usage      UsageConfig
+	Auth       AuthConfig
+	Pipeline   PipelineConfig
+	Moderation ModerationConfig
+	Storage    StorageConfig
+	Schedule   ScheduleConfig
+	Logging    logging.Config
+}
+
+// ConfigChange 一条配置变更记录
+type ConfigChange struct {
+	Key     string
+	Old     string
+	New     string
+	Applied bool // false 表示该项需要重启服务后才能生效
+}
+
+// Validate 校验重新加载的配置，任一项不合法则整体拒绝
+func (c ReloadableConfig) Validate() error {
+	if err := c.AI.Validate(); err != nil {
+		return err
+	}
+	if err := c.Ollama.Validate(); err != nil {
+		return err
+	}
+	if err := c.ASR.Validate(); err != nil {
+		return err
+	}
+	if err := c.Webhooks.Validate(); err != nil {
+		return err
+	}
+	if err := c.Analysis.Validate(); err != nil {
+		return err
+	}
+	if err := c.Usage.Validate(); err != nil {
+		return err
+	}
+	if err := c.Auth.Validate(); err != nil {
+		return err
+	}
+	if err := c.Server.Validate(); err != nil {
+		return err
+	}
+	if err := c.QuietHours.Validate(); err != nil {
+		return err
+	}
+	if err := c.Pipeline.Validate(); err != nil {
+		return err
+	}
+	if err := c.Moderation.Validate(); err != nil {
+		return err
+	}
+	if err := c.Storage.Validate(); err != nil {
+		return err
+	}
+	if err := c.Schedule.Validate(); err != nil {
+		return err
+	}
+	if err := c.Twitch.Validate(); err != nil {
+		return err
+	}
+	if err := c.YouTube.Validate(); err != nil {
+		return err
+	}
+	if err := c.Logging.Validate(); err != nil {
+		return err
+	}
+	if c.SMTP.Timeout < 0 {
+		return fmt.Errorf("smtp 超时时间不能为负数")
+	}
+	return nil
+}
+
+// ApplyConfigReload 校验并应用新的配置
+// 可安全修改的配置（检查间隔、AI 服务、SMTP 等）立即生效，
+// 其余变更（RPC 地址、平台凭据等）仅记录，需要重启后生效
+func ApplyConfigReload(next ReloadableConfig) ([]ConfigChange, error) {
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	var changes []ConfigChange
+
+	// 包级配置：每次使用时读取，直接替换即可生效
+	changes = appendConfigChanges(changes, "smtp", GetSMTPConfig(), next.SMTP, true)
+	SetSMTPConfig(next.SMTP)
+
+	changes = appendConfigChanges(changes, "google_api", GetGoogleAPIConfig(), next.GoogleAPI, true)
+	SetGoogleAPIConfig(next.GoogleAPI)
+
+	changes = appendConfigChanges(changes, "alibaba_api", GetAlibabaAPIConfig(), next.AlibabaAPI, true)
+	SetAlibabaAPIConfig(next.AlibabaAPI)
+
+	changes = appendConfigChanges(changes, "openai_api", GetOpenAIAPIConfig(), next.OpenAIAPI, true)
+	SetOpenAIAPIConfig(next.OpenAIAPI)
+
+	changes = appendConfigChanges(changes, "ollama", GetOllamaConfig(), next.Ollama, true)
+	SetOllamaConfig(next.Ollama)
+
+	changes = appendConfigChanges(changes, "ai", GetAIConfig(), next.AI, true)
+	SetAIConfig(next.AI)
+
+	changes = appendConfigChanges(changes, "asr", GetASRConfig(), next.ASR, true)
+	SetASRConfig(next.ASR)
+
+	changes = appendConfigChanges(changes, "webhooks", GetWebhooksConfig(), next.Webhooks, true)
+	SetWebhooksConfig(next.Webhooks)
+
+	changes = appendConfigChanges(changes, "admin", GetAdminConfig(), next.Admin, true)
+	SetAdminConfig(next.Admin)
+
+	changes = appendConfigChanges(changes, "analysis", GetAnalysisConfig(), next.Analysis, true)
+	SetAnalysisConfig(next.Analysis)
+
+	changes = appendConfigChanges(changes, "quiet_hours", GetQuietHoursConfig(), next.QuietHours, true)
+	SetQuietHoursConfig(next.QuietHours)
+
+	changes = appendConfigChanges(changes, "usage", GetUsageConfig(), next.Usage, true)
+	SetUsageConfig(next.Usage)
+
+	changes = appendConfigChanges(changes, "auth", GetAuthConfig(), next.Auth, true)
+	SetAuthConfig(next.Auth)
+
+	changes = appendConfigChanges(changes, "server", GetServerConfig(), next.Server, true)
+	SetServerConfig(next.Server)
+
+	changes = appendConfigChanges(changes, "pipeline", GetPipelineConfig(), next.Pipeline, true)
+	SetPipelineConfig(next.Pipeline)
+	// 并发上限提高后等待中的请求立即重新检查
+	wakeStageLimiters()
+
+	changes = appendConfigChanges(changes, "moderation", GetModerationConfig(), next.Moderation, true)
+	SetModerationConfig(next.Moderation)
+
+	changes = appendConfigChanges(changes, "storage", GetStorageConfig(), next.Storage, true)
+	SetStorageConfig(next.Storage)
+
+	// 定时任务的时区或计划变更后重建调度器
+	scheduleChanges := len(changes)
+	changes = appendConfigChanges(changes, "schedule", GetScheduleConfig(), next.Schedule, true)
+	SetScheduleConfig(next.Schedule)
+	if len(changes) > scheduleChanges {
+		applyScheduleConfig()
+	}
+
+	// 日志级别与格式替换后立即生效，通过管理接口临时修改的级别会被覆盖
+	loggingChanges := len(changes)
+	changes = appendConfigChanges(changes, "logging", GetLoggingConfig(), next.Logging, true)
+	SetLoggingConfig(next.Logging)
+	if len(changes) > loggingChanges {
+		_ = logging.Configure(next.Logging)
+	}
+
+	// RPC 连接在启动时建立，变更需要重启
+	changes = appendConfigChanges(changes, "rpc", GetRPCConfig(), next.RPC, false)
+
+	if tm := GetTwitchMonitor(); tm != nil {
+		changes = append(changes, tm.UpdateConfig(next.Twitch)...)
+	}
+	if ym := GetYouTubeMonitor(); ym != nil {
+		changes = append(changes, ym.UpdateConfig(next.YouTube)...)
+	}
+
+	for _, change := range changes {
+		auditConfigChange(change)
+	}
+
+	return changes, nil
+}
+
+// UpdateConfig 更新Twitch监控的检查间隔，凭据变更需要重启后生效
+func (tm *TwitchMonitor) UpdateConfig(config TwitchConfig) []ConfigChange {
+	config.applyDefaults()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	old := tm.config
+	var changes []ConfigChange
+	changes = appendConfigChanges(changes, "twitch.min_interval_seconds", old.MinInterval, config.MinInterval, true)
+	changes = appendConfigChanges(changes, "twitch.max_interval_seconds", old.MaxInterval, config.MaxInterval, true)
+	changes = appendConfigChanges(changes, "twitch.reload_interval_minutes", old.ReloadInterval, config.ReloadInterval, true)
+	changes = appendConfigChanges(changes, "twitch.redirect_url", old.RedirectURL, config.RedirectURL, true)
+	changes = appendConfigChanges(changes, "twitch.offline_confirmations", old.OfflineConfirmations, config.OfflineConfirmations, true)
+	changes = appendConfigChanges(changes, "twitch.stream_end_grace_seconds", old.StreamEndGraceSeconds, config.StreamEndGraceSeconds, true)
+	changes = appendConfigChanges(changes, "twitch.client_id", old.ClientID, config.ClientID, false)
+	changes = appendConfigChanges(changes, "twitch.client_secret", old.ClientSecret, config.ClientSecret, false)
+	changes = appendConfigChanges(changes, "twitch.credentials", old.Credentials, config.Credentials, false)
+
+	tm.config.MinInterval = config.MinInterval
+	tm.config.MaxInterval = config.MaxInterval
+	tm.config.ReloadInterval = config.ReloadInterval
+	tm.config.RedirectURL = config.RedirectURL
+	tm.config.OfflineConfirmations = config.OfflineConfirmations
+	tm.config.StreamEndGraceSeconds = config.StreamEndGraceSeconds
+
+	return changes
+}
+
+// UpdateConfig 更新YouTube监控的检查间隔、配额预算与直播检查方式，API Key 与 Referer 变更需要重启后生效
+func (ym *YouTubeMonitor) UpdateConfig(config YouTubeConfig) []ConfigChange {
+	config.applyDefaults()
+
+	ym.mu.Lock()
+	defer ym.mu.Unlock()
+
+	old := ym.config
+	var changes []ConfigChange
+	changes = appendConfigChanges(changes, "youtube.min_interval_seconds", old.MinIntervalSeconds, config.MinIntervalSeconds, true)
+	changes = appendConfigChanges(changes, "youtube.max_interval_seconds", old.MaxIntervalSeconds, config.MaxIntervalSeconds, true)
+	changes = appendConfigChanges(changes, "youtube.reload_interval_minutes", old.ReloadIntervalMinutes, config.ReloadIntervalMinutes, true)
+	changes = appendConfigChanges(changes, "youtube.offline_confirmations", old.OfflineConfirmations, config.OfflineConfirmations, true)
+	changes = appendConfigChanges(changes, "youtube.stream_end_grace_seconds", old.StreamEndGraceSeconds, config.StreamEndGraceSeconds, true)
+	changes = appendConfigChanges(changes, "youtube.daily_quota", old.DailyQuota, config.DailyQuota, true)
+	changes = appendConfigChanges(changes, "youtube.quota_reserve_percent", old.QuotaReservePercent, config.QuotaReservePercent, true)
+	changes = appendConfigChanges(changes, "youtube.live_check", old.LiveCheck, config.LiveCheck, true)
+	changes = appendConfigChanges(changes, "youtube.api_keys", old.APIKeys, config.APIKeys, false)
+	changes = appendConfigChanges(changes, "youtube.referer", old.Referer, config.Referer, false)
+
+	ym.config.MinIntervalSeconds = config.MinIntervalSeconds
+	ym.config.MaxIntervalSeconds = config.MaxIntervalSeconds
+	ym.config.ReloadIntervalMinutes = config.ReloadIntervalMinutes
+	ym.config.OfflineConfirmations = config.OfflineConfirmations
+	ym.config.StreamEndGraceSeconds = config.StreamEndGraceSeconds
+	ym.config.DailyQuota = config.DailyQuota
+	ym.config.QuotaReservePercent = config.QuotaReservePercent
+	ym.config.LiveCheck = config.LiveCheck
+	youtubeQuota.configure(config.DailyQuota, config.QuotaReservePercent)
+
+	return changes
+}
+
+// appendConfigChanges 比较新旧配置值，结构体会按字段逐项比较
+// 带 json:"-" 标签或名称包含密钥含义的字段在审计中会被隐藏
+func appendConfigChanges(changes []ConfigChange, key string, oldVal, newVal interface{}, applied bool) []ConfigChange {
+	ov := reflect.ValueOf(oldVal)
+	nv := reflect.ValueOf(newVal)
+
+	if ov.Kind() == reflect.Struct {
+		t := ov.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := field.Tag.Get("mapstructure")
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			fieldKey := key + "." + name
+			of := ov.Field(i).Interface()
+			nf := nv.Field(i).Interface()
+			if reflect.DeepEqual(of, nf) {
+				continue
+			}
+			change := ConfigChange{Key: fieldKey, Applied: applied}
+			if field.Tag.Get("json") == "-" || isSecretConfigKey(fieldKey) {
+				change.Old, change.New = "***", "***"
+			} else {
+				change.Old, change.New = fmt.Sprint(of), fmt.Sprint(nf)
+			}
+			changes = append(changes, change)
+		}
+		return changes
+	}
+
+	if reflect.DeepEqual(oldVal, newVal) {
+		return changes
+	}
+	change := ConfigChange{Key: key, Applied: applied}
+	if isSecretConfigKey(key) {
+		change.Old, change.New = "***", "***"
+	} else {
+		change.Old, change.New = fmt.Sprint(oldVal), fmt.Sprint(newVal)
+	}
+	return append(changes, change)
+}
+
+// isSecretConfigKey 判断配置项是否为不应写入日志的敏感信息
+func isSecretConfigKey(key string) bool {
+	for _, word := range []string{"secret", "pass", "api_key", "token", "credentials"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditConfigChange 记录配置变更审计日志
+func auditConfigChange(change ConfigChange) {
+	status := "已生效"
+	if !change.Applied {
+		status = "需重启生效"
+	}
+	log.Printf("[配置审计] %s: %s -> %s (%s)", change.Key, change.Old, change.New, status)
+	_ = appendErrorLog("config-audit.log", fmt.Sprintf("%s\t%s\t%s\t%s\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), change.Key, change.Old, change.New, status))
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"subtuber-services/pathsafe"
+)
+
+const (
+	driftFileName = "drift.json"
+	// driftMaxLag 互相关搜索的最大延迟（秒）
+	driftMaxLag = 30
+	// driftMinScore 采用一次估计所需的最小相关系数
+	driftMinScore = 0.2
+	// defaultChatReactionLatency 观众看到内容到发出弹幕的平均延迟（秒）
+	defaultChatReactionLatency = 4.0
+)
+
+// DriftSample 单个片段的延迟估计
+type DriftSample struct {
+	HotMomentOffset float64 `json:"hot_moment_offset"`
+	Lag             float64 `json:"lag"`   // 聊天相对语音的延迟（秒），包含观众反应延迟
+	Score           float64 `json:"score"` // 相关系数
+}
+
+// VODDriftCalibration 录像的聊天时间偏差校准结果
+// 录像时间 = 聊天偏移 - Correction
+type VODDriftCalibration struct {
+	VideoID    string        `json:"video_id"`
+	Correction float64       `json:"correction"`
+	Samples    []DriftSample `json:"samples"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+var driftMu sync.Mutex
+
+// chatReactionLatency 返回配置的观众反应延迟
+func chatReactionLatency() float64 {
+	if latency := GetAnalysisConfig().ChatReactionLatencySeconds; latency > 0 {
+		return latency
+	}
+	return defaultChatReactionLatency
+}
+
+// GetVODDriftCorrection 返回录像的聊天时间偏差（秒），未校准时为 0
+func GetVODDriftCorrection(videoID string) float64 {
+	driftMu.Lock()
+	defer driftMu.Unlock()
+
+	calibration, err := loadVODDrift(videoID)
+	if err != nil || calibration == nil {
+		return 0
+	}
+	return calibration.Correction
+}
+
+// calibrateVODDrift 用片段的语音识别字幕与聊天记录做互相关，更新录像的时间偏差
+func calibrateVODDrift(videoID string, clip ClipSyncInfo, srtContent string) {
+	subtitles, err := ParseSRTDetailed(srtContent)
+	if err != nil || len(subtitles) == 0 {
+		return
+	}
+	chatOffsets, err := loadChatOffsetsForVideo(videoID)
+	if err != nil {
+		return
+	}
+
+	lag, score, ok := estimateChatLag(subtitles, chatOffsets, clip.VODStart, clip.Duration)
+	if !ok {
+		log.Printf("视频 %s 热点 %.0f 的时间偏差估计不可靠 (相关系数 %.2f)，已忽略", videoID, clip.HotMomentOffset, score)
+		return
+	}
+
+	driftMu.Lock()
+	defer driftMu.Unlock()
+
+	calibration, err := loadVODDrift(videoID)
+	if err != nil {
+		log.Printf("读取时间偏差校准失败: %v", err)
+		return
+	}
+	if calibration == nil {
+		calibration = &VODDriftCalibration{VideoID: videoID}
+	}
+
+	sample := DriftSample{HotMomentOffset: clip.HotMomentOffset, Lag: lag, Score: score}
+	replaced := false
+	for i := range calibration.Samples {
+		if math.Abs(calibration.Samples[i].HotMomentOffset-clip.HotMomentOffset) < 1 {
+			calibration.Samples[i] = sample
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		calibration.Samples = append(calibration.Samples, sample)
+	}
+
+	// 取各片段延迟的中位数，减去观众反应延迟即为聊天时间的偏差
+	lags := make([]float64, 0, len(calibration.Samples))
+	for _, s := range calibration.Samples {
+		lags = append(lags, s.Lag)
+	}
+	calibration.Correction = median(lags) - chatReactionLatency()
+	calibration.UpdatedAt = time.Now()
+
+	if err := saveVODDrift(calibration); err != nil {
+		log.Printf("保存时间偏差校准失败: %v", err)
+		return
+	}
+	log.Printf("视频 %s 聊天时间偏差校准为 %.1f 秒 (样本 %d 个)", videoID, calibration.Correction, len(calibration.Samples))
+}
+
+// estimateChatLag 按秒统计字幕覆盖与聊天数量，在 ±driftMaxLag 范围内求相关系数最大的延迟
+func estimateChatLag(subtitles []SRTSubtitle, chatOffsets []float64, vodStart, duration float64) (float64, float64, bool) {
+	n := int(math.Ceil(duration))
+	if n <= 2*driftMaxLag {
+		return 0, 0, false
+	}
+
+	speech := make([]float64, n)
+	for _, sub := range subtitles {
+		start, err1 := parseSRTTime(sub.StartTime)
+		end, err2 := parseSRTTime(sub.EndTime)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		for t := int(start); t <= int(end) && t < n; t++ {
+			if t >= 0 {
+				speech[t] = 1
+			}
+		}
+	}
+
+	chat := make([]float64, n)
+	for _, offset := range chatOffsets {
+		t := int(offset - vodStart)
+		if t >= 0 && t < n {
+			chat[t]++
+		}
+	}
+
+	bestLag, bestScore := 0, math.Inf(-1)
+	for lag := -driftMaxLag; lag <= driftMaxLag; lag++ {
+		var xs, ys []float64
+		for t := 0; t < n; t++ {
+			if u := t + lag; u >= 0 && u < n {
+				xs = append(xs, speech[t])
+				ys = append(ys, chat[u])
+			}
+		}
+		if score := pearson(xs, ys); score > bestScore {
+			bestLag, bestScore = lag, score
+		}
+	}
+
+	return float64(bestLag), bestScore, bestScore >= driftMinScore
+}
+
+// pearson 计算两个序列的相关系数，任一序列无变化时返回 0
+func pearson(xs, ys []float64) float64 {
+	if len(xs) == 0 || len(xs) != len(ys) {
+		return 0
+	}
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// median 返回中位数
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// loadVODDrift 读取录像的时间偏差校准，不存在时返回 nil
+func loadVODDrift(videoID string) (*VODDriftCalibration, error) {
+	data, err := os.ReadFile(filepath.Join(pathsafe.Join("./analysis_results", videoID), driftFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var calibration VODDriftCalibration
+	if err := json.Unmarshal(data, &calibration); err != nil {
+		return nil, err
+	}
+	return &calibration, nil
+}
+
+// saveVODDrift 保存录像的时间偏差校准
+func saveVODDrift(calibration *VODDriftCalibration) error {
+	videoDir := pathsafe.Join("./analysis_results", calibration.VideoID)
+	if err := os.MkdirAll(videoDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(calibration, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(videoDir, driftFileName), data, 0644)
+}
+
+// GetVODDrift 返回录像的聊天时间偏差校准结果
+func GetVODDrift(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+
+	driftMu.Lock()
+	calibration, err := loadVODDrift(videoID)
+	driftMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取时间偏差校准失败: " + err.Error()})
+		return
+	}
+	if calibration == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "video_id": videoID, "calibrated": false, "correction": 0})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "video_id": videoID, "calibrated": true, "calibration": calibration})
+}
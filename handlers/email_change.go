@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"subtuber-services/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const emailChangeCodeTTL = 10 * time.Minute
+
+// pendingEmailChange 等待验证的邮箱变更
+type pendingEmailChange struct {
+	NewEmail string
+	Code     string
+}
+
+type emailChangeRequest struct {
+	NewEmail string `json:"new_email" binding:"required"`
+}
+
+type emailChangeVerifyRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// emailChangeMu 串行化邮箱变更，避免用户目录与各数据文件的迁移交错
+var emailChangeMu sync.Mutex
+
+// RequestEmailChange 向新邮箱发送验证码，验证通过后才会变更账号绑定的邮箱
+func RequestEmailChange(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil || userHash == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "未登录或登录已过期"})
+		return
+	}
+	if rejectImpersonated(c) {
+		return
+	}
+
+	var req emailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+		return
+	}
+	newEmail := strings.TrimSpace(req.NewEmail)
+	if !EmailRegex.MatchString(newEmail) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的邮箱地址。"})
+		return
+	}
+
+	newHash := computeSha256Hex(strings.ToLower(newEmail))
+	if newHash == userHash {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "新邮箱与当前邮箱相同"})
+		return
+	}
+	if userDirExists(newHash) {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "该邮箱已被其他账号使用"})
+		return
+	}
+
+	if GetSMTPConfig().Host == "" || !IsFeatureEnabled(FeatureEmail) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "message": "邮件服务未配置，无法验证新邮箱"})
+		return
+	}
+
+	code := generateNumericCode(6)
+	codeCache.Set("email:change:"+userHash, pendingEmailChange{NewEmail: newEmail, Code: code}, emailChangeCodeTTL)
+
+	body := fmt.Sprintf("您正在将账号绑定的邮箱更改为此地址，验证码为：%s（有效期 10 分钟）。\n如非本人操作请忽略此邮件。", code)
+	if err := sendMail(newEmail, "验证您的新邮箱", body); err != nil {
+		codeCache.Delete("email:change:" + userHash)
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "message": "验证邮件发送失败，请稍后重试"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "验证码已发送到新邮箱"})
+}
+
+// VerifyEmailChange 校验新邮箱的验证码，迁移用户数据并更新登录状态
+func VerifyEmailChange(c *gin.Context) {
+	oldHash, err := getUserHashFromCookie(c)
+	if err != nil || oldHash == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "未登录或登录已过期"})
+		return
+	}
+	if rejectImpersonated(c) {
+		return
+	}
+
+	var req emailChangeVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+		return
+	}
+
+	key := "email:change:" + oldHash
+	v, found := codeCache.Get(key)
+	pending, ok := v.(pendingEmailChange)
+	if !found || !ok || pending.Code != strings.TrimSpace(req.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "验证码错误或已过期。请重新发送验证码并重试。"})
+		return
+	}
+	codeCache.Delete(key)
+
+	user, err := changeUserEmail(oldHash, pending.NewEmail)
+	if err != nil {
+		log.Printf("用户 %s 更换邮箱失败: %v", oldHash, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "更换邮箱失败: " + err.Error()})
+		return
+	}
+
+	// userHash 随邮箱变化，换发会话令牌
+	if _, _, err := setSessionCookie(c, user); err != nil {
+		log.Printf("为用户 %s 换发会话令牌失败: %v", user.UserId, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "邮箱已更换", "user": user})
+}
+
+// changeUserEmail 更新数据层中的用户，并把本地以 userHash 为键的数据迁移到新邮箱对应的 userHash
+func changeUserEmail(oldHash, newEmail string) (userModel, error) {
+	emailChangeMu.Lock()
+	defer emailChangeMu.Unlock()
+
+	newHash := computeSha256Hex(strings.ToLower(newEmail))
+	if userDirExists(newHash) {
+		return userModel{}, fmt.Errorf("该邮箱已被其他账号使用")
+	}
+
+	oldDir := filepath.Join("App_Data", oldHash)
+	newDir := filepath.Join("App_Data", newHash)
+
+	var user userModel
+	if b, err := os.ReadFile(filepath.Join(oldDir, "user.json")); err == nil {
+		_ = json.Unmarshal(b, &user)
+	}
+	oldEmail := user.Email
+
+	// 先更新数据层，失败时本地数据保持不变
+	if services.GetStreamerService() != nil {
+		if err := services.UpdateUserEmailRPC(oldHash, newHash, newEmail); err != nil {
+			return userModel{}, err
+		}
+	}
+
+	if err := os.Rename(oldDir, newDir); err != nil && !os.IsNotExist(err) {
+		return userModel{}, fmt.Errorf("迁移用户目录失败: %v", err)
+	}
+	if err := os.MkdirAll(newDir, 0o755); err != nil {
+		return userModel{}, err
+	}
+
+	user.UserId = newHash
+	user.Email = newEmail
+	if user.DisplayName == "" {
+		user.DisplayName = strings.Split(newEmail, "@")[0]
+	}
+	if user.RegisteredAt.IsZero() {
+		user.RegisteredAt = time.Now().UTC()
+	}
+	if b, err := json.MarshalIndent(user, "", "  "); err == nil {
+		_ = os.WriteFile(filepath.Join(newDir, "user.json"), b, 0o644)
+	}
+	_ = os.WriteFile(filepath.Join(newDir, "email.txt"), []byte(newEmail), 0o644)
+
+	// 其他以 userHash 为键的数据，单项失败只记录日志
+	migrations := map[string]func(string, string) error{
+		"subscription_preferences": renameSubscriptionPreferencesUser,
+		"user_last_seen":           renameUserLastSeen,
+		"notification_digest":      renameNotificationDigestUser,
+		"notifications":            renameNotificationsUser,
+		"twitch_link":              renameLinkedTwitchAccount,
+		"streamer_claims":          renameStreamerClaimsUser,
+	}
+	for name, migrate := range migrations {
+		if err := migrate(oldHash, newHash); err != nil {
+			log.Printf("迁移用户数据 %s 失败 (%s -> %s): %v", name, oldHash, newHash, err)
+		}
+	}
+	renameUsageUser(oldHash, newHash)
+	if _, ok := GetUsageConfig().UserPlans[oldHash]; ok {
+		log.Printf("警告: 用户 %s 在配置中指定了套餐，请将 usage.user_plans 中的键更新为 %s", oldHash, newHash)
+	}
+
+	_ = appendErrorLog("email-changes.log", fmt.Sprintf("%s\t%s\t%s\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), oldHash, newHash, newEmail))
+
+	// 通知原邮箱，便于发现非本人操作
+	if oldEmail != "" && GetSMTPConfig().Host != "" && IsFeatureEnabled(FeatureEmail) {
+		go func() {
+			_ = sendMail(oldEmail, "账号邮箱已更换", fmt.Sprintf("您的账号绑定邮箱已更换为 %s。如非本人操作，请立即联系管理员。", newEmail))
+		}()
+	}
+
+	log.Printf("用户 %s 已更换邮箱，新的用户ID: %s", oldHash, newHash)
+	return user, nil
+}
+
+// userDirExists 检查 App_Data 下是否已有该用户的目录
+func userDirExists(userHash string) bool {
+	_, err := os.Stat(filepath.Join("App_Data", userHash, "user.json"))
+	return err == nil
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"subtuber-services/pathsafe"
+)
+
+const (
+	embedDefaultWidth  = 640
+	embedDefaultHeight = 400
+	embedMaxAge        = 300 // 嵌入页与 oEmbed 响应的缓存时间（秒）
+)
+
+// embedURLPattern oEmbed 请求中的嵌入页地址
+var embedURLPattern = regexp.MustCompile(`/embed/moments/([^/]+)/([0-9.]+)/?$`)
+
+// embedMoment 嵌入页展示的热点
+type embedMoment struct {
+	VideoID       string
+	OffsetSeconds float64
+	FormattedTime string
+	Title         string
+	StreamerName  string
+	Summary       string
+	VODURL        string // 原录像在热点位置的链接
+	ThumbnailURL  string
+	ClipPath      string // 本地片段文件，不存在时为空
+	ColdClipFile  string // 已移到冷存储的片段文件名，访问时重定向到签名链接
+}
+
+// loadEmbedMoment 读取可公开嵌入的热点，遵循与公开主页相同的可见性规则：
+// 主播设置为不公开、非公开录像、被认领者隐藏或未审核的热点、已下架的片段都不可嵌入
+// 返回的状态码用于不可嵌入时的响应
+func loadEmbedMoment(videoID string, offsetSeconds float64) (*embedMoment, int) {
+	videoID = filepath.Base(videoID)
+	videoDir := pathsafe.Join("./analysis_results", videoID)
+	data, err := os.ReadFile(filepath.Join(videoDir, analysisResultFileName(defaultPeakParams)))
+	if err != nil {
+		return nil, http.StatusNotFound
+	}
+	var result AnalysisResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, http.StatusNotFound
+	}
+
+	if result.VideoInfo.Viewable != "" && result.VideoInfo.Viewable != "public" {
+		return nil, http.StatusNotFound
+	}
+	streamerID := result.StreamerName
+	if streamer := ResolveStreamer(result.StreamerName); streamer != nil {
+		if streamer.Visibility == "private" {
+			return nil, http.StatusNotFound
+		}
+		streamerID = streamer.ID
+	}
+
+	moments := result.HotMoments
+	if claim := GetStreamerClaim(streamerID); claim != nil {
+		moments = claim.visibleMoments(videoID, moments)
+	}
+	var moment *VodCommentData
+	for i := range moments {
+		if math.Abs(moments[i].OffsetSeconds-offsetSeconds) < 1 {
+			moment = &moments[i]
+			break
+		}
+	}
+	if moment == nil {
+		return nil, http.StatusNotFound
+	}
+	if IsTakenDown(ArtifactClip, videoID, moment.OffsetSeconds) {
+		return nil, http.StatusGone
+	}
+
+	em := &embedMoment{
+		VideoID:       videoID,
+		OffsetSeconds: moment.OffsetSeconds,
+		FormattedTime: moment.FormattedTime,
+		Title:         result.VideoInfo.Title,
+		StreamerName:  result.VideoInfo.UserName,
+		ThumbnailURL:  strings.NewReplacer("%{width}", "640", "%{height}", "360").Replace(result.VideoInfo.ThumbnailURL),
+	}
+	if em.StreamerName == "" {
+		em.StreamerName = result.StreamerName
+	}
+	if em.FormattedTime == "" {
+		em.FormattedTime = formatDuration(moment.OffsetSeconds)
+	}
+	if !IsTakenDown(ArtifactSummary, videoID, moment.OffsetSeconds) {
+		em.Summary = readSummaryForOffset(videoDir, moment.OffsetSeconds)
+	}
+	if result.VideoInfo.URL != "" {
+		em.VODURL = vodURLAtOffset(result.VideoInfo.URL, moment.OffsetSeconds)
+	}
+
+	if clips, err := loadClipSync(videoID); err == nil {
+		for _, clip := range clips {
+			if math.Abs(clip.HotMomentOffset-moment.OffsetSeconds) >= 1 || clip.ClipFile == "" {
+				continue
+			}
+			path := filepath.Join(pathsafe.Join(hotClipsDir, videoID), filepath.Base(clip.ClipFile))
+			if _, err := os.Stat(path); err == nil {
+				em.ClipPath = path
+			} else if _, ok := lookupColdClip(videoID, clip.ClipFile); ok {
+				em.ColdClipFile = filepath.Base(clip.ClipFile)
+			}
+			break
+		}
+	}
+	return em, http.StatusOK
+}
+
+// vodURLAtOffset 在录像链接上加上跳转到热点的时间参数
+func vodURLAtOffset(vodURL string, offsetSeconds float64) string {
+	u, err := url.Parse(vodURL)
+	if err != nil {
+		return vodURL
+	}
+	q := u.Query()
+	seconds := int(offsetSeconds)
+	if strings.Contains(u.Host, "youtube") || strings.Contains(u.Host, "youtu.be") {
+		q.Set("t", strconv.Itoa(seconds))
+	} else {
+		q.Set("t", fmt.Sprintf("%dh%dm%ds", seconds/3600, seconds%3600/60, seconds%60))
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// requestBaseURL 对外访问的地址，优先使用配置的 server.public_base_url
+// 未配置时只使用请求的 Host，不信任客户端可伪造的 X-Forwarded-* 头
+func requestBaseURL(c *gin.Context) string {
+	if base := GetServerConfig().PublicBaseURL; base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// embedCacheControl 包含绝对链接的响应只有在配置了对外地址时才允许共享缓存，
+// 否则链接来自请求的 Host，只允许浏览器自身缓存
+func embedCacheControl() string {
+	if GetServerConfig().PublicBaseURL != "" {
+		return fmt.Sprintf("public, max-age=%d", embedMaxAge)
+	}
+	return fmt.Sprintf("private, max-age=%d", embedMaxAge)
+}
+
+// embedPath 热点嵌入页的路径
+func embedPath(videoID string, offsetSeconds float64) string {
+	return fmt.Sprintf("/embed/moments/%s/%s", url.PathEscape(videoID), strconv.FormatFloat(offsetSeconds, 'f', -1, 64))
+}
+
+var embedPageTemplate = template.Must(template.New("embed").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - {{.FormattedTime}}</title>
+<meta property="og:type" content="video.other">
+<meta property="og:site_name" content="LumiTime">
+<meta property="og:title" content="{{.StreamerName}} · {{.FormattedTime}} · {{.Title}}">
+{{if .Summary}}<meta property="og:description" content="{{.Summary}}">{{end}}
+{{if .ThumbnailURL}}<meta property="og:image" content="{{.ThumbnailURL}}">{{end}}
+<meta property="og:url" content="{{.PageURL}}">
+{{if .ClipURL}}<meta property="og:video" content="{{.ClipURL}}">
+<meta property="og:video:type" content="video/mp4">
+<meta name="twitter:card" content="player">
+<meta name="twitter:player" content="{{.PageURL}}">
+<meta name="twitter:player:width" content="{{.Width}}">
+<meta name="twitter:player:height" content="{{.Height}}">{{else}}<meta name="twitter:card" content="summary_large_image">{{end}}
+<link rel="alternate" type="application/json+oembed" href="{{.OEmbedURL}}" title="{{.Title}}">
+<style>
+body{margin:0;font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",sans-serif;background:#0e0e10;color:#efeff1}
+video,img{display:block;width:100%;max-height:70vh;background:#000}
+.meta{padding:8px 12px}
+.meta h1{font-size:15px;margin:0 0 4px}
+.meta p{font-size:13px;line-height:1.5;margin:4px 0;color:#adadb8}
+a{color:#bf94ff}
+</style>
+</head>
+<body>
+{{if .ClipURL}}<video src="{{.ClipURL}}" controls preload="metadata"{{if .ThumbnailURL}} poster="{{.ThumbnailURL}}"{{end}}></video>
+{{else if .ThumbnailURL}}<a href="{{.VODURL}}" target="_blank" rel="noopener"><img src="{{.ThumbnailURL}}" alt="{{.Title}}"></a>{{end}}
+<div class="meta">
+<h1>{{.StreamerName}} · {{.FormattedTime}}</h1>
+<p>{{.Title}}</p>
+{{if .Summary}}<p>{{.Summary}}</p>{{end}}
+{{if .VODURL}}<p><a href="{{.VODURL}}" target="_blank" rel="noopener">观看原录像</a></p>{{end}}
+</div>
+</body>
+</html>
+`))
+
+// parseEmbedParams 解析嵌入页路径中的视频ID与偏移
+func parseEmbedParams(c *gin.Context) (string, float64, bool) {
+	offset, err := strconv.ParseFloat(c.Param("offset"), 64)
+	if err != nil || offset < 0 {
+		c.String(http.StatusBadRequest, "offset 参数无效")
+		return "", 0, false
+	}
+	return filepath.Base(c.Param("videoID")), offset, true
+}
+
+// GetMomentEmbed 热点嵌入页：播放热点片段并展示AI总结，可用于博客 iframe 嵌入与 Discord 等链接预览
+func GetMomentEmbed(c *gin.Context) {
+	videoID, offset, ok := parseEmbedParams(c)
+	if !ok {
+		return
+	}
+	moment, status := loadEmbedMoment(videoID, offset)
+	if moment == nil {
+		if status == http.StatusGone {
+			c.String(status, "该片段因违规举报已下架")
+			return
+		}
+		c.String(status, "热点不存在或未公开")
+		return
+	}
+
+	base := requestBaseURL(c)
+	pageURL := base + embedPath(moment.VideoID, moment.OffsetSeconds)
+	clipURL := ""
+	if moment.ClipPath != "" || moment.ColdClipFile != "" {
+		clipURL = pageURL + "/clip"
+	}
+
+	// 允许任意站点通过 iframe 嵌入
+	c.Header("Content-Security-Policy", "frame-ancestors *")
+	c.Header("Cache-Control", embedCacheControl())
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	_ = embedPageTemplate.Execute(c.Writer, gin.H{
+		"Title":         moment.Title,
+		"StreamerName":  moment.StreamerName,
+		"FormattedTime": moment.FormattedTime,
+		"Summary":       moment.Summary,
+		"ThumbnailURL":  moment.ThumbnailURL,
+		"VODURL":        moment.VODURL,
+		"ClipURL":       clipURL,
+		"PageURL":       pageURL,
+		"OEmbedURL":     base + "/oembed?format=json&url=" + url.QueryEscape(pageURL),
+		"Width":         embedDefaultWidth,
+		"Height":        embedDefaultHeight,
+	})
+}
+
+// GetMomentEmbedClip 嵌入页播放的热点片段文件
+func GetMomentEmbedClip(c *gin.Context) {
+	videoID, offset, ok := parseEmbedParams(c)
+	if !ok {
+		return
+	}
+	moment, status := loadEmbedMoment(videoID, offset)
+	if moment != nil && moment.ClipPath == "" && moment.ColdClipFile != "" {
+		// 冷存储的片段重定向到限时签名链接，重定向本身不缓存
+		if signedURL := coldClipURL(moment.VideoID, moment.ColdClipFile); signedURL != "" {
+			c.Header("Cache-Control", "no-store")
+			c.Redirect(http.StatusFound, signedURL)
+			return
+		}
+	}
+	if moment == nil || moment.ClipPath == "" {
+		if status == http.StatusOK {
+			status = http.StatusNotFound
+		}
+		c.String(status, "片段不存在或未公开")
+		return
+	}
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", embedMaxAge))
+	c.File(moment.ClipPath)
+}
+
+// GetOEmbed oEmbed 接口（https://oembed.com），为嵌入页地址返回 iframe 嵌入代码
+func GetOEmbed(c *gin.Context) {
+	if format := c.DefaultQuery("format", "json"); format != "json" {
+		c.JSON(http.StatusNotImplemented, gin.H{"success": false, "message": "只支持 json 格式"})
+		return
+	}
+
+	u, err := url.Parse(c.Query("url"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "无效的 url 参数"})
+		return
+	}
+	match := embedURLPattern.FindStringSubmatch(u.Path)
+	if match == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "url 不是热点嵌入页地址"})
+		return
+	}
+	videoID, _ := url.PathUnescape(match[1])
+	offset, err := strconv.ParseFloat(match[2], 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "无效的偏移"})
+		return
+	}
+
+	moment, status := loadEmbedMoment(videoID, offset)
+	if moment == nil {
+		// oEmbed 规范要求不可公开的资源返回 401/404
+		if status == http.StatusGone {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"success": false, "message": "热点不存在或未公开"})
+		return
+	}
+
+	width, height := embedDefaultWidth, embedDefaultHeight
+	if w, err := strconv.Atoi(c.Query("maxwidth")); err == nil && w > 0 && w < width {
+		height = height * w / width
+		width = w
+	}
+	if h, err := strconv.Atoi(c.Query("maxheight")); err == nil && h > 0 && h < height {
+		width = width * h / height
+		height = h
+	}
+
+	base := requestBaseURL(c)
+	pageURL := base + embedPath(moment.VideoID, moment.OffsetSeconds)
+	resp := gin.H{
+		"version":       "1.0",
+		"type":          "video",
+		"provider_name": "LumiTime",
+		"provider_url":  base,
+		"title":         fmt.Sprintf("%s · %s · %s", moment.StreamerName, moment.FormattedTime, moment.Title),
+		"author_name":   moment.StreamerName,
+		"width":         width,
+		"height":        height,
+		"cache_age":     embedMaxAge,
+		"html": fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" allowfullscreen></iframe>`,
+			template.HTMLEscapeString(pageURL), width, height),
+	}
+	if moment.ThumbnailURL != "" {
+		resp["thumbnail_url"] = moment.ThumbnailURL
+	}
+	c.Header("Cache-Control", embedCacheControl())
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"subtuber-services/chatdownload"
+	"subtuber-services/models"
+	"subtuber-services/pathsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// topEmotesPerMoment 每个热点附带的最常用表情数
+	topEmotesPerMoment = 5
+	// defaultEmoteWindowSeconds 表情统计接口默认的窗口长度（秒）
+	defaultEmoteWindowSeconds = 300
+
+	emotesFileName = "emotes.json"
+)
+
+// EmoteCount 一个表情在一段时间内被使用的消息数
+type EmoteCount struct {
+	Emote string `json:"emote"`        // 表情名称（YouTube 自定义表情去掉两侧的冒号）
+	ID    string `json:"id,omitempty"` // Twitch 表情 ID，可用于拼接表情图片地址
+	Count int    `json:"count"`
+}
+
+// emoteSecond 一秒内各表情被使用的消息数，缓存中只保存有表情的秒
+type emoteSecond struct {
+	Offset int            `json:"t"`
+	Counts map[string]int `json:"counts"`
+}
+
+// emotesCache 录像表情统计的缓存文件，与峰值检测参数无关，聊天记录未变化时复用
+type emotesCache struct {
+	ChatLogModTime int64             `json:"chat_log_mod_time"`
+	IDs            map[string]string `json:"ids,omitempty"` // 表情名称 -> Twitch 表情 ID
+	Seconds        []emoteSecond     `json:"seconds"`
+}
+
+// emoteUsage 按秒统计的表情使用情况
+type emoteUsage struct {
+	ids     map[string]string
+	seconds []emoteSecond
+}
+
+// chatMessageEmotes 消息中的表情（按名称去重），返回名称与表情 ID
+// Twitch 使用平台标记的表情；YouTube 聊天记录没有表情信息，从文本中识别
+func chatMessageEmotes(msg models.ChatMessage) map[string]string {
+	if msg.Platform == models.ChatPlatformYouTube {
+		return youtubeMessageEmotes(msg.Text)
+	}
+	emotes := map[string]string{}
+	for _, emote := range msg.Emotes {
+		emotes[emote.Name] = emote.ID
+	}
+	return emotes
+}
+
+// youtubeMessageEmotes YouTube 消息中的自定义表情（:name:）与 emoji（按名称去重）
+func youtubeMessageEmotes(message string) map[string]string {
+	emotes := map[string]string{}
+	for _, token := range strings.Fields(message) {
+		if isEmoteToken(token) {
+			emotes[strings.Trim(token, ":")] = ""
+		}
+	}
+	return emotes
+}
+
+// countEmotes 统计按秒的表情使用情况，同一条消息中重复的表情只计一次
+func countEmotes(add func(record func(offset float64, emotes map[string]string))) emoteUsage {
+	usage := emoteUsage{ids: map[string]string{}}
+	bySecond := map[int]map[string]int{}
+	add(func(offset float64, emotes map[string]string) {
+		second := int(math.Floor(offset))
+		if second < 0 || len(emotes) == 0 {
+			return
+		}
+		counts, ok := bySecond[second]
+		if !ok {
+			counts = map[string]int{}
+			bySecond[second] = counts
+		}
+		for name, id := range emotes {
+			counts[name]++
+			if id != "" {
+				usage.ids[name] = id
+			}
+		}
+	})
+
+	usage.seconds = make([]emoteSecond, 0, len(bySecond))
+	for second, counts := range bySecond {
+		usage.seconds = append(usage.seconds, emoteSecond{Offset: second, Counts: counts})
+	}
+	sort.Slice(usage.seconds, func(i, j int) bool { return usage.seconds[i].Offset < usage.seconds[j].Offset })
+	return usage
+}
+
+// loadEmoteUsageForVideo 读取录像聊天记录并统计表情
+func loadEmoteUsageForVideo(videoID string) (emoteUsage, error) {
+	var err error
+	usage := countEmotes(func(record func(float64, map[string]string)) {
+		err = eachChatMessage(videoID, func(msg models.ChatMessage) {
+			record(msg.OffsetSeconds, chatMessageEmotes(msg))
+		})
+	})
+	if err != nil {
+		return emoteUsage{}, err
+	}
+	return usage, nil
+}
+
+// emoteUsageForVideo 返回录像按秒的表情统计，优先读取 analysis_results/{videoID}/emotes.json，
+// 不存在或聊天记录已更新时重新统计并保存
+func emoteUsageForVideo(videoID string) (emoteUsage, error) {
+	logPath := chatdownload.FindTwitchLog(videoID)
+	if logPath == "" {
+		logPath = chatdownload.FindYouTubeLog(videoID)
+	}
+	if logPath == "" {
+		return emoteUsage{}, os.ErrNotExist
+	}
+	info, err := os.Stat(logPath)
+	if err != nil {
+		return emoteUsage{}, err
+	}
+
+	cachePath := filepath.Join(pathsafe.Join("./analysis_results", videoID), emotesFileName)
+	if data, err := os.ReadFile(cachePath); err == nil {
+		var cache emotesCache
+		if json.Unmarshal(data, &cache) == nil && cache.ChatLogModTime == info.ModTime().Unix() && cache.Seconds != nil {
+			return emoteUsage{ids: cache.IDs, seconds: cache.Seconds}, nil
+		}
+	}
+
+	usage, err := loadEmoteUsageForVideo(videoID)
+	if err != nil {
+		return emoteUsage{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return usage, nil
+	}
+	data, err := json.Marshal(emotesCache{ChatLogModTime: info.ModTime().Unix(), IDs: usage.ids, Seconds: usage.seconds})
+	if err == nil {
+		err = os.WriteFile(cachePath, data, 0644)
+	}
+	if err != nil {
+		log.Printf("保存录像 %s 的表情统计失败: %v", videoID, err)
+	}
+	return usage, nil
+}
+
+// top 统计 [start, end) 秒内使用最多的 n 个表情，次数相同时按名称排序
+func (u emoteUsage) top(start, end float64, n int) []EmoteCount {
+	totals := map[string]int{}
+	i := sort.Search(len(u.seconds), func(i int) bool { return float64(u.seconds[i].Offset) >= start })
+	for ; i < len(u.seconds) && float64(u.seconds[i].Offset) < end; i++ {
+		for name, count := range u.seconds[i].Counts {
+			totals[name] += count
+		}
+	}
+
+	emotes := make([]EmoteCount, 0, len(totals))
+	for name, count := range totals {
+		emotes = append(emotes, EmoteCount{Emote: name, ID: u.ids[name], Count: count})
+	}
+	sort.Slice(emotes, func(i, j int) bool {
+		if emotes[i].Count != emotes[j].Count {
+			return emotes[i].Count > emotes[j].Count
+		}
+		return emotes[i].Emote < emotes[j].Emote
+	})
+	if n > 0 && len(emotes) > n {
+		emotes = emotes[:n]
+	}
+	return emotes
+}
+
+// annotateTopEmotes 为热点时刻附加窗口内最常用的表情，没有聊天记录时不做处理
+func annotateTopEmotes(videoID string, hotMoments []VodCommentData, windowsLen int) {
+	if len(hotMoments) == 0 {
+		return
+	}
+	usage, err := emoteUsageForVideo(videoID)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("统计录像 %s 的表情失败: %v", videoID, err)
+		}
+		return
+	}
+
+	for i := range hotMoments {
+		// 与情绪分布相同，窗口以峰值为中心，多尺度检测时使用主导尺度
+		window := float64(windowsLen)
+		if hotMoments[i].Scale > 0 {
+			window = float64(hotMoments[i].Scale)
+		}
+		offset := hotMoments[i].OffsetSeconds
+		hotMoments[i].TopEmotes = usage.top(offset-window/2, offset+window/2+1, topEmotesPerMoment)
+	}
+}
+
+// EmoteWindow 一个统计窗口内最常用的表情
+type EmoteWindow struct {
+	StartSeconds   float64      `json:"start_seconds"`
+	EndSeconds     float64      `json:"end_seconds"`
+	FormattedStart string       `json:"formatted_start"`
+	Emotes         []EmoteCount `json:"emotes"`
+}
+
+// GetEmoteStats 按时间窗口统计录像弹幕中的表情使用情况（Twitch 表情、YouTube 自定义表情与 emoji）
+// window 为窗口长度（秒，默认 300），top 为每个窗口返回的表情数（默认 5）；同一条消息中重复的表情只计一次
+// GET /api/analysis/:videoID/emotes
+func GetEmoteStats(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+
+	window := defaultEmoteWindowSeconds
+	if v := c.Query("window"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 10 || n > 3600 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "window 必须为 10 到 3600 之间的秒数"})
+			return
+		}
+		window = n
+	}
+	top := topEmotesPerMoment
+	if v := c.Query("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "top 必须为 1 到 50 之间的整数"})
+			return
+		}
+		top = n
+	}
+
+	usage, err := emoteUsageForVideo(videoID)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "未找到该录像的聊天记录"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计表情失败: " + err.Error()})
+		return
+	}
+
+	windows := []EmoteWindow{}
+	if n := len(usage.seconds); n > 0 {
+		last := float64(usage.seconds[n-1].Offset)
+		for start := 0.0; start <= last; start += float64(window) {
+			emotes := usage.top(start, start+float64(window), top)
+			if len(emotes) == 0 {
+				continue
+			}
+			windows = append(windows, EmoteWindow{
+				StartSeconds:   start,
+				EndSeconds:     start + float64(window),
+				FormattedStart: formatDuration(start),
+				Emotes:         emotes,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id": videoID,
+		"window":   window,
+		"overall":  usage.top(0, math.Inf(1), top),
+		"windows":  windows,
+	})
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const featureFlagsFile = "App_Data/feature_flags.json"
+
+// 功能开关名称
+const (
+	FeatureTwitchMonitoring  = "twitch_monitoring"
+	FeatureYouTubeMonitoring = "youtube_monitoring"
+	FeatureClipDownload      = "clip_download"
+	FeatureASR               = "asr"
+	FeatureAISummary         = "ai_summary"
+	FeatureSummaryEval       = "summary_eval"  // 生成总结后由AI自评质量
+	FeatureClipMetadata      = "clip_metadata" // 生成总结后由AI生成片段标题、话题标签与简介
+	FeatureEmail             = "email"
+)
+
+// knownFeatures 所有支持的功能开关，未配置时默认开启
+var knownFeatures = []string{
+	FeatureTwitchMonitoring,
+	FeatureYouTubeMonitoring,
+	FeatureClipDownload,
+	FeatureASR,
+	FeatureAISummary,
+	FeatureSummaryEval,
+	FeatureClipMetadata,
+	FeatureEmail,
+}
+
+var (
+	featureFlagsMu sync.RWMutex
+	featureFlags   = defaultFeatureFlags()
+)
+
+// defaultFeatureFlags 返回全部开启的功能开关
+func defaultFeatureFlags() map[string]bool {
+	flags := make(map[string]bool, len(knownFeatures))
+	for _, name := range knownFeatures {
+		flags[name] = true
+	}
+	return flags
+}
+
+// isKnownFeature 检查功能开关名称是否受支持
+func isKnownFeature(name string) bool {
+	for _, known := range knownFeatures {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// InitFeatureFlags 使用配置文件中的值初始化功能开关
+// 通过管理接口修改并持久化的值优先于配置文件
+func InitFeatureFlags(configured map[string]bool) {
+	flags := defaultFeatureFlags()
+	for name, enabled := range configured {
+		if !isKnownFeature(name) {
+			log.Printf("警告: 未知的功能开关 %s，已忽略", name)
+			continue
+		}
+		flags[name] = enabled
+	}
+
+	persisted, err := loadFeatureFlags()
+	if err != nil {
+		log.Printf("读取功能开关文件失败: %v", err)
+	}
+	for name, enabled := range persisted {
+		if isKnownFeature(name) {
+			flags[name] = enabled
+		}
+	}
+
+	featureFlagsMu.Lock()
+	featureFlags = flags
+	featureFlagsMu.Unlock()
+
+	for _, name := range knownFeatures {
+		if !flags[name] {
+			log.Printf("功能 %s 已禁用", name)
+		}
+	}
+}
+
+// IsFeatureEnabled 检查功能是否开启
+func IsFeatureEnabled(name string) bool {
+	featureFlagsMu.RLock()
+	defer featureFlagsMu.RUnlock()
+
+	enabled, ok := featureFlags[name]
+	return !ok || enabled
+}
+
+// GetFeatureFlags 返回当前所有功能开关的副本
+func GetFeatureFlags() map[string]bool {
+	featureFlagsMu.RLock()
+	defer featureFlagsMu.RUnlock()
+
+	flags := make(map[string]bool, len(featureFlags))
+	for name, enabled := range featureFlags {
+		flags[name] = enabled
+	}
+	return flags
+}
+
+// SetFeatureEnabled 修改功能开关并持久化到文件
+func SetFeatureEnabled(name string, enabled bool) error {
+	if !isKnownFeature(name) {
+		return fmt.Errorf("未知的功能开关: %s", name)
+	}
+
+	featureFlagsMu.Lock()
+	defer featureFlagsMu.Unlock()
+
+	old := featureFlags[name]
+	featureFlags[name] = enabled
+	if err := saveFeatureFlags(featureFlags); err != nil {
+		featureFlags[name] = old
+		return err
+	}
+
+	log.Printf("功能开关 %s 已设置为 %v", name, enabled)
+	return nil
+}
+
+// loadFeatureFlags 从文件加载持久化的功能开关，文件不存在时返回空
+func loadFeatureFlags() (map[string]bool, error) {
+	data, err := os.ReadFile(featureFlagsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]bool{}, nil
+		}
+		return nil, err
+	}
+
+	var flags map[string]bool
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// saveFeatureFlags 将功能开关写回文件
+func saveFeatureFlags(flags map[string]bool) error {
+	if err := os.MkdirAll(filepath.Dir(featureFlagsFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(flags, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(featureFlagsFile, data, 0644)
+}
+
+// sortedFeatureNames 返回排序后的功能开关名称
+func sortedFeatureNames(flags map[string]bool) []string {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
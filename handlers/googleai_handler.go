@@ -17,17 +17,26 @@ import (
 
 // GoogleAIService provides AI summarization and content generation capabilities
 type GoogleAIService struct {
-	apiKey string
+	apiKey          string
+	model           string
+	maxOutputTokens int
 }
 
 // NewGoogleAIService creates a new GoogleAI service instance
 // If apiKey is empty, it will use the configured API key from config
 func NewGoogleAIService(apiKey string) *GoogleAIService {
+	config := GetGoogleAPIConfig()
 	if apiKey == "" {
-		apiKey = GetGoogleAPIConfig().APIKey
+		apiKey = config.APIKey
+	}
+	model := config.Model
+	if model == "" {
+		model = googleAIModel
 	}
 	return &GoogleAIService{
-		apiKey: apiKey,
+		apiKey:          apiKey,
+		model:           model,
+		maxOutputTokens: config.MaxOutputTokens,
 	}
 }
 
@@ -47,88 +56,109 @@ func (s *GoogleAIService) GenerateContent(ctx context.Context, prompt string, ma
 		return "", errors.New("Google API key not configured")
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
-	defer cancel()
+	// 按提供商的自适应并发数排队，队列已满时返回 errAIBackpressure
+	return withAILimiter(ctx, "google", func(call *aiCall) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+		defer cancel()
 
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  s.apiKey,
-		Backend: genai.BackendGeminiAPI,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to create genai client: %w", err)
-	}
+		client, err := genai.NewClient(ctx, &genai.ClientConfig{
+			APIKey:     s.apiKey,
+			Backend:    genai.BackendGeminiAPI,
+			HTTPClient: newTracedHTTPClient(0),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create genai client: %w", err)
+		}
 
-	temp := float32(0.7)
-	generateCfg := &genai.GenerateContentConfig{
-		MaxOutputTokens: int32(maxOutputTokens),
-		Temperature:     &temp,
-	}
+		temp := float32(0.7)
+		generateCfg := &genai.GenerateContentConfig{
+			MaxOutputTokens: int32(capOutputTokens(maxOutputTokens, s.maxOutputTokens)),
+			Temperature:     &temp,
+		}
 
-	log.Printf("Calling Gemini API with maxOutputTokens: %d, prompt length: %d", maxOutputTokens, len(prompt))
+		log.Printf("Calling Gemini API with maxOutputTokens: %d, prompt length: %d", maxOutputTokens, len(prompt))
 
-	result, err := client.Models.GenerateContent(
-		ctx,
-		"gemini-2.5-flash-lite",
-		genai.Text(prompt),
-		generateCfg,
-	)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate content: %w", err)
-	}
+		result, err := client.Models.GenerateContent(
+			ctx,
+			s.model,
+			genai.Text(prompt),
+			generateCfg,
+		)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate content: %w", err)
+		}
 
-	text := result.Text()
-	if text == "" {
-		return "", errors.New("no generated text found in response")
-	}
+		text := result.Text()
+		if text == "" {
+			return "", errors.New("no generated text found in response")
+		}
 
-	log.Printf("Received response length: %d characters", len(text))
+		log.Printf("Received response length: %d characters", len(text))
 
-	return text, nil
+		return text, nil
+	})
 }
 
-// SummarizeSRT summarizes SRT subtitle content
-// Input: srtContent string (SRT file content), chunkChars int (size of each chunk, default 10000)
-// Output: final summary string, chunk summaries []string
-func (s *GoogleAIService) SummarizeSRT(ctx context.Context, srtContent string, chunkChars int) (string, []string, error) {
-	// Parse SRT content
-	transcript, err := parseSRTFile(srtContent)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to parse SRT file: %w", err)
+// GenerateContentStream generates content using the Gemini streaming API, calling onDelta with each piece of text as it arrives
+// Input: prompt string, maxOutputTokens int, onDelta callback
+// Output: the complete generated text string
+func (s *GoogleAIService) GenerateContentStream(ctx context.Context, prompt string, maxOutputTokens int, onDelta func(delta string) error) (string, error) {
+	if s.apiKey == "" {
+		return "", errors.New("Google API key not configured")
 	}
 
-	if chunkChars <= 0 {
-		chunkChars = 10000
-	}
+	// 按提供商的自适应并发数排队，队列已满时返回 errAIBackpressure
+	return withAILimiter(ctx, "google", func(call *aiCall) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+		defer cancel()
 
-	log.Printf("Parsed transcript length: %d characters", len(transcript))
+		client, err := genai.NewClient(ctx, &genai.ClientConfig{
+			APIKey:     s.apiKey,
+			Backend:    genai.BackendGeminiAPI,
+			HTTPClient: newTracedHTTPClient(0),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create genai client: %w", err)
+		}
 
-	// Split transcript into chunks
-	chunks := chunkText(transcript, chunkChars)
-	summaries := make([]string, 0, len(chunks))
+		temp := float32(0.7)
+		generateCfg := &genai.GenerateContentConfig{
+			MaxOutputTokens: int32(capOutputTokens(maxOutputTokens, s.maxOutputTokens)),
+			Temperature:     &temp,
+		}
 
-	// Summarize each chunk
-	for i, ch := range chunks {
-		prompt := "This is a clip from a streamer's live broadcast. To summarize, what topics are being discussed in this segment: \n\n" + ch
-		s, err := s.GenerateContent(ctx, prompt, 600)
-		if err != nil {
-			return "", nil, fmt.Errorf("failed to summarize chunk %d: %w", i, err)
+		log.Printf("Calling Gemini streaming API with maxOutputTokens: %d, prompt length: %d", maxOutputTokens, len(prompt))
+
+		var text strings.Builder
+		for result, err := range client.Models.GenerateContentStream(ctx, s.model, genai.Text(prompt), generateCfg) {
+			if err != nil {
+				return text.String(), fmt.Errorf("failed to generate content: %w", err)
+			}
+			delta := result.Text()
+			if delta == "" {
+				continue
+			}
+			call.delta()
+			text.WriteString(delta)
+			if err := onDelta(delta); err != nil {
+				return text.String(), err
+			}
+		}
+		if text.Len() == 0 {
+			return "", errors.New("no generated text found in response")
 		}
-		summaries = append(summaries, s)
-		log.Printf("Summarized chunk %d/%d", i+1, len(chunks))
 
-		// Small delay to avoid rate bursts
-		time.Sleep(200 * time.Millisecond)
-	}
+		log.Printf("Received streamed response length: %d characters", text.Len())
 
-	// Combine intermediate summaries and produce a final summary
-	combined := strings.Join(summaries, "\n\n")
-	finalPrompt := "Here are summaries of each section. Please consolidate them into a final summary, presenting key points in Chinese and keeping the length within 300 words: \n\n" + combined
-	finalSummary, err := s.GenerateContent(ctx, finalPrompt, 600)
-	if err != nil {
-		return "", summaries, fmt.Errorf("failed to produce final summary: %w", err)
-	}
+		return text.String(), nil
+	})
+}
 
-	return finalSummary, summaries, nil
+// SummarizeSRT summarizes SRT subtitle content
+// Input: srtContent string (SRT file content), chunkChars int (size of each chunk, 0 plans it from the transcript duration)
+// Output: final summary string, chunk summaries []string
+func (s *GoogleAIService) SummarizeSRT(ctx context.Context, srtContent string, chunkChars int) (string, []string, error) {
+	return summarizeSRTWithBudget(ctx, s, srtContent, chunkChars)
 }
 
 // SaveSummaryToFile saves the summary to a text file next to the subtitle file
@@ -146,48 +176,12 @@ func (s *GoogleAIService) SaveSummaryToFile(srtFilePath, summary string) error {
 	return nil
 }
 
-// chunkText splits text into chunks of approximately maxChars, respecting sentence boundaries when possible.
-func chunkText(text string, maxChars int) []string {
-	text = strings.TrimSpace(text)
-	if text == "" {
-		return nil
-	}
-	if len(text) <= maxChars {
-		return []string{text}
-	}
-
-	// Split by double newlines (subtitle entries)
-	entries := strings.Split(text, "\n\n")
-
-	var chunks []string
-	currentChunk := ""
-
-	for _, entry := range entries {
-		entry = strings.TrimSpace(entry)
-		if entry == "" {
-			continue
-		}
-
-		// If adding this entry would exceed maxChars, save current chunk and start new one
-		if currentChunk != "" && len(currentChunk)+len("\n\n")+len(entry) > maxChars {
-			chunks = append(chunks, strings.TrimSpace(currentChunk))
-			currentChunk = entry
-		} else {
-			// Add to current chunk
-			if currentChunk != "" {
-				currentChunk += "\n\n" + entry
-			} else {
-				currentChunk = entry
-			}
-		}
-	}
-
-	// Add the last chunk
-	if strings.TrimSpace(currentChunk) != "" {
-		chunks = append(chunks, strings.TrimSpace(currentChunk))
+// Probe 只检查是否配置了 API Key，不消耗生成额度
+func (s *GoogleAIService) Probe(ctx context.Context) error {
+	if s.apiKey == "" {
+		return errors.New("Google API key not configured")
 	}
-
-	return chunks
+	return nil
 }
 
 // parseSRTFile parses SRT subtitle content and returns the text transcript with timestamps
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HotMomentChapter 热点章节列表中的一个章节
+type HotMomentChapter struct {
+	OffsetSeconds float64 `json:"offset_seconds"`
+	Time          string  `json:"time"`              // YouTube 章节时间，例如 1:23:45
+	Title         string  `json:"title"`             // AI生成的片段标题，没有时为总结首行或“热点 #N”
+	Summary       string  `json:"summary,omitempty"` // AI总结的第一行摘录，已下架的总结不显示
+}
+
+// Line 章节在简介中的一行：时间 标题 – 总结摘录
+func (ch HotMomentChapter) Line() string {
+	if ch.Summary == "" || ch.Summary == ch.Title {
+		return ch.Time + " " + ch.Title
+	}
+	return ch.Time + " " + ch.Title + " – " + ch.Summary
+}
+
+// renderHotMomentChapters 生成可直接粘贴到视频简介的章节文本
+func renderHotMomentChapters(chapters []HotMomentChapter) string {
+	var b strings.Builder
+	for _, ch := range chapters {
+		b.WriteString(ch.Line())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// GetAnalysisChapters 将录像的热点生成 YouTube 风格的章节，便于主播粘贴到视频简介
+// 章节标题优先使用AI生成的片段标题，并附上AI总结的摘录；YouTube 至少需要 3 个章节才会识别
+// GET /api/analysis/:videoID/chapters?format=text|json
+func GetAnalysisChapters(c *gin.Context) {
+	videoID := c.Param("videoID")
+	format := c.DefaultQuery("format", "text")
+	if format != "text" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "不支持的格式: " + format + "（可选 text、json）",
+		})
+		return
+	}
+
+	params, err := peakParamsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	result, err := loadAnalysisResultForParams(videoID, params)
+	if err != nil {
+		respondAnalysisResultError(c, err)
+		return
+	}
+
+	// 与导出接口的 youtube-chapters 使用相同的标记与章节规则
+	chapters := youtubeChapters(buildExportMarkers(videoID, result.HotMoments, params))
+	text := renderHotMomentChapters(chapters)
+	if format == "text" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(text))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"video_id":      videoID,
+		"title":         result.VideoInfo.Title,
+		"chapters":      chapters,
+		"text":          text,
+		"youtube_ready": len(chapters) >= youtubeMinChapters,
+	})
+}
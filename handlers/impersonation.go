@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ImpersonationHeader 携带代入令牌的请求头，存在时以被代入用户的身份处理请求
+	ImpersonationHeader = "X-Impersonation-Token"
+
+	defaultImpersonationTTL = 15 * time.Minute
+	maxImpersonationTTL     = 60 * time.Minute
+)
+
+// Impersonation 管理员为排查问题签发的短期代入令牌
+type Impersonation struct {
+	ID         string    `json:"id"`
+	UserHash   string    `json:"user_hash"`
+	Operator   string    `json:"operator"` // 签发令牌的支持人员
+	Reason     string    `json:"reason"`
+	AllowWrite bool      `json:"allow_write"` // 默认只允许只读请求
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Uses       int       `json:"uses"`
+
+	tokenHash string
+}
+
+var (
+	impersonationsMu sync.Mutex
+	// impersonations 按令牌哈希索引，只保存在内存中，服务重启后全部失效
+	impersonations = map[string]*Impersonation{}
+)
+
+// impersonationAudit 写入代入审计日志
+func impersonationAudit(action string, imp *Impersonation, detail string) {
+	_ = appendErrorLog("impersonation-audit.log", fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\n",
+		time.Now().Format(time.RFC3339), action, imp.ID, imp.UserHash, imp.Operator, detail))
+}
+
+// pruneImpersonationsLocked 删除已过期的令牌，调用方需持有 impersonationsMu
+func pruneImpersonationsLocked(now time.Time) {
+	for hash, imp := range impersonations {
+		if now.After(imp.ExpiresAt) {
+			delete(impersonations, hash)
+		}
+	}
+}
+
+// impersonatedUser 校验请求中的代入令牌，返回被代入的用户
+// 令牌无效、已过期或只读令牌用于写请求时返回错误，不会退回到请求者自己的身份
+func impersonatedUser(c *gin.Context, token string) (string, error) {
+	now := time.Now()
+	hash := computeSha256Hex(token)
+
+	impersonationsMu.Lock()
+	pruneImpersonationsLocked(now)
+	imp, ok := impersonations[hash]
+	if ok {
+		imp.Uses++
+	}
+	var snapshot Impersonation
+	if ok {
+		snapshot = *imp
+	}
+	impersonationsMu.Unlock()
+
+	if !ok {
+		return "", fmt.Errorf("代入令牌无效或已过期")
+	}
+
+	method := c.Request.Method
+	readOnly := method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+	if !readOnly && !snapshot.AllowWrite {
+		impersonationAudit("DENY", &snapshot, method+" "+c.Request.URL.Path)
+		return "", fmt.Errorf("只读代入令牌不能用于 %s 请求", method)
+	}
+
+	impersonationAudit("USE", &snapshot, method+" "+c.Request.URL.Path)
+	c.Header("X-Impersonating", snapshot.UserHash)
+	return snapshot.UserHash, nil
+}
+
+// rejectImpersonated 变更邮箱、认领主播主页、关联 Twitch 账号等涉及账号归属的操作不能通过代入令牌执行，
+// 即使令牌允许写入；已拒绝请求时返回 true
+func rejectImpersonated(c *gin.Context) bool {
+	if !c.GetBool(impersonatedContextKey) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "代入令牌不能用于该操作"})
+	return true
+}
+
+// createImpersonationRequest 签发代入令牌的请求
+type createImpersonationRequest struct {
+	Operator   string `json:"operator" binding:"required"` // 支持人员，用于审计
+	Reason     string `json:"reason" binding:"required"`   // 排查原因，例如工单号
+	TTLMinutes int    `json:"ttl_minutes"`                 // 有效期，默认 15 分钟，最长 60 分钟
+	AllowWrite bool   `json:"allow_write"`
+}
+
+// createImpersonationHandler 管理员为用户签发短期代入令牌
+// 令牌只在签发时返回一次，使用时放在 X-Impersonation-Token 请求头中
+// POST /api/admin/users/:userHash/impersonate
+func createImpersonationHandler(c *gin.Context) {
+	userHash := strings.TrimSpace(c.Param("userHash"))
+	var req createImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || userHash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "需要提供 operator 与 reason"})
+		return
+	}
+
+	ttl := defaultImpersonationTTL
+	if req.TTLMinutes > 0 {
+		ttl = time.Duration(req.TTLMinutes) * time.Minute
+	}
+	if ttl > maxImpersonationTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": fmt.Sprintf("有效期最长 %d 分钟", int(maxImpersonationTTL.Minutes()))})
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "生成令牌失败"})
+		return
+	}
+	token := hex.EncodeToString(buf)
+
+	now := time.Now()
+	imp := &Impersonation{
+		ID:         computeSha256Hex(token)[:12],
+		UserHash:   userHash,
+		Operator:   strings.TrimSpace(req.Operator),
+		Reason:     strings.TrimSpace(req.Reason),
+		AllowWrite: req.AllowWrite,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+		tokenHash:  computeSha256Hex(token),
+	}
+
+	impersonationsMu.Lock()
+	pruneImpersonationsLocked(now)
+	impersonations[imp.tokenHash] = imp
+	impersonationsMu.Unlock()
+
+	impersonationAudit("ISSUE", imp, fmt.Sprintf("ttl=%s allow_write=%t ip=%s reason=%q", ttl, imp.AllowWrite, c.ClientIP(), imp.Reason))
+	log.Printf("管理员 %s 签发了用户 %s 的代入令牌 %s（%s 后过期）", imp.Operator, userHash, imp.ID, ttl)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"token":         token,
+		"header":        ImpersonationHeader,
+		"impersonation": imp,
+	})
+}
+
+// listImpersonationsHandler 列出未过期的代入令牌
+func listImpersonationsHandler(c *gin.Context) {
+	impersonationsMu.Lock()
+	pruneImpersonationsLocked(time.Now())
+	items := make([]Impersonation, 0, len(impersonations))
+	for _, imp := range impersonations {
+		items = append(items, *imp)
+	}
+	impersonationsMu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.After(items[j].CreatedAt) })
+	c.JSON(http.StatusOK, gin.H{"success": true, "impersonations": items})
+}
+
+// revokeImpersonationHandler 提前吊销代入令牌
+func revokeImpersonationHandler(c *gin.Context) {
+	id := c.Param("id")
+
+	impersonationsMu.Lock()
+	var revoked *Impersonation
+	for hash, imp := range impersonations {
+		if imp.ID == id {
+			revoked = imp
+			delete(impersonations, hash)
+			break
+		}
+	}
+	impersonationsMu.Unlock()
+
+	if revoked == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "代入令牌不存在或已过期"})
+		return
+	}
+	impersonationAudit("REVOKE", revoked, "ip="+c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
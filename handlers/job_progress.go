@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 任务阶段
+const (
+	JobStageQueued       = "queued"
+	JobStageChatDownload = "chat_download"
+	JobStageAnalysis     = "analysis"
+	JobStageClipDownload = "clip_download"
+	JobStageFFmpeg       = "ffmpeg"
+	JobStageASR          = "asr"
+	JobStageAISummary    = "ai_summary"
+	JobStageDone         = "done"
+	JobStageFailed       = "failed"
+)
+
+// jobProgressRetention 任务结束后保留进度的时长，便于前端断线重连后读取最终状态
+const jobProgressRetention = 30 * time.Minute
+
+// JobProgress 单个录像处理任务的进度，任务ID即录像ID
+type JobProgress struct {
+	ID        string    `json:"id"`
+	Stage     string    `json:"stage"`
+	Percent   float64   `json:"percent"` // 当前阶段的完成百分比（0-100）
+	Detail    string    `json:"detail,omitempty"`
+	Done      bool      `json:"done"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// jobProgressEntry 任务进度及其订阅者
+type jobProgressEntry struct {
+	progress    JobProgress
+	subscribers map[chan JobProgress]struct{}
+}
+
+var (
+	jobProgressMu sync.Mutex
+	jobProgresses = map[string]*jobProgressEntry{}
+)
+
+// jobIDKey 在 context 中传递任务ID
+type jobIDKey struct{}
+
+// withJobProgress 返回携带任务ID的 context，下游阶段通过它上报进度
+func withJobProgress(ctx context.Context, jobID string) context.Context {
+	return context.WithValue(ctx, jobIDKey{}, jobID)
+}
+
+// jobIDFromContext 读取 context 中的任务ID
+func jobIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(jobIDKey{}).(string)
+	return id
+}
+
+// reportJobProgress 上报任务进度并推送给所有订阅者
+func reportJobProgress(jobID, stage string, percent float64, detail string) {
+	if jobID == "" {
+		return
+	}
+	updateJobProgress(jobID, func(p *JobProgress) {
+		p.Stage = stage
+		p.Percent = percent
+		p.Detail = detail
+		p.Done = false
+		p.Error = ""
+	})
+}
+
+// reportJobProgressCtx 通过 context 中的任务ID上报进度
+func reportJobProgressCtx(ctx context.Context, stage string, percent float64, detail string) {
+	reportJobProgress(jobIDFromContext(ctx), stage, percent, detail)
+}
+
+// finishJobProgress 标记任务结束
+func finishJobProgress(jobID string, err error) {
+	if jobID == "" {
+		return
+	}
+	// 任务ID即视频ID，流水线结束后不再等待该录像的AI总结
+	pipelineSLO.finishVideo(jobID)
+	updateJobProgress(jobID, func(p *JobProgress) {
+		p.Done = true
+		p.Percent = 100
+		p.Stage = JobStageDone
+		if err != nil {
+			p.Stage = JobStageFailed
+			p.Error = err.Error()
+		}
+	})
+
+	// 保留期后删除；任务在保留期内重新开始并再次结束时，由最后一次结束的计时器删除
+	time.AfterFunc(jobProgressRetention, func() {
+		jobProgressMu.Lock()
+		defer jobProgressMu.Unlock()
+		if entry, ok := jobProgresses[jobID]; ok && entry.progress.Done && time.Since(entry.progress.UpdatedAt) >= jobProgressRetention {
+			delete(jobProgresses, jobID)
+		}
+	})
+}
+
+// updateJobProgress 修改任务进度并通知订阅者，慢订阅者会丢弃中间状态
+// 任务结束时丢弃订阅者尚未读取的中间状态，保证最终状态送达后关闭通道并移除订阅者
+func updateJobProgress(jobID string, update func(p *JobProgress)) {
+	jobProgressMu.Lock()
+	defer jobProgressMu.Unlock()
+
+	entry, ok := jobProgresses[jobID]
+	if !ok {
+		entry = &jobProgressEntry{
+			progress:    JobProgress{ID: jobID},
+			subscribers: map[chan JobProgress]struct{}{},
+		}
+		jobProgresses[jobID] = entry
+	}
+	update(&entry.progress)
+	entry.progress.UpdatedAt = time.Now()
+
+	for ch := range entry.subscribers {
+		if entry.progress.Done {
+			drainJobProgress(ch)
+		}
+		select {
+		case ch <- entry.progress:
+		default:
+		}
+		if entry.progress.Done {
+			close(ch)
+			delete(entry.subscribers, ch)
+		}
+	}
+}
+
+// drainJobProgress 丢弃通道中尚未读取的进度
+func drainJobProgress(ch chan JobProgress) {
+	for {
+		select {
+		case <-ch:
+		default:
+			return
+		}
+	}
+}
+
+// subscribeJobProgress 订阅任务进度，返回当前状态与取消订阅函数
+// 通道在推送最终状态后关闭；任务已结束时返回已关闭的通道
+func subscribeJobProgress(jobID string) (JobProgress, bool, chan JobProgress, func()) {
+	jobProgressMu.Lock()
+	defer jobProgressMu.Unlock()
+
+	entry, ok := jobProgresses[jobID]
+	if !ok {
+		return JobProgress{}, false, nil, func() {}
+	}
+
+	ch := make(chan JobProgress, 16)
+	if entry.progress.Done {
+		close(ch)
+		return entry.progress, true, ch, func() {}
+	}
+	entry.subscribers[ch] = struct{}{}
+	return entry.progress, true, ch, func() {
+		jobProgressMu.Lock()
+		defer jobProgressMu.Unlock()
+		delete(entry.subscribers, ch)
+	}
+}
+
+// GetJobProgress 获取任务当前进度：id 为后台任务ID时返回任务记录（JobStatus），为录像ID时返回录像的处理进度
+// GET /api/jobs/:id
+func GetJobProgress(c *gin.Context) {
+	if job, ok, err := findPersistedJob(c.Param("id")); err == nil && ok {
+		c.JSON(http.StatusOK, jobStatuses([]PersistedJob{job})[0])
+		return
+	}
+
+	current, ok, _, unsubscribe := subscribeJobProgress(c.Param("id"))
+	unsubscribe()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "任务不存在或已过期",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, current)
+}
+
+// StreamJobEvents 以 Server-Sent Events 推送任务的阶段变化与进度
+// GET /api/jobs/:id/events
+func StreamJobEvents(c *gin.Context) {
+	current, ok, ch, unsubscribe := subscribeJobProgress(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "任务不存在或已过期",
+		})
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent("progress", current)
+	c.Writer.Flush()
+	if current.Done {
+		return
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case progress, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.SSEvent("progress", progress)
+			return !progress.Done
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		}
+	})
+}
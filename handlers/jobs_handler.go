@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobStatus 后台任务的记录，附带录像当前的处理进度与排队位置
+type JobStatus struct {
+	PersistedJob
+	Progress      *JobProgress `json:"progress,omitempty"`
+	QueuePosition int          `json:"queue_position,omitempty"` // 等待中的任务在队列中的位置，从 1 开始
+}
+
+// currentJobProgress 录像当前的处理进度，没有进度时返回 nil
+func currentJobProgress(videoID string) *JobProgress {
+	if videoID == "" {
+		return nil
+	}
+	current, ok, _, unsubscribe := subscribeJobProgress(videoID)
+	unsubscribe()
+	if !ok {
+		return nil
+	}
+	return &current
+}
+
+// jobStatuses 为任务附加进度与排队位置，不返回热点列表与录像信息，避免响应过大
+func jobStatuses(jobs []PersistedJob) []JobStatus {
+	_, _, pending := jobQueue.snapshot()
+	positions := make(map[string]int, len(pending))
+	for i, queued := range pending {
+		positions[queued.Job.key()] = i + 1
+	}
+
+	statuses := make([]JobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		job.HotMoments = nil
+		job.Video = nil
+		status := JobStatus{PersistedJob: job}
+		if !job.finished() {
+			status.Progress = currentJobProgress(job.VideoID)
+		}
+		if job.State == persistedJobPending {
+			status.QueuePosition = positions[job.key()]
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// ListJobs 列出下载、分析、片段、语音识别与AI总结等后台任务，最近更新的在前
+// 可按 state（pending/running/failed/done）、stage、video_id 与 streamer 过滤，limit 默认 50
+// GET /api/jobs
+func ListJobs(c *gin.Context) {
+	persistedJobsMu.Lock()
+	jobs, err := loadPersistedJobs()
+	persistedJobsMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "读取任务队列失败: " + err.Error(),
+		})
+		return
+	}
+
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit 必须为正整数",
+			})
+			return
+		}
+		limit = min(n, 500)
+	}
+
+	state, stage := c.Query("state"), c.Query("stage")
+	videoID, streamer := c.Query("video_id"), strings.ToLower(c.Query("streamer"))
+	filtered := jobs[:0]
+	for _, job := range jobs {
+		if (state != "" && job.State != state) || (stage != "" && job.Stage != stage) ||
+			(videoID != "" && job.VideoID != videoID) {
+			continue
+		}
+		if streamer != "" && strings.ToLower(job.Streamer) != streamer &&
+			strings.ToLower(job.StreamerName) != streamer && job.StreamerID != streamer {
+			continue
+		}
+		filtered = append(filtered, job)
+	}
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].UpdatedAt.After(filtered[j].UpdatedAt) })
+
+	total := len(filtered)
+	if len(filtered) > limit {
+		filtered = filtered[:limit]
+	}
+
+	policy, running, pending := jobQueue.snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  jobStatuses(filtered),
+		"total": total,
+		"queue": gin.H{
+			"policy":  policy,
+			"running": running,
+			"pending": len(pending),
+		},
+		"stages": stageConcurrencyStats(),
+	})
+}
+
+// RetryJob 将失败的后台任务重新加入处理队列，热点片段任务只重新处理尚未成功的热点
+// POST /api/jobs/:id/retry
+func RetryJob(c *gin.Context) {
+	if _, err := getUserHashFromCookie(c); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未登录或登录已过期",
+		})
+		return
+	}
+
+	job, err := retryPersistedJob(c.Param("id"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "任务不存在或已过期",
+		})
+		return
+	case errors.Is(err, errJobNotRetryable):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+			"state": job.State,
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "重试任务失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job": jobStatuses([]PersistedJob{job})[0],
+	})
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// twitchIRCWebSocketURL Twitch 聊天室的 WebSocket 地址，匿名（justinfan）登录即可只读
+	twitchIRCWebSocketURL = "wss://irc-ws.chat.twitch.tv:443"
+
+	// maxLiveChatSubscribers 单个主播最多同时连接的客户端数
+	maxLiveChatSubscribers = 50
+	// liveChatBufferSize 每个客户端的消息缓冲，客户端处理不过来时丢弃新消息
+	liveChatBufferSize = 256
+	// maxLiveChatPatternLen 过滤正则的最大长度
+	maxLiveChatPatternLen = 200
+
+	liveChatWriteTimeout = 10 * time.Second
+	liveChatPingInterval = 30 * time.Second
+	liveChatPongTimeout  = 75 * time.Second
+)
+
+// liveChatBadgeRanks 徽章等级，min_badge 过滤时只保留等级不低于指定徽章的发送者
+var liveChatBadgeRanks = map[string]int{
+	"subscriber":  1,
+	"founder":     1,
+	"vip":         2,
+	"moderator":   3,
+	"broadcaster": 4,
+}
+
+// LiveChatMessage 统一格式的直播聊天消息
+type LiveChatMessage struct {
+	ID          string    `json:"id"`
+	Platform    string    `json:"platform"`
+	StreamerID  string    `json:"streamer_id"`
+	Channel     string    `json:"channel"`
+	UserID      string    `json:"user_id,omitempty"`
+	User        string    `json:"user"`
+	DisplayName string    `json:"display_name"`
+	Color       string    `json:"color,omitempty"`
+	Badges      []string  `json:"badges"`
+	Text        string    `json:"text"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+// badgeRank 发送者最高徽章的等级，没有相关徽章时为 0
+func (m LiveChatMessage) badgeRank() int {
+	rank := 0
+	for _, badge := range m.Badges {
+		if r := liveChatBadgeRanks[badge]; r > rank {
+			rank = r
+		}
+	}
+	return rank
+}
+
+// liveChatFilter 客户端连接时指定的服务端过滤条件
+type liveChatFilter struct {
+	minRank int
+	pattern *regexp.Regexp
+}
+
+// match 消息是否满足过滤条件
+func (f liveChatFilter) match(msg LiveChatMessage) bool {
+	if f.minRank > 0 && msg.badgeRank() < f.minRank {
+		return false
+	}
+	if f.pattern != nil && !f.pattern.MatchString(msg.Text) {
+		return false
+	}
+	return true
+}
+
+// parseLiveChatFilter 解析 min_badge 与 match 查询参数
+func parseLiveChatFilter(minBadge, pattern string) (liveChatFilter, error) {
+	var f liveChatFilter
+	if minBadge = strings.ToLower(strings.TrimSpace(minBadge)); minBadge != "" {
+		rank, ok := liveChatBadgeRanks[minBadge]
+		if !ok {
+			return f, fmt.Errorf("不支持的 min_badge: %s（可选 subscriber、vip、moderator、broadcaster）", minBadge)
+		}
+		f.minRank = rank
+	}
+	if pattern != "" {
+		if len(pattern) > maxLiveChatPatternLen {
+			return f, fmt.Errorf("match 正则最长 %d 个字符", maxLiveChatPatternLen)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return f, fmt.Errorf("match 正则无效: %v", err)
+		}
+		f.pattern = re
+	}
+	return f, nil
+}
+
+// liveChatSubscriber 一个 WebSocket 客户端
+type liveChatSubscriber struct {
+	filter liveChatFilter
+	ch     chan LiveChatMessage
+}
+
+// liveChatCapture 单个主播的直播聊天采集，有客户端订阅时才连接平台
+type liveChatCapture struct {
+	streamerID  string
+	login       string
+	subscribers map[*liveChatSubscriber]struct{}
+	cancel      context.CancelFunc
+	done        chan struct{} // 直播结束或采集停止时关闭
+}
+
+var (
+	liveCapturesMu sync.Mutex
+	liveCaptures   = map[string]*liveChatCapture{}
+)
+
+// subscribeLiveChat 订阅主播的直播聊天，首个订阅者到来时启动采集
+// 返回的 done 在直播结束时关闭
+func subscribeLiveChat(streamerID, login string, filter liveChatFilter) (*liveChatSubscriber, <-chan struct{}, func(), error) {
+	liveCapturesMu.Lock()
+	defer liveCapturesMu.Unlock()
+
+	capture, ok := liveCaptures[streamerID]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		capture = &liveChatCapture{
+			streamerID:  streamerID,
+			login:       strings.ToLower(login),
+			subscribers: map[*liveChatSubscriber]struct{}{},
+			cancel:      cancel,
+			done:        make(chan struct{}),
+		}
+		liveCaptures[streamerID] = capture
+		go capture.run(ctx)
+		log.Printf("开始采集 %s 的直播聊天 (#%s)", streamerID, capture.login)
+	}
+	if len(capture.subscribers) >= maxLiveChatSubscribers {
+		return nil, nil, nil, fmt.Errorf("该主播的直播聊天连接数已达上限")
+	}
+
+	sub := &liveChatSubscriber{filter: filter, ch: make(chan LiveChatMessage, liveChatBufferSize)}
+	capture.subscribers[sub] = struct{}{}
+
+	unsubscribe := func() {
+		liveCapturesMu.Lock()
+		defer liveCapturesMu.Unlock()
+		delete(capture.subscribers, sub)
+		if len(capture.subscribers) == 0 && liveCaptures[streamerID] == capture {
+			delete(liveCaptures, streamerID)
+			capture.cancel()
+			log.Printf("%s 的直播聊天没有订阅者，停止采集", streamerID)
+		}
+	}
+	return sub, capture.done, unsubscribe, nil
+}
+
+// stopLiveChatCapture 主播下播时停止采集并通知所有订阅者
+func stopLiveChatCapture(streamerID string) {
+	liveCapturesMu.Lock()
+	capture, ok := liveCaptures[streamerID]
+	if ok {
+		delete(liveCaptures, streamerID)
+	}
+	liveCapturesMu.Unlock()
+
+	if ok {
+		capture.cancel()
+		close(capture.done)
+		log.Printf("%s 已下播，停止采集直播聊天", streamerID)
+	}
+}
+
+// broadcast 按各订阅者的过滤条件分发消息
+func (lc *liveChatCapture) broadcast(msg LiveChatMessage) {
+	liveCapturesMu.Lock()
+	defer liveCapturesMu.Unlock()
+	for sub := range lc.subscribers {
+		if !sub.filter.match(msg) {
+			continue
+		}
+		select {
+		case sub.ch <- msg:
+		default:
+		}
+	}
+}
+
+// run 保持与 Twitch 聊天室的连接，断线后按退避间隔重连，直到采集停止
+func (lc *liveChatCapture) run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := lc.readTwitchChat(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("%s 的直播聊天连接断开: %v，%s 后重连", lc.streamerID, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// readTwitchChat 匿名加入 Twitch 聊天室并读取消息，连接断开或采集停止时返回
+func (lc *liveChatCapture) readTwitchChat(ctx context.Context) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, twitchIRCWebSocketURL, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	nick := fmt.Sprintf("justinfan%d", 10000+rand.Intn(80000))
+	for _, line := range []string{
+		"CAP REQ :twitch.tv/tags twitch.tv/commands",
+		"PASS SCHMOOPIIE",
+		"NICK " + nick,
+		"JOIN #" + lc.login,
+	} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(line)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(data), "\r\n") {
+			if line == "" {
+				continue
+			}
+			if strings.HasPrefix(line, "PING") {
+				if err := conn.WriteMessage(websocket.TextMessage, []byte("PONG"+strings.TrimPrefix(line, "PING"))); err != nil {
+					return err
+				}
+				continue
+			}
+			if msg, ok := parseTwitchPrivmsg(line); ok {
+				msg.StreamerID = lc.streamerID
+				lc.broadcast(msg)
+			}
+		}
+	}
+}
+
+// parseTwitchPrivmsg 解析带 tags 的 IRC PRIVMSG 行，例如
+// @badges=subscriber/12;display-name=Foo;id=...;tmi-sent-ts=... :foo!foo@foo.tmi.twitch.tv PRIVMSG #channel :hello
+func parseTwitchPrivmsg(line string) (LiveChatMessage, bool) {
+	tags := map[string]string{}
+	if strings.HasPrefix(line, "@") {
+		end := strings.IndexByte(line, ' ')
+		if end < 0 {
+			return LiveChatMessage{}, false
+		}
+		for _, pair := range strings.Split(line[1:end], ";") {
+			if k, v, ok := strings.Cut(pair, "="); ok {
+				tags[k] = v
+			}
+		}
+		line = line[end+1:]
+	}
+
+	prefix, rest, ok := strings.Cut(line, " ")
+	if !ok || !strings.HasPrefix(prefix, ":") {
+		return LiveChatMessage{}, false
+	}
+	command, rest, ok := strings.Cut(rest, " ")
+	if !ok || command != "PRIVMSG" {
+		return LiveChatMessage{}, false
+	}
+	channel, text, ok := strings.Cut(rest, " :")
+	if !ok {
+		return LiveChatMessage{}, false
+	}
+	// /me 消息以 CTCP ACTION 包裹
+	if strings.HasPrefix(text, "\x01ACTION ") {
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "\x01ACTION "), "\x01")
+	}
+
+	user, _, _ := strings.Cut(strings.TrimPrefix(prefix, ":"), "!")
+	msg := LiveChatMessage{
+		ID:          tags["id"],
+		Platform:    "twitch",
+		Channel:     strings.TrimPrefix(channel, "#"),
+		UserID:      tags["user-id"],
+		User:        user,
+		DisplayName: tags["display-name"],
+		Color:       tags["color"],
+		Badges:      []string{},
+		Text:        text,
+		SentAt:      time.Now(),
+	}
+	if msg.DisplayName == "" {
+		msg.DisplayName = user
+	}
+	for _, badge := range strings.Split(tags["badges"], ",") {
+		if name, _, _ := strings.Cut(badge, "/"); name != "" {
+			msg.Badges = append(msg.Badges, name)
+		}
+	}
+	if ms, err := strconv.ParseInt(tags["tmi-sent-ts"], 10, 64); err == nil {
+		msg.SentAt = time.UnixMilli(ms)
+	}
+	return msg, true
+}
+
+// liveChatUpgrader 叠加层工具可能运行在任意来源（OBS 浏览器源、本地页面），不校验 Origin
+var liveChatUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// StreamLiveChat 以 WebSocket 推送正在直播的追踪主播的聊天消息
+// 查询参数: min_badge（subscriber、vip、moderator、broadcaster）只保留该徽章及以上的发送者，match 按正则过滤消息内容
+// GET /api/live/:streamerID/chat
+func StreamLiveChat(c *gin.Context) {
+	streamer := ResolveStreamer(c.Param("streamerID"))
+	if streamer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未找到该主播"})
+		return
+	}
+
+	var login string
+	for _, platform := range streamer.Platforms {
+		if platform.Platform == "twitch" {
+			login = platformURLHandle(platform.URL)
+			break
+		}
+	}
+	if login == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该主播没有 Twitch 频道，暂不支持其他平台的直播聊天"})
+		return
+	}
+
+	monitor := GetTwitchMonitor()
+	if monitor == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Twitch监控服务未启动"})
+		return
+	}
+	if status := monitor.GetStreamerStatus(streamer.ID); status == nil || !status.IsLive {
+		c.JSON(http.StatusConflict, gin.H{"error": "该主播当前未在直播"})
+		return
+	}
+
+	filter, err := parseLiveChatFilter(c.Query("min_badge"), c.Query("match"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, ended, unsubscribe, err := subscribeLiveChat(streamer.ID, login, filter)
+	if err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	defer unsubscribe()
+
+	conn, err := liveChatUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 已向客户端写入错误响应
+		return
+	}
+	defer conn.Close()
+
+	// 读取循环只处理控制帧，客户端关闭连接时结束
+	closed := make(chan struct{})
+	conn.SetReadLimit(1024)
+	_ = conn.SetReadDeadline(time.Now().Add(liveChatPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(liveChatPongTimeout))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(liveChatPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ended:
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "stream ended"),
+				time.Now().Add(liveChatWriteTimeout))
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveChatWriteTimeout)); err != nil {
+				return
+			}
+		case msg := <-sub.ch:
+			_ = conn.SetWriteDeadline(time.Now().Add(liveChatWriteTimeout))
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		}
+	}
+}
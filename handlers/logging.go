@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"subtuber-services/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader 请求 ID 的请求头与响应头，客户端或网关已提供时沿用
+const RequestIDHeader = "X-Request-ID"
+
+var httpLog = logging.For("http")
+
+// newRequestID 生成 16 位十六进制的请求 ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RequestLogMiddleware assigns every request an ID (echoed in X-Request-ID) and writes one structured access log line
+// 请求 ID 放入 c.Request.Context()，处理函数使用该 context 记录的日志会带上 request_id
+func RequestLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+		}
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", span.TraceID().String()))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		httpLog.LogAttrs(c.Request.Context(), level, "请求完成", attrs...)
+	}
+}
+
+// getLogLevelsHandler 查看当前的默认日志级别与各模块级别
+// GET /api/admin/logging
+func getLogLevelsHandler(c *gin.Context) {
+	level, modules := logging.Levels()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"level":   level,
+		"modules": modules,
+		"format":  GetLoggingConfig().Format,
+	})
+}
+
+// setLogLevelHandler 运行中修改日志级别，module 为空时修改默认级别
+// 修改只保存在内存中，重启或重新加载配置后恢复为 config.yaml 中的设置
+// PUT /api/admin/logging
+func setLogLevelHandler(c *gin.Context) {
+	var req struct {
+		Module string `json:"module"`
+		Level  string `json:"level" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := logging.SetLevel(strings.TrimSpace(req.Module), req.Level); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	httpLog.InfoContext(c.Request.Context(), "日志级别已修改", "target_module", req.Module, "level", req.Level)
+	level, modules := logging.Levels()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"level":   level,
+		"modules": modules,
+	})
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultMagicLinkTTL = 15 * time.Minute
+
+var (
+	magicLinkRandomKey     []byte
+	magicLinkRandomKeyOnce sync.Once
+)
+
+// Validate 校验登录链接与会话配置
+func (c AuthConfig) Validate() error {
+	if c.MagicLinkTTLMinutes < 0 {
+		return fmt.Errorf("登录链接有效期不能为负数")
+	}
+	if c.SessionTTLHours < 0 {
+		return fmt.Errorf("会话有效期不能为负数")
+	}
+	if c.SessionSecret == "" {
+		return fmt.Errorf("必须配置 session_secret")
+	}
+	if c.LegacyCookieUntil != "" {
+		until, err := time.ParseInLocation(time.DateOnly, c.LegacyCookieUntil, time.Local)
+		if err != nil {
+			return fmt.Errorf("legacy_cookie_until 必须是 YYYY-MM-DD 格式的日期")
+		}
+		if until.After(time.Now().Add(maxLegacyCookieWindow)) {
+			return fmt.Errorf("legacy_cookie_until 不能晚于 %d 天后", int(maxLegacyCookieWindow.Hours()/24))
+		}
+	}
+	if !c.MagicLinkEnabled {
+		return nil
+	}
+	u, err := url.Parse(c.MagicLinkURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("启用登录链接时 magic_link_url 必须是完整的 URL")
+	}
+	return nil
+}
+
+// magicLinkTTL 返回登录链接的有效期
+func (c AuthConfig) magicLinkTTL() time.Duration {
+	if c.MagicLinkTTLMinutes <= 0 {
+		return defaultMagicLinkTTL
+	}
+	return time.Duration(c.MagicLinkTTLMinutes) * time.Minute
+}
+
+// signingKey 返回登录链接的签名密钥，未配置时使用进程内随机密钥（重启后旧链接失效）
+func (c AuthConfig) signingKey() []byte {
+	if c.MagicLinkSecret != "" {
+		return []byte(c.MagicLinkSecret)
+	}
+	magicLinkRandomKeyOnce.Do(func() {
+		magicLinkRandomKey = make([]byte, 32)
+		if _, err := rand.Read(magicLinkRandomKey); err != nil {
+			log.Printf("生成登录链接签名密钥失败: %v", err)
+		}
+	})
+	return magicLinkRandomKey
+}
+
+// newMagicLink 生成一次性登录链接，未启用时返回空字符串
+// 令牌格式为 base64(email|过期时间|随机数).签名，随机数记录在缓存中以保证只能使用一次
+func newMagicLink(email string) (string, error) {
+	cfg := GetAuthConfig()
+	if !cfg.MagicLinkEnabled {
+		return "", nil
+	}
+
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return "", err
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+
+	ttl := cfg.magicLinkTTL()
+	expires := time.Now().Add(ttl).Unix()
+	payload := strings.ToLower(email) + "|" + strconv.FormatInt(expires, 10) + "|" + nonce
+	token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + signMagicLink(cfg, payload)
+
+	codeCache.Set("login:magic:"+nonce, email, ttl)
+
+	u, err := url.Parse(cfg.MagicLinkURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// signMagicLink 计算登录链接负载的签名
+func signMagicLink(cfg AuthConfig, payload string) string {
+	mac := hmac.New(sha256.New, cfg.signingKey())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkMagicLink 校验令牌签名、有效期以及是否已使用，返回登录邮箱与一次性随机数的缓存键，不作废令牌
+func checkMagicLink(token string) (string, string, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", "", fmt.Errorf("登录链接格式无效")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", fmt.Errorf("登录链接格式无效")
+	}
+	payload := string(raw)
+	if !hmac.Equal([]byte(sig), []byte(signMagicLink(GetAuthConfig(), payload))) {
+		return "", "", fmt.Errorf("登录链接签名无效")
+	}
+
+	parts := strings.Split(payload, "|")
+	if len(parts) != 3 {
+		return "", "", fmt.Errorf("登录链接格式无效")
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return "", "", fmt.Errorf("登录链接已过期，请重新发送")
+	}
+
+	key := "login:magic:" + parts[2]
+	v, found := codeCache.Get(key)
+	if !found {
+		return "", "", fmt.Errorf("登录链接已使用或已过期，请重新发送")
+	}
+
+	email, _ := v.(string)
+	if !strings.EqualFold(email, parts[0]) {
+		return "", "", fmt.Errorf("登录链接无效")
+	}
+	return email, key, nil
+}
+
+// consumeMagicLink 校验令牌并作废，返回登录邮箱
+func consumeMagicLink(token string) (string, error) {
+	email, key, err := checkMagicLink(token)
+	if err != nil {
+		return "", err
+	}
+	codeCache.Delete(key)
+	return email, nil
+}
+
+// magicLinkConfirmHandler 打开邮件中的登录链接时只显示确认页面，不作废令牌
+// 邮件安全扫描与链接预取只会发起 GET 请求，用户点击确认按钮提交 POST 后才登录
+func magicLinkConfirmHandler(c *gin.Context) {
+	if !GetAuthConfig().MagicLinkEnabled {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "未启用链接登录"})
+		return
+	}
+
+	token := c.Query("token")
+	_, _, err := checkMagicLink(token)
+
+	// 页面中包含令牌，不允许缓存，也不通过 Referer 泄露
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusBadRequest
+	}
+	c.Status(status)
+	data := gin.H{"Action": c.Request.URL.Path, "Token": token}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	_ = magicLinkConfirmTemplate.Execute(c.Writer, data)
+}
+
+// magicLinkLoginHandler 确认页面提交后作废一次性链接并登录
+func magicLinkLoginHandler(c *gin.Context) {
+	if !GetAuthConfig().MagicLinkEnabled {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "未启用链接登录"})
+		return
+	}
+
+	email, err := consumeMagicLink(c.PostForm("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	user, _ := loginUser(c, email)
+	// 验证码与链接二选一，登录后一并作废
+	codeCache.Delete("login:code:" + strings.ToLower(email))
+	log.Printf("用户 %s 通过登录链接登录", user.UserId)
+
+	if redirect := GetAuthConfig().LoginRedirectURL; redirect != "" {
+		// 表单提交后跳转，使用 303 让浏览器以 GET 打开目标页面
+		c.Redirect(http.StatusSeeOther, redirect)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "登录成功", "user": user})
+}
+
+var magicLinkConfirmTemplate = template.Must(template.New("magic-link").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>LumiTime 登录</title>
+<style>
+body { font-family: sans-serif; display: flex; justify-content: center; padding-top: 20vh; }
+main { text-align: center; }
+button { font-size: 1.1em; padding: 0.5em 2em; cursor: pointer; }
+</style>
+</head>
+<body>
+<main>
+{{if .Error}}
+<p>{{.Error}}</p>
+{{else}}
+<p>点击下方按钮完成登录</p>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">登录 LumiTime</button>
+</form>
+{{end}}
+</main>
+</body>
+</html>
+`))
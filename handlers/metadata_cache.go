@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+)
+
+const metadataCacheDir = "App_Data/metadata_cache"
+
+// 录像元数据的缓存时长：单个录像的信息变化很少；录像列表用于发现新录像，时间较短
+const (
+	videoInfoCacheTTL = 6 * time.Hour
+	videoListCacheTTL = 10 * time.Minute
+)
+
+// metadataCache 内存中的录像元数据，键为 {platform}/{key}
+var metadataCache = cache.New(videoInfoCacheTTL, 10*time.Minute)
+
+// metadataCacheEntry 持久化的缓存条目
+type metadataCacheEntry struct {
+	Key       string          `json:"key"`
+	CachedAt  time.Time       `json:"cached_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+var metadataKeyUnsafe = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// metadataCachePath 缓存条目的文件路径
+func metadataCachePath(platform, key string) string {
+	return filepath.Join(metadataCacheDir, metadataKeyUnsafe.ReplaceAllString(platform, "_"),
+		metadataKeyUnsafe.ReplaceAllString(key, "_")+".json")
+}
+
+// cachedVideoMetadata 依次查找内存与磁盘缓存，均未命中或已过期时调用 fetch，并写入两级缓存
+// fetch 失败时不写入缓存
+func cachedVideoMetadata[T any](platform, key string, ttl time.Duration, fetch func() (T, error)) (T, error) {
+	memKey := platform + "/" + key
+	if v, ok := metadataCache.Get(memKey); ok {
+		if value, ok := v.(T); ok {
+			return value, nil
+		}
+	}
+
+	if data, err := os.ReadFile(metadataCachePath(platform, key)); err == nil {
+		var entry metadataCacheEntry
+		if err := json.Unmarshal(data, &entry); err == nil && entry.Key == key && time.Now().Before(entry.ExpiresAt) {
+			var value T
+			if err := json.Unmarshal(entry.Data, &value); err == nil {
+				metadataCache.Set(memKey, value, time.Until(entry.ExpiresAt))
+				return value, nil
+			}
+		}
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+	storeVideoMetadata(platform, key, ttl, value)
+	return value, nil
+}
+
+// storeVideoMetadata 写入两级缓存，写磁盘失败只记录日志
+func storeVideoMetadata(platform, key string, ttl time.Duration, value interface{}) {
+	metadataCache.Set(platform+"/"+key, value, ttl)
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	entry, err := json.MarshalIndent(metadataCacheEntry{
+		Key:       key,
+		CachedAt:  now,
+		ExpiresAt: now.Add(ttl),
+		Data:      data,
+	}, "", "  ")
+	if err != nil {
+		return
+	}
+
+	path := metadataCachePath(platform, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("创建元数据缓存目录失败: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, entry, 0644); err != nil {
+		log.Printf("写入元数据缓存失败: %v", err)
+	}
+}
+
+// InvalidateVideoMetadata 删除缓存条目（内存与磁盘），返回删除的条目数
+// key 以 "." 结尾时删除该前缀下的所有条目（如频道的录像列表），为空时清空该平台的全部缓存，否则只删除该键
+func InvalidateVideoMetadata(platform, key string) int {
+	key = metadataKeyUnsafe.ReplaceAllString(key, "_")
+	matches := func(name string) bool {
+		if key == "" || strings.HasSuffix(key, ".") {
+			return strings.HasPrefix(name, key)
+		}
+		return name == key
+	}
+
+	removed := map[string]bool{}
+	for k := range metadataCache.Items() {
+		name, ok := strings.CutPrefix(k, platform+"/")
+		if name = metadataKeyUnsafe.ReplaceAllString(name, "_"); ok && matches(name) {
+			metadataCache.Delete(k)
+			removed[name] = true
+		}
+	}
+
+	dir := filepath.Dir(metadataCachePath(platform, "x"))
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".json")
+		if e.IsDir() || !matches(name) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err == nil {
+			removed[name] = true
+		}
+	}
+	return len(removed)
+}
+
+// videoInfoCacheKey 单个录像信息的缓存键
+func videoInfoCacheKey(videoID string) string {
+	return "video." + videoID
+}
+
+// videoListCacheKey 频道录像列表的缓存键，以频道开头便于按频道失效
+// 用户名与频道ID中不含 "."，可作为分隔符避免前缀冲突
+func videoListCacheKey(owner string, parts ...string) string {
+	return "videos." + strings.ToLower(owner) + "." + strings.Join(parts, ".")
+}
+
+// invalidateVideoListCache 删除频道的录像列表缓存（直播结束后会出现新录像）
+func invalidateVideoListCache(platform, owner string) {
+	InvalidateVideoMetadata(platform, videoListCacheKey(owner))
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const notificationsFile = "App_Data/notifications.json"
+
+// maxNotificationsPerUser 每个用户保留的站内通知条数，超出时丢弃最旧的
+const maxNotificationsPerUser = 200
+
+// Notification 用户的一条站内通知
+type Notification struct {
+	ID         string    `json:"id"`
+	StreamerID string    `json:"streamer_id"`
+	Event      string    `json:"event"`
+	VideoID    string    `json:"video_id,omitempty"`
+	Title      string    `json:"title"`
+	Body       string    `json:"body"`
+	Read       bool      `json:"read"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+var notificationsMu sync.Mutex
+
+// loadNotifications 读取所有用户的站内通知，key: userHash，按时间从新到旧
+func loadNotifications() (map[string][]Notification, error) {
+	data, err := os.ReadFile(notificationsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string][]Notification{}, nil
+		}
+		return nil, err
+	}
+
+	inbox := map[string][]Notification{}
+	if err := json.Unmarshal(data, &inbox); err != nil {
+		return nil, err
+	}
+	return inbox, nil
+}
+
+// saveNotifications 将站内通知写回文件
+func saveNotifications(inbox map[string][]Notification) error {
+	if err := os.MkdirAll(filepath.Dir(notificationsFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(inbox, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(notificationsFile, data, 0644)
+}
+
+// addNotifications 为多个用户写入同一条通知，一次写回文件
+func addNotifications(userHashes []string, n Notification) error {
+	if len(userHashes) == 0 {
+		return nil
+	}
+	notificationsMu.Lock()
+	defer notificationsMu.Unlock()
+
+	inbox, err := loadNotifications()
+	if err != nil {
+		return err
+	}
+	n.CreatedAt = time.Now()
+	for i, userHash := range userHashes {
+		n.ID = fmt.Sprintf("%d_%d", n.CreatedAt.UnixNano(), i)
+		items := append([]Notification{n}, inbox[userHash]...)
+		if len(items) > maxNotificationsPerUser {
+			items = items[:maxNotificationsPerUser]
+		}
+		inbox[userHash] = items
+	}
+	return saveNotifications(inbox)
+}
+
+// renameNotificationsUser 将站内通知迁移到新的 userHash
+func renameNotificationsUser(oldHash, newHash string) error {
+	notificationsMu.Lock()
+	defer notificationsMu.Unlock()
+
+	inbox, err := loadNotifications()
+	if err != nil {
+		return err
+	}
+	items, ok := inbox[oldHash]
+	if !ok {
+		return nil
+	}
+	inbox[newHash] = append(items, inbox[newHash]...)
+	delete(inbox, oldHash)
+	return saveNotifications(inbox)
+}
+
+// GetNotifications 获取当前用户的站内通知（从新到旧），unread=true 时只返回未读通知
+func GetNotifications(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "未登录或登录已过期",
+		})
+		return
+	}
+
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = min(n, maxNotificationsPerUser)
+		}
+	}
+	unreadOnly := c.Query("unread") == "true"
+
+	notificationsMu.Lock()
+	inbox, err := loadNotifications()
+	notificationsMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "读取通知失败: " + err.Error(),
+		})
+		return
+	}
+
+	unread := 0
+	items := []Notification{}
+	for _, n := range inbox[userHash] {
+		if !n.Read {
+			unread++
+		}
+		if (unreadOnly && n.Read) || len(items) >= limit {
+			continue
+		}
+		items = append(items, n)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"notifications": items,
+		"unread":        unread,
+	})
+}
+
+// MarkNotificationsRead 将当前用户的通知标记为已读，ids 为空时标记全部
+func MarkNotificationsRead(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "未登录或登录已过期",
+		})
+		return
+	}
+
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	// 允许空请求体，表示全部标记为已读
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "请求参数错误: " + err.Error(),
+			})
+			return
+		}
+	}
+	ids := map[string]bool{}
+	for _, id := range req.IDs {
+		ids[id] = true
+	}
+
+	notificationsMu.Lock()
+	defer notificationsMu.Unlock()
+	inbox, err := loadNotifications()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "读取通知失败: " + err.Error(),
+		})
+		return
+	}
+
+	marked, unread := 0, 0
+	items := inbox[userHash]
+	for i := range items {
+		if !items[i].Read && (len(ids) == 0 || ids[items[i].ID]) {
+			items[i].Read = true
+			marked++
+		}
+		if !items[i].Read {
+			unread++
+		}
+	}
+	if marked > 0 {
+		if err := saveNotifications(inbox); err != nil {
+			log.Printf("保存通知失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "保存通知失败",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"marked":  marked,
+		"unread":  unread,
+	})
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"subtuber-services/services"
+)
+
+const notificationDigestFile = "App_Data/notification_digest.json"
+
+// 通知事件类型
+const (
+	NotifyEventGoLive        = "go_live"
+	NotifyEventAnalysisReady = "analysis_ready"
+)
+
+// digestItem 等待合并进摘要邮件的一条通知
+type digestItem struct {
+	StreamerID string    `json:"streamer_id"`
+	Event      string    `json:"event"`
+	Subject    string    `json:"subject"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+var (
+	notificationDigestMu sync.Mutex
+)
+
+// NotifySubscribers 按订阅者的通知偏好发送通知
+// 订阅了该事件的用户都会收到站内通知；选择了摘要模式的订阅者不会立即收到邮件，通知会合并进下一封摘要邮件；
+// 关闭了邮件通知的用户只收到站内通知
+func NotifySubscribers(streamerID, event, subject, body string) {
+	notifySubscribers(streamerID, event, "", subject, body)
+}
+
+// notifyAnalysisReady 录像分析完成后通知主播的订阅者
+func notifyAnalysisReady(streamerID, streamerName, videoID, title string, hotMoments int) {
+	notifySubscribers(streamerID, NotifyEventAnalysisReady, videoID,
+		fmt.Sprintf("%s 的录像分析已完成", streamerName),
+		fmt.Sprintf("%s\n发现 %d 个热点时刻", title, hotMoments))
+}
+
+// notifySubscribers 写入站内通知并按偏好发送邮件，videoID 为通知关联的录像（可为空）
+func notifySubscribers(streamerID, event, videoID, subject, body string) {
+	resp, err := services.GetStreamerSubscribers(streamerID)
+	if err != nil {
+		log.Printf("获取 %s 的订阅者失败，跳过通知: %v", streamerID, err)
+		return
+	}
+
+	var recipients []string
+	for _, sub := range resp.Subscriptions {
+		if GetSubscriptionPreferences(sub.UserHash, streamerID).wants(event) {
+			recipients = append(recipients, sub.UserHash)
+		}
+	}
+	if err := addNotifications(recipients, Notification{
+		StreamerID: streamerID,
+		Event:      event,
+		VideoID:    videoID,
+		Title:      subject,
+		Body:       body,
+	}); err != nil {
+		log.Printf("写入站内通知失败: %v", err)
+	}
+
+	if !IsFeatureEnabled(FeatureEmail) {
+		log.Printf("%s 的 %s 通知: 站内通知 %d 条", streamerID, event, len(recipients))
+		return
+	}
+
+	sent, digested := 0, 0
+	for _, userHash := range recipients {
+		prefs := GetSubscriptionPreferences(userHash, streamerID)
+		userPrefs := GetUserPreferences(userHash)
+		if !userPrefs.EmailNotifications {
+			continue
+		}
+
+		if prefs.DigestOnly {
+			if userPrefs.DigestFrequency == DigestFrequencyNever {
+				continue
+			}
+			if err := appendDigestItem(userHash, digestItem{
+				StreamerID: streamerID,
+				Event:      event,
+				Subject:    subject,
+				Body:       body,
+				CreatedAt:  time.Now(),
+			}); err != nil {
+				log.Printf("写入摘要队列失败: %v", err)
+				continue
+			}
+			digested++
+			continue
+		}
+
+		user, err := services.GetUserByHashFromRPC(userHash)
+		if err != nil || user.Email == "" {
+			continue
+		}
+		if err := sendMail(user.Email, subject, body); err == nil {
+			sent++
+		}
+	}
+
+	log.Printf("%s 的 %s 通知: 站内通知 %d 条，发送邮件 %d 封，加入摘要 %d 条", streamerID, event, len(recipients), sent, digested)
+}
+
+// StartNotificationDigest 启动后台任务，定期向选择摘要模式的用户发送汇总邮件
+// 检查计划见配置 schedule.jobs.digest（默认每小时），各用户的发送频率见偏好设置中的 digestFrequency
+func StartNotificationDigest() {
+	scheduleJob(scheduleJobDigest, sendNotificationDigests)
+}
+
+// sendNotificationDigests 为摘要已到期的用户合并待发送的通知并发送一封摘要邮件
+// 最早一条通知的等待时间达到用户设置的摘要频率时到期
+func sendNotificationDigests() {
+	if !IsFeatureEnabled(FeatureEmail) {
+		return
+	}
+
+	now := time.Now()
+	notificationDigestMu.Lock()
+	pending, err := loadNotificationDigest()
+	due := map[string][]digestItem{}
+	if err == nil {
+		for userHash, items := range pending {
+			if len(items) == 0 {
+				delete(pending, userHash)
+				continue
+			}
+			interval := GetUserPreferences(userHash).digestInterval()
+			if interval > 0 && now.Sub(items[0].CreatedAt) < interval {
+				continue
+			}
+			// 频率为 never 时丢弃已排队的通知
+			if interval > 0 {
+				due[userHash] = items
+			}
+			delete(pending, userHash)
+		}
+		err = saveNotificationDigest(pending)
+	}
+	notificationDigestMu.Unlock()
+	if err != nil {
+		log.Printf("读取摘要队列失败: %v", err)
+		return
+	}
+
+	for userHash, items := range due {
+		user, err := services.GetUserByHashFromRPC(userHash)
+		if err != nil || user.Email == "" {
+			continue
+		}
+
+		// 按用户的时区显示通知时间
+		loc, err := time.LoadLocation(GetUserPreferences(userHash).Timezone)
+		if err != nil {
+			loc = time.Local
+		}
+		var body strings.Builder
+		for _, item := range items {
+			body.WriteString(fmt.Sprintf("[%s] %s\n%s\n\n", item.CreatedAt.In(loc).Format("01-02 15:04"), item.Subject, item.Body))
+		}
+		subject := fmt.Sprintf("订阅主播动态摘要（%d 条）", len(items))
+		if err := sendMail(user.Email, subject, body.String()); err != nil {
+			log.Printf("发送摘要邮件失败: %v", err)
+		}
+	}
+}
+
+// appendDigestItem 将通知加入用户的摘要队列
+func appendDigestItem(userHash string, item digestItem) error {
+	notificationDigestMu.Lock()
+	defer notificationDigestMu.Unlock()
+
+	pending, err := loadNotificationDigest()
+	if err != nil {
+		return err
+	}
+	pending[userHash] = append(pending[userHash], item)
+	return saveNotificationDigest(pending)
+}
+
+// renameNotificationDigestUser 将待发送的摘要迁移到新的 userHash
+func renameNotificationDigestUser(oldHash, newHash string) error {
+	notificationDigestMu.Lock()
+	defer notificationDigestMu.Unlock()
+
+	pending, err := loadNotificationDigest()
+	if err != nil {
+		return err
+	}
+	items, ok := pending[oldHash]
+	if !ok {
+		return nil
+	}
+	pending[newHash] = append(pending[newHash], items...)
+	delete(pending, oldHash)
+	return saveNotificationDigest(pending)
+}
+
+// loadNotificationDigest 读取待发送的摘要队列，key: userHash
+func loadNotificationDigest() (map[string][]digestItem, error) {
+	data, err := os.ReadFile(notificationDigestFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string][]digestItem{}, nil
+		}
+		return nil, err
+	}
+
+	pending := map[string][]digestItem{}
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// saveNotificationDigest 将摘要队列写回文件
+func saveNotificationDigest(pending map[string][]digestItem) error {
+	if err := os.MkdirAll(filepath.Dir(notificationDigestFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(notificationDigestFile, data, 0644)
+}
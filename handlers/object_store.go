@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultObjectStoreRegion = "us-east-1"
+	defaultSignedURLTTL      = 60 * time.Minute
+	// objectStoreUploadTimeout 单个片段上传的超时时间
+	objectStoreUploadTimeout = 30 * time.Minute
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+)
+
+// Validate checks the tiering age and that the object store is fully configured when tiering is enabled
+func (c StorageConfig) Validate() error {
+	if c.ColdAfterDays < 0 {
+		return fmt.Errorf("cold_after_days 不能为负数")
+	}
+	if err := validateRetention(c.Retention); err != nil {
+		return err
+	}
+	if c.ColdAfterDays == 0 {
+		return nil
+	}
+	s := c.ObjectStore
+	if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+		return fmt.Errorf("启用存储分层时必须配置对象存储的 endpoint、bucket、access_key 与 secret_key")
+	}
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("对象存储 endpoint 无效: %s", s.Endpoint)
+	}
+	if s.SignedURLTTLMinutes < 0 || s.SignedURLTTLMinutes > 7*24*60 {
+		return fmt.Errorf("signed_url_ttl_minutes 必须在 0 到 10080 之间")
+	}
+	return nil
+}
+
+// objectStore S3 兼容的对象存储（AWS S3、MinIO、R2 等），使用路径风格地址与 SigV4 签名
+type objectStore struct {
+	cfg      ObjectStoreConfig
+	endpoint *url.URL
+	region   string
+}
+
+// newObjectStore 按当前配置创建对象存储客户端，未配置时返回 nil
+func newObjectStore() *objectStore {
+	cfg := GetStorageConfig().ObjectStore
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil
+	}
+	u, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil {
+		return nil
+	}
+	region := cfg.Region
+	if region == "" {
+		region = defaultObjectStoreRegion
+	}
+	return &objectStore{cfg: cfg, endpoint: u, region: region}
+}
+
+// objectKey 加上配置的前缀
+func (s *objectStore) objectKey(name string) string {
+	return strings.TrimLeft(s.cfg.Prefix+name, "/")
+}
+
+// objectURL 对象的路径风格地址
+func (s *objectStore) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = "/" + s.cfg.Bucket + "/" + key
+	u.RawPath = "/" + awsURIEncode(s.cfg.Bucket, false) + "/" + awsURIEncode(key, false)
+	return &u
+}
+
+// PutFile 上传本地文件，对象内容不参与签名（UNSIGNED-PAYLOAD），避免为计算哈希读取两遍大文件
+func (s *objectStore) PutFile(ctx context.Context, key, path, contentType string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, unsignedPayload, time.Now())
+
+	resp, err := newTracedHTTPClient(objectStoreUploadTimeout).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("上传到对象存储失败 (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Size 读取对象的大小，用于上传后校验
+func (s *objectStore) Size(ctx context.Context, key string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.objectURL(key).String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	s.sign(req, hex.EncodeToString(sha256.New().Sum(nil)), time.Now())
+
+	resp, err := newTracedHTTPClient(30 * time.Second).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("对象存储返回状态 %d", resp.StatusCode)
+	}
+	return strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+}
+
+// SignedURL 生成对象的限时下载链接
+func (s *objectStore) SignedURL(key string, now time.Time) string {
+	ttl := time.Duration(s.cfg.SignedURLTTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = defaultSignedURLTTL
+	}
+
+	u := s.objectURL(key)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := s.scope(now)
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.cfg.AccessKey + "/" + scope},
+		"X-Amz-Date":          {amzDate},
+		"X-Amz-Expires":       {strconv.Itoa(int(ttl.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		awsCanonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	query.Set("X-Amz-Signature", s.signature(canonicalRequest, amzDate, scope, now))
+	u.RawQuery = awsCanonicalQuery(query)
+	return u.String()
+}
+
+// sign 为请求添加 SigV4 Authorization 请求头
+func (s *objectStore) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := s.scope(now)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, s.signature(canonicalRequest, amzDate, scope, now)))
+}
+
+// scope 签名的凭据范围：日期/区域/s3/aws4_request
+func (s *objectStore) scope(now time.Time) string {
+	return now.UTC().Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// signature 计算 SigV4 签名
+func (s *objectStore) signature(canonicalRequest, amzDate, scope string, now time.Time) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), now.UTC().Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsCanonicalQuery 按参数名排序并按 SigV4 规则编码查询参数
+func awsCanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsURIEncode SigV4 的 URI 编码：只保留非保留字符，encodeSlash 为 false 时保留路径分隔符
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"subtuber-services/chatdownload"
+)
+
+// 离线工具（命令行子命令）使用的入口，复用与 HTTP 服务相同的实现，但不依赖 gin
+
+// DefaultPeakParams 服务端使用的默认峰值检测参数，命令行参数以此为默认值
+func DefaultPeakParams() PeakDetectionParams {
+	return defaultPeakParams
+}
+
+// AnalyzeChatFile 读取本地聊天记录文件（Twitch 或 YouTube 格式）并进行峰值检测
+// 返回分析结果与评论总数
+func AnalyzeChatFile(path string, params PeakDetectionParams) (AnalysisResultWithTimeSeries, int, error) {
+	if err := validatePeakMethod(params.Method); err != nil {
+		return AnalysisResultWithTimeSeries{}, 0, err
+	}
+
+	offsets, _, err := loadTwitchChatOffsets(path)
+	if err != nil || len(offsets) == 0 {
+		chatLogs, err := chatdownload.LoadYouTube(path)
+		if err != nil {
+			return AnalysisResultWithTimeSeries{}, 0, fmt.Errorf("无法识别的聊天记录格式: %w", err)
+		}
+		offsets = make([]float64, 0, len(chatLogs))
+		for _, chat := range chatLogs {
+			offsets = append(offsets, chat.OffsetSeconds)
+		}
+	}
+
+	result := NewAnalysisSession(offsets).Analyze(normalizePeakParams(params))
+	return result, len(offsets), nil
+}
+
+// DownloadTwitchChatToFile 下载 Twitch VOD 聊天记录并保存到 chat_logs，返回文件路径与评论数
+// 已初始化 Twitch 监控时附带录像元数据，否则只下载评论
+func DownloadTwitchChatToFile(videoID string, startTime, endTime *float64) (string, int, error) {
+	var videoInfo chatdownload.VideoInfoFunc
+	if monitor := GetTwitchMonitor(); monitor != nil {
+		videoInfo = monitor.getVideoInfo
+	}
+
+	downloader := chatdownload.NewTwitchDownloader(newTracedHTTPClient(30*time.Second), videoInfo)
+	savedPath, response, err := downloadTwitchChatLog(downloader, videoID, startTime, endTime)
+	if err != nil {
+		return "", 0, err
+	}
+	return savedPath, response.TotalComments, nil
+}
+
+// SummarizeSRTFile 使用配置的 AI 服务总结本地字幕文件
+func SummarizeSRTFile(ctx context.Context, path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取字幕文件失败: %w", err)
+	}
+
+	aiService := NewConfiguredAIService()
+
+	summary, _, err := aiService.SummarizeSRT(ctx, string(content), 0)
+	if err != nil {
+		return "", fmt.Errorf("AI总结失败: %w", err)
+	}
+	return summary, nil
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultOllamaBaseURL = "http://localhost:11434"
+
+// ollamaTimeout 本地模型生成较慢，单次请求的超时比云端提供商更长
+const ollamaTimeout = 300 * time.Second
+
+// OllamaAIService provides AI summarization and content generation using a local Ollama server
+type OllamaAIService struct {
+	baseURL         string
+	model           string
+	maxOutputTokens int
+	contextTokens   int
+	client          *http.Client
+}
+
+// NewOllamaAIService creates a new Ollama service instance from the ollama config
+func NewOllamaAIService() *OllamaAIService {
+	config := GetOllamaConfig()
+	s := &OllamaAIService{
+		baseURL:         strings.TrimRight(config.BaseURL, "/"),
+		model:           config.Model,
+		maxOutputTokens: config.MaxOutputTokens,
+		contextTokens:   config.ContextTokens,
+		client:          newTracedHTTPClient(0),
+	}
+	if s.baseURL == "" {
+		s.baseURL = defaultOllamaBaseURL
+	}
+	return s
+}
+
+// Validate checks that the Ollama token limits are not negative
+func (c OllamaConfig) Validate() error {
+	if c.MaxOutputTokens < 0 || c.ContextTokens < 0 {
+		return fmt.Errorf("Ollama 输出 token 上限与上下文长度不能为负数")
+	}
+	return nil
+}
+
+// ollamaChatRequest /api/chat 的请求体
+type ollamaChatRequest struct {
+	Model    string              `json:"model"`
+	Messages []ollamaChatMessage `json:"messages"`
+	Stream   bool                `json:"stream"`
+	Options  map[string]int      `json:"options,omitempty"`
+}
+
+type ollamaChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ollamaChatResponse /api/chat 的响应，流式请求时每行一个
+type ollamaChatResponse struct {
+	Message ollamaChatMessage `json:"message"`
+	Done    bool              `json:"done"`
+	Error   string            `json:"error"`
+}
+
+// chat 发送 /api/chat 请求并逐条回调响应，非流式请求只有一条
+func (s *OllamaAIService) chat(ctx context.Context, prompt string, maxOutputTokens int, stream bool, onMessage func(ollamaChatResponse) error) error {
+	if s.model == "" {
+		return errors.New("Ollama model not configured")
+	}
+
+	req := ollamaChatRequest{
+		Model:    s.model,
+		Messages: []ollamaChatMessage{{Role: "user", Content: prompt}},
+		Stream:   stream,
+		Options:  map[string]int{},
+	}
+	if maxOutputTokens = capOutputTokens(maxOutputTokens, s.maxOutputTokens); maxOutputTokens > 0 {
+		req.Options["num_predict"] = maxOutputTokens
+	}
+	if s.contextTokens > 0 {
+		req.Options["num_ctx"] = s.contextTokens
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("Ollama 服务无法访问: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failed ollamaChatResponse
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(data, &failed) == nil && failed.Error != "" {
+			return fmt.Errorf("Ollama 返回 %d: %s", resp.StatusCode, failed.Error)
+		}
+		return fmt.Errorf("Ollama 返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg ollamaChatResponse
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return fmt.Errorf("解析 Ollama 响应失败: %w", err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("Ollama: %s", msg.Error)
+		}
+		if err := onMessage(msg); err != nil {
+			return err
+		}
+		if msg.Done {
+			break
+		}
+	}
+	return scanner.Err()
+}
+
+// GenerateContent generates content using the local Ollama model with a given prompt
+// Input: prompt string, maxOutputTokens int (capped by ollama.max_output_tokens)
+// Output: generated text string
+func (s *OllamaAIService) GenerateContent(ctx context.Context, prompt string, maxOutputTokens int) (string, error) {
+	return s.GenerateContentStream(ctx, prompt, maxOutputTokens, func(string) error { return nil })
+}
+
+// GenerateContentStream generates content using the Ollama streaming API, calling onDelta with each piece of text as it arrives
+// Input: prompt string, maxOutputTokens int, onDelta callback
+// Output: the complete generated text string
+func (s *OllamaAIService) GenerateContentStream(ctx context.Context, prompt string, maxOutputTokens int, onDelta func(delta string) error) (string, error) {
+	// 按提供商的自适应并发数排队，队列已满时返回 errAIBackpressure
+	return withAILimiter(ctx, "ollama", func(call *aiCall) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, ollamaTimeout)
+		defer cancel()
+
+		log.Printf("Calling Ollama (%s) with maxOutputTokens: %d, prompt length: %d", s.model, maxOutputTokens, len(prompt))
+
+		// 非流式调用同样按流式读取，避免长时间生成时连接空闲
+		var text strings.Builder
+		err := s.chat(ctx, prompt, maxOutputTokens, true, func(msg ollamaChatResponse) error {
+			if msg.Message.Content == "" {
+				return nil
+			}
+			call.delta()
+			text.WriteString(msg.Message.Content)
+			return onDelta(msg.Message.Content)
+		})
+		if err != nil {
+			return text.String(), fmt.Errorf("failed to generate content: %w", err)
+		}
+		if text.Len() == 0 {
+			return "", errors.New("no generated text found in response")
+		}
+
+		log.Printf("Received response length: %d characters", text.Len())
+
+		return text.String(), nil
+	})
+}
+
+// SummarizeSRT summarizes SRT subtitle content
+// Input: srtContent string (SRT file content), chunkChars int (size of each chunk, 0 plans it from the transcript duration)
+// Output: final summary string, chunk summaries []string
+func (s *OllamaAIService) SummarizeSRT(ctx context.Context, srtContent string, chunkChars int) (string, []string, error) {
+	return summarizeSRTWithBudget(ctx, s, srtContent, chunkChars)
+}
+
+// SaveSummaryToFile saves the summary to a text file next to the subtitle file
+func (s *OllamaAIService) SaveSummaryToFile(srtFilePath, summary string) error {
+	return saveSummaryNextToSubtitle(srtFilePath, summary)
+}
+
+// Probe 列出 Ollama 已拉取的模型，确认服务可以访问且配置的模型已拉取
+func (s *OllamaAIService) Probe(ctx context.Context) error {
+	if s.model == "" {
+		return errors.New("未配置 Ollama 模型 ollama.model")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Ollama 服务无法访问: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Ollama 返回 %d", resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("解析 Ollama 模型列表失败: %w", err)
+	}
+	for _, m := range tags.Models {
+		// 未指定标签的模型名对应 latest
+		if m.Name == s.model || m.Name == s.model+":latest" {
+			return nil
+		}
+	}
+	return fmt.Errorf("Ollama 尚未拉取模型 %s（ollama pull %s）", s.model, s.model)
+}
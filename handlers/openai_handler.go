@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+const (
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultOpenAIModel   = "gpt-4o-mini"
+)
+
+// OpenAIChatService provides AI summarization and content generation using the OpenAI chat completions API
+// or a compatible server (vLLM, LM Studio, etc.) configured through openai_api.base_url
+type OpenAIChatService struct {
+	apiKey          string
+	baseURL         string
+	model           string
+	maxOutputTokens int
+	client          *openai.Client
+}
+
+// NewOpenAIChatService creates a new OpenAI chat service instance
+// If apiKey is empty, it will use the configured API key or the OPENAI_API_KEY environment variable
+func NewOpenAIChatService(apiKey string) *OpenAIChatService {
+	config := GetOpenAIAPIConfig()
+	if apiKey == "" {
+		apiKey = config.APIKey
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+
+	s := &OpenAIChatService{
+		apiKey:          apiKey,
+		baseURL:         strings.TrimRight(config.BaseURL, "/"),
+		model:           config.Model,
+		maxOutputTokens: config.MaxOutputTokens,
+	}
+	if s.baseURL == "" {
+		s.baseURL = defaultOpenAIBaseURL
+	}
+	if s.model == "" {
+		s.model = defaultOpenAIModel
+	}
+
+	opts := []option.RequestOption{
+		option.WithBaseURL(s.baseURL),
+		option.WithHTTPClient(newTracedHTTPClient(0)),
+	}
+	// 自建的兼容服务可以不需要 API Key
+	if apiKey != "" {
+		opts = append(opts, option.WithAPIKey(apiKey))
+	}
+	client := openai.NewClient(opts...)
+	s.client = &client
+	return s
+}
+
+// configured 官方接口必须配置 API Key
+func (s *OpenAIChatService) configured() error {
+	if s.apiKey == "" && s.baseURL == defaultOpenAIBaseURL {
+		return errors.New("OpenAI API key not configured")
+	}
+	return nil
+}
+
+// params 构造请求参数；官方接口使用 max_completion_tokens，兼容服务大多只支持 max_tokens
+func (s *OpenAIChatService) params(prompt string, maxOutputTokens int) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.UserMessage(prompt),
+		},
+		Model: s.model,
+	}
+	if maxOutputTokens = capOutputTokens(maxOutputTokens, s.maxOutputTokens); maxOutputTokens > 0 {
+		if s.baseURL == defaultOpenAIBaseURL {
+			params.MaxCompletionTokens = openai.Int(int64(maxOutputTokens))
+		} else {
+			params.MaxTokens = openai.Int(int64(maxOutputTokens))
+		}
+	}
+	return params
+}
+
+// GenerateContent generates content using the OpenAI chat completions API with a given prompt
+// Input: prompt string, maxOutputTokens int (capped by openai_api.max_output_tokens)
+// Output: generated text string
+func (s *OpenAIChatService) GenerateContent(ctx context.Context, prompt string, maxOutputTokens int) (string, error) {
+	if err := s.configured(); err != nil {
+		return "", err
+	}
+
+	// 按提供商的自适应并发数排队，队列已满时返回 errAIBackpressure
+	return withAILimiter(ctx, "openai", func(call *aiCall) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+		defer cancel()
+
+		log.Printf("Calling OpenAI API (%s) with maxOutputTokens: %d, prompt length: %d", s.model, maxOutputTokens, len(prompt))
+
+		chatCompletion, err := s.client.Chat.Completions.New(ctx, s.params(prompt, maxOutputTokens))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate content: %w", err)
+		}
+
+		if len(chatCompletion.Choices) == 0 {
+			return "", errors.New("no choices returned from API")
+		}
+
+		text := chatCompletion.Choices[0].Message.Content
+		if text == "" {
+			return "", errors.New("no generated text found in response")
+		}
+
+		log.Printf("Received response length: %d characters", len(text))
+
+		return text, nil
+	})
+}
+
+// GenerateContentStream generates content using the OpenAI streaming API, calling onDelta with each piece of text as it arrives
+// Input: prompt string, maxOutputTokens int, onDelta callback
+// Output: the complete generated text string
+func (s *OpenAIChatService) GenerateContentStream(ctx context.Context, prompt string, maxOutputTokens int, onDelta func(delta string) error) (string, error) {
+	if err := s.configured(); err != nil {
+		return "", err
+	}
+
+	// 按提供商的自适应并发数排队，队列已满时返回 errAIBackpressure
+	return withAILimiter(ctx, "openai", func(call *aiCall) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+		defer cancel()
+
+		log.Printf("Calling OpenAI streaming API (%s) with maxOutputTokens: %d, prompt length: %d", s.model, maxOutputTokens, len(prompt))
+
+		stream := s.client.Chat.Completions.NewStreaming(ctx, s.params(prompt, maxOutputTokens))
+		defer stream.Close()
+
+		var text strings.Builder
+		for stream.Next() {
+			chunk := stream.Current()
+			if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+				continue
+			}
+			call.delta()
+			delta := chunk.Choices[0].Delta.Content
+			text.WriteString(delta)
+			if err := onDelta(delta); err != nil {
+				return text.String(), err
+			}
+		}
+		if err := stream.Err(); err != nil {
+			return text.String(), fmt.Errorf("failed to generate content: %w", err)
+		}
+		if text.Len() == 0 {
+			return "", errors.New("no generated text found in response")
+		}
+
+		log.Printf("Received streamed response length: %d characters", text.Len())
+
+		return text.String(), nil
+	})
+}
+
+// SummarizeSRT summarizes SRT subtitle content
+// Input: srtContent string (SRT file content), chunkChars int (size of each chunk, 0 plans it from the transcript duration)
+// Output: final summary string, chunk summaries []string
+func (s *OpenAIChatService) SummarizeSRT(ctx context.Context, srtContent string, chunkChars int) (string, []string, error) {
+	return summarizeSRTWithBudget(ctx, s, srtContent, chunkChars)
+}
+
+// SaveSummaryToFile saves the summary to a text file next to the subtitle file
+func (s *OpenAIChatService) SaveSummaryToFile(srtFilePath, summary string) error {
+	return saveSummaryNextToSubtitle(srtFilePath, summary)
+}
+
+// Probe 列出接口上的模型，确认可以访问、API Key 有效且配置的模型存在，不消耗生成额度
+func (s *OpenAIChatService) Probe(ctx context.Context) error {
+	if err := s.configured(); err != nil {
+		return err
+	}
+	page, err := s.client.Models.List(ctx)
+	if err != nil {
+		return fmt.Errorf("OpenAI 接口无法访问: %w", err)
+	}
+	for _, model := range page.Data {
+		if model.ID == s.model {
+			return nil
+		}
+	}
+	return fmt.Errorf("OpenAI 接口上没有模型 %s", s.model)
+}
@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// overlayHistorySeconds 热度计保留的每秒弹幕数历史，用作基线
+	overlayHistorySeconds = 600
+	// overlayRecentSeconds 计算当前热度的窗口
+	overlayRecentSeconds = 10
+	// overlayIdleTimeout 浏览器源超过该时间没有拉取状态时停止统计
+	overlayIdleTimeout = 60 * time.Second
+	// overlaySpikeRatio 当前窗口弹幕数达到基线的该倍数时触发刷屏提醒
+	overlaySpikeRatio = 2.5
+	// overlaySpikeMinMessages 触发提醒的最少弹幕数，避免冷清时少量弹幕就触发
+	overlaySpikeMinMessages = 10
+	// overlaySpikeCooldown 两次提醒的最短间隔
+	overlaySpikeCooldown = 60 * time.Second
+	// overlayMaxAlerts 保留的最近提醒数
+	overlayMaxAlerts = 5
+	// overlayPollInterval 页面拉取状态的间隔（毫秒）
+	overlayPollInterval = 2000
+)
+
+// OverlayAlert 直播中弹幕突然刷屏的提醒
+type OverlayAlert struct {
+	At       time.Time `json:"at"`
+	Messages int       `json:"messages"` // 窗口内的弹幕数
+	Ratio    float64   `json:"ratio"`    // 相对基线的倍数
+	Keywords []string  `json:"keywords"` // 刷屏内容中最多的词
+}
+
+// overlayLine 最近窗口内的弹幕，用于提取刷屏关键词
+type overlayLine struct {
+	at   time.Time
+	text string
+}
+
+// overlayMeter 单个主播的直播热度统计，复用直播聊天采集，多个浏览器源共享
+type overlayMeter struct {
+	mu         sync.Mutex
+	streamerID string
+	counts     [overlayHistorySeconds]int
+	seconds    [overlayHistorySeconds]int64
+	recent     []overlayLine
+	alerts     []OverlayAlert
+	lastAlert  time.Time
+	lastPolled time.Time
+	startedAt  time.Time
+}
+
+var (
+	overlayMetersMu sync.Mutex
+	overlayMeters   = map[string]*overlayMeter{}
+)
+
+// getOverlayMeter 返回主播的热度统计，不存在时订阅直播聊天并开始统计
+func getOverlayMeter(streamerID, login string) (*overlayMeter, error) {
+	overlayMetersMu.Lock()
+	defer overlayMetersMu.Unlock()
+
+	if meter, ok := overlayMeters[streamerID]; ok {
+		return meter, nil
+	}
+	sub, ended, unsubscribe, err := subscribeLiveChat(streamerID, login, liveChatFilter{})
+	if err != nil {
+		return nil, err
+	}
+	meter := &overlayMeter{streamerID: streamerID, lastPolled: time.Now(), startedAt: time.Now()}
+	overlayMeters[streamerID] = meter
+	go meter.run(sub, ended, unsubscribe)
+	log.Printf("开始统计 %s 的直播热度（OBS 浏览器源）", streamerID)
+	return meter, nil
+}
+
+// run 统计弹幕并每秒检测刷屏，直播结束或浏览器源不再拉取时退出
+func (m *overlayMeter) run(sub *liveChatSubscriber, ended <-chan struct{}, unsubscribe func()) {
+	defer func() {
+		overlayMetersMu.Lock()
+		if overlayMeters[m.streamerID] == m {
+			delete(overlayMeters, m.streamerID)
+		}
+		overlayMetersMu.Unlock()
+		unsubscribe()
+		log.Printf("停止统计 %s 的直播热度", m.streamerID)
+	}()
+
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ended:
+			return
+		case msg := <-sub.ch:
+			m.add(msg.SentAt, msg.Text)
+		case now := <-tick.C:
+			m.mu.Lock()
+			idle := now.Sub(m.lastPolled) > overlayIdleTimeout
+			m.detectSpikeLocked(now)
+			m.mu.Unlock()
+			if idle {
+				return
+			}
+		}
+	}
+}
+
+// add 记录一条弹幕
+func (m *overlayMeter) add(at time.Time, text string) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	sec := at.Unix()
+	idx := int(sec % overlayHistorySeconds)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.seconds[idx] != sec {
+		m.seconds[idx], m.counts[idx] = sec, 0
+	}
+	m.counts[idx]++
+	m.recent = append(m.recent, overlayLine{at: at, text: text})
+}
+
+// windowCountLocked [now-seconds, now) 内的弹幕数，调用方需持有 m.mu
+func (m *overlayMeter) windowCountLocked(now time.Time, from, seconds int) int {
+	total := 0
+	end := now.Unix() - int64(from)
+	for sec := end - int64(seconds); sec < end; sec++ {
+		idx := int(((sec % overlayHistorySeconds) + overlayHistorySeconds) % overlayHistorySeconds)
+		if m.seconds[idx] == sec {
+			total += m.counts[idx]
+		}
+	}
+	return total
+}
+
+// baselineLocked 最近一段时间（不含当前窗口）每个窗口的平均弹幕数，调用方需持有 m.mu
+func (m *overlayMeter) baselineLocked(now time.Time) float64 {
+	history := overlayHistorySeconds - overlayRecentSeconds
+	if elapsed := int(now.Sub(m.startedAt).Seconds()) - overlayRecentSeconds; elapsed < history {
+		history = elapsed
+	}
+	if history < overlayRecentSeconds {
+		return 0
+	}
+	return float64(m.windowCountLocked(now, overlayRecentSeconds, history)) * overlayRecentSeconds / float64(history)
+}
+
+// detectSpikeLocked 当前窗口弹幕数明显高于基线时记录提醒，调用方需持有 m.mu
+func (m *overlayMeter) detectSpikeLocked(now time.Time) {
+	cutoff := now.Add(-overlayRecentSeconds * time.Second)
+	kept := m.recent[:0]
+	for _, line := range m.recent {
+		if line.at.After(cutoff) {
+			kept = append(kept, line)
+		}
+	}
+	m.recent = kept
+
+	current := m.windowCountLocked(now, 0, overlayRecentSeconds)
+	baseline := m.baselineLocked(now)
+	if baseline <= 0 || current < overlaySpikeMinMessages || now.Sub(m.lastAlert) < overlaySpikeCooldown {
+		return
+	}
+	ratio := float64(current) / baseline
+	if ratio < overlaySpikeRatio {
+		return
+	}
+
+	m.lastAlert = now
+	m.alerts = append(m.alerts, OverlayAlert{
+		At:       now,
+		Messages: current,
+		Ratio:    math.Round(ratio*10) / 10,
+		Keywords: overlaySpikeKeywords(m.recent),
+	})
+	if len(m.alerts) > overlayMaxAlerts {
+		m.alerts = m.alerts[len(m.alerts)-overlayMaxAlerts:]
+	}
+}
+
+// overlaySpikeKeywords 刷屏弹幕中出现最多的词
+func overlaySpikeKeywords(lines []overlayLine) []string {
+	counts := map[string]int{}
+	for _, line := range lines {
+		for _, token := range chapterTokens(line.text) {
+			counts[token]++
+		}
+	}
+	tokens := make([]string, 0, len(counts))
+	for token, n := range counts {
+		if n >= 2 {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if counts[tokens[i]] != counts[tokens[j]] {
+			return counts[tokens[i]] > counts[tokens[j]]
+		}
+		return tokens[i] < tokens[j]
+	})
+	if len(tokens) > chapterKeywordCount {
+		tokens = tokens[:chapterKeywordCount]
+	}
+	return tokens
+}
+
+// snapshot 当前热度，hotness 为 0-100：当前窗口弹幕数相对基线的倍数，达到提醒倍数时为 100
+func (m *overlayMeter) snapshot(now time.Time) gin.H {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastPolled = now
+
+	current := m.windowCountLocked(now, 0, overlayRecentSeconds)
+	baseline := m.baselineLocked(now)
+	hotness := 0.0
+	switch {
+	case baseline > 0:
+		hotness = math.Min(100, float64(current)/baseline/overlaySpikeRatio*100)
+	case current > 0:
+		hotness = math.Min(100, float64(current)/overlaySpikeMinMessages*100)
+	}
+
+	alerts := make([]OverlayAlert, len(m.alerts))
+	copy(alerts, m.alerts)
+	return gin.H{
+		"live":                true,
+		"hotness":             math.Round(hotness),
+		"messages_per_minute": current * 60 / overlayRecentSeconds,
+		"baseline_per_minute": math.Round(baseline * 60 / overlayRecentSeconds),
+		"alerts":              alerts,
+	}
+}
+
+// overlayStreamerLogin 主播的 Twitch 频道，直播热度依赖直播聊天采集，目前只支持 Twitch
+func overlayStreamerLogin(streamerID string) (string, string, int) {
+	streamer := ResolveStreamer(streamerID)
+	if streamer == nil {
+		return "", "", http.StatusNotFound
+	}
+	for _, platform := range streamer.Platforms {
+		if platform.Platform == "twitch" {
+			return streamer.ID, platformURLHandle(platform.URL), http.StatusOK
+		}
+	}
+	return streamer.ID, "", http.StatusBadRequest
+}
+
+// GetOverlayState 浏览器源拉取的直播热度与最近的刷屏提醒；未直播时 live 为 false
+// GET /overlay/:streamerID/state
+func GetOverlayState(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	streamerID, login, status := overlayStreamerLogin(c.Param("streamerID"))
+	switch status {
+	case http.StatusNotFound:
+		c.JSON(status, gin.H{"error": "未找到该主播"})
+		return
+	case http.StatusBadRequest:
+		c.JSON(status, gin.H{"error": "该主播没有 Twitch 频道，暂不支持其他平台的直播热度"})
+		return
+	}
+
+	monitor := GetTwitchMonitor()
+	if monitor == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Twitch监控服务未启动"})
+		return
+	}
+	if s := monitor.GetStreamerStatus(streamerID); s == nil || !s.IsLive {
+		c.JSON(http.StatusOK, gin.H{"live": false, "hotness": 0, "alerts": []OverlayAlert{}})
+		return
+	}
+
+	meter, err := getOverlayMeter(streamerID, login)
+	if err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, meter.snapshot(time.Now()))
+}
+
+// GetOverlayPage OBS 浏览器源页面：透明背景的热度条与刷屏提醒，定时拉取状态
+// 查询参数 alerts=0 隐藏提醒，width 设置热度条宽度（像素）
+// GET /overlay/:streamerID
+func GetOverlayPage(c *gin.Context) {
+	streamerID, _, status := overlayStreamerLogin(c.Param("streamerID"))
+	switch status {
+	case http.StatusNotFound:
+		c.String(status, "未找到该主播")
+		return
+	case http.StatusBadRequest:
+		c.String(status, "该主播没有 Twitch 频道，暂不支持其他平台的直播热度")
+		return
+	}
+
+	width := 400
+	if w, err := strconv.Atoi(c.Query("width")); err == nil && w >= 100 && w <= 1920 {
+		width = w
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	_ = overlayPageTemplate.Execute(c.Writer, gin.H{
+		"StreamerID":   streamerID,
+		"StateURL":     "/overlay/" + streamerID + "/state",
+		"PollInterval": overlayPollInterval,
+		"ShowAlerts":   c.Query("alerts") != "0",
+		"Width":        width,
+	})
+}
+
+var overlayPageTemplate = template.Must(template.New("overlay").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>LumiTime Overlay - {{.StreamerID}}</title>
+<style>
+html,body{margin:0;background:transparent;font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",sans-serif;color:#fff;text-shadow:0 1px 3px rgba(0,0,0,.8)}
+#meter{width:{{.Width}}px;padding:8px}
+#label{font-size:14px;margin-bottom:4px}
+#bar{height:14px;border-radius:7px;background:rgba(0,0,0,.45);overflow:hidden}
+#fill{height:100%;width:0;background:linear-gradient(90deg,#4ade80,#facc15,#f43f5e);transition:width .8s ease}
+#alerts{margin-top:8px}
+.alert{font-size:15px;font-weight:600;margin:4px 0;padding:4px 8px;border-radius:6px;background:rgba(244,63,94,.75);animation:pop .4s ease}
+@keyframes pop{from{transform:scale(.8);opacity:0}to{transform:scale(1);opacity:1}}
+.offline #fill{width:0!important}
+</style>
+</head>
+<body>
+<div id="meter">
+<div id="label">🔥 <span id="hotness">0</span> · <span id="rate">0</span> 条/分钟</div>
+<div id="bar"><div id="fill"></div></div>
+{{if .ShowAlerts}}<div id="alerts"></div>{{end}}
+</div>
+<script>
+(function(){
+  var stateURL = {{.StateURL}}, interval = {{.PollInterval}}, alertTTL = 30000;
+  var seen = {};
+  function render(s){
+    document.body.className = s.live ? "" : "offline";
+    document.getElementById("hotness").textContent = s.live ? s.hotness : "未开播";
+    document.getElementById("rate").textContent = s.messages_per_minute || 0;
+    document.getElementById("fill").style.width = (s.hotness || 0) + "%";
+    var box = document.getElementById("alerts");
+    if (!box) return;
+    (s.alerts || []).forEach(function(a){
+      if (seen[a.at] || Date.now() - new Date(a.at).getTime() > alertTTL) return;
+      seen[a.at] = true;
+      var el = document.createElement("div");
+      el.className = "alert";
+      el.textContent = "弹幕刷屏 ×" + a.ratio + (a.keywords && a.keywords.length ? "：" + a.keywords.join(" ") : "");
+      box.insertBefore(el, box.firstChild);
+      setTimeout(function(){ el.remove(); }, alertTTL);
+    });
+  }
+  function poll(){
+    fetch(stateURL, {cache: "no-store"}).then(function(r){ return r.json(); })
+      .then(render).catch(function(){})
+      .then(function(){ setTimeout(poll, interval); });
+  }
+  poll();
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"subtuber-services/chatdownload"
+	"subtuber-services/models"
+	"subtuber-services/pathsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	parquetExportsFile = "App_Data/parquet_exports.json"
+	// parquetExportRoot 对象存储中导出数据的根目录，其下按数据集与 streamer=/date= 分区
+	parquetExportRoot = "parquet/"
+
+	parquetDatasetChat       = "chat_messages"
+	parquetDatasetHotMoments = "hot_moments"
+)
+
+// chatLogFileName 聊天记录文件名：chat_[youtube_]{videoID}_{yyyymmdd_hhmmss}.json[.gz] 或 .ndjson.gz
+var chatLogFileName = regexp.MustCompile(`^chat_(youtube_)?(.+)_(\d{8}_\d{6})\.(nd)?json(\.gz)?$`)
+
+// ParquetExportStatus 导出任务的状态，同一时间只运行一个导出任务
+type ParquetExportStatus struct {
+	Running    bool       `json:"running"`
+	Force      bool       `json:"force"`
+	Streamer   string     `json:"streamer,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Exported   int        `json:"exported"`
+	Skipped    int        `json:"skipped"`
+	Failed     int        `json:"failed"`
+	Bytes      int64      `json:"bytes"`
+	Errors     []string   `json:"errors,omitempty"`
+}
+
+// parquetExportRecord 已导出文件的来源与位置，源文件未变化时下次导出跳过
+type parquetExportRecord struct {
+	Key        string    `json:"key"`
+	SourceTime time.Time `json:"source_time"`
+	Rows       int       `json:"rows"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// parquetExportSource 一个待导出的录像
+type parquetExportSource struct {
+	videoID  string
+	platform string
+	chatLog  string
+	streamer string // 分区用的主播标识
+	date     string // 分区用的录像日期 yyyy-mm-dd
+	start    time.Time
+}
+
+var (
+	parquetExportMu     sync.Mutex
+	parquetExportStatus *ParquetExportStatus
+)
+
+// startParquetExport 在后台启动导出任务，已有任务运行时返回错误
+func startParquetExport(streamer string, force bool) (*ParquetExportStatus, error) {
+	store := newObjectStore()
+	if store == nil {
+		return nil, fmt.Errorf("未配置对象存储")
+	}
+
+	parquetExportMu.Lock()
+	defer parquetExportMu.Unlock()
+	if parquetExportStatus != nil && parquetExportStatus.Running {
+		return nil, fmt.Errorf("已有导出任务正在运行")
+	}
+	status := &ParquetExportStatus{Running: true, Force: force, Streamer: streamer, StartedAt: time.Now()}
+	parquetExportStatus = status
+	snapshot := *status
+
+	go runParquetExport(context.Background(), store, streamer, force)
+	return &snapshot, nil
+}
+
+// currentParquetExport 最近一次导出任务的状态
+func currentParquetExport() *ParquetExportStatus {
+	parquetExportMu.Lock()
+	defer parquetExportMu.Unlock()
+	if parquetExportStatus == nil {
+		return nil
+	}
+	snapshot := *parquetExportStatus
+	snapshot.Errors = append([]string(nil), parquetExportStatus.Errors...)
+	return &snapshot
+}
+
+// updateParquetExport 修改当前导出任务的状态
+func updateParquetExport(update func(s *ParquetExportStatus)) {
+	parquetExportMu.Lock()
+	defer parquetExportMu.Unlock()
+	update(parquetExportStatus)
+}
+
+// runParquetExport 将聊天记录与分析结果转换为 Parquet 并上传到对象存储
+// 每个录像生成一个文件，路径为 parquet/{数据集}/streamer={主播}/date={日期}/{videoID}.parquet
+func runParquetExport(ctx context.Context, store *objectStore, streamer string, force bool) {
+	defer updateParquetExport(func(s *ParquetExportStatus) {
+		now := time.Now()
+		s.Running = false
+		s.FinishedAt = &now
+		log.Printf("Parquet 导出完成: 导出 %d 个文件 (%d 字节)，跳过 %d 个，失败 %d 个", s.Exported, s.Bytes, s.Skipped, s.Failed)
+	})
+
+	records := loadParquetExportRecords()
+	for _, src := range parquetExportSources() {
+		if ctx.Err() != nil {
+			break
+		}
+		if streamer != "" && !strings.EqualFold(src.streamer, streamer) {
+			continue
+		}
+
+		for _, dataset := range []string{parquetDatasetChat, parquetDatasetHotMoments} {
+			sourceTime, ok := parquetSourceTime(src, dataset)
+			if !ok {
+				continue
+			}
+			id := dataset + "/" + src.videoID
+			if prev, exists := records[id]; exists && !force && !sourceTime.After(prev.SourceTime) {
+				updateParquetExport(func(s *ParquetExportStatus) { s.Skipped++ })
+				continue
+			}
+
+			record, size, err := exportParquetDataset(ctx, store, src, dataset)
+			if err != nil {
+				log.Printf("导出 %s 失败: %v", id, err)
+				updateParquetExport(func(s *ParquetExportStatus) {
+					s.Failed++
+					if len(s.Errors) < 20 {
+						s.Errors = append(s.Errors, fmt.Sprintf("%s: %v", id, err))
+					}
+				})
+				continue
+			}
+			record.SourceTime = sourceTime
+			records[id] = record
+			if err := saveParquetExportRecords(records); err != nil {
+				log.Printf("保存 Parquet 导出记录失败: %v", err)
+			}
+			updateParquetExport(func(s *ParquetExportStatus) {
+				s.Exported++
+				s.Bytes += size
+			})
+		}
+	}
+}
+
+// parquetSourceTime 数据集源文件的最后修改时间，没有该数据集时返回 false
+func parquetSourceTime(src parquetExportSource, dataset string) (time.Time, bool) {
+	var paths []string
+	switch dataset {
+	case parquetDatasetChat:
+		paths = []string{src.chatLog}
+	case parquetDatasetHotMoments:
+		paths, _ = filepath.Glob(filepath.Join(pathsafe.Join("./analysis_results", src.videoID), "analysis_*.json"))
+	}
+
+	var latest time.Time
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, !latest.IsZero()
+}
+
+// exportParquetDataset 生成单个录像的 Parquet 文件并上传
+func exportParquetDataset(ctx context.Context, store *objectStore, src parquetExportSource, dataset string) (parquetExportRecord, int64, error) {
+	var (
+		columns []*parquetColumn
+		rows    int
+		err     error
+	)
+	switch dataset {
+	case parquetDatasetChat:
+		columns, rows, err = chatParquetColumns(src)
+	case parquetDatasetHotMoments:
+		columns, rows, err = hotMomentParquetColumns(src)
+	}
+	if err != nil {
+		return parquetExportRecord{}, 0, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, columns); err != nil {
+		return parquetExportRecord{}, 0, err
+	}
+
+	tmp, err := os.CreateTemp("", "export-*.parquet")
+	if err != nil {
+		return parquetExportRecord{}, 0, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return parquetExportRecord{}, 0, err
+	}
+
+	key := store.objectKey(fmt.Sprintf("%s%s/streamer=%s/date=%s/%s.parquet",
+		parquetExportRoot, dataset, src.streamer, src.date, metadataKeyUnsafe.ReplaceAllString(src.videoID, "_")))
+	if err := store.PutFile(ctx, key, tmp.Name(), "application/vnd.apache.parquet"); err != nil {
+		return parquetExportRecord{}, 0, err
+	}
+	return parquetExportRecord{Key: key, Rows: rows, ExportedAt: time.Now()}, int64(buf.Len()), nil
+}
+
+// chatParquetColumns 聊天记录转换为统一的列格式，Twitch 与 YouTube 共用同一 schema
+func chatParquetColumns(src parquetExportSource) ([]*parquetColumn, int, error) {
+	videoID := newParquetStringColumn("video_id")
+	platform := newParquetStringColumn("platform")
+	streamer := newParquetStringColumn("streamer")
+	offset := newParquetDoubleColumn("offset_seconds")
+	sentAt := newParquetTimestampColumn("sent_at")
+	userID := newParquetStringColumn("user_id")
+	user := newParquetStringColumn("user")
+	message := newParquetStringColumn("message")
+	badges := newParquetStringColumn("badges")
+	bits := newParquetInt64Column("bits")
+	columns := []*parquetColumn{videoID, platform, streamer, offset, sentAt, userID, user, message, badges, bits}
+
+	add := func(offsetSeconds float64, at time.Time, uid, name, text, badgeList string, bitsSpent int) {
+		if at.IsZero() && !src.start.IsZero() {
+			at = src.start.Add(time.Duration(offsetSeconds * float64(time.Second)))
+		}
+		videoID.appendString(src.videoID)
+		platform.appendString(src.platform)
+		streamer.appendString(src.streamer)
+		offset.appendDouble(offsetSeconds)
+		sentAt.appendTime(at)
+		userID.appendString(uid)
+		user.appendString(name)
+		message.appendString(text)
+		badges.appendString(badgeList)
+		bits.appendInt64(int64(bitsSpent))
+	}
+
+	if src.platform == "youtube" {
+		logs, err := chatdownload.LoadYouTube(src.chatLog)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, l := range logs {
+			add(l.OffsetSeconds, time.Time{}, "", l.Author, l.Message, "", 0)
+		}
+		return columns, len(logs), nil
+	}
+
+	rows := 0
+	_, err := chatdownload.ReadTwitch(src.chatLog, func(comment models.TwitchChatComment) error {
+		names := make([]string, 0, len(comment.Message.UserBadges))
+		for _, badge := range comment.Message.UserBadges {
+			names = append(names, badge.ID)
+		}
+		at, _ := time.Parse(time.RFC3339, comment.CreatedAt)
+		add(comment.ContentOffsetSeconds, at, comment.Commenter.ID, comment.Commenter.Name,
+			comment.Message.Body, strings.Join(names, ","), comment.Message.BitsSpent)
+		rows++
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return columns, rows, nil
+}
+
+// hotMomentParquetColumns 录像所有参数组合的热点，result 列标识来自哪个分析结果文件
+func hotMomentParquetColumns(src parquetExportSource) ([]*parquetColumn, int, error) {
+	videoID := newParquetStringColumn("video_id")
+	platform := newParquetStringColumn("platform")
+	streamer := newParquetStringColumn("streamer")
+	result := newParquetStringColumn("result")
+	method := newParquetStringColumn("method")
+	offset := newParquetDoubleColumn("offset_seconds")
+	score := newParquetDoubleColumn("score")
+	interval := newParquetStringColumn("time_interval")
+	scale := newParquetInt64Column("scale")
+	analyzedAt := newParquetTimestampColumn("analyzed_at")
+	columns := []*parquetColumn{videoID, platform, streamer, result, method, offset, score, interval, scale, analyzedAt}
+
+	matches, _ := filepath.Glob(filepath.Join(pathsafe.Join("./analysis_results", src.videoID), "analysis_*.json"))
+	sort.Strings(matches)
+	rows := 0
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		var res AnalysisResult
+		if err := json.Unmarshal(data, &res); err != nil {
+			return nil, 0, fmt.Errorf("解析 %s 失败: %w", filepath.Base(path), err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		for _, moment := range res.HotMoments {
+			videoID.appendString(src.videoID)
+			platform.appendString(src.platform)
+			streamer.appendString(src.streamer)
+			result.appendString(name)
+			method.appendString(peakMethodName(PeakDetectionParams{Method: res.Method}))
+			offset.appendDouble(moment.OffsetSeconds)
+			score.appendDouble(moment.CommentsScore)
+			interval.appendString(moment.TimeInterval)
+			scale.appendInt64(int64(moment.Scale))
+			analyzedAt.appendTime(res.AnalyzedAt)
+			rows++
+		}
+	}
+	return columns, rows, nil
+}
+
+// parquetExportSources 列出所有有聊天记录的录像及其分区信息，同一录像有多份聊天记录时使用最新的
+func parquetExportSources() []parquetExportSource {
+	paths, _ := filepath.Glob(filepath.Join(chatdownload.LogDir, "chat_*"))
+	sort.Strings(paths)
+
+	byVideo := map[string]parquetExportSource{}
+	for _, path := range paths {
+		m := chatLogFileName.FindStringSubmatch(filepath.Base(path))
+		if m == nil {
+			continue
+		}
+		src := parquetExportSource{videoID: m[2], platform: "twitch", chatLog: path}
+		if m[1] != "" {
+			src.platform = "youtube"
+		}
+		savedAt, _ := time.ParseInLocation("20060102_150405", m[3], time.Local)
+		src.date = savedAt.Format("2006-01-02")
+		byVideo[src.videoID] = src
+	}
+
+	sources := make([]parquetExportSource, 0, len(byVideo))
+	for _, src := range byVideo {
+		account, info := loadVideoMetaForAnalysis(src.videoID)
+		if info != nil {
+			if info.UserLogin != "" {
+				account = info.UserLogin
+			}
+			if start, err := time.Parse(time.RFC3339, info.CreatedAt); err == nil {
+				src.start = start
+				src.date = start.UTC().Format("2006-01-02")
+			}
+		}
+		src.streamer = parquetStreamerPartition(account)
+		sources = append(sources, src)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].videoID < sources[j].videoID })
+	return sources
+}
+
+// parquetStreamerPartition 分区用的主播标识，追踪的主播使用主播ID，使不同平台的数据落在同一分区
+func parquetStreamerPartition(account string) string {
+	if account == "" {
+		return "unknown"
+	}
+	if streamer := ResolveStreamer(account); streamer != nil {
+		account = streamer.ID
+	}
+	return strings.ToLower(metadataKeyUnsafe.ReplaceAllString(account, "_"))
+}
+
+// loadParquetExportRecords 读取已导出文件的记录
+func loadParquetExportRecords() map[string]parquetExportRecord {
+	records := map[string]parquetExportRecord{}
+	data, err := os.ReadFile(parquetExportsFile)
+	if err != nil {
+		return records
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		log.Printf("读取 Parquet 导出记录失败: %v", err)
+	}
+	return records
+}
+
+// saveParquetExportRecords 写回已导出文件的记录
+func saveParquetExportRecords(records map[string]parquetExportRecord) error {
+	if err := os.MkdirAll(filepath.Dir(parquetExportsFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(parquetExportsFile, data, 0644)
+}
+
+// runParquetExportHandler 管理员启动 Parquet 导出任务
+// 请求体可选 streamer（只导出该主播）与 force（忽略已导出记录，全部重新导出）
+func runParquetExportHandler(c *gin.Context) {
+	var req struct {
+		Streamer string `json:"streamer"`
+		Force    bool   `json:"force"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	streamer := ""
+	if req.Streamer != "" {
+		streamer = parquetStreamerPartition(req.Streamer)
+	}
+	status, err := startParquetExport(streamer, req.Force)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "export": status})
+}
+
+// getParquetExportHandler 查询最近一次导出任务的状态
+func getParquetExportHandler(c *gin.Context) {
+	status := currentParquetExport()
+	if status == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "尚未执行过导出"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "export": status})
+}
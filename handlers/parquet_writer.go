@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// 最小化的 Parquet 写入实现：单个行组、每列一个 gzip 压缩的 PLAIN 数据页、所有列均为 REQUIRED
+// 足以让 Spark、DuckDB、pandas 等读取导出的分析数据，不支持嵌套与可空列
+// 格式参考 https://github.com/apache/parquet-format
+
+const parquetMagic = "PAR1"
+
+// Parquet 物理类型
+const (
+	parquetTypeInt64     int32 = 2
+	parquetTypeDouble    int32 = 5
+	parquetTypeByteArray int32 = 6
+)
+
+// Parquet 逻辑类型（ConvertedType），-1 表示无
+const (
+	parquetConvertedNone            int32 = -1
+	parquetConvertedUTF8            int32 = 0
+	parquetConvertedTimestampMillis int32 = 9
+)
+
+const (
+	parquetEncodingPlain   int32 = 0
+	parquetEncodingRLE     int32 = 3
+	parquetCodecGzip       int32 = 2
+	parquetRepetitionReq   int32 = 0
+	parquetPageTypeData    int32 = 0
+	parquetFormatVersion   int32 = 1
+	parquetCreatedByString       = "subtuber-services"
+)
+
+// parquetColumn 一列数据，值按 PLAIN 编码追加
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	values    bytes.Buffer
+	count     int
+}
+
+func newParquetStringColumn(name string) *parquetColumn {
+	return &parquetColumn{name: name, typ: parquetTypeByteArray, converted: parquetConvertedUTF8}
+}
+
+func newParquetInt64Column(name string) *parquetColumn {
+	return &parquetColumn{name: name, typ: parquetTypeInt64, converted: parquetConvertedNone}
+}
+
+func newParquetDoubleColumn(name string) *parquetColumn {
+	return &parquetColumn{name: name, typ: parquetTypeDouble, converted: parquetConvertedNone}
+}
+
+func newParquetTimestampColumn(name string) *parquetColumn {
+	return &parquetColumn{name: name, typ: parquetTypeInt64, converted: parquetConvertedTimestampMillis}
+}
+
+func (c *parquetColumn) appendString(s string) {
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(s)))
+	c.values.Write(n[:])
+	c.values.WriteString(s)
+	c.count++
+}
+
+func (c *parquetColumn) appendInt64(v int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(v))
+	c.values.Write(b[:])
+	c.count++
+}
+
+func (c *parquetColumn) appendDouble(v float64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	c.values.Write(b[:])
+	c.count++
+}
+
+// appendTime 按毫秒时间戳写入，零值写为 0（1970-01-01）
+func (c *parquetColumn) appendTime(t time.Time) {
+	if t.IsZero() {
+		c.appendInt64(0)
+		return
+	}
+	c.appendInt64(t.UnixMilli())
+}
+
+// writeParquet 将各列写为一个 Parquet 文件，各列的行数必须一致
+func writeParquet(w io.Writer, columns []*parquetColumn) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("没有要写入的列")
+	}
+	rows := columns[0].count
+	for _, col := range columns {
+		if col.count != rows {
+			return fmt.Errorf("列 %s 有 %d 行，与其他列的 %d 行不一致", col.name, col.count, rows)
+		}
+	}
+
+	out := &countingWriter{w: w}
+	if _, err := io.WriteString(out, parquetMagic); err != nil {
+		return err
+	}
+
+	chunks := make([]parquetChunkMeta, 0, len(columns))
+	var totalSize int64
+	for _, col := range columns {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(col.values.Bytes()); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+
+		var header thriftCompactWriter
+		header.fieldI32(1, parquetPageTypeData)
+		header.fieldI32(2, int32(col.values.Len()))
+		header.fieldI32(3, int32(compressed.Len()))
+		header.fieldStructBegin(5)
+		header.fieldI32(1, int32(col.count))
+		header.fieldI32(2, parquetEncodingPlain)
+		header.fieldI32(3, parquetEncodingRLE)
+		header.fieldI32(4, parquetEncodingRLE)
+		header.structEnd()
+		header.structEnd()
+
+		offset := out.n
+		if _, err := out.Write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err := out.Write(compressed.Bytes()); err != nil {
+			return err
+		}
+		chunks = append(chunks, parquetChunkMeta{
+			offset:           offset,
+			uncompressedSize: int64(header.buf.Len() + col.values.Len()),
+			compressedSize:   out.n - offset,
+		})
+		totalSize += int64(header.buf.Len() + col.values.Len())
+	}
+
+	footer := parquetFileMetaData(columns, chunks, rows, totalSize)
+	if _, err := out.Write(footer); err != nil {
+		return err
+	}
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(footer)))
+	if _, err := out.Write(n[:]); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, parquetMagic)
+	return err
+}
+
+// parquetChunkMeta 已写入的列块位置与大小
+type parquetChunkMeta struct {
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+// parquetFileMetaData 编码文件尾部的 FileMetaData
+func parquetFileMetaData(columns []*parquetColumn, chunks []parquetChunkMeta, rows int, totalSize int64) []byte {
+	var t thriftCompactWriter
+	t.fieldI32(1, parquetFormatVersion)
+
+	// schema：根节点 + 各列
+	t.fieldListBegin(2, thriftTypeStruct, len(columns)+1)
+	t.structBegin()
+	t.fieldString(4, "schema")
+	t.fieldI32(5, int32(len(columns)))
+	t.structEnd()
+	for _, col := range columns {
+		t.structBegin()
+		t.fieldI32(1, col.typ)
+		t.fieldI32(3, parquetRepetitionReq)
+		t.fieldString(4, col.name)
+		if col.converted != parquetConvertedNone {
+			t.fieldI32(6, col.converted)
+		}
+		t.structEnd()
+	}
+
+	t.fieldI64(3, int64(rows))
+
+	// 单个行组
+	t.fieldListBegin(4, thriftTypeStruct, 1)
+	t.structBegin()
+	t.fieldListBegin(1, thriftTypeStruct, len(columns))
+	for i, col := range columns {
+		chunk := chunks[i]
+		t.structBegin()
+		t.fieldI64(2, chunk.offset)
+		t.fieldStructBegin(3)
+		t.fieldI32(1, col.typ)
+		t.fieldListBegin(2, thriftTypeI32, 2)
+		t.writeVarint(zigzag32(parquetEncodingPlain))
+		t.writeVarint(zigzag32(parquetEncodingRLE))
+		t.fieldListBegin(3, thriftTypeBinary, 1)
+		t.writeBinary(col.name)
+		t.fieldI32(4, parquetCodecGzip)
+		t.fieldI64(5, int64(col.count))
+		t.fieldI64(6, chunk.uncompressedSize)
+		t.fieldI64(7, chunk.compressedSize)
+		t.fieldI64(9, chunk.offset)
+		t.structEnd()
+		t.structEnd()
+	}
+	t.fieldI64(2, totalSize)
+	t.fieldI64(3, int64(rows))
+	t.structEnd()
+
+	t.fieldString(6, parquetCreatedByString)
+	t.structEnd()
+	return t.buf.Bytes()
+}
+
+// countingWriter 记录已写入的字节数，用于计算列块偏移
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Thrift compact protocol 类型
+const (
+	thriftTypeI32    = 5
+	thriftTypeI64    = 6
+	thriftTypeBinary = 8
+	thriftTypeList   = 9
+	thriftTypeStruct = 12
+)
+
+// thriftCompactWriter Parquet 元数据使用的 Thrift compact protocol 编码，只实现用到的部分
+type thriftCompactWriter struct {
+	buf     bytes.Buffer
+	lastIDs []int16
+	lastID  int16
+}
+
+func zigzag32(v int32) uint64 { return uint64(uint32((v << 1) ^ (v >> 31))) }
+func zigzag64(v int64) uint64 { return uint64((v << 1) ^ (v >> 63)) }
+
+func (t *thriftCompactWriter) writeVarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	t.buf.Write(b[:n])
+}
+
+func (t *thriftCompactWriter) writeBinary(s string) {
+	t.writeVarint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftCompactWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.writeVarint(zigzag32(int32(id)))
+	}
+	t.lastID = id
+}
+
+func (t *thriftCompactWriter) fieldI32(id int16, v int32) {
+	t.fieldHeader(id, thriftTypeI32)
+	t.writeVarint(zigzag32(v))
+}
+
+func (t *thriftCompactWriter) fieldI64(id int16, v int64) {
+	t.fieldHeader(id, thriftTypeI64)
+	t.writeVarint(zigzag64(v))
+}
+
+func (t *thriftCompactWriter) fieldString(id int16, s string) {
+	t.fieldHeader(id, thriftTypeBinary)
+	t.writeBinary(s)
+}
+
+// fieldListBegin 写入列表字段头，随后由调用方写入 size 个元素
+func (t *thriftCompactWriter) fieldListBegin(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftTypeList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	t.buf.WriteByte(0xF0 | elemType)
+	t.writeVarint(uint64(size))
+}
+
+// fieldStructBegin 写入结构体字段头并进入该结构体
+func (t *thriftCompactWriter) fieldStructBegin(id int16) {
+	t.fieldHeader(id, thriftTypeStruct)
+	t.structBegin()
+}
+
+// structBegin 进入结构体（列表元素或嵌套字段），字段ID增量从 0 重新计算
+func (t *thriftCompactWriter) structBegin() {
+	t.lastIDs = append(t.lastIDs, t.lastID)
+	t.lastID = 0
+}
+
+// structEnd 写入结构体结束标记并回到外层
+func (t *thriftCompactWriter) structEnd() {
+	t.buf.WriteByte(0)
+	if n := len(t.lastIDs); n > 0 {
+		t.lastID = t.lastIDs[n-1]
+		t.lastIDs = t.lastIDs[:n-1]
+	}
+}
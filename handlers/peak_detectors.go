@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 内置的峰值检测算法
+const (
+	peakMethodConvolution = "convolution"
+	peakMethodZScore      = "zscore"
+	peakMethodMultiScale  = "multiscale"
+
+	defaultPeakMethod = peakMethodConvolution
+)
+
+// PeakDetector 峰值检测算法
+// 新算法实现该接口并通过 RegisterPeakDetector 注册后，即可在分析请求中通过 method 选择
+type PeakDetector interface {
+	// Name 算法名称，对应请求参数 method
+	Name() string
+	// Description 算法说明
+	Description() string
+	// ParamsSchema 算法使用的参数及其默认值
+	ParamsSchema() []PeakParamSpec
+	// Normalize 为算法参数填充默认值，并清空算法不使用的参数（保证相同配置的参数可比较）
+	Normalize(params PeakDetectionParams) PeakDetectionParams
+	// Detect 在会话的按秒评论序列上检测峰值
+	Detect(series *AnalysisSession, params PeakDetectionParams) PeakDetection
+}
+
+// PeakParamSpec 峰值检测参数说明
+type PeakParamSpec struct {
+	Name        string  `json:"name"`
+	Type        string  `json:"type"` // int / float
+	Default     float64 `json:"default"`
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max,omitempty"`
+	Description string  `json:"description"`
+}
+
+// PeakDetection 峰值检测结果，各序列与按秒评论序列等长
+type PeakDetection struct {
+	Score  []float64 // 时间序列中展示的得分（评论密度，multiscale 为标准化得分）
+	IsPeak []bool
+	Scale  []int // 多尺度算法中每个时间点的主导尺度（窗口长度，秒），单尺度算法为 nil
+}
+
+var (
+	peakDetectorsMu sync.RWMutex
+	peakDetectors   = map[string]PeakDetector{
+		peakMethodConvolution: convolutionDetector{},
+		peakMethodZScore:      zscoreDetector{},
+		peakMethodMultiScale:  multiScaleDetector{},
+	}
+)
+
+// RegisterPeakDetector 注册峰值检测算法，同名算法会被替换
+func RegisterPeakDetector(detector PeakDetector) {
+	peakDetectorsMu.Lock()
+	defer peakDetectorsMu.Unlock()
+	peakDetectors[strings.ToLower(detector.Name())] = detector
+}
+
+// getPeakDetector 按名称查找峰值检测算法，名称为空时返回默认算法
+func getPeakDetector(method string) (PeakDetector, bool) {
+	method = strings.ToLower(strings.TrimSpace(method))
+	if method == "" {
+		method = defaultPeakMethod
+	}
+
+	peakDetectorsMu.RLock()
+	defer peakDetectorsMu.RUnlock()
+	detector, ok := peakDetectors[method]
+	return detector, ok
+}
+
+// listPeakDetectors 返回已注册的峰值检测算法，按名称排序
+func listPeakDetectors() []PeakDetector {
+	peakDetectorsMu.RLock()
+	defer peakDetectorsMu.RUnlock()
+
+	detectors := make([]PeakDetector, 0, len(peakDetectors))
+	for _, detector := range peakDetectors {
+		detectors = append(detectors, detector)
+	}
+	sort.Slice(detectors, func(i, j int) bool { return detectors[i].Name() < detectors[j].Name() })
+	return detectors
+}
+
+// validatePeakMethod 检查请求的峰值检测算法是否已注册
+func validatePeakMethod(method string) error {
+	if _, ok := getPeakDetector(method); !ok {
+		return fmt.Errorf("不支持的峰值检测算法: %s", method)
+	}
+	return nil
+}
+
+// peakParamsFromQuery 从查询参数读取峰值检测参数（未指定时使用默认参数）并填充算法默认值
+func peakParamsFromQuery(c *gin.Context) (PeakDetectionParams, error) {
+	var params PeakDetectionParams
+	params.WindowsLen, _ = strconv.Atoi(c.DefaultQuery("windows_len", strconv.Itoa(defaultPeakParams.WindowsLen)))
+	params.Thr, _ = strconv.ParseFloat(c.DefaultQuery("thr", fmt.Sprintf("%.2f", defaultPeakParams.Thr)), 64)
+	params.SearchRange, _ = strconv.Atoi(c.DefaultQuery("search_range", strconv.Itoa(defaultPeakParams.SearchRange)))
+	params.Method = c.Query("method")
+	params.ZThreshold, _ = strconv.ParseFloat(c.Query("z_threshold"), 64)
+	params.BaselineLen, _ = strconv.Atoi(c.Query("baseline_len"))
+	params.MinWindowsLen, _ = strconv.Atoi(c.Query("min_windows_len"))
+	params.Scales, _ = strconv.Atoi(c.Query("scales"))
+	if err := validatePeakMethod(params.Method); err != nil {
+		return params, err
+	}
+	return normalizePeakParams(params), nil
+}
+
+// peakMethodName 参数对应的算法名称，未指定时为默认算法
+func peakMethodName(params PeakDetectionParams) string {
+	if params.Method == "" {
+		return defaultPeakMethod
+	}
+	return strings.ToLower(params.Method)
+}
+
+// analysisResultFileName 分析结果文件名
+// 默认算法沿用 analysis_{windowsLen}_{thr}_{searchRange}.json，其他算法在前面加上算法名
+func analysisResultFileName(params PeakDetectionParams) string {
+	return fmt.Sprintf("analysis_%s.json", peakParamsKey(params))
+}
+
+// peakParamsKey 分析结果与附属文件名中标识参数的部分
+func peakParamsKey(params PeakDetectionParams) string {
+	key := fmt.Sprintf("%d_%.2f_%d", params.WindowsLen, params.Thr, params.SearchRange)
+	switch method := strings.ToLower(params.Method); method {
+	case "", defaultPeakMethod:
+		return key
+	case peakMethodZScore:
+		return fmt.Sprintf("%s_%d_%.2f_%d_%d", method, params.WindowsLen, params.ZThreshold, params.BaselineLen, params.SearchRange)
+	case peakMethodMultiScale:
+		return fmt.Sprintf("%s_%d-%d_%d_%.2f_%d", method, params.MinWindowsLen, params.WindowsLen, params.Scales, params.ZThreshold, params.SearchRange)
+	default:
+		return method + "_" + key
+	}
+}
+
+// GetPeakDetectors 列出可用的峰值检测算法及其参数
+func GetPeakDetectors(c *gin.Context) {
+	type detectorInfo struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Default     bool            `json:"default"`
+		Params      []PeakParamSpec `json:"params"`
+	}
+
+	detectors := listPeakDetectors()
+	items := make([]detectorInfo, 0, len(detectors))
+	for _, detector := range detectors {
+		items = append(items, detectorInfo{
+			Name:        detector.Name(),
+			Description: detector.Description(),
+			Default:     detector.Name() == defaultPeakMethod,
+			Params:      detector.ParamsSchema(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"detectors": items})
+}
+
+// commonPeakParamsSchema 评论密度窗口与合并范围，各内置算法共用
+var commonPeakParamsSchema = []PeakParamSpec{
+	{Name: "windows_len", Type: "int", Default: 120, Min: 1, Description: "滑动窗口长度（秒），用于计算评论密度"},
+	{Name: "search_range", Type: "int", Default: 60, Min: 1, Description: "搜索范围（秒），在此范围内查找局部最大值并合并热点"},
+}
+
+// convolutionDetector 评论密度超过全局百分位阈值且为搜索范围内局部最大值的点为峰值
+type convolutionDetector struct{}
+
+func (convolutionDetector) Name() string { return peakMethodConvolution }
+
+func (convolutionDetector) Description() string {
+	return "滑动窗口评论密度超过全局百分位阈值，且为搜索范围内的局部最大值"
+}
+
+func (convolutionDetector) ParamsSchema() []PeakParamSpec {
+	return append(append([]PeakParamSpec(nil), commonPeakParamsSchema...),
+		PeakParamSpec{Name: "thr", Type: "float", Default: 0.9, Min: 0, Max: 1, Description: "阈值百分位（0-1），只考虑超过该百分位的密度值"})
+}
+
+func (convolutionDetector) Normalize(params PeakDetectionParams) PeakDetectionParams {
+	params.Method = peakMethodConvolution
+	params.ZThreshold = 0
+	params.BaselineLen = 0
+	params.MinWindowsLen = 0
+	params.Scales = 0
+	return params
+}
+
+func (convolutionDetector) Detect(series *AnalysisSession, params PeakDetectionParams) PeakDetection {
+	d := series.densityFor(params.WindowsLen)
+	thrDensity := percentileFromSorted(d.sorted, params.Thr)
+	windowMax := series.maximaFor(params.WindowsLen, params.SearchRange, d.density)
+	return PeakDetection{Score: d.density, IsPeak: markPeaks(d.density, windowMax, thrDensity)}
+}
+
+// zscoreDetector 评论密度相对周围一段时间的均值高出若干个标准差，且为搜索范围内局部最大值的点为峰值
+// 与全局百分位相比，能在整体热度较低的时段中找到相对突出的时刻
+type zscoreDetector struct{}
+
+const (
+	defaultZThreshold  = 3.0
+	defaultBaselineLen = 1800
+)
+
+func (zscoreDetector) Name() string { return peakMethodZScore }
+
+func (zscoreDetector) Description() string {
+	return "滑动窗口评论密度相对滚动均值高出 z_threshold 个标准差，且为搜索范围内的局部最大值"
+}
+
+func (zscoreDetector) ParamsSchema() []PeakParamSpec {
+	return append(append([]PeakParamSpec(nil), commonPeakParamsSchema...),
+		PeakParamSpec{Name: "z_threshold", Type: "float", Default: defaultZThreshold, Min: 0, Description: "高于滚动均值的标准差倍数"},
+		PeakParamSpec{Name: "baseline_len", Type: "int", Default: defaultBaselineLen, Min: 1, Description: "计算滚动均值与标准差的窗口长度（秒），以当前时刻为中心"})
+}
+
+func (zscoreDetector) Normalize(params PeakDetectionParams) PeakDetectionParams {
+	params.Method = peakMethodZScore
+	if params.ZThreshold <= 0 {
+		params.ZThreshold = defaultZThreshold
+	}
+	if params.BaselineLen <= 0 {
+		params.BaselineLen = defaultBaselineLen
+	}
+	params.Thr = 0
+	params.MinWindowsLen = 0
+	params.Scales = 0
+	return params
+}
+
+func (zscoreDetector) Detect(series *AnalysisSession, params PeakDetectionParams) PeakDetection {
+	d := series.densityFor(params.WindowsLen)
+	density := d.density
+	windowMax := series.maximaFor(params.WindowsLen, params.SearchRange, density)
+
+	n := len(density)
+	prefix := make([]float64, n+1)
+	prefixSq := make([]float64, n+1)
+	for i, v := range density {
+		prefix[i+1] = prefix[i] + v
+		prefixSq[i+1] = prefixSq[i] + v*v
+	}
+
+	half := params.BaselineLen / 2
+	isPeak := make([]bool, n)
+	for i, v := range density {
+		if v <= 0 || v != windowMax[i] {
+			continue
+		}
+		lo, hi := i-half, i+half+1
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > n {
+			hi = n
+		}
+		count := float64(hi - lo)
+		mean := (prefix[hi] - prefix[lo]) / count
+		variance := (prefixSq[hi]-prefixSq[lo])/count - mean*mean
+		if variance <= 0 {
+			continue
+		}
+		if (v-mean)/math.Sqrt(variance) >= params.ZThreshold {
+			isPeak[i] = true
+		}
+	}
+
+	return PeakDetection{Score: density, IsPeak: isPeak}
+}
+
+// detectPeaks 使用参数指定的算法检测峰值，算法未注册时回退到默认算法
+func (s *AnalysisSession) detectPeaks(params PeakDetectionParams) PeakDetection {
+	detector, ok := getPeakDetector(params.Method)
+	if !ok {
+		log.Printf("未注册的峰值检测算法 %q，使用默认算法 %s", params.Method, defaultPeakMethod)
+		detector, _ = getPeakDetector(defaultPeakMethod)
+	}
+	return detector.Detect(s, params)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"math"
+)
+
+const (
+	defaultMinWindowsLen = 15
+	defaultScales        = 4
+	maxScales            = 12
+)
+
+// multiScaleDetector 多尺度峰值检测
+// 在从 min_windows_len 到 windows_len 按等比排列的多个窗口长度上分别计算评论密度，
+// 每个尺度的密度按自身的均值与标准差标准化后取最大值作为得分，
+// 短窗口响应瞬间的刷屏，长窗口响应持续的高热度，得分最大的尺度即为该时刻的主导尺度
+type multiScaleDetector struct{}
+
+func (multiScaleDetector) Name() string { return peakMethodMultiScale }
+
+func (multiScaleDetector) Description() string {
+	return "在多个窗口长度上计算标准化的评论密度，取各尺度中的最大值检测峰值，并给出每个峰值的主导尺度"
+}
+
+func (multiScaleDetector) ParamsSchema() []PeakParamSpec {
+	return append(append([]PeakParamSpec(nil), commonPeakParamsSchema...),
+		PeakParamSpec{Name: "min_windows_len", Type: "int", Default: defaultMinWindowsLen, Min: 1, Description: "最小窗口长度（秒），最大窗口长度为 windows_len"},
+		PeakParamSpec{Name: "scales", Type: "int", Default: defaultScales, Min: 1, Max: maxScales, Description: "尺度数量，窗口长度在最小与最大之间按等比排列"},
+		PeakParamSpec{Name: "z_threshold", Type: "float", Default: defaultZThreshold, Min: 0, Description: "标准化得分的阈值（标准差倍数）"})
+}
+
+func (multiScaleDetector) Normalize(params PeakDetectionParams) PeakDetectionParams {
+	params.Method = peakMethodMultiScale
+	if params.MinWindowsLen <= 0 {
+		params.MinWindowsLen = defaultMinWindowsLen
+	}
+	if params.MinWindowsLen > params.WindowsLen {
+		params.MinWindowsLen = params.WindowsLen
+	}
+	if params.Scales <= 0 {
+		params.Scales = defaultScales
+	}
+	if params.Scales > maxScales {
+		params.Scales = maxScales
+	}
+	if params.ZThreshold <= 0 {
+		params.ZThreshold = defaultZThreshold
+	}
+	params.Thr = 0
+	params.BaselineLen = 0
+	return params
+}
+
+func (multiScaleDetector) Detect(series *AnalysisSession, params PeakDetectionParams) PeakDetection {
+	n := len(series.counts)
+	score := make([]float64, n)
+	scale := make([]int, n)
+	for i := range score {
+		score[i] = math.Inf(-1)
+	}
+
+	for _, windowsLen := range multiScaleWindows(params.MinWindowsLen, params.WindowsLen, params.Scales) {
+		density := series.densityFor(windowsLen).density
+
+		var sum, sumSq float64
+		for _, v := range density {
+			sum += v
+			sumSq += v * v
+		}
+		mean := sum / float64(n)
+		variance := sumSq/float64(n) - mean*mean
+		if variance <= 0 {
+			continue
+		}
+		sigma := math.Sqrt(variance)
+
+		for i, v := range density {
+			if z := (v - mean) / sigma; z > score[i] {
+				score[i] = z
+				scale[i] = windowsLen
+			}
+		}
+	}
+
+	isPeak := make([]bool, n)
+	if n == 0 || math.IsInf(score[0], -1) {
+		// 评论数没有变化，不存在峰值
+		return PeakDetection{Score: make([]float64, n), IsPeak: isPeak, Scale: scale}
+	}
+
+	windowMax := slidingMax(score, params.SearchRange)
+	for i, z := range score {
+		if z >= params.ZThreshold && z == windowMax[i] {
+			isPeak[i] = true
+		}
+	}
+
+	return PeakDetection{Score: score, IsPeak: isPeak, Scale: scale}
+}
+
+// multiScaleWindows 返回在 [minLen, maxLen] 之间按等比排列的窗口长度（去重）
+func multiScaleWindows(minLen, maxLen, scales int) []int {
+	if scales <= 1 || minLen >= maxLen {
+		return []int{maxLen}
+	}
+
+	ratio := math.Pow(float64(maxLen)/float64(minLen), 1/float64(scales-1))
+	windows := make([]int, 0, scales)
+	for k := 0; k < scales; k++ {
+		w := int(math.Round(float64(minLen) * math.Pow(ratio, float64(k))))
+		if k == scales-1 {
+			w = maxLen
+		}
+		if len(windows) == 0 || w > windows[len(windows)-1] {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
@@ -0,0 +1,545 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"subtuber-services/logging"
+	"subtuber-services/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const persistedJobsFile = "App_Data/jobs.json"
+
+var jobsLog = logging.For("jobs")
+
+// 持久化的任务阶段，服务重启后未完成的任务会重新执行
+const (
+	persistedStageStreamEnded = "stream_ended" // 下播后获取录像列表并处理新录像
+	persistedStageHotClips    = "hot_clips"    // 热点片段下载（包含语音识别与AI总结）
+	persistedStageYouTubeVOD  = "youtube_vod"  // YouTube 录像的聊天下载、分析与片段处理
+	persistedStageBackfill    = "backfill"     // 新接入主播的历史录像回填
+)
+
+// 持久化任务的状态，完成的任务保留 persistedJobHistoryRetention 供 /api/jobs 查询
+const (
+	persistedJobPending = "pending"
+	persistedJobRunning = "running"
+	persistedJobFailed  = "failed"
+	persistedJobDone    = "done"
+)
+
+// persistedJobHistoryRetention 已完成任务的保留时长，失败的任务一直保留到重试或被新任务替换
+const persistedJobHistoryRetention = 7 * 24 * time.Hour
+
+// maxPersistedJobAttempts 任务被重启中断的最多次数，超过后标记为失败，避免反复崩溃的任务无限重试
+const maxPersistedJobAttempts = 3
+
+// PersistedJob 持久化的后台任务，按（阶段, 录像ID）去重，与录像无关的任务按（阶段, 平台, 主播）去重
+type PersistedJob struct {
+	ID           string           `json:"id"`
+	Stage        string           `json:"stage"`
+	Platform     string           `json:"platform,omitempty"`
+	VideoID      string           `json:"video_id,omitempty"`
+	StreamerID   string           `json:"streamer_id,omitempty"`
+	Streamer     string           `json:"streamer,omitempty"` // 平台上的用户名或频道ID
+	StreamerName string           `json:"streamer_name,omitempty"`
+	HotMoments   []VodCommentData `json:"hot_moments,omitempty"`
+	Interval     float64          `json:"interval,omitempty"`
+	Video        json.RawMessage  `json:"video,omitempty"` // YouTube 录像信息
+	State        string           `json:"state"`
+	Attempts     int              `json:"attempts"` // 被重启中断的次数
+	Error        string           `json:"error,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+// finished 任务是否已结束（完成或失败），结束的任务可以被相同的新任务替换
+func (j PersistedJob) finished() bool {
+	return j.State == persistedJobDone || j.State == persistedJobFailed
+}
+
+// key 任务的去重键
+func (j PersistedJob) key() string {
+	if j.VideoID != "" {
+		return j.Stage + "/" + j.VideoID
+	}
+	return j.Stage + "/" + j.Platform + "/" + j.Streamer
+}
+
+var persistedJobsMu sync.Mutex
+
+// loadPersistedJobs 读取持久化的任务，文件不存在时返回空
+func loadPersistedJobs() ([]PersistedJob, error) {
+	data, err := os.ReadFile(persistedJobsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []PersistedJob{}, nil
+		}
+		return nil, err
+	}
+	var jobs []PersistedJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// savePersistedJobs 将任务写回文件（先写临时文件再重命名，避免写入中途退出损坏文件）
+// 超过保留时长的已完成任务在写回时移除
+func savePersistedJobs(jobs []PersistedJob) error {
+	kept := jobs[:0:0]
+	for _, job := range jobs {
+		if job.State == persistedJobDone && time.Since(job.UpdatedAt) > persistedJobHistoryRetention {
+			continue
+		}
+		kept = append(kept, job)
+	}
+	jobs = kept
+
+	if err := os.MkdirAll(filepath.Dir(persistedJobsFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := persistedJobsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, persistedJobsFile)
+}
+
+// enqueuePersistedJob 记录一个等待执行的任务，已有相同的未完成任务时不重复记录
+func enqueuePersistedJob(job PersistedJob) {
+	persistedJobsMu.Lock()
+	defer persistedJobsMu.Unlock()
+
+	jobs, err := loadPersistedJobs()
+	if err != nil {
+		jobsLog.Error("读取任务队列失败", "error", err)
+		return
+	}
+	for i := range jobs {
+		if jobs[i].key() != job.key() {
+			continue
+		}
+		if !jobs[i].finished() {
+			return
+		}
+		jobs = append(jobs[:i], jobs[i+1:]...)
+		break
+	}
+
+	now := time.Now()
+	job.ID = fmt.Sprintf("%s_%d", job.Stage, now.UnixNano())
+	job.State = persistedJobPending
+	job.CreatedAt, job.UpdatedAt = now, now
+	if err := savePersistedJobs(append(jobs, job)); err != nil {
+		jobsLog.Error("保存任务队列失败", "error", err)
+	}
+}
+
+// persistedJobInProgress 相同的任务是否正在执行
+func persistedJobInProgress(job PersistedJob) bool {
+	persistedJobsMu.Lock()
+	defer persistedJobsMu.Unlock()
+
+	jobs, err := loadPersistedJobs()
+	if err != nil {
+		jobsLog.Error("读取任务队列失败", "error", err)
+		return false
+	}
+	for _, j := range jobs {
+		if j.key() == job.key() && j.State == persistedJobRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// claimPersistedJob 开始执行任务并持久化其状态
+// 相同的任务正在执行时返回 false，调用方应跳过；已有等待中的相同任务时接管该任务（保留中断次数）
+// 持久化失败不影响任务执行，只是重启后无法恢复
+func claimPersistedJob(job PersistedJob) (PersistedJob, bool) {
+	persistedJobsMu.Lock()
+	defer persistedJobsMu.Unlock()
+
+	jobs, err := loadPersistedJobs()
+	if err != nil {
+		jobsLog.Error("读取任务队列失败", "error", err)
+		return job, true
+	}
+
+	now := time.Now()
+	found := false
+	for i := range jobs {
+		if jobs[i].key() != job.key() {
+			continue
+		}
+		switch jobs[i].State {
+		case persistedJobRunning:
+			return jobs[i], false
+		case persistedJobPending:
+			job.ID, job.Attempts, job.CreatedAt = jobs[i].ID, jobs[i].Attempts, jobs[i].CreatedAt
+		}
+		job.State, job.Error, job.UpdatedAt = persistedJobRunning, "", now
+		if job.ID == "" {
+			job.ID, job.CreatedAt = fmt.Sprintf("%s_%d", job.Stage, now.UnixNano()), now
+		}
+		jobs[i] = job
+		found = true
+		break
+	}
+	if !found {
+		job.ID = fmt.Sprintf("%s_%d", job.Stage, now.UnixNano())
+		job.State = persistedJobRunning
+		job.CreatedAt, job.UpdatedAt = now, now
+		jobs = append(jobs, job)
+	}
+
+	if err := savePersistedJobs(jobs); err != nil {
+		jobsLog.Error("保存任务队列失败", "error", err)
+	}
+	return job, true
+}
+
+// completePersistedJob 任务结束：记录完成或失败，失败时保留错误信息供查看与重试
+func completePersistedJob(id string, jobErr error) {
+	persistedJobsMu.Lock()
+	defer persistedJobsMu.Unlock()
+
+	jobs, err := loadPersistedJobs()
+	if err != nil {
+		jobsLog.Error("读取任务队列失败", "error", err)
+		return
+	}
+	for i := range jobs {
+		if jobs[i].ID != id {
+			continue
+		}
+		jobs[i].State, jobs[i].Error = persistedJobDone, ""
+		if jobErr != nil {
+			jobs[i].State, jobs[i].Error = persistedJobFailed, jobErr.Error()
+		}
+		jobs[i].UpdatedAt = time.Now()
+		if err := savePersistedJobs(jobs); err != nil {
+			jobsLog.Error("保存任务队列失败", "error", err)
+		}
+		return
+	}
+}
+
+// removePersistedJob 移除未开始的任务（如转入静默时段的延后队列）
+func removePersistedJob(job PersistedJob) {
+	persistedJobsMu.Lock()
+	defer persistedJobsMu.Unlock()
+
+	jobs, err := loadPersistedJobs()
+	if err != nil {
+		return
+	}
+	for i := range jobs {
+		if jobs[i].key() == job.key() && jobs[i].State == persistedJobPending {
+			jobs = append(jobs[:i], jobs[i+1:]...)
+			_ = savePersistedJobs(jobs)
+			return
+		}
+	}
+}
+
+// ResumePersistedJobs 服务启动时恢复上次未完成的任务，需在监控服务初始化之后调用
+// 执行中被中断的任务计一次中断，超过上限的标记为失败；其余任务重新加入处理队列
+func ResumePersistedJobs() {
+	persistedJobsMu.Lock()
+	jobs, err := loadPersistedJobs()
+	if err != nil {
+		persistedJobsMu.Unlock()
+		jobsLog.Error("读取任务队列失败，无法恢复未完成的任务", "error", err)
+		return
+	}
+
+	var resume []PersistedJob
+	for i := range jobs {
+		job := &jobs[i]
+		if job.finished() {
+			continue
+		}
+		if job.State == persistedJobRunning {
+			job.Attempts++
+		}
+		job.UpdatedAt = time.Now()
+		if job.Attempts > maxPersistedJobAttempts {
+			job.State = persistedJobFailed
+			job.Error = fmt.Sprintf("任务被中断 %d 次，不再自动恢复", job.Attempts)
+			jobsLog.Warn(job.Error, "job_id", job.ID, "job", job.key())
+			continue
+		}
+		job.State = persistedJobPending
+		resume = append(resume, *job)
+	}
+	if err := savePersistedJobs(jobs); err != nil {
+		jobsLog.Error("保存任务队列失败", "error", err)
+	}
+	persistedJobsMu.Unlock()
+
+	if len(resume) == 0 {
+		return
+	}
+	jobsLog.Info("恢复未完成的任务", "count", len(resume))
+	go func() {
+		for _, job := range resume {
+			submitProcessingJob(job, func() { resumePersistedJob(job) })
+		}
+	}()
+}
+
+// resumePersistedJob 按阶段重新执行任务
+func resumePersistedJob(job PersistedJob) {
+	jobsLog.Info("恢复任务", "job_id", job.ID, "job", job.key())
+
+	switch job.Stage {
+	case persistedStageHotClips:
+		scheduleHotMomentClips(job.VideoID, remainingHotMoments(job.VideoID, job.HotMoments), job.Interval)
+	case persistedStageStreamEnded:
+		switch job.Platform {
+		case "twitch":
+			if tm := GetTwitchMonitor(); tm != nil {
+				tm.runStreamEndedJob(job)
+				return
+			}
+		case "youtube":
+			if ym := GetYouTubeMonitor(); ym != nil {
+				ym.runStreamEndedJob(job)
+				return
+			}
+		}
+		jobsLog.Warn("监控服务未启动，任务留待下次启动时恢复", "platform", job.Platform, "job_id", job.ID)
+	case persistedStageYouTubeVOD:
+		if ym := GetYouTubeMonitor(); ym != nil {
+			ym.runYouTubeVODJob(job)
+			return
+		}
+		jobsLog.Warn("监控服务未启动，任务留待下次启动时恢复", "platform", "youtube", "job_id", job.ID)
+	case persistedStageBackfill:
+		if (job.Platform == "twitch" && GetTwitchMonitor() != nil) || (job.Platform == "youtube" && GetYouTubeMonitor() != nil) {
+			runBackfillJob(job)
+			return
+		}
+		jobsLog.Warn("监控服务未启动，任务留待下次启动时恢复", "platform", job.Platform, "job_id", job.ID)
+	default:
+		jobsLog.Warn("未知的任务阶段，已丢弃", "stage", job.Stage, "job_id", job.ID)
+		completePersistedJob(job.ID, fmt.Errorf("未知的任务阶段: %s", job.Stage))
+	}
+}
+
+// findPersistedJob 按任务ID查找持久化的任务
+func findPersistedJob(id string) (PersistedJob, bool, error) {
+	persistedJobsMu.Lock()
+	defer persistedJobsMu.Unlock()
+
+	jobs, err := loadPersistedJobs()
+	if err != nil {
+		return PersistedJob{}, false, err
+	}
+	for _, job := range jobs {
+		if job.ID == id {
+			return job, true, nil
+		}
+	}
+	return PersistedJob{}, false, nil
+}
+
+// errJobNotRetryable 任务未失败，不能重试
+var errJobNotRetryable = errors.New("只有失败的任务可以重试")
+
+// retryPersistedJob 将失败的任务重新加入处理队列，保留任务ID并清零中断次数
+func retryPersistedJob(id string) (PersistedJob, error) {
+	persistedJobsMu.Lock()
+	jobs, err := loadPersistedJobs()
+	if err != nil {
+		persistedJobsMu.Unlock()
+		return PersistedJob{}, err
+	}
+	index := -1
+	for i := range jobs {
+		if jobs[i].ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		persistedJobsMu.Unlock()
+		return PersistedJob{}, os.ErrNotExist
+	}
+	if jobs[index].State != persistedJobFailed {
+		persistedJobsMu.Unlock()
+		return jobs[index], errJobNotRetryable
+	}
+	jobs[index].State, jobs[index].Error, jobs[index].Attempts = persistedJobPending, "", 0
+	jobs[index].UpdatedAt = time.Now()
+	job := jobs[index]
+	err = savePersistedJobs(jobs)
+	persistedJobsMu.Unlock()
+	if err != nil {
+		return PersistedJob{}, err
+	}
+
+	jobsLog.Info("重试任务", "job_id", job.ID, "job", job.key())
+	// 队列中已有等待中的相同任务，submitProcessingJob 不会重复记录
+	go submitProcessingJob(job, func() { resumePersistedJob(job) })
+	return job, nil
+}
+
+// remainingHotMoments 过滤掉已成功处理的热点，恢复任务时不重复下载
+func remainingHotMoments(videoID string, hotMoments []VodCommentData) []VodCommentData {
+	runs, err := loadClipRuns(videoID)
+	if err != nil || len(runs) == 0 {
+		return hotMoments
+	}
+	remaining := make([]VodCommentData, 0, len(hotMoments))
+	for _, moment := range hotMoments {
+		done := false
+		for _, run := range runs {
+			if math.Abs(run.HotMomentOffset-moment.OffsetSeconds) < 1 && run.ClipStatus == clipRunOK && !run.failed() && !run.waitingForDependency() {
+				done = true
+				break
+			}
+		}
+		if !done {
+			remaining = append(remaining, moment)
+		}
+	}
+	return remaining
+}
+
+// runHotClipsJob 以持久化任务的方式下载热点片段，同一录像的片段任务不会同时执行
+func runHotClipsJob(videoID string, hotMoments []VodCommentData, interval float64) {
+	job, ok := claimPersistedJob(PersistedJob{
+		Stage:      persistedStageHotClips,
+		VideoID:    videoID,
+		HotMoments: hotMoments,
+		Interval:   interval,
+	})
+	if !ok {
+		jobsLog.Info("热点片段任务正在执行，跳过重复任务", "video_id", videoID)
+		return
+	}
+	completePersistedJob(job.ID, runHotMomentClips(videoID, hotMoments, interval))
+}
+
+// submitHotClipsJob 将用户发起的热点片段任务加入处理队列，与其他后台任务共享并发名额
+func submitHotClipsJob(videoID string, hotMoments []VodCommentData, interval float64) {
+	submitProcessingJob(PersistedJob{
+		Stage:      persistedStageHotClips,
+		VideoID:    videoID,
+		HotMoments: hotMoments,
+		Interval:   interval,
+	}, func() { scheduleHotMomentClips(videoID, hotMoments, interval) })
+}
+
+// runStreamEndedJob 主播下播后获取并处理新录像，完成后通知订阅者
+func (tm *TwitchMonitor) runStreamEndedJob(job PersistedJob) {
+	job, ok := claimPersistedJob(job)
+	if !ok {
+		jobsLog.Info("下播处理任务正在执行，跳过重复任务", "streamer", job.Streamer)
+		return
+	}
+
+	newResults, err := tm.GetVideoCommentsForStreamer(job.Streamer)
+	if err != nil {
+		jobsLog.Error("下播处理任务失败", "streamer", job.Streamer, "job_id", job.ID, "error", err)
+	}
+	if len(newResults) > 0 {
+		jobsLog.Info("完成新视频的分析", "streamer", job.Streamer, "videos", len(newResults))
+		for _, result := range newResults {
+			jobsLog.Info("视频分析结果", "video_id", result.VideoID, "hot_moments", len(result.HotMoments))
+			go notifyAnalysisReady(job.StreamerID, job.StreamerName, result.VideoID, result.VideoInfo.Title, len(result.HotMoments))
+		}
+	}
+	// 有录像下载或分析失败时任务标记为失败，可以通过 RetryJob 重试
+	completePersistedJob(job.ID, err)
+}
+
+// runStreamEndedJob 频道下播后处理最近的直播录像
+func (ym *YouTubeMonitor) runStreamEndedJob(job PersistedJob) {
+	job, ok := claimPersistedJob(job)
+	if !ok {
+		jobsLog.Info("下播处理任务正在执行，跳过重复任务", "streamer", job.StreamerName)
+		return
+	}
+	jobsLog.Info("开始处理最近的VOD", "streamer", job.StreamerName)
+	ym.ProcessRecentVOD(job.Streamer, job.StreamerName)
+	completePersistedJob(job.ID, nil)
+}
+
+// runYouTubeVODJob 下载并处理单个 YouTube 录像
+func (ym *YouTubeMonitor) runYouTubeVODJob(job PersistedJob) {
+	var video models.YouTubeVideoItem
+	if err := json.Unmarshal(job.Video, &video); err != nil {
+		jobsLog.Error("任务的录像信息无效", "job_id", job.ID, "error", err)
+		completePersistedJob(job.ID, fmt.Errorf("录像信息无效: %w", err))
+		return
+	}
+
+	job, ok := claimPersistedJob(job)
+	if !ok {
+		jobsLog.Info("视频正在处理，跳过重复任务", "video_id", video.ID)
+		return
+	}
+
+	_, span := startPipelineSpan(context.Background(), "youtube_vod", video.ID,
+		attribute.String("streamer", job.StreamerName))
+	err := ym.downloadYouTubeLiveChat(&video, job.StreamerName)
+	endSpan(span, err)
+	if err != nil {
+		jobsLog.Error("下载YouTube聊天记录失败", "video_id", video.ID, "error", err)
+		completePersistedJob(job.ID, err)
+		return
+	}
+
+	jobsLog.Info("成功处理VOD", "streamer", job.StreamerName, "video_id", video.ID, "title", video.Snippet.Title)
+	completePersistedJob(job.ID, nil)
+}
+
+// listPersistedJobsHandler 管理员查看未完成与失败的持久化任务
+func listPersistedJobsHandler(c *gin.Context) {
+	persistedJobsMu.Lock()
+	jobs, err := loadPersistedJobs()
+	persistedJobsMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取任务队列失败: " + err.Error()})
+		return
+	}
+
+	// 不返回热点列表与录像信息，避免响应过大
+	for i := range jobs {
+		jobs[i].HotMoments = nil
+		jobs[i].Video = nil
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+
+	policy, running, queued := jobQueue.snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"jobs":    jobs,
+		"queue": gin.H{
+			"policy":  policy,
+			"running": running,
+			"pending": queued,
+		},
+		"stages": stageConcurrencyStats(),
+	})
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"subtuber-services/pathsafe"
+)
+
+const pipelinePlanFileName = "pipeline.json"
+
+// 可通过规则跳过的流水线步骤
+const (
+	PipelineStepAnalysis         = "analysis"
+	PipelineStepClips            = "clips"
+	PipelineStepASR              = "asr"
+	PipelineStepAISummary        = "ai_summary"
+	PipelineStepDriftCalibration = "drift_calibration"
+	PipelineStepPreviews         = "previews"
+	PipelineStepChatSummary      = "chat_summary"
+)
+
+var pipelineSteps = []string{
+	PipelineStepAnalysis, PipelineStepClips, PipelineStepASR, PipelineStepAISummary, PipelineStepDriftCalibration,
+	PipelineStepPreviews, PipelineStepChatSummary,
+}
+
+// 默认的片段下载参数
+const (
+	defaultClipQuality  = "720p"
+	defaultClipInterval = 420
+)
+
+// PipelineFacts 规则条件可引用的录像信息
+type PipelineFacts struct {
+	Streamer string  `json:"streamer"`
+	Platform string  `json:"platform"`
+	Language string  `json:"language,omitempty"`
+	Title    string  `json:"title,omitempty"`
+	Duration float64 `json:"duration"` // 录像时长（秒）
+	Comments int     `json:"comments"`
+}
+
+// PipelinePlan 规则求值后单个录像的处理方案
+type PipelinePlan struct {
+	Skipped         []string      `json:"skipped,omitempty"`
+	Quality         string        `json:"quality"`
+	ClipInterval    float64       `json:"clip_interval"`
+	ClipPrePadding  float64       `json:"clip_pre_padding,omitempty"`  // 片段开头额外保留的时长（秒）
+	ClipPostPadding float64       `json:"clip_post_padding,omitempty"` // 片段结尾额外保留的时长（秒）
+	MatchedRules    []int         `json:"matched_rules,omitempty"`     // 命中的规则序号（从 0 开始）
+	SummaryLanguage string        `json:"summary_language,omitempty"`  // AI总结语言，来自订阅者偏好
+	Facts           PipelineFacts `json:"facts"`
+	GateReason      string        `json:"gate_reason,omitempty"` // 未达到处理门槛的原因，此时跳过分析与片段
+
+	qualitySet bool // 画质由规则指定，不再使用订阅者偏好
+}
+
+// Runs 步骤是否需要执行
+func (p PipelinePlan) Runs(step string) bool {
+	for _, s := range p.Skipped {
+		if s == step {
+			return false
+		}
+	}
+	return true
+}
+
+// AudioOnly 片段是否只下载音频
+func (p PipelinePlan) AudioOnly() bool {
+	return p.Quality == "audio_only"
+}
+
+// defaultPipelinePlan 未配置规则时的处理方案：执行全部步骤
+func defaultPipelinePlan(facts PipelineFacts) PipelinePlan {
+	return PipelinePlan{Quality: defaultClipQuality, ClipInterval: defaultClipInterval, Facts: facts}
+}
+
+// pipelineCondition 单个比较条件，形如 duration > 6h
+type pipelineCondition struct {
+	field string
+	op    string
+	value string
+}
+
+var pipelineConditionRe = regexp.MustCompile(`^\s*([a-z_]+)\s*(==|!=|>=|<=|>|<|\bcontains\b)\s*(.+?)\s*$`)
+
+// parsePipelineCondition 解析条件表达式：以 || 分隔的若干组、每组以 && 分隔的比较条件，为空时总是成立
+func parsePipelineCondition(expr string) ([][]pipelineCondition, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+
+	var groups [][]pipelineCondition
+	for _, part := range strings.Split(expr, "||") {
+		var group []pipelineCondition
+		for _, clause := range strings.Split(part, "&&") {
+			m := pipelineConditionRe.FindStringSubmatch(clause)
+			if m == nil {
+				return nil, fmt.Errorf("无法解析的条件: %q", strings.TrimSpace(clause))
+			}
+			cond := pipelineCondition{field: m[1], op: m[2], value: strings.Trim(m[3], `"'`)}
+			if err := cond.validate(); err != nil {
+				return nil, err
+			}
+			group = append(group, cond)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// validate 检查字段与比较方式是否匹配
+func (c pipelineCondition) validate() error {
+	switch c.field {
+	case "duration", "comments":
+		if c.op == "contains" {
+			return fmt.Errorf("字段 %s 不支持 contains", c.field)
+		}
+		if _, err := c.number(); err != nil {
+			return err
+		}
+	case "streamer", "platform", "language", "title":
+		if c.op != "==" && c.op != "!=" && c.op != "contains" {
+			return fmt.Errorf("字段 %s 只支持 ==、!= 与 contains", c.field)
+		}
+	default:
+		return fmt.Errorf("未知的条件字段: %s", c.field)
+	}
+	return nil
+}
+
+// number 数值条件的比较值；duration 支持 6h、90m 等时长写法
+func (c pipelineCondition) number() (float64, error) {
+	if v, err := strconv.ParseFloat(c.value, 64); err == nil {
+		return v, nil
+	}
+	if c.field == "duration" {
+		if d, err := time.ParseDuration(c.value); err == nil {
+			return d.Seconds(), nil
+		}
+	}
+	return 0, fmt.Errorf("条件 %s 的值不是有效的数值: %s", c.field, c.value)
+}
+
+// match 条件是否成立
+func (c pipelineCondition) match(facts PipelineFacts) bool {
+	switch c.field {
+	case "duration", "comments":
+		actual := facts.Duration
+		if c.field == "comments" {
+			actual = float64(facts.Comments)
+		}
+		want, _ := c.number()
+		switch c.op {
+		case "==":
+			return actual == want
+		case "!=":
+			return actual != want
+		case ">":
+			return actual > want
+		case ">=":
+			return actual >= want
+		case "<":
+			return actual < want
+		case "<=":
+			return actual <= want
+		}
+		return false
+	}
+
+	var actual string
+	switch c.field {
+	case "streamer":
+		actual = facts.Streamer
+	case "platform":
+		actual = facts.Platform
+	case "language":
+		actual = facts.Language
+	case "title":
+		actual = facts.Title
+	}
+	switch c.op {
+	case "==":
+		return strings.EqualFold(actual, c.value)
+	case "!=":
+		return !strings.EqualFold(actual, c.value)
+	case "contains":
+		return strings.Contains(strings.ToLower(actual), strings.ToLower(c.value))
+	}
+	return false
+}
+
+// matchPipelineCondition 任一组内的条件全部成立即命中
+func matchPipelineCondition(groups [][]pipelineCondition, facts PipelineFacts) bool {
+	if len(groups) == 0 {
+		return true
+	}
+	for _, group := range groups {
+		matched := true
+		for _, cond := range group {
+			if !cond.match(facts) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPipelineAction 将一条动作应用到处理方案，返回是否停止匹配后续规则
+func applyPipelineAction(plan *PipelinePlan, action string) (bool, error) {
+	fields := strings.Fields(strings.ToLower(action))
+	if len(fields) == 0 {
+		return false, fmt.Errorf("动作不能为空")
+	}
+
+	arg := func() (string, error) {
+		if len(fields) != 2 {
+			return "", fmt.Errorf("动作 %q 需要一个参数", action)
+		}
+		return fields[1], nil
+	}
+
+	switch fields[0] {
+	case "skip", "run":
+		step, err := arg()
+		if err != nil {
+			return false, err
+		}
+		if !containsFold(pipelineSteps, step) {
+			return false, fmt.Errorf("未知的流水线步骤: %s", step)
+		}
+		skipped := plan.Skipped[:0:0]
+		for _, s := range plan.Skipped {
+			if s != step {
+				skipped = append(skipped, s)
+			}
+		}
+		if fields[0] == "skip" {
+			skipped = append(skipped, step)
+		}
+		plan.Skipped = skipped
+	case "audio_only":
+		plan.Quality, plan.qualitySet = "audio_only", true
+	case "quality":
+		quality, err := arg()
+		if err != nil {
+			return false, err
+		}
+		plan.Quality, plan.qualitySet = quality, true
+	case "clip_interval":
+		value, err := arg()
+		if err != nil {
+			return false, err
+		}
+		cond := pipelineCondition{field: "duration", value: value}
+		seconds, err := cond.number()
+		if err != nil || seconds <= 0 {
+			return false, fmt.Errorf("无效的片段时长: %s", value)
+		}
+		plan.ClipInterval = seconds
+	case "stop":
+		return true, nil
+	default:
+		return false, fmt.Errorf("未知的流水线动作: %s", fields[0])
+	}
+	return false, nil
+}
+
+// Validate checks that every pipeline rule has a parsable condition and known actions
+func (c PipelineConfig) Validate() error {
+	for i, rule := range c.Rules {
+		if _, err := parsePipelineCondition(rule.When); err != nil {
+			return fmt.Errorf("流水线规则 #%d: %w", i, err)
+		}
+		if len(rule.Then) == 0 {
+			return fmt.Errorf("流水线规则 #%d 没有动作", i)
+		}
+		plan := defaultPipelinePlan(PipelineFacts{})
+		for _, action := range rule.Then {
+			if _, err := applyPipelineAction(&plan, action); err != nil {
+				return fmt.Errorf("流水线规则 #%d: %w", i, err)
+			}
+		}
+	}
+	if err := c.Gates.Validate(); err != nil {
+		return err
+	}
+	if err := c.Queue.Validate(); err != nil {
+		return err
+	}
+	if err := c.Concurrency.Validate(); err != nil {
+		return err
+	}
+	for streamer, gates := range c.StreamerGates {
+		if err := gates.Validate(); err != nil {
+			return fmt.Errorf("主播 %s: %w", streamer, err)
+		}
+	}
+	if err := c.Clips.Validate(); err != nil {
+		return err
+	}
+	if err := c.Previews.Validate(); err != nil {
+		return err
+	}
+	if err := c.ChatSummary.Validate(); err != nil {
+		return err
+	}
+	for streamer, clips := range c.StreamerClips {
+		if err := clips.Validate(); err != nil {
+			return fmt.Errorf("主播 %s: %w", streamer, err)
+		}
+	}
+	return nil
+}
+
+// EvaluatePipeline 按顺序对录像信息求值流水线规则，后命中的规则覆盖前面的设置，遇到 stop 时停止
+// 主播的片段设置先于规则应用，规则仍可覆盖片段时长与画质
+func EvaluatePipeline(facts PipelineFacts) PipelinePlan {
+	plan := defaultPipelinePlan(facts)
+	GetClipperService().applyTo(&plan, facts.Streamer)
+
+	for i, rule := range GetPipelineConfig().Rules {
+		groups, err := parsePipelineCondition(rule.When)
+		if err != nil || !matchPipelineCondition(groups, facts) {
+			continue
+		}
+		plan.MatchedRules = append(plan.MatchedRules, i)
+
+		stop := false
+		for _, action := range rule.Then {
+			halt, err := applyPipelineAction(&plan, action)
+			if err != nil {
+				log.Printf("流水线规则 #%d 的动作 %q 无效: %v", i, action, err)
+				continue
+			}
+			stop = stop || halt
+		}
+		if stop {
+			break
+		}
+	}
+
+	return plan
+}
+
+// planPipelineForVideo 求值录像的处理方案并保存，供后续的片段、重试等阶段使用
+// 规则未指定画质时使用订阅者偏好的画质，AI总结使用订阅者偏好的语言
+func planPipelineForVideo(videoID string, facts PipelineFacts) PipelinePlan {
+	plan := EvaluatePipeline(facts)
+	if quality, language, ok := subscriberContentPreferences(facts.Streamer); ok {
+		if !plan.qualitySet {
+			plan.Quality = quality
+		}
+		plan.SummaryLanguage = language
+	}
+	// 未达到处理门槛的录像（例如短时间的测试直播）跳过分析与片段，并记录到主播的录像历史
+	if reason := processingGatesFor(facts.Streamer).check(facts); reason != "" {
+		plan.GateReason = reason
+		for _, step := range []string{PipelineStepAnalysis, PipelineStepClips} {
+			if plan.Runs(step) {
+				plan.Skipped = append(plan.Skipped, step)
+			}
+		}
+		log.Printf("视频 %s 未达到处理门槛，跳过自动处理: %s", videoID, reason)
+		recordSkippedVOD(videoID, facts, reason)
+	}
+	if len(plan.MatchedRules) > 0 {
+		log.Printf("视频 %s 命中流水线规则 %v: 跳过 %v, 画质 %s, 片段时长 %.0f 秒",
+			videoID, plan.MatchedRules, plan.Skipped, plan.Quality, plan.ClipInterval)
+	}
+
+	savePipelinePlan(videoID, plan)
+	return plan
+}
+
+// savePipelinePlan 保存录像的处理方案
+func savePipelinePlan(videoID string, plan PipelinePlan) {
+	videoDir := pathsafe.Join("./analysis_results", videoID)
+	if err := os.MkdirAll(videoDir, 0755); err != nil {
+		log.Printf("创建分析目录失败: %v", err)
+		return
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(videoDir, pipelinePlanFileName), data, 0644)
+	}
+	if err != nil {
+		log.Printf("保存视频 %s 的处理方案失败: %v", videoID, err)
+	}
+}
+
+// loadPipelinePlan 读取录像保存的处理方案，不存在时返回执行全部步骤的默认方案
+func loadPipelinePlan(videoID string) PipelinePlan {
+	data, err := os.ReadFile(filepath.Join(pathsafe.Join("./analysis_results", videoID), pipelinePlanFileName))
+	if err != nil {
+		return defaultPipelinePlan(PipelineFacts{})
+	}
+	var plan PipelinePlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		log.Printf("解析视频 %s 的处理方案失败: %v", videoID, err)
+		return defaultPipelinePlan(PipelineFacts{})
+	}
+	return plan
+}
+
+// parseVideoDuration 解析平台返回的录像时长（Twitch 为 3h2m1s，YouTube 为 PT3H2M1S），失败时返回 0
+func parseVideoDuration(s string) float64 {
+	s = strings.ToLower(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "PT"))
+	if s == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0
+	}
+	return d.Seconds()
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	skippedVODsFile = "App_Data/skipped_vods.json"
+	// maxSkippedVODs 最多保留的跳过记录数，超出时丢弃最早的记录
+	maxSkippedVODs = 1000
+)
+
+// SkippedVOD 因未达到处理门槛而跳过自动处理的录像
+type SkippedVOD struct {
+	VideoID   string    `json:"video_id"`
+	Streamer  string    `json:"streamer"`
+	Platform  string    `json:"platform"`
+	Title     string    `json:"title,omitempty"`
+	Duration  float64   `json:"duration"` // 录像时长（秒）
+	Comments  int       `json:"comments"`
+	Reason    string    `json:"reason"`
+	SkippedAt time.Time `json:"skipped_at"`
+}
+
+var skippedVODsMu sync.Mutex
+
+// Validate checks that no gate is negative
+func (g ProcessingGates) Validate() error {
+	if g.MinDurationSeconds < 0 || g.MinComments < 0 {
+		return fmt.Errorf("处理门槛不能为负数")
+	}
+	return nil
+}
+
+// processingGatesFor 主播适用的处理门槛，配置了主播门槛时优先使用
+func processingGatesFor(streamer string) ProcessingGates {
+	cfg := GetPipelineConfig()
+	for id, gates := range cfg.StreamerGates {
+		if strings.EqualFold(id, strings.TrimPrefix(streamer, "@")) {
+			return gates
+		}
+	}
+	return cfg.Gates
+}
+
+// check 返回录像未达到的门槛说明，全部满足时返回空字符串
+// 平台未返回时长时（0）不按时长判断
+func (g ProcessingGates) check(facts PipelineFacts) string {
+	if g.MinDurationSeconds > 0 && facts.Duration > 0 && facts.Duration < float64(g.MinDurationSeconds) {
+		return fmt.Sprintf("录像时长 %s 短于 %s", formatDuration(facts.Duration), formatDuration(float64(g.MinDurationSeconds)))
+	}
+	if g.MinComments > 0 && facts.Comments < g.MinComments {
+		return fmt.Sprintf("聊天消息 %d 条，少于 %d 条", facts.Comments, g.MinComments)
+	}
+	return ""
+}
+
+// recordSkippedVOD 记录跳过的录像，同一录像只保留最新一条
+func recordSkippedVOD(videoID string, facts PipelineFacts, reason string) {
+	skippedVODsMu.Lock()
+	defer skippedVODsMu.Unlock()
+
+	records := loadSkippedVODsLocked()
+	kept := records[:0]
+	for _, r := range records {
+		if r.VideoID != videoID {
+			kept = append(kept, r)
+		}
+	}
+	kept = append(kept, SkippedVOD{
+		VideoID:   videoID,
+		Streamer:  strings.ToLower(strings.TrimPrefix(facts.Streamer, "@")),
+		Platform:  facts.Platform,
+		Title:     facts.Title,
+		Duration:  facts.Duration,
+		Comments:  facts.Comments,
+		Reason:    reason,
+		SkippedAt: time.Now(),
+	})
+	if len(kept) > maxSkippedVODs {
+		kept = kept[len(kept)-maxSkippedVODs:]
+	}
+
+	err := os.MkdirAll(filepath.Dir(skippedVODsFile), 0755)
+	if err == nil {
+		var data []byte
+		if data, err = json.MarshalIndent(kept, "", "  "); err == nil {
+			err = os.WriteFile(skippedVODsFile, data, 0644)
+		}
+	}
+	if err != nil {
+		log.Printf("保存跳过的录像记录失败: %v", err)
+	}
+}
+
+// loadSkippedVODsLocked 读取跳过的录像记录，调用方需持有 skippedVODsMu
+func loadSkippedVODsLocked() []SkippedVOD {
+	data, err := os.ReadFile(skippedVODsFile)
+	if err != nil {
+		return nil
+	}
+	var records []SkippedVOD
+	if err := json.Unmarshal(data, &records); err != nil {
+		log.Printf("读取跳过的录像记录失败: %v", err)
+		return nil
+	}
+	return records
+}
+
+// skippedVODsForStreamer 主播被跳过的录像，最新的在前
+// 记录中的账号（平台登录名或频道）按主播ID、平台账号解析后匹配
+func skippedVODsForStreamer(streamerID string) []SkippedVOD {
+	skippedVODsMu.Lock()
+	records := loadSkippedVODsLocked()
+	skippedVODsMu.Unlock()
+
+	owners := map[string]bool{}
+	result := []SkippedVOD{}
+	for i := len(records) - 1; i >= 0; i-- {
+		account := records[i].Streamer
+		matched, ok := owners[account]
+		if !ok {
+			matched = strings.EqualFold(account, streamerID)
+			if !matched {
+				streamer := ResolveStreamer(account)
+				matched = streamer != nil && strings.EqualFold(streamer.ID, streamerID)
+			}
+			owners[account] = matched
+		}
+		if matched {
+			result = append(result, records[i])
+		}
+	}
+	return result
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	subtube "subtuber-services/protos"
+	"subtuber-services/services"
+)
+
+// 内置的调度策略
+const (
+	schedulerPolicySubscribers = "subscribers"
+	schedulerPolicyFIFO        = "fifo"
+
+	defaultSchedulerPolicy = schedulerPolicySubscribers
+)
+
+const (
+	// defaultQueueMaxConcurrent 同时执行的后台任务数
+	defaultQueueMaxConcurrent = 2
+	// defaultQueueAging 任务每等待该时长，优先级提高 1（相当于订阅者数量翻一倍）
+	defaultQueueAging = 10 * time.Minute
+)
+
+// QueuedJob 处理队列中等待执行的任务
+type QueuedJob struct {
+	Job        PersistedJob `json:"job"`
+	Demand     float64      `json:"demand"` // 主播订阅者的权重之和
+	EnqueuedAt time.Time    `json:"enqueued_at"`
+	Priority   float64      `json:"priority"` // 最近一次调度时计算的优先级，仅用于展示
+
+	run func()
+}
+
+// SchedulerPolicy 处理队列积压时决定任务的执行顺序
+// 新策略实现该接口并通过 RegisterSchedulerPolicy 注册后，即可通过配置 pipeline.queue.policy 选择
+type SchedulerPolicy interface {
+	// Name 策略名称，对应配置 pipeline.queue.policy
+	Name() string
+	// Description 策略说明
+	Description() string
+	// Priority 任务的优先级，数值大的先执行；waited 为任务已等待的时长，aging 为配置的老化周期
+	Priority(job *QueuedJob, waited, aging time.Duration) float64
+}
+
+// subscriberPolicy 订阅者多的主播优先：优先级为订阅者权重的对数，加上按等待时长增加的老化项
+// 对数使订阅者从 1 到 2 与从 100 到 200 的提升相同，订阅者少的主播等待若干个老化周期后即可排到前面
+type subscriberPolicy struct{}
+
+func (subscriberPolicy) Name() string { return schedulerPolicySubscribers }
+
+func (subscriberPolicy) Description() string {
+	return "订阅者多的主播优先，等待时间越长优先级越高，避免订阅者少的主播一直排在后面"
+}
+
+func (subscriberPolicy) Priority(job *QueuedJob, waited, aging time.Duration) float64 {
+	return math.Log2(1+job.Demand) + waited.Seconds()/aging.Seconds()
+}
+
+// fifoPolicy 先到先处理
+type fifoPolicy struct{}
+
+func (fifoPolicy) Name() string { return schedulerPolicyFIFO }
+
+func (fifoPolicy) Description() string { return "按加入队列的先后顺序处理" }
+
+func (fifoPolicy) Priority(job *QueuedJob, waited, aging time.Duration) float64 {
+	return waited.Seconds()
+}
+
+var (
+	schedulerPoliciesMu sync.RWMutex
+	schedulerPolicies   = map[string]SchedulerPolicy{
+		schedulerPolicySubscribers: subscriberPolicy{},
+		schedulerPolicyFIFO:        fifoPolicy{},
+	}
+)
+
+// RegisterSchedulerPolicy 注册调度策略，同名策略会被替换
+func RegisterSchedulerPolicy(policy SchedulerPolicy) {
+	schedulerPoliciesMu.Lock()
+	defer schedulerPoliciesMu.Unlock()
+	schedulerPolicies[strings.ToLower(policy.Name())] = policy
+}
+
+// getSchedulerPolicy 按名称查找调度策略，名称为空时返回默认策略
+func getSchedulerPolicy(name string) (SchedulerPolicy, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = defaultSchedulerPolicy
+	}
+	schedulerPoliciesMu.RLock()
+	defer schedulerPoliciesMu.RUnlock()
+	policy, ok := schedulerPolicies[name]
+	return policy, ok
+}
+
+// Validate checks the queue policy name and limits
+func (c ProcessingQueueConfig) Validate() error {
+	if _, ok := getSchedulerPolicy(c.Policy); !ok {
+		return fmt.Errorf("未知的调度策略: %s", c.Policy)
+	}
+	if c.MaxConcurrent < 0 {
+		return fmt.Errorf("队列并发数不能为负数")
+	}
+	if c.AgingMinutes < 0 {
+		return fmt.Errorf("队列老化周期不能为负数")
+	}
+	return nil
+}
+
+// subscriberWeight 单个订阅者对主播优先级的贡献，付费档位上线后可在此提高付费订阅者的权重
+func subscriberWeight(sub *subtube.UserStreamerSubscription) float64 {
+	return 1
+}
+
+// streamerDemand 主播所有订阅者的权重之和，无法获取订阅者时为 0
+func streamerDemand(streamer string) float64 {
+	streamerID := streamer
+	if info := ResolveStreamer(streamer); info != nil {
+		streamerID = info.ID
+	}
+	if streamerID == "" {
+		return 0
+	}
+	resp, err := services.GetStreamerSubscribers(streamerID)
+	if err != nil {
+		log.Printf("获取 %s 的订阅者失败，按无订阅者排队: %v", streamerID, err)
+		return 0
+	}
+	demand := 0.0
+	for _, sub := range resp.Subscriptions {
+		demand += subscriberWeight(sub)
+	}
+	return demand
+}
+
+// processingQueue 有并发上限的后台任务队列，空闲名额按调度策略分配给等待中的任务
+type processingQueue struct {
+	mu      sync.Mutex
+	pending []*QueuedJob
+	running int
+}
+
+var jobQueue = &processingQueue{}
+
+// submitProcessingJob 将任务加入处理队列并持久化，有空闲名额时立即开始执行
+// 队列中已有相同的任务时不重复加入
+func submitProcessingJob(job PersistedJob, run func()) {
+	streamer := job.StreamerID
+	if streamer == "" {
+		streamer = job.Streamer
+	}
+	queued := &QueuedJob{Job: job, Demand: streamerDemand(streamer), EnqueuedAt: time.Now(), run: run}
+	enqueuePersistedJob(job)
+
+	jobQueue.mu.Lock()
+	defer jobQueue.mu.Unlock()
+	for _, pending := range jobQueue.pending {
+		if pending.Job.key() == job.key() {
+			return
+		}
+	}
+	jobQueue.pending = append(jobQueue.pending, queued)
+	if job.VideoID != "" {
+		reportJobProgress(job.VideoID, JobStageQueued, 0, "等待处理")
+	}
+	jobQueue.dispatchLocked()
+}
+
+// queueSettings 当前配置的调度策略、并发数与老化周期
+func queueSettings() (SchedulerPolicy, int, time.Duration) {
+	cfg := GetPipelineConfig().Queue
+	policy, ok := getSchedulerPolicy(cfg.Policy)
+	if !ok {
+		policy, _ = getSchedulerPolicy("")
+	}
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultQueueMaxConcurrent
+	}
+	aging := time.Duration(cfg.AgingMinutes * float64(time.Minute))
+	if aging <= 0 {
+		aging = defaultQueueAging
+	}
+	return policy, maxConcurrent, aging
+}
+
+// prioritizeLocked 按策略计算优先级并排序，优先级相同时先加入的在前，调用方需持有 q.mu
+func (q *processingQueue) prioritizeLocked(policy SchedulerPolicy, aging time.Duration) {
+	now := time.Now()
+	for _, job := range q.pending {
+		job.Priority = policy.Priority(job, now.Sub(job.EnqueuedAt), aging)
+	}
+	sort.SliceStable(q.pending, func(i, j int) bool {
+		if q.pending[i].Priority != q.pending[j].Priority {
+			return q.pending[i].Priority > q.pending[j].Priority
+		}
+		return q.pending[i].EnqueuedAt.Before(q.pending[j].EnqueuedAt)
+	})
+}
+
+// dispatchLocked 在并发上限内启动优先级最高的任务，调用方需持有 q.mu
+func (q *processingQueue) dispatchLocked() {
+	policy, maxConcurrent, aging := queueSettings()
+	for q.running < maxConcurrent && len(q.pending) > 0 {
+		q.prioritizeLocked(policy, aging)
+		next := q.pending[0]
+		q.pending = q.pending[1:]
+		q.running++
+		if waited := time.Since(next.EnqueuedAt); waited > time.Minute {
+			log.Printf("任务 %s 排队 %s 后开始执行 (策略 %s, 优先级 %.2f)", next.Job.key(), waited.Round(time.Second), policy.Name(), next.Priority)
+		}
+		go q.execute(next)
+	}
+}
+
+// execute 执行任务，结束后释放名额并调度下一个任务
+func (q *processingQueue) execute(job *QueuedJob) {
+	defer func() {
+		q.mu.Lock()
+		q.running--
+		q.dispatchLocked()
+		q.mu.Unlock()
+	}()
+	job.run()
+}
+
+// snapshot 当前执行中的任务数与按优先级排序的等待任务
+func (q *processingQueue) snapshot() (string, int, []QueuedJob) {
+	policy, _, aging := queueSettings()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prioritizeLocked(policy, aging)
+	pending := make([]QueuedJob, 0, len(q.pending))
+	for _, job := range q.pending {
+		item := *job
+		item.Job.HotMoments = nil
+		item.Job.Video = nil
+		pending = append(pending, item)
+	}
+	return policy.Name(), q.running, pending
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+)
+
+const streamerPromptTemplatesFile = "App_Data/streamer_prompt_templates.json"
+
+// 内置的总结提示词，与之前硬编码的提示词一致；字幕分段、各段总结与弹幕附在渲染结果之后
+const (
+	builtinChunkPrompt = "This is a clip from a streamer's live broadcast.{{if .Overlap}} The first {{.Overlap}} subtitle entries repeat the end of the previous segment " +
+		"and are given only as context for the transition.{{end}} To summarize, what topics are being discussed in this segment: "
+	builtinFinalPrompt = "Here are summaries of each section. Please consolidate them into a final summary, " +
+		"presenting key points in {{.Language}} and keeping the length within {{.Words}} words: "
+	builtinChatPrompt = "These are the chat messages sent around {{.ClipTime}} of a streamer's live broadcast, one per line with the time and the author. " +
+		"Summarize in {{.Language}}, within {{.Words}} words, what the chat was reacting to and how viewers felt about it: "
+)
+
+// PromptTemplates holds the text/template prompts used to summarize subtitles; empty fields fall back to the next level
+type PromptTemplates struct {
+	Chunk string `mapstructure:"chunk" json:"chunk,omitempty"` // 分段总结的提示词，字幕分段附在其后
+	Final string `mapstructure:"final" json:"final,omitempty"` // 合并总结的提示词，各分段总结附在其后
+	Chat  string `mapstructure:"chat" json:"chat,omitempty"`   // 弹幕反应总结的提示词，热点附近的弹幕附在其后
+}
+
+// merge 用 override 中设置了的字段覆盖当前模板
+func (t PromptTemplates) merge(override PromptTemplates) PromptTemplates {
+	if strings.TrimSpace(override.Chunk) != "" {
+		t.Chunk = override.Chunk
+	}
+	if strings.TrimSpace(override.Final) != "" {
+		t.Final = override.Final
+	}
+	if strings.TrimSpace(override.Chat) != "" {
+		t.Chat = override.Chat
+	}
+	return t
+}
+
+// Validate checks that every template parses and render with sample variables
+func (t PromptTemplates) Validate() error {
+	sample := SummaryPromptVars{Streamer: "streamer", Game: "game", Title: "title", ClipTime: "1:02:03", Language: "Chinese", Words: 300, Overlap: 2}
+	for name, text := range map[string]string{"chunk": t.Chunk, "final": t.Final, "chat": t.Chat} {
+		if text == "" {
+			continue
+		}
+		if _, err := renderPrompt(text, sample); err != nil {
+			return fmt.Errorf("%s 提示词模板无效: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// PromptConfig holds the default summary prompt templates and the overrides per output language and per streamer
+type PromptConfig struct {
+	Default PromptTemplates `mapstructure:"default" json:"default"`
+	// Languages 按总结语言代码（例如 en、ja、zh-TW）覆盖，语言代码不完全匹配时尝试主语言（ja-JP 使用 ja）
+	Languages map[string]PromptTemplates `mapstructure:"languages" json:"languages"`
+	// Streamers 按主播ID覆盖，优先于语言
+	Streamers map[string]PromptTemplates `mapstructure:"streamers" json:"streamers"`
+}
+
+// Validate checks every configured template
+func (c PromptConfig) Validate() error {
+	if err := c.Default.Validate(); err != nil {
+		return fmt.Errorf("默认%w", err)
+	}
+	for language, t := range c.Languages {
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("语言 %s 的%w", language, err)
+		}
+	}
+	for streamer, t := range c.Streamers {
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("主播 %s 的%w", streamer, err)
+		}
+	}
+	return nil
+}
+
+// SummaryPromptVars 提示词模板中可用的变量
+type SummaryPromptVars struct {
+	StreamerID string `json:"-"`
+	Streamer   string // 主播显示名称
+	Game       string // 直播分类（游戏），仅 Twitch 且开播期间检查到时可用
+	Title      string // 录像标题
+	ClipTime   string // 热点在录像中的时间，例如 1:02:03
+	Language   string // 总结的输出语言，例如 Chinese、English
+	Words      int    // 最终总结的字数上限（合并总结与弹幕反应总结）
+	Overlap    int    // 分段开头与上一段重复的字幕条目数（仅分段总结）
+}
+
+// renderPrompt 渲染提示词模板
+func renderPrompt(text string, vars SummaryPromptVars) (string, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// renderPromptOrBuiltin 渲染提示词模板，模板无法渲染时记录日志并使用内置提示词
+func renderPromptOrBuiltin(text, builtin string, vars SummaryPromptVars) string {
+	prompt, err := renderPrompt(text, vars)
+	if err == nil {
+		return prompt
+	}
+	log.Printf("渲染AI总结提示词失败，使用内置提示词: %v", err)
+	prompt, _ = renderPrompt(builtin, vars)
+	return prompt
+}
+
+// summaryPromptVarsKey 在 context 中传递提示词变量
+type summaryPromptVarsKey struct{}
+
+// withSummaryPromptVars 返回携带提示词变量的 context，语言、字数与重叠条目数在总结时填充
+func withSummaryPromptVars(ctx context.Context, vars SummaryPromptVars) context.Context {
+	return context.WithValue(ctx, summaryPromptVarsKey{}, vars)
+}
+
+// summaryPromptVarsFromContext 读取 context 中的提示词变量
+func summaryPromptVarsFromContext(ctx context.Context) SummaryPromptVars {
+	vars, _ := ctx.Value(summaryPromptVarsKey{}).(SummaryPromptVars)
+	vars.Language = summaryLanguageName(summaryLanguageFromContext(ctx))
+	return vars
+}
+
+// summaryPromptVarsFor 按录像的处理方案生成热点总结的提示词变量
+func summaryPromptVarsFor(plan PipelinePlan, offsetSeconds float64) SummaryPromptVars {
+	vars := SummaryPromptVars{
+		StreamerID: plan.Facts.Streamer,
+		Streamer:   plan.Facts.Streamer,
+		Title:      plan.Facts.Title,
+		ClipTime:   formatDuration(offsetSeconds),
+		Game:       lastStreamGame(plan.Facts.Streamer),
+	}
+	if streamer := ResolveStreamer(plan.Facts.Streamer); streamer != nil {
+		vars.StreamerID, vars.Streamer = streamer.ID, streamer.Name
+	}
+	return vars
+}
+
+// lastStreamGames 主播最近一次开播检查到的直播分类，键为小写的平台账号
+var (
+	lastStreamGamesMu sync.Mutex
+	lastStreamGames   = map[string]string{}
+)
+
+// recordStreamGame 记录主播当前的直播分类，下播后总结录像时使用
+func recordStreamGame(login, game string) {
+	if login == "" || game == "" {
+		return
+	}
+	lastStreamGamesMu.Lock()
+	defer lastStreamGamesMu.Unlock()
+	lastStreamGames[strings.ToLower(login)] = game
+}
+
+// lastStreamGame 主播最近一次开播的直播分类，未记录时为空
+func lastStreamGame(login string) string {
+	lastStreamGamesMu.Lock()
+	defer lastStreamGamesMu.Unlock()
+	return lastStreamGames[strings.ToLower(login)]
+}
+
+// promptTemplatesMu 保护接口保存的主播提示词文件
+var promptTemplatesMu sync.Mutex
+
+// loadStreamerPromptTemplates 读取接口保存的主播提示词模板，文件不存在时返回空
+func loadStreamerPromptTemplates() (map[string]PromptTemplates, error) {
+	data, err := os.ReadFile(streamerPromptTemplatesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]PromptTemplates{}, nil
+		}
+		return nil, err
+	}
+	all := map[string]PromptTemplates{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// saveStreamerPromptTemplates 将主播提示词模板写回文件
+func saveStreamerPromptTemplates(all map[string]PromptTemplates) error {
+	if err := os.MkdirAll(filepath.Dir(streamerPromptTemplatesFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(streamerPromptTemplatesFile, data, 0644)
+}
+
+// storedPromptTemplates 接口保存的主播提示词模板，未保存时返回 false
+func storedPromptTemplates(streamerID string) (PromptTemplates, bool) {
+	promptTemplatesMu.Lock()
+	defer promptTemplatesMu.Unlock()
+
+	all, err := loadStreamerPromptTemplates()
+	if err != nil {
+		log.Printf("读取主播提示词模板失败: %v", err)
+		return PromptTemplates{}, false
+	}
+	t, ok := all[clipSettingsKey(streamerID)]
+	return t, ok
+}
+
+// setStoredPromptTemplates 保存主播的提示词模板，覆盖配置文件中的模板
+func setStoredPromptTemplates(streamerID string, t PromptTemplates) error {
+	promptTemplatesMu.Lock()
+	defer promptTemplatesMu.Unlock()
+
+	all, err := loadStreamerPromptTemplates()
+	if err != nil {
+		return err
+	}
+	all[clipSettingsKey(streamerID)] = t
+	return saveStreamerPromptTemplates(all)
+}
+
+// deleteStoredPromptTemplates 删除接口保存的主播提示词模板
+func deleteStoredPromptTemplates(streamerID string) error {
+	promptTemplatesMu.Lock()
+	defer promptTemplatesMu.Unlock()
+
+	all, err := loadStreamerPromptTemplates()
+	if err != nil {
+		return err
+	}
+	delete(all, clipSettingsKey(streamerID))
+	return saveStreamerPromptTemplates(all)
+}
+
+// promptTemplatesFor 主播与总结语言实际使用的提示词模板
+// 按以下顺序覆盖：接口保存的主播模板 > 配置文件的 streamers > 配置文件的 languages > 配置文件的 default > 内置提示词
+func promptTemplatesFor(streamerID, language string) PromptTemplates {
+	cfg := GetAIConfig().Prompts
+	t := PromptTemplates{Chunk: builtinChunkPrompt, Final: builtinFinalPrompt, Chat: builtinChatPrompt}.merge(cfg.Default)
+
+	if language == "" {
+		language = "zh"
+	}
+	language = strings.ToLower(language)
+	base := strings.SplitN(language, "-", 2)[0]
+	for _, key := range []string{base, language} {
+		for code, override := range cfg.Languages {
+			if strings.ToLower(code) == key {
+				t = t.merge(override)
+			}
+		}
+	}
+
+	if streamerID == "" {
+		return t
+	}
+	for id, override := range cfg.Streamers {
+		if strings.EqualFold(id, strings.TrimPrefix(streamerID, "@")) {
+			t = t.merge(override)
+			break
+		}
+	}
+	if stored, ok := storedPromptTemplates(streamerID); ok {
+		t = t.merge(stored)
+	}
+	return t
+}
+
+// getStreamerPromptTemplatesHandler 查看主播实际使用的提示词模板（language 指定总结语言，默认中文）及接口保存的模板
+func getStreamerPromptTemplatesHandler(c *gin.Context) {
+	streamerID := c.Param("id")
+
+	resp := gin.H{
+		"success":     true,
+		"streamer_id": streamerID,
+		"effective":   promptTemplatesFor(streamerID, c.Query("language")),
+	}
+	if stored, ok := storedPromptTemplates(streamerID); ok {
+		resp["stored"] = stored
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// setStreamerPromptTemplatesHandler 保存主播的提示词模板，之后生成的总结使用新模板
+func setStreamerPromptTemplatesHandler(c *gin.Context) {
+	streamerID := c.Param("id")
+
+	var req PromptTemplates
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if strings.TrimSpace(req.Chunk) == "" && strings.TrimSpace(req.Final) == "" && strings.TrimSpace(req.Chat) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "chunk、final 与 chat 至少需要指定一个",
+		})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := setStoredPromptTemplates(streamerID, req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "保存提示词模板失败: " + err.Error(),
+		})
+		return
+	}
+	log.Printf("已更新主播 %s 的AI总结提示词模板", streamerID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "提示词模板已保存",
+		"streamer_id": streamerID,
+		"effective":   promptTemplatesFor(streamerID, ""),
+	})
+}
+
+// deleteStreamerPromptTemplatesHandler 删除接口保存的主播提示词模板
+func deleteStreamerPromptTemplatesHandler(c *gin.Context) {
+	streamerID := c.Param("id")
+
+	if err := deleteStoredPromptTemplates(streamerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "删除提示词模板失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "提示词模板已删除",
+		"streamer_id": streamerID,
+		"effective":   promptTemplatesFor(streamerID, ""),
+	})
+}
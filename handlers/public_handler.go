@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"subtuber-services/models"
+	"subtuber-services/pathsafe"
+
+	"github.com/gin-gonic/gin"
+	cache "github.com/patrickmn/go-cache"
+)
+
+const (
+	publicProfileVODLimit    = 5   // 公开主页展示的最近录像数
+	publicProfileMomentLimit = 3   // 每个录像展示的热点数
+	publicProfileMaxAge      = 300 // 浏览器/CDN 缓存时间（秒）
+)
+
+// publicProfileCache 缓存序列化后的公开主页，避免频繁扫描分析结果目录
+var publicProfileCache = cache.New(publicProfileMaxAge*time.Second, 10*time.Minute)
+
+// PublicStreamerProfile 对外公开的主播主页
+type PublicStreamerProfile struct {
+	ID              string           `json:"id"`
+	Name            string           `json:"name"`
+	ProfileImageURL string           `json:"profile_image_url,omitempty"`
+	Platforms       []string         `json:"platforms"`
+	RecentVODs      []PublicVODEntry `json:"recent_vods"`
+	GeneratedAt     time.Time        `json:"generated_at"`
+}
+
+// PublicVODEntry 公开主页中的录像条目
+type PublicVODEntry struct {
+	VideoID      string            `json:"video_id"`
+	Title        string            `json:"title"`
+	URL          string            `json:"url,omitempty"`
+	ThumbnailURL string            `json:"thumbnail_url,omitempty"`
+	CreatedAt    string            `json:"created_at,omitempty"`
+	Duration     string            `json:"duration,omitempty"`
+	TopMoments   []PublicHotMoment `json:"top_moments"`
+}
+
+// PublicHotMoment 公开主页中的热点时刻
+type PublicHotMoment struct {
+	OffsetSeconds float64 `json:"offset_seconds"`
+	FormattedTime string  `json:"formatted_time"`
+	Title         string  `json:"title,omitempty"` // AI生成的片段标题
+	Summary       string  `json:"summary,omitempty"`
+	ChatReaction  string  `json:"chat_reaction,omitempty"` // 根据弹幕生成的“观众在对什么做出反应”的总结
+}
+
+// cachedPublicProfile 缓存的主页响应体及其 ETag
+type cachedPublicProfile struct {
+	body []byte
+	etag string
+}
+
+// GetPublicStreamerProfile 获取主播的公开主页数据，用于嵌入与 SEO 页面
+// 仅包含公开可见的录像、热点时刻与总结，不含订阅者相关数据
+func GetPublicStreamerProfile(c *gin.Context) {
+	streamerID := strings.ToLower(c.Param("id"))
+	if streamerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "主播ID不能为空",
+		})
+		return
+	}
+
+	var entry *cachedPublicProfile
+	if cached, found := publicProfileCache.Get(streamerID); found {
+		entry = cached.(*cachedPublicProfile)
+	} else {
+		profile, err := buildPublicStreamerProfile(streamerID)
+		if err != nil {
+			if os.IsNotExist(err) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"success": false,
+					"message": "主播不存在或未公开",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "获取主播主页失败: " + err.Error(),
+			})
+			return
+		}
+
+		body, err := json.Marshal(gin.H{"success": true, "profile": profile})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "序列化主播主页失败: " + err.Error(),
+			})
+			return
+		}
+		sum := sha1.Sum(body)
+		entry = &cachedPublicProfile{body: body, etag: `"` + hex.EncodeToString(sum[:]) + `"`}
+		publicProfileCache.SetDefault(streamerID, entry)
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", publicProfileMaxAge))
+	c.Header("ETag", entry.etag)
+	if c.GetHeader("If-None-Match") == entry.etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", entry.body)
+}
+
+// buildPublicStreamerProfile 根据追踪列表与分析结果构建公开主页
+// 主播不存在或设置为不公开时返回 os.ErrNotExist
+func buildPublicStreamerProfile(streamerID string) (*PublicStreamerProfile, error) {
+	tracked, err := GetTrackedStreamerData()
+	if err != nil {
+		return nil, err
+	}
+
+	// 支持使用显示名称或曾用名访问
+	if resolved := ResolveStreamer(streamerID); resolved != nil {
+		streamerID = resolved.ID
+	}
+
+	var streamer *models.StreamerInfo
+	for i := range tracked.Streamers {
+		if strings.EqualFold(tracked.Streamers[i].ID, streamerID) {
+			streamer = &tracked.Streamers[i]
+			break
+		}
+	}
+	if streamer == nil || streamer.Visibility == "private" {
+		return nil, os.ErrNotExist
+	}
+
+	profile := &PublicStreamerProfile{
+		ID:              streamer.ID,
+		Name:            streamer.Name,
+		ProfileImageURL: streamer.ProfileImageURL,
+		Platforms:       []string{},
+		RecentVODs:      []PublicVODEntry{},
+		GeneratedAt:     time.Now(),
+	}
+	for _, p := range streamer.Platforms {
+		profile.Platforms = append(profile.Platforms, p.Platform)
+	}
+
+	// 主播认领主页后，按其设置隐藏热点或只展示已审核的热点
+	claim := GetStreamerClaim(streamer.ID)
+	results := loadStreamerAnalysisResults(streamer.ID)
+	for _, result := range results {
+		if len(profile.RecentVODs) >= publicProfileVODLimit {
+			break
+		}
+		if claim != nil {
+			result.HotMoments = claim.visibleMoments(result.VideoID, result.HotMoments)
+		}
+		result.HotMoments = withoutTakenDownClips(result.VideoID, result.HotMoments)
+		profile.RecentVODs = append(profile.RecentVODs, toPublicVODEntry(result))
+	}
+
+	return profile, nil
+}
+
+// loadStreamerAnalysisResults 读取主播所有录像的默认参数分析结果，按录像时间倒序排列
+// 仅返回公开可见的录像
+func loadStreamerAnalysisResults(streamerID string) []AnalysisResult {
+	return scanStreamerAnalysisResults(streamerID, false)
+}
+
+// loadAllStreamerAnalysisResults 与 loadStreamerAnalysisResults 相同，但包含订阅者专属等非公开录像
+func loadAllStreamerAnalysisResults(streamerID string) []AnalysisResult {
+	return scanStreamerAnalysisResults(streamerID, true)
+}
+
+func scanStreamerAnalysisResults(streamerID string, includeNonPublic bool) []AnalysisResult {
+	defaultFilename := analysisResultFileName(defaultPeakParams)
+
+	dirs, err := os.ReadDir("./analysis_results")
+	if err != nil {
+		return nil
+	}
+
+	var results []AnalysisResult
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join("./analysis_results", dir.Name(), defaultFilename))
+		if err != nil {
+			continue
+		}
+
+		var result AnalysisResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			continue
+		}
+
+		if !strings.EqualFold(result.StreamerName, streamerID) &&
+			!strings.EqualFold(result.VideoInfo.UserLogin, streamerID) {
+			continue
+		}
+
+		// 订阅者专属等非公开录像不对外展示
+		if !includeNonPublic && result.VideoInfo.Viewable != "" && result.VideoInfo.Viewable != "public" {
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		ti, tj := results[i].VideoInfo.CreatedAt, results[j].VideoInfo.CreatedAt
+		if ti == tj {
+			return results[i].AnalyzedAt.After(results[j].AnalyzedAt)
+		}
+		return ti > tj
+	})
+
+	return results
+}
+
+// toPublicVODEntry 将分析结果转换为公开录像条目，只保留得分最高的若干热点
+func toPublicVODEntry(result AnalysisResult) PublicVODEntry {
+	entry := PublicVODEntry{
+		VideoID:      result.VideoID,
+		Title:        result.VideoInfo.Title,
+		URL:          result.VideoInfo.URL,
+		ThumbnailURL: result.VideoInfo.ThumbnailURL,
+		CreatedAt:    result.VideoInfo.CreatedAt,
+		Duration:     result.VideoInfo.Duration,
+		TopMoments:   []PublicHotMoment{},
+	}
+
+	moments := make([]VodCommentData, len(result.HotMoments))
+	copy(moments, result.HotMoments)
+	sort.Slice(moments, func(i, j int) bool {
+		return moments[i].CommentsScore > moments[j].CommentsScore
+	})
+	if len(moments) > publicProfileMomentLimit {
+		moments = moments[:publicProfileMomentLimit]
+	}
+	// 按时间顺序展示
+	sort.Slice(moments, func(i, j int) bool {
+		return moments[i].OffsetSeconds < moments[j].OffsetSeconds
+	})
+
+	videoDir := pathsafe.Join("./analysis_results", result.VideoID)
+	for _, moment := range moments {
+		title, summary, reaction := "", "", ""
+		if !IsTakenDown(ArtifactSummary, result.VideoID, moment.OffsetSeconds) {
+			summary = readSummaryForOffset(videoDir, moment.OffsetSeconds)
+			reaction = readChatReactionForOffset(result.VideoID, moment.OffsetSeconds)
+			if moment.Metadata != nil {
+				title = moment.Metadata.Title
+			}
+		}
+		entry.TopMoments = append(entry.TopMoments, PublicHotMoment{
+			OffsetSeconds: moment.OffsetSeconds,
+			FormattedTime: moment.FormattedTime,
+			Title:         title,
+			Summary:       summary,
+			ChatReaction:  reaction,
+		})
+	}
+
+	return entry
+}
+
+// readSummaryForOffset 读取与热点偏移对应的AI总结（文件名为 {offset}_summary.txt），不存在时返回空
+func readSummaryForOffset(videoDir string, offsetSeconds float64) string {
+	summaryFiles, err := filepath.Glob(filepath.Join(videoDir, "*_summary.txt"))
+	if err != nil {
+		return ""
+	}
+
+	for _, file := range summaryFiles {
+		parts := strings.Split(filepath.Base(file), "_")
+		fileOffset, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			continue
+		}
+		if math.Abs(fileOffset-offsetSeconds) < 1 {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return ""
+			}
+			return strings.TrimSpace(string(content))
+		}
+	}
+	return ""
+}
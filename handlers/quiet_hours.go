@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const deferredJobsFile = "App_Data/deferred_jobs.json"
+
+// 延后任务类型
+const (
+	deferredJobHotClips     = "hot_clips"     // 热点片段下载（包含语音识别与AI总结）
+	deferredJobSummaryRetry = "summary_retry" // AI 服务繁忙时推迟的热点总结
+)
+
+// DeferredJob 静默时段内被推迟执行的重负载任务
+type DeferredJob struct {
+	ID         string           `json:"id"`
+	Kind       string           `json:"kind"`
+	VideoID    string           `json:"video_id"`
+	HotMoments []VodCommentData `json:"hot_moments,omitempty"`
+	Interval   float64          `json:"interval,omitempty"`
+	// OffsetSeconds/Attempts 推迟的热点总结对应的热点与已推迟次数
+	OffsetSeconds float64 `json:"offset_seconds,omitempty"`
+	Attempts      int     `json:"attempts,omitempty"`
+	// NotBefore 任务最早的执行时间，为空时静默时段结束即可执行
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// WaitFor 任务等待恢复的依赖（ffmpeg/asr），依赖可用后才执行
+	WaitFor   string    `json:"wait_for,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var deferredJobsMu sync.Mutex
+
+// quietWindow 一个静默时段，以当天的分钟数表示
+type quietWindow struct {
+	start, end int
+}
+
+// parseQuietWindow 解析 "HH:MM-HH:MM" 格式的时段
+func parseQuietWindow(s string) (quietWindow, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 2 {
+		return quietWindow{}, fmt.Errorf("静默时段格式错误: %s", s)
+	}
+
+	var w quietWindow
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return quietWindow{}, fmt.Errorf("静默时段格式错误: %s", s)
+		}
+		minutes := t.Hour()*60 + t.Minute()
+		if i == 0 {
+			w.start = minutes
+		} else {
+			w.end = minutes
+		}
+	}
+	if w.start == w.end {
+		return quietWindow{}, fmt.Errorf("静默时段开始与结束时间不能相同: %s", s)
+	}
+	return w, nil
+}
+
+// contains 判断当天的分钟数是否落在时段内，结束时间早于开始时间表示跨越午夜
+func (w quietWindow) contains(minutes int) bool {
+	if w.start < w.end {
+		return minutes >= w.start && minutes < w.end
+	}
+	return minutes >= w.start || minutes < w.end
+}
+
+// location 返回配置的时区，为空时使用本地时区
+func (c QuietHoursConfig) location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+// Validate checks the timezone and window syntax
+func (c QuietHoursConfig) Validate() error {
+	if _, err := c.location(); err != nil {
+		return fmt.Errorf("无效的静默时段时区: %w", err)
+	}
+	for _, s := range c.Windows {
+		if _, err := parseQuietWindow(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Active 判断给定时间是否处于任一静默时段内
+func (c QuietHoursConfig) Active(t time.Time) bool {
+	if !c.Enabled || len(c.Windows) == 0 {
+		return false
+	}
+
+	loc, err := c.location()
+	if err != nil {
+		return false
+	}
+	local := t.In(loc)
+	minutes := local.Hour()*60 + local.Minute()
+
+	for _, s := range c.Windows {
+		w, err := parseQuietWindow(s)
+		if err != nil {
+			continue
+		}
+		if w.contains(minutes) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsQuietHours 当前配置下给定时间是否处于静默时段
+func IsQuietHours(t time.Time) bool {
+	return GetQuietHoursConfig().Active(t)
+}
+
+// StartDeferredJobRunner 启动后台任务，静默时段结束后依次执行延后队列中的任务
+// 检查计划见配置 schedule.jobs.deferred_jobs（默认每分钟）
+func StartDeferredJobRunner() {
+	scheduleJob(scheduleJobDeferred, func() {
+		if IsQuietHours(time.Now()) {
+			return
+		}
+		drainDeferredJobs()
+	})
+}
+
+// drainDeferredJobs 依次取出并执行队列中的任务，期间进入静默时段则停止
+func drainDeferredJobs() {
+	for !IsQuietHours(time.Now()) {
+		job, ok, err := popDeferredJob("")
+		if err != nil {
+			log.Printf("读取延后任务队列失败: %v", err)
+			return
+		}
+		if !ok {
+			return
+		}
+		executeDeferredJob(job)
+	}
+}
+
+// executeDeferredJob 执行单个延后任务
+func executeDeferredJob(job DeferredJob) {
+	log.Printf("开始执行延后任务 %s (%s, 视频 %s)", job.ID, job.Kind, job.VideoID)
+
+	switch job.Kind {
+	case deferredJobHotClips:
+		runHotClipsJob(job.VideoID, job.HotMoments, job.Interval)
+	case deferredJobSummaryRetry:
+		runDeferredSummaryRetry(job)
+	default:
+		log.Printf("未知的延后任务类型: %s，已丢弃", job.Kind)
+	}
+}
+
+// enqueueDeferredJob 将任务加入延后队列并持久化
+func enqueueDeferredJob(job DeferredJob) (DeferredJob, error) {
+	deferredJobsMu.Lock()
+	defer deferredJobsMu.Unlock()
+
+	jobs, err := loadDeferredJobs()
+	if err != nil {
+		return job, err
+	}
+
+	job.CreatedAt = time.Now()
+	job.ID = fmt.Sprintf("%s_%s_%d", job.Kind, job.VideoID, job.CreatedAt.UnixNano())
+	jobs = append(jobs, job)
+
+	return job, saveDeferredJobs(jobs)
+}
+
+// popDeferredJob 从队列中移除并返回指定任务，id 为空时返回最早的已到执行时间的任务
+func popDeferredJob(id string) (DeferredJob, bool, error) {
+	deferredJobsMu.Lock()
+	defer deferredJobsMu.Unlock()
+
+	jobs, err := loadDeferredJobs()
+	if err != nil {
+		return DeferredJob{}, false, err
+	}
+
+	now := time.Now()
+	for i, job := range jobs {
+		if id != "" && job.ID != id {
+			continue
+		}
+		if id == "" && job.NotBefore != nil && job.NotBefore.After(now) {
+			continue
+		}
+		if id == "" && job.WaitFor != "" && !capabilityAvailable(job.WaitFor) {
+			continue
+		}
+		jobs = append(jobs[:i], jobs[i+1:]...)
+		if err := saveDeferredJobs(jobs); err != nil {
+			return DeferredJob{}, false, err
+		}
+		return job, true, nil
+	}
+	return DeferredJob{}, false, nil
+}
+
+// ListDeferredJobs 返回当前延后队列中的任务
+func ListDeferredJobs() ([]DeferredJob, error) {
+	deferredJobsMu.Lock()
+	defer deferredJobsMu.Unlock()
+	return loadDeferredJobs()
+}
+
+// RunDeferredJobNow 忽略静默时段立即执行指定任务，任务不存在时返回 os.ErrNotExist
+func RunDeferredJobNow(id string) (DeferredJob, error) {
+	job, ok, err := popDeferredJob(id)
+	if err != nil {
+		return DeferredJob{}, err
+	}
+	if !ok {
+		return DeferredJob{}, os.ErrNotExist
+	}
+
+	go executeDeferredJob(job)
+	return job, nil
+}
+
+// loadDeferredJobs 读取持久化的延后队列，文件不存在时返回空
+func loadDeferredJobs() ([]DeferredJob, error) {
+	data, err := os.ReadFile(deferredJobsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []DeferredJob{}, nil
+		}
+		return nil, err
+	}
+
+	var jobs []DeferredJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// saveDeferredJobs 将延后队列写回文件
+func saveDeferredJobs(jobs []DeferredJob) error {
+	if err := os.MkdirAll(filepath.Dir(deferredJobsFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(deferredJobsFile, data, 0644)
+}
@@ -0,0 +1,494 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"subtuber-services/pathsafe"
+)
+
+const (
+	researchKeysFile = "App_Data/research_api_keys.json"
+
+	// ResearchAPIKeyHeader 研究接口的 API Key 请求头，也可使用 Authorization: Bearer
+	ResearchAPIKeyHeader = "X-API-Key"
+
+	researchKeyPrefix = "lrk_"
+
+	// 默认限额，签发时可单独指定
+	defaultResearchPerMinute = 30
+	defaultResearchPerDay    = 5000
+
+	// researchUsageSaveInterval 调用统计写盘的最短间隔
+	researchUsageSaveInterval = time.Minute
+
+	// researchAttribution 研究接口返回数据的署名要求，每个响应都会附带
+	researchAttribution = "数据来自 LumiTime 聊天分析。发表或公开使用时须注明来源并链接原始录像；数据仅供研究使用，不得用于识别或追踪个人。"
+)
+
+// ResearchAPIKey 为研究人员签发的只读 API Key，文件中只保存哈希
+type ResearchAPIKey struct {
+	ID                string     `json:"id"`
+	KeyHash           string     `json:"key_hash"`
+	KeyPrefix         string     `json:"key_prefix"` // 便于识别的前几位
+	Name              string     `json:"name"`
+	Organization      string     `json:"organization"`
+	Email             string     `json:"email"`
+	Purpose           string     `json:"purpose"`
+	RequestsPerMinute int        `json:"requests_per_minute"`
+	RequestsPerDay    int        `json:"requests_per_day"`
+	CreatedAt         time.Time  `json:"created_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+	TotalRequests     int64      `json:"total_requests"`
+	UsageDate         string     `json:"usage_date"` // UsageToday 对应的日期（UTC）
+	UsageToday        int        `json:"usage_today"`
+
+	minuteStart time.Time
+	minuteCount int
+}
+
+var (
+	researchKeysMu      sync.Mutex
+	researchKeys        []*ResearchAPIKey
+	researchKeysLoaded  bool
+	researchKeysSavedAt time.Time
+	researchKeysDirty   bool
+)
+
+// researchAudit 写入研究接口 Key 的审计日志
+func researchAudit(action string, key *ResearchAPIKey, detail string) {
+	_ = appendErrorLog("research-api-audit.log", fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\n",
+		time.Now().UTC().Format(time.RFC3339), action, key.ID, key.Name, key.Organization, detail))
+}
+
+// loadResearchKeysLocked 首次使用时读取已签发的 Key，调用方需持有 researchKeysMu
+func loadResearchKeysLocked() {
+	if researchKeysLoaded {
+		return
+	}
+	researchKeysLoaded = true
+	data, err := os.ReadFile(researchKeysFile)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &researchKeys); err != nil {
+		log.Printf("读取研究接口 API Key 失败: %v", err)
+	}
+}
+
+// saveResearchKeysLocked 写回已签发的 Key，调用方需持有 researchKeysMu
+func saveResearchKeysLocked() error {
+	if err := os.MkdirAll(filepath.Dir(researchKeysFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(researchKeys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(researchKeysFile, data, 0600); err != nil {
+		return err
+	}
+	researchKeysSavedAt = time.Now()
+	researchKeysDirty = false
+	return nil
+}
+
+// researchKeyFromRequest 读取请求中的 API Key
+func researchKeyFromRequest(c *gin.Context) string {
+	if key := strings.TrimSpace(c.GetHeader(ResearchAPIKeyHeader)); key != "" {
+		return key
+	}
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return ""
+}
+
+// ResearchAPIKeyMiddleware 校验研究接口的 API Key 并执行每分钟与每天的调用限额
+// 所有响应附带限额与署名要求的响应头
+func ResearchAPIKeyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("X-Data-Attribution", "LumiTime")
+
+		provided := researchKeyFromRequest(c)
+		if provided == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "需要在 " + ResearchAPIKeyHeader + " 请求头中提供 API Key"})
+			return
+		}
+		hash := computeSha256Hex(provided)
+		now := time.Now()
+
+		researchKeysMu.Lock()
+		loadResearchKeysLocked()
+		var key *ResearchAPIKey
+		for _, k := range researchKeys {
+			if k.KeyHash == hash {
+				key = k
+				break
+			}
+		}
+		if key == nil || key.RevokedAt != nil {
+			researchKeysMu.Unlock()
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API Key 无效或已吊销"})
+			return
+		}
+
+		today := now.UTC().Format(usageDateLayout)
+		if key.UsageDate != today {
+			key.UsageDate, key.UsageToday = today, 0
+		}
+		if now.Sub(key.minuteStart) >= time.Minute {
+			key.minuteStart, key.minuteCount = now, 0
+		}
+
+		var retryAfter time.Duration
+		var limit, remaining int
+		switch {
+		case key.UsageToday >= key.RequestsPerDay:
+			tomorrow := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+			retryAfter, limit = tomorrow.Sub(now), key.RequestsPerDay
+		case key.minuteCount >= key.RequestsPerMinute:
+			retryAfter, limit = key.minuteStart.Add(time.Minute).Sub(now), key.RequestsPerMinute
+		default:
+			key.minuteCount++
+			key.UsageToday++
+			key.TotalRequests++
+			key.LastUsedAt = &now
+			researchKeysDirty = true
+			limit, remaining = key.RequestsPerMinute, key.RequestsPerMinute-key.minuteCount
+		}
+		resetAt := key.minuteStart.Add(time.Minute)
+		dailyRemaining := key.RequestsPerDay - key.UsageToday
+		if researchKeysDirty && now.Sub(researchKeysSavedAt) >= researchUsageSaveInterval {
+			if err := saveResearchKeysLocked(); err != nil {
+				log.Printf("保存研究接口调用统计失败: %v", err)
+			}
+		}
+		researchKeysMu.Unlock()
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+		c.Header("X-RateLimit-Daily-Remaining", strconv.Itoa(dailyRemaining))
+		if retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "已超过调用限额，请稍后再试"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RegisterResearchRoutes 注册面向研究人员的只读接口（/research/v1），需要签发的 API Key
+// 只返回公开主播的公开录像的聚合数据，不包含任何用户数据与聊天内容
+func RegisterResearchRoutes(r gin.IRouter) {
+	g := r.Group("/research/v1", ResearchAPIKeyMiddleware())
+	g.GET("/streamers", researchListStreamersHandler)
+	g.GET("/streamers/:id/analyses", researchListAnalysesHandler)
+	g.GET("/analyses/:videoID", researchGetAnalysisHandler)
+	g.GET("/analyses/:videoID/chat-stats", researchChatStatsHandler)
+}
+
+// researchJSON 返回数据并附带署名要求
+func researchJSON(c *gin.Context, body gin.H) {
+	body["attribution"] = researchAttribution
+	c.JSON(http.StatusOK, body)
+}
+
+// researchPublicAnalysis 读取公开主播的公开录像的默认参数分析结果，不公开时返回 os.ErrNotExist
+func researchPublicAnalysis(videoID string) (*AnalysisResult, error) {
+	data, err := os.ReadFile(filepath.Join(pathsafe.Join("./analysis_results", videoID), analysisResultFileName(defaultPeakParams)))
+	if err != nil {
+		return nil, err
+	}
+	var result AnalysisResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	streamer := ResolveStreamer(result.StreamerName)
+	if streamer == nil || streamer.Visibility == "private" {
+		return nil, os.ErrNotExist
+	}
+	if result.VideoInfo.Viewable != "" && result.VideoInfo.Viewable != "public" {
+		return nil, os.ErrNotExist
+	}
+
+	moments := result.HotMoments
+	if claim := GetStreamerClaim(streamer.ID); claim != nil {
+		moments = claim.visibleMoments(result.VideoID, moments)
+	}
+	result.HotMoments = withoutTakenDownClips(result.VideoID, moments)
+	result.StreamerName = streamer.ID
+	return &result, nil
+}
+
+// researchAnalysisEntry 研究接口中的录像分析概要
+type researchAnalysisEntry struct {
+	VideoID    string          `json:"video_id"`
+	Title      string          `json:"title"`
+	URL        string          `json:"url,omitempty"`
+	CreatedAt  string          `json:"created_at,omitempty"`
+	Duration   string          `json:"duration,omitempty"`
+	Method     string          `json:"method"`
+	Stats      VodCommentStats `json:"stats"`
+	HotMoments int             `json:"hot_moments"`
+	AnalyzedAt time.Time       `json:"analyzed_at"`
+}
+
+// researchListStreamersHandler 公开主播列表
+// GET /research/v1/streamers
+func researchListStreamersHandler(c *gin.Context) {
+	config, err := GetTrackedStreamerData()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取主播列表失败"})
+		return
+	}
+
+	streamers := []gin.H{}
+	for _, s := range config.Streamers {
+		if s.Visibility == "private" {
+			continue
+		}
+		platforms := make([]string, 0, len(s.Platforms))
+		for _, p := range s.Platforms {
+			platforms = append(platforms, p.Platform)
+		}
+		streamers = append(streamers, gin.H{"id": s.ID, "name": s.Name, "platforms": platforms})
+	}
+	sort.Slice(streamers, func(i, j int) bool { return streamers[i]["id"].(string) < streamers[j]["id"].(string) })
+	researchJSON(c, gin.H{"streamers": streamers})
+}
+
+// researchListAnalysesHandler 公开主播的公开录像分析列表，按录像时间倒序
+// GET /research/v1/streamers/:id/analyses
+func researchListAnalysesHandler(c *gin.Context) {
+	streamer := ResolveStreamer(c.Param("id"))
+	if streamer == nil || streamer.Visibility == "private" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主播不存在或未公开"})
+		return
+	}
+
+	analyses := []researchAnalysisEntry{}
+	for _, result := range loadStreamerAnalysisResults(streamer.ID) {
+		analyses = append(analyses, researchAnalysisEntry{
+			VideoID:    result.VideoID,
+			Title:      result.VideoInfo.Title,
+			URL:        result.VideoInfo.URL,
+			CreatedAt:  result.VideoInfo.CreatedAt,
+			Duration:   result.VideoInfo.Duration,
+			Method:     result.Method,
+			Stats:      result.Stats,
+			HotMoments: len(result.HotMoments),
+			AnalyzedAt: result.AnalyzedAt,
+		})
+	}
+	researchJSON(c, gin.H{"streamer_id": streamer.ID, "analyses": analyses})
+}
+
+// researchGetAnalysisHandler 录像的分析结果：热点时刻、统计、冷场片段与章节
+// GET /research/v1/analyses/:videoID
+func researchGetAnalysisHandler(c *gin.Context) {
+	result, err := researchPublicAnalysis(c.Param("videoID"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "未找到该录像的公开分析结果"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取分析结果失败"})
+		return
+	}
+
+	researchJSON(c, gin.H{
+		"video_id":    result.VideoID,
+		"streamer_id": result.StreamerName,
+		"title":       result.VideoInfo.Title,
+		"url":         result.VideoInfo.URL,
+		"created_at":  result.VideoInfo.CreatedAt,
+		"duration":    result.VideoInfo.Duration,
+		"method":      result.Method,
+		"stats":       result.Stats,
+		"hot_moments": result.HotMoments,
+		"skip_ranges": result.SkipRanges,
+		"chapters":    result.Chapters,
+		"analyzed_at": result.AnalyzedAt,
+	})
+}
+
+// researchChatStatsHandler 录像每分钟的弹幕数，只提供聚合计数，不返回聊天内容与用户名
+// GET /research/v1/analyses/:videoID/chat-stats
+func researchChatStatsHandler(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+	if _, err := researchPublicAnalysis(videoID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未找到该录像的公开分析结果"})
+		return
+	}
+
+	offsets, err := loadChatOffsetsForVideo(videoID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未找到该录像的聊天记录"})
+		return
+	}
+
+	perMinute := []int{}
+	for _, offset := range offsets {
+		if offset < 0 {
+			continue
+		}
+		minute := int(offset / 60)
+		for len(perMinute) <= minute {
+			perMinute = append(perMinute, 0)
+		}
+		perMinute[minute]++
+	}
+
+	researchJSON(c, gin.H{
+		"video_id":            videoID,
+		"total_messages":      len(offsets),
+		"messages_per_minute": perMinute,
+	})
+}
+
+// issueResearchKeyRequest 签发研究接口 API Key 的请求
+type issueResearchKeyRequest struct {
+	Name              string `json:"name" binding:"required"`
+	Organization      string `json:"organization" binding:"required"`
+	Email             string `json:"email" binding:"required"`
+	Purpose           string `json:"purpose" binding:"required"` // 研究用途，用于审核与审计
+	RequestsPerMinute int    `json:"requests_per_minute"`
+	RequestsPerDay    int    `json:"requests_per_day"`
+}
+
+// issueResearchKeyHandler 管理员为研究人员签发 API Key，Key 只在签发时返回一次
+// POST /api/admin/research-keys
+func issueResearchKeyHandler(c *gin.Context) {
+	var req issueResearchKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "需要提供 name、organization、email 与 purpose"})
+		return
+	}
+	if req.RequestsPerMinute < 0 || req.RequestsPerDay < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "调用限额不能为负数"})
+		return
+	}
+	if req.RequestsPerMinute == 0 {
+		req.RequestsPerMinute = defaultResearchPerMinute
+	}
+	if req.RequestsPerDay == 0 {
+		req.RequestsPerDay = defaultResearchPerDay
+	}
+
+	secret := make([]byte, 24)
+	idBytes := make([]byte, 6)
+	if _, err := rand.Read(secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "生成 API Key 失败"})
+		return
+	}
+	if _, err := rand.Read(idBytes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "生成 API Key 失败"})
+		return
+	}
+	token := researchKeyPrefix + hex.EncodeToString(secret)
+
+	key := &ResearchAPIKey{
+		ID:                "rk_" + hex.EncodeToString(idBytes),
+		KeyHash:           computeSha256Hex(token),
+		KeyPrefix:         token[:len(researchKeyPrefix)+6],
+		Name:              strings.TrimSpace(req.Name),
+		Organization:      strings.TrimSpace(req.Organization),
+		Email:             strings.TrimSpace(req.Email),
+		Purpose:           strings.TrimSpace(req.Purpose),
+		RequestsPerMinute: req.RequestsPerMinute,
+		RequestsPerDay:    req.RequestsPerDay,
+		CreatedAt:         time.Now(),
+	}
+
+	researchKeysMu.Lock()
+	loadResearchKeysLocked()
+	researchKeys = append(researchKeys, key)
+	err := saveResearchKeysLocked()
+	if err != nil {
+		researchKeys = researchKeys[:len(researchKeys)-1]
+	}
+	snapshot := *key
+	researchKeysMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "保存 API Key 失败: " + err.Error()})
+		return
+	}
+
+	researchAudit("ISSUE", &snapshot, snapshot.Purpose)
+	log.Printf("已为 %s (%s) 签发研究接口 API Key %s", snapshot.Name, snapshot.Organization, snapshot.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"key":         snapshot,
+		"api_key":     token,
+		"attribution": researchAttribution,
+	})
+}
+
+// listResearchKeysHandler 管理员查看已签发的 API Key 与调用统计
+// GET /api/admin/research-keys
+func listResearchKeysHandler(c *gin.Context) {
+	researchKeysMu.Lock()
+	loadResearchKeysLocked()
+	keys := make([]ResearchAPIKey, 0, len(researchKeys))
+	for _, k := range researchKeys {
+		keys = append(keys, *k)
+	}
+	researchKeysMu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	c.JSON(http.StatusOK, gin.H{"success": true, "keys": keys})
+}
+
+// revokeResearchKeyHandler 吊销 API Key，吊销后立即失效
+// DELETE /api/admin/research-keys/:id
+func revokeResearchKeyHandler(c *gin.Context) {
+	id := c.Param("id")
+
+	researchKeysMu.Lock()
+	loadResearchKeysLocked()
+	var key *ResearchAPIKey
+	for _, k := range researchKeys {
+		if k.ID == id {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		researchKeysMu.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "API Key 不存在"})
+		return
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+	}
+	err := saveResearchKeysLocked()
+	snapshot := *key
+	researchKeysMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "保存 API Key 失败: " + err.Error()})
+		return
+	}
+
+	researchAudit("REVOKE", &snapshot, "")
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "API Key 已吊销"})
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
+)
+
+// 定时任务名称，对应配置 schedule.jobs 中的键
+const (
+	scheduleJobCleanup        = "cleanup"         // 清理无订阅主播
+	scheduleJobPersistence    = "persistence"     // 主播数据持久化
+	scheduleJobDigest         = "digest"          // 检查到期的通知摘要邮件
+	scheduleJobDeferred       = "deferred_jobs"   // 静默时段结束后执行延后的任务
+	scheduleJobStorageTiering = "storage_tiering" // 旧片段移到冷存储
+	scheduleJobRetention      = "retention"       // 按保留策略清理聊天记录、下载与分析结果
+)
+
+// scheduleJobDefaults 各定时任务的默认计划，未在配置中指定时使用
+var scheduleJobDefaults = map[string]string{
+	scheduleJobCleanup:        "0 2 * * *",
+	scheduleJobPersistence:    "@every 5m",
+	scheduleJobDigest:         "@every 1h",
+	scheduleJobDeferred:       "@every 1m",
+	scheduleJobStorageTiering: "@every 6h",
+	scheduleJobRetention:      "@every 1h",
+}
+
+// scheduledJob 已注册的定时任务
+type scheduledJob struct {
+	run     func()
+	entryID cron.EntryID
+}
+
+var (
+	// schedulerMu 保护调度器与已注册的任务，配置变更时整体重建调度器
+	schedulerMu   sync.Mutex
+	scheduler     *cron.Cron
+	scheduledJobs = map[string]*scheduledJob{}
+)
+
+// location 计划使用的时区，cron 按该时区的本地时间计算触发时刻，夏令时切换时不会漂移
+func (c ScheduleConfig) location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+// spec 任务的 cron 表达式，未配置时使用默认计划
+func (c ScheduleConfig) spec(name string) string {
+	if spec := strings.TrimSpace(c.Jobs[name]); spec != "" {
+		return spec
+	}
+	return scheduleJobDefaults[name]
+}
+
+// Validate checks the timezone, that every configured job exists and that every expression parses
+func (c ScheduleConfig) Validate() error {
+	if _, err := c.location(); err != nil {
+		return fmt.Errorf("无效的定时任务时区: %w", err)
+	}
+	for name, spec := range c.Jobs {
+		if _, ok := scheduleJobDefaults[name]; !ok {
+			return fmt.Errorf("未知的定时任务: %s", name)
+		}
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		if _, err := cron.ParseStandard(spec); err != nil {
+			return fmt.Errorf("定时任务 %s 的 cron 表达式无效: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// scheduleJob 注册定时任务并按当前配置加入调度器，同名任务会被替换
+// 上一次执行尚未结束时跳过本次触发，任务 panic 不影响其他任务
+func scheduleJob(name string, run func()) {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+
+	if job, ok := scheduledJobs[name]; ok && scheduler != nil {
+		scheduler.Remove(job.entryID)
+	}
+	job := &scheduledJob{run: run}
+	scheduledJobs[name] = job
+
+	if scheduler == nil {
+		rebuildSchedulerLocked()
+		return
+	}
+	addScheduledJobLocked(name, job, GetScheduleConfig())
+}
+
+// unscheduleJob 移除定时任务
+func unscheduleJob(name string) {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+
+	if job, ok := scheduledJobs[name]; ok {
+		if scheduler != nil {
+			scheduler.Remove(job.entryID)
+		}
+		delete(scheduledJobs, name)
+	}
+}
+
+// applyScheduleConfig 按新的时区与表达式重建调度器，配置热加载后调用
+func applyScheduleConfig() {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	if scheduler != nil {
+		rebuildSchedulerLocked()
+	}
+}
+
+// rebuildSchedulerLocked 停止旧调度器并按当前配置创建新调度器，调用方需持有 schedulerMu
+// 旧调度器中正在执行的任务会继续执行完
+func rebuildSchedulerLocked() {
+	if scheduler != nil {
+		scheduler.Stop()
+	}
+
+	cfg := GetScheduleConfig()
+	loc, err := cfg.location()
+	if err != nil {
+		log.Printf("定时任务时区无效，使用服务器本地时区: %v", err)
+		loc = time.Local
+	}
+	logger := cron.PrintfLogger(log.Default())
+	scheduler = cron.New(
+		cron.WithLocation(loc),
+		cron.WithLogger(logger),
+		cron.WithChain(cron.Recover(logger), cron.SkipIfStillRunning(logger)),
+	)
+	for name, job := range scheduledJobs {
+		addScheduledJobLocked(name, job, cfg)
+	}
+	scheduler.Start()
+}
+
+// addScheduledJobLocked 将任务加入调度器，配置的表达式无效时退回默认计划
+func addScheduledJobLocked(name string, job *scheduledJob, cfg ScheduleConfig) {
+	spec := cfg.spec(name)
+	id, err := scheduler.AddFunc(spec, job.run)
+	if err != nil {
+		log.Printf("定时任务 %s 的计划 %q 无效，使用默认计划: %v", name, spec, err)
+		spec = scheduleJobDefaults[name]
+		if id, err = scheduler.AddFunc(spec, job.run); err != nil {
+			log.Printf("无法调度定时任务 %s: %v", name, err)
+			return
+		}
+	}
+	job.entryID = id
+	next := scheduler.Entry(id).Schedule.Next(time.Now().In(scheduler.Location()))
+	log.Printf("定时任务 %s 的计划: %s，下次执行: %s", name, spec, next.Format("2006-01-02 15:04:05 MST"))
+}
+
+// scheduledJobInfo 定时任务列表项
+type scheduledJobInfo struct {
+	Name    string     `json:"name"`
+	Spec    string     `json:"spec"`
+	Default bool       `json:"default"` // 是否使用默认计划
+	Next    *time.Time `json:"next,omitempty"`
+	Prev    *time.Time `json:"prev,omitempty"`
+}
+
+// listScheduledJobsHandler 管理员查看定时任务的计划与下次执行时间
+func listScheduledJobsHandler(c *gin.Context) {
+	cfg := GetScheduleConfig()
+	loc, _ := cfg.location()
+	if loc == nil {
+		loc = time.Local
+	}
+
+	schedulerMu.Lock()
+	jobs := make([]scheduledJobInfo, 0, len(scheduledJobs))
+	for name, job := range scheduledJobs {
+		info := scheduledJobInfo{
+			Name:    name,
+			Spec:    cfg.spec(name),
+			Default: strings.TrimSpace(cfg.Jobs[name]) == "",
+		}
+		if scheduler != nil {
+			entry := scheduler.Entry(job.entryID)
+			if !entry.Next.IsZero() {
+				next := entry.Next
+				info.Next = &next
+			}
+			if !entry.Prev.IsZero() {
+				prev := entry.Prev
+				info.Prev = &prev
+			}
+		}
+		jobs = append(jobs, info)
+	}
+	schedulerMu.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"timezone": loc.String(),
+		"jobs":     jobs,
+	})
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"subtuber-services/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// SessionCookieName 保存会话令牌（JWT）的 Cookie，非浏览器客户端可改用 Authorization: Bearer 请求头
+	SessionCookieName = "SessionToken"
+	// legacyUserCookieName 旧版本写入的明文用户信息 Cookie，可被客户端伪造，仅在过渡期内接受
+	legacyUserCookieName = "UserInfo"
+
+	defaultSessionTTL = 7 * 24 * time.Hour
+
+	// maxLegacyCookieWindow legacy_cookie_until 最多可设置到当前时间之后的天数
+	maxLegacyCookieWindow = 90 * 24 * time.Hour
+
+	// userHashContextKey 已认证的用户 hash 在 gin.Context 中的键
+	userHashContextKey = "userHash"
+	// impersonatedContextKey 请求的用户身份来自已校验的代入令牌时为 true
+	impersonatedContextKey = "impersonated"
+	// impersonationErrContextKey 代入令牌被拒绝时保存拒绝原因，同一请求内不再重复校验
+	impersonationErrContextKey = "impersonationErr"
+)
+
+var authLog = logging.For("auth")
+
+// sessionTokenHeader 会话令牌的 JWT 头部，只签发与接受 HS256，避免 alg=none 等降级
+var sessionTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+var (
+	errNoSession      = errors.New("未登录")
+	errInvalidSession = errors.New("会话令牌无效")
+	errSessionExpired = errors.New("会话已过期")
+	errLegacySession  = errors.New("旧版登录信息已停止使用，请重新登录")
+)
+
+// sessionClaims 会话令牌中的声明
+type sessionClaims struct {
+	Subject   string `json:"sub"` // userHash
+	Email     string `json:"email,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// sessionTTL 返回会话令牌的有效期
+func (c AuthConfig) sessionTTL() time.Duration {
+	if c.SessionTTLHours <= 0 {
+		return defaultSessionTTL
+	}
+	return time.Duration(c.SessionTTLHours) * time.Hour
+}
+
+// sessionSigningKey 返回会话令牌的签名密钥（auth.session_secret，启动时校验必须配置）
+func (c AuthConfig) sessionSigningKey() []byte {
+	return []byte(c.SessionSecret)
+}
+
+// legacyCookieAccepted 是否仍接受旧的 UserInfo Cookie：只在明确配置了 legacy_cookie_until 时接受，当天仍然接受
+func (c AuthConfig) legacyCookieAccepted(now time.Time) bool {
+	if c.LegacyCookieUntil == "" {
+		return false
+	}
+	until, err := time.ParseInLocation(time.DateOnly, c.LegacyCookieUntil, time.Local)
+	return err == nil && now.Before(until.AddDate(0, 0, 1))
+}
+
+// signSessionToken 签发 HS256 JWT
+func signSessionToken(cfg AuthConfig, claims sessionClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := sessionTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, cfg.sessionSigningKey())
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// parseSessionToken 校验会话令牌的签名与有效期
+func parseSessionToken(cfg AuthConfig, token string, now time.Time) (sessionClaims, error) {
+	var claims sessionClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != sessionTokenHeader {
+		return claims, errInvalidSession
+	}
+
+	mac := hmac.New(sha256.New, cfg.sessionSigningKey())
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, mac.Sum(nil)) {
+		return claims, errInvalidSession
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &claims) != nil || claims.Subject == "" {
+		return sessionClaims{}, errInvalidSession
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return sessionClaims{}, errSessionExpired
+	}
+	return claims, nil
+}
+
+// setSessionCookie 为用户签发会话令牌并写入 Cookie，同时清除旧的 UserInfo Cookie
+func setSessionCookie(c *gin.Context, user userModel) (string, time.Time, error) {
+	cfg := GetAuthConfig()
+	now := time.Now()
+	expiresAt := now.Add(cfg.sessionTTL())
+	token, err := signSessionToken(cfg, sessionClaims{
+		Subject:   user.UserId,
+		Email:     user.Email,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	c.SetCookie(SessionCookieName, token, int(cfg.sessionTTL().Seconds()), "/", "", true, true)
+	if _, err := c.Cookie(legacyUserCookieName); err == nil {
+		c.SetCookie(legacyUserCookieName, "", -1, "/", "", true, true)
+	}
+	return token, expiresAt, nil
+}
+
+// clearSessionCookies 清除会话令牌与旧的 UserInfo Cookie
+func clearSessionCookies(c *gin.Context) {
+	// MaxAge=-1 表示立即删除 cookie
+	c.SetCookie(SessionCookieName, "", -1, "/", "", true, true)
+	c.SetCookie(legacyUserCookieName, "", -1, "/", "", true, true)
+}
+
+// requestSessionToken 请求中的会话令牌，Authorization: Bearer 优先，其次是 Cookie
+func requestSessionToken(c *gin.Context) string {
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	token, _ := c.Cookie(SessionCookieName)
+	return token
+}
+
+// sessionUser 校验请求中的会话令牌并返回其中的声明
+// 没有会话令牌时，过渡期内仍接受旧的 UserInfo Cookie（仅限本地已有资料的用户）；旧 Cookie 可被伪造，不会换发会话令牌，用户需重新登录
+func sessionUser(c *gin.Context) (sessionClaims, error) {
+	cfg := GetAuthConfig()
+	now := time.Now()
+	if token := requestSessionToken(c); token != "" {
+		return parseSessionToken(cfg, token, now)
+	}
+
+	legacy, err := c.Cookie(legacyUserCookieName)
+	if err != nil || legacy == "" {
+		return sessionClaims{}, errNoSession
+	}
+	if !cfg.legacyCookieAccepted(now) {
+		c.SetCookie(legacyUserCookieName, "", -1, "/", "", true, true)
+		return sessionClaims{}, errLegacySession
+	}
+
+	var cookieUser struct {
+		UserId string `json:"userId"`
+	}
+	if err := json.Unmarshal([]byte(legacy), &cookieUser); err != nil || cookieUser.UserId == "" {
+		return sessionClaims{}, errInvalidSession
+	}
+	user, err := loadUserModel(cookieUser.UserId)
+	if err != nil {
+		return sessionClaims{}, errInvalidSession
+	}
+
+	c.Header("Deprecation", "true")
+	authLog.WarnContext(c.Request.Context(), "使用旧版 UserInfo Cookie 访问", "user", user.UserId)
+	return sessionClaims{Subject: user.UserId, Email: user.Email}, nil
+}
+
+// RequireSession rejects requests that carry neither a valid session token nor an impersonation token
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := getUserHashFromCookie(c); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "未登录或登录已过期",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// refreshSessionHandler 换发会话令牌以延长有效期，令牌已过期或只有旧的 UserInfo Cookie 时需要重新登录
+// POST /api/auth/refresh
+func refreshSessionHandler(c *gin.Context) {
+	token := requestSessionToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "未登录或登录已过期"})
+		return
+	}
+	claims, err := parseSessionToken(GetAuthConfig(), token, time.Now())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "未登录或登录已过期"})
+		return
+	}
+
+	user, err := loadUserModel(claims.Subject)
+	if err != nil {
+		user = userModel{UserId: claims.Subject, Email: claims.Email}
+	}
+	token, expiresAt, err := setSessionCookie(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "签发会话令牌失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    "会话已更新",
+		"token":      token,
+		"expires_at": expiresAt.UTC(),
+	})
+}
@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"subtuber-services/pathsafe"
+)
+
+// shadowMinMatchSeconds 判断两个热点为同一热点的最小时间容差
+const shadowMinMatchSeconds = 60
+
+// ShadowRun 一个算法版本在录像上的分析结果
+type ShadowRun struct {
+	Version    string              `json:"version"`
+	Params     PeakDetectionParams `json:"params"`
+	HotMoments []VodCommentData    `json:"hot_moments"`
+	DurationMs int64               `json:"duration_ms"`
+}
+
+// ShadowComparison 线上版本与候选版本热点的差异
+type ShadowComparison struct {
+	ProductionCount  int     `json:"production_count"`
+	ShadowCount      int     `json:"shadow_count"`
+	Matched          int     `json:"matched"`
+	OnlyProduction   int     `json:"only_production"` // 候选版本漏掉的热点
+	OnlyShadow       int     `json:"only_shadow"`     // 候选版本新增的热点
+	Overlap          float64 `json:"overlap"`         // 匹配数 / 并集数（Jaccard）
+	MeanShiftSeconds float64 `json:"mean_shift_seconds"`
+	TopMomentMatched bool    `json:"top_moment_matched"` // 线上得分最高的热点是否也被候选版本检出
+	ToleranceSeconds float64 `json:"tolerance_seconds"`
+}
+
+// ShadowSnapshot 影子模式下同一录像两个版本的分析结果与对比，保存在 shadow_{候选版本}.json
+type ShadowSnapshot struct {
+	VideoID      string           `json:"video_id"`
+	StreamerName string           `json:"streamer_name,omitempty"`
+	Comments     int              `json:"comments"`
+	Production   ShadowRun        `json:"production"`
+	Shadow       ShadowRun        `json:"shadow"`
+	Comparison   ShadowComparison `json:"comparison"`
+	AnalyzedAt   time.Time        `json:"analyzed_at"`
+}
+
+// params 候选版本的峰值检测参数
+func (c ShadowAnalysisConfig) params() PeakDetectionParams {
+	return normalizePeakParams(PeakDetectionParams{
+		Method:        c.Method,
+		WindowsLen:    c.WindowsLen,
+		Thr:           c.Thr,
+		SearchRange:   c.SearchRange,
+		ZThreshold:    c.ZThreshold,
+		BaselineLen:   c.BaselineLen,
+		MinWindowsLen: c.MinWindowsLen,
+		Scales:        c.Scales,
+	})
+}
+
+// analysisVersion 由算法与参数生成的版本标签
+func analysisVersion(params PeakDetectionParams) string {
+	key := peakParamsKey(params)
+	if method := peakMethodName(params); method == defaultPeakMethod {
+		return method + "_" + key
+	}
+	return key
+}
+
+// shadowSnapshotPath 候选版本在录像上的对比结果文件
+func shadowSnapshotPath(videoID, version string) string {
+	return filepath.Join(pathsafe.Join("./analysis_results", videoID),
+		"shadow_"+metadataKeyUnsafe.ReplaceAllString(version, "_")+".json")
+}
+
+// compareHotMoments 按时间将两组热点一一匹配（容差内最近的优先）并统计差异
+func compareHotMoments(production, shadow []VodCommentData, tolerance float64) ShadowComparison {
+	cmp := ShadowComparison{
+		ProductionCount:  len(production),
+		ShadowCount:      len(shadow),
+		ToleranceSeconds: tolerance,
+	}
+
+	type pair struct {
+		p, s  int
+		shift float64
+	}
+	var pairs []pair
+	for i, pm := range production {
+		for j, sm := range shadow {
+			if shift := math.Abs(pm.OffsetSeconds - sm.OffsetSeconds); shift <= tolerance {
+				pairs = append(pairs, pair{i, j, shift})
+			}
+		}
+	}
+	sort.Slice(pairs, func(a, b int) bool { return pairs[a].shift < pairs[b].shift })
+
+	usedP := make([]bool, len(production))
+	usedS := make([]bool, len(shadow))
+	var totalShift float64
+	for _, pr := range pairs {
+		if usedP[pr.p] || usedS[pr.s] {
+			continue
+		}
+		usedP[pr.p], usedS[pr.s] = true, true
+		cmp.Matched++
+		totalShift += pr.shift
+	}
+
+	cmp.OnlyProduction = cmp.ProductionCount - cmp.Matched
+	cmp.OnlyShadow = cmp.ShadowCount - cmp.Matched
+	if union := cmp.Matched + cmp.OnlyProduction + cmp.OnlyShadow; union > 0 {
+		cmp.Overlap = math.Round(float64(cmp.Matched)/float64(union)*1000) / 1000
+	} else {
+		cmp.Overlap = 1
+	}
+	if cmp.Matched > 0 {
+		cmp.MeanShiftSeconds = math.Round(totalShift/float64(cmp.Matched)*10) / 10
+	}
+
+	top := -1
+	for i, pm := range production {
+		if top < 0 || pm.CommentsScore > production[top].CommentsScore {
+			top = i
+		}
+	}
+	cmp.TopMomentMatched = top < 0 || usedP[top]
+	return cmp
+}
+
+// runShadowAnalysis 影子模式：对已保存聊天记录的录像运行候选算法，与线上结果对比后保存
+// 只在后台执行，失败只记录日志，不影响线上结果
+func runShadowAnalysis(videoID, streamerName string, production []VodCommentData, productionParams PeakDetectionParams) {
+	cfg := GetAnalysisConfig().Shadow
+	if !cfg.Enabled {
+		return
+	}
+	if _, err := saveShadowSnapshot(cfg, videoID, streamerName, production, productionParams); err != nil {
+		log.Printf("视频 %s 的影子模式分析失败: %v", videoID, err)
+	}
+}
+
+// saveShadowSnapshot 运行候选算法并保存两个版本的结果与对比
+func saveShadowSnapshot(cfg ShadowAnalysisConfig, videoID, streamerName string, production []VodCommentData, productionParams PeakDetectionParams) (*ShadowSnapshot, error) {
+	offsets, err := loadChatOffsetsForVideo(videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	shadowParams := cfg.params()
+	version := cfg.Version
+	if version == "" {
+		version = analysisVersion(shadowParams)
+	}
+
+	start := time.Now()
+	result := NewAnalysisSession(offsets).Analyze(shadowParams)
+	elapsed := time.Since(start)
+
+	productionParams = normalizePeakParams(productionParams)
+	tolerance := math.Max(shadowMinMatchSeconds, float64(productionParams.WindowsLen)/2)
+	snapshot := &ShadowSnapshot{
+		VideoID:      videoID,
+		StreamerName: streamerName,
+		Comments:     len(offsets),
+		Production: ShadowRun{
+			Version:    analysisVersion(productionParams),
+			Params:     productionParams,
+			HotMoments: production,
+		},
+		Shadow: ShadowRun{
+			Version:    version,
+			Params:     shadowParams,
+			HotMoments: result.HotMoments,
+			DurationMs: elapsed.Milliseconds(),
+		},
+		Comparison: compareHotMoments(production, result.HotMoments, tolerance),
+		AnalyzedAt: time.Now(),
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	path := shadowSnapshotPath(videoID, version)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	log.Printf("视频 %s 影子模式 %s: 线上 %d 个热点，候选 %d 个，重合度 %.2f",
+		videoID, version, snapshot.Comparison.ProductionCount, snapshot.Comparison.ShadowCount, snapshot.Comparison.Overlap)
+	return snapshot, nil
+}
+
+// loadShadowSnapshots 读取指定时间之后的影子模式结果，version 不为空时只返回该候选版本
+func loadShadowSnapshots(videoID, version string, since time.Time) []ShadowSnapshot {
+	dir := "*"
+	if videoID != "" {
+		dir = pathsafe.Name(videoID)
+	}
+	paths, _ := filepath.Glob(filepath.Join("./analysis_results", dir, "shadow_*.json"))
+
+	snapshots := make([]ShadowSnapshot, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var snapshot ShadowSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			continue
+		}
+		if snapshot.AnalyzedAt.Before(since) || (version != "" && snapshot.Shadow.Version != version) {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+// shadowReport 一对线上/候选版本在多个录像上的汇总差异
+type shadowReport struct {
+	ProductionVersion  string  `json:"production_version"`
+	ShadowVersion      string  `json:"shadow_version"`
+	Videos             int     `json:"videos"`
+	AvgOverlap         float64 `json:"avg_overlap"`
+	AvgShiftSeconds    float64 `json:"avg_shift_seconds"`
+	TopMomentAgreement float64 `json:"top_moment_agreement"` // 线上最高分热点被候选版本检出的比例
+	ProductionMoments  int     `json:"production_moments"`
+	ShadowMoments      int     `json:"shadow_moments"`
+	OnlyProduction     int     `json:"only_production"`
+	OnlyShadow         int     `json:"only_shadow"`
+	AvgShadowMs        float64 `json:"avg_shadow_ms"`
+}
+
+// aggregateShadowSnapshots 按线上/候选版本对汇总，录像多的在前
+func aggregateShadowSnapshots(snapshots []ShadowSnapshot) []shadowReport {
+	byPair := map[string]*shadowReport{}
+	shifted := map[string]int{}
+	for _, s := range snapshots {
+		key := s.Production.Version + "|" + s.Shadow.Version
+		r, ok := byPair[key]
+		if !ok {
+			r = &shadowReport{ProductionVersion: s.Production.Version, ShadowVersion: s.Shadow.Version}
+			byPair[key] = r
+		}
+		cmp := s.Comparison
+		r.Videos++
+		r.AvgOverlap += cmp.Overlap
+		if cmp.Matched > 0 {
+			r.AvgShiftSeconds += cmp.MeanShiftSeconds
+			shifted[key]++
+		}
+		if cmp.TopMomentMatched {
+			r.TopMomentAgreement++
+		}
+		r.ProductionMoments += cmp.ProductionCount
+		r.ShadowMoments += cmp.ShadowCount
+		r.OnlyProduction += cmp.OnlyProduction
+		r.OnlyShadow += cmp.OnlyShadow
+		r.AvgShadowMs += float64(s.Shadow.DurationMs)
+	}
+
+	reports := make([]shadowReport, 0, len(byPair))
+	for key, r := range byPair {
+		n := float64(r.Videos)
+		r.AvgOverlap = math.Round(r.AvgOverlap/n*1000) / 1000
+		r.TopMomentAgreement = math.Round(r.TopMomentAgreement/n*1000) / 1000
+		r.AvgShadowMs = math.Round(r.AvgShadowMs / n)
+		if shifted[key] > 0 {
+			r.AvgShiftSeconds = math.Round(r.AvgShiftSeconds/float64(shifted[key])*10) / 10
+		}
+		reports = append(reports, *r)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Videos != reports[j].Videos {
+			return reports[i].Videos > reports[j].Videos
+		}
+		return reports[i].ShadowVersion < reports[j].ShadowVersion
+	})
+	return reports
+}
+
+// getShadowReportHandler 管理员查看影子模式的对比报告
+// 可选参数 days（默认 30）、version 只统计指定候选版本；同时列出差异最大的录像
+func getShadowReportHandler(c *gin.Context) {
+	days := 30
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "days 参数无效"})
+			return
+		}
+		days = n
+	}
+
+	snapshots := loadShadowSnapshots("", c.Query("version"), time.Now().AddDate(0, 0, -days))
+
+	type videoDiff struct {
+		VideoID       string           `json:"video_id"`
+		ShadowVersion string           `json:"shadow_version"`
+		Comparison    ShadowComparison `json:"comparison"`
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Comparison.Overlap < snapshots[j].Comparison.Overlap
+	})
+	diffs := make([]videoDiff, 0, 20)
+	for _, s := range snapshots {
+		if len(diffs) == 20 {
+			break
+		}
+		diffs = append(diffs, videoDiff{VideoID: s.VideoID, ShadowVersion: s.Shadow.Version, Comparison: s.Comparison})
+	}
+
+	cfg := GetAnalysisConfig().Shadow
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"enabled":       cfg.Enabled,
+		"days":          days,
+		"snapshots":     len(snapshots),
+		"versions":      aggregateShadowSnapshots(snapshots),
+		"largest_diffs": diffs,
+	})
+}
+
+// getVideoShadowHandler 管理员查看单个录像各候选版本与线上版本的完整结果
+func getVideoShadowHandler(c *gin.Context) {
+	snapshots := loadShadowSnapshots(c.Param("videoID"), c.Query("version"), time.Time{})
+	c.JSON(http.StatusOK, gin.H{"success": true, "snapshots": snapshots})
+}
+
+// runVideoShadowHandler 管理员对已分析的录像补跑当前配置的候选版本（不要求开启影子模式）
+func runVideoShadowHandler(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+
+	cfg := GetAnalysisConfig().Shadow
+	if err := validatePeakMethod(cfg.Method); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(pathsafe.Join("./analysis_results", videoID), analysisResultFileName(defaultPeakParams)))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "未找到该视频的默认参数分析结果"})
+		return
+	}
+	var result AnalysisResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "解析分析结果失败: " + err.Error()})
+		return
+	}
+
+	snapshot, err := saveShadowSnapshot(cfg, videoID, result.StreamerName, result.HotMoments, defaultPeakParams)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": fmt.Sprintf("影子模式分析失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "snapshot": snapshot})
+}
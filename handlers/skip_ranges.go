@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"subtuber-services/pathsafe"
+)
+
+const (
+	// skipActivityWindow 计算弹幕活跃度的滑动窗口（秒）
+	skipActivityWindow = 60
+	// defaultSkipMinDuration 可跳过片段的最短时长（秒）
+	defaultSkipMinDuration = 300
+	// defaultSkipActivityRatio 活跃度低于中位数的该比例时视为冷场
+	defaultSkipActivityRatio = 0.15
+)
+
+// 可跳过片段的原因
+const (
+	skipReasonNoChat      = "no_chat"      // 完全没有弹幕（挂机、休息画面）
+	skipReasonLowActivity = "low_activity" // 弹幕远低于平时水平
+)
+
+// SkipRange 持续冷场、可供播放器自动跳过的片段
+type SkipRange struct {
+	StartSeconds   float64 `json:"start_seconds"`
+	EndSeconds     float64 `json:"end_seconds"`
+	Duration       float64 `json:"duration"`
+	FormattedStart string  `json:"formatted_start"`
+	FormattedEnd   string  `json:"formatted_end"`
+	Reason         string  `json:"reason"`
+	Comments       int     `json:"comments"` // 片段内的评论数
+}
+
+// skipRangeSettings 返回配置的冷场判定参数
+func skipRangeSettings() (minDuration int, ratio float64) {
+	cfg := GetAnalysisConfig()
+	minDuration, ratio = cfg.SkipMinDurationSeconds, cfg.SkipActivityRatio
+	if minDuration <= 0 {
+		minDuration = defaultSkipMinDuration
+	}
+	if ratio <= 0 {
+		ratio = defaultSkipActivityRatio
+	}
+	return minDuration, ratio
+}
+
+// detectSkipRanges 查找每秒评论数中持续低活跃的片段
+// 以 skipActivityWindow 秒内的评论数作为活跃度，低于有弹幕时段活跃度中位数的 ratio 倍、
+// 且持续不少于 minDuration 秒的连续区间即为可跳过片段
+func detectSkipRanges(counts []float64, minDuration int, ratio float64) []SkipRange {
+	ranges := []SkipRange{}
+	n := len(counts)
+	if n < minDuration {
+		return ranges
+	}
+
+	activity := boxSumSame(counts, skipActivityWindow)
+	active := make([]float64, 0, n)
+	for _, v := range activity {
+		if v > 0 {
+			active = append(active, v)
+		}
+	}
+	if len(active) == 0 {
+		return ranges
+	}
+	sort.Float64s(active)
+	threshold := active[len(active)/2] * ratio
+
+	flush := func(start, end int) {
+		if end-start < minDuration {
+			return
+		}
+		comments := 0
+		for _, v := range counts[start:end] {
+			comments += int(v)
+		}
+		reason := skipReasonLowActivity
+		if comments == 0 {
+			reason = skipReasonNoChat
+		}
+		ranges = append(ranges, SkipRange{
+			StartSeconds:   float64(start),
+			EndSeconds:     float64(end),
+			Duration:       float64(end - start),
+			FormattedStart: formatDuration(float64(start)),
+			FormattedEnd:   formatDuration(float64(end)),
+			Reason:         reason,
+			Comments:       comments,
+		})
+	}
+
+	start := -1
+	for i, v := range activity {
+		if v <= threshold {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			flush(start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		flush(start, n)
+	}
+
+	return ranges
+}
+
+// SkipRanges 返回会话的可跳过片段，结果与峰值检测参数无关，只计算一次
+func (s *AnalysisSession) SkipRanges() []SkipRange {
+	s.skipOnce.Do(func() {
+		minDuration, ratio := skipRangeSettings()
+		s.skipRanges = detectSkipRanges(s.counts, minDuration, ratio)
+	})
+	return s.skipRanges
+}
+
+// TimelineMarker 时间轴上的热点标记
+type TimelineMarker struct {
+	OffsetSeconds float64 `json:"offset_seconds"`
+	FormattedTime string  `json:"formatted_time"`
+	Score         float64 `json:"score"`
+}
+
+// GetAnalysisTimeline 返回播放器使用的时间轴：热点标记、可跳过片段与章节
+// 参数与导出接口相同；早期的分析结果没有保存可跳过片段或章节时，根据聊天记录重新计算
+func GetAnalysisTimeline(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+
+	params, err := peakParamsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(pathsafe.Join("./analysis_results", videoID), analysisResultFileName(params)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "未找到该参数的分析结果，请先获取分析结果"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取分析结果失败: " + err.Error()})
+		return
+	}
+
+	var result AnalysisResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解析分析结果失败: " + err.Error()})
+		return
+	}
+
+	skipRanges := result.SkipRanges
+	if skipRanges == nil {
+		if offsets, err := loadChatOffsetsForVideo(videoID); err == nil {
+			skipRanges = NewAnalysisSession(offsets).SkipRanges()
+		} else {
+			skipRanges = []SkipRange{}
+		}
+	}
+
+	chapters := result.Chapters
+	if chapters == nil {
+		if chapters, err = chaptersForVideo(videoID); err != nil {
+			chapters = []Chapter{}
+		}
+	}
+
+	markers := make([]TimelineMarker, 0, len(result.HotMoments))
+	for _, moment := range result.HotMoments {
+		markers = append(markers, TimelineMarker{
+			OffsetSeconds: moment.OffsetSeconds,
+			FormattedTime: moment.FormattedTime,
+			Score:         moment.CommentsScore,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id":    videoID,
+		"method":      peakMethodName(params),
+		"hot_moments": markers,
+		"skip_ranges": skipRanges,
+		"chapters":    chapters,
+	})
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 流水线 SLO 指标
+const (
+	sloStreamEndToAnalysis = "stream_end_to_analysis" // 下播到分析结果可用
+	sloAnalysisToSummary   = "analysis_to_summary"    // 分析结果可用到首个AI总结可用
+)
+
+const (
+	// sloSampleWindow 计算滚动分位数的时间窗口
+	sloSampleWindow = 24 * time.Hour
+	// sloMaxSamples 每个指标每个平台最多保留的样本数
+	sloMaxSamples = 1000
+	// sloPendingExpiry 等待中的事件超过该时长仍未完成时丢弃（如主播关闭了录像）
+	sloPendingExpiry = 48 * time.Hour
+)
+
+// sloQuantiles 导出的分位数
+var sloQuantiles = []float64{0.5, 0.9, 0.99}
+
+type sloSample struct {
+	at      time.Time
+	seconds float64
+}
+
+// sloSeries 单个平台单个指标的样本，count 与 sum 为累计值
+type sloSeries struct {
+	samples []sloSample
+	count   int64
+	sum     float64
+}
+
+type sloPending struct {
+	platform string
+	since    time.Time
+}
+
+// pipelineSLOTracker 记录流水线各阶段的完成时间并计算延迟
+type pipelineSLOTracker struct {
+	mu             sync.Mutex
+	series         map[string]map[string]*sloSeries // 指标 -> 平台 -> 样本
+	streamEnded    map[string]sloPending            // platform/streamer -> 下播时间
+	analysisReady  map[string]sloPending            // 视频ID -> 分析结果可用时间（等待AI总结）
+	lastObservedAt time.Time
+}
+
+var pipelineSLO = &pipelineSLOTracker{
+	series:        map[string]map[string]*sloSeries{},
+	streamEnded:   map[string]sloPending{},
+	analysisReady: map[string]sloPending{},
+}
+
+func sloStreamerKey(platform, streamer string) string {
+	return platform + "/" + strings.ToLower(streamer)
+}
+
+// markStreamEnded 记录主播下播的时间，同一主播重复下播时保留最早的时间
+func (t *pipelineSLOTracker) markStreamEnded(platform, streamer string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := sloStreamerKey(platform, streamer)
+	if _, ok := t.streamEnded[key]; !ok {
+		t.streamEnded[key] = sloPending{platform: platform, since: at}
+	}
+}
+
+// discardStreamEnded 不再等待主播的分析结果（如按流水线规则跳过了分析）
+func (t *pipelineSLOTracker) discardStreamEnded(platform, streamer string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.streamEnded, sloStreamerKey(platform, streamer))
+}
+
+// markAnalysisReady 记录录像分析结果可用：下播后的首个分析结果计入下播到分析的延迟，并开始等待AI总结
+func (t *pipelineSLOTracker) markAnalysisReady(platform, streamer, videoID string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := sloStreamerKey(platform, streamer)
+	if ended, ok := t.streamEnded[key]; ok {
+		delete(t.streamEnded, key)
+		t.observe(sloStreamEndToAnalysis, platform, at.Sub(ended.since), at)
+	}
+	t.analysisReady[videoID] = sloPending{platform: platform, since: at}
+}
+
+// markSummaryReady 记录录像的AI总结可用，每个录像只计入首个总结
+func (t *pipelineSLOTracker) markSummaryReady(videoID string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ready, ok := t.analysisReady[videoID]
+	if !ok {
+		return
+	}
+	delete(t.analysisReady, videoID)
+	t.observe(sloAnalysisToSummary, ready.platform, at.Sub(ready.since), at)
+}
+
+// finishVideo 录像的流水线已结束，没有生成AI总结时不再等待
+func (t *pipelineSLOTracker) finishVideo(videoID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.analysisReady, videoID)
+}
+
+// observe 记录一个延迟样本，调用方需持有锁
+func (t *pipelineSLOTracker) observe(metric, platform string, latency time.Duration, at time.Time) {
+	byPlatform, ok := t.series[metric]
+	if !ok {
+		byPlatform = map[string]*sloSeries{}
+		t.series[metric] = byPlatform
+	}
+	s, ok := byPlatform[platform]
+	if !ok {
+		s = &sloSeries{}
+		byPlatform[platform] = s
+	}
+
+	seconds := latency.Seconds()
+	s.samples = append(s.samples, sloSample{at: at, seconds: seconds})
+	if len(s.samples) > sloMaxSamples {
+		s.samples = s.samples[len(s.samples)-sloMaxSamples:]
+	}
+	s.count++
+	s.sum += seconds
+	t.lastObservedAt = at
+}
+
+// prune 丢弃窗口外的样本与过期的等待事件，调用方需持有锁
+func (t *pipelineSLOTracker) prune(now time.Time) {
+	for _, byPlatform := range t.series {
+		for _, s := range byPlatform {
+			i := sort.Search(len(s.samples), func(i int) bool { return now.Sub(s.samples[i].at) <= sloSampleWindow })
+			s.samples = s.samples[i:]
+		}
+	}
+	for _, pending := range []map[string]sloPending{t.streamEnded, t.analysisReady} {
+		for key, p := range pending {
+			if now.Sub(p.since) > sloPendingExpiry {
+				delete(pending, key)
+			}
+		}
+	}
+}
+
+// writePrometheus 以 Prometheus 文本格式输出指标：各阶段延迟的滚动分位数（summary），
+// 以及仍在等待中的最早事件已等待的时长，用于在流水线卡住、尚无样本时告警
+func (t *pipelineSLOTracker) writePrometheus(b *strings.Builder, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(now)
+
+	for _, metric := range []string{sloStreamEndToAnalysis, sloAnalysisToSummary} {
+		name := "subtuber_pipeline_" + metric + "_seconds"
+		fmt.Fprintf(b, "# HELP %s Pipeline latency %s, quantiles over the last %s.\n", name, strings.ReplaceAll(metric, "_", " "), sloSampleWindow)
+		fmt.Fprintf(b, "# TYPE %s summary\n", name)
+
+		byPlatform := t.series[metric]
+		for _, platform := range sortedKeys(byPlatform) {
+			s := byPlatform[platform]
+			values := make([]float64, len(s.samples))
+			for i, sample := range s.samples {
+				values[i] = sample.seconds
+			}
+			sort.Float64s(values)
+			for _, q := range sloQuantiles {
+				v := "NaN"
+				if len(values) > 0 {
+					v = fmt.Sprintf("%g", percentileFromSorted(values, q))
+				}
+				fmt.Fprintf(b, "%s{platform=%q,quantile=\"%g\"} %s\n", name, platform, q, v)
+			}
+			fmt.Fprintf(b, "%s_sum{platform=%q} %g\n", name, platform, s.sum)
+			fmt.Fprintf(b, "%s_count{platform=%q} %d\n", name, platform, s.count)
+		}
+	}
+
+	oldest := map[string]map[string]time.Time{sloStreamEndToAnalysis: {}, sloAnalysisToSummary: {}}
+	for metric, pending := range map[string]map[string]sloPending{
+		sloStreamEndToAnalysis: t.streamEnded,
+		sloAnalysisToSummary:   t.analysisReady,
+	} {
+		for _, p := range pending {
+			if since, ok := oldest[metric][p.platform]; !ok || p.since.Before(since) {
+				oldest[metric][p.platform] = p.since
+			}
+		}
+	}
+
+	name := "subtuber_pipeline_pending_age_seconds"
+	fmt.Fprintf(b, "# HELP %s Age of the oldest pipeline stage still waiting to complete.\n", name)
+	fmt.Fprintf(b, "# TYPE %s gauge\n", name)
+	for _, metric := range []string{sloStreamEndToAnalysis, sloAnalysisToSummary} {
+		for _, platform := range sortedKeys(oldest[metric]) {
+			fmt.Fprintf(b, "%s{platform=%q,stage=%q} %g\n", name, platform, metric, now.Sub(oldest[metric][platform]).Seconds())
+		}
+	}
+
+	if !t.lastObservedAt.IsZero() {
+		name = "subtuber_pipeline_last_observation_timestamp_seconds"
+		fmt.Fprintf(b, "# HELP %s Unix time of the most recent pipeline latency sample.\n", name)
+		fmt.Fprintf(b, "# TYPE %s gauge\n", name)
+		fmt.Fprintf(b, "%s %d\n", name, t.lastObservedAt.Unix())
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetMetrics 以 Prometheus 文本格式导出流水线 SLO 指标、AI提供商的延迟与并发指标以及 Twitch 凭据的配额使用情况
+func GetMetrics(c *gin.Context) {
+	var b strings.Builder
+	pipelineSLO.writePrometheus(&b, time.Now())
+	writeAIPrometheus(&b)
+	writeCapabilityPrometheus(&b)
+	writeTwitchCredentialPrometheus(&b)
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// 流水线中受并发上限约束的阶段，对应配置 pipeline.concurrency
+const (
+	pipelineStageChatDownload = "chat_download"
+	pipelineStageFFmpeg       = "ffmpeg"
+	pipelineStageASR          = "asr"
+	pipelineStageAI           = "ai"
+)
+
+// 各阶段默认的并发上限
+const (
+	defaultChatDownloadConcurrency = 2
+	defaultFFmpegConcurrency       = 2
+	defaultASRConcurrency          = 2
+	defaultAIConcurrency           = 4
+)
+
+// pipelineStages 阶段的显示顺序
+var pipelineStages = []string{pipelineStageChatDownload, pipelineStageFFmpeg, pipelineStageASR, pipelineStageAI}
+
+// stageJobStages 等待名额时上报进度使用的任务阶段
+var stageJobStages = map[string]string{
+	pipelineStageChatDownload: JobStageChatDownload,
+	pipelineStageFFmpeg:       JobStageFFmpeg,
+	pipelineStageASR:          JobStageASR,
+	pipelineStageAI:           JobStageAISummary,
+}
+
+// Validate checks that no stage concurrency limit is negative
+func (c StageConcurrencyConfig) Validate() error {
+	for _, stage := range pipelineStages {
+		if c.limit(stage) < 0 {
+			return fmt.Errorf("阶段 %s 的并发上限不能为负数", stage)
+		}
+	}
+	return nil
+}
+
+// limit 阶段配置的并发上限，未配置时为 0
+func (c StageConcurrencyConfig) limit(stage string) int {
+	switch stage {
+	case pipelineStageChatDownload:
+		return c.ChatDownload
+	case pipelineStageFFmpeg:
+		return c.FFmpeg
+	case pipelineStageASR:
+		return c.ASR
+	case pipelineStageAI:
+		return c.AI
+	}
+	return 0
+}
+
+// stageLimit 阶段当前生效的并发上限，配置热加载后对新的请求立即生效
+func stageLimit(stage string) int {
+	if n := GetPipelineConfig().Concurrency.limit(stage); n > 0 {
+		return n
+	}
+	switch stage {
+	case pipelineStageChatDownload:
+		return defaultChatDownloadConcurrency
+	case pipelineStageFFmpeg:
+		return defaultFFmpegConcurrency
+	case pipelineStageASR:
+		return defaultASRConcurrency
+	default:
+		return defaultAIConcurrency
+	}
+}
+
+// stageLimiter 单个阶段的计数信号量，上限每次获取时从配置读取
+type stageLimiter struct {
+	stage string
+
+	mu       sync.Mutex
+	inflight int
+	waiting  int
+	wake     chan struct{} // 有名额释放时关闭并替换，唤醒等待者
+}
+
+var (
+	stageLimitersMu sync.Mutex
+	stageLimiters   = map[string]*stageLimiter{}
+)
+
+// stageLimiterFor 返回阶段的信号量，首次使用时创建
+func stageLimiterFor(stage string) *stageLimiter {
+	stageLimitersMu.Lock()
+	defer stageLimitersMu.Unlock()
+	l, ok := stageLimiters[stage]
+	if !ok {
+		l = &stageLimiter{stage: stage, wake: make(chan struct{})}
+		stageLimiters[stage] = l
+	}
+	return l
+}
+
+// acquire 等待阶段的空闲名额，context 取消时返回其错误
+func (l *stageLimiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if l.inflight >= stageLimit(l.stage) {
+		l.waiting++
+		reportJobProgressCtx(ctx, stageJobStages[l.stage], 0, fmt.Sprintf("等待空闲名额（%d 个任务排队）", l.waiting))
+		for l.inflight >= stageLimit(l.stage) {
+			wake := l.wake
+			l.mu.Unlock()
+			select {
+			case <-wake:
+			case <-ctx.Done():
+				l.mu.Lock()
+				l.waiting--
+				l.mu.Unlock()
+				return ctx.Err()
+			}
+			l.mu.Lock()
+		}
+		l.waiting--
+	}
+	l.inflight++
+	l.mu.Unlock()
+	return nil
+}
+
+// release 归还名额并唤醒等待者
+func (l *stageLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// wakeStageLimiters 唤醒所有阶段的等待者，使其按新的并发上限重新检查
+func wakeStageLimiters() {
+	stageLimitersMu.Lock()
+	defer stageLimitersMu.Unlock()
+	for _, l := range stageLimiters {
+		l.mu.Lock()
+		close(l.wake)
+		l.wake = make(chan struct{})
+		l.mu.Unlock()
+	}
+}
+
+// acquireStageSlot 等待阶段的空闲名额，返回归还名额的函数；context 取消时返回其错误
+func acquireStageSlot(ctx context.Context, stage string) (func(), error) {
+	l := stageLimiterFor(stage)
+	if err := l.acquire(ctx); err != nil {
+		return nil, err
+	}
+	return l.release, nil
+}
+
+// waitStageSlot 没有 context 的调用方等待阶段的空闲名额，返回归还名额的函数
+func waitStageSlot(stage string) func() {
+	release, _ := acquireStageSlot(context.Background(), stage)
+	return release
+}
+
+// StageConcurrencyStats 阶段的并发上限与当前状态
+type StageConcurrencyStats struct {
+	Stage   string `json:"stage"`
+	Limit   int    `json:"limit"`
+	Running int    `json:"running"`
+	Waiting int    `json:"waiting"`
+}
+
+// stageConcurrencyStats 各阶段的并发上限、执行中与等待中的数量
+func stageConcurrencyStats() []StageConcurrencyStats {
+	stats := make([]StageConcurrencyStats, 0, len(pipelineStages))
+	for _, stage := range pipelineStages {
+		l := stageLimiterFor(stage)
+		l.mu.Lock()
+		stats = append(stats, StageConcurrencyStats{
+			Stage:   stage,
+			Limit:   stageLimit(stage),
+			Running: l.inflight,
+			Waiting: l.waiting,
+		})
+		l.mu.Unlock()
+	}
+	return stats
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"subtuber-services/chatdownload"
+	"subtuber-services/models"
+	"subtuber-services/pathsafe"
+	"subtuber-services/storage"
+)
+
+// storageIndexComplete 已有文件是否已全部导入索引，导入完成前列表类查询仍扫描目录
+var storageIndexComplete atomic.Bool
+
+// InitStorageIndex 打开存储索引，并在后台导入尚未记录的聊天记录、分析结果与AI总结
+// 索引打开失败时各接口回退到扫描文件
+func InitStorageIndex(path string) error {
+	store, err := storage.Init(path)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if _, err := store.ImportFiles(chatdownload.LogDir, "./analysis_results"); err != nil {
+			log.Printf("导入已有文件到存储索引失败: %v", err)
+			return
+		}
+		storageIndexComplete.Store(true)
+	}()
+	return nil
+}
+
+// completeStorageIndex 已导入全部已有文件的存储索引，尚未导入完成或不可用时返回 nil
+func completeStorageIndex() *storage.Store {
+	if !storageIndexComplete.Load() {
+		return nil
+	}
+	return storage.Default()
+}
+
+// downloadTwitchChatLog 下载 Twitch 聊天记录并逐条写入文件，完成后写入存储索引
+// 返回文件路径与不含评论的下载信息
+func downloadTwitchChatLog(downloader *chatdownload.TwitchDownloader, videoID string, startTime, endTime *float64) (string, *models.TwitchChatDownloadResponse, error) {
+	path, response, err := downloader.DownloadToLog(videoID, startTime, endTime)
+	if err != nil {
+		return "", nil, err
+	}
+	indexChatLog(videoID, "twitch", path, response.TotalComments)
+	return path, response, nil
+}
+
+// saveYouTubeChatLog 保存 YouTube 聊天记录并写入存储索引，返回文件路径
+func saveYouTubeChatLog(videoID string, logs []models.YoutubeChatLog) (string, error) {
+	path, err := chatdownload.SaveYouTube(videoID, logs)
+	if err != nil {
+		return "", err
+	}
+	indexChatLog(videoID, "youtube", path, len(logs))
+	return path, nil
+}
+
+// indexChatLog 将新保存的聊天记录写入存储索引，索引不可用时只记录日志
+func indexChatLog(videoID, platform, path string, comments int) {
+	store := storage.Default()
+	if store == nil {
+		return
+	}
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	if err := store.PutChatLog(storage.ChatLog{
+		VideoID:      videoID,
+		Platform:     platform,
+		Path:         filepath.Clean(path),
+		Comments:     comments,
+		Size:         size,
+		DownloadedAt: time.Now(),
+	}); err != nil {
+		log.Printf("记录视频 %s 的聊天记录到存储索引失败: %v", videoID, err)
+	}
+}
+
+// indexAnalysisResult 将刚写入的分析结果文件同时写入存储索引，data 为文件内容
+func indexAnalysisResult(videoID, file string, result AnalysisResult, data []byte) {
+	store := storage.Default()
+	if store == nil {
+		return
+	}
+	if err := store.PutAnalysisRun(storage.AnalysisRun{
+		VideoID:      pathsafe.Name(videoID),
+		File:         file,
+		StreamerName: result.StreamerName,
+		Title:        result.VideoInfo.Title,
+		Method:       result.Method,
+		HotMoments:   len(result.HotMoments),
+		AnalyzedAt:   result.AnalyzedAt,
+		Result:       data,
+	}); err != nil {
+		log.Printf("记录视频 %s 的分析结果到存储索引失败: %v", videoID, err)
+	}
+}
+
+// indexSummary 将刚保存的AI总结写入存储索引；summaryPath 与 SaveSummaryToFile 的参数相同，
+// 总结文件名为 {热点秒数整数部分}_summary.txt
+func indexSummary(videoID string, offsetSeconds float64, summaryPath, summary string) {
+	store := storage.Default()
+	if store == nil {
+		return
+	}
+	if err := store.PutSummary(storage.Summary{
+		VideoID:       pathsafe.Name(videoID),
+		OffsetSeconds: math.Trunc(offsetSeconds),
+		Path:          filepath.Clean(strings.TrimSuffix(summaryPath, filepath.Ext(summaryPath)) + "_summary.txt"),
+		Summary:       summary,
+		UpdatedAt:     time.Now(),
+	}); err != nil {
+		log.Printf("记录视频 %s 热点 %.0f 秒的AI总结到存储索引失败: %v", videoID, offsetSeconds, err)
+	}
+}
+
+// readAnalysisResultData 读取分析结果的 JSON：优先从存储索引读取，索引中没有时读取文件并补录到索引
+// 两者都没有时返回 os.ErrNotExist
+func readAnalysisResultData(videoID, file string) ([]byte, error) {
+	store := storage.Default()
+	if store != nil {
+		run, err := store.GetAnalysisRun(pathsafe.Name(videoID), file)
+		if err != nil {
+			log.Printf("从存储索引读取视频 %s 的分析结果失败，改为读取文件: %v", videoID, err)
+		} else if run != nil {
+			return run.Result, nil
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(pathsafe.Join("./analysis_results", videoID), file))
+	if err != nil {
+		return nil, err
+	}
+	if store != nil {
+		var result AnalysisResult
+		if err := json.Unmarshal(data, &result); err == nil {
+			indexAnalysisResult(videoID, file, result, data)
+		}
+	}
+	return data, nil
+}
+
+// analysisResultExists 录像是否已有该文件名的分析结果
+func analysisResultExists(videoID, file string) bool {
+	if store := storage.Default(); store != nil {
+		if run, err := store.GetAnalysisRun(pathsafe.Name(videoID), file); err == nil && run != nil {
+			return true
+		}
+	}
+	_, err := os.Stat(filepath.Join(pathsafe.Join("./analysis_results", videoID), file))
+	return err == nil
+}
+
+// listAnalysisResultFiles 录像的所有分析结果文件名，按分析时间倒序；索引不可用时按文件名排序
+func listAnalysisResultFiles(videoID string) ([]string, error) {
+	if store := completeStorageIndex(); store != nil {
+		runs, err := store.ListAnalysisRuns(pathsafe.Name(videoID))
+		if err == nil && len(runs) > 0 {
+			files := make([]string, 0, len(runs))
+			for _, run := range runs {
+				files = append(files, run.File)
+			}
+			return files, nil
+		}
+		if err != nil {
+			log.Printf("从存储索引查询视频 %s 的分析结果失败，改为扫描目录: %v", videoID, err)
+		}
+	}
+
+	matches, err := filepath.Glob(filepath.Join(pathsafe.Join("./analysis_results", videoID), "analysis_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(matches))
+	for _, match := range matches {
+		files = append(files, filepath.Base(match))
+	}
+	return files, nil
+}
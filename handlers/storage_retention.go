@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"subtuber-services/chatdownload"
+	"subtuber-services/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 受保留策略管理的目录，对应配置 storage.retention 中的键
+const (
+	retentionChatLogs  = "chat_logs"
+	retentionDownloads = "downloads"
+	retentionAnalysis  = "analysis_results"
+
+	// retentionMinAge 最近修改的数据可能仍在写入（下载中的聊天记录、剪辑中的片段），不参与清理
+	retentionMinAge = time.Hour
+)
+
+// retentionDirs 目录名 -> 路径
+var retentionDirs = map[string]string{
+	retentionChatLogs:  chatdownload.LogDir,
+	retentionDownloads: "./downloads",
+	retentionAnalysis:  "./analysis_results",
+}
+
+// retentionDirOrder 统计与清理的顺序
+var retentionDirOrder = []string{retentionChatLogs, retentionDownloads, retentionAnalysis}
+
+// storageRetentionMu 保证同一时间只执行一次清理
+var storageRetentionMu sync.Mutex
+
+// retentionEntry 清理的单位：chat_logs 与 downloads 为单个文件，analysis_results 为整个录像目录
+type retentionEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// DirUsage 一个数据目录的占用情况
+type DirUsage struct {
+	Name        string          `json:"name"`
+	Path        string          `json:"path"`
+	Bytes       int64           `json:"bytes"`
+	Entries     int             `json:"entries"` // 文件数，analysis_results 为录像数
+	Oldest      *time.Time      `json:"oldest,omitempty"`
+	Newest      *time.Time      `json:"newest,omitempty"`
+	Policy      RetentionPolicy `json:"policy"`
+	UsedPercent float64         `json:"used_percent,omitempty"` // 占 max_size_gb 的百分比
+}
+
+// RetentionStats 一个目录一次清理的结果
+type RetentionStats struct {
+	Dir     string `json:"dir"`
+	Deleted int    `json:"deleted"`
+	Failed  int    `json:"failed"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// validateRetention 校验各目录的保留策略
+func validateRetention(policies map[string]RetentionPolicy) error {
+	for name, policy := range policies {
+		if _, ok := retentionDirs[name]; !ok {
+			return fmt.Errorf("未知的保留策略目录: %s，可选 %s", name, strings.Join(retentionDirOrder, "、"))
+		}
+		if policy.MaxAgeDays < 0 || policy.MaxSizeGB < 0 {
+			return fmt.Errorf("目录 %s 的 max_age_days 与 max_size_gb 不能为负数", name)
+		}
+	}
+	return nil
+}
+
+// maxBytes 目录占用上限（字节），0 表示不限制
+func (p RetentionPolicy) maxBytes() int64 {
+	return int64(p.MaxSizeGB * (1 << 30))
+}
+
+// enabled 是否设置了任一限制
+func (p RetentionPolicy) enabled() bool {
+	return p.MaxAgeDays > 0 || p.MaxSizeGB > 0
+}
+
+// scanRetentionEntries 列出目录中可清理的数据，按修改时间从旧到新排序；目录不存在时返回空列表
+func scanRetentionEntries(name string) ([]retentionEntry, error) {
+	root := retentionDirs[name]
+	var entries []retentionEntry
+
+	if name == retentionAnalysis {
+		dirs, err := os.ReadDir(root)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		for _, dir := range dirs {
+			if !dir.IsDir() {
+				continue
+			}
+			entry := retentionEntry{path: filepath.Join(root, dir.Name())}
+			_ = filepath.WalkDir(entry.path, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return nil
+				}
+				if info, err := d.Info(); err == nil {
+					entry.size += info.Size()
+					if info.ModTime().After(entry.modTime) {
+						entry.modTime = info.ModTime()
+					}
+				}
+				return nil
+			})
+			if entry.modTime.IsZero() {
+				if info, err := dir.Info(); err == nil {
+					entry.modTime = info.ModTime()
+				}
+			}
+			entries = append(entries, entry)
+		}
+	} else {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				return nil
+			}
+			entries = append(entries, retentionEntry{path: path, size: info.Size(), modTime: info.ModTime()})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	return entries, nil
+}
+
+// dirUsage 统计目录的占用情况
+func dirUsage(name string, policy RetentionPolicy) (DirUsage, error) {
+	usage := DirUsage{Name: name, Path: retentionDirs[name], Policy: policy}
+	entries, err := scanRetentionEntries(name)
+	if err != nil {
+		return usage, err
+	}
+	for _, entry := range entries {
+		usage.Bytes += entry.size
+	}
+	usage.Entries = len(entries)
+	if n := len(entries); n > 0 {
+		oldest, newest := entries[0].modTime, entries[n-1].modTime
+		usage.Oldest, usage.Newest = &oldest, &newest
+	}
+	if limit := policy.maxBytes(); limit > 0 {
+		usage.UsedPercent = float64(usage.Bytes) * 100 / float64(limit)
+	}
+	return usage, nil
+}
+
+// StartStorageRetention 启动后台任务，按 storage.retention 清理超过保留天数或超出占用上限的数据
+// 计划见配置 schedule.jobs.retention（默认每小时）
+func StartStorageRetention() {
+	scheduleJob(scheduleJobRetention, func() {
+		RunStorageRetention()
+	})
+}
+
+// RunStorageRetention 对配置了保留策略的目录执行一次清理：先删除超过保留天数的数据，
+// 仍超出占用上限时从最旧的数据开始删除，直到低于上限
+func RunStorageRetention() []RetentionStats {
+	storageRetentionMu.Lock()
+	defer storageRetentionMu.Unlock()
+
+	policies := GetStorageConfig().Retention
+	var results []RetentionStats
+	for _, name := range retentionDirOrder {
+		policy, ok := policies[name]
+		if !ok || !policy.enabled() {
+			continue
+		}
+		stats, err := enforceRetention(name, policy, time.Now())
+		if err != nil {
+			log.Printf("清理目录 %s 失败: %v", retentionDirs[name], err)
+		}
+		if stats.Deleted > 0 || stats.Failed > 0 {
+			log.Printf("目录 %s 清理完成: 删除 %d 项 (%d 字节)，失败 %d 项", retentionDirs[name], stats.Deleted, stats.Bytes, stats.Failed)
+		}
+		results = append(results, stats)
+	}
+	return results
+}
+
+// enforceRetention 按保留策略清理单个目录
+func enforceRetention(name string, policy RetentionPolicy, now time.Time) (RetentionStats, error) {
+	stats := RetentionStats{Dir: name}
+	entries, err := scanRetentionEntries(name)
+	if err != nil {
+		return stats, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+	limit := policy.maxBytes()
+	var cutoff time.Time
+	if policy.MaxAgeDays > 0 {
+		cutoff = now.AddDate(0, 0, -policy.MaxAgeDays)
+	}
+
+	for _, entry := range entries {
+		expired := !cutoff.IsZero() && entry.modTime.Before(cutoff)
+		overLimit := limit > 0 && total > limit
+		if !expired && !overLimit {
+			// 按从旧到新排序，之后的数据都不会过期
+			break
+		}
+		if now.Sub(entry.modTime) < retentionMinAge {
+			continue
+		}
+		if err := removeRetentionEntry(name, entry); err != nil {
+			log.Printf("清理 %s 失败: %v", entry.path, err)
+			stats.Failed++
+			continue
+		}
+		total -= entry.size
+		stats.Deleted++
+		stats.Bytes += entry.size
+
+		reason := "MAX_AGE"
+		if !expired {
+			reason = "MAX_SIZE"
+		}
+		_ = appendErrorLog("storage-audit.log", fmt.Sprintf("%s\tRETENTION\t%s\t%s\t%d\n",
+			now.Format(time.RFC3339), reason, entry.path, entry.size))
+	}
+	return stats, nil
+}
+
+// removeRetentionEntry 删除一项数据并同步存储索引
+func removeRetentionEntry(name string, entry retentionEntry) error {
+	switch name {
+	case retentionAnalysis:
+		// 录像有进行中的片段任务（会写入总结与字幕）时跳过，下次清理时重试
+		if clipArtifactsInFlight(filepath.Join(hotClipsDir, filepath.Base(entry.path))) {
+			return fmt.Errorf("有进行中的片段任务")
+		}
+		if err := os.RemoveAll(entry.path); err != nil {
+			return err
+		}
+		if store := storage.Default(); store != nil {
+			if err := store.DeleteVideoAnalysis(filepath.Base(entry.path)); err != nil {
+				log.Printf("删除录像 %s 的分析结果索引失败: %v", filepath.Base(entry.path), err)
+			}
+		}
+		return nil
+
+	case retentionDownloads:
+		dir := filepath.Dir(entry.path)
+		if clipArtifactsInFlight(dir) {
+			return fmt.Errorf("有进行中的片段任务")
+		}
+		if err := os.Remove(entry.path); err != nil {
+			return err
+		}
+		// 录像目录已清空时一并删除，Remove 只会删除空目录
+		if filepath.Clean(dir) != filepath.Clean(retentionDirs[name]) {
+			_ = os.Remove(dir)
+		}
+		return nil
+
+	default:
+		if err := os.Remove(entry.path); err != nil {
+			return err
+		}
+		if store := storage.Default(); store != nil {
+			if err := store.DeleteChatLogPath(filepath.Clean(entry.path)); err != nil {
+				log.Printf("删除聊天记录 %s 的索引失败: %v", entry.path, err)
+			}
+		}
+		return nil
+	}
+}
+
+// GetStorageUsage 查看各数据目录的占用情况与保留策略
+// GET /api/storage/usage
+func GetStorageUsage(c *gin.Context) {
+	policies := GetStorageConfig().Retention
+	dirs := make([]DirUsage, 0, len(retentionDirOrder))
+	var total int64
+	for _, name := range retentionDirOrder {
+		usage, err := dirUsage(name, policies[name])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("统计目录 %s 失败: %v", usage.Path, err),
+			})
+			return
+		}
+		dirs = append(dirs, usage)
+		total += usage.Bytes
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"dirs":        dirs,
+		"total_bytes": total,
+	})
+}
+
+// runStorageRetentionHandler 管理员立即执行一次清理
+func runStorageRetentionHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "stats": RunStorageRetention()})
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	coldClipsFile = "App_Data/cold_clips.json"
+	hotClipsDir   = "./downloads/hot_clips"
+)
+
+// coldClipExts 移到冷存储的媒体文件类型，字幕等小文件保留在本地
+var coldClipExts = map[string]bool{".mp4": true, ".m4a": true, ".mp3": true, ".webm": true, ".mkv": true, ".ts": true}
+
+// ColdClip 已移到对象存储的片段
+type ColdClip struct {
+	VideoID string    `json:"video_id"`
+	File    string    `json:"file"`
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	MovedAt time.Time `json:"moved_at"`
+}
+
+// TieringStats 一次存储分层的结果
+type TieringStats struct {
+	Moved  int   `json:"moved"`
+	Failed int   `json:"failed"`
+	Bytes  int64 `json:"bytes"`
+}
+
+var (
+	// coldClipsMu 保护内存中的冷存储片段位置，读取频繁（片段访问），只在变更时写文件
+	coldClipsMu     sync.RWMutex
+	coldClips       map[string]ColdClip
+	coldClipsLoaded bool
+
+	// storageTieringMu 保证同一时间只执行一次分层
+	storageTieringMu sync.Mutex
+)
+
+// coldClipID 片段在冷存储位置表中的标识
+func coldClipID(videoID, file string) string {
+	return filepath.Base(videoID) + "/" + filepath.Base(file)
+}
+
+// loadColdClipsLocked 首次使用时从文件读取冷存储位置表，调用方需持有写锁
+func loadColdClipsLocked() {
+	if coldClipsLoaded {
+		return
+	}
+	coldClipsLoaded = true
+	coldClips = map[string]ColdClip{}
+	data, err := os.ReadFile(coldClipsFile)
+	if err != nil {
+		return
+	}
+	var list []ColdClip
+	if err := json.Unmarshal(data, &list); err != nil {
+		log.Printf("读取冷存储片段列表失败: %v", err)
+		return
+	}
+	for _, clip := range list {
+		coldClips[coldClipID(clip.VideoID, clip.File)] = clip
+	}
+}
+
+// saveColdClipsLocked 写回冷存储位置表，调用方需持有写锁
+func saveColdClipsLocked() error {
+	list := make([]ColdClip, 0, len(coldClips))
+	for _, clip := range coldClips {
+		list = append(list, clip)
+	}
+	if err := os.MkdirAll(filepath.Dir(coldClipsFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(coldClipsFile, data, 0644)
+}
+
+// lookupColdClip 查找已移到冷存储的片段
+func lookupColdClip(videoID, file string) (ColdClip, bool) {
+	coldClipsMu.Lock()
+	loadColdClipsLocked()
+	coldClipsMu.Unlock()
+
+	coldClipsMu.RLock()
+	defer coldClipsMu.RUnlock()
+	clip, ok := coldClips[coldClipID(videoID, file)]
+	return clip, ok
+}
+
+// coldClipURL 冷存储片段的限时下载链接，片段不在冷存储或未配置对象存储时返回空字符串
+func coldClipURL(videoID, file string) string {
+	clip, ok := lookupColdClip(videoID, file)
+	if !ok {
+		return ""
+	}
+	store := newObjectStore()
+	if store == nil {
+		return ""
+	}
+	return store.SignedURL(clip.Key, time.Now())
+}
+
+// StartStorageTiering 启动后台任务，定期将超过保留天数的片段移到对象存储
+// 计划见配置 schedule.jobs.storage_tiering（默认每6小时）
+func StartStorageTiering() {
+	scheduleJob(scheduleJobStorageTiering, func() {
+		if GetStorageConfig().ColdAfterDays > 0 {
+			RunStorageTiering(context.Background())
+		}
+	})
+}
+
+// RunStorageTiering 将下载时间超过 cold_after_days 的片段上传到对象存储，校验大小一致后删除本地文件
+// 单个片段失败不影响其他片段，下次执行时重试
+func RunStorageTiering(ctx context.Context) TieringStats {
+	storageTieringMu.Lock()
+	defer storageTieringMu.Unlock()
+
+	var stats TieringStats
+	cfg := GetStorageConfig()
+	store := newObjectStore()
+	if cfg.ColdAfterDays <= 0 || store == nil {
+		return stats
+	}
+	cutoff := time.Now().AddDate(0, 0, -cfg.ColdAfterDays)
+
+	paths, _ := filepath.Glob(filepath.Join(hotClipsDir, "*", "*"))
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		// 片段任务进行中的目录跳过，避免移走正在识别或总结的文件
+		if !coldClipExts[strings.ToLower(filepath.Ext(path))] || clipArtifactsInFlight(filepath.Dir(path)) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		videoID := filepath.Base(filepath.Dir(path))
+		if err := moveClipToColdStorage(ctx, store, videoID, path, info.Size()); err != nil {
+			log.Printf("片段 %s 移到冷存储失败: %v", path, err)
+			stats.Failed++
+			continue
+		}
+		stats.Moved++
+		stats.Bytes += info.Size()
+	}
+
+	if stats.Moved > 0 || stats.Failed > 0 {
+		log.Printf("存储分层完成: 移动 %d 个片段 (%d 字节)，失败 %d 个", stats.Moved, stats.Bytes, stats.Failed)
+	}
+	return stats
+}
+
+// moveClipToColdStorage 上传单个片段并记录位置，记录成功后才删除本地文件
+func moveClipToColdStorage(ctx context.Context, store *objectStore, videoID, path string, size int64) error {
+	file := filepath.Base(path)
+	key := store.objectKey(videoID + "/" + file)
+
+	contentType := mime.TypeByExtension(filepath.Ext(file))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if err := store.PutFile(ctx, key, path, contentType); err != nil {
+		return err
+	}
+	remoteSize, err := store.Size(ctx, key)
+	if err != nil {
+		return fmt.Errorf("校验上传结果失败: %w", err)
+	}
+	if remoteSize != size {
+		return fmt.Errorf("上传后大小不一致: 本地 %d 字节，对象存储 %d 字节", size, remoteSize)
+	}
+
+	coldClipsMu.Lock()
+	loadColdClipsLocked()
+	coldClips[coldClipID(videoID, file)] = ColdClip{VideoID: videoID, File: file, Key: key, Size: size, MovedAt: time.Now()}
+	err = saveColdClipsLocked()
+	if err != nil {
+		delete(coldClips, coldClipID(videoID, file))
+	}
+	coldClipsMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("保存冷存储位置失败: %w", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		log.Printf("删除已移到冷存储的本地片段失败: %v", err)
+	}
+	_ = appendErrorLog("storage-audit.log", fmt.Sprintf("%s\tCOLD\t%s\t%s\t%d\n",
+		time.Now().Format(time.RFC3339), coldClipID(videoID, file), key, size))
+	return nil
+}
+
+// runStorageTieringHandler 管理员立即执行一次存储分层
+func runStorageTieringHandler(c *gin.Context) {
+	cfg := GetStorageConfig()
+	if cfg.ColdAfterDays <= 0 || newObjectStore() == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "未启用存储分层"})
+		return
+	}
+	stats := RunStorageTiering(c.Request.Context())
+	c.JSON(http.StatusOK, gin.H{"success": true, "stats": stats})
+}
+
+// listColdClipsHandler 管理员查看已移到冷存储的片段
+func listColdClipsHandler(c *gin.Context) {
+	coldClipsMu.Lock()
+	loadColdClipsLocked()
+	coldClipsMu.Unlock()
+
+	coldClipsMu.RLock()
+	clips := make([]ColdClip, 0, len(coldClips))
+	var total int64
+	for _, clip := range coldClips {
+		if videoID := c.Query("video_id"); videoID != "" && clip.VideoID != videoID {
+			continue
+		}
+		clips = append(clips, clip)
+		total += clip.Size
+	}
+	coldClipsMu.RUnlock()
+	sort.Slice(clips, func(i, j int) bool { return clips[i].MovedAt.After(clips[j].MovedAt) })
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"count":   len(clips),
+		"bytes":   total,
+		"clips":   clips,
+	})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultOfflineConfirmations 直播中的主播连续多少次检查为离线才判定下播
+	defaultOfflineConfirmations = 2
+	// defaultStreamEndGraceSeconds 判定下播后等待多久才开始下播处理，期间恢复直播则取消
+	defaultStreamEndGraceSeconds = 300
+)
+
+// pendingStreamEnd 已判定下播、仍在宽限期内等待执行的下播处理
+type pendingStreamEnd struct {
+	job     PersistedJob
+	endedAt time.Time
+	timer   *time.Timer
+}
+
+// streamEndTracker 下播判定去抖：单次检查失败或接口短暂返回离线不会立即触发下播处理，
+// 需连续多次检查为离线才判定下播；判定后的下播处理在宽限期后才加入处理队列，主播在宽限期内恢复直播时取消
+type streamEndTracker struct {
+	mu            sync.Mutex
+	offlineStreak map[string]int
+	pending       map[string]*pendingStreamEnd
+}
+
+var streamEnds = &streamEndTracker{
+	offlineStreak: make(map[string]int),
+	pending:       make(map[string]*pendingStreamEnd),
+}
+
+func streamEndKey(platform, streamerID string) string {
+	return platform + ":" + streamerID
+}
+
+// observeOffline 记录直播中的主播一次离线检查结果，返回连续离线次数以及是否已达到判定下播所需的次数
+func (t *streamEndTracker) observeOffline(platform, streamerID string, confirmations int) (int, bool) {
+	if confirmations <= 0 {
+		confirmations = defaultOfflineConfirmations
+	}
+	key := streamEndKey(platform, streamerID)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.offlineStreak[key]++
+	streak := t.offlineStreak[key]
+	if streak < confirmations {
+		return streak, false
+	}
+	delete(t.offlineStreak, key)
+	return streak, true
+}
+
+// observeLive 记录一次直播检查结果：清除连续离线计数，并取消宽限期内尚未开始的下播处理
+// 返回 true 表示本次直播是宽限期内的恢复而不是新的开播，调用方不应发送开播通知
+func (t *streamEndTracker) observeLive(platform, streamerID string) bool {
+	key := streamEndKey(platform, streamerID)
+
+	t.mu.Lock()
+	delete(t.offlineStreak, key)
+	pending, ok := t.pending[key]
+	if ok {
+		delete(t.pending, key)
+	}
+	t.mu.Unlock()
+
+	if !ok || !pending.timer.Stop() {
+		return false
+	}
+	removePersistedJob(pending.job)
+	pipelineSLO.discardStreamEnded(pending.job.Platform, pending.job.Streamer)
+	log.Printf("↩️ %s 在下播 %s 后恢复直播，取消下播处理", pending.job.StreamerName, time.Since(pending.endedAt).Round(time.Second))
+	return true
+}
+
+// scheduleEnd 判定下播后持久化下播处理，宽限期结束后加入处理队列
+// 宽限期内服务重启时，任务由 ResumePersistedJobs 恢复执行
+func (t *streamEndTracker) scheduleEnd(job PersistedJob, graceSeconds int, run func()) {
+	if graceSeconds <= 0 {
+		graceSeconds = defaultStreamEndGraceSeconds
+	}
+	key := streamEndKey(job.Platform, job.StreamerID)
+	enqueuePersistedJob(job)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if previous, ok := t.pending[key]; ok {
+		previous.timer.Stop()
+	}
+	pending := &pendingStreamEnd{job: job, endedAt: time.Now()}
+	pending.timer = time.AfterFunc(time.Duration(graceSeconds)*time.Second, func() {
+		t.mu.Lock()
+		if t.pending[key] == pending {
+			delete(t.pending, key)
+		}
+		t.mu.Unlock()
+		submitProcessingJob(job, run)
+	})
+	t.pending[key] = pending
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"subtuber-services/models"
+)
+
+// streamerAliasMu 串行化显示名称的更新，避免并发检查时重复写入别名
+var streamerAliasMu sync.Mutex
+
+// RecordStreamerDisplayName 平台返回的显示名称与记录不一致时更新名称，并把旧名称加入别名列表
+func RecordStreamerDisplayName(streamerID, displayName string) {
+	displayName = strings.TrimSpace(displayName)
+	if streamerID == "" || displayName == "" {
+		return
+	}
+
+	streamerAliasMu.Lock()
+	defer streamerAliasMu.Unlock()
+
+	config, err := GetTrackedStreamerData()
+	if err != nil {
+		return
+	}
+
+	for i := range config.Streamers {
+		streamer := &config.Streamers[i]
+		if !strings.EqualFold(streamer.ID, streamerID) {
+			continue
+		}
+		if strings.EqualFold(streamer.Name, displayName) {
+			return
+		}
+
+		oldName := streamer.Name
+		if oldName != "" && !containsFold(streamer.Aliases, oldName) {
+			streamer.Aliases = append(streamer.Aliases, oldName)
+		}
+		streamer.Name = displayName
+
+		if err := UpdateTrackedStreamerData(config); err != nil {
+			log.Printf("更新主播 %s 的显示名称失败: %v", streamerID, err)
+			return
+		}
+		publicProfileCache.Delete(strings.ToLower(streamer.ID))
+		log.Printf("主播 %s 的显示名称已从 %q 变更为 %q", streamer.ID, oldName, displayName)
+		return
+	}
+}
+
+// ResolveStreamer 按主播ID、当前显示名称、曾用名或平台账号查找主播，未找到时返回 nil
+func ResolveStreamer(query string) *models.StreamerInfo {
+	query = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(query), "@"))
+	if query == "" {
+		return nil
+	}
+
+	config, err := GetTrackedStreamerData()
+	if err != nil {
+		return nil
+	}
+
+	// 主播ID优先，避免与其他主播的曾用名冲突
+	for i := range config.Streamers {
+		if strings.EqualFold(config.Streamers[i].ID, query) {
+			streamer := config.Streamers[i]
+			return &streamer
+		}
+	}
+	for i := range config.Streamers {
+		streamer := config.Streamers[i]
+		if strings.EqualFold(streamer.Name, query) || containsFold(streamer.Aliases, query) ||
+			strings.EqualFold(streamer.YouTubeChannelID, query) || containsFold(platformHandles(streamer), query) {
+			return &streamer
+		}
+	}
+	return nil
+}
+
+// streamerMatchesSearch 主播ID、显示名称、曾用名或平台账号是否包含搜索词
+func streamerMatchesSearch(streamer models.StreamerInfo, query string) bool {
+	query = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query), "@"))
+	if query == "" {
+		return true
+	}
+
+	candidates := append([]string{streamer.ID, streamer.Name, streamer.YouTubeChannelID}, streamer.Aliases...)
+	candidates = append(candidates, platformHandles(streamer)...)
+	for _, candidate := range candidates {
+		if candidate != "" && strings.Contains(strings.ToLower(candidate), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// platformHandles 返回主播各平台地址中的账号名（地址最后一段）
+func platformHandles(streamer models.StreamerInfo) []string {
+	handles := make([]string, 0, len(streamer.Platforms))
+	for _, p := range streamer.Platforms {
+		if handle := strings.TrimPrefix(platformURLHandle(p.URL), "@"); handle != "" {
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
+
+// containsFold 忽略大小写判断列表中是否包含指定字符串
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"subtuber-services/models"
+
+	"github.com/gin-gonic/gin"
+	cache "github.com/patrickmn/go-cache"
+)
+
+const (
+	// 统计的最近录像数
+	defaultAnalyticsVODs = 20
+	maxAnalyticsVODs     = 100
+	// analyticsTopChatters 返回的发言最多的观众数
+	analyticsTopChatters = 10
+	// analyticsTopEmotes 跟踪趋势的表情数
+	analyticsTopEmotes = 10
+	// analyticsVODEmotes 每个录像附带的最常用表情数
+	analyticsVODEmotes = 5
+)
+
+// analyticsCache 缓存主播统计，与公开主页相同的过期时间；统计需要读取全部聊天记录
+var analyticsCache = cache.New(publicProfileMaxAge*time.Second, 10*time.Minute)
+
+// AnalyticsVOD 单个录像的统计，录像按开播时间从早到晚排列，即各项指标的趋势
+type AnalyticsVOD struct {
+	VideoID         string       `json:"video_id"`
+	Title           string       `json:"title"`
+	CreatedAt       string       `json:"created_at"`
+	DurationSeconds float64      `json:"duration_seconds"`
+	Comments        int          `json:"comments"`  // 聊天记录中的弹幕数，聊天记录已清理时为 0
+	ChatRate        float64      `json:"chat_rate"` // 每分钟弹幕数
+	Chatters        int          `json:"chatters"`  // 发言的观众数，没有聊天记录时为 0
+	HotMoments      int          `json:"hot_moments"`
+	MomentsPerHour  float64      `json:"moments_per_hour"`
+	TopEmotes       []EmoteCount `json:"top_emotes"`
+}
+
+// ChatterCount 一位观众在统计范围内的发言数
+type ChatterCount struct {
+	Author   string `json:"author"`
+	AuthorID string `json:"author_id,omitempty"`
+	Messages int    `json:"messages"`
+	VODs     int    `json:"vods"` // 发言过的录像数
+}
+
+// EmoteTrend 一个表情在各录像中的使用次数，Counts 与 vods 的顺序一致
+type EmoteTrend struct {
+	Emote  string `json:"emote"`
+	ID     string `json:"id,omitempty"`
+	Total  int    `json:"total"`
+	Counts []int  `json:"counts"`
+}
+
+// StreamerAnalytics 主播已分析录像的汇总统计
+type StreamerAnalytics struct {
+	StreamerID         string         `json:"streamer_id"`
+	VODs               []AnalyticsVOD `json:"vods"`
+	TotalHours         float64        `json:"total_hours"`
+	AvgDurationSeconds float64        `json:"avg_duration_seconds"`
+	AvgChatRate        float64        `json:"avg_chat_rate"`    // 保留聊天记录的录像合计的每分钟弹幕数
+	MomentsPerHour     float64        `json:"moments_per_hour"` // 所有录像合计的每小时热点数
+	DurationTrend      float64        `json:"duration_trend"`   // 后一半录像相对前一半的平均时长变化（百分比），录像少于 2 个时为 0
+	ChatRateTrend      float64        `json:"chat_rate_trend"`  // 后一半录像相对前一半的平均弹幕速度变化（百分比）
+	TopChatters        []ChatterCount `json:"top_chatters"`
+	EmoteTrends        []EmoteTrend   `json:"emote_trends"`
+	GeneratedAt        time.Time      `json:"generated_at"`
+}
+
+// round2 保留两位小数
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// halfTrend 后一半相对前一半平均值的变化百分比
+func halfTrend(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	mean := func(vs []float64) float64 {
+		sum := 0.0
+		for _, v := range vs {
+			sum += v
+		}
+		return sum / float64(len(vs))
+	}
+	half := len(values) / 2
+	before, after := mean(values[:half]), mean(values[len(values)-half:])
+	if before == 0 {
+		return 0
+	}
+	return round2((after - before) / before * 100)
+}
+
+// buildStreamerAnalytics 汇总主播最近 limit 个公开录像的分析结果与聊天记录
+// 弹幕、观众与表情统计只包含仍保留聊天记录的录像
+func buildStreamerAnalytics(streamerID string, limit int) *StreamerAnalytics {
+	analytics := &StreamerAnalytics{
+		StreamerID:  streamerID,
+		VODs:        []AnalyticsVOD{},
+		TopChatters: []ChatterCount{},
+		EmoteTrends: []EmoteTrend{},
+		GeneratedAt: time.Now(),
+	}
+
+	results := loadStreamerAnalysisResults(streamerID)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	// 从早到晚排列，便于前端直接绘制趋势
+	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+		results[i], results[j] = results[j], results[i]
+	}
+
+	claim := GetStreamerClaim(streamerID)
+	chatters := map[string]*ChatterCount{}
+	vodEmotes := make([]map[string]int, len(results))
+	emoteTotals := map[string]int{}
+	emoteIDs := map[string]string{}
+	var totalSeconds, chatSeconds float64
+	var totalComments, totalMoments int
+	var durations, chatRates []float64
+
+	for i, result := range results {
+		moments := result.HotMoments
+		if claim != nil {
+			moments = claim.visibleMoments(result.VideoID, moments)
+		}
+		moments = withoutTakenDownClips(result.VideoID, moments)
+
+		vod := AnalyticsVOD{
+			VideoID:         result.VideoID,
+			Title:           result.VideoInfo.Title,
+			CreatedAt:       result.VideoInfo.CreatedAt,
+			DurationSeconds: parseVideoDuration(result.VideoInfo.Duration),
+			HotMoments:      len(moments),
+			TopEmotes:       []EmoteCount{},
+		}
+
+		// 发言最多的观众：Twitch 按用户 ID 合并（改名后仍为同一人），YouTube 按名称
+		seen := map[string]bool{}
+		lastOffset := 0.0
+		err := eachChatMessage(result.VideoID, func(msg models.ChatMessage) {
+			vod.Comments++
+			lastOffset = math.Max(lastOffset, msg.OffsetSeconds)
+			key := msg.AuthorID
+			if key == "" {
+				key = strings.ToLower(msg.Author)
+			}
+			if key == "" {
+				return
+			}
+			chatter, ok := chatters[key]
+			if !ok {
+				chatter = &ChatterCount{AuthorID: msg.AuthorID}
+				chatters[key] = chatter
+			}
+			chatter.Author = msg.Author // 使用最近的显示名
+			chatter.Messages++
+			if !seen[key] {
+				seen[key] = true
+				chatter.VODs++
+			}
+		})
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("读取录像 %s 的聊天记录失败: %v", result.VideoID, err)
+		}
+		vod.Chatters = len(seen)
+
+		// 录像信息没有时长时以最后一条弹幕的时间估算
+		if vod.DurationSeconds <= 0 {
+			vod.DurationSeconds = math.Round(lastOffset)
+		}
+		if vod.DurationSeconds > 0 {
+			vod.MomentsPerHour = round2(float64(vod.HotMoments) / (vod.DurationSeconds / 3600))
+			totalSeconds += vod.DurationSeconds
+			totalMoments += vod.HotMoments
+			durations = append(durations, vod.DurationSeconds)
+			if err == nil {
+				vod.ChatRate = round2(float64(vod.Comments) / (vod.DurationSeconds / 60))
+				chatSeconds += vod.DurationSeconds
+				totalComments += vod.Comments
+				chatRates = append(chatRates, vod.ChatRate)
+			}
+		}
+
+		if usage, err := emoteUsageForVideo(result.VideoID); err == nil {
+			counts := map[string]int{}
+			for _, emote := range usage.top(0, math.Inf(1), 0) {
+				counts[emote.Emote] = emote.Count
+				emoteTotals[emote.Emote] += emote.Count
+				if emote.ID != "" {
+					emoteIDs[emote.Emote] = emote.ID
+				}
+			}
+			vodEmotes[i] = counts
+			vod.TopEmotes = usage.top(0, math.Inf(1), analyticsVODEmotes)
+		}
+
+		analytics.VODs = append(analytics.VODs, vod)
+	}
+
+	analytics.TotalHours = round2(totalSeconds / 3600)
+	if len(durations) > 0 {
+		analytics.AvgDurationSeconds = math.Round(totalSeconds / float64(len(durations)))
+	}
+	if totalSeconds > 0 {
+		analytics.MomentsPerHour = round2(float64(totalMoments) / (totalSeconds / 3600))
+	}
+	if chatSeconds > 0 {
+		analytics.AvgChatRate = round2(float64(totalComments) / (chatSeconds / 60))
+	}
+	analytics.DurationTrend = halfTrend(durations)
+	analytics.ChatRateTrend = halfTrend(chatRates)
+
+	for _, chatter := range chatters {
+		analytics.TopChatters = append(analytics.TopChatters, *chatter)
+	}
+	sort.Slice(analytics.TopChatters, func(i, j int) bool {
+		a, b := analytics.TopChatters[i], analytics.TopChatters[j]
+		if a.Messages != b.Messages {
+			return a.Messages > b.Messages
+		}
+		return a.Author < b.Author
+	})
+	if len(analytics.TopChatters) > analyticsTopChatters {
+		analytics.TopChatters = analytics.TopChatters[:analyticsTopChatters]
+	}
+
+	for emote, total := range emoteTotals {
+		analytics.EmoteTrends = append(analytics.EmoteTrends, EmoteTrend{Emote: emote, ID: emoteIDs[emote], Total: total})
+	}
+	sort.Slice(analytics.EmoteTrends, func(i, j int) bool {
+		a, b := analytics.EmoteTrends[i], analytics.EmoteTrends[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Emote < b.Emote
+	})
+	if len(analytics.EmoteTrends) > analyticsTopEmotes {
+		analytics.EmoteTrends = analytics.EmoteTrends[:analyticsTopEmotes]
+	}
+	for i := range analytics.EmoteTrends {
+		trend := &analytics.EmoteTrends[i]
+		trend.Counts = make([]int, len(vodEmotes))
+		for j, counts := range vodEmotes {
+			trend.Counts[j] = counts[trend.Emote]
+		}
+	}
+	return analytics
+}
+
+// GetStreamerAnalytics 主播已分析录像的汇总统计：平均弹幕速度、每小时热点数、发言最多的观众、表情趋势与直播时长趋势
+// 只统计公开录像；vods 为统计的最近录像数（默认 20，最大 100）
+// GET /api/streamers/:id/analytics?vods=20
+func GetStreamerAnalytics(c *gin.Context) {
+	streamer := ResolveStreamer(c.Param("id"))
+	if streamer == nil || streamer.Visibility == "private" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "主播不存在或未公开",
+		})
+		return
+	}
+
+	limit := defaultAnalyticsVODs
+	if v := c.Query("vods"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAnalyticsVODs {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "vods 必须为 1 到 100 之间的整数",
+			})
+			return
+		}
+		limit = n
+	}
+
+	key := streamer.ID + "|" + strconv.Itoa(limit)
+	var analytics *StreamerAnalytics
+	if cached, found := analyticsCache.Get(key); found {
+		analytics = cached.(*StreamerAnalytics)
+	} else {
+		analytics = buildStreamerAnalytics(streamer.ID, limit)
+		analyticsCache.SetDefault(key, analytics)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"analytics": analytics,
+	})
+}
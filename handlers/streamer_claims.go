@@ -0,0 +1,683 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"subtuber-services/models"
+	"subtuber-services/pathsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+const streamerClaimsFile = "App_Data/streamer_claims.json"
+
+// claimTokenTTL 验证码的有效期，主播需要在此期间内完成验证
+const claimTokenTTL = 24 * time.Hour
+
+// 主播认领的验证方式
+const (
+	claimMethodDescription = "description"  // 在频道简介中加入验证码
+	claimMethodTwitchOAuth = "twitch_oauth" // 通过已关联的 Twitch 账号证明身份
+)
+
+// StreamerClaim 主播对 LumiTime 主页的认领记录
+type StreamerClaim struct {
+	StreamerID string    `json:"streamer_id"`
+	UserHash   string    `json:"user_hash"`
+	Platform   string    `json:"platform"`
+	Method     string    `json:"method"`
+	VerifiedAt time.Time `json:"verified_at"`
+
+	// 主播对公开内容的管理设置
+	RequireApproval bool                 `json:"require_approval"`           // 只公开已审核的热点
+	HiddenMoments   map[string][]float64 `json:"hidden_moments,omitempty"`   // 视频ID -> 隐藏的热点偏移
+	ApprovedMoments map[string][]float64 `json:"approved_moments,omitempty"` // 视频ID -> 已审核的热点偏移
+}
+
+// pendingClaim 等待验证的认领请求
+type pendingClaim struct {
+	UserHash  string    `json:"user_hash"`
+	Platform  string    `json:"platform"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// streamerClaimsData 认领数据文件
+type streamerClaimsData struct {
+	Claims  map[string]*StreamerClaim `json:"claims"`  // streamerID -> 认领记录
+	Pending map[string]pendingClaim   `json:"pending"` // streamerID/userHash -> 待验证请求
+}
+
+var streamerClaimsMu sync.Mutex
+
+// loadStreamerClaims 读取认领数据，调用方需持有锁
+func loadStreamerClaims() (*streamerClaimsData, error) {
+	data := &streamerClaimsData{Claims: map[string]*StreamerClaim{}, Pending: map[string]pendingClaim{}}
+	b, err := os.ReadFile(streamerClaimsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, data); err != nil {
+		return nil, err
+	}
+	if data.Claims == nil {
+		data.Claims = map[string]*StreamerClaim{}
+	}
+	if data.Pending == nil {
+		data.Pending = map[string]pendingClaim{}
+	}
+	return data, nil
+}
+
+// saveStreamerClaims 写回认领数据，并清除过期的待验证请求，调用方需持有锁
+func saveStreamerClaims(data *streamerClaimsData) error {
+	for key, p := range data.Pending {
+		if time.Now().After(p.ExpiresAt) {
+			delete(data.Pending, key)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(streamerClaimsFile), 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(streamerClaimsFile, b, 0644)
+}
+
+// GetStreamerClaim 返回主播的认领记录，未认领时返回 nil
+func GetStreamerClaim(streamerID string) *StreamerClaim {
+	streamerClaimsMu.Lock()
+	defer streamerClaimsMu.Unlock()
+
+	data, err := loadStreamerClaims()
+	if err != nil {
+		return nil
+	}
+	return data.Claims[strings.ToLower(streamerID)]
+}
+
+// isStreamerOwner 用户是否已认领该主播
+func isStreamerOwner(userHash, streamerID string) bool {
+	claim := GetStreamerClaim(streamerID)
+	return claim != nil && claim.UserHash == userHash
+}
+
+// renameStreamerClaimsUser 将认领记录迁移到新的 userHash
+func renameStreamerClaimsUser(oldHash, newHash string) error {
+	streamerClaimsMu.Lock()
+	defer streamerClaimsMu.Unlock()
+
+	data, err := loadStreamerClaims()
+	if err != nil {
+		return err
+	}
+	for _, claim := range data.Claims {
+		if claim.UserHash == oldHash {
+			claim.UserHash = newHash
+		}
+	}
+	for key, p := range data.Pending {
+		if p.UserHash == oldHash {
+			delete(data.Pending, key)
+		}
+	}
+	return saveStreamerClaims(data)
+}
+
+// streamerPlatformHandle 主播在指定平台的账号（YouTube 优先使用频道ID）
+func streamerPlatformHandle(streamer *models.StreamerInfo, platform string) string {
+	if platform == "youtube" && streamer.YouTubeChannelID != "" {
+		return streamer.YouTubeChannelID
+	}
+	for _, p := range streamer.Platforms {
+		if strings.EqualFold(p.Platform, platform) {
+			parts := strings.Split(strings.TrimRight(p.URL, "/"), "/")
+			return strings.TrimPrefix(parts[len(parts)-1], "@")
+		}
+	}
+	return ""
+}
+
+// resolveClaimStreamer 解析路径中的主播，并确认当前用户已登录
+func resolveClaimStreamer(c *gin.Context) (*models.StreamerInfo, string, bool) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil || userHash == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "未登录或登录已过期"})
+		return nil, "", false
+	}
+
+	streamer := ResolveStreamer(c.Param("id"))
+	if streamer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "主播不存在"})
+		return nil, "", false
+	}
+	return streamer, userHash, true
+}
+
+// GetStreamerClaimStatus 查看主播的认领状态，以及当前用户是否为认领者、是否有待验证的请求
+func GetStreamerClaimStatus(c *gin.Context) {
+	streamer, userHash, ok := resolveClaimStreamer(c)
+	if !ok {
+		return
+	}
+
+	streamerClaimsMu.Lock()
+	data, err := loadStreamerClaims()
+	streamerClaimsMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取认领记录失败: " + err.Error()})
+		return
+	}
+
+	resp := gin.H{"success": true, "streamer_id": streamer.ID, "claimed": false, "owner": false}
+	if claim, found := data.Claims[streamer.ID]; found {
+		resp["claimed"] = true
+		resp["owner"] = claim.UserHash == userHash
+		resp["verified_at"] = claim.VerifiedAt
+		if claim.UserHash == userHash {
+			resp["claim"] = claim
+		}
+	}
+	if p, found := data.Pending[streamer.ID+"/"+userHash]; found && time.Now().Before(p.ExpiresAt) {
+		resp["pending"] = gin.H{"platform": p.Platform, "token": p.Token, "expires_at": p.ExpiresAt}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// StartStreamerClaim 发起认领，返回需要加入频道简介的验证码
+func StartStreamerClaim(c *gin.Context) {
+	streamer, userHash, ok := resolveClaimStreamer(c)
+	if !ok || rejectImpersonated(c) {
+		return
+	}
+
+	var req struct {
+		Platform string `json:"platform"`
+	}
+	_ = c.ShouldBindJSON(&req)
+	platform := strings.ToLower(req.Platform)
+	if platform == "" && len(streamer.Platforms) > 0 {
+		platform = strings.ToLower(streamer.Platforms[0].Platform)
+	}
+	if streamerPlatformHandle(streamer, platform) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "该主播没有此平台的账号: " + platform})
+		return
+	}
+
+	tokenBytes := make([]byte, 6)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "生成验证码失败"})
+		return
+	}
+
+	streamerClaimsMu.Lock()
+	defer streamerClaimsMu.Unlock()
+
+	data, err := loadStreamerClaims()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取认领记录失败: " + err.Error()})
+		return
+	}
+	if claim, found := data.Claims[streamer.ID]; found {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "该主播主页已被认领", "owner": claim.UserHash == userHash})
+		return
+	}
+
+	pending := pendingClaim{
+		UserHash:  userHash,
+		Platform:  platform,
+		Token:     "lumitime-verify-" + hex.EncodeToString(tokenBytes),
+		ExpiresAt: time.Now().Add(claimTokenTTL),
+	}
+	data.Pending[streamer.ID+"/"+userHash] = pending
+	if err := saveStreamerClaims(data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "保存认领请求失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"platform":   platform,
+		"token":      pending.Token,
+		"expires_at": pending.ExpiresAt,
+		"message": fmt.Sprintf("请将验证码添加到 %s 频道简介中，然后调用验证接口（method=%s）；Twitch 也可以关联账号后使用 method=%s 验证",
+			platform, claimMethodDescription, claimMethodTwitchOAuth),
+	})
+}
+
+// VerifyStreamerClaim 验证认领请求，成功后当前用户获得主播主页的管理权限
+func VerifyStreamerClaim(c *gin.Context) {
+	streamer, userHash, ok := resolveClaimStreamer(c)
+	if !ok || rejectImpersonated(c) {
+		return
+	}
+
+	var req struct {
+		Method string `json:"method"`
+	}
+	_ = c.ShouldBindJSON(&req)
+	method := req.Method
+	if method == "" {
+		method = claimMethodDescription
+	}
+
+	streamerClaimsMu.Lock()
+	data, err := loadStreamerClaims()
+	streamerClaimsMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取认领记录失败: " + err.Error()})
+		return
+	}
+	if _, found := data.Claims[streamer.ID]; found {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "该主播主页已被认领"})
+		return
+	}
+
+	var platform string
+	switch method {
+	case claimMethodDescription:
+		pending, found := data.Pending[streamer.ID+"/"+userHash]
+		if !found || time.Now().After(pending.ExpiresAt) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "没有待验证的认领请求或验证码已过期，请重新发起认领"})
+			return
+		}
+		platform = pending.Platform
+		description, err := fetchChannelDescription(streamer, platform)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"success": false, "message": "获取频道简介失败: " + err.Error()})
+			return
+		}
+		if !strings.Contains(description, pending.Token) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "频道简介中未找到验证码，平台更新简介可能有几分钟延迟"})
+			return
+		}
+	case claimMethodTwitchOAuth:
+		platform = "twitch"
+		account := getLinkedTwitchAccount(userHash)
+		handle := streamerPlatformHandle(streamer, platform)
+		if account == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请先关联 Twitch 账号"})
+			return
+		}
+		if handle == "" || !strings.EqualFold(account.Login, handle) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "关联的 Twitch 账号与该主播的频道不一致"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "不支持的验证方式: " + method})
+		return
+	}
+
+	streamerClaimsMu.Lock()
+	defer streamerClaimsMu.Unlock()
+
+	// 重新读取，避免与并发的认领请求冲突
+	data, err = loadStreamerClaims()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取认领记录失败: " + err.Error()})
+		return
+	}
+	if _, found := data.Claims[streamer.ID]; found {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "该主播主页已被认领"})
+		return
+	}
+	claim := &StreamerClaim{
+		StreamerID: streamer.ID,
+		UserHash:   userHash,
+		Platform:   platform,
+		Method:     method,
+		VerifiedAt: time.Now(),
+	}
+	data.Claims[streamer.ID] = claim
+	delete(data.Pending, streamer.ID+"/"+userHash)
+	if err := saveStreamerClaims(data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "保存认领记录失败: " + err.Error()})
+		return
+	}
+
+	log.Printf("用户 %s 通过 %s 认领了主播 %s (%s)", userHash, method, streamer.ID, platform)
+	_ = appendErrorLog("streamer-claims.log", fmt.Sprintf("%s\tCLAIM\t%s\t%s\t%s\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), streamer.ID, userHash, platform, method))
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "认领成功", "claim": claim})
+}
+
+// ReleaseStreamerClaim 认领者放弃对主播主页的管理权限
+func ReleaseStreamerClaim(c *gin.Context) {
+	streamer, userHash, ok := resolveClaimStreamer(c)
+	if !ok || rejectImpersonated(c) {
+		return
+	}
+
+	streamerClaimsMu.Lock()
+	defer streamerClaimsMu.Unlock()
+
+	data, err := loadStreamerClaims()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取认领记录失败: " + err.Error()})
+		return
+	}
+	claim, found := data.Claims[streamer.ID]
+	if !found || claim.UserHash != userHash {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "只有认领者可以取消认领"})
+		return
+	}
+	delete(data.Claims, streamer.ID)
+	if err := saveStreamerClaims(data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "保存认领记录失败: " + err.Error()})
+		return
+	}
+	publicProfileCache.Delete(streamer.ID)
+
+	_ = appendErrorLog("streamer-claims.log", fmt.Sprintf("%s\tRELEASE\t%s\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), streamer.ID, userHash))
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "已取消认领"})
+}
+
+// fetchChannelDescription 获取主播频道的简介
+func fetchChannelDescription(streamer *models.StreamerInfo, platform string) (string, error) {
+	handle := streamerPlatformHandle(streamer, platform)
+	switch platform {
+	case "twitch":
+		tm := GetTwitchMonitor()
+		if tm == nil {
+			return "", fmt.Errorf("Twitch 监控未初始化")
+		}
+		user, err := tm.getUserInfo(handle)
+		if err != nil {
+			return "", err
+		}
+		return user.Description, nil
+	case "youtube":
+		ym := GetYouTubeMonitor()
+		if ym == nil {
+			return "", fmt.Errorf("YouTube 监控未初始化")
+		}
+		return ym.getChannelDescription(handle)
+	default:
+		return "", fmt.Errorf("不支持的平台: %s", platform)
+	}
+}
+
+// getChannelDescription 获取 YouTube 频道简介，参数可以是频道ID或 Handle
+func (ym *YouTubeMonitor) getChannelDescription(channel string) (string, error) {
+	query := "id=" + url.QueryEscape(channel)
+	if !youtubeChannelIDPattern.MatchString(channel) {
+		query = "forHandle=" + url.QueryEscape("@"+strings.TrimPrefix(channel, "@"))
+	}
+
+	resp, err := ym.makeRequestWithRetry("https://www.googleapis.com/youtube/v3/channels?part=snippet&" + query)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API返回错误状态 %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Items []struct {
+			Snippet struct {
+				Description string `json:"description"`
+			} `json:"snippet"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if len(result.Items) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrYouTubeChannelNotFound, channel)
+	}
+	return result.Items[0].Snippet.Description, nil
+}
+
+// StreamerOwnerMiddleware 只允许已认领该主播的用户访问
+func StreamerOwnerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		streamer, userHash, ok := resolveClaimStreamer(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+		if !isStreamerOwner(userHash, streamer.ID) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "message": "只有认领该主播主页的用户可以管理"})
+			return
+		}
+		c.Set("streamer", streamer)
+		c.Next()
+	}
+}
+
+// updateOwnedClaim 修改认领记录中的管理设置
+func updateOwnedClaim(streamerID string, update func(claim *StreamerClaim)) (*StreamerClaim, error) {
+	streamerClaimsMu.Lock()
+	defer streamerClaimsMu.Unlock()
+
+	data, err := loadStreamerClaims()
+	if err != nil {
+		return nil, err
+	}
+	claim, found := data.Claims[streamerID]
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	update(claim)
+	if err := saveStreamerClaims(data); err != nil {
+		return nil, err
+	}
+	publicProfileCache.Delete(streamerID)
+	return claim, nil
+}
+
+// UpdateOwnerSettings 认领者修改主页可见性与热点审核模式
+func UpdateOwnerSettings(c *gin.Context) {
+	streamer := c.MustGet("streamer").(*models.StreamerInfo)
+
+	var req struct {
+		Visibility      string `json:"visibility"`
+		RequireApproval *bool  `json:"require_approval"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的请求参数: " + err.Error()})
+		return
+	}
+	if req.Visibility != "" && req.Visibility != "public" && req.Visibility != "private" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "visibility 只能为 public 或 private"})
+		return
+	}
+
+	if req.Visibility != "" {
+		config, err := GetTrackedStreamerData()
+		if err == nil {
+			for i := range config.Streamers {
+				if config.Streamers[i].ID == streamer.ID {
+					config.Streamers[i].Visibility = req.Visibility
+				}
+			}
+			err = UpdateTrackedStreamerData(config)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "保存主页可见性失败: " + err.Error()})
+			return
+		}
+	}
+
+	claim, err := updateOwnedClaim(streamer.ID, func(claim *StreamerClaim) {
+		if req.RequireApproval != nil {
+			claim.RequireApproval = *req.RequireApproval
+		}
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "保存管理设置失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "设置已更新", "claim": claim})
+}
+
+// 热点管理操作
+const (
+	momentActionHide      = "hide"
+	momentActionShow      = "show"
+	momentActionApprove   = "approve"
+	momentActionUnapprove = "unapprove"
+)
+
+// ModerateHotMoment 认领者隐藏/恢复热点片段，或审核热点
+func ModerateHotMoment(c *gin.Context) {
+	streamer := c.MustGet("streamer").(*models.StreamerInfo)
+
+	var req struct {
+		VideoID       string  `json:"video_id" binding:"required"`
+		OffsetSeconds float64 `json:"offset_seconds"`
+		Action        string  `json:"action" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	owned := false
+	for _, result := range loadAllStreamerAnalysisResults(streamer.ID) {
+		if result.VideoID == req.VideoID {
+			owned = true
+			break
+		}
+	}
+	if !owned {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "该主播没有此录像的分析结果"})
+		return
+	}
+
+	var apply func(claim *StreamerClaim)
+	switch req.Action {
+	case momentActionHide, momentActionShow:
+		apply = func(claim *StreamerClaim) {
+			claim.HiddenMoments = toggleMomentOffset(claim.HiddenMoments, req.VideoID, req.OffsetSeconds, req.Action == momentActionHide)
+		}
+	case momentActionApprove, momentActionUnapprove:
+		apply = func(claim *StreamerClaim) {
+			claim.ApprovedMoments = toggleMomentOffset(claim.ApprovedMoments, req.VideoID, req.OffsetSeconds, req.Action == momentActionApprove)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "不支持的操作: " + req.Action})
+		return
+	}
+
+	claim, err := updateOwnedClaim(streamer.ID, apply)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "保存管理设置失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"hidden_moments":   claim.HiddenMoments[req.VideoID],
+		"approved_moments": claim.ApprovedMoments[req.VideoID],
+	})
+}
+
+// toggleMomentOffset 在视频的热点偏移列表中加入或移除一个偏移（1 秒内视为同一热点）
+func toggleMomentOffset(moments map[string][]float64, videoID string, offset float64, add bool) map[string][]float64 {
+	if moments == nil {
+		moments = map[string][]float64{}
+	}
+	offsets := moments[videoID][:0:0]
+	for _, o := range moments[videoID] {
+		if math.Abs(o-offset) >= 1 {
+			offsets = append(offsets, o)
+		}
+	}
+	if add {
+		offsets = append(offsets, offset)
+	}
+	if len(offsets) == 0 {
+		delete(moments, videoID)
+	} else {
+		moments[videoID] = offsets
+	}
+	return moments
+}
+
+// containsMoment 偏移列表中是否包含该热点
+func containsMoment(offsets []float64, offset float64) bool {
+	for _, o := range offsets {
+		if math.Abs(o-offset) < 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleMoments 过滤掉主播隐藏的热点；开启审核模式时只保留已审核的热点
+func (claim *StreamerClaim) visibleMoments(videoID string, moments []VodCommentData) []VodCommentData {
+	visible := make([]VodCommentData, 0, len(moments))
+	for _, moment := range moments {
+		if containsMoment(claim.HiddenMoments[videoID], moment.OffsetSeconds) {
+			continue
+		}
+		if claim.RequireApproval && !containsMoment(claim.ApprovedMoments[videoID], moment.OffsetSeconds) {
+			continue
+		}
+		visible = append(visible, moment)
+	}
+	return visible
+}
+
+// GetOwnerAnalytics 认领者查看完整的分析数据，包括订阅者专属录像、全部热点与时间序列
+func GetOwnerAnalytics(c *gin.Context) {
+	streamer := c.MustGet("streamer").(*models.StreamerInfo)
+	claim := GetStreamerClaim(streamer.ID)
+
+	type ownerVOD struct {
+		AnalysisResult
+		HiddenMoments   []float64 `json:"hidden_moments"`
+		ApprovedMoments []float64 `json:"approved_moments"`
+	}
+
+	vods := []ownerVOD{}
+	for _, result := range loadAllStreamerAnalysisResults(streamer.ID) {
+		// 时间序列单独存储时一并读取
+		if result.TimeSeriesFile != "" && len(result.TimeSeriesData) == 0 {
+			if data, err := loadTimeSeriesSidecar(pathsafe.Join("./analysis_results", result.VideoID), result.TimeSeriesFile); err == nil {
+				result.TimeSeriesData = data
+			}
+		}
+		vod := ownerVOD{AnalysisResult: result, HiddenMoments: []float64{}, ApprovedMoments: []float64{}}
+		if claim != nil {
+			if hidden := claim.HiddenMoments[result.VideoID]; hidden != nil {
+				vod.HiddenMoments = hidden
+			}
+			if approved := claim.ApprovedMoments[result.VideoID]; approved != nil {
+				vod.ApprovedMoments = approved
+			}
+		}
+		vods = append(vods, vod)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"streamer_id": streamer.ID,
+		"claim":       claim,
+		"vods":        vods,
+	})
+}
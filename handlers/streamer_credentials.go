@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const streamerCredentialsFile = "App_Data/streamer_credentials.json"
+
+// ErrCredentialsKeyMissing 未配置加密密钥时拒绝保存或读取凭据
+var ErrCredentialsKeyMissing = errors.New("未配置凭据加密密钥 (credentials.encryption_key)")
+
+// credentialsSecurityWarning 保存会员凭据时返回给管理员的安全提示
+const credentialsSecurityWarning = "会员凭据等同于账号登录状态：请使用专用的小号，" +
+	"不要使用个人主账号；凭据仅用于该主播的聊天与字幕下载，泄露或滥用可能导致账号被封禁"
+
+// YouTubeMemberCredentials 访问会员专属录像所需的凭据
+type YouTubeMemberCredentials struct {
+	Cookies string `json:"cookies"`            // Netscape cookies.txt 格式
+	POToken string `json:"po_token,omitempty"` // 可选的 PO Token，传给 yt-dlp
+}
+
+// encryptedCredential 加密存储的凭据
+type encryptedCredential struct {
+	Platform   string    `json:"platform"`
+	Nonce      string    `json:"nonce"`
+	Ciphertext string    `json:"ciphertext"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+var streamerCredentialsMu sync.Mutex
+
+// credentialsCipher 由配置的密钥派生 AES-256-GCM
+func credentialsCipher() (cipher.AEAD, error) {
+	secret := GetCredentialsConfig().EncryptionKey
+	if secret == "" {
+		return nil, ErrCredentialsKeyMissing
+	}
+	derived := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptCredential 加密凭据，凭据键（平台与主播ID）作为附加数据，防止密文被挪用到其他主播
+func encryptCredential(key, platform string, plaintext []byte) (encryptedCredential, error) {
+	aead, err := credentialsCipher()
+	if err != nil {
+		return encryptedCredential{}, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return encryptedCredential{}, err
+	}
+	ciphertext := aead.Seal(nil, nonce, plaintext, []byte(key))
+
+	return encryptedCredential{
+		Platform:   platform,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+		UpdatedAt:  time.Now(),
+	}, nil
+}
+
+// decryptCredential 解密凭据
+func decryptCredential(key string, enc encryptedCredential) ([]byte, error) {
+	aead, err := credentialsCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(enc.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(enc.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, nonce, ciphertext, []byte(key))
+}
+
+// credentialKey 凭据文件中的键：{platform}:{streamerID}
+func credentialKey(platform, streamerID string) string {
+	return platform + ":" + strings.ToLower(strings.TrimPrefix(streamerID, "@"))
+}
+
+// GetYouTubeMemberCredentials 读取主播的会员凭据，未配置时返回 nil
+func GetYouTubeMemberCredentials(streamerID string) *YouTubeMemberCredentials {
+	streamerCredentialsMu.Lock()
+	defer streamerCredentialsMu.Unlock()
+
+	all, err := loadStreamerCredentials()
+	if err != nil {
+		log.Printf("读取主播凭据失败: %v", err)
+		return nil
+	}
+
+	key := credentialKey("youtube", streamerID)
+	enc, ok := all[key]
+	if !ok {
+		return nil
+	}
+
+	plaintext, err := decryptCredential(key, enc)
+	if err != nil {
+		log.Printf("解密主播 %s 的会员凭据失败: %v", streamerID, err)
+		return nil
+	}
+
+	var creds YouTubeMemberCredentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil
+	}
+	return &creds
+}
+
+// SetYouTubeMemberCredentials 加密保存主播的会员凭据
+func SetYouTubeMemberCredentials(streamerID string, creds YouTubeMemberCredentials) error {
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+
+	key := credentialKey("youtube", streamerID)
+	enc, err := encryptCredential(key, "youtube", plaintext)
+	if err != nil {
+		return err
+	}
+
+	streamerCredentialsMu.Lock()
+	defer streamerCredentialsMu.Unlock()
+
+	all, err := loadStreamerCredentials()
+	if err != nil {
+		return err
+	}
+	all[key] = enc
+	return saveStreamerCredentials(all)
+}
+
+// DeleteYouTubeMemberCredentials 删除主播的会员凭据
+func DeleteYouTubeMemberCredentials(streamerID string) error {
+	streamerCredentialsMu.Lock()
+	defer streamerCredentialsMu.Unlock()
+
+	all, err := loadStreamerCredentials()
+	if err != nil {
+		return err
+	}
+	delete(all, credentialKey("youtube", streamerID))
+	return saveStreamerCredentials(all)
+}
+
+// CookieHeader 将 cookies.txt 内容转换为 youtube.com 请求使用的 Cookie 请求头
+func (c *YouTubeMemberCredentials) CookieHeader() string {
+	if c == nil {
+		return ""
+	}
+
+	var pairs []string
+	for _, line := range strings.Split(c.Cookies, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#HttpOnly_"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// domain, flag, path, secure, expiration, name, value
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 || !strings.HasSuffix(fields[0], "youtube.com") {
+			continue
+		}
+		pairs = append(pairs, fields[5]+"="+fields[6])
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// writeTempCookiesFile 将 cookies 写入仅当前用户可读的临时文件，供 yt-dlp 使用
+// 调用方负责删除返回的文件
+func (c *YouTubeMemberCredentials) writeTempCookiesFile() (string, error) {
+	f, err := os.CreateTemp("", "yt-cookies-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := f.Chmod(0600); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if _, err := f.WriteString(c.Cookies); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// loadStreamerCredentials 读取加密的主播凭据文件
+func loadStreamerCredentials() (map[string]encryptedCredential, error) {
+	return loadEncryptedCredentials(streamerCredentialsFile)
+}
+
+// saveStreamerCredentials 将加密的主播凭据写回文件
+func saveStreamerCredentials(all map[string]encryptedCredential) error {
+	return saveEncryptedCredentials(streamerCredentialsFile, all)
+}
+
+// loadEncryptedCredentials 读取加密的凭据文件，文件不存在时返回空
+func loadEncryptedCredentials(path string) (map[string]encryptedCredential, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]encryptedCredential{}, nil
+		}
+		return nil, err
+	}
+
+	all := map[string]encryptedCredential{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// saveEncryptedCredentials 将加密的凭据写回文件（仅当前用户可读写）
+func saveEncryptedCredentials(path string, all map[string]encryptedCredential) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// getYouTubeCredentialsHandler 查看主播是否配置了会员凭据（不返回凭据内容）
+func getYouTubeCredentialsHandler(c *gin.Context) {
+	streamerID := c.Param("id")
+
+	streamerCredentialsMu.Lock()
+	all, err := loadStreamerCredentials()
+	streamerCredentialsMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "读取主播凭据失败: " + err.Error(),
+		})
+		return
+	}
+
+	enc, ok := all[credentialKey("youtube", streamerID)]
+	resp := gin.H{
+		"success":     true,
+		"streamer_id": streamerID,
+		"configured":  ok,
+	}
+	if ok {
+		resp["updated_at"] = enc.UpdatedAt
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// setYouTubeCredentialsHandler 保存主播的会员凭据
+func setYouTubeCredentialsHandler(c *gin.Context) {
+	streamerID := c.Param("id")
+
+	var req YouTubeMemberCredentials
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Cookies) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: 需要 cookies.txt 格式的 cookies",
+		})
+		return
+	}
+	if req.CookieHeader() == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "cookies 中没有 youtube.com 的条目",
+		})
+		return
+	}
+
+	if err := SetYouTubeMemberCredentials(streamerID, req); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrCredentialsKeyMissing) {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": "保存主播凭据失败: " + err.Error(),
+		})
+		return
+	}
+
+	log.Printf("⚠️ 已更新主播 %s 的 YouTube 会员凭据", streamerID)
+	_ = appendErrorLog("credentials-audit.log", fmt.Sprintf("%s\tSET\tyoutube\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), streamerID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "会员凭据已加密保存",
+		"warning": credentialsSecurityWarning,
+	})
+}
+
+// deleteYouTubeCredentialsHandler 删除主播的会员凭据
+func deleteYouTubeCredentialsHandler(c *gin.Context) {
+	streamerID := c.Param("id")
+
+	if err := DeleteYouTubeMemberCredentials(streamerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "删除主播凭据失败: " + err.Error(),
+		})
+		return
+	}
+
+	_ = appendErrorLog("credentials-audit.log", fmt.Sprintf("%s\tDELETE\tyoutube\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), streamerID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "会员凭据已删除",
+	})
+}
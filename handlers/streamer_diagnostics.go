@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const streamerDiagnosticsFile = "App_Data/streamer_diagnostics.json"
+
+// 记录问题的处理阶段，每个主播每个阶段只保留最近一次问题，该阶段成功后清除
+const (
+	diagStageResolve      = "platform_resolve" // 解析平台账号（Twitch 用户、YouTube 频道ID）
+	diagStageLiveCheck    = "live_check"       // 检查直播状态
+	diagStageVODList      = "vod_list"         // 获取录像列表
+	diagStageChatDownload = "chat_download"    // 下载录像聊天记录
+	diagStageAnalysis     = "analysis"         // 分析并保存结果
+)
+
+// diagStageDescriptions 各阶段出问题时对用户的说明
+var diagStageDescriptions = map[string]string{
+	diagStageResolve:      "无法解析主播的平台账号，账号可能已改名、被封禁或地址填写有误",
+	diagStageLiveCheck:    "检查直播状态失败，开播与下播可能无法被及时发现",
+	diagStageVODList:      "获取录像列表失败，下播后不会处理新录像",
+	diagStageChatDownload: "下载录像聊天记录失败，录像可能已删除、设为订阅者/会员专属或尚未生成",
+	diagStageAnalysis:     "分析或保存结果失败",
+}
+
+// youtubeQuotaResetWindow YouTube API 配额每天重置，超过该时间的配额耗尽记录不再提示
+const youtubeQuotaResetWindow = 24 * time.Hour
+
+// StreamerIssue 主播某个处理阶段最近一次的问题
+type StreamerIssue struct {
+	Stage   string    `json:"stage"`
+	VideoID string    `json:"video_id,omitempty"`
+	Error   string    `json:"error"`
+	Count   int       `json:"count"` // 连续出现的次数
+	FirstAt time.Time `json:"first_at"`
+	LastAt  time.Time `json:"last_at"`
+}
+
+var (
+	streamerIssuesMu     sync.Mutex
+	streamerIssues       map[string]map[string]*StreamerIssue // 主播ID -> 阶段 -> 问题
+	youtubeQuotaMu       sync.Mutex
+	youtubeQuotaExhausts time.Time
+)
+
+// secretQueryPattern 错误信息中的 API Key 等查询参数，返回给用户前需要隐藏
+var secretQueryPattern = regexp.MustCompile(`(?i)\b(key|token|access_token|client_secret)=[^&\s"]+`)
+
+// diagnosticsStreamerID 将平台账号、频道ID或主播ID解析为主播ID，未追踪的账号按小写原样记录
+func diagnosticsStreamerID(account string) string {
+	if streamer := ResolveStreamer(account); streamer != nil {
+		return streamer.ID
+	}
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(account), "@"))
+}
+
+// loadStreamerIssuesLocked 首次使用时从文件读取问题记录，调用方需持有 streamerIssuesMu
+func loadStreamerIssuesLocked() {
+	if streamerIssues != nil {
+		return
+	}
+	streamerIssues = map[string]map[string]*StreamerIssue{}
+	data, err := os.ReadFile(streamerDiagnosticsFile)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &streamerIssues); err != nil {
+		log.Printf("读取主播诊断记录失败: %v", err)
+		streamerIssues = map[string]map[string]*StreamerIssue{}
+	}
+}
+
+// saveStreamerIssuesLocked 将问题记录写回文件，调用方需持有 streamerIssuesMu
+func saveStreamerIssuesLocked() {
+	err := os.MkdirAll(filepath.Dir(streamerDiagnosticsFile), 0755)
+	if err == nil {
+		var data []byte
+		if data, err = json.MarshalIndent(streamerIssues, "", "  "); err == nil {
+			err = os.WriteFile(streamerDiagnosticsFile, data, 0644)
+		}
+	}
+	if err != nil {
+		log.Printf("保存主播诊断记录失败: %v", err)
+	}
+}
+
+// recordStreamerIssue 记录主播在某个阶段遇到的问题，account 可以是平台账号、频道ID或主播ID
+func recordStreamerIssue(account, stage, videoID string, issueErr error) {
+	if account == "" || issueErr == nil {
+		return
+	}
+	id := diagnosticsStreamerID(account)
+
+	streamerIssuesMu.Lock()
+	defer streamerIssuesMu.Unlock()
+	loadStreamerIssuesLocked()
+
+	stages := streamerIssues[id]
+	if stages == nil {
+		stages = map[string]*StreamerIssue{}
+		streamerIssues[id] = stages
+	}
+	now := time.Now()
+	issue := stages[stage]
+	if issue == nil {
+		issue = &StreamerIssue{Stage: stage, FirstAt: now}
+		stages[stage] = issue
+	}
+	issue.VideoID = videoID
+	issue.Error = strings.TrimSpace(secretQueryPattern.ReplaceAllString(issueErr.Error(), "$1=***"))
+	issue.Count++
+	issue.LastAt = now
+	saveStreamerIssuesLocked()
+}
+
+// clearStreamerIssue 阶段执行成功后清除该阶段的问题记录
+func clearStreamerIssue(account, stage string) {
+	if account == "" {
+		return
+	}
+	id := diagnosticsStreamerID(account)
+
+	streamerIssuesMu.Lock()
+	defer streamerIssuesMu.Unlock()
+	loadStreamerIssuesLocked()
+
+	stages := streamerIssues[id]
+	if _, ok := stages[stage]; !ok {
+		return
+	}
+	delete(stages, stage)
+	if len(stages) == 0 {
+		delete(streamerIssues, id)
+	}
+	saveStreamerIssuesLocked()
+}
+
+// streamerIssuesFor 主播当前的问题记录，按最近发生时间排序
+func streamerIssuesFor(streamerID string) []StreamerIssue {
+	streamerIssuesMu.Lock()
+	defer streamerIssuesMu.Unlock()
+	loadStreamerIssuesLocked()
+
+	issues := []StreamerIssue{}
+	for _, issue := range streamerIssues[strings.ToLower(streamerID)] {
+		issues = append(issues, *issue)
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].LastAt.After(issues[j].LastAt) })
+	return issues
+}
+
+// markYouTubeQuotaExhausted 所有 YouTube API Key 的配额均已用尽
+func markYouTubeQuotaExhausted() {
+	youtubeQuotaMu.Lock()
+	youtubeQuotaExhausts = time.Now()
+	youtubeQuotaMu.Unlock()
+}
+
+// clearYouTubeQuotaExhausted YouTube API 请求成功，配额已恢复
+func clearYouTubeQuotaExhausted() {
+	youtubeQuotaMu.Lock()
+	youtubeQuotaExhausts = time.Time{}
+	youtubeQuotaMu.Unlock()
+}
+
+// youtubeQuotaExhaustedSince 配额耗尽的时间，未耗尽或已超过重置周期时返回零值
+func youtubeQuotaExhaustedSince() time.Time {
+	youtubeQuotaMu.Lock()
+	defer youtubeQuotaMu.Unlock()
+	if youtubeQuotaExhausts.IsZero() || time.Since(youtubeQuotaExhausts) > youtubeQuotaResetWindow {
+		return time.Time{}
+	}
+	return youtubeQuotaExhausts
+}
+
+// failedJobsForStreamer 主播失败的持久化任务
+func failedJobsForStreamer(streamerID string) []PersistedJob {
+	persistedJobsMu.Lock()
+	jobs, err := loadPersistedJobs()
+	persistedJobsMu.Unlock()
+	if err != nil {
+		return []PersistedJob{}
+	}
+
+	failed := []PersistedJob{}
+	for _, job := range jobs {
+		if job.State != persistedJobFailed {
+			continue
+		}
+		owner := job.StreamerID
+		if owner == "" && job.Streamer != "" {
+			owner = diagnosticsStreamerID(job.Streamer)
+		}
+		if strings.EqualFold(owner, streamerID) {
+			job.HotMoments = nil
+			job.Video = nil
+			job.Error = secretQueryPattern.ReplaceAllString(job.Error, "$1=***")
+			failed = append(failed, job)
+		}
+	}
+	return failed
+}
+
+// GetStreamerDiagnostics 说明主播可能缺少数据的原因：平台解析失败、配额耗尽、录像不可用、
+// 未达到处理门槛而跳过的录像，以及各阶段最近一次的错误
+// GET /api/streamers/:id/diagnostics
+func GetStreamerDiagnostics(c *gin.Context) {
+	streamer := ResolveStreamer(c.Param("id"))
+	if streamer == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "未追踪该主播，请先订阅或添加主播",
+		})
+		return
+	}
+
+	reasons := []string{}
+	platforms := make([]string, 0, len(streamer.Platforms))
+	hasYouTube := false
+	for _, p := range streamer.Platforms {
+		platforms = append(platforms, p.Platform)
+		if p.Platform == "youtube" {
+			hasYouTube = true
+		}
+	}
+	if len(platforms) == 0 {
+		reasons = append(reasons, "主播没有配置任何平台地址，无法检查直播与录像")
+	}
+
+	issues := streamerIssuesFor(streamer.ID)
+	for _, issue := range issues {
+		reason := diagStageDescriptions[issue.Stage]
+		if reason == "" {
+			reason = issue.Stage
+		}
+		if issue.VideoID != "" {
+			reason += fmt.Sprintf("（录像 %s）", issue.VideoID)
+		}
+		if issue.Count > 1 {
+			reason += fmt.Sprintf("，自 %s 起已连续失败 %d 次", issue.FirstAt.Format("2006-01-02 15:04"), issue.Count)
+		}
+		reasons = append(reasons, reason+"："+issue.Error)
+	}
+
+	quota := gin.H{"youtube_exhausted": false}
+	if since := youtubeQuotaExhaustedSince(); hasYouTube && !since.IsZero() {
+		quota = gin.H{"youtube_exhausted": true, "since": since}
+		reasons = append(reasons, fmt.Sprintf("YouTube API 配额已于 %s 用尽，配额重置前无法检查直播与获取录像", since.Format("2006-01-02 15:04")))
+	}
+
+	skipped := skippedVODsForStreamer(streamer.ID)
+	for _, vod := range skipped {
+		reasons = append(reasons, fmt.Sprintf("录像 %s 未达到处理门槛，未自动分析：%s", vod.VideoID, vod.Reason))
+	}
+
+	failedJobs := failedJobsForStreamer(streamer.ID)
+	for _, job := range failedJobs {
+		reasons = append(reasons, fmt.Sprintf("后台任务 %s 失败：%s", job.Stage, job.Error))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"streamer_id":  streamer.ID,
+		"name":         streamer.Name,
+		"platforms":    platforms,
+		"healthy":      len(reasons) == 0,
+		"reasons":      reasons,
+		"issues":       issues,
+		"quota":        quota,
+		"skipped_vods": skipped,
+		"failed_jobs":  failedJobs,
+	})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -26,18 +27,10 @@ var (
 	streamerFileMutex sync.Mutex
 	// 最后持久化时间
 	lastPersistTime time.Time
-	// 持久化间隔（5分钟）
-	persistInterval = 5 * time.Minute
 	// 默认主播配置文件路径
 	configPath = filepath.Join("App_Data", "tracked_streamers.json")
 	// 初始化标志
 	streamerServiceInitialized = false
-	// 定期持久化的 ticker
-	persistenceTicker *time.Ticker
-	// 定期清理无订阅主播的 ticker
-	cleanupTicker *time.Ticker
-	// 清理间隔（默认24小时）
-	cleanupInterval = 24 * time.Hour
 )
 
 // StreamerInfo 主播信息结构
@@ -57,59 +50,33 @@ func InitStreamerCache() error {
 	}
 
 	// 启动定期持久化
-	go startPeriodicPersistence()
+	startPeriodicPersistence()
 
 	// 启动定期清理无订阅主播
-	go startPeriodicCleanup()
+	startPeriodicCleanup()
 
 	streamerServiceInitialized = true
-	log.Printf("主播缓存服务已初始化，配置文件: %s, 持久化间隔: %v, 清理间隔: %v", configPath, persistInterval, cleanupInterval)
+	log.Printf("主播缓存服务已初始化，配置文件: %s", configPath)
 	return nil
 }
 
-// startPeriodicPersistence 启动定期持久化任务
+// startPeriodicPersistence 启动定期持久化任务，计划见配置 schedule.jobs.persistence（默认每5分钟）
 func startPeriodicPersistence() {
-	if persistenceTicker != nil {
-		persistenceTicker.Stop()
-	}
-
-	persistenceTicker = time.NewTicker(persistInterval)
-	defer persistenceTicker.Stop()
-
-	log.Printf("启动主播数据定期持久化任务，间隔: %v", persistInterval)
-	for range persistenceTicker.C {
+	scheduleJob(scheduleJobPersistence, func() {
 		if err := persistStreamerDataIfNeeded(); err != nil {
 			log.Printf("定期持久化主播数据失败: %v", err)
 		}
-	}
+	})
 }
 
-// startPeriodicCleanup 启动定期清理无订阅主播任务（每天凌晨2点执行）
+// startPeriodicCleanup 启动定期清理无订阅主播任务，计划见配置 schedule.jobs.cleanup（默认每天凌晨2点）
 func startPeriodicCleanup() {
-	log.Println("启动无订阅主播定期清理任务，将在每天凌晨2点执行")
-
-	for {
-		// 计算到下一个凌晨2点的时间
-		now := time.Now()
-		nextCleanup := time.Date(now.Year(), now.Month(), now.Day(), 2, 0, 0, 0, now.Location())
-
-		// 如果当前时间已经过了今天的2点，则安排到明天2点
-		if now.After(nextCleanup) {
-			nextCleanup = nextCleanup.Add(24 * time.Hour)
-		}
-
-		duration := nextCleanup.Sub(now)
-		log.Printf("下次清理时间: %s (距离现在 %v)", nextCleanup.Format("2006-01-02 15:04:05"), duration)
-
-		// 等待到指定时间
-		time.Sleep(duration)
-
-		// 执行清理任务
+	scheduleJob(scheduleJobCleanup, func() {
 		log.Println("开始执行定时清理任务...")
 		if err := cleanupUnsubscribedStreamers(); err != nil {
 			log.Printf("定期清理无订阅主播失败: %v", err)
 		}
-	}
+	})
 }
 
 // cleanupUnsubscribedStreamers 清理没有任何订阅者的主播
@@ -220,15 +187,9 @@ func StopStreamerCache() error {
 
 	log.Println("正在停止主播缓存服务...")
 
-	// 停止定期持久化
-	if persistenceTicker != nil {
-		persistenceTicker.Stop()
-	}
-
-	// 停止定期清理
-	if cleanupTicker != nil {
-		cleanupTicker.Stop()
-	}
+	// 停止定期持久化与清理
+	unscheduleJob(scheduleJobPersistence)
+	unscheduleJob(scheduleJobCleanup)
 
 	// 最后一次持久化
 	if err := persistStreamerDataIfNeeded(); err != nil {
@@ -345,6 +306,11 @@ func GetStreamerVODsByStreamerID(c *gin.Context) {
 		return
 	}
 
+	// 支持使用显示名称或曾用名访问
+	if streamer := ResolveStreamer(streamerID); streamer != nil {
+		streamerID = streamer.ID
+	}
+
 	// 获取 streamer service
 	streamerService := services.GetStreamerService()
 	if streamerService == nil {
@@ -367,32 +333,30 @@ func GetStreamerVODsByStreamerID(c *gin.Context) {
 
 	// 尝试从 cookie 获取用户信息并记录 last-seen（如果用户已登录）
 	// 记录的逻辑是：取当前返回的 VOD 列表中最新的 created_at（RFC3339 string），
-	// 将该时间戳写为用户对该主播已查看到的 watermark（覆盖式）。
+	// 将该时间戳写为用户对该主播已查看到的 watermark（只前移，不覆盖用户在 whats-new 中确认的更晚时间）。
 	// 后端在检测到新 VOD 时，可将其 created_at 与此 watermark 比较来判断用户是否有未查看过的视频。
 	if userHash, err := getUserHashFromCookie(c); err == nil && userHash != "" {
 		// 找到最新的 created_at
-		var latest string
+		var latest time.Time
 		for _, v := range streamer.Streamers {
-			if v.CreatedAt == "" {
-				continue
-			}
-			if latest == "" || v.CreatedAt > latest {
-				latest = v.CreatedAt
+			if t, err := time.Parse(time.RFC3339, v.CreatedAt); err == nil && t.After(latest) {
+				latest = t
 			}
 		}
-		if latest != "" {
+		if !latest.IsZero() {
 			// 更新用户 last-seen（忽略错误，不影响主流程）
-			_ = UpdateUserLastSeen(userHash, streamerID, latest)
+			_ = AdvanceUserLastSeen(userHash, map[string]time.Time{streamerID: latest})
 		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"vods":    streamer.Streamers,
+		"success":      true,
+		"vods":         streamer.Streamers,
+		"skipped_vods": skippedVODsForStreamer(streamerID), // 未达到处理门槛而未自动处理的录像
 	})
 }
 
-// ListStreamers 查询主播列表
+// ListStreamers 查询主播列表，可通过 q 参数按ID、显示名称、曾用名或平台账号搜索
 func ListStreamers(c *gin.Context) {
 	config, err := GetTrackedStreamerData()
 	if err != nil {
@@ -403,10 +367,20 @@ func ListStreamers(c *gin.Context) {
 		return
 	}
 
+	streamers := config.Streamers
+	if query := c.Query("q"); query != "" {
+		streamers = []models.StreamerInfo{}
+		for _, streamer := range config.Streamers {
+			if streamerMatchesSearch(streamer, query) {
+				streamers = append(streamers, streamer)
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success":   true,
-		"streamers": config.Streamers,
-		"total":     len(config.Streamers),
+		"streamers": streamers,
+		"total":     len(streamers),
 	})
 }
 
@@ -524,6 +498,7 @@ func addStreamerToConfig(rawStreamerID, streamerName string, platforms []models.
 }
 
 // SubscribeStreamer 在主播广场订阅新的主播
+// 与 POST /api/streamers/onboard 执行相同的接入流程，保留原有的响应格式
 func SubscribeStreamer(c *gin.Context) {
 	var req models.SubscriptionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -544,234 +519,56 @@ func SubscribeStreamer(c *gin.Context) {
 		return
 	}
 
-	// 加载或创建配置文件
-	config, err := loadOrCreateTrackedStreamers()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.SubscriptionResponse{
+	onboarding, status, message := onboardStreamer(userHash, req.Platform, req.Streamer_Id)
+	if onboarding == nil {
+		c.JSON(status, models.SubscriptionResponse{
 			Success: false,
-			Message: "加载配置文件失败: " + err.Error(),
+			Message: message,
 		})
 		return
 	}
 
-	// 使用 streamer 字段作为主播ID
-	rawStreamerID := req.Streamer_Id
-	streamerID := strings.ToLower(req.Streamer_Id)
-	// 移除可能存在的 @ 符号，确保 ID 格式统一
-	streamerID = strings.TrimPrefix(streamerID, "@")
-	// 如果主播不在总体追踪列表中添加到追踪列表
-	platform := req.Platform
-	// 准备平台信息
-	var newPlatform models.StreamerPlatform
-	if strings.ToLower(platform) == "twitch" {
-		newPlatform = models.StreamerPlatform{
-			Platform: "twitch",
-			URL:      "https://www.twitch.tv/" + streamerID,
-		}
-	} else if strings.ToLower(platform) == "youtube" {
-		newPlatform = models.StreamerPlatform{
-			Platform: "youtube",
-			URL:      "https://www.youtube.com/@" + streamerID,
-		}
-	} else {
-		// 不支持的平台
-		c.JSON(http.StatusBadRequest, models.SubscriptionResponse{
-			Success: false,
-			Message: "暂时不支持的平台: " + platform,
-		})
-		return
+	response := models.SubscriptionResponse{
+		Success: true,
+		Message: "订阅成功",
 	}
-
-	// 如果主播在总体追踪列表中，直接创建订阅
-	if isStreamerSubscribed(config, streamerID) {
-		// 主播已存在，检查是否已有该平台
-		if hasPlatform(config, streamerID, platform) {
-			// 平台已存在，直接创建订阅
-			err := checkAndSubscribeStreamer(userHash, streamerID)
-			if err != nil {
-				log.Printf("创建订阅失败: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"success": false,
-					"message": "订阅失败: " + err.Error(),
-				})
-				return
-			}
-
-			c.JSON(http.StatusOK, models.SubscriptionResponse{
-				Success: true,
-				Message: "订阅成功",
-			})
-			return
-		}
-
-		// 平台不存在，添加新平台
-		if err := addPlatformToStreamer(streamerID, newPlatform); err != nil {
-			c.JSON(http.StatusInternalServerError, models.SubscriptionResponse{
-				Success: false,
-				Message: "添加平台失败: " + err.Error(),
-			})
-			return
-		}
-
-		// 创建订阅
-		err := checkAndSubscribeStreamer(userHash, streamerID)
-		if err != nil {
-			log.Printf("创建订阅失败: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "订阅失败: " + err.Error(),
-			})
-			return
-		}
-
-		c.JSON(http.StatusOK, models.SubscriptionResponse{
-			Success: true,
-			Message: "订阅成功",
-		})
-	} else {
-		// 主播不存在，添加新主播
-		platforms := []models.StreamerPlatform{newPlatform}
-		if err := addStreamerToConfig(streamerID, streamerID, platforms); err != nil {
-			c.JSON(http.StatusInternalServerError, models.SubscriptionResponse{
-				Success: false,
-				Message: "添加主播失败: " + err.Error(),
-			})
-			return
-		}
-
-		// 调用 RPC 服务创建订阅
-		_, err := services.CreateSubscription(userHash, streamerID)
-		if err != nil {
-			log.Printf("创建订阅失败: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "订阅失败: " + err.Error(),
-			})
-			return
-		}
+	if onboarding.Job != nil {
+		response.Message = "订阅成功，正在后台分析最近的视频，如果正在直播将会在本次直播结束后自动分析。"
 	}
-
-	// 根据平台触发相应的监控服务
-	if strings.ToLower(platform) == "twitch" {
-		// 触发 TwitchMonitor 重新加载主播列表
-		monitor := GetTwitchMonitor()
-		if monitor != nil {
-			// 异步触发对新主播的聊天记录下载和分析
-			go func(username string) {
-				// 确保有有效的token
-				if err := monitor.ensureValidToken(); err != nil {
-					log.Printf("获取token失败，无法检查主播 %s 状态: %v", username, err)
-					return
-				}
-
-				userInfo, err := monitor.getUserInfo(username)
-				if err != nil {
-					log.Printf("获取 %s 用户信息失败: %v", username, err)
-					// 检查是否是用户不存在的错误
-					if strings.Contains(err.Error(), "用户不存在") {
-						log.Printf("主播 %s (用户名: %s) 不存在", username, username)
-						if removeErr := monitor.removeStreamerFromConfig(username); removeErr != nil {
-							log.Printf("移除主播 %s 失败: %v", username, removeErr)
-						} else {
-							log.Printf("已成功移除主播 %s", username)
-							// 从内存中移除主播状态
-							monitor.mu.Lock()
-							delete(monitor.streamerStatus, username)
-							monitor.mu.Unlock()
-						}
-					}
-				} else if userInfo.ProfileImageURL != "" {
-					if err := monitor.updateStreamerProfileImage(userInfo.Login, username, userInfo.ProfileImageURL); err != nil {
-						log.Printf("更新 %s 头像URL失败: %v", username, err)
-					}
-				}
-
-				// 检查主播是否在直播
-				stream, err := monitor.CheckStreamStatusByUsername(username)
-				if err != nil {
-					log.Printf("检查主播 %s 直播状态失败: %v", username, err)
-					return
-				}
-
-				if stream != nil {
-					// 主播正在直播，不立即下载分析
-					log.Printf("🔴 主播 %s 当前正在直播，将在直播结束后自动下载和分析", username)
-					return
-				}
-
-				// 主播离线，开始下载和分析历史视频
-				log.Printf("开始下载和分析主播 %s 的历史视频...", username)
-				newResults := monitor.GetVideoCommentsForStreamer(username)
-				if len(newResults) > 0 {
-					log.Printf("📊 完成新主播 %s 的 %d 个视频的分析", username, len(newResults))
-					for _, result := range newResults {
-						log.Printf("  - VideoID: %s, 热点时刻: %d", result.VideoID, len(result.HotMoments))
-					}
-				}
-			}(streamerID)
+	if onboarding.Platform == "youtube" {
+		response.Channel = &models.ChannelInfo{
+			Platform:  "youtube",
+			ChannelID: onboarding.PlatformID,
+			Handle:    onboarding.Handle,
+			Name:      onboarding.Name,
 		}
-	} else if strings.ToLower(platform) == "youtube" {
-		// 触发 YouTubeMonitor 重新加载主播列表
-		monitor := GetYouTubeMonitor()
-		if monitor != nil {
-			// 异步触发对新频道的视频下载和分析
-			go func(username string) {
-				log.Printf("开始处理YouTube频道 %s ...", username)
-
-				// 首先尝试通过用户名获取频道ID
-				var channelID string
-				var err error
-
-				// 如果用户名以@开头，需要通过API获取频道ID
-				if strings.HasPrefix(username, "@") || !strings.HasPrefix(username, "UC") {
-					// 使用带缓存的方法获取频道ID
-					channelID, err = monitor.getChannelIDByUsernameAndCache(username, username)
-					if err != nil {
-						log.Printf("获取频道ID失败 (%s): %v", username, err)
-						return
-					}
-
-					// 获取并更新头像
-					channelInfo, err := monitor.getChannelInfo(channelID)
-					if err != nil {
-						log.Printf("获取 %s 频道信息失败: %v", username, err)
-					} else if channelInfo.ProfileImageURL != "" {
-						if err := monitor.updateChannelProfileImage(channelInfo.ID, username, channelInfo.ProfileImageURL); err != nil {
-							log.Printf("更新 %s 头像URL失败: %v", username, err)
-						}
-					}
-				} else {
-					// 已经是频道ID格式
-					channelID = username
-				}
-
-				log.Printf("频道 %s 的ID为: %s", username, channelID)
-
-				// 检查频道是否在直播
-				stream, err := monitor.CheckLiveStatusByChannelID(channelID)
-				if err != nil {
-					log.Printf("检查YouTube频道 %s 直播状态失败: %v", username, err)
-					return
-				}
+	}
+	c.JSON(http.StatusOK, response)
+}
 
-				if stream != nil {
-					// 频道正在直播，不立即下载分析
-					log.Printf("🔴 YouTube频道 %s 当前正在直播，将在直播结束后自动下载和分析", username)
-					return
-				}
+// validateYouTubeSubscription 校验要订阅的 YouTube Handle，失败时返回 HTTP 状态码与可操作的错误提示
+func validateYouTubeSubscription(handle string) (*YouTubeChannelResolution, int, string) {
+	monitor := GetYouTubeMonitor()
+	if monitor == nil {
+		return nil, http.StatusServiceUnavailable, "YouTube服务未配置，暂时无法订阅YouTube频道"
+	}
 
-				// 频道离线，开始处理最近的VOD
-				log.Printf("开始处理YouTube频道 %s 的最近VOD...", username)
-				monitor.ProcessRecentVOD(channelID, username)
-				log.Printf("✅ 完成YouTube频道 %s 的VOD处理", username)
-			}(rawStreamerID)
+	resolved, err := monitor.ResolveYouTubeChannel(handle)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidYouTubeHandle):
+			return nil, http.StatusBadRequest,
+				"无效的YouTube Handle: 请填写频道主页中 @ 后的名称（3-30位字母、数字、下划线、连字符或点），例如 @example"
+		case errors.Is(err, ErrYouTubeChannelNotFound):
+			return nil, http.StatusNotFound,
+				"未找到YouTube频道 " + handle + "，请确认Handle拼写正确，或该频道已被删除/终止"
+		default:
+			log.Printf("校验YouTube频道 %s 失败: %v", handle, err)
+			return nil, http.StatusBadGateway, "暂时无法验证YouTube频道，请稍后重试"
 		}
 	}
 
-	c.JSON(http.StatusOK, models.SubscriptionResponse{
-		Success: true,
-		Message: "订阅成功，正在后台分析最近的视频，如果正在直播将会在本次直播结束后自动分析。",
-	})
+	return resolved, http.StatusOK, ""
 }
 
 func checkAndSubscribeStreamer(userHash, streamerID string) error {
@@ -788,7 +585,7 @@ func checkAndSubscribeStreamer(userHash, streamerID string) error {
 	_, err = services.CreateSubscription(userHash, streamerID)
 	if err != nil {
 		log.Printf("创建订阅失败: %v", err)
-		return fmt.Errorf("订阅失败: " + err.Error())
+		return fmt.Errorf("订阅失败: %w", err)
 	}
 	return nil
 }
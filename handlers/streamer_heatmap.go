@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	cache "github.com/patrickmn/go-cache"
+)
+
+const (
+	// heatmapMinStreamHours 推荐时段至少需要的直播时长（小时），避免偶尔一次直播的时段排在前面
+	heatmapMinStreamHours = 1.0
+	// heatmapBestSlots 返回的最精彩时段数
+	heatmapBestSlots = 3
+)
+
+// heatmapCache 缓存热力图，与公开主页相同的过期时间
+var heatmapCache = cache.New(publicProfileMaxAge*time.Second, 10*time.Minute)
+
+// HeatmapCell 一周中某一天某个小时的热点统计
+type HeatmapCell struct {
+	Weekday        int     `json:"weekday"` // 0 为星期日
+	Hour           int     `json:"hour"`
+	Moments        int     `json:"moments"`          // 落在该时段的热点数
+	Score          float64 `json:"score"`            // 热点得分之和
+	StreamHours    float64 `json:"stream_hours"`     // 该时段累计直播时长（小时）
+	MomentsPerHour float64 `json:"moments_per_hour"` // 每直播一小时的热点数，不受直播频率影响
+}
+
+// StreamerHeatmap 主播热点按星期与小时分布的热力图
+type StreamerHeatmap struct {
+	StreamerID  string             `json:"streamer_id"`
+	Timezone    string             `json:"timezone"`
+	VODs        int                `json:"vods"`         // 参与统计的录像数
+	SkippedVODs int                `json:"skipped_vods"` // 缺少开播时间而未统计的录像数
+	Moments     int                `json:"moments"`
+	Cells       [7][24]HeatmapCell `json:"cells"`      // cells[星期][小时]
+	BestSlots   []HeatmapCell      `json:"best_slots"` // 每小时热点数最高的时段
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// buildStreamerHeatmap 将主播所有公开录像的热点换算为开播时间加偏移的实际时间，按星期与小时汇总
+// 同时统计每个时段的直播时长，用每小时热点数衡量该时段的精彩程度
+func buildStreamerHeatmap(streamerID string, loc *time.Location) *StreamerHeatmap {
+	heatmap := &StreamerHeatmap{
+		StreamerID:  streamerID,
+		Timezone:    loc.String(),
+		BestSlots:   []HeatmapCell{},
+		GeneratedAt: time.Now(),
+	}
+	for d := 0; d < 7; d++ {
+		for h := 0; h < 24; h++ {
+			heatmap.Cells[d][h] = HeatmapCell{Weekday: d, Hour: h}
+		}
+	}
+
+	claim := GetStreamerClaim(streamerID)
+	for _, result := range loadStreamerAnalysisResults(streamerID) {
+		start, err := time.Parse(time.RFC3339, result.VideoInfo.CreatedAt)
+		if err != nil {
+			heatmap.SkippedVODs++
+			continue
+		}
+		start = start.In(loc)
+		heatmap.VODs++
+
+		// 按整点切分直播时长，累加到各时段
+		if duration := parseVideoDuration(result.VideoInfo.Duration); duration > 0 {
+			end := start.Add(time.Duration(duration * float64(time.Second)))
+			for t := start; t.Before(end); {
+				// 按本地整点切分，Truncate 对半小时时区的整点不准确
+				next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc).Add(time.Hour)
+				if next.After(end) {
+					next = end
+				}
+				cell := &heatmap.Cells[t.Weekday()][t.Hour()]
+				cell.StreamHours += next.Sub(t).Hours()
+				t = next
+			}
+		}
+
+		moments := result.HotMoments
+		if claim != nil {
+			moments = claim.visibleMoments(result.VideoID, moments)
+		}
+		moments = withoutTakenDownClips(result.VideoID, moments)
+		for _, moment := range moments {
+			at := start.Add(time.Duration(moment.OffsetSeconds * float64(time.Second)))
+			cell := &heatmap.Cells[at.Weekday()][at.Hour()]
+			cell.Moments++
+			cell.Score += moment.CommentsScore
+			heatmap.Moments++
+		}
+	}
+
+	var candidates []HeatmapCell
+	for d := 0; d < 7; d++ {
+		for h := 0; h < 24; h++ {
+			cell := &heatmap.Cells[d][h]
+			cell.StreamHours = math.Round(cell.StreamHours*100) / 100
+			cell.Score = math.Round(cell.Score*100) / 100
+			if cell.StreamHours > 0 {
+				cell.MomentsPerHour = math.Round(float64(cell.Moments)/cell.StreamHours*100) / 100
+			}
+			if cell.Moments > 0 && cell.StreamHours >= heatmapMinStreamHours {
+				candidates = append(candidates, *cell)
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].MomentsPerHour != candidates[j].MomentsPerHour {
+			return candidates[i].MomentsPerHour > candidates[j].MomentsPerHour
+		}
+		return candidates[i].Score > candidates[j].Score
+	})
+	if len(candidates) > heatmapBestSlots {
+		candidates = candidates[:heatmapBestSlots]
+	}
+	heatmap.BestSlots = append(heatmap.BestSlots, candidates...)
+	return heatmap
+}
+
+// GetStreamerHeatmap 主播热点的星期/小时热力图，显示其直播通常在什么时候最精彩
+// 只统计公开录像；tz 为 IANA 时区名（默认 UTC），热点时间按该时区换算
+// GET /api/streamers/:id/heatmap?tz=Asia/Shanghai
+func GetStreamerHeatmap(c *gin.Context) {
+	streamer := ResolveStreamer(c.Param("id"))
+	if streamer == nil || streamer.Visibility == "private" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "主播不存在或未公开",
+		})
+		return
+	}
+
+	tz := strings.TrimSpace(c.DefaultQuery("tz", "UTC"))
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的时区: " + tz,
+		})
+		return
+	}
+
+	key := streamer.ID + "|" + loc.String()
+	var heatmap *StreamerHeatmap
+	if cached, found := heatmapCache.Get(key); found {
+		heatmap = cached.(*StreamerHeatmap)
+	} else {
+		heatmap = buildStreamerHeatmap(streamer.ID, loc)
+		heatmapCache.SetDefault(key, heatmap)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"heatmap": heatmap,
+	})
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"subtuber-services/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OnboardStreamerRequest 接入主播请求
+type OnboardStreamerRequest struct {
+	Platform string `json:"platform" binding:"required"`
+	Handle   string `json:"handle" binding:"required"` // Twitch 用户名，YouTube Handle 或频道ID
+}
+
+// OnboardingJob 接入时安排的历史录像回填任务，可通过任务进度接口查看
+type OnboardingJob struct {
+	ID          string `json:"id"`
+	ProgressURL string `json:"progress_url"`
+	EventsURL   string `json:"events_url"`
+}
+
+// StreamerOnboarding 主播接入结果
+type StreamerOnboarding struct {
+	StreamerID      string         `json:"streamer_id"`
+	Name            string         `json:"name"`
+	Platform        string         `json:"platform"`
+	PlatformID      string         `json:"platform_id"` // Twitch 登录名或 YouTube 频道ID
+	Handle          string         `json:"handle,omitempty"`
+	URL             string         `json:"url"`
+	ProfileImageURL string         `json:"profile_image_url,omitempty"`
+	Created         bool           `json:"created"` // 本次接入是否新加入主播广场
+	Job             *OnboardingJob `json:"-"`
+}
+
+// backfillJobID 回填任务的进度ID，同一主播同一平台的回填共用一个ID
+func backfillJobID(platform, streamer string) string {
+	return "backfill_" + platform + "_" + streamer
+}
+
+// resolveOnboardingIdentity 确认主播在平台上真实存在，并取得规范的ID、名称与头像
+// Twitch 监控服务未配置时无法校验，按用户名直接接入，不安排回填
+func resolveOnboardingIdentity(platform, handle string) (*StreamerOnboarding, int, string) {
+	handle = strings.TrimSpace(handle)
+	switch strings.ToLower(platform) {
+	case "twitch":
+		login := strings.ToLower(strings.TrimPrefix(handle, "@"))
+		if login == "" {
+			return nil, http.StatusBadRequest, "Twitch 用户名不能为空"
+		}
+		onboarding := &StreamerOnboarding{
+			StreamerID: login,
+			Name:       login,
+			Platform:   "twitch",
+			PlatformID: login,
+			URL:        "https://www.twitch.tv/" + login,
+		}
+
+		monitor := GetTwitchMonitor()
+		if monitor == nil {
+			return onboarding, http.StatusOK, ""
+		}
+		if err := monitor.ensureValidToken(); err != nil {
+			log.Printf("获取token失败，无法校验主播 %s: %v", login, err)
+			return nil, http.StatusBadGateway, "暂时无法连接Twitch，请稍后重试"
+		}
+		userInfo, err := monitor.getUserInfo(login)
+		if err != nil {
+			if strings.Contains(err.Error(), "用户不存在") {
+				return nil, http.StatusNotFound, fmt.Sprintf("Twitch 用户 %s 不存在，请检查用户名", login)
+			}
+			log.Printf("获取 %s 用户信息失败: %v", login, err)
+			return nil, http.StatusBadGateway, "暂时无法连接Twitch，请稍后重试"
+		}
+		onboarding.StreamerID = strings.ToLower(userInfo.Login)
+		onboarding.PlatformID = onboarding.StreamerID
+		onboarding.URL = "https://www.twitch.tv/" + onboarding.StreamerID
+		onboarding.ProfileImageURL = userInfo.ProfileImageURL
+		if userInfo.DisplayName != "" {
+			onboarding.Name = userInfo.DisplayName
+		}
+		return onboarding, http.StatusOK, ""
+
+	case "youtube":
+		resolved, status, message := validateYouTubeSubscription(handle)
+		if resolved == nil {
+			return nil, status, message
+		}
+		onboarding := &StreamerOnboarding{
+			Name:            resolved.Title,
+			Platform:        "youtube",
+			PlatformID:      resolved.ChannelID,
+			Handle:          resolved.Handle,
+			ProfileImageURL: resolved.ProfileImageURL,
+		}
+		if resolved.Handle != "" {
+			onboarding.StreamerID = strings.ToLower(strings.TrimPrefix(resolved.Handle, "@"))
+			onboarding.URL = "https://www.youtube.com/" + resolved.Handle
+		} else {
+			onboarding.StreamerID = strings.ToLower(resolved.ChannelID)
+			onboarding.URL = "https://www.youtube.com/channel/" + resolved.ChannelID
+		}
+		return onboarding, http.StatusOK, ""
+
+	default:
+		return nil, http.StatusBadRequest, "暂时不支持的平台: " + platform
+	}
+}
+
+// addOnboardedStreamer 将主播加入主播广场：新主播创建条目，已有主播补充平台，并保存头像与频道ID
+func addOnboardedStreamer(onboarding *StreamerOnboarding) error {
+	config, err := loadOrCreateTrackedStreamers()
+	if err != nil {
+		return fmt.Errorf("加载配置文件失败: %w", err)
+	}
+
+	platform := models.StreamerPlatform{Platform: onboarding.Platform, URL: onboarding.URL}
+	switch {
+	case !isStreamerSubscribed(config, onboarding.StreamerID):
+		if err := addStreamerToConfig(onboarding.StreamerID, onboarding.Name, []models.StreamerPlatform{platform}); err != nil {
+			return fmt.Errorf("添加主播失败: %w", err)
+		}
+		onboarding.Created = true
+	case !hasPlatform(config, onboarding.StreamerID, onboarding.Platform):
+		if err := addPlatformToStreamer(onboarding.StreamerID, platform); err != nil {
+			return fmt.Errorf("添加平台失败: %w", err)
+		}
+	}
+
+	// 头像与频道ID保存失败不影响接入，后续监控检查时会再次补全
+	switch onboarding.Platform {
+	case "twitch":
+		if monitor := GetTwitchMonitor(); monitor != nil && onboarding.ProfileImageURL != "" {
+			if err := monitor.updateStreamerProfileImage(onboarding.StreamerID, onboarding.Name, onboarding.ProfileImageURL); err != nil {
+				log.Printf("更新 %s 头像URL失败: %v", onboarding.Name, err)
+			}
+		}
+	case "youtube":
+		if monitor := GetYouTubeMonitor(); monitor != nil {
+			raw := onboarding.Handle
+			if raw == "" {
+				raw = onboarding.PlatformID
+			}
+			// 缓存已校验的频道ID，后台处理时无需再次搜索
+			if err := monitor.updateStreamerChannelID(onboarding.StreamerID, onboarding.PlatformID, raw); err != nil {
+				log.Printf("保存频道ID到配置文件失败: %v", err)
+			}
+			if onboarding.ProfileImageURL != "" {
+				if err := monitor.updateChannelProfileImage(onboarding.StreamerID, onboarding.Name, onboarding.ProfileImageURL); err != nil {
+					log.Printf("更新 %s 头像URL失败: %v", onboarding.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// scheduleBackfill 安排主播历史录像的回填任务（持久化，重启后继续；积压时按调度策略排队）
+// 相同主播的回填任务未完成时不重复安排，返回进行中的任务；对应平台的监控服务未启动时返回 nil
+func scheduleBackfill(onboarding *StreamerOnboarding) *OnboardingJob {
+	switch onboarding.Platform {
+	case "twitch":
+		if GetTwitchMonitor() == nil {
+			return nil
+		}
+	case "youtube":
+		if GetYouTubeMonitor() == nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	job := PersistedJob{
+		Stage:        persistedStageBackfill,
+		Platform:     onboarding.Platform,
+		StreamerID:   onboarding.StreamerID,
+		Streamer:     onboarding.PlatformID,
+		StreamerName: onboarding.Name,
+	}
+	id := backfillJobID(job.Platform, job.Streamer)
+	current, ok, _, unsubscribe := subscribeJobProgress(id)
+	unsubscribe()
+	if !ok || current.Done {
+		reportJobProgress(id, JobStageQueued, 0, "等待处理")
+	}
+	submitProcessingJob(job, func() { runBackfillJob(job) })
+
+	return &OnboardingJob{
+		ID:          id,
+		ProgressURL: "/api/jobs/" + id,
+		EventsURL:   "/api/jobs/" + id + "/events",
+	}
+}
+
+// onboardStreamer 接入主播：确认身份、加入主播广场、保存头像、为用户创建订阅并安排历史录像回填
+func onboardStreamer(userHash, platform, handle string) (*StreamerOnboarding, int, string) {
+	onboarding, status, message := resolveOnboardingIdentity(platform, handle)
+	if onboarding == nil {
+		return nil, status, message
+	}
+
+	if err := addOnboardedStreamer(onboarding); err != nil {
+		return nil, http.StatusInternalServerError, err.Error()
+	}
+
+	if err := checkAndSubscribeStreamer(userHash, onboarding.StreamerID); err != nil {
+		return nil, http.StatusInternalServerError, err.Error()
+	}
+
+	onboarding.Job = scheduleBackfill(onboarding)
+	log.Printf("✅ 用户接入主播 %s (%s/%s)，新加入广场: %v", onboarding.Name, onboarding.Platform, onboarding.PlatformID, onboarding.Created)
+	return onboarding, http.StatusOK, ""
+}
+
+// runBackfillJob 回填主播的历史录像：主播正在直播时跳过，由下播处理负责本次直播的录像
+func runBackfillJob(job PersistedJob) {
+	id := backfillJobID(job.Platform, job.Streamer)
+	job, ok := claimPersistedJob(job)
+	if !ok {
+		log.Printf("%s 的回填任务正在执行，跳过重复任务", job.StreamerName)
+		return
+	}
+
+	err := runBackfill(id, job)
+	if err != nil {
+		log.Printf("回填 %s 的历史录像失败: %v", job.StreamerName, err)
+	}
+	completePersistedJob(job.ID, err)
+	finishJobProgress(id, err)
+}
+
+func runBackfill(id string, job PersistedJob) error {
+	switch job.Platform {
+	case "twitch":
+		monitor := GetTwitchMonitor()
+		if monitor == nil {
+			return fmt.Errorf("Twitch监控服务未启动")
+		}
+		if err := monitor.ensureValidToken(); err != nil {
+			return fmt.Errorf("获取token失败: %w", err)
+		}
+		reportJobProgress(id, JobStageChatDownload, 0, "检查直播状态")
+		stream, err := monitor.CheckStreamStatusByUsername(job.Streamer)
+		if err != nil {
+			return fmt.Errorf("检查直播状态失败: %w", err)
+		}
+		if stream != nil {
+			log.Printf("🔴 主播 %s 当前正在直播，将在直播结束后自动下载和分析", job.StreamerName)
+			reportJobProgress(id, JobStageChatDownload, 100, "主播正在直播，将在本次直播结束后自动分析")
+			return nil
+		}
+
+		reportJobProgress(id, JobStageChatDownload, 0, "下载并分析历史录像")
+		newResults, err := monitor.GetVideoCommentsForStreamer(job.Streamer)
+		for _, result := range newResults {
+			log.Printf("  - VideoID: %s, 热点时刻: %d", result.VideoID, len(result.HotMoments))
+		}
+		if err != nil {
+			return fmt.Errorf("下载并分析历史录像失败: %w", err)
+		}
+		reportJobProgress(id, JobStageAnalysis, 100, fmt.Sprintf("完成 %d 个录像的分析", len(newResults)))
+		return nil
+
+	case "youtube":
+		monitor := GetYouTubeMonitor()
+		if monitor == nil {
+			return fmt.Errorf("YouTube监控服务未启动")
+		}
+		reportJobProgress(id, JobStageChatDownload, 0, "检查直播状态")
+		stream, err := monitor.CheckLiveStatusByChannelID(job.Streamer)
+		if err != nil {
+			return fmt.Errorf("检查直播状态失败: %w", err)
+		}
+		if stream != nil {
+			log.Printf("🔴 YouTube频道 %s 当前正在直播，将在直播结束后自动下载和分析", job.StreamerName)
+			reportJobProgress(id, JobStageChatDownload, 100, "频道正在直播，将在本次直播结束后自动分析")
+			return nil
+		}
+
+		reportJobProgress(id, JobStageChatDownload, 0, "处理最近的直播录像")
+		monitor.ProcessRecentVOD(job.Streamer, job.StreamerName)
+		reportJobProgress(id, JobStageAnalysis, 100, "最近的直播录像已处理")
+		return nil
+
+	default:
+		return fmt.Errorf("未知的平台: %s", job.Platform)
+	}
+}
+
+// OnboardStreamer 一次完成主播接入：确认身份、获取头像与频道信息、加入主播广场、创建当前用户的订阅，
+// 并安排历史录像的回填任务，返回任务ID供 /api/jobs/:id 查看进度
+// POST /api/streamers/onboard
+func OnboardStreamer(c *gin.Context) {
+	var req OnboardStreamerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的请求参数: " + err.Error(),
+		})
+		return
+	}
+
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "未登录或登录已过期",
+		})
+		return
+	}
+
+	onboarding, status, message := onboardStreamer(userHash, req.Platform, req.Handle)
+	if onboarding == nil {
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
+	message = "接入成功"
+	if onboarding.Job != nil {
+		message = "接入成功，正在后台分析最近的录像，如果正在直播将会在本次直播结束后自动分析。"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  message,
+		"streamer": onboarding,
+		"job":      onboarding.Job,
+	})
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"subtuber-services/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 主播监控优先级（monitoring.priority）
+const (
+	streamerPriorityHigh   = "high"
+	streamerPriorityNormal = "normal"
+	streamerPriorityLow    = "low"
+)
+
+// streamerPriorityFactors 各优先级的检查间隔相对默认随机间隔的倍数
+var streamerPriorityFactors = map[string]float64{
+	streamerPriorityHigh:   0.5,
+	streamerPriorityNormal: 1,
+	streamerPriorityLow:    3,
+}
+
+const (
+	// streamerOffHoursFactor 常开播时段外且未直播时检查间隔放大的倍数
+	streamerOffHoursFactor = 4
+	// minStreamerCheckInterval check_interval_seconds 允许的最小值（秒）
+	minStreamerCheckInterval = 30
+	// minScheduleWait 监控循环两次检查之间的最短等待时间
+	minScheduleWait = 15 * time.Second
+)
+
+// validateStreamerMonitoring 检查主播监控计划的优先级、间隔、时段与时区
+func validateStreamerMonitoring(m models.StreamerMonitoring) error {
+	if m.Priority != "" {
+		if _, ok := streamerPriorityFactors[m.Priority]; !ok {
+			return fmt.Errorf("priority 只能为 high、normal 或 low")
+		}
+	}
+	if m.CheckIntervalSeconds != 0 && m.CheckIntervalSeconds < minStreamerCheckInterval {
+		return fmt.Errorf("check_interval_seconds 不能小于 %d", minStreamerCheckInterval)
+	}
+	if _, err := streamerScheduleLocation(m); err != nil {
+		return fmt.Errorf("无效的时区: %w", err)
+	}
+	for _, s := range m.ActiveHours {
+		if _, err := parseQuietWindow(s); err != nil {
+			return fmt.Errorf("常开播时段格式错误: %s（应为 HH:MM-HH:MM）", s)
+		}
+	}
+	return nil
+}
+
+// streamerScheduleLocation 返回常开播时段的时区，为空时使用本地时区
+func streamerScheduleLocation(m models.StreamerMonitoring) (*time.Location, error) {
+	if m.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(m.Timezone)
+}
+
+// inActiveHours 给定时间是否处于主播的常开播时段内，未配置时段时始终为 true
+func inActiveHours(m models.StreamerMonitoring, t time.Time) bool {
+	if len(m.ActiveHours) == 0 {
+		return true
+	}
+	loc, err := streamerScheduleLocation(m)
+	if err != nil {
+		return true
+	}
+	local := t.In(loc)
+	minutes := local.Hour()*60 + local.Minute()
+	for _, s := range m.ActiveHours {
+		if w, err := parseQuietWindow(s); err == nil && w.contains(minutes) {
+			return true
+		}
+	}
+	return false
+}
+
+// streamerCheckInterval 主播下一次检查的间隔：固定间隔优先，否则按优先级缩放 base；
+// 常开播时段外且未直播时再放大，直播中始终按正常间隔检查以便及时发现下播
+func streamerCheckInterval(streamer models.StreamerInfo, base time.Duration, live bool, now time.Time) time.Duration {
+	if streamer.Monitoring == nil {
+		return base
+	}
+	m := *streamer.Monitoring
+
+	interval := base
+	if m.CheckIntervalSeconds > 0 {
+		interval = time.Duration(m.CheckIntervalSeconds) * time.Second
+	} else if factor, ok := streamerPriorityFactors[m.Priority]; ok {
+		interval = time.Duration(float64(base) * factor)
+	}
+	if !live && !inActiveHours(m, now) {
+		interval *= streamerOffHoursFactor
+	}
+	return max(interval, minScheduleWait)
+}
+
+// monitorSchedule 记录每个主播下一次应检查的时间，监控循环每轮只检查已到期的主播
+type monitorSchedule struct {
+	mu   sync.Mutex
+	next map[string]time.Time // 主播ID -> 下次检查时间
+}
+
+func newMonitorSchedule() *monitorSchedule {
+	return &monitorSchedule{next: make(map[string]time.Time)}
+}
+
+// due 返回已到检查时间的主播（包括从未检查过的），并清除已不再追踪的主播记录
+func (s *monitorSchedule) due(streamers []models.StreamerInfo, now time.Time) []models.StreamerInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tracked := make(map[string]bool, len(streamers))
+	var due []models.StreamerInfo
+	for _, streamer := range streamers {
+		tracked[streamer.ID] = true
+		if next, ok := s.next[streamer.ID]; !ok || !now.Before(next) {
+			due = append(due, streamer)
+		}
+	}
+	for id := range s.next {
+		if !tracked[id] {
+			delete(s.next, id)
+		}
+	}
+	return due
+}
+
+// checked 记录主播已检查，interval 后再次到期
+func (s *monitorSchedule) checked(streamerID string, interval time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next[streamerID] = now.Add(interval)
+}
+
+// wait 距下一个主播到期的等待时间，不超过 base 且不小于 minScheduleWait；
+// 本轮检查失败而仍处于到期状态的主播在下一轮（base 后）重试
+func (s *monitorSchedule) wait(base time.Duration, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wait := base
+	for _, next := range s.next {
+		if next.After(now) {
+			wait = min(wait, next.Sub(now))
+		}
+	}
+	return max(wait, minScheduleWait)
+}
+
+// nextCheck 主播下一次检查的时间，尚未检查过时返回零值
+func (s *monitorSchedule) nextCheck(streamerID string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next[streamerID]
+}
+
+// reset 清除主播的检查计划，使其在下一轮立即检查
+func (s *monitorSchedule) reset(streamerID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.next, streamerID)
+}
+
+// findTrackedStreamer 按主播ID查找追踪的主播
+func findTrackedStreamer(streamerID string) (*models.StreamerInfo, error) {
+	config, err := GetTrackedStreamerData()
+	if err != nil {
+		return nil, err
+	}
+	for i := range config.Streamers {
+		if config.Streamers[i].ID == streamerID {
+			streamer := config.Streamers[i]
+			return &streamer, nil
+		}
+	}
+	return nil, nil
+}
+
+// updateStreamerMonitoring 保存主播的监控计划（monitoring 为 nil 时删除）并让监控服务重新加载主播列表
+func updateStreamerMonitoring(streamerID string, monitoring *models.StreamerMonitoring) (bool, error) {
+	config, err := GetTrackedStreamerData()
+	if err != nil {
+		return false, err
+	}
+	found := false
+	for i := range config.Streamers {
+		if config.Streamers[i].ID == streamerID {
+			config.Streamers[i].Monitoring = monitoring
+			found = true
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	if err := UpdateTrackedStreamerData(config); err != nil {
+		return true, err
+	}
+
+	// 立即生效：重新加载主播列表并在下一轮检查该主播
+	if tm := GetTwitchMonitor(); tm != nil {
+		if err := tm.loadStreamers(); err != nil {
+			log.Printf("重新加载主播列表失败: %v", err)
+		}
+		tm.schedule.reset(streamerID)
+	}
+	if ym := GetYouTubeMonitor(); ym != nil {
+		if err := ym.loadChannels(); err != nil {
+			log.Printf("重新加载频道列表失败: %v", err)
+		}
+		ym.schedule.reset(streamerID)
+	}
+	return true, nil
+}
+
+// streamerMonitoringResponse 主播监控计划及各平台的下一次检查时间
+func streamerMonitoringResponse(streamer *models.StreamerInfo) gin.H {
+	resp := gin.H{
+		"success":     true,
+		"streamer_id": streamer.ID,
+		"monitoring":  streamer.Monitoring,
+	}
+	nextChecks := gin.H{}
+	if tm := GetTwitchMonitor(); tm != nil {
+		if next := tm.schedule.nextCheck(streamer.ID); !next.IsZero() {
+			nextChecks["twitch"] = next
+		}
+	}
+	if ym := GetYouTubeMonitor(); ym != nil {
+		if next := ym.schedule.nextCheck(streamer.ID); !next.IsZero() {
+			nextChecks["youtube"] = next
+		}
+	}
+	resp["next_checks"] = nextChecks
+	return resp
+}
+
+// getStreamerMonitoringHandler 查看主播的监控计划与下一次检查时间
+func getStreamerMonitoringHandler(c *gin.Context) {
+	streamer, err := findTrackedStreamer(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "读取主播列表失败: " + err.Error(),
+		})
+		return
+	}
+	if streamer == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "主播不存在",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, streamerMonitoringResponse(streamer))
+}
+
+// setStreamerMonitoringHandler 设置主播的检查间隔、优先级与常开播时段
+func setStreamerMonitoringHandler(c *gin.Context) {
+	streamerID := c.Param("id")
+
+	var req models.StreamerMonitoring
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if err := validateStreamerMonitoring(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	found, err := updateStreamerMonitoring(streamerID, &req)
+	if !found && err == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "主播不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "保存监控计划失败: " + err.Error(),
+		})
+		return
+	}
+	log.Printf("已更新主播 %s 的监控计划: %+v", streamerID, req)
+
+	resp := streamerMonitoringResponse(&models.StreamerInfo{ID: streamerID, Monitoring: &req})
+	resp["message"] = "监控计划已保存"
+	c.JSON(http.StatusOK, resp)
+}
+
+// deleteStreamerMonitoringHandler 删除主播的监控计划，恢复按默认间隔检查
+func deleteStreamerMonitoringHandler(c *gin.Context) {
+	streamerID := c.Param("id")
+
+	found, err := updateStreamerMonitoring(streamerID, nil)
+	if !found && err == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "主播不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "删除监控计划失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "监控计划已删除",
+		"streamer_id": streamerID,
+	})
+}
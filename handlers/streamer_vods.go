@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"subtuber-services/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultPlatformVODPageSize 每页默认返回的录像数
+	defaultPlatformVODPageSize = 20
+	// maxPlatformVODPageSize 每页最多返回的录像数（Twitch 与 YouTube 接口的单页上限）
+	maxPlatformVODPageSize = 50
+)
+
+// PlatformVOD 平台上的一个录像，analyzed 表示已有分析结果，可直接查看
+type PlatformVOD struct {
+	ID             string `json:"id"`
+	Platform       string `json:"platform"`
+	Title          string `json:"title"`
+	URL            string `json:"url"`
+	ThumbnailURL   string `json:"thumbnail_url,omitempty"`
+	CreatedAt      string `json:"created_at,omitempty"`
+	Duration       string `json:"duration,omitempty"`
+	Type           string `json:"type,omitempty"` // Twitch: archive/highlight/upload；YouTube: live（直播录像）/video
+	Analyzed       bool   `json:"analyzed"`
+	ChatDownloaded bool   `json:"chat_downloaded"`
+}
+
+// streamerPlatformAccount 主播在指定平台上的账号（Twitch 用户名或 YouTube 频道链接中的账号），未配置该平台时返回空
+func streamerPlatformAccount(streamer *models.StreamerInfo, platform string) string {
+	for _, p := range streamer.Platforms {
+		if strings.EqualFold(p.Platform, platform) {
+			return platformURLHandle(p.URL)
+		}
+	}
+	return ""
+}
+
+// listTwitchVODs 分页获取 Twitch 录像，cursor 为 Helix 分页游标
+func listTwitchVODs(login, videoType string, limit int, cursor string) ([]PlatformVOD, string, int, string) {
+	monitor := GetTwitchMonitor()
+	if monitor == nil {
+		return nil, "", http.StatusServiceUnavailable, "Twitch服务未配置"
+	}
+	if err := monitor.ensureValidToken(); err != nil {
+		return nil, "", http.StatusBadGateway, "获取Twitch token失败: " + err.Error()
+	}
+	resp, err := monitor.getVideos(login, videoType, strconv.Itoa(limit), cursor)
+	if err != nil {
+		return nil, "", http.StatusBadGateway, "获取Twitch录像列表失败: " + err.Error()
+	}
+
+	vods := make([]PlatformVOD, 0, len(resp.Videos))
+	for _, video := range resp.Videos {
+		analyzed, chatDownloaded := videoProcessingState(video.ID)
+		vods = append(vods, PlatformVOD{
+			ID:             video.ID,
+			Platform:       "twitch",
+			Title:          video.Title,
+			URL:            video.URL,
+			ThumbnailURL:   strings.NewReplacer("%{width}", "320", "%{height}", "180").Replace(video.ThumbnailURL),
+			CreatedAt:      video.CreatedAt,
+			Duration:       video.Duration,
+			Type:           video.Type,
+			Analyzed:       analyzed,
+			ChatDownloaded: chatDownloaded,
+		})
+	}
+	return vods, resp.Cursor, http.StatusOK, ""
+}
+
+// listYouTubeVODs 分页获取 YouTube 频道上传的视频，cursor 为 pageToken
+func listYouTubeVODs(streamer *models.StreamerInfo, account string, limit int, cursor string) ([]PlatformVOD, string, int, string) {
+	monitor := GetYouTubeMonitor()
+	if monitor == nil {
+		return nil, "", http.StatusServiceUnavailable, "YouTube服务未配置"
+	}
+
+	channelID := streamer.YouTubeChannelID
+	if !strings.HasPrefix(channelID, "UC") {
+		channelID = account
+	}
+	if !strings.HasPrefix(channelID, "UC") {
+		resolved, err := monitor.getChannelIDByUsernameAndCache(streamer.ID, account)
+		if err != nil {
+			return nil, "", http.StatusBadGateway, "获取YouTube频道ID失败: " + err.Error()
+		}
+		channelID = resolved
+	}
+
+	page, err := monitor.getUploads(channelID, limit, cursor)
+	if err != nil {
+		return nil, "", http.StatusBadGateway, "获取YouTube视频列表失败: " + err.Error()
+	}
+
+	vods := make([]PlatformVOD, 0, len(page.Videos))
+	for _, video := range page.Videos {
+		analyzed, chatDownloaded := videoProcessingState(video.ID)
+		vod := PlatformVOD{
+			ID:             video.ID,
+			Platform:       "youtube",
+			Title:          video.Snippet.Title,
+			URL:            "https://www.youtube.com/watch?v=" + video.ID,
+			ThumbnailURL:   video.Snippet.Thumbnails.High.URL,
+			Type:           "video",
+			Analyzed:       analyzed,
+			ChatDownloaded: chatDownloaded,
+		}
+		if video.LiveStreamingDetails != nil && video.LiveStreamingDetails.ActualStartTime != "" {
+			vod.Type = "live"
+			vod.CreatedAt = video.LiveStreamingDetails.ActualStartTime
+		}
+		if video.ContentDetails != nil {
+			vod.Duration = video.ContentDetails.Duration
+		}
+		vods = append(vods, vod)
+	}
+	return vods, page.NextPageToken, http.StatusOK, ""
+}
+
+// GetStreamerPlatformVODs 分页浏览主播在平台上的历史录像，并标记每个录像是否已分析，
+// 便于用户挑选较早的录像请求分析。列表来自平台接口并短时间缓存
+// GET /api/streamers/:id/vods?platform=twitch|youtube&limit=20&cursor=...&type=archive
+func GetStreamerPlatformVODs(c *gin.Context) {
+	streamer := ResolveStreamer(c.Param("id"))
+	if streamer == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "主播不存在",
+		})
+		return
+	}
+
+	limit := defaultPlatformVODPageSize
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPlatformVODPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "limit 必须为 1 到 50 之间的整数",
+			})
+			return
+		}
+		limit = n
+	}
+
+	// 未指定平台时使用主播配置的第一个平台
+	platform := strings.ToLower(c.Query("platform"))
+	if platform == "" && len(streamer.Platforms) > 0 {
+		platform = strings.ToLower(streamer.Platforms[0].Platform)
+	}
+	account := streamerPlatformAccount(streamer, platform)
+	if account == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "主播没有配置该平台: " + platform,
+		})
+		return
+	}
+
+	cursor := c.Query("cursor")
+	var (
+		vods       []PlatformVOD
+		nextCursor string
+		status     int
+		message    string
+	)
+	switch platform {
+	case "twitch":
+		videoType := c.DefaultQuery("type", "archive")
+		switch videoType {
+		case "all", "archive", "highlight", "upload":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "type 必须为 all、archive、highlight 或 upload",
+			})
+			return
+		}
+		vods, nextCursor, status, message = listTwitchVODs(account, videoType, limit, cursor)
+	case "youtube":
+		vods, nextCursor, status, message = listYouTubeVODs(streamer, account, limit, cursor)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "暂时不支持的平台: " + platform,
+		})
+		return
+	}
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"streamer_id": streamer.ID,
+		"platform":    platform,
+		"vods":        vods,
+		"cursor":      nextCursor,
+		"has_more":    nextCursor != "",
+	})
+}
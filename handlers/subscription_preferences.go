@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"subtuber-services/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const subscriptionPreferencesFile = "App_Data/subscription_preferences.json"
+
+// SubscriptionPreferences 用户对单个订阅主播的通知设置
+type SubscriptionPreferences struct {
+	GoLive        bool `json:"go_live"`        // 开播通知
+	AnalysisReady bool `json:"analysis_ready"` // 录像分析完成通知
+	DigestOnly    bool `json:"digest_only"`    // 只通过每日摘要邮件接收，不单独发送
+}
+
+// defaultSubscriptionPreferences 未设置时的默认通知偏好
+func defaultSubscriptionPreferences() SubscriptionPreferences {
+	return SubscriptionPreferences{GoLive: true, AnalysisReady: true}
+}
+
+// wants 判断该偏好是否接收指定类型的通知
+func (p SubscriptionPreferences) wants(event string) bool {
+	switch event {
+	case NotifyEventGoLive:
+		return p.GoLive
+	case NotifyEventAnalysisReady:
+		return p.AnalysisReady
+	default:
+		return false
+	}
+}
+
+var subscriptionPreferencesMu sync.Mutex
+
+// GetSubscriptionPreferences 读取用户对某主播的通知偏好，未设置时返回默认值
+func GetSubscriptionPreferences(userHash, streamerID string) SubscriptionPreferences {
+	subscriptionPreferencesMu.Lock()
+	defer subscriptionPreferencesMu.Unlock()
+
+	all, err := loadSubscriptionPreferences()
+	if err != nil {
+		return defaultSubscriptionPreferences()
+	}
+	if prefs, ok := all[userHash][strings.ToLower(streamerID)]; ok {
+		return prefs
+	}
+	return defaultSubscriptionPreferences()
+}
+
+// SetSubscriptionPreferences 保存用户对某主播的通知偏好
+func SetSubscriptionPreferences(userHash, streamerID string, prefs SubscriptionPreferences) error {
+	subscriptionPreferencesMu.Lock()
+	defer subscriptionPreferencesMu.Unlock()
+
+	all, err := loadSubscriptionPreferences()
+	if err != nil {
+		return err
+	}
+	if all[userHash] == nil {
+		all[userHash] = make(map[string]SubscriptionPreferences)
+	}
+	all[userHash][strings.ToLower(streamerID)] = prefs
+	return saveSubscriptionPreferences(all)
+}
+
+// renameSubscriptionPreferencesUser 将通知偏好迁移到新的 userHash
+func renameSubscriptionPreferencesUser(oldHash, newHash string) error {
+	subscriptionPreferencesMu.Lock()
+	defer subscriptionPreferencesMu.Unlock()
+
+	all, err := loadSubscriptionPreferences()
+	if err != nil {
+		return err
+	}
+	prefs, ok := all[oldHash]
+	if !ok {
+		return nil
+	}
+	all[newHash] = prefs
+	delete(all, oldHash)
+	return saveSubscriptionPreferences(all)
+}
+
+// loadSubscriptionPreferences 读取所有用户的通知偏好，key: userHash -> streamerID
+func loadSubscriptionPreferences() (map[string]map[string]SubscriptionPreferences, error) {
+	data, err := os.ReadFile(subscriptionPreferencesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]map[string]SubscriptionPreferences{}, nil
+		}
+		return nil, err
+	}
+
+	all := map[string]map[string]SubscriptionPreferences{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// saveSubscriptionPreferences 将通知偏好写回文件
+func saveSubscriptionPreferences(all map[string]map[string]SubscriptionPreferences) error {
+	if err := os.MkdirAll(filepath.Dir(subscriptionPreferencesFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(subscriptionPreferencesFile, data, 0644)
+}
+
+// GetSubscriptionPreferencesHandler 获取用户对某主播的通知偏好
+func GetSubscriptionPreferencesHandler(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "未登录或登录已过期",
+		})
+		return
+	}
+
+	streamerID := strings.TrimPrefix(c.Param("streamerID"), "@")
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"streamer_id": streamerID,
+		"preferences": GetSubscriptionPreferences(userHash, streamerID),
+	})
+}
+
+// UpdateSubscriptionPreferences 更新用户对某主播的通知偏好（仅限已订阅的主播）
+func UpdateSubscriptionPreferences(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "未登录或登录已过期",
+		})
+		return
+	}
+
+	// 移除可能存在的 @ 符号
+	streamerID := strings.TrimPrefix(c.Param("streamerID"), "@")
+
+	// 未提供的字段保持原值
+	prefs := GetSubscriptionPreferences(userHash, streamerID)
+	if err := c.ShouldBindJSON(&prefs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的请求参数: " + err.Error(),
+		})
+		return
+	}
+
+	exists, err := services.CheckSubscriptionExists(userHash, streamerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "检查订阅状态失败: " + err.Error(),
+		})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "尚未订阅该主播",
+		})
+		return
+	}
+
+	if err := SetSubscriptionPreferences(userHash, streamerID, prefs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "保存通知设置失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "通知设置已更新",
+		"streamer_id": streamerID,
+		"preferences": prefs,
+	})
+}
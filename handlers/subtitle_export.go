@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 字幕导出格式
+const (
+	subtitleFormatSRT = "srt"
+	subtitleFormatVTT = "vtt"
+)
+
+// subtitleOffsetVOD offset 参数取该值时按片段在录像中的开始时间平移，使字幕与完整录像对齐
+const subtitleOffsetVOD = "vod"
+
+// subtitleCue 一条字幕，时间为秒
+type subtitleCue struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+// ClipSubtitleInfo 片段字幕的下载信息
+type ClipSubtitleInfo struct {
+	ID              string  `json:"id"` // 与片段下载地址中的标识相同
+	HotMomentOffset float64 `json:"hot_moment_offset"`
+	FormattedTime   string  `json:"formatted_time"`
+	VODStart        float64 `json:"vod_start"` // 片段第 0 秒对应的录像偏移（秒），offset=vod 时字幕按此平移
+	Duration        float64 `json:"duration"`
+	Cues            int     `json:"cues"`
+	SRTURL          string  `json:"srt_url"`
+	VTTURL          string  `json:"vtt_url"`
+	VODAlignedURL   string  `json:"vod_aligned_url"` // 与完整录像对齐的 WebVTT
+}
+
+// loadSubtitleCues 读取 SRT 文件并解析为字幕条目，无法解析时间的条目跳过
+func loadSubtitleCues(path string) ([]subtitleCue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	subtitles, err := ParseSRTDetailed(string(data))
+	if err != nil {
+		return nil, err
+	}
+	cues := make([]subtitleCue, 0, len(subtitles))
+	for _, sub := range subtitles {
+		start, err := parseSRTTime(sub.StartTime)
+		if err != nil {
+			continue
+		}
+		end, err := parseSRTTime(sub.EndTime)
+		if err != nil {
+			continue
+		}
+		cues = append(cues, subtitleCue{Start: start, End: end, Text: sub.Text})
+	}
+	return cues, nil
+}
+
+// shiftSubtitleCues 将字幕整体平移 shift 秒，平移后完全落在 0 之前的条目丢弃，跨过 0 的条目从 0 开始
+func shiftSubtitleCues(cues []subtitleCue, shift float64) []subtitleCue {
+	shifted := make([]subtitleCue, 0, len(cues))
+	for _, cue := range cues {
+		cue.Start += shift
+		cue.End += shift
+		if cue.End <= 0 {
+			continue
+		}
+		cue.Start = math.Max(cue.Start, 0)
+		shifted = append(shifted, cue)
+	}
+	return shifted
+}
+
+// formatSubtitleTime 格式化字幕时间，SRT 使用逗号分隔毫秒，WebVTT 使用点号
+func formatSubtitleTime(seconds float64, format string) string {
+	ms := int64(math.Round(seconds * 1000))
+	separator := ","
+	if format == subtitleFormatVTT {
+		separator = "."
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
+
+// renderSubtitles 将字幕条目生成为 SRT 或 WebVTT
+func renderSubtitles(cues []subtitleCue, format string) string {
+	var b strings.Builder
+	if format == subtitleFormatVTT {
+		b.WriteString("WEBVTT\n\n")
+	}
+	for i, cue := range cues {
+		if format == subtitleFormatSRT {
+			fmt.Fprintf(&b, "%d\n", i+1)
+		}
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", formatSubtitleTime(cue.Start, format), formatSubtitleTime(cue.End, format), cue.Text)
+	}
+	return b.String()
+}
+
+// subtitleFormatFromQuery 读取 format 参数，默认 srt
+func subtitleFormatFromQuery(c *gin.Context) (string, error) {
+	format := c.DefaultQuery("format", subtitleFormatSRT)
+	if format != subtitleFormatSRT && format != subtitleFormatVTT {
+		return "", fmt.Errorf("format 只能是 srt 或 vtt")
+	}
+	return format, nil
+}
+
+// subtitleShiftFromQuery 读取 offset 参数：vod 按片段开始时间平移，数字按指定秒数平移（可为负数），为空时不平移
+func subtitleShiftFromQuery(c *gin.Context, vodStart float64) (float64, error) {
+	offset := c.Query("offset")
+	switch offset {
+	case "":
+		return 0, nil
+	case subtitleOffsetVOD:
+		return vodStart, nil
+	}
+	shift, err := strconv.ParseFloat(offset, 64)
+	if err != nil || math.IsNaN(shift) || math.IsInf(shift, 0) {
+		return 0, fmt.Errorf("offset 只能是 vod 或秒数")
+	}
+	return shift, nil
+}
+
+// writeSubtitles 返回字幕内容，attachment=true 时以附件形式下载
+func writeSubtitles(c *gin.Context, name, format string, cues []subtitleCue) {
+	contentType := "application/x-subrip; charset=utf-8"
+	if format == subtitleFormatVTT {
+		contentType = "text/vtt; charset=utf-8"
+	}
+	disposition := "inline"
+	if c.Query("attachment") == "true" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name + "." + format}))
+	c.Data(http.StatusOK, contentType, []byte(renderSubtitles(cues, format)))
+}
+
+// ListSubtitles 列出录像各片段的语音识别字幕（不含已下架的片段）
+// 指定 format 时返回所有片段字幕按录像时间合并后的字幕文件，相邻片段重叠的部分只保留前一个片段的字幕，
+// offset 为秒数时在对齐录像的基础上再整体平移
+// GET /api/subtitles/:videoID?format=srt|vtt&offset=
+func ListSubtitles(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+
+	clips, err := loadClipSync(videoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取片段信息失败: " + err.Error()})
+		return
+	}
+	sort.Slice(clips, func(i, j int) bool {
+		return clips[i].VODStart < clips[j].VODStart
+	})
+
+	type clipCues struct {
+		clip ClipSyncInfo
+		cues []subtitleCue
+	}
+	var available []clipCues
+	for _, clip := range clips {
+		if clip.ClipFile == "" || IsTakenDown(ArtifactClip, videoID, clip.HotMomentOffset) {
+			continue
+		}
+		path := clipSubtitlePath(videoID, clip)
+		if path == "" {
+			continue
+		}
+		cues, err := loadSubtitleCues(path)
+		if err != nil || len(cues) == 0 {
+			continue
+		}
+		available = append(available, clipCues{clip: clip, cues: cues})
+	}
+
+	if c.Query("format") == "" {
+		base := strings.TrimSuffix(c.Request.URL.Path, "/")
+		items := make([]ClipSubtitleInfo, 0, len(available))
+		for _, a := range available {
+			id := clipID(a.clip.HotMomentOffset)
+			items = append(items, ClipSubtitleInfo{
+				ID:              id,
+				HotMomentOffset: a.clip.HotMomentOffset,
+				FormattedTime:   formatDuration(a.clip.HotMomentOffset),
+				VODStart:        a.clip.VODStart,
+				Duration:        a.clip.Duration,
+				Cues:            len(a.cues),
+				SRTURL:          base + "/" + id + "?format=" + subtitleFormatSRT,
+				VTTURL:          base + "/" + id + "?format=" + subtitleFormatVTT,
+				VODAlignedURL:   base + "/" + id + "?format=" + subtitleFormatVTT + "&offset=" + subtitleOffsetVOD,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"video_id":  videoID,
+			"subtitles": items,
+			"count":     len(items),
+		})
+		return
+	}
+
+	format, err := subtitleFormatFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 合并后的字幕已与录像对齐，offset=vod 不再额外平移
+	shift, err := subtitleShiftFromQuery(c, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(available) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该录像的片段没有字幕"})
+		return
+	}
+
+	var merged []subtitleCue
+	coveredUntil := math.Inf(-1)
+	for _, a := range available {
+		for _, cue := range shiftSubtitleCues(a.cues, a.clip.VODStart) {
+			if cue.Start < coveredUntil {
+				continue
+			}
+			merged = append(merged, cue)
+		}
+		coveredUntil = math.Max(coveredUntil, a.clip.VODStart+a.clip.Duration)
+	}
+	writeSubtitles(c, videoID, format, shiftSubtitleCues(merged, shift))
+}
+
+// GetClipSubtitle 返回片段的语音识别字幕，可转换为 WebVTT
+// offset=vod 时按片段在录像中的开始时间平移，使字幕与完整录像对齐；为秒数时按指定秒数平移
+// GET /api/subtitles/:videoID/:clipID?format=srt|vtt&offset=
+func GetClipSubtitle(c *gin.Context) {
+	videoID := filepath.Base(c.Param("videoID"))
+	format, err := subtitleFormatFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	clip, ok, err := findClip(videoID, c.Param("clipID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取片段信息失败: " + err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "片段不存在"})
+		return
+	}
+	if IsTakenDown(ArtifactClip, videoID, clip.HotMomentOffset) {
+		c.JSON(http.StatusGone, gin.H{"error": "该片段因违规举报已下架"})
+		return
+	}
+	shift, err := subtitleShiftFromQuery(c, clip.VODStart)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	path := clipSubtitlePath(videoID, clip)
+	if path == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该片段没有字幕"})
+		return
+	}
+	cues, err := loadSubtitleCues(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取字幕失败: " + err.Error()})
+		return
+	}
+	writeSubtitles(c, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), format, shiftSubtitleCues(cues, shift))
+}
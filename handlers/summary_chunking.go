@@ -0,0 +1,89 @@
+package handlers
+
+import "strings"
+
+const (
+	// defaultChunkOverlapRatio 相邻分段默认重叠分段大小的 10%
+	defaultChunkOverlapRatio = 0.1
+	// maxChunkOverlapRatio 重叠比例上限，重叠过多时分段数与AI调用次数明显增加
+	maxChunkOverlapRatio = 0.5
+)
+
+// transcriptChunk 一段待总结的字幕
+type transcriptChunk struct {
+	Text    string
+	Overlap int // 开头重复上一段末尾的字幕条数
+}
+
+// chunkOverlapRatio 当前配置的分段重叠比例，未配置时使用默认值
+func chunkOverlapRatio() float64 {
+	ratio := GetAIConfig().ChunkOverlapRatio
+	if ratio <= 0 {
+		return defaultChunkOverlapRatio
+	}
+	return min(ratio, maxChunkOverlapRatio)
+}
+
+// chunkTranscript 按字幕条目（以空行分隔）将字幕文本切分为不超过 maxChars 的分段，分段边界总是落在条目之间；
+// 每段开头重复上一段末尾不超过 maxChars×overlapRatio 的完整条目，使分段边界处的句子保留上下文。
+// 单个条目超过 maxChars 时独占一段
+func chunkTranscript(text string, maxChars int, overlapRatio float64) []transcriptChunk {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if len(text) <= maxChars {
+		return []transcriptChunk{{Text: text}}
+	}
+
+	const sep = "\n\n"
+	overlapChars := int(float64(maxChars) * overlapRatio)
+
+	var chunks []transcriptChunk
+	var current []string
+	currentLen := 0
+	overlap := 0
+
+	flush := func() {
+		chunks = append(chunks, transcriptChunk{Text: strings.Join(current, sep), Overlap: overlap})
+	}
+
+	for _, entry := range strings.Split(text, sep) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if len(current) > overlap && currentLen+len(sep)+len(entry) > maxChars {
+			flush()
+
+			// 从上一段末尾取完整条目作为重叠部分，且重叠部分加上新条目不超过分段大小
+			start, tailLen := len(current), 0
+			for start > 0 {
+				n := len(current[start-1])
+				if tailLen > 0 {
+					n += len(sep)
+				}
+				if tailLen+n > overlapChars || tailLen+n+len(sep)+len(entry) > maxChars {
+					break
+				}
+				tailLen += n
+				start--
+			}
+			current = append([]string(nil), current[start:]...)
+			currentLen = tailLen
+			overlap = len(current)
+		}
+
+		if len(current) > 0 {
+			currentLen += len(sep)
+		}
+		current = append(current, entry)
+		currentLen += len(entry)
+	}
+
+	if len(current) > overlap {
+		flush()
+	}
+	return chunks
+}
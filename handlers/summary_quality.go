@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"subtuber-services/pathsafe"
+)
+
+const (
+	// summaryEvalTranscriptChars 自评提示词中字幕的最大字符数，超出部分截断
+	summaryEvalTranscriptChars = 12000
+	// summaryEvalLowScore 低于或等于该分数视为低质量总结
+	summaryEvalLowScore = 2
+)
+
+// 各提供商使用的模型
+const (
+	googleAIModel      = "gemini-2.5-flash-lite"
+	defaultAliyunModel = "qwen-flash"
+)
+
+// SummaryEvaluation AI对总结的自评结果，保存在总结旁的 {offset}_summary_eval.json
+type SummaryEvaluation struct {
+	VideoID         string    `json:"video_id"`
+	OffsetSeconds   float64   `json:"offset_seconds"`
+	Provider        string    `json:"provider"`
+	Model           string    `json:"model"`
+	Faithfulness    int       `json:"faithfulness"` // 1-5，总结内容是否都能在字幕中找到依据
+	Coverage        int       `json:"coverage"`     // 1-5，是否覆盖了字幕中的主要内容
+	Comment         string    `json:"comment,omitempty"`
+	TranscriptChars int       `json:"transcript_chars"`
+	SummaryChars    int       `json:"summary_chars"`
+	EvaluatedAt     time.Time `json:"evaluated_at"`
+}
+
+var summaryEvalJSONRe = regexp.MustCompile(`(?s)\{.*\}`)
+
+// aiProviderName 规范化的提供商名称，未配置时与 NewAIService 一致默认为 google
+func aiProviderName(provider string) string {
+	switch provider {
+	case "aliyun", "openai", "ollama":
+		return provider
+	}
+	return "google"
+}
+
+// aiModelName 提供商当前使用的模型
+func aiModelName(provider string) string {
+	switch aiProviderName(provider) {
+	case "aliyun":
+		if model := GetAlibabaAPIConfig().Model; model != "" {
+			return model
+		}
+		return defaultAliyunModel
+	case "openai":
+		if model := GetOpenAIAPIConfig().Model; model != "" {
+			return model
+		}
+		return defaultOpenAIModel
+	case "ollama":
+		return GetOllamaConfig().Model
+	}
+	if model := GetGoogleAPIConfig().Model; model != "" {
+		return model
+	}
+	return googleAIModel
+}
+
+// summaryEvalPath 总结自评结果的文件路径
+func summaryEvalPath(videoID string, offsetSeconds float64) string {
+	return filepath.Join(pathsafe.Join("./analysis_results", videoID), pathsafe.Seconds(offsetSeconds)+"_summary_eval.json")
+}
+
+// evaluateSummary 让AI对照字幕给总结的忠实度与覆盖度打分（1-5）
+func evaluateSummary(ctx context.Context, aiService AIService, transcript, summary string) (faithfulness, coverage int, comment string, err error) {
+	if runes := []rune(transcript); len(runes) > summaryEvalTranscriptChars {
+		transcript = string(runes[:summaryEvalTranscriptChars])
+	}
+
+	prompt := "You are grading a summary of a livestream transcript.\n" +
+		"Score the summary from 1 (poor) to 5 (excellent) on:\n" +
+		"- faithfulness: every statement in the summary is supported by the transcript, with no invented details\n" +
+		"- coverage: the summary captures the main events and topics of the transcript\n" +
+		"Respond with JSON only, for example {\"faithfulness\": 4, \"coverage\": 3, \"comment\": \"one short sentence\"}.\n\n" +
+		"Transcript:\n" + transcript + "\n\nSummary:\n" + summary
+
+	text, err := aiService.GenerateContent(ctx, prompt, 200)
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	var result struct {
+		Faithfulness json.Number `json:"faithfulness"`
+		Coverage     json.Number `json:"coverage"`
+		Comment      string      `json:"comment"`
+	}
+	if err := json.Unmarshal([]byte(summaryEvalJSONRe.FindString(text)), &result); err != nil {
+		return 0, 0, "", fmt.Errorf("无法解析自评结果: %q", text)
+	}
+	faithfulness, err = parseEvalScore(result.Faithfulness)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	coverage, err = parseEvalScore(result.Coverage)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return faithfulness, coverage, strings.TrimSpace(result.Comment), nil
+}
+
+// parseEvalScore 解析 1-5 的分数，小数四舍五入
+func parseEvalScore(n json.Number) (int, error) {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || f < 1 || f > 5 {
+		return 0, fmt.Errorf("无效的自评分数: %q", n)
+	}
+	return int(math.Round(f)), nil
+}
+
+// recordSummaryEvaluation 对刚生成的总结执行自评并保存结果
+// 自评失败只记录日志，不影响总结本身
+func recordSummaryEvaluation(ctx context.Context, aiService AIService, provider, videoID string, offsetSeconds float64, transcript, summary string) {
+	if !IsFeatureEnabled(FeatureSummaryEval) {
+		return
+	}
+
+	ctx, span := startPipelineSpan(ctx, "ai_summary_eval", videoID)
+	faithfulness, coverage, comment, err := evaluateSummary(ctx, aiService, transcript, summary)
+	endSpan(span, err)
+	if err != nil {
+		log.Printf("热点 %.0f 秒的AI总结自评失败: %v", offsetSeconds, err)
+		return
+	}
+
+	eval := SummaryEvaluation{
+		VideoID:         videoID,
+		OffsetSeconds:   offsetSeconds,
+		Provider:        aiProviderName(provider),
+		Model:           aiModelName(provider),
+		Faithfulness:    faithfulness,
+		Coverage:        coverage,
+		Comment:         comment,
+		TranscriptChars: len([]rune(transcript)),
+		SummaryChars:    len([]rune(summary)),
+		EvaluatedAt:     time.Now(),
+	}
+	data, err := json.MarshalIndent(eval, "", "  ")
+	if err == nil {
+		err = os.WriteFile(summaryEvalPath(videoID, offsetSeconds), data, 0644)
+	}
+	if err != nil {
+		log.Printf("保存AI总结自评结果失败: %v", err)
+		return
+	}
+	log.Printf("热点 %.0f 秒的AI总结自评: 忠实度 %d，覆盖度 %d (%s/%s)", offsetSeconds, faithfulness, coverage, eval.Provider, eval.Model)
+}
+
+// loadSummaryEvaluations 读取指定时间之后的所有总结自评结果
+func loadSummaryEvaluations(since time.Time) []SummaryEvaluation {
+	paths, _ := filepath.Glob(filepath.Join("./analysis_results", "*", "*_summary_eval.json"))
+	evals := make([]SummaryEvaluation, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var eval SummaryEvaluation
+		if err := json.Unmarshal(data, &eval); err != nil {
+			continue
+		}
+		if eval.EvaluatedAt.Before(since) {
+			continue
+		}
+		evals = append(evals, eval)
+	}
+	return evals
+}
+
+// summaryQualityStats 单个提供商/模型的总结质量汇总
+type summaryQualityStats struct {
+	Provider            string  `json:"provider"`
+	Model               string  `json:"model"`
+	Count               int     `json:"count"`
+	AvgFaithfulness     float64 `json:"avg_faithfulness"`
+	AvgCoverage         float64 `json:"avg_coverage"`
+	LowFaithfulnessRate float64 `json:"low_faithfulness_rate"` // 忠实度不高于 2 分的比例
+	LowCoverageRate     float64 `json:"low_coverage_rate"`
+	Distribution        [5]int  `json:"faithfulness_distribution"` // 忠实度 1-5 分各自的数量
+}
+
+// aggregateSummaryQuality 按提供商与模型汇总总结自评结果，样本多的在前
+func aggregateSummaryQuality(evals []SummaryEvaluation) []summaryQualityStats {
+	byModel := map[string]*summaryQualityStats{}
+	for _, eval := range evals {
+		key := eval.Provider + "/" + eval.Model
+		stats, ok := byModel[key]
+		if !ok {
+			stats = &summaryQualityStats{Provider: eval.Provider, Model: eval.Model}
+			byModel[key] = stats
+		}
+		stats.Count++
+		stats.AvgFaithfulness += float64(eval.Faithfulness)
+		stats.AvgCoverage += float64(eval.Coverage)
+		if eval.Faithfulness <= summaryEvalLowScore {
+			stats.LowFaithfulnessRate++
+		}
+		if eval.Coverage <= summaryEvalLowScore {
+			stats.LowCoverageRate++
+		}
+		if eval.Faithfulness >= 1 && eval.Faithfulness <= 5 {
+			stats.Distribution[eval.Faithfulness-1]++
+		}
+	}
+
+	result := make([]summaryQualityStats, 0, len(byModel))
+	for _, stats := range byModel {
+		n := float64(stats.Count)
+		stats.AvgFaithfulness = math.Round(stats.AvgFaithfulness/n*100) / 100
+		stats.AvgCoverage = math.Round(stats.AvgCoverage/n*100) / 100
+		stats.LowFaithfulnessRate = math.Round(stats.LowFaithfulnessRate/n*1000) / 1000
+		stats.LowCoverageRate = math.Round(stats.LowCoverageRate/n*1000) / 1000
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Provider+"/"+result[i].Model < result[j].Provider+"/"+result[j].Model
+	})
+	return result
+}
+
+// getSummaryQualityHandler 管理员查看各提供商/模型的总结质量，用于选择AI服务
+// 可选参数 days 指定统计最近多少天，默认 30 天
+func getSummaryQualityHandler(c *gin.Context) {
+	days := 30
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "days 参数无效"})
+			return
+		}
+		days = n
+	}
+
+	evals := loadSummaryEvaluations(time.Now().AddDate(0, 0, -days))
+
+	resp := gin.H{
+		"success": true,
+		"days":    days,
+		"total":   len(evals),
+		"models":  aggregateSummaryQuality(evals),
+	}
+	// 列出最差的几条总结，便于人工复核
+	if c.Query("include_worst") == "true" {
+		sort.Slice(evals, func(i, j int) bool {
+			si, sj := evals[i].Faithfulness+evals[i].Coverage, evals[j].Faithfulness+evals[j].Coverage
+			if si != sj {
+				return si < sj
+			}
+			return evals[i].EvaluatedAt.After(evals[j].EvaluatedAt)
+		})
+		if len(evals) > 20 {
+			evals = evals[:20]
+		}
+		resp["worst"] = evals
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// streamingSummaries 正在流式生成总结的热点，同一热点同时只生成一次
+	streamingSummariesMu sync.Mutex
+	streamingSummaries   = map[string]bool{}
+)
+
+// beginStreamingSummary 登记热点开始生成总结，已在生成时返回 false
+func beginStreamingSummary(key string) bool {
+	streamingSummariesMu.Lock()
+	defer streamingSummariesMu.Unlock()
+	if streamingSummaries[key] {
+		return false
+	}
+	streamingSummaries[key] = true
+	return true
+}
+
+func endStreamingSummary(key string) {
+	streamingSummariesMu.Lock()
+	defer streamingSummariesMu.Unlock()
+	delete(streamingSummaries, key)
+}
+
+// momentTranscript 读取热点片段已保存的字幕
+func momentTranscript(videoID string, offsetSeconds float64) (ClipRunRecord, string, error) {
+	clipRunsMu.Lock()
+	runs, err := loadClipRuns(videoID)
+	clipRunsMu.Unlock()
+	if err != nil {
+		return ClipRunRecord{}, "", err
+	}
+	for _, run := range runs {
+		if math.Abs(run.HotMomentOffset-offsetSeconds) >= 1 {
+			continue
+		}
+		srtContent, err := os.ReadFile(transcriptPathForRun(videoID, run))
+		if err != nil || len(srtContent) == 0 {
+			return run, "", os.ErrNotExist
+		}
+		return run, string(srtContent), nil
+	}
+	return ClipRunRecord{}, "", os.ErrNotExist
+}
+
+// StreamMomentSummary 立即为热点生成AI总结，以 Server-Sent Events 逐段推送生成的文本
+// 字幕较长时先分段总结（progress 事件报告进度），最终总结逐段推送（delta 事件），完成后保存并发送 done 事件；
+// 失败时发送 failed 事件。支持流式输出的提供商逐 token 推送，其余提供商生成完成后一次推送
+// GET /api/analysis/:videoID/summary/stream?offset_seconds=...
+func StreamMomentSummary(c *gin.Context) {
+	if _, err := getUserHashFromCookie(c); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未登录或登录已过期",
+		})
+		return
+	}
+
+	if !IsFeatureEnabled(FeatureAISummary) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "AI总结功能未开启",
+		})
+		return
+	}
+
+	videoID := c.Param("videoID")
+	offsetSeconds, err := strconv.ParseFloat(c.Query("offset_seconds"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset_seconds is required",
+		})
+		return
+	}
+
+	if IsTakenDown(ArtifactSummary, videoID, offsetSeconds) {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "summary has been taken down",
+		})
+		return
+	}
+
+	run, srtContent, err := momentTranscript(videoID, offsetSeconds)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "该热点还没有字幕，无法生成总结",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "读取片段处理记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	key := fmt.Sprintf("%s/%.0f", videoID, run.HotMomentOffset)
+	if !beginStreamingSummary(key) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "该热点的总结正在生成，请稍后查看",
+		})
+		return
+	}
+	defer endStreamingSummary(key)
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	ctx := c.Request.Context()
+	plan := loadPipelinePlan(videoID)
+	summaryCtx := withSummaryPromptVars(withSummaryLanguage(ctx, plan.SummaryLanguage), summaryPromptVarsFor(plan, run.HotMomentOffset))
+	aiConfig := GetAIConfig()
+	aiService := NewConfiguredAIService()
+
+	summaryCtx, summarySpan := startPipelineSpan(summaryCtx, "ai_summary_stream", videoID,
+		attribute.String("ai.provider", aiConfig.Provider))
+	summary, _, err := summarizeSRTStream(summaryCtx, aiService, srtContent, 0, summaryStreamCallbacks{
+		OnChunk: func(done, total int) {
+			c.SSEvent("progress", gin.H{"done": done, "total": total})
+			c.Writer.Flush()
+		},
+		OnDelta: func(delta string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			c.SSEvent("delta", gin.H{"text": delta})
+			c.Writer.Flush()
+			return nil
+		},
+	})
+	endSpan(summarySpan, err)
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("客户端已断开，停止生成视频 %s 热点 %.0f 秒的总结", videoID, offsetSeconds)
+			return
+		}
+		log.Printf("流式生成视频 %s 热点 %.0f 秒的AI总结失败: %v", videoID, offsetSeconds, err)
+		c.SSEvent("failed", gin.H{"error": err.Error()})
+		c.Writer.Flush()
+		return
+	}
+
+	// 客户端收到完整总结后才断开也应保存，使用不随请求取消的 context
+	saved := true
+	if err := saveHotMomentSummary(context.WithoutCancel(ctx), aiService, aiConfig.Provider, videoID, run.HotMomentOffset, srtContent, summary); err != nil {
+		log.Printf("保存视频 %s 热点 %.0f 秒的AI总结失败: %v", videoID, offsetSeconds, err)
+		saved = false
+	} else {
+		setClipSummaryStatus(videoID, run.HotMomentOffset, clipRunOK, "")
+	}
+
+	c.SSEvent("done", gin.H{
+		"summary":       summary,
+		"actual_offset": run.HotMomentOffset,
+		"saved":         saved,
+	})
+	c.Writer.Flush()
+}
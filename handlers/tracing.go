@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	Enabled     bool    `mapstructure:"enabled" json:"enabled"`
+	Endpoint    string  `mapstructure:"endpoint" json:"endpoint"`         // OTLP/HTTP 地址，例如 localhost:4318（Jaeger 可直接接收 OTLP）
+	Insecure    bool    `mapstructure:"insecure" json:"insecure"`         // 是否使用 HTTP 而非 HTTPS
+	ServiceName string  `mapstructure:"service_name" json:"service_name"` // 默认 subtuber-services
+	SampleRatio float64 `mapstructure:"sample_ratio" json:"sample_ratio"` // 采样比例（0-1），默认全部采样
+}
+
+const tracerName = "subtuber-services"
+
+// tracer 在未启用追踪时为 no-op 实现
+var tracer = otel.Tracer(tracerName)
+
+// InitTracing 初始化全局 TracerProvider，返回用于退出时刷新数据的关闭函数
+func InitTracing(cfg TracingConfig) (func(context.Context) error, error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = tracerName
+	}
+	if cfg.SampleRatio <= 0 || cfg.SampleRatio > 1 {
+		cfg.SampleRatio = 1
+	}
+
+	opts := []otlptracehttp.Option{}
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("创建 OTLP 导出器失败: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("创建追踪资源失败: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	log.Printf("OpenTelemetry 追踪已启用，导出到 %s", cfg.Endpoint)
+	return provider.Shutdown, nil
+}
+
+// newTracedHTTPClient 创建带有出站请求追踪的 HTTP 客户端
+func newTracedHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
+}
+
+// startPipelineSpan 开始一个流水线阶段的 span，并以视频ID关联同一视频的所有阶段
+func startPipelineSpan(ctx context.Context, stage, videoID string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("pipeline.stage", stage), attribute.String("video.id", videoID))
+	return tracer.Start(ctx, "pipeline."+stage, trace.WithAttributes(attrs...))
+}
+
+// endSpan 结束 span，出错时记录错误状态
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -1,14 +1,15 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
+	"math"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -17,10 +18,14 @@ import (
 	"sync"
 	"time"
 
+	"subtuber-services/chatdownload"
 	"subtuber-services/models"
+	"subtuber-services/pathsafe"
 	"subtuber-services/services"
+	"subtuber-services/twitchclient"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -42,6 +47,56 @@ type TwitchConfig struct {
 	MinInterval    int    `mapstructure:"min_interval_seconds"`    // 最小检查间隔（秒）
 	MaxInterval    int    `mapstructure:"max_interval_seconds"`    // 最大检查间隔（秒）
 	ReloadInterval int    `mapstructure:"reload_interval_minutes"` // 重新加载主播列表的间隔（分钟）
+	RedirectURL    string `mapstructure:"redirect_url"`            // 用户关联 Twitch 账号的 OAuth 回调地址，为空时不启用关联
+
+	// Credentials 额外的应用凭据，与 client_id 组成凭据池，Helix 被限流时轮换使用；用户关联 OAuth 只使用 client_id
+	Credentials []twitchclient.Credential `mapstructure:"credentials"`
+
+	OfflineConfirmations  int `mapstructure:"offline_confirmations"`    // 直播中连续多少次检查为离线才判定下播
+	StreamEndGraceSeconds int `mapstructure:"stream_end_grace_seconds"` // 判定下播后等待多久才开始下播处理，期间恢复直播则取消
+}
+
+// applyDefaults 为未设置的检查间隔填充默认值
+func (c *TwitchConfig) applyDefaults() {
+	if c.MinInterval == 0 {
+		c.MinInterval = 30 // 默认最小30秒
+	}
+	if c.MaxInterval == 0 {
+		c.MaxInterval = 120 // 默认最大120秒
+	}
+	if c.ReloadInterval == 0 {
+		c.ReloadInterval = 10 // 默认每10分钟重新加载一次
+	}
+	if c.OfflineConfirmations == 0 {
+		c.OfflineConfirmations = defaultOfflineConfirmations
+	}
+	if c.StreamEndGraceSeconds == 0 {
+		c.StreamEndGraceSeconds = defaultStreamEndGraceSeconds
+	}
+}
+
+// appCredentials 返回 Helix 客户端使用的应用凭据，client_id 为主凭据排在最前
+func (c TwitchConfig) appCredentials() []twitchclient.Credential {
+	return append([]twitchclient.Credential{{ClientID: c.ClientID, ClientSecret: c.ClientSecret}}, c.Credentials...)
+}
+
+// Validate 校验检查间隔配置
+func (c TwitchConfig) Validate() error {
+	if c.MinInterval < 0 || c.MaxInterval < 0 || c.ReloadInterval < 0 {
+		return fmt.Errorf("twitch 检查间隔不能为负数")
+	}
+	if c.MaxInterval != 0 && c.MinInterval > c.MaxInterval {
+		return fmt.Errorf("twitch 最小检查间隔(%d)不能大于最大检查间隔(%d)", c.MinInterval, c.MaxInterval)
+	}
+	if c.OfflineConfirmations < 0 || c.StreamEndGraceSeconds < 0 {
+		return fmt.Errorf("twitch 下播确认次数与宽限期不能为负数")
+	}
+	for i, credential := range c.Credentials {
+		if credential.ClientID == "" || credential.ClientSecret == "" {
+			return fmt.Errorf("twitch.credentials[%d] 需要同时设置 client_id 与 client_secret", i)
+		}
+	}
+	return nil
 }
 
 // StreamerStatus 主播状态
@@ -54,12 +109,12 @@ type StreamerStatus struct {
 // TwitchMonitor Twitch监控服务
 type TwitchMonitor struct {
 	config         TwitchConfig
-	accessToken    string
-	tokenExpiry    time.Time
+	api            *twitchclient.Client // Helix 客户端，所有 Helix 请求共用其凭据池
 	mu             sync.RWMutex
 	streamers      []models.StreamerInfo      // 追踪的主播列表
 	streamerStatus map[string]*StreamerStatus // 主播ID -> 状态
 	lastReloadTime time.Time                  // 上次重新加载配置的时间
+	schedule       *monitorSchedule           // 各主播的下次检查时间
 	stopCh         chan struct{}
 }
 
@@ -67,19 +122,13 @@ type TwitchMonitor struct {
 func InitTwitchMonitor(config TwitchConfig) *TwitchMonitor {
 	twitchMonitorOnce.Do(func() {
 		// 设置默认值
-		if config.MinInterval == 0 {
-			config.MinInterval = 30 // 默认最小30秒
-		}
-		if config.MaxInterval == 0 {
-			config.MaxInterval = 120 // 默认最大120秒
-		}
-		if config.ReloadInterval == 0 {
-			config.ReloadInterval = 10 // 默认每10分钟重新加载一次
-		}
+		config.applyDefaults()
 
 		twitchMonitor = &TwitchMonitor{
 			config:         config,
+			api:            twitchclient.New(newTracedHTTPClient(15*time.Second), config.appCredentials()...),
 			streamerStatus: make(map[string]*StreamerStatus),
+			schedule:       newMonitorSchedule(),
 			stopCh:         make(chan struct{}),
 		}
 
@@ -166,12 +215,12 @@ func (tm *TwitchMonitor) monitorLoop() {
 			}
 		}
 
-		// 随机间隔时间
-		interval := tm.getRandomInterval()
-		log.Printf("下次检查将在 %d 秒后进行", interval)
+		// 随机间隔时间，有主播更早到期时提前检查
+		interval := tm.schedule.wait(time.Duration(tm.getRandomInterval())*time.Second, time.Now())
+		log.Printf("下次检查将在 %s 后进行", interval)
 
 		select {
-		case <-time.After(time.Duration(interval) * time.Second):
+		case <-time.After(interval):
 			tm.checkAllStreamers()
 		case <-tm.stopCh:
 			return
@@ -179,8 +228,19 @@ func (tm *TwitchMonitor) monitorLoop() {
 	}
 }
 
+// isStreamerLive 主播最近一次检查是否在直播
+func (tm *TwitchMonitor) isStreamerLive(streamerID string) bool {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	status, ok := tm.streamerStatus[streamerID]
+	return ok && status.isLive
+}
+
 // getRandomInterval 获取随机检查间隔
 func (tm *TwitchMonitor) getRandomInterval() int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
 	min := tm.config.MinInterval
 	max := tm.config.MaxInterval
 	if max <= min {
@@ -191,6 +251,11 @@ func (tm *TwitchMonitor) getRandomInterval() int {
 
 // checkAllStreamers 检查所有主播的状态
 func (tm *TwitchMonitor) checkAllStreamers() {
+	if !IsFeatureEnabled(FeatureTwitchMonitoring) {
+		log.Println("Twitch监控已通过功能开关禁用，跳过本次检查")
+		return
+	}
+
 	// 确保有有效的访问令牌
 	if err := tm.ensureValidToken(); err != nil {
 		log.Printf("获取访问令牌失败: %v", err)
@@ -207,11 +272,19 @@ func (tm *TwitchMonitor) checkAllStreamers() {
 		return
 	}
 
-	log.Printf("开始检查 %d 个主播的直播状态...", len(streamers))
+	// 只检查已到检查时间的主播，间隔由各主播的监控计划决定
+	due := tm.schedule.due(streamers, time.Now())
+	if len(due) == 0 {
+		return
+	}
+	log.Printf("开始检查 %d 个主播的直播状态 (共%d个)...", len(due), len(streamers))
 
 	// 逐个检查主播状态
-	for _, streamer := range streamers {
+	for _, streamer := range due {
 		tm.checkStreamerStatus(streamer)
+		base := time.Duration(tm.getRandomInterval()) * time.Second
+		interval := streamerCheckInterval(streamer, base, tm.isStreamerLive(streamer.ID), time.Now())
+		tm.schedule.checked(streamer.ID, interval, time.Now())
 		// 在检查之间添加短暂延迟，避免请求过于频繁
 		time.Sleep(time.Duration(1+rand.Intn(3)) * time.Second)
 	}
@@ -224,10 +297,7 @@ func (tm *TwitchMonitor) checkStreamerStatus(streamer models.StreamerInfo) {
 	for _, platform := range streamer.Platforms {
 		if platform.Platform == "twitch" {
 			// 从 URL 中提取用户名，例如 https://www.twitch.tv/kanekolumi
-			parts := strings.Split(platform.URL, "/")
-			if len(parts) > 0 {
-				twitchUsername = parts[len(parts)-1]
-			}
+			twitchUsername = platformURLHandle(platform.URL)
 			break
 		}
 	}
@@ -244,6 +314,7 @@ func (tm *TwitchMonitor) checkStreamerStatus(streamer models.StreamerInfo) {
 		userInfo, err := tm.getUserInfo(twitchUsername)
 		if err != nil {
 			log.Printf("获取 %s 用户信息失败: %v", streamer.Name, err)
+			recordStreamerIssue(streamer.ID, diagStageResolve, "", err)
 			// 检查是否是用户不存在的错误
 			if strings.Contains(err.Error(), "用户不存在") {
 				log.Printf("主播 %s (用户名: %s) 不存在，将从配置中移除", streamer.Name, twitchUsername)
@@ -257,7 +328,10 @@ func (tm *TwitchMonitor) checkStreamerStatus(streamer models.StreamerInfo) {
 					tm.mu.Unlock()
 				}
 			}
-		} else if userInfo.ProfileImageURL != "" {
+		} else {
+			clearStreamerIssue(streamer.ID, diagStageResolve)
+		}
+		if err == nil && userInfo.ProfileImageURL != "" {
 			if err := tm.updateStreamerProfileImage(streamer.ID, twitchUsername, userInfo.ProfileImageURL); err != nil {
 				log.Printf("更新 %s 头像URL失败: %v", streamer.Name, err)
 			}
@@ -268,8 +342,10 @@ func (tm *TwitchMonitor) checkStreamerStatus(streamer models.StreamerInfo) {
 	stream, err := tm.CheckStreamStatusByUsername(twitchUsername)
 	if err != nil {
 		log.Printf("检查 %s 直播状态失败: %v", streamer.Name, err)
+		recordStreamerIssue(streamer.ID, diagStageLiveCheck, "", err)
 		return
 	}
+	clearStreamerIssue(streamer.ID, diagStageLiveCheck)
 
 	// 获取之前的状态
 	tm.mu.Lock()
@@ -282,9 +358,22 @@ func (tm *TwitchMonitor) checkStreamerStatus(streamer models.StreamerInfo) {
 		tm.streamerStatus[streamer.ID] = status
 	}
 	previousIsLive := status.isLive
+	checkedBefore := !status.lastChecked.IsZero()
+	currentIsLive := stream != nil
+
+	// 直播中的主播需连续多次检查为离线才判定下播，避免单次检查异常触发下播处理
+	if previousIsLive && !currentIsLive {
+		confirmations := tm.config.OfflineConfirmations
+		if streak, confirmed := streamEnds.observeOffline("twitch", streamer.ID, confirmations); !confirmed {
+			status.lastChecked = time.Now()
+			tm.mu.Unlock()
+			log.Printf("⚠️ %s 检查为离线 (%d/%d)，等待再次确认后判定下播", streamer.Name, streak, confirmations)
+			return
+		}
+	}
+	graceSeconds := tm.config.StreamEndGraceSeconds
 
 	// 更新状态
-	currentIsLive := stream != nil
 	status.isLive = currentIsLive
 	status.lastChecked = time.Now()
 	status.latestStatus = &models.TwitchStatusResponse{
@@ -298,23 +387,39 @@ func (tm *TwitchMonitor) checkStreamerStatus(streamer models.StreamerInfo) {
 	if stream != nil {
 		log.Printf("🔴 %s 正在直播！标题: %s, 观众: %d",
 			stream.UserName, stream.Title, stream.ViewerCount)
+		RecordStreamerDisplayName(streamer.ID, stream.UserName)
+		recordStreamGame(stream.UserLogin, stream.GameName)
+		resumed := streamEnds.observeLive("twitch", streamer.ID)
+
+		// 检测从离线变为直播状态（服务启动后的首次检查与宽限期内恢复直播不发送通知）
+		if checkedBefore && !previousIsLive && !resumed {
+			go NotifySubscribers(streamer.ID, NotifyEventGoLive,
+				fmt.Sprintf("%s 开始直播了", stream.UserName),
+				fmt.Sprintf("%s\nhttps://www.twitch.tv/%s", stream.Title, stream.UserLogin))
+			go postGoLiveWebhooks([]string{streamer.ID, streamer.Name, stream.UserLogin}, stream.UserName,
+				stream.Title, "https://www.twitch.tv/"+stream.UserLogin)
+		}
 	} else {
 		log.Printf("⚫ %s 当前离线", streamer.Name)
 
 		// 检测从直播状态变为离线状态
 		if previousIsLive {
 			log.Printf("🎬 检测到 %s 的直播结束，开始自动下载聊天记录...", streamer.Name)
-
-			// 检查并下载最近的聊天记录进行分析
-			go func(username string) {
-				newResults := tm.GetVideoCommentsForStreamer(username)
-				if len(newResults) > 0 {
-					log.Printf("📊 完成 %s 的 %d 个新视频的分析", username, len(newResults))
-					for _, result := range newResults {
-						log.Printf("  - VideoID: %s, 热点时刻: %d", result.VideoID, len(result.HotMoments))
-					}
-				}
-			}(twitchUsername)
+			stopLiveChatCapture(streamer.ID)
+			go postOfflineWebhooks([]string{streamer.ID, streamer.Name, twitchUsername}, streamer.Name,
+				"https://www.twitch.tv/"+twitchUsername)
+			invalidateVideoListCache("twitch", twitchUsername)
+			pipelineSLO.markStreamEnded("twitch", twitchUsername, time.Now())
+
+			// 检查并下载最近的聊天记录进行分析（持久化，重启后继续；宽限期后加入队列，积压时按调度策略排队）
+			job := PersistedJob{
+				Stage:        persistedStageStreamEnded,
+				Platform:     "twitch",
+				StreamerID:   streamer.ID,
+				Streamer:     twitchUsername,
+				StreamerName: streamer.Name,
+			}
+			streamEnds.scheduleEnd(job, graceSeconds, func() { tm.runStreamEndedJob(job) })
 		}
 	}
 }
@@ -324,52 +429,9 @@ func (tm *TwitchMonitor) checkAndUpdate() {
 	tm.checkAllStreamers()
 }
 
-// ensureValidToken 确保有有效的访问令牌
+// ensureValidToken 确保凭据池当前使用的凭据有有效的访问令牌
 func (tm *TwitchMonitor) ensureValidToken() error {
-	tm.mu.RLock()
-	if tm.accessToken != "" && time.Now().Before(tm.tokenExpiry) {
-		tm.mu.RUnlock()
-		return nil
-	}
-	tm.mu.RUnlock()
-
-	// 需要获取新令牌
-	token, expiresIn, err := tm.getAccessToken()
-	if err != nil {
-		return err
-	}
-
-	tm.mu.Lock()
-	tm.accessToken = token
-	tm.tokenExpiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
-	tm.mu.Unlock()
-
-	log.Println("成功获取新的访问令牌")
-	return nil
-}
-
-// getAccessToken 获取OAuth访问令牌
-func (tm *TwitchMonitor) getAccessToken() (string, int, error) {
-	url := fmt.Sprintf("https://id.twitch.tv/oauth2/token?client_id=%s&client_secret=%s&grant_type=client_credentials",
-		tm.config.ClientID, tm.config.ClientSecret)
-
-	resp, err := http.Post(url, "application/json", nil)
-	if err != nil {
-		return "", 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", 0, err
-	}
-
-	var tokenResp models.TwitchTokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return "", 0, err
-	}
-
-	return tokenResp.AccessToken, tokenResp.ExpiresIn, nil
+	return tm.api.EnsureToken(context.Background())
 }
 
 // checkStreamStatus 检查直播状态（保留用于向后兼容）
@@ -379,12 +441,9 @@ func (tm *TwitchMonitor) checkStreamStatus() (*models.TwitchStreamData, error) {
 	if len(tm.streamers) > 0 {
 		for _, platform := range tm.streamers[0].Platforms {
 			if platform.Platform == "twitch" {
-				parts := strings.Split(platform.URL, "/")
-				if len(parts) > 0 {
-					username := parts[len(parts)-1]
-					tm.mu.RUnlock()
-					return tm.CheckStreamStatusByUsername(username)
-				}
+				username := platformURLHandle(platform.URL)
+				tm.mu.RUnlock()
+				return tm.CheckStreamStatusByUsername(username)
 			}
 		}
 	}
@@ -395,34 +454,8 @@ func (tm *TwitchMonitor) checkStreamStatus() (*models.TwitchStreamData, error) {
 
 // CheckStreamStatusByUsername 根据用户名检查直播状态
 func (tm *TwitchMonitor) CheckStreamStatusByUsername(username string) (*models.TwitchStreamData, error) {
-	url := fmt.Sprintf("https://api.twitch.tv/helix/streams?user_login=%s", username)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	tm.mu.RLock()
-	accessToken := tm.accessToken
-	tm.mu.RUnlock()
-
-	req.Header.Set("Client-ID", tm.config.ClientID)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
 	var streamResp models.TwitchStreamResponse
-	if err := json.Unmarshal(body, &streamResp); err != nil {
+	if err := tm.api.Get(context.Background(), "/streams", url.Values{"user_login": {username}}, &streamResp); err != nil {
 		return nil, err
 	}
 
@@ -516,8 +549,27 @@ func CheckTwitchStatusNow(c *gin.Context) {
 	})
 }
 
-// getVideos 获取录像列表
+// getVideos 获取录像列表，结果短时间缓存，并顺带缓存列表中每个录像的信息
 func (tm *TwitchMonitor) getVideos(username, videoType, first, after string) (*models.TwitchVideosListResponse, error) {
+	key := videoListCacheKey(username, videoType, first, after)
+	response, err := cachedVideoMetadata("twitch", key, videoListCacheTTL, func() (models.TwitchVideosListResponse, error) {
+		resp, err := tm.fetchVideos(username, videoType, first, after)
+		if err != nil {
+			return models.TwitchVideosListResponse{}, err
+		}
+		for _, video := range resp.Videos {
+			storeVideoMetadata("twitch", videoInfoCacheKey(video.ID), videoInfoCacheTTL, video)
+		}
+		return *resp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// fetchVideos 从 Helix 接口获取录像列表
+func (tm *TwitchMonitor) fetchVideos(username, videoType, first, after string) (*models.TwitchVideosListResponse, error) {
 	// 首先需要通过用户名获取用户ID
 	// 因为这个用户ID是不会改变的，建议通过rpc进行吃持久化
 	userID, err := tm.getUserID(username)
@@ -525,45 +577,18 @@ func (tm *TwitchMonitor) getVideos(username, videoType, first, after string) (*m
 		return nil, fmt.Errorf("获取用户ID失败: %w", err)
 	}
 
-	// 构建URL - 使用user_id而不是user_login
-	url := fmt.Sprintf("https://api.twitch.tv/helix/videos?user_id=%s&first=%s", userID, first)
-
+	query := url.Values{"user_id": {userID}, "first": {first}}
 	// 添加录像类型过滤
 	if videoType != "all" {
-		url += "&type=" + videoType
+		query.Set("type", videoType)
 	}
-
 	// 添加分页游标
 	if after != "" {
-		url += "&after=" + after
-	}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	tm.mu.RLock()
-	accessToken := tm.accessToken
-	tm.mu.RUnlock()
-
-	req.Header.Set("Client-ID", tm.config.ClientID)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+		query.Set("after", after)
 	}
 
 	var videoResp models.TwitchVideoResponse
-	if err := json.Unmarshal(body, &videoResp); err != nil {
+	if err := tm.api.Get(context.Background(), "/videos", query, &videoResp); err != nil {
 		return nil, err
 	}
 
@@ -592,34 +617,8 @@ func (tm *TwitchMonitor) getUserID(username string) (string, error) {
 
 // getUserInfo 通过用户名获取完整用户信息
 func (tm *TwitchMonitor) getUserInfo(username string) (*models.TwitchUserData, error) {
-	url := fmt.Sprintf("https://api.twitch.tv/helix/users?login=%s", username)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	tm.mu.RLock()
-	accessToken := tm.accessToken
-	tm.mu.RUnlock()
-
-	req.Header.Set("Client-ID", tm.config.ClientID)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
 	var userResp models.TwitchUserResponse
-	if err := json.Unmarshal(body, &userResp); err != nil {
+	if err := tm.api.Get(context.Background(), "/users", url.Values{"login": {username}}, &userResp); err != nil {
 		return nil, err
 	}
 
@@ -713,6 +712,7 @@ func (tm *TwitchMonitor) removeStreamerFromConfig(streamerID string) error {
 }
 
 // DownloadVODChat 下载VOD聊天记录的HTTP处理器
+// collapse_emotes=true 时将纯表情刷屏合并为 collapsed_emotes 中的条目（用于回放展示）
 func DownloadVODChat(c *gin.Context) {
 	monitor := GetTwitchMonitor()
 	if monitor == nil {
@@ -722,6 +722,14 @@ func DownloadVODChat(c *gin.Context) {
 		return
 	}
 
+	collapse, err := emoteCollapseFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	var req models.TwitchChatDownloadRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -739,7 +747,7 @@ func DownloadVODChat(c *gin.Context) {
 	}
 
 	// 下载聊天记录
-	response, err := monitor.downloadChatComments(req.VideoID, req.StartTime, req.EndTime)
+	response, err := monitor.fetchChatComments(req.VideoID, req.StartTime, req.EndTime)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "下载聊天记录失败: " + err.Error(),
@@ -747,6 +755,10 @@ func DownloadVODChat(c *gin.Context) {
 		return
 	}
 
+	if collapse.Enabled {
+		c.JSON(http.StatusOK, newCollapsedTwitchChat(response, collapse))
+		return
+	}
 	c.JSON(http.StatusOK, response)
 }
 
@@ -776,8 +788,8 @@ func SaveVODChatToFile(c *gin.Context) {
 		return
 	}
 
-	// 下载聊天记录
-	response, err := monitor.downloadChatComments(req.VideoID, req.StartTime, req.EndTime)
+	// 下载聊天记录，逐条写入文件
+	savedPath, response, err := monitor.downloadChatComments(req.VideoID, req.StartTime, req.EndTime)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "下载聊天记录失败: " + err.Error(),
@@ -785,225 +797,69 @@ func SaveVODChatToFile(c *gin.Context) {
 		return
 	}
 
-	// 保存到文件
-	filename := fmt.Sprintf("chat_%s_%s.json", req.VideoID, time.Now().Format("20060102_150405"))
-	filepath := filepath.Join("./chat_logs", filename)
-
-	// 确保目录存在
-	if err := os.MkdirAll("./chat_logs", 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "创建目录失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 将数据序列化为JSON
-	jsonData, err := json.MarshalIndent(response, "", "  ")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "序列化JSON失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 写入文件
-	if err := os.WriteFile(filepath, jsonData, 0644); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "写入文件失败: " + err.Error(),
-		})
-		return
-	}
-
-	log.Printf("聊天记录已保存到文件: %s", filepath)
-
 	c.JSON(http.StatusOK, gin.H{
 		"message":        "聊天记录已成功保存",
-		"filename":       filename,
-		"filepath":       filepath,
+		"filename":       filepath.Base(savedPath),
+		"filepath":       savedPath,
 		"total_comments": response.TotalComments,
 		"video_id":       response.VideoID,
 	})
 }
 
-// downloadChatComments 下载VOD聊天记录（使用GraphQL API）
-func (m *TwitchMonitor) downloadChatComments(videoID string, startTime, endTime *float64) (*models.TwitchChatDownloadResponse, error) {
-	const (
-		gqlURL    = "https://gql.twitch.tv/gql"
-		clientID  = "kd1unb4b3q4t58fwlpcbzcbnm76a8fp"
-		operation = "VideoCommentsByOffsetOrCursor"
-		sha256    = "b70a3591ff0f4e0313d126c6a1502d79a1c02baebb288227c582044aa76adf6a"
-	)
-
-	var allComments []models.TwitchChatComment
-	var cursor string
-	hasNextPage := true
-	isFirstRequest := true
-
-	log.Printf("开始下载 Video ID: %s 的聊天记录", videoID)
-
-	// 获取视频信息
-	videoInfo, err := m.getVideoInfo(videoID)
-	if err != nil {
-		log.Printf("获取视频信息失败: %v", err)
-		// 继续下载聊天，即使获取视频信息失败
-	}
-
-	for hasNextPage {
-		var requestBody map[string]interface{}
-
-		if isFirstRequest {
-			// 第一次请求使用 contentOffsetSeconds
-			offsetSeconds := 0.0
-			if startTime != nil {
-				offsetSeconds = *startTime
-			}
-
-			requestBody = map[string]interface{}{
-				"operationName": operation,
-				"variables": map[string]interface{}{
-					"videoID":              videoID,
-					"contentOffsetSeconds": offsetSeconds,
-				},
-				"extensions": map[string]interface{}{
-					"persistedQuery": map[string]interface{}{
-						"version":    1,
-						"sha256Hash": sha256,
-					},
-				},
-			}
-			isFirstRequest = false
-		} else {
-			// 后续请求使用 cursor 进行分页
-			requestBody = map[string]interface{}{
-				"operationName": operation,
-				"variables": map[string]interface{}{
-					"videoID": videoID,
-					"cursor":  cursor,
-				},
-				"extensions": map[string]interface{}{
-					"persistedQuery": map[string]interface{}{
-						"version":    1,
-						"sha256Hash": sha256,
-					},
-				},
-			}
-		}
-
-		// 序列化请求体
-		jsonData, err := json.Marshal(requestBody)
-		if err != nil {
-			return nil, fmt.Errorf("序列化请求失败: %w", err)
-		}
-
-		// 创建HTTP请求
-		req, err := http.NewRequest("POST", gqlURL, bytes.NewBuffer(jsonData))
-		if err != nil {
-			return nil, fmt.Errorf("创建请求失败: %w", err)
-		}
-
-		req.Header.Set("Client-ID", clientID)
-		req.Header.Set("Content-Type", "application/json")
-
-		// 发送请求
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("请求失败: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("API返回错误状态 %d: %s", resp.StatusCode, string(body))
-		}
-
-		// 解析响应
-		var gqlResp models.TwitchGQLCommentResponse
-		if err := json.NewDecoder(resp.Body).Decode(&gqlResp); err != nil {
-			return nil, fmt.Errorf("解析响应失败: %w", err)
-		}
-
-		// 检查是否有评论数据
-		if len(gqlResp.Data.Video.Comments.Edges) == 0 {
-			log.Printf("没有更多评论数据，当前游标: %s", cursor)
-			break
-		}
+// newChatDownloader 创建聊天下载器，录像信息使用元数据缓存
+func (m *TwitchMonitor) newChatDownloader() *chatdownload.TwitchDownloader {
+	return chatdownload.NewTwitchDownloader(newTracedHTTPClient(30*time.Second), m.getVideoInfo)
+}
 
-		// 收集评论
-		for _, edge := range gqlResp.Data.Video.Comments.Edges {
-			node := edge.Node
+// fetchChatComments 下载VOD聊天记录（使用GraphQL API）并在内存中返回，用于直接返回评论的接口
+func (m *TwitchMonitor) fetchChatComments(videoID string, startTime, endTime *float64) (*models.TwitchChatDownloadResponse, error) {
+	defer waitStageSlot(pipelineStageChatDownload)()
+	return m.newChatDownloader().Download(videoID, startTime, endTime)
+}
 
-			// 如果指定了结束时间，检查是否超出范围
-			if endTime != nil && float64(node.ContentOffsetSeconds) > *endTime {
-				hasNextPage = false
-				break
-			}
+// downloadChatComments 下载VOD聊天记录（使用GraphQL API），评论逐条写入 chat_logs，返回文件路径与下载信息
+func (m *TwitchMonitor) downloadChatComments(videoID string, startTime, endTime *float64) (string, *models.TwitchChatDownloadResponse, error) {
+	defer waitStageSlot(pipelineStageChatDownload)()
+	return downloadTwitchChatLog(m.newChatDownloader(), videoID, startTime, endTime)
+}
 
-			// 如果指定了开始时间，只收集开始时间之后的评论
-			if startTime != nil && float64(node.ContentOffsetSeconds) < *startTime {
-				continue
+// downloadChatCommentsWithProgress 下载整场录像的聊天记录到文件，并按已下载的时间偏移上报任务进度
+func (m *TwitchMonitor) downloadChatCommentsWithProgress(video models.TwitchVideoData) (string, *models.TwitchChatDownloadResponse, error) {
+	release, _ := acquireStageSlot(withJobProgress(context.Background(), video.ID), pipelineStageChatDownload)
+	defer release()
+
+	duration, _ := time.ParseDuration(video.Duration)
+	downloader := m.newChatDownloader().
+		WithProgress(func(comments int, offsetSeconds float64) {
+			percent := 0.0
+			if duration > 0 {
+				percent = math.Min(offsetSeconds/duration.Seconds()*100, 100)
 			}
-
-			// 转换为 TwitchChatComment 格式
-			comment := convertGQLNodeToComment(node, videoID)
-			allComments = append(allComments, comment)
-			cursor = edge.Cursor
-		}
-
-		log.Printf("已获取 %d 条评论，总计: %d", len(gqlResp.Data.Video.Comments.Edges), len(allComments))
-
-		// 检查是否有下一页
-		hasNextPage = hasNextPage && gqlResp.Data.Video.Comments.PageInfo.HasNextPage
-
-		// 避免请求过快
-		time.Sleep(100 * time.Millisecond)
-	}
-
-	log.Printf("下载完成，共获取 %d 条评论", len(allComments))
-
-	return &models.TwitchChatDownloadResponse{
-		VideoID:       videoID,
-		TotalComments: len(allComments),
-		Comments:      allComments,
-		VideoInfo:     videoInfo,
-		DownloadedAt:  time.Now().Format(time.RFC3339),
-	}, nil
+			reportJobProgress(video.ID, JobStageChatDownload, percent, fmt.Sprintf("已下载 %d 条评论", comments))
+		})
+	return downloadTwitchChatLog(downloader, video.ID, nil, nil)
 }
 
-// getVideoInfo 获取视频信息
+// getVideoInfo 获取视频信息，优先使用元数据缓存
 func (m *TwitchMonitor) getVideoInfo(videoID string) (*models.TwitchVideoData, error) {
-	if err := m.ensureValidToken(); err != nil {
-		return nil, err
-	}
-
-	m.mu.RLock()
-	token := m.accessToken
-	m.mu.RUnlock()
-
-	url := fmt.Sprintf("https://api.twitch.tv/helix/videos?id=%s", videoID)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Client-ID", m.config.ClientID)
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	video, err := cachedVideoMetadata("twitch", videoInfoCacheKey(videoID), videoInfoCacheTTL, func() (models.TwitchVideoData, error) {
+		video, err := m.fetchVideoInfo(videoID)
+		if err != nil {
+			return models.TwitchVideoData{}, err
+		}
+		return *video, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("获取视频信息失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
-	}
+	return &video, nil
+}
 
+// fetchVideoInfo 从 Helix 接口获取单个录像的信息
+func (m *TwitchMonitor) fetchVideoInfo(videoID string) (*models.TwitchVideoData, error) {
 	var videoResp models.TwitchVideoResponse
-	if err := json.NewDecoder(resp.Body).Decode(&videoResp); err != nil {
-		return nil, err
+	if err := m.api.Get(context.Background(), "/videos", url.Values{"id": {videoID}}, &videoResp); err != nil {
+		return nil, fmt.Errorf("获取视频信息失败: %w", err)
 	}
 
 	if len(videoResp.Data) == 0 {
@@ -1013,121 +869,31 @@ func (m *TwitchMonitor) getVideoInfo(videoID string) (*models.TwitchVideoData, e
 	return &videoResp.Data[0], nil
 }
 
-// convertGQLNodeToComment 将 GraphQL 节点转换为 TwitchChatComment 格式
-func convertGQLNodeToComment(node struct {
-	ID                   string    `json:"id"`
-	CreatedAt            time.Time `json:"createdAt"`
-	ContentOffsetSeconds int       `json:"contentOffsetSeconds"`
-	Commenter            *struct {
-		ID          string `json:"id"`
-		Login       string `json:"login"`
-		DisplayName string `json:"displayName"`
-	} `json:"commenter"`
-	Message struct {
-		Fragments []struct {
-			Text  string `json:"text"`
-			Emote *struct {
-				EmoteID string `json:"emoteID"`
-			} `json:"emote"`
-		} `json:"fragments"`
-		UserBadges []struct {
-			ID      string `json:"id"`
-			SetID   string `json:"setID"`
-			Version string `json:"version"`
-		} `json:"userBadges"`
-		UserColor string `json:"userColor"`
-	} `json:"message"`
-}, videoID string) models.TwitchChatComment {
-
-	comment := models.TwitchChatComment{
-		ID:                   node.ID,
-		CreatedAt:            node.CreatedAt.Format(time.RFC3339),
-		ContentOffsetSeconds: float64(node.ContentOffsetSeconds),
-		ContentType:          "video",
-		ContentID:            videoID,
-	}
-
-	// 转换 Commenter
-	if node.Commenter != nil {
-		comment.Commenter = models.TwitchChatCommenter{
-			ID:          node.Commenter.ID,
-			DisplayName: node.Commenter.DisplayName,
-			Name:        node.Commenter.Login,
-		}
-	}
-
-	// 转换 Message
-	var messageBody strings.Builder
-	var fragments []models.TwitchChatMessageFragment
-	var emoticons []models.TwitchChatEmoticon
-
-	for i, frag := range node.Message.Fragments {
-		messageBody.WriteString(frag.Text)
-
-		fragment := models.TwitchChatMessageFragment{
-			Text: frag.Text,
-		}
-
-		if frag.Emote != nil {
-			emoticon := models.TwitchChatEmoticon{
-				EmoticonID: frag.Emote.EmoteID,
-				Begin:      i,
-				End:        i + len(frag.Text),
-			}
-			fragment.Emoticon = &emoticon
-			emoticons = append(emoticons, emoticon)
-		}
-
-		fragments = append(fragments, fragment)
-	}
-
-	// 转换 UserBadges
-	var badges []models.TwitchChatBadge
-	for _, badge := range node.Message.UserBadges {
-		badges = append(badges, models.TwitchChatBadge{
-			ID:      badge.SetID,
-			Version: badge.Version,
-		})
-	}
-
-	comment.Message = models.TwitchChatMessage{
-		Body:       messageBody.String(),
-		Fragments:  fragments,
-		UserColor:  node.Message.UserColor,
-		UserBadges: badges,
-		Emoticons:  emoticons,
-	}
-
-	return comment
-}
-
 // GetVideoCommentsForStreamer 下载并分析指定主播的视频评论，返回新完成的分析结果
-func (m *TwitchMonitor) GetVideoCommentsForStreamer(twitchUsername string) []AnalysisResult {
+// 获取录像列表失败，或有录像的聊天记录下载、分析失败时返回错误（其他录像仍会继续处理）
+func (m *TwitchMonitor) GetVideoCommentsForStreamer(twitchUsername string) ([]AnalysisResult, error) {
 	log.Printf("开始检查并下载 %s 的未下载聊天记录...", twitchUsername)
 
 	// 获取最近的录像列表
 	videosResp, err := m.getVideos(twitchUsername, "archive", fetchVodCount, "")
 	if err != nil {
 		log.Printf("获取 %s 的录像列表失败: %v", twitchUsername, err)
-		return nil
+		recordStreamerIssue(twitchUsername, diagStageVODList, "", err)
+		return nil, fmt.Errorf("获取录像列表失败: %w", err)
 	}
+	clearStreamerIssue(twitchUsername, diagStageVODList)
 
 	if len(videosResp.Videos) == 0 {
 		log.Printf("%s 没有找到录像", twitchUsername)
-		return nil
+		return nil, nil
 	}
 
 	log.Printf("找到 %s 的 %d 个录像，开始检查...", twitchUsername, len(videosResp.Videos))
 
-	// 确保聊天日志目录存在
-	if err := os.MkdirAll("./chat_logs", 0755); err != nil {
-		log.Printf("创建聊天日志目录失败: %v", err)
-		return nil
-	}
-
 	downloadedCount := 0
 	skippedCount := 0
 	var newAnalysisResults []AnalysisResult
+	var errs []error
 
 	for _, video := range videosResp.Videos {
 		// 检查是否已经下载过
@@ -1139,25 +905,43 @@ func (m *TwitchMonitor) GetVideoCommentsForStreamer(twitchUsername string) []Ana
 
 		log.Printf("开始下载录像 %s 的聊天记录: %s", video.ID, video.Title)
 
+		// 每个录像一条流水线追踪，各阶段通过视频ID关联
+		ctx, span := startPipelineSpan(context.Background(), "twitch_vod", video.ID,
+			attribute.String("streamer", twitchUsername))
+
 		// 下载聊天记录
-		response, err := m.downloadChatComments(video.ID, nil, nil)
-		if err != nil {
-			log.Printf("下载录像 %s 的聊天记录失败: %v", video.ID, err)
-			continue
+		_, downloadSpan := startPipelineSpan(ctx, "chat_download", video.ID)
+		reportJobProgress(video.ID, JobStageChatDownload, 0, "")
+		filePath, response, err := m.downloadChatCommentsWithProgress(video)
+		if err == nil && response != nil {
+			downloadSpan.SetAttributes(attribute.Int("chat.comments", response.TotalComments))
 		}
-
-		// 保存到文件
-		filename := fmt.Sprintf("chat_%s_%s.json", video.ID, time.Now().Format("20060102_150405"))
-		filePath := filepath.Join("./chat_logs", filename)
-
-		jsonData, err := json.MarshalIndent(response, "", "  ")
+		endSpan(downloadSpan, err)
 		if err != nil {
-			log.Printf("序列化JSON失败: %v", err)
+			log.Printf("下载录像 %s 的聊天记录失败: %v", video.ID, err)
+			recordStreamerIssue(twitchUsername, diagStageChatDownload, video.ID, err)
+			endSpan(span, err)
+			finishJobProgress(video.ID, err)
+			errs = append(errs, fmt.Errorf("下载录像 %s 的聊天记录失败: %w", video.ID, err))
 			continue
 		}
-
-		if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
-			log.Printf("写入文件失败: %v", err)
+		clearStreamerIssue(twitchUsername, diagStageChatDownload)
+
+		// 按规则确定该录像的处理流程
+		plan := planPipelineForVideo(video.ID, PipelineFacts{
+			Streamer: twitchUsername,
+			Platform: "twitch",
+			Language: video.Language,
+			Title:    video.Title,
+			Duration: parseVideoDuration(video.Duration),
+			Comments: response.TotalComments,
+		})
+		if !plan.Runs(PipelineStepAnalysis) {
+			log.Printf("按流水线规则跳过录像 %s 的分析", video.ID)
+			pipelineSLO.discardStreamEnded("twitch", twitchUsername)
+			endSpan(span, nil)
+			finishJobProgress(video.ID, nil)
+			downloadedCount++
 			continue
 		}
 
@@ -1168,15 +952,52 @@ func (m *TwitchMonitor) GetVideoCommentsForStreamer(twitchUsername string) []Ana
 
 		// 使用默认参数进行分析
 		params := defaultPeakParams
-		analysisResult := FindHotCommentsWithParamsTwitch(response.Comments, 5, params)
+		_, analysisSpan := startPipelineSpan(ctx, "analysis", video.ID)
+		reportJobProgress(video.ID, JobStageAnalysis, 0, fmt.Sprintf("%d 条评论", response.TotalComments))
+		// 评论已写入文件，分析时逐条读取时间偏移，不再整体载入
+		offsets, _, err := loadTwitchChatOffsets(filePath)
+		if err != nil {
+			log.Printf("读取聊天记录失败: %v", err)
+			recordStreamerIssue(twitchUsername, diagStageAnalysis, video.ID, err)
+			endSpan(analysisSpan, err)
+			endSpan(span, err)
+			finishJobProgress(video.ID, err)
+			errs = append(errs, fmt.Errorf("读取录像 %s 的聊天记录失败: %w", video.ID, err))
+			continue
+		}
+		analysisResult := findHotCommentsWithParams(offsets, 5, params)
+		analysisSpan.SetAttributes(attribute.Int("analysis.hot_moments", len(analysisResult.HotMoments)))
+		endSpan(analysisSpan, nil)
 		hotMoments = analysisResult.HotMoments
 		timeSeriesData = analysisResult.TimeSeriesData
 		analysisStats = analysisResult.Stats
 
 		// 保存完整的分析结果到文件（包含params参数）
-		if err := saveAnalysisResultToFile(video.ID, hotMoments, timeSeriesData,
-			video.UserName, analysisStats, &video, params); err != nil {
+		_, saveSpan := startPipelineSpan(ctx, "save_results", video.ID)
+		err = saveAnalysisResultToFile(video.ID, hotMoments, timeSeriesData,
+			video.UserName, analysisStats, analysisResult.SkipRanges, &video, params)
+		endSpan(saveSpan, err)
+		if err != nil {
 			log.Printf("保存分析结果失败: %v", err)
+			recordStreamerIssue(twitchUsername, diagStageAnalysis, video.ID, err)
+			errs = append(errs, fmt.Errorf("保存录像 %s 的分析结果失败: %w", video.ID, err))
+		} else {
+			clearStreamerIssue(twitchUsername, diagStageAnalysis)
+			pipelineSLO.markAnalysisReady("twitch", twitchUsername, video.ID, time.Now())
+			go runShadowAnalysis(video.ID, video.UserName, hotMoments, params)
+			// 弹幕反应总结只依赖聊天记录，不必等待片段与语音识别
+			if shouldSummarizeChatReactions(plan) {
+				go summarizeHotMomentChatReactions(ctx, video.ID, hotMoments, plan, normalizePeakParams(params).WindowsLen)
+			}
+			// 片段在本轮所有录像分析完成后才下载，先记录任务以免期间重启丢失
+			if plan.Runs(PipelineStepClips) {
+				enqueuePersistedJob(PersistedJob{
+					Stage:      persistedStageHotClips,
+					VideoID:    video.ID,
+					HotMoments: hotMoments,
+					Interval:   plan.ClipInterval,
+				})
+			}
 		}
 
 		// 保存录像信息到 RPC（如果有视频信息）
@@ -1200,6 +1021,7 @@ func (m *TwitchMonitor) GetVideoCommentsForStreamer(twitchUsername string) []Ana
 			AnalyzedAt:     time.Now(),
 		}
 		newAnalysisResults = append(newAnalysisResults, newResult)
+		endSpan(span, nil)
 
 		log.Printf("✅ 成功保存 %s 的录像 %s 聊天记录 (%d 条评论) 到: %s",
 			twitchUsername, video.ID, response.TotalComments, filePath)
@@ -1214,10 +1036,16 @@ func (m *TwitchMonitor) GetVideoCommentsForStreamer(twitchUsername string) []Ana
 
 	// 下载热点片段
 	for _, v := range newAnalysisResults {
-		m.downloadHotMomentClips(v.VideoID, v.HotMoments, 420)
+		plan := loadPipelinePlan(v.VideoID)
+		if !plan.Runs(PipelineStepClips) {
+			log.Printf("按流水线规则跳过录像 %s 的热点片段", v.VideoID)
+			finishJobProgress(v.VideoID, nil)
+			continue
+		}
+		m.downloadHotMomentClips(v.VideoID, v.HotMoments, plan.ClipInterval)
 	}
 
-	return newAnalysisResults
+	return newAnalysisResults, errors.Join(errs...)
 }
 
 // autoDownloadRecentChats 自动下载最近录像的聊天记录，返回新完成分析的结果（保留用于向后兼容）
@@ -1230,10 +1058,7 @@ func (m *TwitchMonitor) autoDownloadRecentChats() []AnalysisResult {
 	if len(m.streamers) > 0 {
 		for _, platform := range m.streamers[0].Platforms {
 			if platform.Platform == "twitch" {
-				parts := strings.Split(platform.URL, "/")
-				if len(parts) > 0 {
-					twitchUsername = parts[len(parts)-1]
-				}
+				twitchUsername = platformURLHandle(platform.URL)
 				break
 			}
 		}
@@ -1245,46 +1070,97 @@ func (m *TwitchMonitor) autoDownloadRecentChats() []AnalysisResult {
 		return nil
 	}
 
-	return m.GetVideoCommentsForStreamer(twitchUsername)
+	// 失败的录像已在处理时记录日志与诊断信息
+	results, _ := m.GetVideoCommentsForStreamer(twitchUsername)
+	return results
 }
 
 // isChatAlreadyDownloaded 检查聊天记录是否已经下载过
 func (m *TwitchMonitor) isChatAlreadyDownloaded(videoID string) bool {
 	// 检查 chat_logs 目录下是否存在该视频ID的文件
-	pattern := filepath.Join("./chat_logs", fmt.Sprintf("chat_%s_*.json", videoID))
-	matches, err := filepath.Glob(pattern)
-	if err != nil {
-		log.Printf("检查文件失败: %v", err)
-		return false
-	}
-	return len(matches) > 0
+	return chatdownload.FindTwitchLog(videoID) != ""
 }
 
 // downloadHotMomentClips 根据热点时刻下载 VOD 片段
 func (m *TwitchMonitor) downloadHotMomentClips(videoID string, hotMoments []VodCommentData, interval float64) {
+	scheduleHotMomentClips(videoID, hotMoments, interval)
+}
+
+// scheduleHotMomentClips 执行热点片段任务，处于静默时段时只加入延后队列，时段结束后再执行
+func scheduleHotMomentClips(videoID string, hotMoments []VodCommentData, interval float64) {
+	if IsQuietHours(time.Now()) {
+		job, err := enqueueDeferredJob(DeferredJob{
+			Kind:       deferredJobHotClips,
+			VideoID:    videoID,
+			HotMoments: hotMoments,
+			Interval:   interval,
+		})
+		if err != nil {
+			log.Printf("加入延后队列失败，立即执行: %v", err)
+		} else {
+			log.Printf("当前为静默时段，视频 %s 的热点片段任务已延后 (任务ID: %s)", videoID, job.ID)
+			reportJobProgress(videoID, JobStageQueued, 0, "静默时段，已延后执行")
+			// 延后队列本身已持久化
+			removePersistedJob(PersistedJob{Stage: persistedStageHotClips, VideoID: videoID})
+			return
+		}
+	}
+
+	runHotClipsJob(videoID, hotMoments, interval)
+}
+
+// runHotMomentClips 下载热点片段并执行语音识别与AI总结
+// 所有片段都下载失败时返回错误，对应的后台任务标记为失败并可以重试
+func runHotMomentClips(videoID string, hotMoments []VodCommentData, interval float64) error {
+	if !IsFeatureEnabled(FeatureClipDownload) {
+		log.Printf("片段下载已通过功能开关禁用，跳过视频 %s 的热点片段", videoID)
+		finishJobProgress(videoID, nil)
+		return nil
+	}
+
 	log.Printf("开始下载视频 %s 的热点片段，共 %d 个热点", videoID, len(hotMoments))
 
+	plan := loadPipelinePlan(videoID)
+	pipelineCtx, span := startPipelineSpan(withSummaryLanguage(withJobProgress(context.Background(), videoID), plan.SummaryLanguage),
+		"hot_clips", videoID, attribute.Int("clips.count", len(hotMoments)))
+	defer span.End()
+
+	// ffmpeg 不可用时跳过片段、语音识别与AI总结，聊天分析结果不受影响，ffmpeg 恢复后自动补做
+	if !capabilityAvailable(capabilityFFmpeg) {
+		reason := dependencyUnavailableError(capabilityFFmpeg)
+		for _, hotMoment := range hotMoments {
+			recordClipRun(videoID, ClipRunRecord{
+				HotMomentOffset: hotMoment.OffsetSeconds,
+				Interval:        interval,
+				ClipStatus:      clipRunDependencyUnavailable,
+				Error:           reason,
+			})
+		}
+		deferUntilAvailable(videoID, hotMoments, interval, capabilityFFmpeg)
+		finishJobProgress(videoID, nil)
+		return nil
+	}
+	// 语音识别不可用时仍下载片段，跳过语音识别与AI总结，恢复后重新处理这些热点
+	wantASR := IsFeatureEnabled(FeatureASR) && plan.Runs(PipelineStepASR)
+	asrAvailable := !wantASR || capabilityAvailable(capabilityASR)
+	var waitingForASR, summarized []VodCommentData
+	failed := 0
+
 	// 创建 VOD 下载器
-	downloader := NewVODDownloader("./downloads/hot_clips")
+	downloader := NewVODDownloader(hotClipsDir)
 
 	// 确保输出目录存在
-	outputDir := filepath.Join("./downloads/hot_clips", videoID)
+	outputDir := pathsafe.Join(hotClipsDir, videoID)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		log.Printf("创建输出目录失败: %v", err)
-		return
+		finishJobProgress(videoID, err)
+		return err
 	}
 
 	// 遍历每个热点时刻
 	for i, hotMoment := range hotMoments {
-		// 计算下载的时间范围：向前推 interval 的一半，向后推 interval 的一半
-		halfInterval := interval / 2.0
-		startTime := hotMoment.OffsetSeconds - halfInterval
-		endTime := interval
-
-		// 确保开始时间不小于0
-		if startTime < 0 {
-			startTime = 0
-		}
+		// 计算下载的时间范围：向前推 interval 的一半，向后推 interval 的一半，再加上主播设置的前后留白
+		startTime, endTime := clipWindow(videoID, hotMoment.OffsetSeconds, interval, plan)
 
 		log.Printf("下载热点 #%d: 偏移 %.2f 秒, 时间范围 %.2f - %.2f 秒",
 			i+1, hotMoment.OffsetSeconds, startTime, endTime)
@@ -1294,73 +1170,94 @@ func (m *TwitchMonitor) downloadHotMomentClips(videoID string, hotMoments []VodC
 			VODID:      videoID,
 			StartTime:  startTime,
 			EndTime:    endTime,
-			Quality:    "720p", // 使用 720p 质量以节省空间和时间
+			Quality:    plan.Quality, // 默认 720p 以节省空间和时间，可由主播片段设置、订阅者偏好或流水线规则修改
 			OutputPath: outputDir,
+			SkipASR:    !plan.Runs(PipelineStepASR) || !asrAvailable,
+			Platform:   plan.Facts.Platform, // YouTube 录像通过 yt-dlp 下载
+			Streamer:   plan.Facts.Streamer,
 		}
 
 		// 执行下载
-		ctx := context.Background()
+		reportJobProgress(videoID, JobStageClipDownload, float64(i)/float64(len(hotMoments))*100,
+			fmt.Sprintf("热点 %d/%d", i+1, len(hotMoments)))
+		ctx, clipSpan := startPipelineSpan(pipelineCtx, "clip_download", videoID,
+			attribute.Float64("clip.offset_seconds", hotMoment.OffsetSeconds))
+		// 处理结果持久化之前，目录中的片段、音频与字幕不会被清理
+		beginClipArtifacts(outputDir)
 		resp, err := downloader.DownloadVOD(ctx, req)
+		endSpan(clipSpan, err)
+		run := ClipRunRecord{HotMomentOffset: hotMoment.OffsetSeconds, StartTime: startTime, Interval: interval}
 		if err != nil {
 			log.Printf("下载热点 #%d 失败: %v", i+1, err)
+			run.ClipStatus, run.Error = clipRunFailed, err.Error()
+			recordClipRun(videoID, run)
+			finishClipArtifacts(outputDir, false)
+			failed++
 			continue
 		}
 
 		if resp.Success {
 			log.Printf("成功下载热点 #%d 到: %s (用时 %.2f 秒)",
 				i+1, resp.VideoPath, resp.DownloadTime)
+			run.ClipStatus = clipRunOK
+			clipSync := recordClipSync(videoID, hotMoment.OffsetSeconds, startTime, endTime, resp.VideoPath)
+			if plan.Runs(PipelineStepPreviews) && !plan.AudioOnly() {
+				clipSync = generateClipPreviews(pipelineCtx, videoID, clipSync, resp.VideoPath)
+			}
 
-			// 下载完成后执行AI总结
-			if resp.SubtitlePath != "" {
-				log.Printf("开始对热点 #%d 的字幕进行AI总结...", i+1)
-
-				// 从配置读取AI服务提供商
-				aiConfig := GetAIConfig()
-				aiService := NewAIService(aiConfig.Provider, "")
-				if aiService == nil {
-					log.Println("AI 服务未初始化，跳过AI总结")
-				} else {
-					// 执行字幕总结
-					ctx := context.Background()
-					file, err := os.Open(resp.SubtitlePath)
-					if err != nil {
-						log.Printf("打开字幕文件失败: %v", err)
-						continue
-					}
-					defer file.Close()
+			// 识别失败时重新检测语音识别服务，服务已不可用时后续热点也跳过语音识别
+			if wantASR && asrAvailable && resp.SubtitlePath == "" {
+				asrAvailable = checkCapability(capabilityASR).Available
+			}
 
-					srtContext, err := io.ReadAll(file)
-					if err != nil {
-						log.Printf("读取字幕文件失败: %v", err)
-						continue
-					}
+			switch {
+			case !wantASR:
+				run.ASRStatus = clipRunSkipped
+			case !asrAvailable:
+				run.ASRStatus, run.SummaryStatus = clipRunDependencyUnavailable, clipRunDependencyUnavailable
+				run.Error = dependencyUnavailableError(capabilityASR)
+				waitingForASR = append(waitingForASR, hotMoment)
+			case resp.SubtitlePath == "":
+				run.ASRStatus, run.Error = clipRunFailed, resp.Message
+			default:
+				run.ASRStatus = clipRunOK
+			}
 
-					summary, _, err := aiService.SummarizeSRT(ctx, string(srtContext), 10000)
+			var srtContent []byte
+			if resp.SubtitlePath != "" {
+				if srtContent, err = os.ReadFile(resp.SubtitlePath); err != nil {
+					log.Printf("读取字幕文件失败: %v", err)
+				} else if plan.Runs(PipelineStepDriftCalibration) {
+					calibrateVODDrift(videoID, clipSync, string(srtContent))
+				}
+			}
 
-					if err != nil {
-						log.Printf("AI总结失败: %v", err)
+			// 下载完成后执行AI总结
+			if len(srtContent) > 0 && IsFeatureEnabled(FeatureAISummary) && plan.Runs(PipelineStepAISummary) {
+				log.Printf("开始对热点 #%d 的字幕进行AI总结...", i+1)
+				if err := summarizeHotMomentTranscript(pipelineCtx, videoID, hotMoment.OffsetSeconds, string(srtContent)); err != nil {
+					if errors.Is(err, errAIBackpressure) && deferSummaryRetry(videoID, hotMoment.OffsetSeconds, 0) {
+						run.SummaryStatus, run.Error = clipRunDeferred, err.Error()
 					} else {
-						// 保存总结到analysis_results文件夹，避免被清理
-						analysisDir := filepath.Join("./analysis_results", videoID)
-						if err := os.MkdirAll(analysisDir, 0755); err != nil {
-							log.Printf("创建分析目录失败: %v", err)
-						} else {
-							// 使用原始字幕文件名，但保存到analysis_results目录
-							summaryPath := filepath.Join(analysisDir, fmt.Sprintf("%f", hotMoment.OffsetSeconds))
-							if err := aiService.SaveSummaryToFile(summaryPath, summary); err != nil {
-								log.Printf("保存总结失败: %v", err)
-							} else {
-								log.Printf("热点 #%d AI总结完成并已保存到: %s", i+1, summaryPath)
-							}
-						}
+						log.Printf("热点 #%d AI总结失败: %v", i+1, err)
+						run.SummaryStatus, run.Error = clipRunFailed, err.Error()
 					}
+				} else {
+					run.SummaryStatus = clipRunOK
+					summarized = append(summarized, hotMoment)
 				}
+			} else if run.ASRStatus == clipRunOK {
+				run.SummaryStatus = clipRunSkipped
 			}
 		} else {
 			log.Printf("下载热点 #%d 失败: %s", i+1, resp.Message)
+			run.ClipStatus, run.Error = clipRunFailed, resp.Message
+			failed++
 		}
+		persisted := recordClipRun(videoID, run) == nil
+		finishClipArtifacts(outputDir, persisted, resp.VideoPath, resp.AudioPath, resp.SubtitlePath)
 
-		// 清理downloads文件夹中的临时文件
+		// 清理downloads文件夹中处理结果已持久化的临时文件
 		if err := cleanTempFiles(outputDir); err != nil {
 			log.Printf("清理临时文件失败: %v", err)
 		}
@@ -1369,11 +1266,69 @@ func (m *TwitchMonitor) downloadHotMomentClips(videoID string, hotMoments []VodC
 		time.Sleep(10 * time.Second)
 	}
 
+	deferUntilAvailable(videoID, waitingForASR, interval, capabilityASR)
+	if len(summarized) > 0 {
+		go postSummaryWebhooks(videoID, plan, summarized)
+	}
+
+	if failed > 0 && failed == len(hotMoments) {
+		err := fmt.Errorf("全部 %d 个热点片段下载失败", failed)
+		log.Printf("视频 %s %v", videoID, err)
+		finishJobProgress(videoID, err)
+		return err
+	}
 	log.Printf("视频 %s 的所有热点片段下载完成", videoID)
+	finishJobProgress(videoID, nil)
+	return nil
+}
+
+// summarizeHotMomentTranscript 对热点片段的字幕执行AI总结，并保存到 analysis_results 目录
+func summarizeHotMomentTranscript(ctx context.Context, videoID string, offsetSeconds float64, srtContent string) error {
+	// 从配置读取AI服务提供商，主提供商变慢或出错时由备用提供商接替
+	aiConfig := GetAIConfig()
+	aiService := NewConfiguredAIService()
+
+	ctx = withSummaryPromptVars(ctx, summaryPromptVarsFor(loadPipelinePlan(videoID), offsetSeconds))
+	summaryCtx, summarySpan := startPipelineSpan(ctx, "ai_summary", videoID,
+		attribute.String("ai.provider", aiConfig.Provider))
+	summary, _, err := aiService.SummarizeSRT(summaryCtx, srtContent, 0)
+	endSpan(summarySpan, err)
+	if err != nil {
+		return err
+	}
+	return saveHotMomentSummary(ctx, aiService, aiConfig.Provider, videoID, offsetSeconds, srtContent, summary)
+}
+
+// saveHotMomentSummary 保存热点的AI总结，记录自评分数并生成片段元数据
+func saveHotMomentSummary(ctx context.Context, aiService AIService, provider, videoID string, offsetSeconds float64, srtContent, summary string) error {
+	// 保存总结到analysis_results文件夹，避免被清理
+	analysisDir := pathsafe.Join("./analysis_results", videoID)
+	if err := os.MkdirAll(analysisDir, 0755); err != nil {
+		return fmt.Errorf("创建分析目录失败: %v", err)
+	}
+	// 使用原始字幕文件名，但保存到analysis_results目录
+	summaryPath := filepath.Join(analysisDir, pathsafe.Seconds(offsetSeconds))
+	if err := aiService.SaveSummaryToFile(summaryPath, summary); err != nil {
+		return fmt.Errorf("保存总结失败: %v", err)
+	}
+	indexSummary(videoID, offsetSeconds, summaryPath, summary)
+	log.Printf("热点 %.0f 秒的AI总结已保存到: %s", offsetSeconds, summaryPath)
+	pipelineSLO.markSummaryReady(videoID, time.Now())
+	recordSummaryEvaluation(ctx, aiService, provider, videoID, offsetSeconds, srtContent, summary)
+	recordClipMetadata(ctx, aiService, provider, videoID, offsetSeconds, summary)
+	return nil
 }
 
 // cleanTempFiles 清理指定目录下的临时文件
+// 只删除登记为所属阶段已完成且结果已持久化的文件；目录中有进行中的阶段时跳过，由最后结束的阶段清理，
+// 未登记的文件（其他任务正在写入的文件，或服务重启前遗留的文件）不会被删除
 func cleanTempFiles(dir string) error {
+	cleanable, release, ok := takeCleanableClipArtifacts(dir)
+	if !ok {
+		log.Printf("目录 %s 中有进行中的片段任务，暂不清理临时文件", dir)
+		return nil
+	}
+	defer release()
 	log.Printf("开始清理目录中的临时文件: %s", dir)
 
 	// 临时文件的扩展名模式
@@ -1388,8 +1343,8 @@ func cleanTempFiles(dir string) error {
 			return err
 		}
 
-		// 跳过目录
-		if info.IsDir() {
+		// 跳过目录与未登记为可清理的文件
+		if info.IsDir() || !cleanable[filepath.Clean(path)] {
 			return nil
 		}
 
@@ -1450,18 +1405,22 @@ type AnalysisResult struct {
 	Method         string                 `json:"method"`
 	HotMoments     []VodCommentData       `json:"hot_moments"`
 	TimeSeriesData []TimeSeriesDataPoint  `json:"time_series_data"`
+	TimeSeriesFile string                 `json:"time_series_file,omitempty"` // 时间序列单独存储时的附属文件名
 	Stats          VodCommentStats        `json:"stats"`
+	SkipRanges     []SkipRange            `json:"skip_ranges,omitempty"`
+	Chapters       []Chapter              `json:"chapters,omitempty"` // 根据弹幕划分的章节
+	Range          *AnalysisRange         `json:"range,omitempty"`    // 区间分析时的录像区间
 	VideoInfo      models.TwitchVideoData `json:"video_info"`
 	AnalyzedAt     time.Time              `json:"analyzed_at"`
 }
 
 // saveAnalysisResultToFile 保存分析结果到文件
 func saveAnalysisResultToFile(videoID string, hotMoments []VodCommentData,
-	timeSeriesData []TimeSeriesDataPoint, name string, stats VodCommentStats,
+	timeSeriesData []TimeSeriesDataPoint, name string, stats VodCommentStats, skipRanges []SkipRange,
 	videoInfo *models.TwitchVideoData, params PeakDetectionParams) error {
 
 	// 按videoID创建目录
-	videoDir := filepath.Join("./analysis_results", videoID)
+	videoDir := pathsafe.Join("./analysis_results", videoID)
 	if err := os.MkdirAll(videoDir, 0755); err != nil {
 		return fmt.Errorf("创建目录失败: %w", err)
 	}
@@ -1473,13 +1432,36 @@ func saveAnalysisResultToFile(videoID string, hotMoments []VodCommentData,
 		HotMoments:     hotMoments,
 		TimeSeriesData: timeSeriesData,
 		Stats:          stats,
+		SkipRanges:     skipRanges,
 		VideoInfo:      *videoInfo,
 		AnalyzedAt:     time.Now(),
+		Method:         peakMethodName(params),
+	}
+
+	// 弹幕情绪按秒统计后缓存，热点与时间序列按本次的窗口长度汇总
+	annotateEmotions(videoID, result.HotMoments, result.TimeSeriesData, normalizePeakParams(params).WindowsLen)
+	annotateTopEmotes(videoID, result.HotMoments, normalizePeakParams(params).WindowsLen)
+	annotateChatters(videoID, result.HotMoments, result.TimeSeriesData, &result.Stats, normalizePeakParams(params).WindowsLen)
+
+	// 章节与峰值检测参数无关，同一录像的多个分析结果共用缓存
+	if chapters, err := chaptersForVideo(videoID); err == nil {
+		result.Chapters = chapters
+	} else if !os.IsNotExist(err) {
+		log.Printf("划分录像 %s 的章节失败: %v", videoID, err)
 	}
 
-	// 使用参数生成文件名：analysis_{windowsLen}_{thr}_{searchRange}.json
-	filename := filepath.Join(videoDir, fmt.Sprintf("analysis_%d_%.2f_%d.json",
-		params.WindowsLen, params.Thr, params.SearchRange))
+	// 时间序列单独压缩存储，主文件只保留引用
+	if useTimeSeriesSidecar() {
+		sidecar, err := writeTimeSeriesSidecar(videoDir, params, timeSeriesData)
+		if err != nil {
+			return err
+		}
+		result.TimeSeriesData = nil
+		result.TimeSeriesFile = sidecar
+	}
+
+	// 使用参数生成文件名：analysis_{windowsLen}_{thr}_{searchRange}.json（非默认算法带算法名前缀）
+	filename := filepath.Join(videoDir, analysisResultFileName(params))
 
 	// 序列化为JSON
 	data, err := json.MarshalIndent(result, "", "  ")
@@ -1491,11 +1473,47 @@ func saveAnalysisResultToFile(videoID string, hotMoments []VodCommentData,
 	if err := os.WriteFile(filename, data, 0644); err != nil {
 		return fmt.Errorf("写入文件失败: %w", err)
 	}
+	indexAnalysisResult(videoID, filepath.Base(filename), result, data)
 
 	log.Printf("分析结果已保存到: %s", filename)
 	return nil
 }
 
+// analyzeAndSaveTwitchResult 读取 Twitch 聊天记录按指定参数分析并保存结果，没有聊天记录时返回 os.ErrNotExist
+func analyzeAndSaveTwitchResult(videoID string, params PeakDetectionParams) error {
+	// 查找聊天记录文件
+	chatFile := chatdownload.FindTwitchLog(videoID)
+	if chatFile == "" {
+		return os.ErrNotExist
+	}
+
+	// 逐条读取评论时间偏移
+	offsets, header, err := loadTwitchChatOffsets(chatFile)
+	if err != nil {
+		return err
+	}
+
+	// 执行分析
+	analysisResult := findHotCommentsWithParams(offsets, 5, params)
+
+	// 保存分析结果
+	if header.VideoInfo != nil {
+		if err := saveAnalysisResultToFile(
+			videoID,
+			analysisResult.HotMoments,
+			analysisResult.TimeSeriesData,
+			header.VideoInfo.UserName,
+			analysisResult.Stats,
+			analysisResult.SkipRanges,
+			header.VideoInfo,
+			params,
+		); err != nil {
+			log.Printf("保存分析结果失败: %v", err)
+		}
+	}
+	return nil
+}
+
 // GetAnalysisResult 获取分析结果
 func GetAnalysisResult(c *gin.Context) {
 	videoID := c.Param("videoID")
@@ -1510,9 +1528,16 @@ func GetAnalysisResult(c *gin.Context) {
 	windowsLen := c.DefaultQuery("windows_len", "420")
 	thr := c.DefaultQuery("thr", "0.90")
 	searchRange := c.DefaultQuery("search_range", "210")
+	method := c.Query("method")
+	if err := validatePeakMethod(method); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
-	// 查找分析结果文件
-	videoDir := filepath.Join("./analysis_results", videoID)
+	// 查找分析结果（优先从存储索引读取，索引不可用时读取文件）
+	videoDir := pathsafe.Join("./analysis_results", videoID)
 	var targetFile string
 
 	// 如果提供了参数，查找特定的文件
@@ -1522,60 +1547,39 @@ func GetAnalysisResult(c *gin.Context) {
 		params.WindowsLen, _ = strconv.Atoi(windowsLen)
 		params.Thr, _ = strconv.ParseFloat(thr, 64)
 		params.SearchRange, _ = strconv.Atoi(searchRange)
-
-		filename := fmt.Sprintf("analysis_%d_%.2f_%d.json", params.WindowsLen, params.Thr, params.SearchRange)
-		targetFile = filepath.Join(videoDir, filename)
-		if _, err := os.Stat(targetFile); os.IsNotExist(err) {
-			// 如果指定参数的文件不存在，执行分析并保存结果
-			// 查找聊天记录文件
-			chatPattern := filepath.Join("./chat_logs", fmt.Sprintf("chat_%s_*.json", videoID))
-			chatFiles, err := filepath.Glob(chatPattern)
-			if err != nil || len(chatFiles) == 0 {
+		params.Method = method
+		params.ZThreshold, _ = strconv.ParseFloat(c.Query("z_threshold"), 64)
+		params.BaselineLen, _ = strconv.Atoi(c.Query("baseline_len"))
+		params.MinWindowsLen, _ = strconv.Atoi(c.Query("min_windows_len"))
+		params.Scales, _ = strconv.Atoi(c.Query("scales"))
+		params = normalizePeakParams(params)
+
+		targetFile = analysisResultFileName(params)
+		if !analysisResultExists(videoID, targetFile) {
+			// 如果指定参数的文件不存在，执行分析并保存结果；相同参数的并发请求共享同一次分析
+			_, jobID, shared, err := doAnalysisFlight(videoID, peakParamsKey(params), func() (interface{}, error) {
+				return nil, analyzeAndSaveTwitchResult(videoID, params)
+			})
+			c.Header("X-Analysis-Job-ID", jobID)
+			if shared {
+				c.Header("X-Analysis-Coalesced", "true")
+			}
+			if errors.Is(err, os.ErrNotExist) {
 				c.JSON(http.StatusNotFound, gin.H{
 					"error": "未找到该视频的聊天记录，请先下载聊天记录",
 				})
 				return
 			}
-
-			// 读取聊天记录
-			chatData, err := os.ReadFile(chatFiles[0])
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": "读取聊天记录失败: " + err.Error(),
+					"error": err.Error(),
 				})
 				return
 			}
-
-			var chatResponse models.TwitchChatDownloadResponse
-			if err := json.Unmarshal(chatData, &chatResponse); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": "解析聊天记录失败: " + err.Error(),
-				})
-				return
-			}
-
-			// 执行分析
-			analysisResult := FindHotCommentsWithParamsTwitch(chatResponse.Comments, 5, params)
-
-			// 保存分析结果
-			if chatResponse.VideoInfo != nil {
-				if err := saveAnalysisResultToFile(
-					videoID,
-					analysisResult.HotMoments,
-					analysisResult.TimeSeriesData,
-					chatResponse.VideoInfo.UserName,
-					analysisResult.Stats,
-					chatResponse.VideoInfo,
-					params,
-				); err != nil {
-					log.Printf("保存分析结果失败: %v", err)
-				}
-			}
 		}
 	} else {
-		// 查找目录下的所有分析文件
-		pattern := filepath.Join(videoDir, "analysis_*.json")
-		matches, err := filepath.Glob(pattern)
+		// 查找该视频的所有分析结果
+		files, err := listAnalysisResultFiles(videoID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "查询分析结果失败: " + err.Error(),
@@ -1583,18 +1587,18 @@ func GetAnalysisResult(c *gin.Context) {
 			return
 		}
 
-		if len(matches) == 0 {
+		if len(files) == 0 {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error": "未找到该视频的分析结果",
 			})
 			return
 		}
 
-		// 使用第一个文件（如果有多个，用户应该指定参数）
-		targetFile = matches[0]
+		// 使用最新的结果（如果有多个，用户应该指定参数）
+		targetFile = files[0]
 	}
 
-	data, err := os.ReadFile(targetFile)
+	data, err := readAnalysisResultData(videoID, targetFile)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "读取分析结果失败: " + err.Error(),
@@ -1610,14 +1614,26 @@ func GetAnalysisResult(c *gin.Context) {
 		return
 	}
 
+	// 时间序列体积较大，仅在 include_time_series=true 时返回
+	if c.Query("include_time_series") != "true" {
+		result.TimeSeriesData = nil
+	} else if result.TimeSeriesFile != "" {
+		timeSeries, err := loadTimeSeriesSidecar(videoDir, result.TimeSeriesFile)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "读取时间序列失败: " + err.Error(),
+			})
+			return
+		}
+		result.TimeSeriesData = timeSeries
+	}
+
 	// 读取默认参数的hotmoments数据
-	defaultFilename := fmt.Sprintf("analysis_%d_%.2f_%d.json",
-		defaultPeakParams.WindowsLen, defaultPeakParams.Thr, defaultPeakParams.SearchRange)
-	defaultFile := filepath.Join(videoDir, defaultFilename)
+	defaultFilename := analysisResultFileName(defaultPeakParams)
 
-	// 如果默认参数文件存在且不是当前文件，则从默认文件读取HotMoments
-	if defaultFile != targetFile {
-		if defaultData, err := os.ReadFile(defaultFile); err == nil {
+	// 如果默认参数文件存在且不是当前文件，则从默认文件读取HotMoments（仅限默认算法的结果）
+	if defaultFilename != targetFile && (result.Method == "" || result.Method == defaultPeakMethod) {
+		if defaultData, err := readAnalysisResultData(videoID, defaultFilename); err == nil {
 			var defaultResult AnalysisResult
 			if err := json.Unmarshal(defaultData, &defaultResult); err == nil {
 				// 用默认参数的HotMoments替换当前结果的HotMoments
@@ -1630,23 +1646,13 @@ func GetAnalysisResult(c *gin.Context) {
 			log.Printf("默认参数文件不存在或读取失败: %s, 使用当前文件的HotMoments", defaultFilename)
 		}
 	}
+	attachClipPreviews(videoID, result.HotMoments)
 
 	c.JSON(http.StatusOK, result)
 }
 
-// ListAnalysisResults 列出所有分析结果
+// ListAnalysisResults 列出所有分析结果，优先从存储索引查询，索引不可用时扫描 analysis_results 目录
 func ListAnalysisResults(c *gin.Context) {
-	analysisDir := "./analysis_results"
-
-	// 读取所有视频ID目录
-	dirs, err := os.ReadDir(analysisDir)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "查询分析结果失败: " + err.Error(),
-		})
-		return
-	}
-
 	type AnalysisListItem struct {
 		VideoID      string    `json:"video_id"`
 		StreamerName string    `json:"streamer_name"`
@@ -1657,8 +1663,47 @@ func ListAnalysisResults(c *gin.Context) {
 		Params       string    `json:"params"` // 参数信息
 	}
 
+	// 从文件名中提取参数信息
+	paramsOf := func(filename string) string {
+		return strings.TrimSuffix(strings.TrimPrefix(filename, "analysis_"), ".json")
+	}
+
 	var results []AnalysisListItem
 
+	if store := completeStorageIndex(); store != nil {
+		runs, err := store.ListAnalysisRuns("")
+		if err == nil {
+			for _, run := range runs {
+				results = append(results, AnalysisListItem{
+					VideoID:      run.VideoID,
+					StreamerName: run.StreamerName,
+					Title:        run.Title,
+					Method:       run.Method,
+					AnalyzedAt:   run.AnalyzedAt,
+					HotMoments:   run.HotMoments,
+					Params:       paramsOf(run.File),
+				})
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"total":   len(results),
+				"results": results,
+			})
+			return
+		}
+		log.Printf("从存储索引查询分析结果失败，改为扫描目录: %v", err)
+	}
+
+	analysisDir := "./analysis_results"
+
+	// 读取所有视频ID目录
+	dirs, err := os.ReadDir(analysisDir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "查询分析结果失败: " + err.Error(),
+		})
+		return
+	}
+
 	// 遍历每个视频ID目录
 	for _, dir := range dirs {
 		if !dir.IsDir() {
@@ -1686,11 +1731,6 @@ func ListAnalysisResults(c *gin.Context) {
 				continue
 			}
 
-			// 从文件名中提取参数信息
-			filename := filepath.Base(file)
-			params := strings.TrimPrefix(filename, "analysis_")
-			params = strings.TrimSuffix(params, ".json")
-
 			results = append(results, AnalysisListItem{
 				VideoID:      result.VideoID,
 				StreamerName: result.StreamerName,
@@ -1698,7 +1738,7 @@ func ListAnalysisResults(c *gin.Context) {
 				Method:       result.Method,
 				AnalyzedAt:   result.AnalyzedAt,
 				HotMoments:   len(result.HotMoments),
-				Params:       params,
+				Params:       paramsOf(filepath.Base(file)),
 			})
 		}
 	}
@@ -1721,7 +1761,7 @@ func GetVideoCommentsAndAnalysis(tm *TwitchMonitor) []AnalysisResult {
 
 	for _, v := range ars {
 		// 调用下载 VOD 片段的方法
-		tm.downloadHotMomentClips(v.VideoID, v.HotMoments, 420)
+		tm.downloadHotMomentClips(v.VideoID, v.HotMoments, loadPipelinePlan(v.VideoID).ClipInterval)
 	}
 
 	return ars
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"subtuber-services/twitchclient"
+)
+
+// writeTwitchCredentialPrometheus 以 Prometheus 文本格式导出 Twitch 凭据池的配额使用情况
+func writeTwitchCredentialPrometheus(b *strings.Builder) {
+	tm := GetTwitchMonitor()
+	if tm == nil {
+		return
+	}
+	stats := tm.api.Stats()
+	if len(stats) == 0 {
+		return
+	}
+
+	gauges := []struct {
+		name, help string
+		value      func(twitchclient.CredentialStats) float64
+	}{
+		{"subtuber_twitch_ratelimit_limit", "Helix rate limit bucket size reported for the Twitch credential.", func(s twitchclient.CredentialStats) float64 { return float64(s.RateLimit) }},
+		{"subtuber_twitch_ratelimit_remaining", "Helix requests remaining in the current rate limit window for the Twitch credential.", func(s twitchclient.CredentialStats) float64 { return float64(s.Remaining) }},
+		{"subtuber_twitch_credential_cooldown_seconds", "Seconds until a throttled Twitch credential is used again.", func(s twitchclient.CredentialStats) float64 { return s.CooldownSeconds }},
+		{"subtuber_twitch_credential_active", "Whether Helix requests currently use this Twitch credential.", func(s twitchclient.CredentialStats) float64 {
+			if s.Active {
+				return 1
+			}
+			return 0
+		}},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(b, "# HELP %s %s\n", g.name, g.help)
+		fmt.Fprintf(b, "# TYPE %s gauge\n", g.name)
+		for _, s := range stats {
+			fmt.Fprintf(b, "%s{client_id=%q} %g\n", g.name, s.ClientID, g.value(s))
+		}
+	}
+
+	name := "subtuber_twitch_requests_total"
+	fmt.Fprintf(b, "# HELP %s Helix requests by Twitch credential and outcome; throttled requests were answered with 429.\n", name)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+	for _, s := range stats {
+		fmt.Fprintf(b, "%s{client_id=%q,outcome=\"ok\"} %d\n", name, s.ClientID, s.Requests-s.Throttled)
+		fmt.Fprintf(b, "%s{client_id=%q,outcome=\"throttled\"} %d\n", name, s.ClientID, s.Throttled)
+	}
+}
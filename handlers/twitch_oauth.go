@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const twitchLinksFile = "App_Data/twitch_links.json"
+
+// twitchLinkConsentText 关联前需要用户明确同意的说明
+const twitchLinkConsentText = "关联后，系统只读取你的 Twitch 用户ID与用户名，用于认领主播主页时确认身份；" +
+	"不申请任何其他权限，可随时取消关联"
+
+// LinkedTwitchAccount 用户关联的 Twitch 账号（加密存储）
+type LinkedTwitchAccount struct {
+	TwitchUserID string    `json:"twitch_user_id"`
+	Login        string    `json:"login"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Scopes       []string  `json:"scopes"`
+	ConsentAt    time.Time `json:"consent_at"`
+}
+
+var twitchLinksMu sync.Mutex
+
+// twitchOAuthConfig 返回 OAuth 所需的应用配置，Twitch 监控未初始化时不可用
+func twitchOAuthConfig() (TwitchConfig, bool) {
+	tm := GetTwitchMonitor()
+	if tm == nil {
+		return TwitchConfig{}, false
+	}
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	cfg := tm.config
+	return cfg, cfg.ClientID != "" && cfg.ClientSecret != "" && cfg.RedirectURL != ""
+}
+
+// twitchLinkStartHandler 用户同意后跳转到 Twitch 授权页面
+func twitchLinkStartHandler(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "未登录或登录已过期"})
+		return
+	}
+	if rejectImpersonated(c) {
+		return
+	}
+
+	cfg, ok := twitchOAuthConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "message": "Twitch 账号关联未启用"})
+		return
+	}
+
+	if c.Query("consent") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "需要同意授权说明后才能关联 Twitch 账号 (consent=true)",
+			"consent": twitchLinkConsentText,
+		})
+		return
+	}
+
+	stateBytes := make([]byte, 16)
+	if _, err := rand.Read(stateBytes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "生成授权状态失败"})
+		return
+	}
+	state := hex.EncodeToString(stateBytes)
+	codeCache.Set("twitch:oauth:state:"+state, userHash, 10*time.Minute)
+
+	authURL := "https://id.twitch.tv/oauth2/authorize?" + url.Values{
+		"client_id":     {cfg.ClientID},
+		"redirect_uri":  {cfg.RedirectURL},
+		"response_type": {"code"},
+		"scope":         {""}, // 只用于确认 Twitch 身份，不申请任何权限
+		"state":         {state},
+	}.Encode()
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// twitchLinkCallbackHandler Twitch 授权回调，交换令牌并加密保存
+func twitchLinkCallbackHandler(c *gin.Context) {
+	state := c.Query("state")
+	v, found := codeCache.Get("twitch:oauth:state:" + state)
+	if state == "" || !found {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "授权状态无效或已过期，请重新关联"})
+		return
+	}
+	codeCache.Delete("twitch:oauth:state:" + state)
+	userHash := v.(string)
+
+	if errMsg := c.Query("error"); errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "用户拒绝了授权: " + errMsg})
+		return
+	}
+
+	cfg, ok := twitchOAuthConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "message": "Twitch 账号关联未启用"})
+		return
+	}
+
+	account, err := exchangeTwitchToken(cfg, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {c.Query("code")},
+		"redirect_uri": {cfg.RedirectURL},
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "message": "获取 Twitch 授权失败: " + err.Error()})
+		return
+	}
+
+	user, err := fetchTwitchTokenUser(cfg, account.AccessToken)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "message": "获取 Twitch 用户信息失败: " + err.Error()})
+		return
+	}
+	account.TwitchUserID = user.ID
+	account.Login = user.Login
+	account.ConsentAt = time.Now()
+
+	if err := saveLinkedTwitchAccount(userHash, account); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "保存 Twitch 授权失败: " + err.Error()})
+		return
+	}
+
+	log.Printf("用户 %s 已关联 Twitch 账号 %s", userHash, account.Login)
+	_ = appendErrorLog("credentials-audit.log", fmt.Sprintf("%s\tLINK\ttwitch\t%s\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), userHash, account.Login))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Twitch 账号关联成功",
+		"login":   account.Login,
+	})
+}
+
+// twitchLinkStatusHandler 查看当前用户的 Twitch 关联状态（不返回令牌）
+func twitchLinkStatusHandler(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "未登录或登录已过期"})
+		return
+	}
+
+	account := getLinkedTwitchAccount(userHash)
+	if account == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "linked": false, "consent": twitchLinkConsentText})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"linked":     true,
+		"login":      account.Login,
+		"scopes":     account.Scopes,
+		"consent_at": account.ConsentAt,
+	})
+}
+
+// twitchUnlinkHandler 取消关联并撤销令牌
+func twitchUnlinkHandler(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "未登录或登录已过期"})
+		return
+	}
+	if rejectImpersonated(c) {
+		return
+	}
+
+	if account := getLinkedTwitchAccount(userHash); account != nil {
+		if cfg, ok := twitchOAuthConfig(); ok {
+			revokeTwitchToken(cfg, account.AccessToken)
+		}
+	}
+
+	if err := deleteLinkedTwitchAccount(userHash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "取消关联失败: " + err.Error()})
+		return
+	}
+
+	_ = appendErrorLog("credentials-audit.log", fmt.Sprintf("%s\tUNLINK\ttwitch\t%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), userHash))
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "已取消关联 Twitch 账号"})
+}
+
+// exchangeTwitchToken 请求 Twitch 令牌接口（授权码或刷新令牌）
+func exchangeTwitchToken(cfg TwitchConfig, form url.Values) (*LinkedTwitchAccount, error) {
+	form.Set("client_id", cfg.ClientID)
+	form.Set("client_secret", cfg.ClientSecret)
+
+	resp, err := newTracedHTTPClient(10*time.Second).PostForm("https://id.twitch.tv/oauth2/token", form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API返回错误状态 %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		AccessToken  string   `json:"access_token"`
+		RefreshToken string   `json:"refresh_token"`
+		ExpiresIn    int      `json:"expires_in"`
+		Scope        []string `json:"scope"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, err
+	}
+
+	return &LinkedTwitchAccount{
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+		Scopes:       tokenResp.Scope,
+	}, nil
+}
+
+// fetchTwitchTokenUser 获取令牌所属的 Twitch 用户
+func fetchTwitchTokenUser(cfg TwitchConfig, accessToken string) (*struct{ ID, Login string }, error) {
+	var userResp struct {
+		Data []struct {
+			ID    string `json:"id"`
+			Login string `json:"login"`
+		} `json:"data"`
+	}
+	if err := GetTwitchMonitor().api.GetAsUser(context.Background(), cfg.ClientID, accessToken, "/users", nil, &userResp); err != nil {
+		return nil, err
+	}
+	if len(userResp.Data) == 0 {
+		return nil, fmt.Errorf("未找到令牌对应的用户")
+	}
+	return &struct{ ID, Login string }{userResp.Data[0].ID, userResp.Data[0].Login}, nil
+}
+
+// revokeTwitchToken 撤销令牌，失败时仅记录日志
+func revokeTwitchToken(cfg TwitchConfig, accessToken string) {
+	resp, err := newTracedHTTPClient(10*time.Second).PostForm("https://id.twitch.tv/oauth2/revoke", url.Values{
+		"client_id": {cfg.ClientID},
+		"token":     {accessToken},
+	})
+	if err != nil {
+		log.Printf("撤销 Twitch 令牌失败: %v", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// getLinkedTwitchAccount 读取并解密用户关联的 Twitch 账号，未关联时返回 nil
+func getLinkedTwitchAccount(userHash string) *LinkedTwitchAccount {
+	twitchLinksMu.Lock()
+	defer twitchLinksMu.Unlock()
+
+	all, err := loadEncryptedCredentials(twitchLinksFile)
+	if err != nil {
+		return nil
+	}
+	enc, ok := all[userHash]
+	if !ok {
+		return nil
+	}
+
+	plaintext, err := decryptCredential("twitch_user:"+userHash, enc)
+	if err != nil {
+		log.Printf("解密用户 %s 的 Twitch 授权失败: %v", userHash, err)
+		return nil
+	}
+
+	var account LinkedTwitchAccount
+	if err := json.Unmarshal(plaintext, &account); err != nil {
+		return nil
+	}
+	return &account
+}
+
+// saveLinkedTwitchAccount 加密保存用户关联的 Twitch 账号
+func saveLinkedTwitchAccount(userHash string, account *LinkedTwitchAccount) error {
+	plaintext, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	enc, err := encryptCredential("twitch_user:"+userHash, "twitch", plaintext)
+	if err != nil {
+		return err
+	}
+
+	twitchLinksMu.Lock()
+	defer twitchLinksMu.Unlock()
+
+	all, err := loadEncryptedCredentials(twitchLinksFile)
+	if err != nil {
+		return err
+	}
+	all[userHash] = enc
+	return saveEncryptedCredentials(twitchLinksFile, all)
+}
+
+// renameLinkedTwitchAccount 将关联的 Twitch 账号迁移到新的 userHash（密文与 userHash 绑定，需要重新加密）
+func renameLinkedTwitchAccount(oldHash, newHash string) error {
+	account := getLinkedTwitchAccount(oldHash)
+	if account == nil {
+		return nil
+	}
+	if err := saveLinkedTwitchAccount(newHash, account); err != nil {
+		return err
+	}
+	return deleteLinkedTwitchAccount(oldHash)
+}
+
+// deleteLinkedTwitchAccount 删除用户关联的 Twitch 账号
+func deleteLinkedTwitchAccount(userHash string) error {
+	twitchLinksMu.Lock()
+	defer twitchLinksMu.Unlock()
+
+	all, err := loadEncryptedCredentials(twitchLinksFile)
+	if err != nil {
+		return err
+	}
+	delete(all, userHash)
+	return saveEncryptedCredentials(twitchLinksFile, all)
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"subtuber-services/chatdownload"
+	"subtuber-services/pathsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 链接解析得到的资源类型
+const (
+	URLTypeChannel = "channel"
+	URLTypeVideo   = "video"
+	URLTypeClip    = "clip"
+)
+
+// ErrUnsupportedURL 不是可识别的 Twitch/YouTube 链接
+var ErrUnsupportedURL = errors.New("无法识别的 Twitch/YouTube 链接")
+
+var (
+	twitchLoginPattern   = regexp.MustCompile(`^[A-Za-z0-9_]{2,25}$`)
+	twitchVideoIDPattern = regexp.MustCompile(`^v?(\d+)$`)
+	twitchClipPattern    = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	youtubeVideoPattern  = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	youtubeClipPattern   = regexp.MustCompile(`^Ug[A-Za-z0-9_-]+$`)
+
+	// twitchReservedPaths twitch.tv 下不是频道名的一级路径
+	twitchReservedPaths = map[string]bool{
+		"directory": true, "downloads": true, "drops": true, "inventory": true, "jobs": true,
+		"messages": true, "moderator": true, "p": true, "popout": true, "search": true,
+		"settings": true, "subscriptions": true, "turbo": true, "wallet": true, "embed": true,
+	}
+)
+
+// ParsedURL 规范化后的平台链接信息
+type ParsedURL struct {
+	Platform      string  `json:"platform"`                 // twitch 或 youtube
+	Type          string  `json:"type"`                     // channel、video 或 clip
+	Handle        string  `json:"handle,omitempty"`         // Twitch 登录名或 YouTube Handle（带 @）
+	ChannelID     string  `json:"channel_id,omitempty"`     // YouTube 频道ID（UC 开头）
+	VideoID       string  `json:"video_id,omitempty"`       // 录像ID
+	ClipID        string  `json:"clip_id,omitempty"`        // 剪辑ID
+	OffsetSeconds float64 `json:"offset_seconds,omitempty"` // 链接中 t 参数指定的起始时间
+	CanonicalURL  string  `json:"canonical_url"`
+}
+
+// ParsePlatformURL 解析任意 Twitch/YouTube 链接（频道、录像、剪辑、Handle），不访问网络
+// 省略协议头的链接（如 twitch.tv/xxx）同样支持
+func ParsePlatformURL(raw string) (*ParsedURL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, ErrUnsupportedURL
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedURL, err)
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+
+	var parsed *ParsedURL
+	switch host {
+	case "twitch.tv", "go.twitch.tv", "clips.twitch.tv", "player.twitch.tv":
+		parsed = parseTwitchURL(host, segments, u.Query())
+	case "youtube.com", "music.youtube.com", "youtu.be", "youtube-nocookie.com":
+		parsed = parseYouTubeURL(host, segments, u.Query())
+	}
+	if parsed == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedURL, raw)
+	}
+
+	if parsed.Type == URLTypeVideo {
+		parsed.OffsetSeconds = parseURLOffset(u.Query().Get("t"))
+	}
+	parsed.CanonicalURL = canonicalPlatformURL(parsed)
+	return parsed, nil
+}
+
+// parseTwitchURL 解析 twitch.tv、clips.twitch.tv 与 player.twitch.tv 的链接
+func parseTwitchURL(host string, segments []string, query url.Values) *ParsedURL {
+	switch host {
+	case "clips.twitch.tv":
+		if len(segments) >= 1 && segments[0] != "embed" && twitchClipPattern.MatchString(segments[0]) {
+			return &ParsedURL{Platform: "twitch", Type: URLTypeClip, ClipID: segments[0]}
+		}
+		if clip := query.Get("clip"); twitchClipPattern.MatchString(clip) {
+			return &ParsedURL{Platform: "twitch", Type: URLTypeClip, ClipID: clip}
+		}
+		return nil
+	case "player.twitch.tv":
+		if m := twitchVideoIDPattern.FindStringSubmatch(query.Get("video")); m != nil {
+			return &ParsedURL{Platform: "twitch", Type: URLTypeVideo, VideoID: m[1]}
+		}
+		if login := query.Get("channel"); twitchLoginPattern.MatchString(login) {
+			return &ParsedURL{Platform: "twitch", Type: URLTypeChannel, Handle: strings.ToLower(login)}
+		}
+		return nil
+	}
+
+	if len(segments) == 0 {
+		return nil
+	}
+	if segments[0] == "videos" {
+		if len(segments) >= 2 {
+			if m := twitchVideoIDPattern.FindStringSubmatch(segments[1]); m != nil {
+				return &ParsedURL{Platform: "twitch", Type: URLTypeVideo, VideoID: m[1]}
+			}
+		}
+		return nil
+	}
+
+	login := segments[0]
+	if twitchReservedPaths[strings.ToLower(login)] || !twitchLoginPattern.MatchString(login) {
+		return nil
+	}
+	login = strings.ToLower(login)
+
+	if len(segments) >= 3 {
+		switch segments[1] {
+		case "clip":
+			if twitchClipPattern.MatchString(segments[2]) {
+				return &ParsedURL{Platform: "twitch", Type: URLTypeClip, Handle: login, ClipID: segments[2]}
+			}
+			return nil
+		case "v", "video":
+			// 旧版录像链接 twitch.tv/{login}/v/{id}
+			if m := twitchVideoIDPattern.FindStringSubmatch(segments[2]); m != nil {
+				return &ParsedURL{Platform: "twitch", Type: URLTypeVideo, Handle: login, VideoID: m[1]}
+			}
+			return nil
+		}
+	}
+	return &ParsedURL{Platform: "twitch", Type: URLTypeChannel, Handle: login}
+}
+
+// parseYouTubeURL 解析 youtube.com 与 youtu.be 的链接
+func parseYouTubeURL(host string, segments []string, query url.Values) *ParsedURL {
+	video := func(id string) *ParsedURL {
+		if !youtubeVideoPattern.MatchString(id) {
+			return nil
+		}
+		return &ParsedURL{Platform: "youtube", Type: URLTypeVideo, VideoID: id}
+	}
+
+	if host == "youtu.be" {
+		if len(segments) == 0 {
+			return nil
+		}
+		return video(segments[0])
+	}
+
+	if len(segments) == 0 {
+		return nil
+	}
+	first := segments[0]
+	switch {
+	case first == "watch":
+		return video(query.Get("v"))
+	case first == "live" || first == "shorts" || first == "embed" || first == "v":
+		if len(segments) < 2 {
+			return nil
+		}
+		return video(segments[1])
+	case first == "clip":
+		if len(segments) >= 2 && youtubeClipPattern.MatchString(segments[1]) {
+			return &ParsedURL{Platform: "youtube", Type: URLTypeClip, ClipID: segments[1]}
+		}
+		return nil
+	case first == "channel":
+		if len(segments) >= 2 && youtubeChannelIDPattern.MatchString(segments[1]) {
+			return &ParsedURL{Platform: "youtube", Type: URLTypeChannel, ChannelID: segments[1]}
+		}
+		return nil
+	case first == "c" || first == "user":
+		// 旧版自定义链接，名称不一定与 Handle 相同，只作为查找依据
+		if len(segments) >= 2 && youtubeHandlePattern.MatchString(segments[1]) {
+			return &ParsedURL{Platform: "youtube", Type: URLTypeChannel, Handle: segments[1]}
+		}
+		return nil
+	case strings.HasPrefix(first, "@"):
+		if youtubeHandlePattern.MatchString(first) {
+			return &ParsedURL{Platform: "youtube", Type: URLTypeChannel, Handle: first}
+		}
+		return nil
+	}
+	return nil
+}
+
+// parseURLOffset 解析链接的 t 参数，支持 90、90s、1m30s、1h2m3s 等格式
+func parseURLOffset(t string) float64 {
+	if t == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(t, 64); err == nil && seconds > 0 {
+		return seconds
+	}
+	if d, err := time.ParseDuration(t); err == nil && d > 0 {
+		return d.Seconds()
+	}
+	return 0
+}
+
+// canonicalPlatformURL 生成资源的规范链接
+func canonicalPlatformURL(p *ParsedURL) string {
+	switch p.Platform + "/" + p.Type {
+	case "twitch/channel":
+		return "https://www.twitch.tv/" + p.Handle
+	case "twitch/video":
+		return "https://www.twitch.tv/videos/" + p.VideoID
+	case "twitch/clip":
+		return "https://clips.twitch.tv/" + p.ClipID
+	case "youtube/channel":
+		if p.ChannelID != "" {
+			return "https://www.youtube.com/channel/" + p.ChannelID
+		}
+		if strings.HasPrefix(p.Handle, "@") {
+			return "https://www.youtube.com/" + p.Handle
+		}
+		return "https://www.youtube.com/c/" + p.Handle
+	case "youtube/video":
+		return "https://www.youtube.com/watch?v=" + p.VideoID
+	case "youtube/clip":
+		return "https://www.youtube.com/clip/" + p.ClipID
+	}
+	return ""
+}
+
+// platformURLHandle 从主播平台配置的链接中取出账号（Twitch 登录名、YouTube Handle 或频道ID）
+// 无法解析时退回到链接的最后一段，兼容手动填写的配置
+func platformURLHandle(rawURL string) string {
+	if parsed, err := ParsePlatformURL(rawURL); err == nil && parsed.Type == URLTypeChannel {
+		if parsed.ChannelID != "" {
+			return parsed.ChannelID
+		}
+		return parsed.Handle
+	}
+	parts := strings.Split(strings.TrimRight(rawURL, "/"), "/")
+	return parts[len(parts)-1]
+}
+
+// videoProcessingState 录像是否已有分析结果、是否已下载聊天记录
+func videoProcessingState(videoID string) (analyzed, chatDownloaded bool) {
+	analyses, _ := filepath.Glob(filepath.Join(pathsafe.Join("./analysis_results", videoID), "analysis_*.json"))
+	chatLog := chatdownload.FindTwitchLog(videoID)
+	if chatLog == "" {
+		chatLog = chatdownload.FindYouTubeLog(videoID)
+	}
+	return len(analyses) > 0, chatLog != ""
+}
+
+// ResolveURLRequest 链接解析请求
+type ResolveURLRequest struct {
+	URL string `json:"url" binding:"required"`
+}
+
+// ResolveURL 解析任意 Twitch/YouTube 链接，返回规范的平台、类型与ID，以及是否已追踪/已分析
+// POST /api/resolve
+func ResolveURL(c *gin.Context) {
+	var req ResolveURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	parsed, err := ParsePlatformURL(req.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	resp := gin.H{
+		"success":  true,
+		"resolved": parsed,
+		"tracked":  false,
+	}
+
+	// 频道与带频道名的链接按账号查找，录像按已保存的元数据中的主播查找
+	lookup := parsed.Handle
+	if parsed.ChannelID != "" {
+		lookup = parsed.ChannelID
+	}
+	if parsed.VideoID != "" {
+		videoID := filepath.Base(parsed.VideoID)
+		analyzed, chatDownloaded := videoProcessingState(videoID)
+		resp["analyzed"] = analyzed
+		resp["chat_downloaded"] = chatDownloaded
+		if lookup == "" && (analyzed || chatDownloaded) {
+			lookup, _ = loadVideoMetaForAnalysis(videoID)
+		}
+		if _, err := os.Stat(pathsafe.Join(hotClipsDir, videoID)); err == nil {
+			resp["clips_downloaded"] = true
+		}
+	}
+
+	if lookup != "" {
+		if streamer := ResolveStreamer(lookup); streamer != nil {
+			resp["tracked"] = true
+			resp["streamer_id"] = streamer.ID
+			resp["streamer_name"] = streamer.Name
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	usageFile          = "App_Data/usage.json"
+	usageRetentionDays = 30
+	usageFlushInterval = time.Minute
+	usageDateLayout    = "2006-01-02"
+)
+
+// UsageCounters 用户一天内的使用量
+type UsageCounters struct {
+	Requests    int `json:"requests"`
+	Jobs        int `json:"jobs"`         // 提交的分析、下载等任务
+	AISummaries int `json:"ai_summaries"` // 读取的AI总结
+}
+
+// usage 计数类型
+const (
+	usageKindRequest   = "requests"
+	usageKindJob       = "jobs"
+	usageKindAISummary = "ai_summaries"
+)
+
+// usageJobRoutes 计为任务提交的接口
+var usageJobRoutes = map[string]bool{
+	"POST /api/analysis/sweep":          true,
+	"POST /api/analysis/:videoID/range": true,
+	"POST /api/clips/generate":          true,
+	"POST /api/jobs/:id/retry":          true,
+	"POST /api/twitch/download-chat":    true,
+	"POST /api/twitch/save-chat":        true,
+	"POST /api/streamers/subscribe":     true,
+	"POST /api/streamers/onboard":       true,
+	"POST /api/user/subscriptions":      true,
+}
+
+// usageAISummaryRoutes 计为AI总结消耗的接口
+var usageAISummaryRoutes = map[string]bool{
+	"GET /api/twitch/analysis-summary":          true,
+	"GET /api/analysis/:videoID/summary/stream": true,
+}
+
+var (
+	usageMu    sync.Mutex
+	usageData  = map[string]map[string]*UsageCounters{} // userHash -> 日期 -> 计数
+	usageDirty bool
+	usageOnce  sync.Once
+)
+
+// InitUsageTracking 加载历史使用量并启动定期写盘
+func InitUsageTracking() {
+	usageOnce.Do(func() {
+		if data, err := os.ReadFile(usageFile); err == nil {
+			usageMu.Lock()
+			if err := json.Unmarshal(data, &usageData); err != nil {
+				log.Printf("读取使用量文件失败: %v", err)
+				usageData = map[string]map[string]*UsageCounters{}
+			}
+			usageMu.Unlock()
+		}
+
+		go func() {
+			ticker := time.NewTicker(usageFlushInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := flushUsage(); err != nil {
+					log.Printf("保存使用量失败: %v", err)
+				}
+			}
+		}()
+	})
+}
+
+// UsageMiddleware 统计已登录用户的请求、任务提交与AI总结读取次数，并执行套餐限额
+// 未登录的请求提交任务或读取AI总结时按客户端 IP 计量，使用默认套餐的限额
+func UsageMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + canonicalRoute(c.FullPath())
+		userHash, err := getUserHashFromCookie(c)
+		if err != nil || userHash == "" {
+			if !usageJobRoutes[route] && !usageAISummaryRoutes[route] {
+				c.Next()
+				return
+			}
+			// 不登录也不能绕过限额
+			userHash = anonymousUsageKey(c)
+		} else if c.GetBool(impersonatedContextKey) {
+			// 支持人员代入用户身份的请求不计入该用户的使用量；只认已校验的代入令牌，
+			// 无效令牌不会退回到请求者自己的身份，因此不能借此绕过限额
+			c.Next()
+			return
+		}
+
+		kinds := []string{usageKindRequest}
+		if usageJobRoutes[route] {
+			kinds = append(kinds, usageKindJob)
+		}
+		if usageAISummaryRoutes[route] {
+			kinds = append(kinds, usageKindAISummary)
+		}
+
+		if kind, limit, ok := recordUsage(userHash, kinds); !ok {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"message": "已达到今日使用上限",
+				"limit":   kind,
+				"max":     limit,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// anonymousUsageKey 未登录请求的计量键，按客户端 IP 的哈希区分，不保存原始 IP
+func anonymousUsageKey(c *gin.Context) string {
+	return "ip:" + computeSha256Hex(c.ClientIP())[:16]
+}
+
+// renameUsageUser 将使用量记录迁移到新的 userHash
+func renameUsageUser(oldHash, newHash string) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	days, ok := usageData[oldHash]
+	if !ok {
+		return
+	}
+	usageData[newHash] = days
+	delete(usageData, oldHash)
+	usageDirty = true
+}
+
+// recordUsage 检查限额并累加计数，超出任一限额时不计数并返回超出的类型
+func recordUsage(userHash string, kinds []string) (string, int, bool) {
+	limits := GetUsageConfig().limitsFor(userHash)
+	today := time.Now().Format(usageDateLayout)
+
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	days := usageData[userHash]
+	if days == nil {
+		days = map[string]*UsageCounters{}
+		usageData[userHash] = days
+	}
+	counters := days[today]
+	if counters == nil {
+		counters = &UsageCounters{}
+		days[today] = counters
+	}
+
+	for _, kind := range kinds {
+		if limit := limits.limitFor(kind); limit > 0 && counters.get(kind) >= limit {
+			return kind, limit, false
+		}
+	}
+	for _, kind := range kinds {
+		counters.add(kind)
+	}
+	usageDirty = true
+	return "", 0, true
+}
+
+// get 读取指定类型的计数
+func (u *UsageCounters) get(kind string) int {
+	switch kind {
+	case usageKindJob:
+		return u.Jobs
+	case usageKindAISummary:
+		return u.AISummaries
+	default:
+		return u.Requests
+	}
+}
+
+// add 指定类型的计数加一
+func (u *UsageCounters) add(kind string) {
+	switch kind {
+	case usageKindJob:
+		u.Jobs++
+	case usageKindAISummary:
+		u.AISummaries++
+	default:
+		u.Requests++
+	}
+}
+
+// limitFor 返回指定类型的每日上限，0 表示不限制
+func (p PlanLimits) limitFor(kind string) int {
+	switch kind {
+	case usageKindJob:
+		return p.DailyJobs
+	case usageKindAISummary:
+		return p.DailyAISummaries
+	default:
+		return p.DailyRequests
+	}
+}
+
+// flushUsage 清理过期数据并写盘
+func flushUsage() error {
+	usageMu.Lock()
+	if !usageDirty {
+		usageMu.Unlock()
+		return nil
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -usageRetentionDays).Format(usageDateLayout)
+	for userHash, days := range usageData {
+		for day := range days {
+			if day < cutoff {
+				delete(days, day)
+			}
+		}
+		if len(days) == 0 {
+			delete(usageData, userHash)
+		}
+	}
+
+	data, err := json.MarshalIndent(usageData, "", "  ")
+	usageDirty = false
+	usageMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(usageFile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(usageFile, data, 0644)
+}
+
+// usageDay 每日使用量
+type usageDay struct {
+	Date string `json:"date"`
+	UsageCounters
+}
+
+// GetUserUsage 获取当前用户最近几天的使用量与套餐限额
+// GET /api/user/usage?days=7
+func GetUserUsage(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "未登录或登录已过期",
+		})
+		return
+	}
+
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if days <= 0 || days > usageRetentionDays {
+		days = 7
+	}
+
+	now := time.Now()
+	breakdown := make([]usageDay, 0, days)
+	var total UsageCounters
+
+	usageMu.Lock()
+	for i := 0; i < days; i++ {
+		date := now.AddDate(0, 0, -i).Format(usageDateLayout)
+		day := usageDay{Date: date}
+		if counters := usageData[userHash][date]; counters != nil {
+			day.UsageCounters = *counters
+		}
+		total.Requests += day.Requests
+		total.Jobs += day.Jobs
+		total.AISummaries += day.AISummaries
+		breakdown = append(breakdown, day)
+	}
+	usageMu.Unlock()
+
+	sort.Slice(breakdown, func(i, j int) bool {
+		return breakdown[i].Date < breakdown[j].Date
+	})
+
+	cfg := GetUsageConfig()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"plan":    cfg.planFor(userHash),
+		"limits":  cfg.limitsFor(userHash),
+		"total":   total,
+		"daily":   breakdown,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 const userLastSeenFile = "App_Data/user_lastseen.json"
@@ -77,6 +78,24 @@ func UpdateUserLastSeen(userHash, streamerID, lastSeen string) error {
     return saveUserLastSeen(s)
 }
 
+// renameUserLastSeen 将 lastSeen 记录迁移到新的 userHash
+func renameUserLastSeen(oldHash, newHash string) error {
+	lastSeenMutex.Lock()
+	defer lastSeenMutex.Unlock()
+
+	s, err := loadUserLastSeen()
+	if err != nil {
+		return err
+	}
+	m, ok := s.LastSeen[oldHash]
+	if !ok {
+		return nil
+	}
+	s.LastSeen[newHash] = m
+	delete(s.LastSeen, oldHash)
+	return saveUserLastSeen(s)
+}
+
 // GetUserLastSeen 获取用户对某主播的 lastSeen 时间戳，返回 (value, true) 如果存在
 func GetUserLastSeen(userHash, streamerID string) (string, bool, error) {
     lastSeenMutex.Lock()
@@ -93,3 +112,28 @@ func GetUserLastSeen(userHash, streamerID string) (string, bool, error) {
     }
     return "", false, nil
 }
+
+// AdvanceUserLastSeen 将用户对各主播的 lastSeen 时间戳前移到给定值，已有的更晚时间戳保持不变
+func AdvanceUserLastSeen(userHash string, markers map[string]time.Time) error {
+	lastSeenMutex.Lock()
+	defer lastSeenMutex.Unlock()
+
+	s, err := loadUserLastSeen()
+	if err != nil {
+		return err
+	}
+
+	m, ok := s.LastSeen[userHash]
+	if !ok || m == nil {
+		m = map[string]string{}
+		s.LastSeen[userHash] = m
+	}
+	for streamerID, at := range markers {
+		if prev, err := time.Parse(time.RFC3339, m[streamerID]); err == nil && !at.After(prev) {
+			continue
+		}
+		m[streamerID] = at.UTC().Format(time.RFC3339)
+	}
+
+	return saveUserLastSeen(s)
+}
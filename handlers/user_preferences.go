@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"subtuber-services/pathsafe"
+	"subtuber-services/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 摘要邮件的发送频率
+const (
+	DigestFrequencyHourly = "hourly"
+	DigestFrequencyDaily  = "daily"
+	DigestFrequencyWeekly = "weekly"
+	DigestFrequencyNever  = "never" // 不发送摘要，摘要模式下的通知直接丢弃
+)
+
+// 用户偏好的默认值
+const (
+	defaultUserLanguage        = "zh-CN"
+	defaultUserTimezone        = "Asia/Shanghai"
+	defaultUserDigestFrequency = DigestFrequencyDaily
+)
+
+// clipQualityRank 片段画质从低到高，订阅者偏好不同时取最高的画质
+var clipQualityRank = []string{"audio_only", "160p", "360p", "480p", "720p", "1080p", "source"}
+
+var summaryLanguageRe = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// summaryLanguageNames AI总结提示词中使用的语言名称，未列出的语言直接使用语言代码
+var summaryLanguageNames = map[string]string{
+	"zh":    "Chinese",
+	"zh-cn": "Simplified Chinese",
+	"zh-tw": "Traditional Chinese",
+	"en":    "English",
+	"ja":    "Japanese",
+	"ko":    "Korean",
+}
+
+var userPreferencesMu sync.Mutex
+
+// withDefaults 为未设置的偏好填充默认值（兼容早期创建的用户）
+func (p userPreferences) withDefaults() userPreferences {
+	if p.Language == "" {
+		p.Language = defaultUserLanguage
+	}
+	if p.Timezone == "" {
+		p.Timezone = defaultUserTimezone
+	}
+	if p.ClipQuality == "" {
+		p.ClipQuality = defaultClipQuality
+	}
+	if p.SummaryLanguage == "" {
+		p.SummaryLanguage = p.Language
+	}
+	if p.DigestFrequency == "" {
+		p.DigestFrequency = defaultUserDigestFrequency
+	}
+	return p
+}
+
+// validate 检查偏好的取值
+func (p userPreferences) validate() error {
+	if !containsFold(clipQualityRank, p.ClipQuality) {
+		return fmt.Errorf("不支持的片段画质: %s", p.ClipQuality)
+	}
+	if !summaryLanguageRe.MatchString(p.SummaryLanguage) {
+		return fmt.Errorf("无效的总结语言: %s", p.SummaryLanguage)
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("无效的时区: %s", p.Timezone)
+	}
+	switch p.DigestFrequency {
+	case DigestFrequencyHourly, DigestFrequencyDaily, DigestFrequencyWeekly, DigestFrequencyNever:
+	default:
+		return fmt.Errorf("不支持的摘要频率: %s", p.DigestFrequency)
+	}
+	return nil
+}
+
+// digestInterval 摘要邮件的发送间隔，never 时返回 0
+func (p userPreferences) digestInterval() time.Duration {
+	switch p.DigestFrequency {
+	case DigestFrequencyHourly:
+		return time.Hour
+	case DigestFrequencyWeekly:
+		return 7 * 24 * time.Hour
+	case DigestFrequencyNever:
+		return 0
+	default:
+		return 24 * time.Hour
+	}
+}
+
+// userModelPath 用户资料文件路径
+func userModelPath(userHash string) string {
+	return pathsafe.Join("App_Data", userHash, "user.json")
+}
+
+// loadUserModel 读取用户资料，偏好中未设置的字段使用默认值
+func loadUserModel(userHash string) (userModel, error) {
+	var user userModel
+	data, err := os.ReadFile(userModelPath(userHash))
+	if err != nil {
+		return user, err
+	}
+	if err := json.Unmarshal(data, &user); err != nil {
+		return user, err
+	}
+	user.Preferences = user.Preferences.withDefaults()
+	return user, nil
+}
+
+// GetUserPreferences 读取用户偏好，用户资料不存在时返回默认偏好
+func GetUserPreferences(userHash string) userPreferences {
+	user, err := loadUserModel(userHash)
+	if err != nil {
+		return userPreferences{EmailNotifications: true}.withDefaults()
+	}
+	return user.Preferences
+}
+
+// GetUserPreferencesHandler 获取当前用户的偏好设置
+func GetUserPreferencesHandler(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "未登录或登录已过期",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"preferences": GetUserPreferences(userHash),
+	})
+}
+
+// UpdateUserPreferences 更新当前用户的偏好设置，未提供的字段保持原值
+func UpdateUserPreferences(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "未登录或登录已过期",
+		})
+		return
+	}
+
+	userPreferencesMu.Lock()
+	defer userPreferencesMu.Unlock()
+
+	user, err := loadUserModel(userHash)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "用户不存在",
+		})
+		return
+	}
+
+	prefs := user.Preferences
+	if err := c.ShouldBindJSON(&prefs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的请求参数: " + err.Error(),
+		})
+		return
+	}
+	prefs.ClipQuality = strings.ToLower(prefs.ClipQuality)
+	prefs.DigestFrequency = strings.ToLower(prefs.DigestFrequency)
+	prefs = prefs.withDefaults()
+	if err := prefs.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	user.Preferences = prefs
+	data, err := json.MarshalIndent(user, "", "  ")
+	if err == nil {
+		err = os.WriteFile(userModelPath(userHash), data, 0o644)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "保存偏好设置失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "偏好设置已更新",
+		"preferences": prefs,
+	})
+}
+
+// subscriberContentPreferences 汇总主播订阅者的内容偏好
+// 片段只下载一次，画质取订阅者中最高的；总结语言取选择人数最多的语言（人数相同时按语言代码排序）
+// 没有订阅者或无法获取订阅者时 ok 为 false
+func subscriberContentPreferences(streamer string) (quality, language string, ok bool) {
+	streamerID := streamer
+	if info := ResolveStreamer(streamer); info != nil {
+		streamerID = info.ID
+	}
+
+	resp, err := services.GetStreamerSubscribers(streamerID)
+	if err != nil {
+		log.Printf("获取 %s 的订阅者失败，使用默认的内容设置: %v", streamerID, err)
+		return "", "", false
+	}
+	if len(resp.Subscriptions) == 0 {
+		return "", "", false
+	}
+
+	bestRank := -1
+	languages := map[string]int{}
+	for _, sub := range resp.Subscriptions {
+		prefs := GetUserPreferences(sub.UserHash)
+		for rank, q := range clipQualityRank {
+			if strings.EqualFold(q, prefs.ClipQuality) && rank > bestRank {
+				bestRank, quality = rank, q
+			}
+		}
+		languages[prefs.SummaryLanguage]++
+	}
+
+	candidates := make([]string, 0, len(languages))
+	for lang := range languages {
+		candidates = append(candidates, lang)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if languages[candidates[i]] != languages[candidates[j]] {
+			return languages[candidates[i]] > languages[candidates[j]]
+		}
+		return candidates[i] < candidates[j]
+	})
+	return quality, candidates[0], true
+}
+
+// summaryLanguageName AI总结提示词中的语言名称，未指定时为中文
+func summaryLanguageName(language string) string {
+	if language == "" {
+		return "Chinese"
+	}
+	if name, ok := summaryLanguageNames[strings.ToLower(language)]; ok {
+		return name
+	}
+	if name, ok := summaryLanguageNames[strings.ToLower(strings.SplitN(language, "-", 2)[0])]; ok {
+		return name
+	}
+	return language
+}
+
+// summaryLanguageKey 在 context 中传递AI总结语言
+type summaryLanguageKey struct{}
+
+// withSummaryLanguage 返回携带AI总结语言的 context，语言为空时使用默认语言
+func withSummaryLanguage(ctx context.Context, language string) context.Context {
+	if language == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, summaryLanguageKey{}, language)
+}
+
+// summaryLanguageFromContext 读取 context 中的AI总结语言
+func summaryLanguageFromContext(ctx context.Context) string {
+	language, _ := ctx.Value(summaryLanguageKey{}).(string)
+	return language
+}
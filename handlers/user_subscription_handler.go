@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
 	"log"
 	"net/http"
 	"strings"
@@ -27,21 +26,36 @@ type UserSubscription struct {
 	SubscribedAt time.Time `json:"subscribed_at"`
 }
 
-// getUserHashFromCookie 从 cookie 中获取用户 hash
+// getUserHashFromCookie 从会话令牌中获取用户 hash，同一请求中只校验一次
 func getUserHashFromCookie(c *gin.Context) (string, error) {
-	userInfoCookie, err := c.Cookie("UserInfo")
-	if err != nil {
-		return "", err
+	if v, ok := c.Get(userHashContextKey); ok {
+		return v.(string), nil
 	}
-
-	var user struct {
-		UserId string `json:"userId"`
+	if v, ok := c.Get(impersonationErrContextKey); ok {
+		return "", v.(error)
 	}
-	if err := json.Unmarshal([]byte(userInfoCookie), &user); err != nil {
-		return "", err
+
+	var userHash string
+	// 管理员签发的代入令牌优先，用于支持人员以用户身份复现问题
+	if token := c.GetHeader(ImpersonationHeader); token != "" {
+		hash, err := impersonatedUser(c, token)
+		if err != nil {
+			// 中间件与处理器都会读取用户身份，拒绝结果只校验并审计一次
+			c.Set(impersonationErrContextKey, err)
+			return "", err
+		}
+		userHash = hash
+		c.Set(impersonatedContextKey, true)
+	} else {
+		claims, err := sessionUser(c)
+		if err != nil {
+			return "", err
+		}
+		userHash = claims.Subject
 	}
 
-	return user.UserId, nil
+	c.Set(userHashContextKey, userHash)
+	return userHash, nil
 }
 
 // GetUserSubscriptions 通过 RPC 获取用户订阅的主播列表
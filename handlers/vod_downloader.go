@@ -1,22 +1,32 @@
 package handlers
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"subtuber-services/pathsafe"
 	"subtuber-services/services"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// ErrSubscriberOnlyVOD 录像匿名无法获取任何画质（通常为订阅者专属），不支持下载
+var ErrSubscriberOnlyVOD = errors.New("该录像为订阅者专属或无法匿名访问，暂不支持下载")
+
 // VODDownloadRequest 定义 VOD 下载请求的结构
 type VODDownloadRequest struct {
 	VODID        string  `json:"vod_id"`        // VOD ID (可以是完整URL或纯ID)
@@ -25,6 +35,9 @@ type VODDownloadRequest struct {
 	Quality      string  `json:"quality"`       // 视频质量，如 "1080p60", "720p", "audio_only" 等
 	OutputPath   string  `json:"output_path"`   // 输出路径（可选，默认为 downloads 目录）
 	ExtractAudio bool    `json:"extract_audio"` // 是否提取音频
+	SkipASR      bool    `json:"skip_asr"`      // 是否跳过语音识别
+	Platform     string  `json:"platform"`      // twitch（默认）或 youtube，为空时根据链接或录像ID判断
+	Streamer     string  `json:"streamer"`      // 主播ID（可选），用于查找 YouTube 会员凭据
 }
 
 // VODDownloadResponse 定义下载响应
@@ -59,6 +72,7 @@ type TwitchGQLResponse struct {
 			Title         string `json:"title"`
 			LengthSeconds int    `json:"lengthSeconds"`
 			Owner         struct {
+				ID          string `json:"id"`
 				DisplayName string `json:"displayName"`
 			} `json:"owner"`
 		} `json:"video"`
@@ -86,29 +100,47 @@ func NewVODDownloader(outputDir string) *VODDownloader {
 	os.MkdirAll(outputDir, 0755)
 
 	return &VODDownloader{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		outputDir: outputDir,
+		httpClient: newTracedHTTPClient(30 * time.Second),
+		outputDir:  outputDir,
 	}
 }
 
 // ExtractVODID 从 URL 或字符串中提取 VOD ID
 func (vd *VODDownloader) ExtractVODID(input string) string {
-	// 匹配 twitch.tv/videos/123456
-	re := regexp.MustCompile(`(?:twitch\.tv/videos/|^)(\d+)`)
-	matches := re.FindStringSubmatch(input)
-	if len(matches) > 1 {
-		return matches[1]
+	// 匹配 twitch.tv/videos/123456 等录像链接
+	if parsed, err := ParsePlatformURL(input); err == nil && parsed.Platform == "twitch" && parsed.VideoID != "" {
+		return parsed.VideoID
+	}
+	if m := twitchVideoIDPattern.FindStringSubmatch(strings.TrimSpace(input)); m != nil {
+		return m[1]
+	}
+	// 匹配 youtube.com/watch?v=xxx、youtu.be/xxx 等录像链接
+	if parsed, err := ParsePlatformURL(input); err == nil && parsed.Platform == "youtube" && parsed.VideoID != "" {
+		return parsed.VideoID
 	}
 	return input
 }
 
-// GetVideoInfo 获取视频信息
+// requestPlatform 请求的录像所在平台：未指定时 YouTube 链接或 11 位非纯数字的录像ID视为 YouTube，其余视为 Twitch
+func (vd *VODDownloader) requestPlatform(req *VODDownloadRequest) string {
+	if req.Platform != "" {
+		return strings.ToLower(req.Platform)
+	}
+	if parsed, err := ParsePlatformURL(req.VODID); err == nil && parsed.Platform == "youtube" {
+		return "youtube"
+	}
+	vodID := strings.TrimSpace(req.VODID)
+	if youtubeVideoPattern.MatchString(vodID) && !twitchVideoIDPattern.MatchString(vodID) {
+		return "youtube"
+	}
+	return "twitch"
+}
+
+// GetVideoInfo 获取视频信息与匿名播放令牌
 func (vd *VODDownloader) GetVideoInfo(vodID string) (*TwitchGQLResponse, error) {
 	// Twitch GraphQL API
 	gqlQuery := fmt.Sprintf(`{
-		"query": "query { video(id: \"%s\") { id title lengthSeconds owner { displayName } } videoPlaybackAccessToken(id: \"%s\", params: { platform: \"web\", playerBackend: \"mediaplayer\", playerType: \"site\" }) { value signature } }"
+		"query": "query { video(id: \"%s\") { id title lengthSeconds owner { id displayName } } videoPlaybackAccessToken(id: \"%s\", params: { platform: \"web\", playerBackend: \"mediaplayer\", playerType: \"site\" }) { value signature } }"
 	}`, vodID, vodID)
 
 	req, err := http.NewRequest("POST", "https://gql.twitch.tv/gql", strings.NewReader(gqlQuery))
@@ -197,10 +229,15 @@ func (vd *VODDownloader) ParseM3U8Playlist(playlistURL string) (*TwitchPlaylist,
 	return playlist, nil
 }
 
-// DownloadVOD 下载 VOD 音频 还有字幕转换
+// DownloadVOD 下载 VOD 音频 还有字幕转换，Twitch 录像通过 M3U8 播放列表下载，YouTube 录像通过 yt-dlp 下载
 func (vd *VODDownloader) DownloadVOD(ctx context.Context, req *VODDownloadRequest) (*VODDownloadResponse, error) {
 	startTime := time.Now()
 
+	// YouTube 录像通过 yt-dlp 下载
+	if vd.requestPlatform(req) == "youtube" {
+		return vd.downloadYouTubeVOD(ctx, req)
+	}
+
 	// 提取 VOD ID
 	vodID := vd.ExtractVODID(req.VODID)
 
@@ -242,6 +279,14 @@ func (vd *VODDownloader) DownloadVOD(ctx context.Context, req *VODDownloadReques
 		}, err
 	}
 
+	// 订阅者专属录像匿名请求拿不到任何画质
+	if len(playlist.Qualities) == 0 {
+		return &VODDownloadResponse{
+			Success: false,
+			Message: ErrSubscriberOnlyVOD.Error(),
+		}, ErrSubscriberOnlyVOD
+	}
+
 	// 选择质量
 	selectedQuality := vd.selectQuality(playlist, req.Quality)
 	if selectedQuality == nil {
@@ -258,9 +303,10 @@ func (vd *VODDownloader) DownloadVOD(ctx context.Context, req *VODDownloadReques
 	}
 	os.MkdirAll(outputDir, 0755)
 
-	// 生成文件名
-	safeTitle := sanitizeFilename(videoInfo.Data.Video.Title)
-	videoFilename := fmt.Sprintf("%s_%s.mp4", vodID, safeTitle)
+	// 生成文件名，标题清理后附加哈希后缀，避免清理后同名的标题互相覆盖
+	safeTitle := pathsafe.Unique(videoInfo.Data.Video.Title)
+	safeID := pathsafe.Name(vodID)
+	videoFilename := fmt.Sprintf("%s_%s.mp4", safeID, safeTitle)
 	videoPath := filepath.Join(outputDir, videoFilename)
 
 	// 检查 ffmpeg 是否可用
@@ -288,11 +334,17 @@ func (vd *VODDownloader) DownloadVOD(ctx context.Context, req *VODDownloadReques
 		DownloadTime: time.Since(startTime).Seconds(),
 	}
 
+	vd.extractAudioAndSubtitles(ctx, req, vodID, filepath.Join(outputDir, fmt.Sprintf("%s_%s", safeID, safeTitle)), response)
+	return response, nil
+}
+
+// extractAudioAndSubtitles 从下载的视频中提取音频，并使用必剪接口生成字幕，结果写入 response
+// basePath 为不含扩展名的输出路径，音频与字幕分别保存为 .mp3 与 .srt
+func (vd *VODDownloader) extractAudioAndSubtitles(ctx context.Context, req *VODDownloadRequest, vodID, basePath string, response *VODDownloadResponse) {
 	// 如果需要提取音频
-	audioFilename := fmt.Sprintf("%s_%s.mp3", vodID, safeTitle)
-	audioPath := filepath.Join(outputDir, audioFilename)
+	audioPath := basePath + ".mp3"
 
-	err = vd.extractAudio(ctx, videoPath, audioPath)
+	err := vd.extractAudio(ctx, response.VideoPath, audioPath)
 	if err != nil {
 		response.Message += fmt.Sprintf("; Failed to extract audio: %v", err)
 	} else {
@@ -300,61 +352,57 @@ func (vd *VODDownloader) DownloadVOD(ctx context.Context, req *VODDownloadReques
 		response.Message = "Video downloaded and audio extracted successfully"
 	}
 
-	// 使用必剪接口提取字幕
-	if response.AudioPath != "" {
-		subtitleFilename := fmt.Sprintf("%s_%s.srt", vodID, safeTitle)
-		subtitlePath := filepath.Join(outputDir, subtitleFilename)
+	// 使用配置的语音识别提供商提取字幕（默认必剪，失败时依次尝试备用提供商）
+	if response.AudioPath != "" && IsFeatureEnabled(FeatureASR) && !req.SkipASR {
+		subtitlePath := basePath + ".srt"
 
 		log.Printf("Starting subtitle extraction for: %s", audioPath)
 
-		// 读取音频文件
-		audioFile, err := os.Open(audioPath)
+		var audioBytes int64
+		if info, err := os.Stat(audioPath); err == nil {
+			audioBytes = info.Size()
+		}
+		asr := NewConfiguredASRService()
+		_, asrSpan := startPipelineSpan(ctx, "asr", vodID,
+			attribute.Int64("asr.audio_bytes", audioBytes),
+			attribute.String("asr.providers", asr.Name()))
+		var asrResult *services.ASRResult
+		release, err := acquireStageSlot(ctx, pipelineStageASR)
+		if err == nil {
+			asrResult, err = asr.Transcribe(ctx, audioPath)
+			release()
+		}
+		endSpan(asrSpan, err)
 		if err != nil {
-			log.Printf("Failed to open audio file: %v", err)
-			response.Message += "; Failed to open audio file for subtitle extraction"
+			log.Printf("Failed to extract subtitles: %v", err)
+			response.Message += fmt.Sprintf("; Failed to extract subtitles: %v", err)
 		} else {
-			audioData, err := io.ReadAll(audioFile)
-			audioFile.Close()
+			// 转换为SRT格式并保存
+			srtContent := vd.convertToSRT(asrResult)
+			err = os.WriteFile(subtitlePath, []byte(srtContent), 0644)
 			if err != nil {
-				log.Printf("Failed to read audio file: %v", err)
-				response.Message += "; Failed to read audio file for subtitle extraction"
+				log.Printf("Failed to save subtitle file: %v", err)
+				response.Message += "; Failed to save subtitle file"
 			} else {
-				// 创建必剪ASR实例并运行
-				asr := services.NewBcutASR(audioData)
-				asrResult, err := asr.Run()
-				if err != nil {
-					log.Printf("Failed to extract subtitles: %v", err)
-					response.Message += fmt.Sprintf("; Failed to extract subtitles: %v", err)
-				} else {
-					// 转换为SRT格式并保存
-					srtContent := vd.convertToSRT(asrResult)
-					err = os.WriteFile(subtitlePath, []byte(srtContent), 0644)
-					if err != nil {
-						log.Printf("Failed to save subtitle file: %v", err)
-						response.Message += "; Failed to save subtitle file"
+				response.SubtitlePath = subtitlePath
+				response.Message = "Video downloaded, audio extracted, and subtitles generated successfully"
+				log.Printf("Subtitles saved to: %s (segments: %d)", subtitlePath, len(asrResult.Segments))
+
+				// 复制SRT文件到 analysis_results/{vodID} 目录
+				analysisDir := pathsafe.Join("./analysis_results", vodID)
+				if err := os.MkdirAll(analysisDir, 0755); err == nil {
+					analysisFilename := fmt.Sprintf("%s_%.0f.srt", pathsafe.Name(vodID), req.StartTime)
+					analysisPath := filepath.Join(analysisDir, analysisFilename)
+					if err := os.WriteFile(analysisPath, []byte(srtContent), 0644); err == nil {
+						log.Printf("Subtitle also copied to: %s", analysisPath)
 					} else {
-						response.SubtitlePath = subtitlePath
-						response.Message = "Video downloaded, audio extracted, and subtitles generated successfully"
-						log.Printf("Subtitles saved to: %s (segments: %d)", subtitlePath, len(asrResult.Segments))
-
-						// 复制SRT文件到 analysis_results/{vodID} 目录
-						analysisDir := filepath.Join("analysis_results", vodID)
-						if err := os.MkdirAll(analysisDir, 0755); err == nil {
-							analysisFilename := fmt.Sprintf("%s_%.0f.srt", vodID, req.StartTime)
-							analysisPath := filepath.Join(analysisDir, analysisFilename)
-							if err := os.WriteFile(analysisPath, []byte(srtContent), 0644); err == nil {
-								log.Printf("Subtitle also copied to: %s", analysisPath)
-							} else {
-								log.Printf("Failed to copy subtitle to analysis folder: %v", err)
-							}
-						}
+						log.Printf("Failed to copy subtitle to analysis folder: %v", err)
 					}
 				}
 			}
 		}
 	}
 
-	return response, nil
 }
 
 // formatSRTTimestamp 格式化时间戳为SRT格式 (HH:MM:SS,mmm)
@@ -434,11 +482,69 @@ func (vd *VODDownloader) downloadWithFFmpeg(ctx context.Context, m3u8URL, output
 
 	args = append(args, "-y", outputPath)
 
+	release, err := acquireStageSlot(ctx, pipelineStageFFmpeg)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// 有任务在跟踪进度时，通过 -progress 读取已处理的时长
+	jobID := jobIDFromContext(ctx)
+	if jobID == "" {
+		cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+
+	args = append([]string{"-progress", "pipe:1", "-nostats"}, args...)
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
 
-	return cmd.Run()
+	// 开始时间在 -i 之前时 -to 表示截取时长
+	duration := endTime
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "out_time_us=")
+		if !ok {
+			continue
+		}
+		us, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || us < 0 {
+			continue
+		}
+		processed := float64(us) / 1e6
+		percent := 0.0
+		if duration > 0 {
+			percent = math.Min(processed/duration*100, 100)
+		}
+		reportJobProgress(jobID, JobStageFFmpeg, percent, fmt.Sprintf("已处理 %.0f 秒", processed))
+	}
+
+	return cmd.Wait()
+}
+
+// asrStateText 必剪ASR任务状态的说明
+func asrStateText(state int) string {
+	switch state {
+	case 0:
+		return "等待识别"
+	case 1:
+		return "识别中"
+	case 3:
+		return "识别失败"
+	case 4:
+		return "识别完成"
+	default:
+		return fmt.Sprintf("状态 %d", state)
+	}
 }
 
 // extractAudio 从视频中提取音频
@@ -453,24 +559,15 @@ func (vd *VODDownloader) extractAudio(ctx context.Context, videoPath, audioPath
 		audioPath,
 	}
 
+	release, err := acquireStageSlot(ctx, pipelineStageFFmpeg)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	return cmd.Run()
 }
-
-// sanitizeFilename 清理文件名中的非法字符
-func sanitizeFilename(filename string) string {
-	// 移除或替换非法字符
-	reg := regexp.MustCompile(`[<>:"/\\|?*]`)
-	filename = reg.ReplaceAllString(filename, "_")
-	filename = strings.TrimSpace(filename)
-
-	// 限制长度
-	if len(filename) > 100 {
-		filename = filename[:100]
-	}
-
-	return filename
-}
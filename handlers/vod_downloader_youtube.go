@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"subtuber-services/pathsafe"
+)
+
+// youtubeQualityPattern 画质名称中的高度，如 720p、1080p60
+var youtubeQualityPattern = regexp.MustCompile(`^(\d{3,4})p`)
+
+// youtubeFormatSelector 将画质转换为 yt-dlp 的格式选择：720p 等选择不高于该高度的最佳视频与音频，
+// 没有满足条件的格式时退回最佳格式；audio_only 只下载音频
+func youtubeFormatSelector(quality string) string {
+	quality = strings.ToLower(strings.TrimSpace(quality))
+	if quality == "audio_only" {
+		return "ba/b"
+	}
+	if m := youtubeQualityPattern.FindStringSubmatch(quality); m != nil {
+		return fmt.Sprintf("bv*[height<=%s]+ba/b[height<=%s]/bv*+ba/b", m[1], m[1])
+	}
+	return "bv*+ba/b"
+}
+
+// ytDlpCredentialArgs 配置了会员凭据时返回 yt-dlp 使用会员 cookies 的参数，cleanup 删除临时 cookies 文件
+func ytDlpCredentialArgs(creds *YouTubeMemberCredentials) ([]string, func(), error) {
+	if creds == nil {
+		return nil, func() {}, nil
+	}
+	cookiesPath, err := creds.writeTempCookiesFile()
+	if err != nil {
+		return nil, nil, fmt.Errorf("写入会员凭据失败: %v", err)
+	}
+	args := []string{"--cookies", cookiesPath}
+	if creds.POToken != "" {
+		args = append(args, "--extractor-args", "youtube:po_token=web+"+creds.POToken)
+	}
+	return args, func() { os.Remove(cookiesPath) }, nil
+}
+
+// runYtDlp 执行 yt-dlp 并返回标准输出，失败时错误中包含标准错误输出
+// 参数中可能包含凭据，调用方不应将参数写入日志
+func runYtDlp(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("yt-dlp 执行失败: %v, 输出: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+// lastLine 输出中最后一个非空行
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// downloadYouTubeVOD 使用 yt-dlp 下载 YouTube 录像（或其中一段），之后与 Twitch 录像相同地提取音频并生成字幕
+// 与 Twitch 录像一致，EndTime 为从 StartTime 开始截取的时长
+func (vd *VODDownloader) downloadYouTubeVOD(ctx context.Context, req *VODDownloadRequest) (*VODDownloadResponse, error) {
+	startTime := time.Now()
+
+	videoID := vd.ExtractVODID(req.VODID)
+	videoURL := "https://www.youtube.com/watch?v=" + videoID
+
+	if _, err := exec.LookPath("yt-dlp"); err != nil {
+		return &VODDownloadResponse{
+			Success: false,
+			Message: "yt-dlp not found in PATH. Please install yt-dlp to download YouTube videos.",
+		}, err
+	}
+	// 按时间段下载与合并音视频都依赖 ffmpeg
+	if err := vd.checkFFmpeg(); err != nil {
+		return &VODDownloadResponse{
+			Success: false,
+			Message: fmt.Sprintf("FFmpeg not found: %v. Please install FFmpeg to download videos.", err),
+		}, err
+	}
+
+	// 配置了会员凭据时用于下载会员专属录像
+	var creds *YouTubeMemberCredentials
+	if req.Streamer != "" {
+		creds = GetYouTubeMemberCredentials(req.Streamer)
+	}
+	credArgs, cleanup, err := ytDlpCredentialArgs(creds)
+	if err != nil {
+		return &VODDownloadResponse{
+			Success: false,
+			Message: err.Error(),
+		}, err
+	}
+	defer cleanup()
+
+	// 获取视频信息（标题与时长）
+	info, err := runYtDlp(ctx, append(append([]string{
+		"--skip-download", "--no-playlist", "--no-warnings",
+		"--print", "title",
+		"--print", "duration",
+	}, credArgs...), videoURL)...)
+	if err != nil {
+		return &VODDownloadResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to get video info: %v", err),
+		}, err
+	}
+	lines := strings.Split(strings.TrimSpace(info), "\n")
+	if len(lines) < 2 {
+		return &VODDownloadResponse{
+			Success: false,
+			Message: "Video not found or deleted",
+		}, fmt.Errorf("video not found")
+	}
+	title := strings.TrimSpace(lines[0])
+	duration, _ := strconv.ParseFloat(strings.TrimSpace(lines[1]), 64) // 直播中的录像为 NA
+
+	// 确定输出路径
+	outputDir := req.OutputPath
+	if outputDir == "" {
+		outputDir = vd.outputDir
+	}
+	os.MkdirAll(outputDir, 0755)
+
+	// 生成文件名，与 Twitch 录像相同；扩展名由 yt-dlp 决定（合并音视频时为 mp4，只下载音频时为 m4a 等）
+	basePath := filepath.Join(outputDir, fmt.Sprintf("%s_%s", pathsafe.Name(videoID), pathsafe.Unique(title)))
+	args := []string{
+		"-f", youtubeFormatSelector(req.Quality),
+		"--no-playlist", "--no-part", "--no-warnings", "--force-overwrites",
+		"--merge-output-format", "mp4",
+		"-o", basePath + ".%(ext)s",
+		"--print", "after_move:filepath",
+	}
+	if req.StartTime > 0 || req.EndTime > 0 {
+		end := "inf"
+		if req.EndTime > 0 {
+			end = fmt.Sprintf("%.2f", req.StartTime+req.EndTime)
+		}
+		args = append(args, "--download-sections", fmt.Sprintf("*%.2f-%s", req.StartTime, end))
+	}
+
+	log.Printf("使用 yt-dlp 下载 YouTube 录像 %s（%.0f 秒起，时长 %.0f 秒）", videoID, req.StartTime, req.EndTime)
+	release, err := acquireStageSlot(ctx, pipelineStageFFmpeg)
+	if err != nil {
+		return &VODDownloadResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to download video: %v", err),
+		}, err
+	}
+	reportJobProgressCtx(ctx, JobStageFFmpeg, 0, "yt-dlp 下载中")
+	output, err := runYtDlp(ctx, append(append(args, credArgs...), videoURL)...)
+	release()
+	if err != nil {
+		return &VODDownloadResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to download video: %v", err),
+		}, err
+	}
+	videoPath := lastLine(output)
+	if _, err := os.Stat(videoPath); err != nil {
+		return &VODDownloadResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to download video: output file not found: %s", videoPath),
+		}, fmt.Errorf("output file not found: %s", videoPath)
+	}
+	reportJobProgressCtx(ctx, JobStageFFmpeg, 100, "")
+
+	response := &VODDownloadResponse{
+		Success:      true,
+		Message:      "Video downloaded successfully",
+		VideoPath:    videoPath,
+		Duration:     duration,
+		DownloadTime: time.Since(startTime).Seconds(),
+	}
+	vd.extractAudioAndSubtitles(ctx, req, videoID, basePath, response)
+
+	return response, nil
+}
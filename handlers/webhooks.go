@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"subtuber-services/pathsafe"
+)
+
+// Webhook 推送的事件，对应配置 webhooks.targets[].events
+const (
+	webhookEventGoLive  = "go_live"
+	webhookEventOffline = "offline"
+	webhookEventSummary = "summary"
+)
+
+const (
+	webhookTypeDiscord  = "discord"
+	webhookTypeTelegram = "telegram"
+
+	webhookTimeout = 15 * time.Second
+
+	// Discord 嵌入消息与 Telegram 消息的长度上限
+	discordDescriptionMaxRunes = 4000
+	discordFieldMaxRunes       = 1000
+	discordMaxFields           = 25
+	telegramMessageMaxRunes    = 4000
+
+	// webhookMomentSummaryMaxRunes 每个热点在消息中展示的总结长度
+	webhookMomentSummaryMaxRunes = 300
+)
+
+// webhookMoment 消息中的一个热点时刻
+type webhookMoment struct {
+	OffsetSeconds float64
+	URL           string
+	Summary       string
+}
+
+// webhookMessage 推送到 Discord / Telegram 的一条消息
+type webhookMessage struct {
+	Event     string
+	Streamers []string // 用于匹配目标的主播标识（ID、登录名、频道 ID）
+	Title     string
+	URL       string
+	Text      string
+	Moments   []webhookMoment
+}
+
+// Validate checks that every webhook target has a supported type, its destination and known events
+func (c WebhooksConfig) Validate() error {
+	for i, t := range c.Targets {
+		switch strings.ToLower(t.Type) {
+		case webhookTypeDiscord:
+			if t.URL == "" {
+				return fmt.Errorf("第 %d 个 Webhook 未配置 Discord 地址 url", i+1)
+			}
+		case webhookTypeTelegram:
+			if t.BotToken == "" || t.ChatID == "" {
+				return fmt.Errorf("第 %d 个 Webhook 未配置 Telegram 的 bot_token 或 chat_id", i+1)
+			}
+		default:
+			return fmt.Errorf("第 %d 个 Webhook 的类型 %q 不受支持，可选 discord 或 telegram", i+1, t.Type)
+		}
+		for _, event := range t.Events {
+			switch event {
+			case webhookEventGoLive, webhookEventOffline, webhookEventSummary:
+			default:
+				return fmt.Errorf("第 %d 个 Webhook 的事件 %q 不受支持", i+1, event)
+			}
+		}
+	}
+	return nil
+}
+
+// wants 目标是否订阅了该事件与主播，未配置主播或事件时表示全部
+func (t WebhookTarget) wants(msg webhookMessage) bool {
+	if len(t.Events) > 0 && !slices.Contains(t.Events, msg.Event) {
+		return false
+	}
+	if len(t.Streamers) == 0 {
+		return true
+	}
+	for _, want := range t.Streamers {
+		for _, streamer := range msg.Streamers {
+			if streamer != "" && strings.EqualFold(want, streamer) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// label 日志中显示的目标名称
+func (t WebhookTarget) label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	if t.ChatID != "" {
+		return t.Type + ":" + t.ChatID
+	}
+	return t.Type
+}
+
+// postWebhooks 将消息推送到订阅了该事件与主播的所有目标，单个目标失败只记录日志
+func postWebhooks(msg webhookMessage) {
+	for _, target := range GetWebhooksConfig().Targets {
+		if !target.wants(msg) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		err := target.post(ctx, msg)
+		cancel()
+		if err != nil {
+			log.Printf("推送 %s 事件到 %s 失败: %v", msg.Event, target.label(), err)
+			continue
+		}
+		log.Printf("已推送 %s 事件到 %s", msg.Event, target.label())
+	}
+}
+
+// post 按目标类型格式化并发送消息
+func (t WebhookTarget) post(ctx context.Context, msg webhookMessage) error {
+	switch strings.ToLower(t.Type) {
+	case webhookTypeDiscord:
+		return postWebhookJSON(ctx, t.URL, discordPayload(msg))
+	case webhookTypeTelegram:
+		return postWebhookJSON(ctx, "https://api.telegram.org/bot"+t.BotToken+"/sendMessage", map[string]interface{}{
+			"chat_id":                  t.ChatID,
+			"text":                     telegramText(msg),
+			"disable_web_page_preview": len(msg.Moments) > 0,
+		})
+	default:
+		return fmt.Errorf("不支持的 Webhook 类型: %s", t.Type)
+	}
+}
+
+// discordPayload 生成 Discord 嵌入消息，每个热点时刻为一个字段
+func discordPayload(msg webhookMessage) map[string]interface{} {
+	embed := map[string]interface{}{
+		"title":       truncateWebhookText(msg.Title, 256),
+		"description": truncateWebhookText(msg.Text, discordDescriptionMaxRunes),
+		"timestamp":   time.Now().Format(time.RFC3339),
+	}
+	if msg.URL != "" {
+		embed["url"] = msg.URL
+	}
+	var fields []map[string]interface{}
+	for _, moment := range msg.Moments {
+		if len(fields) == discordMaxFields {
+			break
+		}
+		value := moment.Summary
+		if value == "" {
+			value = "（暂无总结）"
+		}
+		if moment.URL != "" {
+			value = truncateWebhookText(value, discordFieldMaxRunes-len(moment.URL)-4) + "\n" + moment.URL
+		}
+		fields = append(fields, map[string]interface{}{
+			"name":  "⏱ " + formatDuration(moment.OffsetSeconds),
+			"value": truncateWebhookText(value, discordFieldMaxRunes),
+		})
+	}
+	if len(fields) > 0 {
+		embed["fields"] = fields
+	}
+	return map[string]interface{}{"embeds": []interface{}{embed}}
+}
+
+// telegramText 生成 Telegram 纯文本消息
+func telegramText(msg webhookMessage) string {
+	var b strings.Builder
+	b.WriteString(msg.Title)
+	if msg.Text != "" {
+		b.WriteString("\n" + msg.Text)
+	}
+	if msg.URL != "" {
+		b.WriteString("\n" + msg.URL)
+	}
+	for _, moment := range msg.Moments {
+		b.WriteString("\n\n⏱ " + formatDuration(moment.OffsetSeconds))
+		if moment.URL != "" {
+			b.WriteString(" " + moment.URL)
+		}
+		if moment.Summary != "" {
+			b.WriteString("\n" + moment.Summary)
+		}
+	}
+	return truncateWebhookText(b.String(), telegramMessageMaxRunes)
+}
+
+// postWebhookJSON 以 JSON 发送请求，非 2xx 响应视为失败
+func postWebhookJSON(ctx context.Context, endpoint string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := newTracedHTTPClient(webhookTimeout).Do(req)
+	if err != nil {
+		// 错误信息中的地址包含 Webhook 密钥与机器人令牌，只输出底层错误
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// truncateWebhookText 按字符截断文本，超出时以省略号结尾
+func truncateWebhookText(s string, maxRunes int) string {
+	runes := []rune(strings.TrimSpace(s))
+	if maxRunes <= 0 || len(runes) <= maxRunes {
+		return string(runes)
+	}
+	return string(runes[:maxRunes-1]) + "…"
+}
+
+// videoMomentURL 录像中某个时刻的链接，偏移为 0 时为录像链接
+func videoMomentURL(platform, videoID string, offsetSeconds float64) string {
+	seconds := int(offsetSeconds)
+	if platform == "youtube" {
+		if seconds <= 0 {
+			return "https://www.youtube.com/watch?v=" + videoID
+		}
+		return fmt.Sprintf("https://www.youtube.com/watch?v=%s&t=%ds", videoID, seconds)
+	}
+	if seconds <= 0 {
+		return "https://www.twitch.tv/videos/" + videoID
+	}
+	return fmt.Sprintf("https://www.twitch.tv/videos/%s?t=%dh%dm%ds", videoID, seconds/3600, seconds/60%60, seconds%60)
+}
+
+// postGoLiveWebhooks 主播开播时推送
+func postGoLiveWebhooks(streamers []string, name, title, link string) {
+	postWebhooks(webhookMessage{
+		Event:     webhookEventGoLive,
+		Streamers: streamers,
+		Title:     fmt.Sprintf("🔴 %s 开始直播了", name),
+		URL:       link,
+		Text:      title,
+	})
+}
+
+// postOfflineWebhooks 主播下播时推送
+func postOfflineWebhooks(streamers []string, name, link string) {
+	postWebhooks(webhookMessage{
+		Event:     webhookEventOffline,
+		Streamers: streamers,
+		Title:     fmt.Sprintf("⚫ %s 已下播", name),
+		URL:       link,
+		Text:      "录像处理完成后会推送热点时刻与AI总结",
+	})
+}
+
+// postSummaryWebhooks 录像的热点AI总结完成后推送标题、热点时间点与总结
+func postSummaryWebhooks(videoID string, plan PipelinePlan, hotMoments []VodCommentData) {
+	videoDir := pathsafe.Join("./analysis_results", videoID)
+	moments := make([]webhookMoment, 0, len(hotMoments))
+	for _, hotMoment := range hotMoments {
+		moments = append(moments, webhookMoment{
+			OffsetSeconds: hotMoment.OffsetSeconds,
+			URL:           videoMomentURL(plan.Facts.Platform, videoID, hotMoment.OffsetSeconds),
+			Summary:       truncateWebhookText(readSummaryForOffset(videoDir, hotMoment.OffsetSeconds), webhookMomentSummaryMaxRunes),
+		})
+	}
+
+	streamers, name := []string{plan.Facts.Streamer}, plan.Facts.Streamer
+	if streamer := ResolveStreamer(plan.Facts.Streamer); streamer != nil {
+		streamers, name = append(streamers, streamer.ID, streamer.Name), streamer.Name
+	}
+	title := plan.Facts.Title
+	if title == "" {
+		title = videoID
+	}
+	postWebhooks(webhookMessage{
+		Event:     webhookEventSummary,
+		Streamers: streamers,
+		Title:     "📝 " + title,
+		URL:       videoMomentURL(plan.Facts.Platform, videoID, 0),
+		Text:      fmt.Sprintf("%s 的录像AI总结已完成，共 %d 个热点时刻", name, len(moments)),
+		Moments:   moments,
+	})
+}
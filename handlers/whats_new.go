@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"subtuber-services/pathsafe"
+	"subtuber-services/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// whatsNewItemLimit 每个订阅每类最多返回的条目数，超出部分只计入总数
+	whatsNewItemLimit = 50
+	// whatsNewDefaultWindow 没有 lastSeen 记录且订阅时间未知时回看的时长
+	whatsNewDefaultWindow = 7 * 24 * time.Hour
+)
+
+// WhatsNewAnalysis 上次访问后新完成的录像分析
+type WhatsNewAnalysis struct {
+	VideoID        string    `json:"video_id"`
+	Title          string    `json:"title"`
+	URL            string    `json:"url,omitempty"`
+	CreatedAt      string    `json:"created_at,omitempty"`
+	AnalyzedAt     time.Time `json:"analyzed_at"`
+	HotMomentCount int       `json:"hot_moment_count"`
+}
+
+// WhatsNewHotMoment 新分析中的热点时刻
+type WhatsNewHotMoment struct {
+	VideoID       string  `json:"video_id"`
+	OffsetSeconds float64 `json:"offset_seconds"`
+	FormattedTime string  `json:"formatted_time"`
+	Score         float64 `json:"score"`
+}
+
+// WhatsNewSummary 上次访问后新生成的热点AI总结
+type WhatsNewSummary struct {
+	VideoID       string    `json:"video_id"`
+	OffsetSeconds float64   `json:"offset_seconds"`
+	Summary       string    `json:"summary"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// WhatsNewSubscription 单个订阅主播自上次访问以来的新内容
+type WhatsNewSubscription struct {
+	StreamerID   string              `json:"streamer_id"`
+	StreamerName string              `json:"streamer_name"`
+	Since        time.Time           `json:"since"`
+	Analyses     []WhatsNewAnalysis  `json:"analyses"`
+	HotMoments   []WhatsNewHotMoment `json:"hot_moments"`
+	Summaries    []WhatsNewSummary   `json:"summaries"`
+	Total        int                 `json:"total"` // 三类新内容的总数（含超出返回上限的部分）
+}
+
+// whatsNewSince 用户对主播的 lastSeen 时间，没有记录时使用订阅时间，都没有时回看 whatsNewDefaultWindow
+func whatsNewSince(userHash, streamerID, subscribedAt string, now time.Time) time.Time {
+	if v, ok, err := GetUserLastSeen(userHash, streamerID); err == nil && ok {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, subscribedAt); err == nil {
+		return t
+	}
+	return now.Add(-whatsNewDefaultWindow)
+}
+
+// newSummariesSince 录像目录中晚于 since 生成的AI总结（文件名为 {offset}_summary.txt），已下架的总结不返回
+func newSummariesSince(videoID string, since time.Time) []WhatsNewSummary {
+	files, err := filepath.Glob(filepath.Join(pathsafe.Join("./analysis_results", videoID), "*_summary.txt"))
+	if err != nil {
+		return nil
+	}
+
+	var summaries []WhatsNewSummary
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().After(since) {
+			continue
+		}
+		offset, err := strconv.ParseFloat(strings.Split(filepath.Base(file), "_")[0], 64)
+		if err != nil || IsTakenDown(ArtifactSummary, videoID, offset) {
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		summaries = append(summaries, WhatsNewSummary{
+			VideoID:       videoID,
+			OffsetSeconds: offset,
+			Summary:       strings.TrimSpace(string(content)),
+			CreatedAt:     info.ModTime(),
+		})
+	}
+	return summaries
+}
+
+// buildWhatsNew 汇总主播自 since 以来新完成的分析、其中的热点时刻，以及新生成的AI总结
+// 主播认领主页后隐藏或待审核的热点与已下架的片段不返回
+func buildWhatsNew(streamerID, streamerName string, since time.Time) WhatsNewSubscription {
+	entry := WhatsNewSubscription{
+		StreamerID:   streamerID,
+		StreamerName: streamerName,
+		Since:        since,
+		Analyses:     []WhatsNewAnalysis{},
+		HotMoments:   []WhatsNewHotMoment{},
+		Summaries:    []WhatsNewSummary{},
+	}
+
+	claim := GetStreamerClaim(streamerID)
+	for _, result := range loadStreamerAnalysisResults(streamerID) {
+		// 热点总结通常晚于分析完成，早于 since 的分析仍可能有新总结
+		entry.Summaries = append(entry.Summaries, newSummariesSince(result.VideoID, since)...)
+		if !result.AnalyzedAt.After(since) {
+			continue
+		}
+
+		moments := result.HotMoments
+		if claim != nil {
+			moments = claim.visibleMoments(result.VideoID, moments)
+		}
+		moments = withoutTakenDownClips(result.VideoID, moments)
+
+		entry.Analyses = append(entry.Analyses, WhatsNewAnalysis{
+			VideoID:        result.VideoID,
+			Title:          result.VideoInfo.Title,
+			URL:            result.VideoInfo.URL,
+			CreatedAt:      result.VideoInfo.CreatedAt,
+			AnalyzedAt:     result.AnalyzedAt,
+			HotMomentCount: len(moments),
+		})
+		for _, moment := range moments {
+			entry.HotMoments = append(entry.HotMoments, WhatsNewHotMoment{
+				VideoID:       result.VideoID,
+				OffsetSeconds: moment.OffsetSeconds,
+				FormattedTime: moment.FormattedTime,
+				Score:         math.Round(moment.CommentsScore*100) / 100,
+			})
+		}
+	}
+
+	entry.Total = len(entry.Analyses) + len(entry.HotMoments) + len(entry.Summaries)
+
+	sort.SliceStable(entry.HotMoments, func(i, j int) bool {
+		return entry.HotMoments[i].Score > entry.HotMoments[j].Score
+	})
+	sort.Slice(entry.Summaries, func(i, j int) bool {
+		return entry.Summaries[i].CreatedAt.After(entry.Summaries[j].CreatedAt)
+	})
+	if len(entry.Analyses) > whatsNewItemLimit {
+		entry.Analyses = entry.Analyses[:whatsNewItemLimit]
+	}
+	if len(entry.HotMoments) > whatsNewItemLimit {
+		entry.HotMoments = entry.HotMoments[:whatsNewItemLimit]
+	}
+	if len(entry.Summaries) > whatsNewItemLimit {
+		entry.Summaries = entry.Summaries[:whatsNewItemLimit]
+	}
+	return entry
+}
+
+// GetWhatsNew 获取用户各订阅主播自上次访问以来新完成的分析、新热点与新AI总结
+// GET /api/user/whats-new
+func GetWhatsNew(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "未登录或登录已过期",
+		})
+		return
+	}
+
+	resp, err := services.GetUserSubscriptions(userHash)
+	if err != nil {
+		log.Printf("获取用户订阅列表失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取订阅列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	subscriptions := []WhatsNewSubscription{}
+	total := 0
+	for _, sub := range resp.Subscriptions {
+		name := sub.StreamerId
+		if streamer := ResolveStreamer(sub.StreamerId); streamer != nil {
+			name = streamer.Name
+		}
+		entry := buildWhatsNew(sub.StreamerId, name, whatsNewSince(userHash, sub.StreamerId, sub.CreatedAt, now))
+		total += entry.Total
+		subscriptions = append(subscriptions, entry)
+	}
+
+	// 有新内容的主播在前
+	sort.SliceStable(subscriptions, func(i, j int) bool {
+		return subscriptions[i].Total > subscriptions[j].Total
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"subscriptions": subscriptions,
+		"total":         total,
+		"generated_at":  now.UTC().Format(time.RFC3339),
+	})
+}
+
+// AcknowledgeWhatsNew 用户确认已查看新内容，将 lastSeen 前移
+// POST /api/user/whats-new/ack，streamer_ids 为空时确认所有订阅；until 通常为 GET 返回的 generated_at，
+// 避免确认在查看之后才出现的内容，为空时使用当前时间
+func AcknowledgeWhatsNew(c *gin.Context) {
+	userHash, err := getUserHashFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "未登录或登录已过期",
+		})
+		return
+	}
+
+	var req struct {
+		StreamerIDs []string `json:"streamer_ids"`
+		Until       string   `json:"until"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	until := time.Now()
+	if req.Until != "" {
+		t, err := time.Parse(time.RFC3339, req.Until)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "until 必须为 RFC3339 时间",
+			})
+			return
+		}
+		if t.Before(until) {
+			until = t
+		}
+	}
+
+	if len(req.StreamerIDs) == 0 {
+		resp, err := services.GetUserSubscriptions(userHash)
+		if err != nil {
+			log.Printf("获取用户订阅列表失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "获取订阅列表失败: " + err.Error(),
+			})
+			return
+		}
+		for _, sub := range resp.Subscriptions {
+			req.StreamerIDs = append(req.StreamerIDs, sub.StreamerId)
+		}
+	}
+
+	markers := make(map[string]time.Time, len(req.StreamerIDs))
+	for _, streamerID := range req.StreamerIDs {
+		markers[streamerID] = until
+	}
+	if err := AdvanceUserLastSeen(userHash, markers); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "保存查看记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"acknowledged": len(markers),
+		"until":        until.UTC().Format(time.RFC3339),
+	})
+}
@@ -3,10 +3,12 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,6 +18,7 @@ import (
 	"time"
 
 	"subtuber-services/models"
+	"subtuber-services/pathsafe"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -27,6 +30,67 @@ type YouTubeConfig struct {
 	MaxIntervalSeconds    int      `mapstructure:"max_interval_seconds" json:"max_interval_seconds"`
 	ReloadIntervalMinutes int      `mapstructure:"reload_interval_minutes" json:"reload_interval_minutes"`
 	Referer               string   `mapstructure:"referer" json:"referer"`
+	// OfflineConfirmations 直播中连续多少次检查为离线才判定下播
+	OfflineConfirmations int `mapstructure:"offline_confirmations" json:"offline_confirmations"`
+	// StreamEndGraceSeconds 判定下播后等待多久才开始下播处理，期间恢复直播则取消
+	StreamEndGraceSeconds int `mapstructure:"stream_end_grace_seconds" json:"stream_end_grace_seconds"`
+	// DailyQuota 每个 API Key 每天的配额单位
+	DailyQuota int `mapstructure:"daily_quota" json:"daily_quota"`
+	// QuotaReservePercent 为低消耗接口保留的配额百分比，只剩保留部分时拒绝 search 等高消耗调用并放慢监控检查
+	QuotaReservePercent int `mapstructure:"quota_reserve_percent" json:"quota_reserve_percent"`
+	// LiveCheck 直播检查方式：uploads（上传列表，默认）、scrape（频道 /live 页面）或 search（search 接口，每次 100 单位）
+	LiveCheck string `mapstructure:"live_check" json:"live_check"`
+}
+
+// applyDefaults 为未设置的检查间隔填充默认值
+func (c *YouTubeConfig) applyDefaults() {
+	if c.MinIntervalSeconds == 0 {
+		c.MinIntervalSeconds = 30
+	}
+	if c.MaxIntervalSeconds == 0 {
+		c.MaxIntervalSeconds = 120
+	}
+	if c.ReloadIntervalMinutes == 0 {
+		c.ReloadIntervalMinutes = 10
+	}
+	if c.OfflineConfirmations == 0 {
+		c.OfflineConfirmations = defaultOfflineConfirmations
+	}
+	if c.StreamEndGraceSeconds == 0 {
+		c.StreamEndGraceSeconds = defaultStreamEndGraceSeconds
+	}
+	if c.DailyQuota == 0 {
+		c.DailyQuota = defaultYouTubeDailyQuota
+	}
+	if c.QuotaReservePercent == 0 {
+		c.QuotaReservePercent = defaultYouTubeQuotaReserve
+	}
+	if c.LiveCheck == "" {
+		c.LiveCheck = youtubeLiveCheckUploads
+	}
+}
+
+// Validate 校验检查间隔配置
+func (c YouTubeConfig) Validate() error {
+	if c.MinIntervalSeconds < 0 || c.MaxIntervalSeconds < 0 || c.ReloadIntervalMinutes < 0 {
+		return fmt.Errorf("youtube 检查间隔不能为负数")
+	}
+	if c.MaxIntervalSeconds != 0 && c.MinIntervalSeconds > c.MaxIntervalSeconds {
+		return fmt.Errorf("youtube 最小检查间隔(%d)不能大于最大检查间隔(%d)", c.MinIntervalSeconds, c.MaxIntervalSeconds)
+	}
+	if c.OfflineConfirmations < 0 || c.StreamEndGraceSeconds < 0 {
+		return fmt.Errorf("youtube 下播确认次数与宽限期不能为负数")
+	}
+	if c.DailyQuota < 0 {
+		return fmt.Errorf("youtube 每日配额不能为负数")
+	}
+	if c.QuotaReservePercent < 0 || c.QuotaReservePercent >= 100 {
+		return fmt.Errorf("youtube 保留配额比例必须在 0 到 99 之间")
+	}
+	if !validYouTubeLiveCheck(c.LiveCheck) {
+		return fmt.Errorf("youtube 直播检查方式只能是 uploads、scrape 或 search: %s", c.LiveCheck)
+	}
+	return nil
 }
 
 // YouTubeMonitor YouTube监控服务
@@ -37,12 +101,16 @@ type YouTubeMonitor struct {
 	mu              sync.RWMutex
 	stopChan        chan struct{}
 	lastReloadTime  time.Time
-	currentKeyIndex int        // 当前使用的API Key索引
-	apiKeyMu        sync.Mutex // API Key索引的互斥锁
+	schedule        *monitorSchedule // 各频道的下次检查时间
+	currentKeyIndex int              // 当前使用的API Key索引
+	apiKeyMu        sync.Mutex       // API Key索引的互斥锁
 }
 
 const (
 	ContinuationPrefix = "https://www.youtube.com/live_chat_replay?continuation="
+
+	// youtubeSubtitleDownloadEnabled 是否启用 yt-dlp 字幕下载（目前问题较多，暂时禁用）
+	youtubeSubtitleDownloadEnabled = false
 )
 
 var (
@@ -57,6 +125,7 @@ func InitYouTubeMonitor(config YouTubeConfig) *YouTubeMonitor {
 			config:          config,
 			channelStatus:   make(map[string]*models.YouTubeStatusResponse),
 			stopChan:        make(chan struct{}),
+			schedule:        newMonitorSchedule(),
 			currentKeyIndex: 0,
 		}
 
@@ -68,15 +137,8 @@ func InitYouTubeMonitor(config YouTubeConfig) *YouTubeMonitor {
 		}
 
 		// 设置默认值
-		if youtubeMonitor.config.MinIntervalSeconds == 0 {
-			youtubeMonitor.config.MinIntervalSeconds = 30
-		}
-		if youtubeMonitor.config.MaxIntervalSeconds == 0 {
-			youtubeMonitor.config.MaxIntervalSeconds = 120
-		}
-		if youtubeMonitor.config.ReloadIntervalMinutes == 0 {
-			youtubeMonitor.config.ReloadIntervalMinutes = 10
-		}
+		youtubeMonitor.config.applyDefaults()
+		youtubeQuota.configure(youtubeMonitor.config.DailyQuota, youtubeMonitor.config.QuotaReservePercent)
 
 		// 加载频道列表
 		if err := youtubeMonitor.loadChannels(); err != nil {
@@ -132,6 +194,9 @@ func (ym *YouTubeMonitor) makeRequestWithRetry(url string) (*http.Response, erro
 	}
 
 	var lastErr error
+	quotaErrors := 0
+	budgetRefusals := 0
+	endpoint, cost := youtubeCallCost(url)
 
 	for i := 0; i < maxRetries; i++ {
 		apiKey := ym.getCurrentAPIKey()
@@ -139,6 +204,13 @@ func (ym *YouTubeMonitor) makeRequestWithRetry(url string) (*http.Response, erro
 			return nil, fmt.Errorf("无可用的API Key")
 		}
 
+		// 该 Key 当天的剩余配额不足时不发出请求，换下一个Key
+		if !youtubeQuota.reserve(apiKey, endpoint, cost) {
+			budgetRefusals++
+			ym.rotateAPIKey()
+			continue
+		}
+
 		// 在URL中添加API Key
 		fullURL := url
 		if strings.Contains(url, "?") {
@@ -154,7 +226,7 @@ func (ym *YouTubeMonitor) makeRequestWithRetry(url string) (*http.Response, erro
 		}
 		req.Header.Set("Referer", ym.config.Referer)
 
-		client := &http.Client{Timeout: 10 * time.Second}
+		client := newTracedHTTPClient(10 * time.Second)
 		resp, err := client.Do(req)
 		if err != nil {
 			lastErr = err
@@ -164,6 +236,7 @@ func (ym *YouTubeMonitor) makeRequestWithRetry(url string) (*http.Response, erro
 
 		// 检查响应状态
 		if resp.StatusCode == http.StatusOK {
+			clearYouTubeQuotaExhausted()
 			return resp, nil
 		}
 
@@ -173,6 +246,10 @@ func (ym *YouTubeMonitor) makeRequestWithRetry(url string) (*http.Response, erro
 			resp.Body.Close()
 			log.Printf("API Key配额可能已用尽 (状态码: %d)，尝试下一个Key", resp.StatusCode)
 			lastErr = fmt.Errorf("API返回错误状态 %d: %s", resp.StatusCode, string(body))
+			quotaErrors++
+			if isYouTubeQuotaExceeded(string(body)) {
+				youtubeQuota.markExhausted(apiKey)
+			}
 			ym.rotateAPIKey()
 			time.Sleep(500 * time.Millisecond) // 短暂延迟
 			continue
@@ -182,6 +259,12 @@ func (ym *YouTubeMonitor) makeRequestWithRetry(url string) (*http.Response, erro
 		return resp, nil
 	}
 
+	if quotaErrors == maxRetries {
+		markYouTubeQuotaExhausted()
+	}
+	if budgetRefusals == maxRetries {
+		return nil, youtubeQuotaBudgetError(endpoint, cost)
+	}
 	return nil, fmt.Errorf("所有API Keys都失败了: %v", lastErr)
 }
 
@@ -210,6 +293,14 @@ func (ym *YouTubeMonitor) shouldReloadChannels() bool {
 	return time.Since(ym.lastReloadTime) >= reloadInterval
 }
 
+// reloadInterval 获取当前配置的频道列表重新加载间隔
+func (ym *YouTubeMonitor) reloadInterval() time.Duration {
+	ym.mu.RLock()
+	defer ym.mu.RUnlock()
+
+	return time.Duration(ym.config.ReloadIntervalMinutes) * time.Minute
+}
+
 // Start 启动监控服务
 func (ym *YouTubeMonitor) Start() {
 	go ym.monitorLoop()
@@ -226,10 +317,10 @@ func (ym *YouTubeMonitor) monitorLoop() {
 	// 初始化时立即检查一次所有频道
 	ym.checkAllChannels()
 
-	ticker := time.NewTicker(time.Duration(ym.getRandomInterval()) * time.Second)
+	ticker := time.NewTicker(ym.schedule.wait(ym.nextCheckInterval(), time.Now()))
 	defer ticker.Stop()
 
-	reloadTicker := time.NewTicker(time.Duration(ym.config.ReloadIntervalMinutes) * time.Minute)
+	reloadTicker := time.NewTicker(ym.reloadInterval())
 	defer reloadTicker.Stop()
 
 	for {
@@ -239,8 +330,8 @@ func (ym *YouTubeMonitor) monitorLoop() {
 			return
 		case <-ticker.C:
 			ym.checkAllChannels()
-			// 重置为新的随机间隔
-			ticker.Reset(time.Duration(ym.getRandomInterval()) * time.Second)
+			// 重置为新的随机间隔，有频道更早到期时提前检查
+			ticker.Reset(ym.schedule.wait(ym.nextCheckInterval(), time.Now()))
 		case <-reloadTicker.C:
 			if ym.shouldReloadChannels() {
 				if err := ym.loadChannels(); err != nil {
@@ -249,12 +340,35 @@ func (ym *YouTubeMonitor) monitorLoop() {
 					log.Println("已重新加载YouTube频道列表")
 				}
 			}
+			// 重新加载间隔可能已通过配置热加载修改
+			reloadTicker.Reset(ym.reloadInterval())
 		}
 	}
 }
 
+// nextCheckInterval 下一次检查的间隔，配额只剩保留部分时放慢检查，把剩余配额留给录像处理
+func (ym *YouTubeMonitor) nextCheckInterval() time.Duration {
+	interval := time.Duration(ym.getRandomInterval()) * time.Second
+	if youtubeQuota.nearCap(ym.config.APIKeys) {
+		interval *= youtubeQuotaSlowdownFactor
+		log.Printf("YouTube API 配额接近上限，下次检查推迟到 %s 后", interval)
+	}
+	return interval
+}
+
+// isChannelLive 频道最近一次检查是否在直播
+func (ym *YouTubeMonitor) isChannelLive(streamerID string) bool {
+	ym.mu.RLock()
+	defer ym.mu.RUnlock()
+	status, ok := ym.channelStatus[streamerID]
+	return ok && status.IsLive
+}
+
 // getRandomInterval 获取随机检查间隔
 func (ym *YouTubeMonitor) getRandomInterval() int {
+	ym.mu.RLock()
+	defer ym.mu.RUnlock()
+
 	min := ym.config.MinIntervalSeconds
 	max := ym.config.MaxIntervalSeconds
 	if min >= max {
@@ -265,16 +379,35 @@ func (ym *YouTubeMonitor) getRandomInterval() int {
 
 // checkAllChannels 检查所有频道的状态
 func (ym *YouTubeMonitor) checkAllChannels() {
+	if !IsFeatureEnabled(FeatureYouTubeMonitoring) {
+		log.Println("YouTube监控已通过功能开关禁用，跳过本次检查")
+		return
+	}
+
 	ym.mu.RLock()
 	channels := make([]models.StreamerInfo, len(ym.channels))
 	copy(channels, ym.channels)
 	ym.mu.RUnlock()
 
-	log.Printf("开始检查 %d 个YouTube频道的直播状态", len(channels))
+	// 只检查已到检查时间的频道，间隔由各主播的监控计划决定
+	due := ym.schedule.due(channels, time.Now())
+	if len(due) == 0 {
+		return
+	}
+	log.Printf("开始检查 %d 个YouTube频道的直播状态 (共%d个)", len(due), len(channels))
+
+	// 配额接近上限时固定间隔的频道同样放慢
+	slowdown := youtubeQuota.nearCap(ym.config.APIKeys)
 
 	// 逐个检查频道状态
-	for _, channel := range channels {
+	for _, channel := range due {
 		ym.checkChannelStatus(channel)
+		base := time.Duration(ym.getRandomInterval()) * time.Second
+		interval := streamerCheckInterval(channel, base, ym.isChannelLive(channel.ID), time.Now())
+		if slowdown {
+			interval *= youtubeQuotaSlowdownFactor
+		}
+		ym.schedule.checked(channel.ID, interval, time.Now())
 		// 避免请求过快
 		time.Sleep(500 * time.Millisecond)
 	}
@@ -295,23 +428,22 @@ func (ym *YouTubeMonitor) checkChannelStatus(channel models.StreamerInfo) {
 			}
 
 			// 从URL中提取频道ID或用户名
-			parts := strings.Split(platform.URL, "/")
-			if len(parts) > 0 {
-				lastPart := parts[len(parts)-1]
-
-				// 如果是 @username 格式或不是UC开头的频道ID格式
-				if strings.HasPrefix(lastPart, "@") {
-					// 通过用户名获取频道ID并保存
-					channelID, err := ym.getChannelIDByUsernameAndCache(channel.ID, lastPart)
-					if err != nil {
-						log.Printf("获取频道ID失败 (%s): %v", lastPart, err)
-						return
-					}
-					youtubeChannelID = channelID
-				} else {
-					// 已经是频道ID格式
-					youtubeChannelID = lastPart
+			account := platformURLHandle(platform.URL)
+
+			// 如果是 @username 格式或不是UC开头的频道ID格式
+			if strings.HasPrefix(account, "@") {
+				// 通过用户名获取频道ID并保存
+				channelID, err := ym.getChannelIDByUsernameAndCache(channel.ID, account)
+				if err != nil {
+					log.Printf("获取频道ID失败 (%s): %v", account, err)
+					recordStreamerIssue(channel.ID, diagStageResolve, "", err)
+					return
 				}
+				youtubeChannelID = channelID
+				clearStreamerIssue(channel.ID, diagStageResolve)
+			} else {
+				// 已经是频道ID格式
+				youtubeChannelID = account
 			}
 			break
 		}
@@ -340,14 +472,26 @@ func (ym *YouTubeMonitor) checkChannelStatus(channel models.StreamerInfo) {
 	stream, err := ym.CheckLiveStatusByChannelID(youtubeChannelID)
 	if err != nil {
 		log.Printf("检查频道 %s 直播状态失败: %v", channel.Name, err)
+		recordStreamerIssue(channel.ID, diagStageLiveCheck, "", err)
 		return
 	}
+	clearStreamerIssue(channel.ID, diagStageLiveCheck)
 
 	// 获取之前的状态
 	ym.mu.RLock()
 	prevStatus, existed := ym.channelStatus[channel.ID]
+	confirmations := ym.config.OfflineConfirmations
+	graceSeconds := ym.config.StreamEndGraceSeconds
 	ym.mu.RUnlock()
 
+	// 直播中的频道需连续多次检查为离线才判定下播，避免单次检查异常触发下播处理
+	if stream == nil && existed && prevStatus.IsLive {
+		if streak, confirmed := streamEnds.observeOffline("youtube", channel.ID, confirmations); !confirmed {
+			log.Printf("⚠️ %s 检查为未直播 (%d/%d)，等待再次确认后判定下播", channel.Name, streak, confirmations)
+			return
+		}
+	}
+
 	// 更新状态
 	newStatus := &models.YouTubeStatusResponse{
 		IsLive:       stream != nil,
@@ -362,11 +506,20 @@ func (ym *YouTubeMonitor) checkChannelStatus(channel models.StreamerInfo) {
 
 	if stream != nil {
 		log.Printf("✅ %s 正在直播: %s (观众: %s)", channel.Name, stream.Title, stream.ViewerCount)
+		RecordStreamerDisplayName(channel.ID, stream.ChannelTitle)
+		resumed := streamEnds.observeLive("youtube", channel.ID)
 
 		// 检测从离线到直播的状态变化
 		if !existed || !prevStatus.IsLive {
 			log.Printf("🎉 %s 开始直播了！", channel.Name)
-			// 这里可以添加通知逻辑
+			// 服务启动后的首次检查与宽限期内恢复直播不发送开播通知
+			if existed && !resumed {
+				go NotifySubscribers(channel.ID, NotifyEventGoLive,
+					fmt.Sprintf("%s 开始直播了", channel.Name),
+					fmt.Sprintf("%s\nhttps://www.youtube.com/watch?v=%s", stream.Title, stream.ID))
+				go postGoLiveWebhooks([]string{channel.ID, channel.Name, youtubeChannelID}, channel.Name,
+					stream.Title, "https://www.youtube.com/watch?v="+stream.ID)
+			}
 		}
 	} else {
 		log.Printf("💤 %s 当前未直播", channel.Name)
@@ -374,11 +527,19 @@ func (ym *YouTubeMonitor) checkChannelStatus(channel models.StreamerInfo) {
 		// 检测从直播状态变为离线状态
 		if existed && prevStatus.IsLive {
 			log.Printf("📴 %s 已下播", channel.Name)
-			// 主播下播后，自动下载最近的VOD
-			go func() {
-				log.Printf("开始处理 %s 的最近VOD...", channel.Name)
-				ym.ProcessRecentVOD(youtubeChannelID, channel.Name)
-			}()
+			go postOfflineWebhooks([]string{channel.ID, channel.Name, youtubeChannelID}, channel.Name,
+				"https://www.youtube.com/channel/"+youtubeChannelID)
+			invalidateVideoListCache("youtube", youtubeChannelID)
+			pipelineSLO.markStreamEnded("youtube", youtubeChannelID, time.Now())
+			// 主播下播后，自动下载最近的VOD（持久化，重启后继续；宽限期后加入队列，积压时按调度策略排队）
+			job := PersistedJob{
+				Stage:        persistedStageStreamEnded,
+				Platform:     "youtube",
+				StreamerID:   channel.ID,
+				Streamer:     youtubeChannelID,
+				StreamerName: channel.Name,
+			}
+			streamEnds.scheduleEnd(job, graceSeconds, func() { ym.runStreamEndedJob(job) })
 		}
 	}
 }
@@ -390,8 +551,16 @@ func (ym *YouTubeMonitor) getChannelIDByUsername(username string) (string, error
 		username = "@" + username
 	}
 
-	// 方法 A: 使用 search 接口通过 Handle 查询频道
-	// 这是目前推荐的方法，因为 forUsername 只适用于旧版
+	// 优先使用 channels 接口的 forHandle 精确查询（1 单位），找不到时再用 search 接口（100 单位）模糊查询
+	resolved, err := ym.ResolveYouTubeChannel(username)
+	if err == nil {
+		return resolved.ChannelID, nil
+	}
+	if !errors.Is(err, ErrYouTubeChannelNotFound) && !errors.Is(err, ErrInvalidYouTubeHandle) {
+		return "", err
+	}
+
+	// 使用 search 接口通过 Handle 查询频道
 	searchURL := fmt.Sprintf("https://www.googleapis.com/youtube/v3/search?part=snippet&q=%s&type=channel",
 		username)
 
@@ -490,66 +659,9 @@ func (ym *YouTubeMonitor) updateStreamerChannelID(streamerID, newChannelID, user
 	return nil
 }
 
-// CheckLiveStatusByChannelID 根据频道ID检查直播状态
-func (ym *YouTubeMonitor) CheckLiveStatusByChannelID(channelID string) (*models.YouTubeStreamData, error) {
-	// 搜索该频道的直播视频
-	searchURL := fmt.Sprintf("https://www.googleapis.com/youtube/v3/search?part=snippet&channelId=%s&eventType=live&type=video",
-		channelID)
-
-	resp, err := ym.makeRequestWithRetry(searchURL)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API返回错误状态 %d: %s", resp.StatusCode, string(body))
-	}
-
-	var searchResp models.YouTubeSearchResponse
-	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
-		return nil, err
-	}
-
-	// 如果没有直播，返回nil
-	if len(searchResp.Items) == 0 {
-		return nil, nil
-	}
-
-	// 获取第一个直播视频的详细信息
-	videoID := searchResp.Items[0].ID.VideoID
-	videoURL := fmt.Sprintf("https://www.googleapis.com/youtube/v3/videos?part=snippet,liveStreamingDetails&id=%s",
-		videoID)
-
-	videoResp, err := ym.makeRequestWithRetry(videoURL)
-	if err != nil {
-		return nil, err
-	}
-	defer videoResp.Body.Close()
-
-	if videoResp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(videoResp.Body)
-		return nil, fmt.Errorf("API返回错误状态 %d: %s", videoResp.StatusCode, string(body))
-	}
-
-	var videoData models.YouTubeVideoResponse
-	if err := json.NewDecoder(videoResp.Body).Decode(&videoData); err != nil {
-		return nil, err
-	}
-
-	if len(videoData.Items) == 0 {
-		return nil, nil
-	}
-
-	item := videoData.Items[0]
-
-	// 检查LiveStreamingDetails是否存在
-	if item.LiveStreamingDetails == nil {
-		return nil, nil
-	}
-
-	stream := &models.YouTubeStreamData{
+// youtubeStreamData 将视频详情转换为直播数据
+func youtubeStreamData(item models.YouTubeVideoItem) *models.YouTubeStreamData {
+	return &models.YouTubeStreamData{
 		ID:             item.ID,
 		ChannelID:      item.Snippet.ChannelID,
 		ChannelTitle:   item.Snippet.ChannelTitle,
@@ -561,7 +673,6 @@ func (ym *YouTubeMonitor) CheckLiveStatusByChannelID(channelID string) (*models.
 		ScheduledStart: item.LiveStreamingDetails.ScheduledStartTime,
 	}
 
-	return stream, nil
 }
 
 // GetLatestStatus 获取最新的直播状态（返回所有频道的状态）
@@ -695,12 +806,32 @@ func (ym *YouTubeMonitor) updateChannelProfileImage(channelID, channelName, imag
 	return nil
 }
 
-// getVideos 获取频道的视频列表（VOD）
+// getVideos 获取频道的视频列表（VOD），结果短时间缓存
 func (ym *YouTubeMonitor) getVideos(channelID string, maxResults int) ([]models.YouTubeVideoItem, error) {
 	if maxResults <= 0 {
 		maxResults = 1 // 默认获取1个视频
 	}
 
+	key := videoListCacheKey(channelID, fmt.Sprintf("%d", maxResults))
+	return cachedVideoMetadata("youtube", key, videoListCacheTTL, func() ([]models.YouTubeVideoItem, error) {
+		return ym.fetchVideos(channelID, maxResults)
+	})
+}
+
+// fetchVideos 通过 Data API 获取频道最近的视频及其详细信息
+func (ym *YouTubeMonitor) fetchVideos(channelID string, maxResults int) ([]models.YouTubeVideoItem, error) {
+	// 上传列表同样按发布时间倒序，每页只消耗 2 单位，search 接口需要 101 单位
+	if strings.HasPrefix(channelID, "UC") {
+		page, err := ym.fetchUploads(channelID, maxResults, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Videos) == 0 {
+			return nil, fmt.Errorf("未找到视频")
+		}
+		return page.Videos, nil
+	}
+
 	// 搜索该频道最近的视频，按发布时间倒序排列
 	searchURL := fmt.Sprintf("https://www.googleapis.com/youtube/v3/search?part=snippet&channelId=%s&order=date&type=video&maxResults=%d",
 		channelID, maxResults)
@@ -753,6 +884,89 @@ func (ym *YouTubeMonitor) getVideos(channelID string, maxResults int) ([]models.
 	return videoData.Items, nil
 }
 
+// YouTubeUploadsPage 频道上传列表的一页
+type YouTubeUploadsPage struct {
+	Videos        []models.YouTubeVideoItem `json:"videos"`
+	NextPageToken string                    `json:"next_page_token,omitempty"`
+	TotalResults  int                       `json:"total_results"`
+}
+
+// getUploads 分页获取频道上传的视频（含直播录像），结果短时间缓存
+// 读取频道的上传播放列表（UU 开头），每页只消耗少量配额，适合浏览较早的录像
+func (ym *YouTubeMonitor) getUploads(channelID string, maxResults int, pageToken string) (*YouTubeUploadsPage, error) {
+	if !strings.HasPrefix(channelID, "UC") {
+		return nil, fmt.Errorf("无效的频道ID: %s", channelID)
+	}
+	key := videoListCacheKey(channelID, "uploads", fmt.Sprintf("%d", maxResults), pageToken)
+	page, err := cachedVideoMetadata("youtube", key, videoListCacheTTL, func() (YouTubeUploadsPage, error) {
+		return ym.fetchUploads(channelID, maxResults, pageToken)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// fetchUploads 通过 playlistItems 接口获取上传列表，再获取视频的详细信息
+func (ym *YouTubeMonitor) fetchUploads(channelID string, maxResults int, pageToken string) (YouTubeUploadsPage, error) {
+	playlistURL := fmt.Sprintf("https://www.googleapis.com/youtube/v3/playlistItems?part=contentDetails&playlistId=UU%s&maxResults=%d",
+		strings.TrimPrefix(channelID, "UC"), maxResults)
+	if pageToken != "" {
+		playlistURL += "&pageToken=" + url.QueryEscape(pageToken)
+	}
+
+	resp, err := ym.makeRequestWithRetry(playlistURL)
+	if err != nil {
+		return YouTubeUploadsPage{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return YouTubeUploadsPage{}, fmt.Errorf("API返回错误状态 %d: %s", resp.StatusCode, string(body))
+	}
+
+	var playlist models.YouTubePlaylistItemsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&playlist); err != nil {
+		return YouTubeUploadsPage{}, err
+	}
+
+	page := YouTubeUploadsPage{
+		Videos:        []models.YouTubeVideoItem{},
+		NextPageToken: playlist.NextPageToken,
+		TotalResults:  playlist.PageInfo.TotalResults,
+	}
+	if len(playlist.Items) == 0 {
+		return page, nil
+	}
+
+	videoIDs := make([]string, 0, len(playlist.Items))
+	for _, item := range playlist.Items {
+		videoIDs = append(videoIDs, item.ContentDetails.VideoID)
+	}
+
+	videoURL := fmt.Sprintf("https://www.googleapis.com/youtube/v3/videos?part=snippet,liveStreamingDetails,contentDetails&id=%s",
+		strings.Join(videoIDs, ","))
+
+	videoResp, err := ym.makeRequestWithRetry(videoURL)
+	if err != nil {
+		return YouTubeUploadsPage{}, err
+	}
+	defer videoResp.Body.Close()
+
+	if videoResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(videoResp.Body)
+		return YouTubeUploadsPage{}, fmt.Errorf("API返回错误状态 %d: %s", videoResp.StatusCode, string(body))
+	}
+
+	var videoData models.YouTubeVideoResponse
+	if err := json.NewDecoder(videoResp.Body).Decode(&videoData); err != nil {
+		return YouTubeUploadsPage{}, err
+	}
+	page.Videos = videoData.Items
+	return page, nil
+}
+
 // TODO 需要修改 isVODAlreadyProcessed 检查VOD是否已经处理过
 func (ym *YouTubeMonitor) isVODAlreadyProcessed(videoID string) bool {
 	// 检查 chat_logs 目录下是否存在该视频ID的文件
@@ -777,8 +991,10 @@ func (ym *YouTubeMonitor) ProcessRecentVOD(channelID, channelName string) {
 	videos, err := ym.getVideos(channelID, 5)
 	if err != nil {
 		log.Printf("获取 %s 视频列表失败: %v", channelName, err)
+		recordStreamerIssue(channelID, diagStageVODList, "", err)
 		return
 	}
+	clearStreamerIssue(channelID, diagStageVODList)
 
 	// 查找最近的一个直播VOD（有 liveStreamingDetails 的视频）
 	var latestLiveVOD *models.YouTubeVideoItem
@@ -804,41 +1020,74 @@ func (ym *YouTubeMonitor) ProcessRecentVOD(channelID, channelName string) {
 
 	log.Printf("找到最近的直播VOD: %s (%s)", latestLiveVOD.Snippet.Title, latestLiveVOD.ID)
 
-	// 下载聊天记录
-	if err := ym.downloadYouTubeLiveChat(latestLiveVOD, channelName); err != nil {
-		log.Printf("下载YouTube聊天记录失败: %v", err)
+	// 下载聊天记录并分析
+	video, err := json.Marshal(latestLiveVOD)
+	if err != nil {
+		log.Printf("序列化录像信息失败: %v", err)
 		return
 	}
+	ym.runYouTubeVODJob(PersistedJob{
+		Stage:        persistedStageYouTubeVOD,
+		Platform:     "youtube",
+		VideoID:      latestLiveVOD.ID,
+		Streamer:     channelID,
+		StreamerName: channelName,
+		Video:        video,
+	})
+}
 
-	log.Printf("成功处理 %s 的VOD: %s", channelName, latestLiveVOD.Snippet.Title)
+// youtubeActualStartTime 直播录像的实际开播时间，非直播录像返回空
+func youtubeActualStartTime(video *models.YouTubeVideoItem) string {
+	if video.LiveStreamingDetails == nil {
+		return ""
+	}
+	return video.LiveStreamingDetails.ActualStartTime
 }
 
 func (ym *YouTubeMonitor) downloadYouTubeLiveChat(video *models.YouTubeVideoItem,
-	channelName string) error {
-	// 确保聊天日志目录存在
-	if err := os.MkdirAll("./chat_logs", 0755); err != nil {
-		return fmt.Errorf("创建目录失败: %w", err)
-	}
+	channelName string) (retErr error) {
+	log.Printf("开始下载视频 %s 的聊天数据...\n", video.ID)
+	reportJobProgress(video.ID, JobStageChatDownload, 0, "")
+	defer func() { finishJobProgress(video.ID, retErr) }()
+
+	// 移除可能存在的 @ 符号，确保 ID 格式统一
+	channelId := strings.TrimPrefix(channelName, "@")
+	channelId = strings.ToLower(channelId)
 
-	// 构建文件名
-	filename := fmt.Sprintf("chat_youtube_%s_%s.json", video.ID, time.Now().Format("20060102_150405"))
-	filePath := filepath.Join("./chat_logs", filename)
+	// 配置了会员凭据时用于下载会员专属录像
+	creds := GetYouTubeMemberCredentials(channelId)
+	if creds != nil {
+		log.Printf("使用 %s 的会员凭据下载视频 %s", channelName, video.ID)
+	}
 
-	log.Printf("开始下载视频 %s 的聊天数据...\n", video.ID)
-	result, err := DownloadChatsData(video.ID)
+	release, _ := acquireStageSlot(withJobProgress(context.Background(), video.ID), pipelineStageChatDownload)
+	result, err := DownloadChatsData(video.ID, creds)
+	release()
 	if err != nil {
+		recordStreamerIssue(channelId, diagStageChatDownload, video.ID, err)
 		return fmt.Errorf("下载失败: %v\n", err)
 	}
+	clearStreamerIssue(channelId, diagStageChatDownload)
 
-	// 序列化为JSON
-	jsonData, err := json.MarshalIndent(result, "", "  ")
+	// 保存到文件
+	filePath, err := saveYouTubeChatLog(video.ID, result)
 	if err != nil {
-		return fmt.Errorf("序列化失败: %w", err)
+		return err
 	}
 
-	// 写入文件
-	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
-		return fmt.Errorf("写入文件失败: %w", err)
+	// 按规则确定该录像的处理流程
+	plan := planPipelineForVideo(video.ID, PipelineFacts{
+		Streamer: channelId,
+		Platform: "youtube",
+		Language: video.Snippet.DefaultAudioLanguage,
+		Title:    video.Snippet.Title,
+		Duration: parseVideoDuration(video.ContentDetails.Duration),
+		Comments: len(result),
+	})
+	if !plan.Runs(PipelineStepAnalysis) {
+		log.Printf("按流水线规则跳过录像 %s 的分析", video.ID)
+		pipelineSLO.discardStreamEnded("youtube", video.Snippet.ChannelID)
+		return nil
 	}
 
 	// 进行数据分析
@@ -848,25 +1097,33 @@ func (ym *YouTubeMonitor) downloadYouTubeLiveChat(video *models.YouTubeVideoItem
 
 	// 使用默认参数进行分析
 	params := defaultPeakParams
-	analysisResult := FindHotCommentsWithParamsYoutube(result, 5, params)
+	reportJobProgress(video.ID, JobStageAnalysis, 0, fmt.Sprintf("%d 条评论", len(result)))
+	analysisResult := FindHotCommentsWithParams(models.ChatMessagesFromYouTube(result), 5, params)
 	hotMoments = analysisResult.HotMoments
 	timeSeriesData = analysisResult.TimeSeriesData
 	analysisStats = analysisResult.Stats
 
-	// 移除可能存在的 @ 符号，确保 ID 格式统一
-	channelId := strings.TrimPrefix(channelName, "@")
-	channelId = strings.ToLower(channelId)
-
 	// 保存完整的分析结果到文件（包含params参数）
 	if err := saveAnalysisResultToFile(video.ID, hotMoments, timeSeriesData,
-		channelId, analysisStats, &models.TwitchVideoData{
+		channelId, analysisStats, analysisResult.SkipRanges, &models.TwitchVideoData{
 			ID:          video.ID,
 			Title:       video.Snippet.Title,
 			Description: video.Snippet.Description,
 			URL:         fmt.Sprintf("https://www.youtube.com/watch?v=%s", video.ID),
 			Duration:    video.ContentDetails.Duration,
+			CreatedAt:   youtubeActualStartTime(video),
 		}, params); err != nil {
 		log.Printf("保存分析结果失败: %v", err)
+		recordStreamerIssue(channelId, diagStageAnalysis, video.ID, err)
+	} else {
+		clearStreamerIssue(channelId, diagStageAnalysis)
+		pipelineSLO.markAnalysisReady("youtube", video.Snippet.ChannelID, video.ID, time.Now())
+		go runShadowAnalysis(video.ID, channelId, hotMoments, params)
+		// 弹幕反应总结只依赖聊天记录，不必等待片段与语音识别
+		if shouldSummarizeChatReactions(plan) {
+			go summarizeHotMomentChatReactions(context.Background(), video.ID, hotMoments, plan, normalizePeakParams(params).WindowsLen)
+		}
+		go notifyAnalysisReady(channelId, channelName, video.ID, video.Snippet.Title, len(hotMoments))
 	}
 
 	// 保存录像信息到 RPC（如果有视频信息）
@@ -882,8 +1139,19 @@ func (ym *YouTubeMonitor) downloadYouTubeLiveChat(video *models.YouTubeVideoItem
 	log.Printf("✅ 成功保存 %s 的录像 %s 聊天记录 (%d 条评论) 到: %s",
 		channelName, video.ID, len(result), filePath)
 
+	// 热点片段通过 yt-dlp 下载，片段流水线包含语音识别与AI总结；按规则跳过片段时仍尝试使用平台字幕总结
+	if plan.Runs(PipelineStepClips) && len(hotMoments) > 0 {
+		scheduleHotMomentClips(video.ID, hotMoments, plan.ClipInterval)
+		return nil
+	}
+
+	if !plan.Runs(PipelineStepAISummary) {
+		log.Printf("按流水线规则跳过录像 %s 的AI总结", video.ID)
+		return nil
+	}
+
 	// 下载视频的字幕文件（如果有）
-	srtContent, err := downloadYouTubeSubtitlesWithThirdPartyTool(video.ID, "")
+	srtContent, err := downloadYouTubeSubtitlesWithThirdPartyTool(video.ID, "", creds)
 	if err != nil || srtContent == "" {
 		log.Printf("下载字幕失败或无字幕, 已跳过分析: %v", err)
 		return nil
@@ -899,34 +1167,39 @@ func (ym *YouTubeMonitor) downloadYouTubeLiveChat(video *models.YouTubeVideoItem
 
 		// 从配置读取AI服务提供商
 		aiConfig := GetAIConfig()
-		aiService := NewAIService(aiConfig.Provider, "")
+		aiService := NewConfiguredAIService()
 		if aiService == nil {
 			log.Println("AI 服务未初始化，跳过AI总结")
 			break
 		} else {
-			// 执行字幕总结
-			ctx := context.Background()
+			// 执行字幕总结，使用订阅者偏好的语言
+			ctx := withSummaryPromptVars(withSummaryLanguage(context.Background(), plan.SummaryLanguage),
+				summaryPromptVarsFor(plan, hotMoment.OffsetSeconds))
 
-			summary, _, err := aiService.SummarizeSRT(ctx, subedSrtContent, 10000)
+			summary, _, err := aiService.SummarizeSRT(ctx, subedSrtContent, 0)
 
 			if err != nil {
 				log.Printf("AI总结失败: %v", err)
 			} else {
 				// 保存总结到analysis_results文件夹，避免被清理
-				analysisDir := filepath.Join("./analysis_results", video.ID)
+				analysisDir := pathsafe.Join("./analysis_results", video.ID)
 				if err := os.MkdirAll(analysisDir, 0755); err != nil {
 					log.Printf("创建分析目录失败: %v", err)
 				} else {
 					// 使用原始字幕文件名，但保存到analysis_results目录
-					summaryPath := filepath.Join(analysisDir, fmt.Sprintf("%f", hotMoment.OffsetSeconds))
+					summaryPath := filepath.Join(analysisDir, pathsafe.Seconds(hotMoment.OffsetSeconds))
 					if err := aiService.SaveSummaryToFile(summaryPath, summary); err != nil {
 						log.Printf("保存总结失败: %v", err)
 					} else {
+						indexSummary(video.ID, hotMoment.OffsetSeconds, summaryPath, summary)
 						log.Printf("热点 #%d AI总结完成并已保存到: %s", i+1, summaryPath)
+						pipelineSLO.markSummaryReady(video.ID, time.Now())
+						recordSummaryEvaluation(ctx, aiService, aiConfig.Provider, video.ID, hotMoment.OffsetSeconds, subedSrtContent, summary)
+						recordClipMetadata(ctx, aiService, aiConfig.Provider, video.ID, hotMoment.OffsetSeconds, summary)
 					}
 
 					// 保留原始srt文件
-					subedSrtFileName := fmt.Sprintf("%s_%.0f.srt", video.ID, hotMoment.OffsetSeconds)
+					subedSrtFileName := fmt.Sprintf("%s_%.0f.srt", pathsafe.Name(video.ID), hotMoment.OffsetSeconds)
 					analysisPath := filepath.Join(analysisDir, subedSrtFileName)
 					if err := os.WriteFile(analysisPath, []byte(subedSrtContent), 0644); err == nil {
 						log.Printf("Subtitle also copied to: %s", analysisPath)
@@ -941,12 +1214,12 @@ func (ym *YouTubeMonitor) downloadYouTubeLiveChat(video *models.YouTubeVideoItem
 	return nil
 }
 
-// DownloadChatsData 下载聊天数据的主函数
-func DownloadChatsData(videoID string) ([]models.YoutubeChatLog, error) {
+// DownloadChatsData 下载聊天数据的主函数，creds 不为 nil 时携带会员 cookies 访问
+func DownloadChatsData(videoID string, creds *YouTubeMemberCredentials) ([]models.YoutubeChatLog, error) {
 	url := fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoID)
 
 	// 创建HTTP客户端
-	client := &http.Client{}
+	client := newTracedHTTPClient(0)
 
 	// 创建请求
 	req, err := http.NewRequest("GET", url, nil)
@@ -956,6 +1229,9 @@ func DownloadChatsData(videoID string) ([]models.YoutubeChatLog, error) {
 
 	// 设置请求头
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/83.0.4103.116 Safari/537.36")
+	if cookie := creds.CookieHeader(); cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	}
 
 	// 发送GET请求
 	response, err := client.Do(req)
@@ -985,7 +1261,7 @@ func DownloadChatsData(videoID string) ([]models.YoutubeChatLog, error) {
 		}
 
 		// 获取Chats
-		chatLogs, _, err := GetChatReplayFromContinuation(videoID, continuation, 9999)
+		chatLogs, _, err := GetChatReplayFromContinuation(videoID, continuation, 9999, creds)
 		if err != nil {
 			return nil, err
 		}
@@ -1174,11 +1450,12 @@ func GetContinueUrl(ytInitialData map[string]interface{}) (string, error) {
 }
 
 // GetChatReplayFromContinuation 从continuation获取聊天重播数据
-func GetChatReplayFromContinuation(videoID, continuation string, pageCountLimit int) ([]models.YoutubeChatLog, string, error) {
+func GetChatReplayFromContinuation(videoID, continuation string, pageCountLimit int,
+	creds *YouTubeMemberCredentials) ([]models.YoutubeChatLog, string, error) {
 	result := []models.YoutubeChatLog{}
 	count := 1
 	pageCount := 1
-	client := &http.Client{}
+	client := newTracedHTTPClient(0)
 
 	for pageCount < pageCountLimit {
 		if continuation == "" {
@@ -1194,6 +1471,9 @@ func GetChatReplayFromContinuation(videoID, continuation string, pageCountLimit
 		}
 
 		req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/83.0.4103.116 Safari/537.36")
+		if cookie := creds.CookieHeader(); cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
 
 		resp, err := client.Do(req)
 		if err != nil {
@@ -1486,10 +1766,12 @@ func parseFloat(s string) (float64, error) {
 }
 
 // downloadYouTubeSubtitlesWithThirdPartyTool 使用第三方工具下载YouTube字幕
-// 首先要确保这个完成了安装
-func downloadYouTubeSubtitlesWithThirdPartyTool(videoID string, lang string) (string, error) {
+// 首先要确保这个完成了安装；creds 不为 nil 时使用主播的会员凭据代替默认 cookies.txt
+func downloadYouTubeSubtitlesWithThirdPartyTool(videoID string, lang string, creds *YouTubeMemberCredentials) (string, error) {
 	//! 不再支持 ，有很大的问题
-	return "", fmt.Errorf("下载YouTube字幕功能已被禁用")
+	if !youtubeSubtitleDownloadEnabled {
+		return "", fmt.Errorf("下载YouTube字幕功能已被禁用")
+	}
 
 	if lang == "" {
 		lang = "en" // 默认语言
@@ -1508,7 +1790,21 @@ func downloadYouTubeSubtitlesWithThirdPartyTool(videoID string, lang string) (st
 	}
 	cookiesPath := filepath.Join(homeDir, "cookies.txt")
 
-	cmd := exec.Command("yt-dlp",
+	var extraArgs []string
+	if creds != nil {
+		tempCookies, err := creds.writeTempCookiesFile()
+		if err != nil {
+			return "", fmt.Errorf("写入会员凭据失败: %v", err)
+		}
+		defer os.Remove(tempCookies)
+		cookiesPath = tempCookies
+
+		if creds.POToken != "" {
+			extraArgs = append(extraArgs, "--extractor-args", "youtube:po_token=web+"+creds.POToken)
+		}
+	}
+
+	args := append([]string{
 		"--cookies", cookiesPath, // 设置cookies文件路径
 		"--write-auto-subs", // 下载自动生成的字幕
 		"--write-subs",      // 下载手动字幕
@@ -1516,10 +1812,15 @@ func downloadYouTubeSubtitlesWithThirdPartyTool(videoID string, lang string) (st
 		"--sub-format", "srt", // 指定格式
 		"--skip-download",    // 不下载视频
 		"-o", outputTemplate, // 输出模板
-		videoURL,
-	)
+	}, extraArgs...)
+	cmd := exec.Command("yt-dlp", append(args, videoURL)...)
 
-	log.Printf("执行 yt-dlp 命令: %s %v", cmd.Path, cmd.Args)
+	if creds == nil {
+		log.Printf("执行 yt-dlp 命令: %s %v", cmd.Path, cmd.Args)
+	} else {
+		// 参数中包含凭据，不写入日志
+		log.Printf("执行 yt-dlp 命令（使用会员凭据）: %s", videoURL)
+	}
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"subtuber-services/models"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// YouTube 直播检查方式（youtube.live_check）
+const (
+	// youtubeLiveCheckUploads 读取频道上传列表最前面的视频并查看详情，每次 2 单位
+	youtubeLiveCheckUploads = "uploads"
+	// youtubeLiveCheckScrape 访问频道的 /live 页面，直播中时页面的 canonical 链接指向直播视频，再用 videos 接口确认；
+	// 未直播时不消耗配额，直播或有预约直播时 1 单位
+	youtubeLiveCheckScrape = "scrape"
+	// youtubeLiveCheckSearch 使用 search 接口（eventType=live），每次 100 单位
+	youtubeLiveCheckSearch = "search"
+)
+
+// youtubeLiveCheckUploadsCount uploads 方式读取的最近上传数量，正在进行的直播会出现在上传列表最前面
+const youtubeLiveCheckUploadsCount = 5
+
+// validYouTubeLiveCheck 直播检查方式是否合法，空值表示使用默认方式
+func validYouTubeLiveCheck(method string) bool {
+	switch method {
+	case "", youtubeLiveCheckUploads, youtubeLiveCheckScrape, youtubeLiveCheckSearch:
+		return true
+	}
+	return false
+}
+
+// liveCheckMethod 当前配置的直播检查方式
+func (ym *YouTubeMonitor) liveCheckMethod() string {
+	ym.mu.RLock()
+	defer ym.mu.RUnlock()
+	return ym.config.LiveCheck
+}
+
+// CheckLiveStatusByChannelID 根据频道ID检查直播状态，未直播时返回 nil
+// 检查方式由 youtube.live_check 决定；uploads 与 scrape 只支持 UC 开头的频道ID，其他格式使用 search
+func (ym *YouTubeMonitor) CheckLiveStatusByChannelID(channelID string) (*models.YouTubeStreamData, error) {
+	method := ym.liveCheckMethod()
+	if !strings.HasPrefix(channelID, "UC") {
+		method = youtubeLiveCheckSearch
+	}
+
+	switch method {
+	case youtubeLiveCheckScrape:
+		return ym.checkLiveByScrape(channelID)
+	case youtubeLiveCheckSearch:
+		return ym.checkLiveBySearch(channelID)
+	default:
+		return ym.checkLiveByUploads(channelID)
+	}
+}
+
+// checkLiveByUploads 在频道上传列表最前面的视频中查找正在直播的视频
+func (ym *YouTubeMonitor) checkLiveByUploads(channelID string) (*models.YouTubeStreamData, error) {
+	page, err := ym.fetchUploads(channelID, youtubeLiveCheckUploadsCount, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range page.Videos {
+		if item.Snippet.LiveBroadcastContent == "live" && item.LiveStreamingDetails != nil {
+			return youtubeStreamData(item), nil
+		}
+	}
+	return nil, nil
+}
+
+// checkLiveByScrape 访问频道的 /live 页面获取直播视频ID，再通过 videos 接口确认是否正在直播
+// /live 在有预约直播时也会指向预约的视频，因此需要确认 liveBroadcastContent
+func (ym *YouTubeMonitor) checkLiveByScrape(channelID string) (*models.YouTubeStreamData, error) {
+	req, err := http.NewRequest("GET", "https://www.youtube.com/channel/"+channelID+"/live", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/83.0.4103.116 Safari/537.36")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	// 跳过欧盟地区的 Cookie 同意页面
+	req.Header.Set("Cookie", "CONSENT=YES+1")
+
+	resp, err := newTracedHTTPClient(10 * time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("频道直播页面返回错误状态 %d", resp.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	canonical, _ := doc.Find(`link[rel="canonical"]`).Attr("href")
+	u, err := url.Parse(canonical)
+	if err != nil || !strings.HasSuffix(u.Path, "/watch") {
+		// 未直播时页面停留在频道首页
+		return nil, nil
+	}
+	videoID := u.Query().Get("v")
+	if videoID == "" {
+		return nil, nil
+	}
+	return ym.fetchLiveVideo(videoID)
+}
+
+// checkLiveBySearch 使用 search 接口搜索频道正在直播的视频
+func (ym *YouTubeMonitor) checkLiveBySearch(channelID string) (*models.YouTubeStreamData, error) {
+	searchURL := fmt.Sprintf("https://www.googleapis.com/youtube/v3/search?part=snippet&channelId=%s&eventType=live&type=video",
+		channelID)
+
+	resp, err := ym.makeRequestWithRetry(searchURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API返回错误状态 %d: %s", resp.StatusCode, string(body))
+	}
+
+	var searchResp models.YouTubeSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
+		return nil, err
+	}
+
+	// 如果没有直播，返回nil
+	if len(searchResp.Items) == 0 {
+		return nil, nil
+	}
+
+	// 获取第一个直播视频的详细信息
+	return ym.fetchLiveVideo(searchResp.Items[0].ID.VideoID)
+}
+
+// fetchLiveVideo 获取视频详情，视频正在直播时返回直播数据
+func (ym *YouTubeMonitor) fetchLiveVideo(videoID string) (*models.YouTubeStreamData, error) {
+	videoURL := fmt.Sprintf("https://www.googleapis.com/youtube/v3/videos?part=snippet,liveStreamingDetails&id=%s",
+		url.QueryEscape(videoID))
+
+	resp, err := ym.makeRequestWithRetry(videoURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API返回错误状态 %d: %s", resp.StatusCode, string(body))
+	}
+
+	var videoData models.YouTubeVideoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&videoData); err != nil {
+		return nil, err
+	}
+
+	if len(videoData.Items) == 0 {
+		return nil, nil
+	}
+	item := videoData.Items[0]
+
+	// 预约直播与已结束的直播不算正在直播
+	if item.LiveStreamingDetails == nil || item.Snippet.LiveBroadcastContent != "live" {
+		return nil, nil
+	}
+	return youtubeStreamData(item), nil
+}
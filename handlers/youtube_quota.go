@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const youtubeQuotaFile = "App_Data/youtube_quota.json"
+
+// YouTube Data API 配额相关默认值
+const (
+	defaultYouTubeDailyQuota    = 10000 // Google 为每个项目默认分配的每日配额单位
+	defaultYouTubeQuotaReserve  = 10    // 为低消耗接口保留的配额百分比
+	youtubeExpensiveCallCost    = 100   // 消耗不低于该值的接口在动用保留配额前被拒绝
+	youtubeQuotaSlowdownFactor  = 4     // 配额接近上限时监控检查间隔放大的倍数
+	youtubeUnknownEndpointUnits = 1
+)
+
+// youtubeEndpointCosts 各接口每次调用消耗的配额单位（https://developers.google.com/youtube/v3/determine_quota_cost）
+var youtubeEndpointCosts = map[string]int{
+	"search":        100,
+	"videos":        1,
+	"channels":      1,
+	"playlistItems": 1,
+}
+
+// errYouTubeQuotaBudget 所有 API Key 当天剩余的配额都不足以完成本次调用
+var errYouTubeQuotaBudget = errors.New("YouTube API 配额预算不足")
+
+// youtubeQuotaLocation 配额在太平洋时间午夜重置
+var youtubeQuotaLocation = func() *time.Location {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		return time.FixedZone("PST", -8*3600)
+	}
+	return loc
+}()
+
+// YouTubeKeyUsage 一个 API Key 当天的配额使用情况
+type YouTubeKeyUsage struct {
+	Used      int            `json:"used"`
+	Calls     map[string]int `json:"calls"`     // 接口 -> 调用次数
+	Refused   int            `json:"refused"`   // 因预算不足而未发出的调用次数
+	Exhausted bool           `json:"exhausted"` // API 已返回配额用尽
+}
+
+// youtubeQuotaState 持久化的配额记录，Keys 以脱敏后的 API Key 为键
+type youtubeQuotaState struct {
+	Day  string                      `json:"day"`
+	Keys map[string]*YouTubeKeyUsage `json:"keys"`
+}
+
+// youtubeQuotaTracker 按 API Key 按天记录 YouTube Data API 的配额使用
+type youtubeQuotaTracker struct {
+	mu             sync.Mutex
+	dailyQuota     int
+	reservePercent int
+	state          *youtubeQuotaState
+}
+
+var youtubeQuota = &youtubeQuotaTracker{
+	dailyQuota:     defaultYouTubeDailyQuota,
+	reservePercent: defaultYouTubeQuotaReserve,
+}
+
+// configure 设置每个 Key 的每日配额与保留比例
+func (t *youtubeQuotaTracker) configure(dailyQuota, reservePercent int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dailyQuota = dailyQuota
+	t.reservePercent = reservePercent
+}
+
+// youtubeQuotaDay 配额所属的日期（太平洋时间）
+func youtubeQuotaDay(now time.Time) string {
+	return now.In(youtubeQuotaLocation).Format("2006-01-02")
+}
+
+// youtubeQuotaResetAt 下一次配额重置的时间
+func youtubeQuotaResetAt(now time.Time) time.Time {
+	local := now.In(youtubeQuotaLocation)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, youtubeQuotaLocation)
+}
+
+// youtubeCallCost 根据请求地址判断调用的接口与消耗的配额单位
+func youtubeCallCost(rawURL string) (string, int) {
+	endpoint := "unknown"
+	if u, err := url.Parse(rawURL); err == nil {
+		endpoint = path.Base(u.Path)
+	}
+	if cost, ok := youtubeEndpointCosts[endpoint]; ok {
+		return endpoint, cost
+	}
+	return endpoint, youtubeUnknownEndpointUnits
+}
+
+// maskYouTubeAPIKey 只保留 API Key 的后 4 位
+func maskYouTubeAPIKey(key string) string {
+	if len(key) <= 4 {
+		return "***"
+	}
+	return "***" + key[len(key)-4:]
+}
+
+// usageLocked 返回 Key 当天的使用记录，跨天时清空所有记录，调用方需持有 t.mu
+func (t *youtubeQuotaTracker) usageLocked(key string) *YouTubeKeyUsage {
+	day := youtubeQuotaDay(time.Now())
+	if t.state == nil {
+		t.state = &youtubeQuotaState{}
+		if data, err := os.ReadFile(youtubeQuotaFile); err == nil {
+			if err := json.Unmarshal(data, t.state); err != nil {
+				log.Printf("读取 YouTube 配额记录失败: %v", err)
+			}
+		}
+	}
+	if t.state.Day != day || t.state.Keys == nil {
+		t.state = &youtubeQuotaState{Day: day, Keys: map[string]*YouTubeKeyUsage{}}
+	}
+
+	label := maskYouTubeAPIKey(key)
+	usage, ok := t.state.Keys[label]
+	if !ok {
+		usage = &YouTubeKeyUsage{Calls: map[string]int{}}
+		t.state.Keys[label] = usage
+	}
+	return usage
+}
+
+// saveLocked 将配额记录写回文件，调用方需持有 t.mu
+func (t *youtubeQuotaTracker) saveLocked() {
+	err := os.MkdirAll(filepath.Dir(youtubeQuotaFile), 0755)
+	if err == nil {
+		var data []byte
+		if data, err = json.MarshalIndent(t.state, "", "  "); err == nil {
+			err = os.WriteFile(youtubeQuotaFile, data, 0644)
+		}
+	}
+	if err != nil {
+		log.Printf("保存 YouTube 配额记录失败: %v", err)
+	}
+}
+
+// limitLocked 本次调用可用的额度上限：高消耗接口不能动用保留给低消耗接口的配额
+func (t *youtubeQuotaTracker) limitLocked(cost int) int {
+	if cost >= youtubeExpensiveCallCost {
+		return t.dailyQuota * (100 - t.reservePercent) / 100
+	}
+	return t.dailyQuota
+}
+
+// reserve 在发出请求前记录 Key 的配额消耗，剩余额度不足时拒绝并返回 false
+// YouTube 对失败的请求同样计费，因此在请求前扣除
+func (t *youtubeQuotaTracker) reserve(key, endpoint string, cost int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.usageLocked(key)
+	if usage.Exhausted || usage.Used+cost > t.limitLocked(cost) {
+		usage.Refused++
+		t.saveLocked()
+		return false
+	}
+	usage.Used += cost
+	usage.Calls[endpoint]++
+	t.saveLocked()
+	return true
+}
+
+// markExhausted API 返回配额用尽时，当天不再使用该 Key
+func (t *youtubeQuotaTracker) markExhausted(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usageLocked(key).Exhausted = true
+	t.saveLocked()
+}
+
+// nearCap 所有 Key 的剩余配额合计是否已只剩保留部分
+func (t *youtubeQuotaTracker) nearCap(keys []string) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	remaining := 0
+	for _, key := range keys {
+		usage := t.usageLocked(key)
+		if !usage.Exhausted {
+			remaining += max(t.dailyQuota-usage.Used, 0)
+		}
+	}
+	return remaining*100 <= t.dailyQuota*len(keys)*t.reservePercent
+}
+
+// YouTubeKeyQuota 配额状态接口中一个 API Key 的使用情况
+type YouTubeKeyQuota struct {
+	Key       string         `json:"key"` // 只显示后 4 位
+	Used      int            `json:"used"`
+	Remaining int            `json:"remaining"`
+	Calls     map[string]int `json:"calls"`
+	Refused   int            `json:"refused"`
+	Exhausted bool           `json:"exhausted"`
+}
+
+// snapshot 当天各 Key 的配额使用情况，按配置顺序排列
+func (t *youtubeQuotaTracker) snapshot(keys []string) []YouTubeKeyQuota {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	quotas := make([]YouTubeKeyQuota, 0, len(keys))
+	for _, key := range keys {
+		usage := t.usageLocked(key)
+		calls := make(map[string]int, len(usage.Calls))
+		for endpoint, n := range usage.Calls {
+			calls[endpoint] = n
+		}
+		remaining := max(t.dailyQuota-usage.Used, 0)
+		if usage.Exhausted {
+			remaining = 0
+		}
+		quotas = append(quotas, YouTubeKeyQuota{
+			Key:       maskYouTubeAPIKey(key),
+			Used:      usage.Used,
+			Remaining: remaining,
+			Calls:     calls,
+			Refused:   usage.Refused,
+			Exhausted: usage.Exhausted,
+		})
+	}
+	return quotas
+}
+
+// youtubeQuotaBudgetError 所有 Key 都因预算不足拒绝调用时返回的错误
+func youtubeQuotaBudgetError(endpoint string, cost int) error {
+	return fmt.Errorf("%w: %s 接口需要 %d 单位，配额将在 %s 重置", errYouTubeQuotaBudget, endpoint, cost,
+		youtubeQuotaResetAt(time.Now()).Format(time.RFC3339))
+}
+
+// isYouTubeQuotaExceeded 403 响应是否为配额用尽（而不是 Referer 限制等其他原因）
+func isYouTubeQuotaExceeded(body string) bool {
+	return strings.Contains(body, "quotaExceeded") || strings.Contains(body, "dailyLimitExceeded")
+}
+
+// GetYouTubeQuota 返回各 YouTube API Key 当天的配额使用情况与各接口的配额单价
+// GET /api/youtube/quota
+func GetYouTubeQuota(c *gin.Context) {
+	ym := GetYouTubeMonitor()
+	if ym == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "YouTube监控服务未启动"})
+		return
+	}
+
+	keys := ym.config.APIKeys
+	quotas := youtubeQuota.snapshot(keys)
+	used, remaining := 0, 0
+	for _, q := range quotas {
+		used += q.Used
+		remaining += q.Remaining
+	}
+
+	youtubeQuota.mu.Lock()
+	dailyQuota, reservePercent := youtubeQuota.dailyQuota, youtubeQuota.reservePercent
+	youtubeQuota.mu.Unlock()
+
+	now := time.Now()
+	c.JSON(http.StatusOK, gin.H{
+		"day":                   youtubeQuotaDay(now),
+		"reset_at":              youtubeQuotaResetAt(now),
+		"daily_quota_per_key":   dailyQuota,
+		"quota_reserve_percent": reservePercent,
+		"used":                  used,
+		"remaining":             remaining,
+		"near_cap":              youtubeQuota.nearCap(keys),
+		"keys":                  quotas,
+		"costs":                 youtubeEndpointCosts,
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalidYouTubeHandle Handle 格式不合法
+	ErrInvalidYouTubeHandle = errors.New("无效的YouTube Handle")
+	// ErrYouTubeChannelNotFound 频道不存在或已被终止
+	ErrYouTubeChannelNotFound = errors.New("未找到YouTube频道")
+
+	youtubeHandlePattern    = regexp.MustCompile(`^@?[A-Za-z0-9._-]{3,30}$`)
+	youtubeChannelIDPattern = regexp.MustCompile(`^UC[A-Za-z0-9_-]{22}$`)
+)
+
+// YouTubeChannelResolution 解析得到的频道规范信息
+type YouTubeChannelResolution struct {
+	ChannelID       string // UC 开头的频道ID
+	Handle          string // 规范 Handle（带 @），频道未设置 Handle 时为空
+	Title           string // 频道名称
+	ProfileImageURL string
+}
+
+// ResolveYouTubeChannel 通过 Handle 或频道ID确认频道存在，并返回规范的 Handle 与频道ID
+// 使用 channels 接口的 forHandle/id 精确查询，而不是模糊的 search 接口
+func (ym *YouTubeMonitor) ResolveYouTubeChannel(input string) (*YouTubeChannelResolution, error) {
+	input = strings.TrimSpace(input)
+
+	var query string
+	switch {
+	case youtubeChannelIDPattern.MatchString(input):
+		query = "id=" + url.QueryEscape(input)
+	case youtubeHandlePattern.MatchString(input):
+		if !strings.HasPrefix(input, "@") {
+			input = "@" + input
+		}
+		query = "forHandle=" + url.QueryEscape(input)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidYouTubeHandle, input)
+	}
+
+	apiURL := "https://www.googleapis.com/youtube/v3/channels?part=snippet&" + query
+	resp, err := ym.makeRequestWithRetry(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API返回错误状态 %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Items []struct {
+			ID      string `json:"id"`
+			Snippet struct {
+				Title      string `json:"title"`
+				CustomURL  string `json:"customUrl"`
+				Thumbnails struct {
+					High struct {
+						URL string `json:"url"`
+					} `json:"high"`
+					Default struct {
+						URL string `json:"url"`
+					} `json:"default"`
+				} `json:"thumbnails"`
+			} `json:"snippet"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	// 被终止或不存在的频道不会出现在结果中
+	if len(result.Items) == 0 || result.Items[0].ID == "" {
+		return nil, fmt.Errorf("%w: %s", ErrYouTubeChannelNotFound, input)
+	}
+
+	item := result.Items[0]
+	handle := item.Snippet.CustomURL
+	if handle == "" && strings.HasPrefix(input, "@") {
+		handle = input
+	}
+	if handle != "" && !strings.HasPrefix(handle, "@") {
+		handle = "@" + handle
+	}
+
+	profileImageURL := item.Snippet.Thumbnails.High.URL
+	if profileImageURL == "" {
+		profileImageURL = item.Snippet.Thumbnails.Default.URL
+	}
+
+	return &YouTubeChannelResolution{
+		ChannelID:       item.ID,
+		Handle:          handle,
+		Title:           item.Snippet.Title,
+		ProfileImageURL: profileImageURL,
+	}, nil
+}
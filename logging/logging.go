@@ -0,0 +1,200 @@
+// Package logging 基于 log/slog 的结构化日志：支持文本或 JSON 输出、按模块设置日志级别（运行中可修改），
+// 并为携带请求 ID 的 context 自动附加 request_id 字段
+// 标准库 log 的输出同样转发到这里，按 app 模块以 INFO 级别记录
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config holds the log output format and the per-module log levels
+type Config struct {
+	Level   string            `mapstructure:"level" json:"level"`     // 默认级别：debug、info、warn、error，默认 info
+	Format  string            `mapstructure:"format" json:"format"`   // text（默认）或 json，json 便于日志采集
+	Modules map[string]string `mapstructure:"modules" json:"modules"` // 模块名 -> 级别，覆盖默认级别，例如 http: warn
+}
+
+// Validate 校验日志配置
+func (c Config) Validate() error {
+	switch c.Format {
+	case "", FormatText, FormatJSON:
+	default:
+		return fmt.Errorf("日志格式必须是 text 或 json: %s", c.Format)
+	}
+	if c.Level != "" {
+		if _, err := ParseLevel(c.Level); err != nil {
+			return err
+		}
+	}
+	for module, level := range c.Modules {
+		if _, err := ParseLevel(level); err != nil {
+			return fmt.Errorf("模块 %s: %w", module, err)
+		}
+	}
+	return nil
+}
+
+// ParseLevel 解析日志级别名称（不区分大小写）
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("无效的日志级别 %q，可选 debug、info、warn、error", s)
+	}
+	return level, nil
+}
+
+var (
+	// base 当前的输出 handler，修改格式时整体替换，已创建的模块 logger 无需重建
+	base atomic.Pointer[slog.Handler]
+
+	levelsMu     sync.RWMutex
+	defaultLevel = slog.LevelInfo
+	moduleLevels = map[string]slog.Level{}
+)
+
+func init() {
+	setOutput(os.Stderr, FormatText)
+}
+
+func setOutput(w io.Writer, format string) {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug} // 级别由 handler.Enabled 按模块判断
+	var h slog.Handler
+	if format == FormatJSON {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	base.Store(&h)
+}
+
+// Configure applies the output format and log levels; it is safe to call again on config reload
+func Configure(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	setOutput(os.Stderr, cfg.Format)
+
+	level := slog.LevelInfo
+	if cfg.Level != "" {
+		level, _ = ParseLevel(cfg.Level)
+	}
+	modules := make(map[string]slog.Level, len(cfg.Modules))
+	for module, name := range cfg.Modules {
+		modules[module], _ = ParseLevel(name)
+	}
+	levelsMu.Lock()
+	defaultLevel = level
+	moduleLevels = modules
+	levelsMu.Unlock()
+
+	// 标准库 log 转发到 app 模块，时间与级别由 slog 输出
+	slog.SetDefault(For("app"))
+	return nil
+}
+
+// SetLevel changes the level of a module at runtime; an empty module changes the default level
+func SetLevel(module, level string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	if module == "" {
+		defaultLevel = l
+	} else {
+		moduleLevels[module] = l
+	}
+	return nil
+}
+
+// Levels returns the default level and the module overrides currently in effect
+func Levels() (string, map[string]string) {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	modules := make(map[string]string, len(moduleLevels))
+	for module, level := range moduleLevels {
+		modules[module] = strings.ToLower(level.String())
+	}
+	return strings.ToLower(defaultLevel.String()), modules
+}
+
+func levelFor(module string) slog.Level {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	if level, ok := moduleLevels[module]; ok {
+		return level
+	}
+	return defaultLevel
+}
+
+// For returns the logger of a subsystem; records carry a module attribute and obey the module's level
+// 可在包初始化时调用，之后的格式与级别修改会立即生效
+func For(module string) *slog.Logger {
+	return slog.New(&handler{module: module}).With("module", module)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 返回携带请求 ID 的 context，使用该 context 记录的日志会附加 request_id 字段
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回 context 中的请求 ID，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// handler 按模块级别过滤，并在输出时才取当前的 base，使格式修改对已创建的 logger 生效
+type handler struct {
+	module string
+	ops    []func(slog.Handler) slog.Handler // With / WithGroup 调用，按顺序应用到 base
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= levelFor(h.module)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	out := *base.Load()
+	for _, op := range h.ops {
+		out = op(out)
+	}
+	return out.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithGroup(name) })
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) slog.Handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{module: h.module, ops: append(ops, op)}
+}
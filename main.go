@@ -1,48 +1,311 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"os"
 	"time"
 
+	"subtuber-services/chatdownload"
+	"subtuber-services/config"
 	"subtuber-services/handlers"
+	"subtuber-services/logging"
 	"subtuber-services/services"
+	"subtuber-services/storage"
 
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
+// initConfig loads config.yaml and environment overrides and installs the package-level configs
+func initConfig() (config.App, error) {
+	cfg, err := config.Load()
+	config.Apply(cfg)
+	return cfg, err
+}
+
 func main() {
-	// load configuration (config.yaml) via viper
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	_ = viper.ReadInConfig()
-
-	var cfg struct {
-		SubTuber   handlers.SubTuberConfig   `mapstructure:"subtuber"`
-		SMTP       handlers.SMTPConfig       `mapstructure:"smtp"`
-		Twitch     handlers.TwitchConfig     `mapstructure:"twitch"`
-		YouTube    handlers.YouTubeConfig    `mapstructure:"youtube"`
-		RPC        handlers.RPCConfig        `mapstructure:"rpc"`
-		GoogleAPI  handlers.GoogleAPIConfig  `mapstructure:"google_api"`
-		AlibabaAPI handlers.AlibabaAPIConfig `mapstructure:"alibaba_api"`
-		AI         handlers.AIConfig         `mapstructure:"ai"`
-	}
-	_ = viper.Unmarshal(&cfg)
-
-	// set default timeout if not provided
-	if cfg.SMTP.Timeout == 0 {
-		cfg.SMTP.Timeout = 30 * time.Second
-	}
-	// set default AI provider if not provided
-	if cfg.AI.Provider == "" {
-		cfg.AI.Provider = "aliyun"
-	}
-	handlers.SetSMTPConfig(cfg.SMTP)
-	handlers.SetRPCConfig(cfg.RPC)
-	handlers.SetGoogleAPIConfig(cfg.GoogleAPI)
-	handlers.SetAlibabaAPIConfig(cfg.AlibabaAPI)
-	handlers.SetAIConfig(cfg.AI)
+	rootCmd := &cobra.Command{
+		Use:   "lumitime",
+		Short: "subtuber API server and offline tools",
+		// 不带子命令时启动 HTTP 服务
+		Run: func(cmd *cobra.Command, args []string) {
+			runServer()
+		},
+	}
+	rootCmd.AddCommand(newServeCmd(), newAnalyzeCmd(), newDownloadChatCmd(), newCompressChatLogsCmd(), newMigrateStorageCmd(), newSummarizeCmd())
+
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// newServeCmd starts the HTTP API server (same as running without a subcommand)
+func newServeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Start the HTTP API server",
+		Run: func(cmd *cobra.Command, args []string) {
+			runServer()
+		},
+	}
+}
+
+// newAnalyzeCmd runs peak detection on a local chat log file
+func newAnalyzeCmd() *cobra.Command {
+	var chatPath string
+	var params handlers.PeakDetectionParams
+	var withTimeSeries bool
+
+	cmd := &cobra.Command{
+		Use:   "analyze",
+		Short: "Detect hot moments in a saved chat log",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			initConfig()
+
+			result, total, err := handlers.AnalyzeChatFile(chatPath, params)
+			if err != nil {
+				return err
+			}
+			if !withTimeSeries {
+				result.TimeSeriesData = nil
+			}
+
+			return printJSON(map[string]interface{}{
+				"chat_file":        chatPath,
+				"total_comments":   total,
+				"hot_moments":      result.HotMoments,
+				"stats":            result.Stats,
+				"skip_ranges":      result.SkipRanges,
+				"time_series_data": result.TimeSeriesData,
+			})
+		},
+	}
+	cmd.Flags().StringVar(&chatPath, "chat", "", "chat log file (Twitch or YouTube JSON)")
+	defaults := handlers.DefaultPeakParams()
+	cmd.Flags().IntVar(&params.WindowsLen, "windows-len", defaults.WindowsLen, "density window length in seconds")
+	cmd.Flags().Float64Var(&params.Thr, "thr", defaults.Thr, "threshold percentile 0-1")
+	cmd.Flags().IntVar(&params.SearchRange, "search-range", defaults.SearchRange, "local maximum search range in seconds")
+	cmd.Flags().StringVar(&params.Method, "method", "", "peak detection method: convolution, zscore or multiscale (default convolution)")
+	cmd.Flags().Float64Var(&params.ZThreshold, "z-threshold", 0, "zscore/multiscale: standard deviations above the mean (default 3)")
+	cmd.Flags().IntVar(&params.BaselineLen, "baseline-len", 0, "zscore: rolling mean/deviation window in seconds (default 1800)")
+	cmd.Flags().IntVar(&params.MinWindowsLen, "min-windows-len", 0, "multiscale: smallest density window in seconds (default 15)")
+	cmd.Flags().IntVar(&params.Scales, "scales", 0, "multiscale: number of window lengths between min-windows-len and windows-len (default 4)")
+	cmd.Flags().BoolVar(&withTimeSeries, "time-series", false, "include the density time series in the output")
+	_ = cmd.MarkFlagRequired("chat")
+	return cmd
+}
+
+// newDownloadChatCmd downloads a Twitch VOD chat into ./chat_logs
+func newDownloadChatCmd() *cobra.Command {
+	var videoID string
+
+	cmd := &cobra.Command{
+		Use:   "download-chat",
+		Short: "Download a Twitch VOD chat log into chat_logs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _ := initConfig()
+
+			// 配置了应用凭据时用于获取录像元数据，不启动监控循环
+			if cfg.Twitch.ClientID != "" && cfg.Twitch.ClientSecret != "" {
+				handlers.InitTwitchMonitor(cfg.Twitch)
+			}
+
+			savedPath, total, err := handlers.DownloadTwitchChatToFile(videoID, nil, nil)
+			if err != nil {
+				return err
+			}
+			return printJSON(map[string]interface{}{
+				"video_id":       videoID,
+				"total_comments": total,
+				"file_path":      savedPath,
+			})
+		},
+	}
+	cmd.Flags().StringVar(&videoID, "video", "", "Twitch video ID")
+	_ = cmd.MarkFlagRequired("video")
+	return cmd
+}
+
+// newCompressChatLogsCmd compresses existing uncompressed chat logs in ./chat_logs
+func newCompressChatLogsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "compress-chat-logs",
+		Short: "Gzip-compress existing chat logs in chat_logs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stats, err := chatdownload.CompressLogs()
+			if err != nil {
+				return err
+			}
+			return printJSON(stats)
+		},
+	}
+}
+
+// newMigrateStorageCmd imports existing chat logs, analysis results and summaries into the SQLite index
+func newMigrateStorageCmd() *cobra.Command {
+	var dbPath string
+
+	cmd := &cobra.Command{
+		Use:   "migrate-storage",
+		Short: "Import existing chat_logs and analysis_results files into the SQLite index",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := storage.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			stats, err := store.ImportFiles(chatdownload.LogDir, "./analysis_results")
+			if err != nil {
+				return err
+			}
+			return printJSON(stats)
+		},
+	}
+	cmd.Flags().StringVar(&dbPath, "db", storage.DefaultPath, "SQLite database file")
+	return cmd
+}
+
+// newSummarizeCmd summarizes a subtitle file with the configured AI provider
+func newSummarizeCmd() *cobra.Command {
+	var srtPath string
+
+	cmd := &cobra.Command{
+		Use:   "summarize",
+		Short: "Summarize an SRT subtitle file with the configured AI provider",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			initConfig()
+
+			summary, err := handlers.SummarizeSRTFile(cmd.Context(), srtPath)
+			if err != nil {
+				return err
+			}
+			fmt.Println(summary)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&srtPath, "srt", "", "SRT subtitle file")
+	_ = cmd.MarkFlagRequired("srt")
+	return cmd
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// runServer starts the monitors and the HTTP API server
+func runServer() {
+	cfg, configErr := initConfig()
+
+	// 结构化日志（标准库 log 的输出也会转为相同格式）
+	if err := logging.Configure(cfg.Logging); err != nil {
+		_ = logging.Configure(logging.Config{})
+		log.Printf("警告: 日志配置无效，使用默认设置: %v", err)
+		handlers.SetLoggingConfig(logging.Config{})
+	}
+
+	// 缺少 secrets.required 中列出的密钥时拒绝启动
+	if err := cfg.ValidateSecrets(); err != nil {
+		log.Fatalf("启动失败: %v", err)
+	}
+
+	// OpenTelemetry 追踪（未启用时为 no-op）
+	shutdownTracing, err := handlers.InitTracing(cfg.Tracing)
+	if err != nil {
+		log.Printf("警告: 初始化追踪失败: %v", err)
+	} else {
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = shutdownTracing(ctx)
+		}()
+	}
+
+	// 功能开关（管理接口修改后的值会持久化并优先生效）
+	handlers.InitFeatureFlags(cfg.Features)
+
+	// 定时任务的计划与时区（清理、持久化、摘要邮件等）
+	if err := cfg.Schedule.Validate(); err != nil {
+		log.Printf("警告: 定时任务配置无效，使用默认计划: %v", err)
+		handlers.SetScheduleConfig(handlers.ScheduleConfig{})
+	}
+
+	// 静默时段内推迟的重负载任务，时段结束后自动执行
+	if err := cfg.QuietHours.Validate(); err != nil {
+		log.Printf("警告: 静默时段配置无效，已忽略: %v", err)
+		handlers.SetQuietHoursConfig(handlers.QuietHoursConfig{})
+	}
+	handlers.StartDeferredJobRunner()
+
+	// 订阅通知摘要邮件
+	handlers.StartNotificationDigest()
+
+	// 用户使用量统计
+	if err := cfg.Usage.Validate(); err != nil {
+		log.Printf("警告: 使用量限额配置无效，已忽略: %v", err)
+		handlers.SetUsageConfig(handlers.UsageConfig{})
+	}
+	handlers.InitUsageTracking()
+
+	// 邮件登录链接
+	if err := cfg.Auth.Validate(); err != nil {
+		log.Printf("警告: 登录链接配置无效，已禁用: %v", err)
+		// 会话令牌的签名密钥与有效期仍然生效，旧的 UserInfo Cookie 不再接受
+		handlers.SetAuthConfig(handlers.AuthConfig{
+			SessionSecret:   cfg.Auth.SessionSecret,
+			SessionTTLHours: max(cfg.Auth.SessionTTLHours, 0),
+		})
+	}
+
+	// 嵌入页与 oEmbed 使用的对外地址
+	if err := cfg.Server.Validate(); err != nil {
+		log.Printf("警告: 对外访问地址配置无效，已忽略: %v", err)
+		handlers.SetServerConfig(handlers.ServerConfig{})
+	}
+
+	// 语音识别提供商与故障转移顺序
+	if err := cfg.ASR.Validate(); err != nil {
+		log.Printf("警告: 语音识别配置无效，使用必剪语音识别: %v", err)
+		handlers.SetASRConfig(handlers.ASRConfig{})
+	}
+
+	// Discord / Telegram 推送
+	if err := cfg.Webhooks.Validate(); err != nil {
+		log.Printf("警告: Webhook 配置无效，已禁用推送: %v", err)
+		handlers.SetWebhooksConfig(handlers.WebhooksConfig{})
+	}
+
+	// 按录像信息定制处理流程的规则
+	if err := cfg.Pipeline.Validate(); err != nil {
+		log.Printf("警告: 流水线规则无效，已忽略: %v", err)
+		handlers.SetPipelineConfig(handlers.PipelineConfig{})
+	}
+
+	// 内容举报的自动下架
+	if err := cfg.Moderation.Validate(); err != nil {
+		log.Printf("警告: 内容举报配置无效，已禁用自动下架: %v", err)
+		handlers.SetModerationConfig(handlers.ModerationConfig{})
+	}
+
+	// 旧片段移到对象存储（冷存储）
+	if err := cfg.Storage.Validate(); err != nil {
+		log.Printf("警告: 存储分层配置无效，已禁用: %v", err)
+		handlers.SetStorageConfig(handlers.StorageConfig{})
+	}
+	handlers.StartStorageTiering()
+	handlers.StartStorageRetention()
+
+	// 聊天记录、分析结果与AI总结的 SQLite 索引（打开失败时回退到扫描文件）
+	if err := handlers.InitStorageIndex(storage.DefaultPath); err != nil {
+		log.Printf("警告: 打开存储索引失败，将直接读取文件: %v", err)
+	}
 
 	// 初始化 RPC 服务（在主播缓存之前初始化，因为清理任务需要 RPC 服务）
 	if cfg.RPC.Address != "" {
@@ -83,13 +346,25 @@ func main() {
 		}
 	}
 
-	r := gin.Default()
+	// 恢复上次退出时未完成的后台任务
+	handlers.ResumePersistedJobs()
+
+	// 配置热加载（仅在成功读取配置文件时启用文件监听）
+	if configErr == nil {
+		config.Watch()
+	}
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(otelgin.Middleware("subtuber-services"))
+	r.Use(handlers.RequestLogMiddleware())
 
 	// CORS middleware for frontend development
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Admin-Token, X-Impersonation-Token, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -98,6 +373,7 @@ func main() {
 
 		c.Next()
 	})
+	r.Use(handlers.UsageMiddleware())
 
 	// register legacy API routes
 	registerAPIs(r)
@@ -0,0 +1,85 @@
+package models
+
+import "strings"
+
+// 聊天消息所属平台
+const (
+	ChatPlatformTwitch  = "twitch"
+	ChatPlatformYouTube = "youtube"
+)
+
+// ChatMessage 与平台无关的聊天消息，各平台的聊天记录先转换为它再进行分析
+// 新增平台只需要提供对应的转换函数
+type ChatMessage struct {
+	Platform      string      `json:"platform"`
+	VideoID       string      `json:"video_id"`
+	ID            string      `json:"id,omitempty"`
+	AuthorID      string      `json:"author_id,omitempty"`
+	Author        string      `json:"author"`
+	Text          string      `json:"text"`
+	OffsetSeconds float64     `json:"offset_seconds"` // 相对录像开始的时间偏移（秒）
+	Timestamp     string      `json:"timestamp,omitempty"`
+	Bits          int         `json:"bits,omitempty"`
+	Emotes        []ChatEmote `json:"emotes,omitempty"` // 平台标记的表情，YouTube 聊天记录没有表情信息
+}
+
+// ChatEmote 消息中平台标记的一个表情
+type ChatEmote struct {
+	Name string `json:"name"`
+	ID   string `json:"id,omitempty"`
+}
+
+// ChatMessageFromTwitch 将 Twitch 评论转换为 ChatMessage
+func ChatMessageFromTwitch(comment TwitchChatComment) ChatMessage {
+	msg := ChatMessage{
+		Platform:      ChatPlatformTwitch,
+		VideoID:       comment.ContentID,
+		ID:            comment.ID,
+		AuthorID:      comment.Commenter.ID,
+		Author:        comment.Commenter.DisplayName,
+		Text:          comment.Message.Body,
+		OffsetSeconds: comment.ContentOffsetSeconds,
+		Timestamp:     comment.CreatedAt,
+		Bits:          comment.Message.BitsSpent,
+	}
+	for _, frag := range comment.Message.Fragments {
+		if frag.Emoticon == nil {
+			continue
+		}
+		if name := strings.TrimSpace(frag.Text); name != "" {
+			msg.Emotes = append(msg.Emotes, ChatEmote{Name: name, ID: frag.Emoticon.EmoticonID})
+		}
+	}
+	return msg
+}
+
+// ChatMessageFromYouTube 将 YouTube 聊天记录转换为 ChatMessage
+func ChatMessageFromYouTube(chat YoutubeChatLog) ChatMessage {
+	return ChatMessage{
+		Platform:      ChatPlatformYouTube,
+		VideoID:       chat.VideoID,
+		ID:            chat.ChatNo,
+		Author:        chat.Author,
+		Text:          chat.Message,
+		OffsetSeconds: chat.OffsetSeconds,
+		Timestamp:     chat.Timestamp,
+	}
+}
+
+// ChatMessagesFromTwitch 批量转换 Twitch 评论
+func ChatMessagesFromTwitch(comments []TwitchChatComment) []ChatMessage {
+	messages := make([]ChatMessage, 0, len(comments))
+	for _, comment := range comments {
+		messages = append(messages, ChatMessageFromTwitch(comment))
+	}
+	return messages
+}
+
+// ChatMessagesFromYouTube 批量转换 YouTube 聊天记录
+func ChatMessagesFromYouTube(chats []YoutubeChatLog) []ChatMessage {
+	messages := make([]ChatMessage, 0, len(chats))
+	for _, chat := range chats {
+		messages = append(messages, ChatMessageFromYouTube(chat))
+	}
+	return messages
+}
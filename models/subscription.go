@@ -21,6 +21,15 @@ type SubscriptionResponse struct {
 	Success      bool          `json:"success"`
 	Message      string        `json:"message"`
 	Subscription *Subscription `json:"subscription,omitempty"`
+	Channel      *ChannelInfo  `json:"channel,omitempty"` // 校验后的规范频道信息
+}
+
+// ChannelInfo 平台频道的规范标识
+type ChannelInfo struct {
+	Platform  string `json:"platform"`
+	ChannelID string `json:"channel_id"`
+	Handle    string `json:"handle,omitempty"`
+	Name      string `json:"name"`
 }
 
 // SubscriptionListResponse 订阅列表响应
@@ -47,11 +47,26 @@ type StreamerPlatform struct {
 
 // StreamerInfo 主播信息
 type StreamerInfo struct {
-	ID               string             `json:"id"`   //全部小写的主播id
-	Name             string             `json:"name"` // 主播显示名称
-	Platforms        []StreamerPlatform `json:"platforms"`
-	ProfileImageURL  string             `json:"profile_image_url,omitempty"`
-	YouTubeChannelID string             `json:"youtube_channel_id,omitempty"` // YouTube真实频道ID（UC开头）
+	ID               string              `json:"id"`   //全部小写的主播id
+	Name             string              `json:"name"` // 主播显示名称
+	Platforms        []StreamerPlatform  `json:"platforms"`
+	ProfileImageURL  string              `json:"profile_image_url,omitempty"`
+	YouTubeChannelID string              `json:"youtube_channel_id,omitempty"` // YouTube真实频道ID（UC开头）
+	Visibility       string              `json:"visibility,omitempty"`         // 公开主页可见性：public（默认）或 private
+	Aliases          []string            `json:"aliases,omitempty"`            // 曾用显示名称，显示名称变化时自动记录
+	Monitoring       *StreamerMonitoring `json:"monitoring,omitempty"`         // 监控计划，为空时按默认间隔检查
+}
+
+// StreamerMonitoring 主播的直播状态检查计划
+type StreamerMonitoring struct {
+	// Priority 优先级：high 检查间隔减半，low 为默认间隔的 3 倍，为空表示 normal
+	Priority string `json:"priority,omitempty"`
+	// CheckIntervalSeconds 固定检查间隔（秒），设置后优先于优先级
+	CheckIntervalSeconds int `json:"check_interval_seconds,omitempty"`
+	// ActiveHours 常开播时段（"HH:MM-HH:MM"，可跨午夜），时段外未直播时降低检查频率
+	ActiveHours []string `json:"active_hours,omitempty"`
+	// Timezone ActiveHours 使用的时区（IANA 名称），为空时使用服务器本地时区
+	Timezone string `json:"timezone,omitempty"`
 }
 
 // TrackedStreamers 追踪的主播列表
@@ -184,31 +184,8 @@ type TwitchGQLCommentResponse struct {
 			ID       string `json:"id"`
 			Comments struct {
 				Edges []struct {
-					Node struct {
-						ID                   string    `json:"id"`
-						CreatedAt            time.Time `json:"createdAt"`
-						ContentOffsetSeconds int       `json:"contentOffsetSeconds"`
-						Commenter            *struct {
-							ID          string `json:"id"`
-							Login       string `json:"login"`
-							DisplayName string `json:"displayName"`
-						} `json:"commenter"`
-						Message struct {
-							Fragments []struct {
-								Text  string `json:"text"`
-								Emote *struct {
-									EmoteID string `json:"emoteID"`
-								} `json:"emote"`
-							} `json:"fragments"`
-							UserBadges []struct {
-								ID      string `json:"id"`
-								SetID   string `json:"setID"`
-								Version string `json:"version"`
-							} `json:"userBadges"`
-							UserColor string `json:"userColor"`
-						} `json:"message"`
-					} `json:"node"`
-					Cursor string `json:"cursor"`
+					Node   TwitchGQLCommentNode `json:"node"`
+					Cursor string               `json:"cursor"`
 				} `json:"edges"`
 				PageInfo struct {
 					HasNextPage     bool `json:"hasNextPage"`
@@ -219,6 +196,32 @@ type TwitchGQLCommentResponse struct {
 	} `json:"data"`
 }
 
+// TwitchGQLCommentNode GraphQL评论节点
+type TwitchGQLCommentNode struct {
+	ID                   string    `json:"id"`
+	CreatedAt            time.Time `json:"createdAt"`
+	ContentOffsetSeconds int       `json:"contentOffsetSeconds"`
+	Commenter            *struct {
+		ID          string `json:"id"`
+		Login       string `json:"login"`
+		DisplayName string `json:"displayName"`
+	} `json:"commenter"`
+	Message struct {
+		Fragments []struct {
+			Text  string `json:"text"`
+			Emote *struct {
+				EmoteID string `json:"emoteID"`
+			} `json:"emote"`
+		} `json:"fragments"`
+		UserBadges []struct {
+			ID      string `json:"id"`
+			SetID   string `json:"setID"`
+			Version string `json:"version"`
+		} `json:"userBadges"`
+		UserColor string `json:"userColor"`
+	} `json:"message"`
+}
+
 // TwitchGQLRequest GraphQL请求
 type TwitchGQLRequest struct {
 	OperationName string                 `json:"operationName"`
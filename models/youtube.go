@@ -38,6 +38,20 @@ type YouTubeSearchResponse struct {
 	} `json:"items"`
 }
 
+// YouTubePlaylistItemsResponse YouTube播放列表条目API响应（用于读取频道的上传列表）
+type YouTubePlaylistItemsResponse struct {
+	NextPageToken string `json:"nextPageToken"`
+	PageInfo      struct {
+		TotalResults int `json:"totalResults"`
+	} `json:"pageInfo"`
+	Items []struct {
+		ContentDetails struct {
+			VideoID          string `json:"videoId"`
+			VideoPublishedAt string `json:"videoPublishedAt"`
+		} `json:"contentDetails"`
+	} `json:"items"`
+}
+
 // YouTubeVideoResponse YouTube视频详情API响应
 type YouTubeVideoResponse struct {
 	Items []YouTubeVideoItem `json:"items"`
@@ -56,6 +70,8 @@ type YouTubeVideoItem struct {
 				URL string `json:"url"`
 			} `json:"high"`
 		} `json:"thumbnails"`
+		DefaultAudioLanguage string `json:"defaultAudioLanguage,omitempty"` // 默认音频语言（可能为空）
+		LiveBroadcastContent string `json:"liveBroadcastContent,omitempty"` // live、upcoming 或 none
 	} `json:"snippet"`
 	LiveStreamingDetails *struct {
 		ActualStartTime    string `json:"actualStartTime"`
@@ -0,0 +1,115 @@
+// Package pathsafe 统一处理写入磁盘的文件名与路径：
+// 录像标题、视频ID、偏移秒数等外部数据在拼接成路径前都应经过这里清理，
+// 防止路径穿越、非法字符、Unicode 写法不同导致的重复文件，以及清理后不同名称落到同一个文件
+package pathsafe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxNameBytes 单个文件名组件的最大字节数，留出扩展名与后缀的余量
+const MaxNameBytes = 100
+
+// suffixLen Unique 附加的哈希后缀长度（十六进制字符数）
+const suffixLen = 8
+
+// illegalChars Windows 与 Unix 文件名中不允许或有特殊含义的字符
+const illegalChars = `<>:"/\|?*`
+
+// reservedNames Windows 保留的设备名，即使带扩展名也不能作为文件名
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Name 将任意字符串清理为安全的单个文件名组件：
+// NFC 规范化，非法字符与路径分隔符替换为下划线，去除控制字符与不可见的格式字符（如从右到左覆盖符），
+// 合并空白，去掉首尾的点与空格（"." 与 ".." 因此不会出现），按字节数截断且不截断多字节字符
+// 结果为空时返回 "_"
+func Name(s string) string {
+	s = norm.NFC.String(s)
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == utf8.RuneError:
+			b.WriteRune('_')
+		case strings.ContainsRune(illegalChars, r):
+			b.WriteRune('_')
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+			// 丢弃
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	name := strings.Trim(strings.Join(strings.Fields(b.String()), " "), ". ")
+	name = strings.TrimRight(truncate(name, MaxNameBytes), ". ")
+	if name == "" {
+		return "_"
+	}
+	if base, _, _ := strings.Cut(name, "."); reservedNames[strings.ToUpper(base)] {
+		name = "_" + name
+	}
+	return name
+}
+
+// Unique 与 Name 相同，但清理改变了（规范化后的）原始字符串时附加其短哈希，
+// 使 "a/b" 与 "a:b" 这类清理后相同的名称仍对应不同的文件；无需清理的名称保持不变
+func Unique(s string) string {
+	s = norm.NFC.String(s)
+	name := Name(s)
+	if name == s {
+		return name
+	}
+	sum := sha256.Sum256([]byte(s))
+	suffix := "_" + hex.EncodeToString(sum[:])[:suffixLen]
+	return strings.TrimRight(truncate(name, MaxNameBytes-len(suffix)), ". ") + suffix
+}
+
+// Join 将各组件清理后拼接到 base 下，结果保证位于 base 之内
+// 组件中的路径分隔符会被替换，因此每个组件只对应一级目录或文件名
+func Join(base string, elem ...string) string {
+	parts := make([]string, 0, len(elem)+1)
+	parts = append(parts, base)
+	for _, e := range elem {
+		parts = append(parts, Name(e))
+	}
+	return filepath.Join(parts...)
+}
+
+// Seconds 将偏移秒数格式化为文件名使用的 "%f" 形式（与已有文件名保持一致），
+// NaN 与无穷大等非法值返回 "invalid"，避免生成无法对应的文件
+func Seconds(offset float64) string {
+	if math.IsNaN(offset) || math.IsInf(offset, 0) {
+		return "invalid"
+	}
+	return fmt.Sprintf("%f", offset)
+}
+
+// truncate 按字节数截断字符串，不截断多字节字符
+func truncate(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := 0
+	for i := range s {
+		if i > maxBytes {
+			break
+		}
+		cut = i
+	}
+	return s[:cut]
+}
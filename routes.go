@@ -21,41 +21,141 @@ func registerAPIs(r *gin.Engine) {
 		})
 	})
 
+	// Prometheus metrics (pipeline SLO)
+	r.GET("/metrics", handlers.GetMetrics)
+
+	// Embeddable hot moment pages and oEmbed discovery
+	r.GET("/embed/moments/:videoID/:offset", handlers.GetMomentEmbed)
+	r.GET("/embed/moments/:videoID/:offset/clip", handlers.GetMomentEmbedClip)
+	r.GET("/oembed", handlers.GetOEmbed)
+
+	// Read-only research API (issued API keys, rate limited)
+	handlers.RegisterResearchRoutes(r)
+
+	// OBS browser-source overlay: live hotness meter and chat spike alerts
+	r.GET("/overlay/:streamerID", handlers.GetOverlayPage)
+	r.GET("/overlay/:streamerID/state", handlers.GetOverlayState)
+
+	// Versioned API
+	registerAPIRoutes(r.Group(handlers.APIV1Prefix))
+
+	// 未版本化的旧路径保留为兼容层，响应带弃用提示
+	registerAPIRoutes(r.Group(handlers.LegacyAPIPrefix, handlers.DeprecatedAPIMiddleware()))
+}
+
+// registerAPIRoutes registers the API surface on a prefix group (/api/v1 or the legacy /api).
+func registerAPIRoutes(api *gin.RouterGroup) {
 	// API endpoints for frontend
-	r.GET("/api/time", func(c *gin.Context) {
+	api.GET("/time", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"time": time.Now().Format(time.RFC3339)})
 	})
 
 	// Authentication routes (send code / verify)
-	handlers.RegisterAuthRoutes(r)
+	handlers.RegisterAuthRoutes(api)
+
+	// Admin routes (require X-Admin-Token)
+	handlers.RegisterAdminRoutes(api)
 
 	// Twitch monitoring routes
-	r.GET("/api/twitch/status/:streamer_id", handlers.GetTwitchStatus)
-	r.POST("/api/twitch/check-now", handlers.CheckTwitchStatusNow)
+	api.GET("/twitch/status/:streamer_id", handlers.GetTwitchStatus)
+	api.POST("/twitch/check-now", handlers.CheckTwitchStatusNow)
+
+	// YouTube Data API quota usage per API key for the current quota day
+	api.GET("/youtube/quota", handlers.GetYouTubeQuota)
 
 	// Streaming status route
-	r.GET("/api/streaming/status/:streamer_id", handlers.GetStreamingStatus)
+	api.GET("/streaming/status/:streamer_id", handlers.GetStreamingStatus)
 
 	// Twitch VOD chat download routes
-	r.POST("/api/twitch/download-chat", handlers.DownloadVODChat)
-	r.POST("/api/twitch/save-chat", handlers.SaveVODChatToFile)
+	api.POST("/twitch/download-chat", handlers.DownloadVODChat)
+	api.POST("/twitch/save-chat", handlers.SaveVODChatToFile)
+
+	// Search downloaded VOD chat logs by text, author and time range
+	api.GET("/chats/:videoID/search", handlers.SearchChat)
 
 	// Twitch chat analysis routes
-	r.GET("/api/twitch/analysis/:videoID", handlers.GetAnalysisResult)
-	r.GET("/api/twitch/analysis", handlers.ListAnalysisResults)
-	r.GET("/api/twitch/analysis-summary", handlers.GetAnalysisSummary)
+	api.GET("/twitch/analysis/:videoID", handlers.GetAnalysisResult)
+	api.GET("/twitch/analysis", handlers.ListAnalysisResults)
+	api.GET("/twitch/analysis-summary", handlers.GetAnalysisSummary)
+	api.GET("/analysis/detectors", handlers.GetPeakDetectors)
+	api.POST("/analysis/sweep", handlers.SweepAnalysisParams)
+	api.GET("/analysis/:videoID/export", handlers.ExportAnalysisResult)
+	api.GET("/analysis/:videoID/chapters", handlers.GetAnalysisChapters)
+	api.POST("/analysis/:videoID/range", handlers.AnalyzeVODRange)
+	api.GET("/analysis/:videoID/clips/sync", handlers.GetClipSync)
+	api.GET("/analysis/:videoID/drift", handlers.GetVODDrift)
+	api.GET("/analysis/:videoID/timeline", handlers.GetAnalysisTimeline)
+	api.GET("/analysis/:videoID/emotes", handlers.GetEmoteStats)
+	api.GET("/analysis/:videoID/summary/stream", handlers.StreamMomentSummary)
+
+	// Re-cut hot moment clips of an existing analysis result with new clip settings
+	api.POST("/clips/generate", handlers.GenerateClips)
+	// Generated clips and their subtitles, downloads support range requests for embedding
+	api.GET("/clips/:videoID", handlers.ListClips)
+	api.GET("/clips/:videoID/:clipID/download", handlers.DownloadClip)
+	api.GET("/clips/:videoID/:clipID/thumbnail", handlers.GetClipThumbnail)
+
+	// Clip subtitles as SRT or WebVTT, optionally shifted to align with the full VOD
+	api.GET("/subtitles/:videoID", handlers.ListSubtitles)
+	api.GET("/subtitles/:videoID/:clipID", handlers.GetClipSubtitle)
+
+	// Live chat firehose for tracked streamers that are currently live (WebSocket)
+	api.GET("/live/:streamerID/chat", handlers.StreamLiveChat)
+
+	// Background pipeline jobs and per-VOD job progress (Server-Sent Events)
+	api.GET("/jobs", handlers.ListJobs)
+	api.GET("/jobs/:id", handlers.GetJobProgress)
+	api.GET("/jobs/:id/events", handlers.StreamJobEvents)
+	api.POST("/jobs/:id/retry", handlers.RetryJob)
+
+	// Disk usage and retention limits of the data directories (require X-Admin-Token)
+	api.GET("/storage/usage", handlers.AdminAuthMiddleware(), handlers.GetStorageUsage)
+
+	// Resolve any Twitch/YouTube URL to its canonical platform, type and IDs
+	api.POST("/resolve", handlers.ResolveURL)
 
 	// 获取订阅主播市场的列表
-	r.GET("/api/streamers", handlers.ListStreamers)
-	r.GET("/api/streamers/:id", handlers.GetStreamerVODsByStreamerID)
+	api.GET("/streamers", handlers.ListStreamers)
+	api.GET("/streamers/:id", handlers.GetStreamerVODsByStreamerID)
+	api.GET("/streamers/:id/vods", handlers.GetStreamerPlatformVODs)
+	api.GET("/streamers/:id/diagnostics", handlers.GetStreamerDiagnostics)
+	api.GET("/streamers/:id/heatmap", handlers.GetStreamerHeatmap)
+	api.GET("/streamers/:id/analytics", handlers.GetStreamerAnalytics)
+
+	// Streamer ownership claims and owner management
+	api.GET("/streamers/:id/claim", handlers.GetStreamerClaimStatus)
+	api.POST("/streamers/:id/claim", handlers.StartStreamerClaim)
+	api.POST("/streamers/:id/claim/verify", handlers.VerifyStreamerClaim)
+	api.DELETE("/streamers/:id/claim", handlers.ReleaseStreamerClaim)
+	api.POST("/reports", handlers.SubmitAbuseReport)
+	owner := api.Group("/streamers/:id/owner", handlers.StreamerOwnerMiddleware())
+	owner.PUT("/settings", handlers.UpdateOwnerSettings)
+	owner.PUT("/moments", handlers.ModerateHotMoment)
+	owner.GET("/analytics", handlers.GetOwnerAnalytics)
+
+	// Public (embeddable) streamer profile
+	api.GET("/public/streamers/:id", handlers.GetPublicStreamerProfile)
 
 	// Streamer subscription routes
-	r.POST("/api/streamers/subscribe", handlers.SubscribeStreamer)
-
-	// User subscription routes
-	r.GET("/api/user/subscriptions", handlers.GetUserSubscriptions)
-	r.POST("/api/user/subscriptions", handlers.AddUserSubscription)
-	r.DELETE("/api/user/subscriptions", handlers.RemoveUserSubscription)
-	r.GET("/api/user/subscriptions/check", handlers.CheckUserSubscription)
-	r.GET("/api/user/subscriptions/count", handlers.GetUserSubscriptionCount)
+	api.POST("/streamers/subscribe", handlers.SubscribeStreamer)
+	api.POST("/streamers/onboard", handlers.OnboardStreamer)
+
+	// User routes (require a session token, see handlers.RequireSession)
+	user := api.Group("/user", handlers.RequireSession())
+	user.GET("/subscriptions", handlers.GetUserSubscriptions)
+	user.POST("/subscriptions", handlers.AddUserSubscription)
+	user.DELETE("/subscriptions", handlers.RemoveUserSubscription)
+	user.GET("/subscriptions/check", handlers.CheckUserSubscription)
+	user.GET("/subscriptions/count", handlers.GetUserSubscriptionCount)
+	user.GET("/usage", handlers.GetUserUsage)
+	user.GET("/whats-new", handlers.GetWhatsNew)
+	user.POST("/whats-new/ack", handlers.AcknowledgeWhatsNew)
+	user.GET("/preferences", handlers.GetUserPreferencesHandler)
+	user.PUT("/preferences", handlers.UpdateUserPreferences)
+	user.POST("/email/change", handlers.RequestEmailChange)
+	user.POST("/email/verify", handlers.VerifyEmailChange)
+	user.GET("/subscriptions/:streamerID/preferences", handlers.GetSubscriptionPreferencesHandler)
+	user.PUT("/subscriptions/:streamerID/preferences", handlers.UpdateSubscriptionPreferences)
+	api.GET("/notifications", handlers.RequireSession(), handlers.GetNotifications)
+	api.POST("/notifications/read", handlers.RequireSession(), handlers.MarkNotificationsRead)
 }
@@ -24,6 +24,9 @@ type BcutASR struct {
 	clips       int
 	etags       []string
 	downloadURL string
+
+	// OnState 轮询识别结果时回调任务状态（可选）
+	OnState func(state int)
 }
 
 // ASRSegment 字幕片段
@@ -386,6 +389,9 @@ func (b *BcutASR) Run() (*ASRResult, error) {
 		}
 
 		state := int(taskData["state"].(float64))
+		if b.OnState != nil {
+			b.OnState(state)
+		}
 
 		// state: 4表示完成
 		if state == 4 {
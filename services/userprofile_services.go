@@ -59,6 +59,58 @@ func UpdateUserMaxTrackingLimitRPC(userID int, userHash, email string, newLimit
 	return nil
 }
 
+// UpdateUserEmailRPC 将用户的邮箱及其派生的 userHash 更新为新值，并迁移订阅关系（使用共享连接）
+func UpdateUserEmailRPC(oldHash, newHash, newEmail string) error {
+	service := GetStreamerService()
+	if service == nil {
+		return fmt.Errorf("服务未初始化，请先调用 InitStreamerService")
+	}
+
+	user, err := GetUserByHashFromRPC(oldHash)
+	if err != nil {
+		return err
+	}
+	subs, err := GetUserSubscriptions(oldHash)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), service.config.Timeout)
+	defer cancel()
+
+	resp, err := service.userRpc.UpdateUser(ctx, &subtube.UpdateUserRequest{
+		Id:               user.Id,
+		UserHash:         newHash,
+		Email:            newEmail,
+		MaxTrackingLimit: user.MaxTrackingLimit,
+	})
+	if err != nil {
+		return fmt.Errorf("更新用户信息失败: %v", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("更新用户信息失败: %s", resp.Message)
+	}
+
+	// 订阅按 userHash 关联，逐条迁移到新的 userHash
+	for _, sub := range subs.Subscriptions {
+		if sub.UserHash != oldHash {
+			continue
+		}
+		if exists, err := CheckSubscriptionExists(newHash, sub.StreamerId); err == nil && exists {
+			continue
+		}
+		if _, err := CreateSubscription(newHash, sub.StreamerId); err != nil {
+			return err
+		}
+		if err := DeleteUserStreamerSubscription(oldHash, sub.StreamerId); err != nil {
+			log.Printf("删除旧订阅失败 %s -> %s: %v", oldHash, sub.StreamerId, err)
+		}
+	}
+
+	log.Printf("成功将用户 %d 的邮箱更新为 %s", user.Id, newEmail)
+	return nil
+}
+
 // ========== 订阅相关服务 ==========
 
 // GetUserSubscriptions 获取用户订阅的所有主播
@@ -0,0 +1,266 @@
+package storage
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"subtuber-services/chatdownload"
+	"subtuber-services/models"
+)
+
+// ImportStats 导入已有文件的统计
+type ImportStats struct {
+	ChatLogs     int `json:"chat_logs"`
+	AnalysisRuns int `json:"analysis_runs"`
+	Summaries    int `json:"summaries"`
+	Skipped      int `json:"skipped"` // 已记录过的文件
+	Failed       int `json:"failed"`
+}
+
+// ImportFiles 将 chat_logs 与 analysis_results 目录中尚未记录的文件导入数据库
+// 已记录的文件直接跳过，可以重复执行；单个文件失败不影响其他文件
+func (s *Store) ImportFiles(chatDir, analysisDir string) (ImportStats, error) {
+	var stats ImportStats
+	if err := s.importChatLogs(chatDir, &stats); err != nil {
+		return stats, err
+	}
+	if err := s.importAnalysisDir(analysisDir, &stats); err != nil {
+		return stats, err
+	}
+	log.Printf("存储索引导入完成: 聊天记录 %d 个，分析结果 %d 个，AI总结 %d 个，跳过 %d 个，失败 %d 个",
+		stats.ChatLogs, stats.AnalysisRuns, stats.Summaries, stats.Skipped, stats.Failed)
+	return stats, nil
+}
+
+// parseChatLogName 从聊天记录文件名解析录像ID、平台与下载时间
+// 文件名为 chat_{videoID}_{日期}_{时间}.json[.gz] 或 .ndjson.gz，YouTube 为 chat_youtube_{videoID}_{日期}_{时间}.json[.gz]
+func parseChatLogName(path string) (videoID, platform string, downloadedAt time.Time, ok bool) {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, ".gz")
+	if trimmed, isStream := strings.CutSuffix(name, ".ndjson"); isStream {
+		name = trimmed
+	} else {
+		name = strings.TrimSuffix(name, ".json")
+	}
+	name, ok = strings.CutPrefix(name, "chat_")
+	if !ok {
+		return "", "", time.Time{}, false
+	}
+	platform = "twitch"
+	if rest, isYouTube := strings.CutPrefix(name, "youtube_"); isYouTube {
+		name, platform = rest, "youtube"
+	}
+
+	// 录像ID可能包含下划线（YouTube），从末尾去掉日期与时间
+	parts := strings.Split(name, "_")
+	if len(parts) < 3 {
+		return "", "", time.Time{}, false
+	}
+	stamp := parts[len(parts)-2] + "_" + parts[len(parts)-1]
+	downloadedAt, err := time.ParseInLocation("20060102_150405", stamp, time.Local)
+	if err != nil {
+		return "", "", time.Time{}, false
+	}
+	return strings.Join(parts[:len(parts)-2], "_"), platform, downloadedAt, true
+}
+
+// countChatLogComments 统计聊天记录文件中的评论数，Twitch 聊天记录逐条读取
+func countChatLogComments(path, platform string) (int, error) {
+	if platform != "youtube" {
+		count := 0
+		_, err := chatdownload.ReadTwitch(path, func(models.TwitchChatComment) error {
+			count++
+			return nil
+		})
+		return count, err
+	}
+
+	r, err := chatdownload.OpenLog(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	var logs []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&logs); err != nil {
+		return 0, err
+	}
+	return len(logs), nil
+}
+
+// importChatLogs 导入聊天记录目录，同一录像有多个文件时保留最新下载的
+func (s *Store) importChatLogs(chatDir string, stats *ImportStats) error {
+	paths, err := filepath.Glob(filepath.Join(chatDir, "chat_*json*"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if !strings.HasSuffix(path, ".json") && !strings.HasSuffix(path, chatdownload.CompressedExt) &&
+			!strings.HasSuffix(path, chatdownload.StreamExt) {
+			continue
+		}
+		videoID, platform, downloadedAt, ok := parseChatLogName(path)
+		if !ok {
+			continue
+		}
+		if known, err := s.hasChatLogPath(path); err != nil {
+			return err
+		} else if known {
+			stats.Skipped++
+			continue
+		}
+		if existing, err := s.GetChatLog(videoID, platform); err != nil {
+			return err
+		} else if existing != nil && existing.DownloadedAt.After(downloadedAt) {
+			stats.Skipped++
+			continue
+		}
+
+		comments, err := countChatLogComments(path, platform)
+		if err != nil {
+			log.Printf("导入聊天记录 %s 失败: %v", path, err)
+			stats.Failed++
+			continue
+		}
+		var size int64
+		if info, err := os.Stat(path); err == nil {
+			size = info.Size()
+		}
+		if err := s.PutChatLog(ChatLog{
+			VideoID:      videoID,
+			Platform:     platform,
+			Path:         path,
+			Comments:     comments,
+			Size:         size,
+			DownloadedAt: downloadedAt,
+		}); err != nil {
+			return err
+		}
+		stats.ChatLogs++
+	}
+	return nil
+}
+
+// importAnalysisDir 导入分析结果目录中每个录像的分析结果与AI总结
+func (s *Store) importAnalysisDir(analysisDir string, stats *ImportStats) error {
+	dirs, err := os.ReadDir(analysisDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		videoID := dir.Name()
+		videoDir := filepath.Join(analysisDir, videoID)
+
+		files, _ := filepath.Glob(filepath.Join(videoDir, "analysis_*.json"))
+		for _, file := range files {
+			if err := s.importAnalysisFile(videoID, file, stats); err != nil {
+				return err
+			}
+		}
+
+		summaries, _ := filepath.Glob(filepath.Join(videoDir, "*_summary.txt"))
+		for _, path := range summaries {
+			if err := s.importSummaryFile(videoID, path, stats); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// importAnalysisFile 导入单个分析结果文件，只有数据库错误会中止导入
+func (s *Store) importAnalysisFile(videoID, path string, stats *ImportStats) error {
+	file := filepath.Base(path)
+	if known, err := s.hasAnalysisRun(videoID, file); err != nil {
+		return err
+	} else if known {
+		stats.Skipped++
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("导入分析结果 %s 失败: %v", path, err)
+		stats.Failed++
+		return nil
+	}
+	var result struct {
+		StreamerName string            `json:"streamer_name"`
+		Method       string            `json:"method"`
+		HotMoments   []json.RawMessage `json:"hot_moments"`
+		VideoInfo    struct {
+			Title string `json:"title"`
+		} `json:"video_info"`
+		AnalyzedAt time.Time `json:"analyzed_at"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		log.Printf("导入分析结果 %s 失败: %v", path, err)
+		stats.Failed++
+		return nil
+	}
+
+	if err := s.PutAnalysisRun(AnalysisRun{
+		VideoID:      videoID,
+		File:         file,
+		StreamerName: result.StreamerName,
+		Title:        result.VideoInfo.Title,
+		Method:       result.Method,
+		HotMoments:   len(result.HotMoments),
+		AnalyzedAt:   result.AnalyzedAt,
+		Result:       data,
+	}); err != nil {
+		return err
+	}
+	stats.AnalysisRuns++
+	return nil
+}
+
+// importSummaryFile 导入单个AI总结文件（{热点秒数}_summary.txt），只有数据库错误会中止导入
+func (s *Store) importSummaryFile(videoID, path string, stats *ImportStats) error {
+	offsetSeconds, err := strconv.ParseFloat(strings.TrimSuffix(filepath.Base(path), "_summary.txt"), 64)
+	if err != nil {
+		return nil
+	}
+	if known, err := s.hasSummaryPath(path); err != nil {
+		return err
+	} else if known {
+		stats.Skipped++
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("导入AI总结 %s 失败: %v", path, err)
+		stats.Failed++
+		return nil
+	}
+	updatedAt := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		updatedAt = info.ModTime()
+	}
+
+	if err := s.PutSummary(Summary{
+		VideoID:       videoID,
+		OffsetSeconds: offsetSeconds,
+		Path:          path,
+		Summary:       string(data),
+		UpdatedAt:     updatedAt,
+	}); err != nil {
+		return err
+	}
+	stats.Summaries++
+	return nil
+}
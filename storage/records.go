@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ChatLog 已下载的聊天记录
+type ChatLog struct {
+	VideoID      string    `json:"video_id"`
+	Platform     string    `json:"platform"`
+	Path         string    `json:"path"`
+	Comments     int       `json:"comments"`
+	Size         int64     `json:"size"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// AnalysisRun 一次分析的结果，Result 为完整的分析结果 JSON
+type AnalysisRun struct {
+	VideoID      string    `json:"video_id"`
+	File         string    `json:"file"` // 分析结果文件名，如 analysis_420_0.90_210.json
+	StreamerName string    `json:"streamer_name"`
+	Title        string    `json:"title"`
+	Method       string    `json:"method"`
+	HotMoments   int       `json:"hot_moments"`
+	AnalyzedAt   time.Time `json:"analyzed_at"`
+	Result       []byte    `json:"-"`
+}
+
+// Summary 热点的AI总结
+type Summary struct {
+	VideoID       string    `json:"video_id"`
+	OffsetSeconds float64   `json:"offset_seconds"`
+	Path          string    `json:"path"`
+	Summary       string    `json:"summary"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PutChatLog 记录聊天记录文件，同一录像重新下载时覆盖
+func (s *Store) PutChatLog(c ChatLog) error {
+	_, err := s.db.Exec(`INSERT INTO chat_logs (video_id, platform, path, comments, size, downloaded_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (video_id, platform) DO UPDATE SET
+			path = excluded.path, comments = excluded.comments, size = excluded.size, downloaded_at = excluded.downloaded_at`,
+		c.VideoID, c.Platform, c.Path, c.Comments, c.Size, c.DownloadedAt.UTC())
+	return err
+}
+
+// GetChatLog 查找录像的聊天记录，不存在时返回 nil
+func (s *Store) GetChatLog(videoID, platform string) (*ChatLog, error) {
+	var c ChatLog
+	err := s.db.QueryRow(`SELECT video_id, platform, path, comments, size, downloaded_at
+		FROM chat_logs WHERE video_id = ? AND platform = ?`, videoID, platform).
+		Scan(&c.VideoID, &c.Platform, &c.Path, &c.Comments, &c.Size, &c.DownloadedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// hasChatLogPath 文件是否已记录
+func (s *Store) hasChatLogPath(path string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM chat_logs WHERE path = ?`, path).Scan(&n)
+	return n > 0, err
+}
+
+// PutAnalysisRun 记录分析结果，相同录像与文件名的结果覆盖
+func (s *Store) PutAnalysisRun(r AnalysisRun) error {
+	_, err := s.db.Exec(`INSERT INTO analysis_runs (video_id, file, streamer_name, title, method, hot_moments, analyzed_at, result)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (video_id, file) DO UPDATE SET
+			streamer_name = excluded.streamer_name, title = excluded.title, method = excluded.method,
+			hot_moments = excluded.hot_moments, analyzed_at = excluded.analyzed_at, result = excluded.result`,
+		r.VideoID, r.File, r.StreamerName, r.Title, r.Method, r.HotMoments, r.AnalyzedAt.UTC(), r.Result)
+	return err
+}
+
+// GetAnalysisRun 读取分析结果（包含完整 JSON），不存在时返回 nil
+func (s *Store) GetAnalysisRun(videoID, file string) (*AnalysisRun, error) {
+	var r AnalysisRun
+	err := s.db.QueryRow(`SELECT video_id, file, streamer_name, title, method, hot_moments, analyzed_at, result
+		FROM analysis_runs WHERE video_id = ? AND file = ?`, videoID, file).
+		Scan(&r.VideoID, &r.File, &r.StreamerName, &r.Title, &r.Method, &r.HotMoments, &r.AnalyzedAt, &r.Result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListAnalysisRuns 按分析时间倒序列出分析结果（不含完整 JSON），videoID 为空时列出全部录像
+func (s *Store) ListAnalysisRuns(videoID string) ([]AnalysisRun, error) {
+	rows, err := s.db.Query(`SELECT video_id, file, streamer_name, title, method, hot_moments, analyzed_at
+		FROM analysis_runs WHERE ? = '' OR video_id = ? ORDER BY analyzed_at DESC`, videoID, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []AnalysisRun
+	for rows.Next() {
+		var r AnalysisRun
+		if err := rows.Scan(&r.VideoID, &r.File, &r.StreamerName, &r.Title, &r.Method, &r.HotMoments, &r.AnalyzedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// hasAnalysisRun 分析结果是否已记录
+func (s *Store) hasAnalysisRun(videoID, file string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM analysis_runs WHERE video_id = ? AND file = ?`, videoID, file).Scan(&n)
+	return n > 0, err
+}
+
+// PutSummary 记录热点的AI总结，重新生成时覆盖
+func (s *Store) PutSummary(sum Summary) error {
+	_, err := s.db.Exec(`INSERT INTO summaries (video_id, offset_seconds, path, summary, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (video_id, offset_seconds) DO UPDATE SET
+			path = excluded.path, summary = excluded.summary, updated_at = excluded.updated_at`,
+		sum.VideoID, sum.OffsetSeconds, sum.Path, sum.Summary, sum.UpdatedAt.UTC())
+	return err
+}
+
+// ListSummaries 按热点时间列出录像的AI总结
+func (s *Store) ListSummaries(videoID string) ([]Summary, error) {
+	rows, err := s.db.Query(`SELECT video_id, offset_seconds, path, summary, updated_at
+		FROM summaries WHERE video_id = ? ORDER BY offset_seconds`, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sums []Summary
+	for rows.Next() {
+		var sum Summary
+		if err := rows.Scan(&sum.VideoID, &sum.OffsetSeconds, &sum.Path, &sum.Summary, &sum.UpdatedAt); err != nil {
+			return nil, err
+		}
+		sums = append(sums, sum)
+	}
+	return sums, rows.Err()
+}
+
+// hasSummaryPath 总结文件是否已记录
+func (s *Store) hasSummaryPath(path string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM summaries WHERE path = ?`, path).Scan(&n)
+	return n > 0, err
+}
+
+// DeleteChatLogPath 删除指定文件的聊天记录索引，文件已被清理时调用
+func (s *Store) DeleteChatLogPath(path string) error {
+	_, err := s.db.Exec(`DELETE FROM chat_logs WHERE path = ?`, path)
+	return err
+}
+
+// DeleteVideoAnalysis 删除录像的分析结果与AI总结索引，分析目录已被清理时调用
+func (s *Store) DeleteVideoAnalysis(videoID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM analysis_runs WHERE video_id = ?`, videoID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM summaries WHERE video_id = ?`, videoID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,129 @@
+// Package storage 以 SQLite 索引聊天记录、分析结果与AI总结，避免每次请求都扫描 chat_logs 与 analysis_results 目录
+// 原有的 JSON 文件仍然保留（其他功能直接读取这些文件），数据库中的记录与文件同时写入
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+// DefaultPath 默认的数据库文件
+const DefaultPath = "App_Data/lumitime.db"
+
+// schemaVersion 当前的表结构版本，保存在 PRAGMA user_version
+const schemaVersion = 1
+
+// schema 各版本的建表语句，按版本顺序执行
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS chat_logs (
+		video_id      TEXT NOT NULL,
+		platform      TEXT NOT NULL,
+		path          TEXT NOT NULL,
+		comments      INTEGER NOT NULL DEFAULT 0,
+		size          INTEGER NOT NULL DEFAULT 0,
+		downloaded_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (video_id, platform)
+	);
+	CREATE TABLE IF NOT EXISTS analysis_runs (
+		video_id      TEXT NOT NULL,
+		file          TEXT NOT NULL,
+		streamer_name TEXT NOT NULL DEFAULT '',
+		title         TEXT NOT NULL DEFAULT '',
+		method        TEXT NOT NULL DEFAULT '',
+		hot_moments   INTEGER NOT NULL DEFAULT 0,
+		analyzed_at   TIMESTAMP NOT NULL,
+		result        BLOB NOT NULL,
+		PRIMARY KEY (video_id, file)
+	);
+	CREATE INDEX IF NOT EXISTS idx_analysis_runs_analyzed_at ON analysis_runs (analyzed_at DESC);
+	CREATE TABLE IF NOT EXISTS summaries (
+		video_id       TEXT NOT NULL,
+		offset_seconds REAL NOT NULL,
+		path           TEXT NOT NULL,
+		summary        TEXT NOT NULL,
+		updated_at     TIMESTAMP NOT NULL,
+		PRIMARY KEY (video_id, offset_seconds)
+	);`,
+}
+
+// Store SQLite 索引
+type Store struct {
+	db *sql.DB
+}
+
+// Open 打开（不存在时创建）数据库并升级表结构
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建数据库目录失败: %w", err)
+	}
+
+	// WAL 模式下读取不会被写入阻塞；并发写入时等待而不是立即返回 SQLITE_BUSY
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+
+	s := &Store{db: db}
+	if err := s.migrateSchema(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrateSchema 执行尚未执行的建表语句
+func (s *Store) migrateSchema() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("读取数据库版本失败: %w", err)
+	}
+	for v := version; v < schemaVersion; v++ {
+		if _, err := s.db.Exec(schema[v]); err != nil {
+			return fmt.Errorf("升级数据库到版本 %d 失败: %w", v+1, err)
+		}
+		if _, err := s.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", v+1)); err != nil {
+			return fmt.Errorf("升级数据库到版本 %d 失败: %w", v+1, err)
+		}
+	}
+	return nil
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+var (
+	defaultStore   *Store
+	defaultStoreMu sync.RWMutex
+)
+
+// Init 打开默认数据库，之后通过 Default 获取
+func Init(path string) (*Store, error) {
+	s, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultStoreMu.Lock()
+	defer defaultStoreMu.Unlock()
+	if defaultStore != nil {
+		defaultStore.Close()
+	}
+	defaultStore = s
+	log.Printf("存储索引已打开: %s", path)
+	return s, nil
+}
+
+// Default 返回默认数据库，未初始化时返回 nil，调用方应回退到读取文件
+func Default() *Store {
+	defaultStoreMu.RLock()
+	defer defaultStoreMu.RUnlock()
+	return defaultStore
+}
@@ -0,0 +1,278 @@
+// Package twitchclient 提供 Twitch Helix 接口的统一客户端：应用凭据池与访问令牌、认证请求头、
+// 被限流（429）时轮换凭据或按 Ratelimit-Reset / Retry-After 等待、5xx 与网络错误的指数退避重试以及响应解码
+package twitchclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHelixURL = "https://api.twitch.tv/helix"
+	defaultTokenURL = "https://id.twitch.tv/oauth2/token"
+
+	defaultMaxRetries = 3
+	defaultBackoff    = 500 * time.Millisecond
+	// maxWait 429 或退避的最长等待时间，需要等待更久时直接返回错误，避免阻塞调用方
+	maxWait = 30 * time.Second
+)
+
+// APIError Helix 返回的非 2xx 响应
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Twitch API返回错误状态 %d: %s", e.StatusCode, e.Body)
+}
+
+// StatusCode 返回 err 中 Helix 响应的状态码，不是 APIError 时返回 0
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// Client Twitch Helix 客户端，可被多个调用方共用
+type Client struct {
+	http       *http.Client
+	helixURL   string
+	tokenURL   string
+	maxRetries int
+	backoff    time.Duration
+	pool       *credentialPool
+}
+
+// New 创建 Helix 客户端，credentials 中第一组为主凭据，相同 Client ID 只保留第一组；httpClient 可为 nil
+func New(httpClient *http.Client, credentials ...Credential) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &Client{
+		http:       httpClient,
+		helixURL:   defaultHelixURL,
+		tokenURL:   defaultTokenURL,
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+		pool:       newCredentialPool(credentials),
+	}
+}
+
+// WithRetry 设置 5xx、网络错误与限流的最大重试次数和首次退避时间，之后每次翻倍
+func (c *Client) WithRetry(maxRetries int, backoff time.Duration) *Client {
+	c.maxRetries = maxRetries
+	c.backoff = backoff
+	return c
+}
+
+// WithEndpoints 替换 Helix 与令牌接口地址，用于代理或模拟服务
+func (c *Client) WithEndpoints(helixURL, tokenURL string) *Client {
+	c.helixURL = strings.TrimSuffix(helixURL, "/")
+	c.tokenURL = tokenURL
+	return c
+}
+
+// EnsureToken 确保当前使用的凭据有有效的访问令牌，可用于检查凭据是否可用
+func (c *Client) EnsureToken(ctx context.Context) error {
+	state, err := c.pool.pick()
+	if err != nil {
+		return err
+	}
+	_, err = c.token(ctx, state)
+	return err
+}
+
+// Get 使用应用凭据请求 Helix 接口（path 如 "/users"），响应 JSON 解码到 out（可为 nil）
+// 被限流时先轮换到其他未限流的凭据，全部限流时等待配额重置后重试
+func (c *Client) Get(ctx context.Context, path string, query url.Values, out any) error {
+	return c.do(ctx, path, query, out, "", "")
+}
+
+// GetAsUser 使用用户 OAuth 令牌请求 Helix 接口，clientID 必须是签发该令牌的应用；不使用凭据池
+func (c *Client) GetAsUser(ctx context.Context, clientID, userToken, path string, query url.Values, out any) error {
+	return c.do(ctx, path, query, out, clientID, userToken)
+}
+
+// do 发送 GET 请求并处理重试；userToken 为空时使用凭据池
+func (c *Client) do(ctx context.Context, path string, query url.Values, out any, clientID, userToken string) error {
+	endpoint := c.helixURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	retries := 0
+	// 轮换凭据与重新获取令牌不计入重试次数，但最多每组凭据一次
+	switches := 0
+	for {
+		var state *credentialState
+		requestClientID, token := clientID, userToken
+		if userToken == "" {
+			var err error
+			if state, err = c.pool.pick(); err != nil {
+				return err
+			}
+			if token, err = c.token(ctx, state); err != nil {
+				return err
+			}
+			requestClientID = state.credential.ClientID
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Client-ID", requestClientID)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			if retries >= c.maxRetries || ctx.Err() != nil {
+				return err
+			}
+			if err := c.wait(ctx, c.backoffFor(retries)); err != nil {
+				return err
+			}
+			retries++
+			continue
+		}
+		if state != nil {
+			c.pool.record(state, resp)
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return decode(resp, out)
+		}
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			if state != nil && switches < c.pool.size() && c.pool.hasAvailable() {
+				log.Printf("Twitch 凭据 %s 被限流，尝试下一组凭据", MaskClientID(state.credential.ClientID))
+				switches++
+				continue
+			}
+			wait := retryAfter(resp)
+			if wait <= 0 {
+				wait = c.backoffFor(retries)
+			}
+			if retries >= c.maxRetries || wait > maxWait {
+				return apiErr
+			}
+			log.Printf("Twitch API 被限流，%s 后重试", wait.Round(time.Second))
+			if err := c.wait(ctx, wait); err != nil {
+				return err
+			}
+			retries++
+		case resp.StatusCode == http.StatusUnauthorized && state != nil && switches < c.pool.size():
+			// 应用令牌失效（被吊销或凭据已更换），record 已清除令牌，重新获取后重试
+			switches++
+		case resp.StatusCode >= 500 && retries < c.maxRetries:
+			if err := c.wait(ctx, c.backoffFor(retries)); err != nil {
+				return err
+			}
+			retries++
+		default:
+			return apiErr
+		}
+	}
+}
+
+// decode 解码 2xx 响应并关闭响应体
+func decode(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析 Twitch API 响应失败: %w", err)
+	}
+	return nil
+}
+
+// backoffFor 第 retry 次重试前的退避时间，从 backoff 开始每次翻倍，不超过 maxWait
+func (c *Client) backoffFor(retry int) time.Duration {
+	return min(c.backoff<<retry, maxWait)
+}
+
+// wait 等待 d，ctx 取消时提前返回
+func (c *Client) wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfter 从 429 响应中读取需要等待的时间：优先 Retry-After（秒），其次 Helix 的 Ratelimit-Reset（Unix 时间戳）
+func retryAfter(resp *http.Response) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("Ratelimit-Reset"), 10, 64); err == nil {
+		return time.Until(time.Unix(reset, 0))
+	}
+	return 0
+}
+
+// token 返回凭据的访问令牌，过期或尚未获取时使用 client credentials 重新获取
+func (c *Client) token(ctx context.Context, state *credentialState) (string, error) {
+	if token, ok := c.pool.cachedToken(state); ok {
+		return token, nil
+	}
+
+	form := url.Values{
+		"client_id":     {state.credential.ClientID},
+		"client_secret": {state.credential.ClientSecret},
+		"grant_type":    {"client_credentials"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil || tokenResp.AccessToken == "" {
+		return "", fmt.Errorf("获取访问令牌失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
+	}
+
+	c.pool.storeToken(state, tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn)*time.Second)
+	log.Printf("成功获取新的访问令牌 (Client ID: %s)", MaskClientID(state.credential.ClientID))
+	return tokenResp.AccessToken, nil
+}
+
+// Stats 凭据池中各组凭据的配额使用情况，按池中顺序排列
+func (c *Client) Stats() []CredentialStats {
+	return c.pool.stats()
+}
@@ -0,0 +1,198 @@
+package twitchclient
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// throttleCooldown Helix 返回 429 但没有 Retry-After 与 Ratelimit-Reset 时凭据的冷却时间
+const throttleCooldown = time.Minute
+
+// Credential Twitch 应用的 Client ID 与 Client Secret
+type Credential struct {
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+}
+
+// credentialState 凭据池中一组凭据的令牌与限流状态
+type credentialState struct {
+	credential  Credential
+	accessToken string
+	tokenExpiry time.Time
+
+	// 最近一次 Helix 响应头中的限流信息，limit 为 0 表示还没有请求过
+	limit     int
+	remaining int
+	resetAt   time.Time
+
+	requests      int64
+	throttled     int64
+	cooldownUntil time.Time
+}
+
+// credentialPool 应用凭据池，当前凭据被限流（429）时轮换到下一组
+type credentialPool struct {
+	mu          sync.Mutex
+	credentials []*credentialState
+	current     int
+}
+
+// newCredentialPool 按顺序创建凭据池，忽略空的与重复的 Client ID
+func newCredentialPool(credentials []Credential) *credentialPool {
+	pool := &credentialPool{}
+	seen := make(map[string]bool)
+	for _, credential := range credentials {
+		if credential.ClientID == "" || seen[credential.ClientID] {
+			continue
+		}
+		seen[credential.ClientID] = true
+		pool.credentials = append(pool.credentials, &credentialState{credential: credential})
+	}
+	return pool
+}
+
+// size 凭据数量
+func (p *credentialPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.credentials)
+}
+
+// hasAvailable 是否还有不在冷却中的凭据
+func (p *credentialPool) hasAvailable() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for _, state := range p.credentials {
+		if !now.Before(state.cooldownUntil) {
+			return true
+		}
+	}
+	return false
+}
+
+// pick 返回当前使用的凭据；当前凭据在冷却中时轮换到下一组可用凭据，全部冷却中时选最早恢复的一组
+func (p *credentialPool) pick() (*credentialState, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.credentials) == 0 {
+		return nil, fmt.Errorf("未配置 Twitch 凭据")
+	}
+
+	now := time.Now()
+	earliest := p.current
+	for i := 0; i < len(p.credentials); i++ {
+		index := (p.current + i) % len(p.credentials)
+		state := p.credentials[index]
+		if !now.Before(state.cooldownUntil) {
+			if index != p.current {
+				p.current = index
+				log.Printf("Twitch 凭据已轮换到第 %d 组 (共%d组)", index+1, len(p.credentials))
+			}
+			return state, nil
+		}
+		if state.cooldownUntil.Before(p.credentials[earliest].cooldownUntil) {
+			earliest = index
+		}
+	}
+	p.current = earliest
+	return p.credentials[earliest], nil
+}
+
+// cachedToken 返回凭据未过期的访问令牌
+func (p *credentialPool) cachedToken(state *credentialState) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if state.accessToken != "" && time.Now().Before(state.tokenExpiry) {
+		return state.accessToken, true
+	}
+	return "", false
+}
+
+// storeToken 保存新获取的访问令牌
+func (p *credentialPool) storeToken(state *credentialState, token string, expiresIn time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state.accessToken = token
+	state.tokenExpiry = time.Now().Add(expiresIn)
+}
+
+// record 根据 Helix 响应更新凭据的请求计数与限流状态，429 时凭据进入冷却直到可以重试
+func (p *credentialPool) record(state *credentialState, resp *http.Response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state.requests++
+	if limit, err := strconv.Atoi(resp.Header.Get("Ratelimit-Limit")); err == nil {
+		state.limit = limit
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get("Ratelimit-Remaining")); err == nil {
+		state.remaining = remaining
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("Ratelimit-Reset"), 10, 64); err == nil {
+		state.resetAt = time.Unix(reset, 0)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		state.throttled++
+		cooldown := retryAfter(resp)
+		if cooldown <= 0 {
+			cooldown = throttleCooldown
+		}
+		state.cooldownUntil = time.Now().Add(cooldown)
+	case http.StatusUnauthorized:
+		// 令牌失效，下次请求重新获取
+		state.accessToken = ""
+	}
+}
+
+// MaskClientID 只保留 Client ID 的前 6 位，用于日志与指标标签
+func MaskClientID(clientID string) string {
+	if len(clientID) <= 6 {
+		return clientID
+	}
+	return clientID[:6] + "***"
+}
+
+// CredentialStats 凭据池中一组凭据的配额使用情况
+type CredentialStats struct {
+	ClientID        string    `json:"client_id"` // 只保留前 6 位
+	Active          bool      `json:"active"`
+	RateLimit       int       `json:"rate_limit"`
+	Remaining       int       `json:"remaining"`
+	ResetAt         time.Time `json:"reset_at,omitempty"`
+	Requests        int64     `json:"requests"`
+	Throttled       int64     `json:"throttled"`
+	CoolingDown     bool      `json:"cooling_down"`
+	CooldownSeconds float64   `json:"cooldown_remaining_seconds"`
+}
+
+// stats 凭据池中各组凭据的当前状态，按池中顺序排列
+func (p *credentialPool) stats() []CredentialStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]CredentialStats, 0, len(p.credentials))
+	for i, state := range p.credentials {
+		cooldown := state.cooldownUntil.Sub(now).Seconds()
+		stats = append(stats, CredentialStats{
+			ClientID:        MaskClientID(state.credential.ClientID),
+			Active:          i == p.current,
+			RateLimit:       state.limit,
+			Remaining:       state.remaining,
+			ResetAt:         state.resetAt,
+			Requests:        state.requests,
+			Throttled:       state.throttled,
+			CoolingDown:     cooldown > 0,
+			CooldownSeconds: max(cooldown, 0),
+		})
+	}
+	return stats
+}